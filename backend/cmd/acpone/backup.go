@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/daodao97/acpone/internal/backup"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// loadDataBackend loads the config and opens its storage backend, with
+// sessions read from and written to the configured session store
+func loadDataBackend(configPath string) (storage.Backend, string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	backend, err := storage.NewBackend(cfg.Storage)
+	if err == nil {
+		_, backend, err = storage.OpenSessions(cfg.Storage, backend)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open storage: %v\n", err)
+		os.Exit(1)
+	}
+	return backend, config.LoadedConfigPath
+}
+
+// runBackup implements `acpone backup [-config path] [-o file]`
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	configPath := fs.String("config", "", "Config file path")
+	output := fs.String("o", fmt.Sprintf("acpone-backup-%s.zip", time.Now().Format("20060102-150405")), "Output file")
+	fs.Parse(args)
+
+	backend, cfgPath := loadDataBackend(*configPath)
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Backup failed: %v\n", err)
+		os.Exit(1)
+	}
+	manifest, err := backup.Create(f, backend, cfgPath)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*output)
+		fmt.Fprintf(os.Stderr, "Backup failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("📦 Backup written to %s (%d files)\n", *output, len(manifest.Files))
+}
+
+// runRestore implements `acpone restore [-config path] <file>`
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := fs.String("config", "", "Config file path to restore to")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: acpone restore [-config path] <backup.zip>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := config.EnsureConfigExists(); err != nil {
+		fmt.Printf("⚠️  Config initialization: %v\n", err)
+	}
+	backend, cfgPath := loadDataBackend(*configPath)
+
+	manifest, err := backup.RestoreBytes(data, backend, cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Restored %d files from backup created %s on %s\n", len(manifest.Files), manifest.CreatedAt, manifest.Host)
+	if manifest.ConfigPath != "" && cfgPath != "" {
+		fmt.Printf("   Config restored to %s\n", cfgPath)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conformance"
+)
+
+// runCheckAgent implements `acpone check-agent [-timeout 2m] <command> [args...]`
+func runCheckAgent(args []string) {
+	fs := flag.NewFlagSet("check-agent", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 2*time.Minute, "Timeout per protocol request")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: acpone check-agent [-timeout 2m] <command> [args...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	command := fs.Arg(0)
+	fmt.Printf("\n🔍 Checking ACP agent: %s\n\n", strings.Join(fs.Args(), " "))
+
+	results, err := conformance.Run(command, fs.Args()[1:], *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Check failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("\n📋 Conformance report")
+	fmt.Println(strings.Repeat("─", 50))
+	failed := false
+	for _, r := range results {
+		mark := "✓"
+		switch r.State {
+		case conformance.Fail:
+			mark = "✗"
+			failed = true
+		case conformance.Skipped:
+			mark = "–"
+		}
+		fmt.Printf("   %s %-28s %s\n", mark, r.Feature, r.Detail)
+	}
+	fmt.Println()
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// apiRequest calls a running server, sending $ACPONE_AUTH_TOKEN when set
+func apiRequest(method, url string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := os.Getenv(config.EnvAuthToken); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return http.DefaultClient.Do(req)
+}
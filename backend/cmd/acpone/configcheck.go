@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// runCheckConfig implements `acpone check-config [file]`, validating a config
+// file without starting or changing anything. Exits 1 when it has errors.
+func runCheckConfig(args []string) {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: acpone check-config [file]")
+		fmt.Fprintln(os.Stderr, "Checks $ACPONE_CONFIG or the first config file found when no file is given.")
+	}
+	fs.Parse(args)
+
+	path := fs.Arg(0)
+	if path == "" {
+		path = os.Getenv(config.EnvConfig)
+	}
+	if path == "" {
+		path = config.FindConfigPath()
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "No config file found")
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read config: %v\n", err)
+		os.Exit(1)
+	}
+
+	problems := config.Check(data)
+	for _, p := range problems {
+		mark := "⚠️ "
+		if p.Severity == config.SeverityError {
+			mark = "✗"
+		}
+		fmt.Printf("%s %s:%s\n", mark, path, p)
+	}
+	if config.HasErrors(problems) {
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %s is valid\n", path)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// runIssue implements `acpone issue [-server url] [-workspace id] [-agent id] <issue>`,
+// starting a conversation on a running server from a GitHub issue URL,
+// owner/repo#123 or #123 (the workspace's own repository)
+func runIssue(args []string) {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	server := fs.String("server", "http://localhost:3000", "acpone server URL")
+	workspace := fs.String("workspace", "", "Workspace ID (default: the clone of the issue's repository)")
+	agentID := fs.String("agent", "", "Agent ID (default: the workspace's default agent)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "  acpone issue [-server url] [-workspace id] [-agent id] <issue URL | owner/repo#123 | #123>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	issue := fs.Arg(0)
+	// The issue may come before or after the flags
+	fs.Parse(fs.Args()[min(1, fs.NArg()):])
+	if issue == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"issue":       issue,
+		"workspaceId": *workspace,
+		"agent":       *agentID,
+	})
+	resp, err := apiRequest("POST", strings.TrimRight(*server, "/")+"/api/issues/start", body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach acpone: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Error          string `json:"error"`
+		ConversationID string `json:"conversationId"`
+		WorkspaceID    string `json:"workspaceId"`
+		Issue          struct {
+			Ref   string `json:"ref"`
+			Title string `json:"title"`
+		} `json:"issue"`
+	}
+	json.NewDecoder(resp.Body).Decode(&data)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", data.Error)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Started %s \"%s\" in workspace %s\n", data.Issue.Ref, data.Issue.Title, data.WorkspaceID)
+	fmt.Printf("   Conversation: %s\n", data.ConversationID)
+}
@@ -11,34 +11,90 @@ import (
 
 	"github.com/daodao97/acpone/internal/api"
 	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/logging"
+	"github.com/daodao97/acpone/internal/storage"
+	"github.com/daodao97/acpone/internal/sysutil"
 	"github.com/daodao97/acpone/web"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check-agent":
+			runCheckAgent(os.Args[2:])
+			return
+		case "check-config":
+			runCheckConfig(os.Args[2:])
+			return
+		case "backup":
+			runBackup(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "permissions":
+			runPermissions(os.Args[2:])
+			return
+		case "issue":
+			runIssue(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		configPath = flag.String("config", "", "Config file path")
-		port       = flag.String("port", "3000", "Server port")
+		port       = flag.String("port", "", "Server port (default 3000, or $ACPONE_PORT)")
 		webDir     = flag.String("web", "", "Web directory (overrides embedded)")
+		record     = flag.Bool("record", false, "Record raw ACP traffic to .acprec files")
+		offline    = flag.Bool("offline", false, "Use cached npm packages only, never install from the registry")
+		check      = flag.Bool("check", false, "Wait for the setup checks and print a report before serving")
+		strict     = flag.Bool("require-ready", false, "Exit if the setup checks find missing dependencies (implies -check)")
+		takeover   = flag.Bool("takeover", false, "Shut down an acpone already running on the port and replace it")
 	)
 	flag.Parse()
+	if *configPath == "" {
+		*configPath = os.Getenv(config.EnvConfig)
+	}
 
 	// Ensure config exists (copy example if needed)
 	if err := config.EnsureConfigExists(); err != nil {
 		fmt.Printf("⚠️  Config initialization: %v\n", err)
 	}
 
-	// Load config
+	// Load config, ACPONE_* environment variables taking precedence
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
 		os.Exit(1)
 	}
+	cfg.ApplyEnv()
+	if *port != "" {
+		cfg.Server.Port = *port
+	}
+	if cfg.Server.DataDir != "" {
+		sysutil.SetDataDir(cfg.Server.DataDir)
+	}
 
 	if err := cfg.Validate(); err != nil {
 		fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *record {
+		if cfg.Debug == nil {
+			cfg.Debug = &config.DebugConfig{}
+		}
+		cfg.Debug.Record = true
+	}
+	if *offline {
+		cfg.Offline = true
+	}
+	closeLog, err := logging.Setup(cfg.Log)
+	if err != nil {
+		fmt.Printf("⚠️  Log file unavailable: %v\n", err)
+	}
+	defer closeLog()
+
 	// Print startup info
 	printStartupInfo(cfg, config.LoadedConfigPath)
 
@@ -57,27 +113,53 @@ func main() {
 		}
 	}
 
+	// Another instance on the port is reported before agents get started
+	addr := cfg.Server.Addr()
+	if err := claimPort(cfg, addr, *takeover); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create server
 	server := api.NewServer(cfg, staticFS)
+	printWorkspaces(server.Workspaces())
 
-	// Graceful shutdown
-	sigCh := make(chan os.Signal, 1)
+	if *check || *strict {
+		if !checkSetup(server, *strict) {
+			server.Shutdown()
+			os.Exit(1)
+		}
+	}
+
+	// Graceful shutdown: stop accepting requests, let those in flight
+	// finish and stop the agents. A second signal exits right away. An
+	// instance taking over the port shuts this one down the same way.
+	sigCh := make(chan os.Signal, 2)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	stopped := make(chan struct{})
 
 	go func() {
-		<-sigCh
-		fmt.Println("\nShutting down...")
+		select {
+		case <-sigCh:
+			fmt.Println("\nShutting down...")
+		case <-server.ShutdownRequested():
+			fmt.Println("\nAnother acpone took over the port, shutting down...")
+		}
+		go func() {
+			<-sigCh
+			os.Exit(1)
+		}()
 		server.Shutdown()
-		os.Exit(0)
+		close(stopped)
 	}()
 
 	// Start server
-	printServerBanner(*port)
-	addr := ":" + *port
+	printServerBanner(api.LocalURL(addr))
 	if err := server.ListenAndServe(addr); err != nil {
 		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
 		os.Exit(1)
 	}
+	<-stopped
 }
 
 func printStartupInfo(cfg *config.Config, configPath string) {
@@ -89,8 +171,18 @@ func printStartupInfo(cfg *config.Config, configPath string) {
 		fmt.Println("   Config file: (using defaults)")
 	}
 	fmt.Printf("   Default agent: %s\n", cfg.DefaultAgent)
-	if cfg.DefaultWorkspace != "" {
-		fmt.Printf("   Default workspace: %s\n", cfg.DefaultWorkspace)
+	fmt.Printf("   Data directory: %s\n", sysutil.DataDir())
+	if dir := logging.Dir(cfg.Log); dir != "" {
+		fmt.Printf("   Logs: %s (%s)\n", dir, cfg.Log.LevelName())
+	}
+	if cfg.Server.AuthToken != "" {
+		fmt.Println("   Auth: token required")
+	}
+	if addr := cfg.Server.AdminAddr(); addr != "" {
+		fmt.Printf("   Admin endpoints: http://%s\n", addr)
+	}
+	if cfg.Offline {
+		fmt.Println("   Offline mode: cached packages only")
 	}
 	fmt.Println()
 
@@ -100,6 +192,8 @@ func printStartupInfo(cfg *config.Config, configPath string) {
 		isDefault := ""
 		if agent.ID == cfg.DefaultAgent {
 			isDefault = " (default)"
+		} else if !agent.IsEnabled() {
+			isDefault = " (disabled)"
 		}
 		permission := getPermissionLabel(agent.PermissionMode)
 		fmt.Printf("   %s%s\n", agent.Name, isDefault)
@@ -109,12 +203,17 @@ func printStartupInfo(cfg *config.Config, configPath string) {
 		fmt.Println()
 	}
 
-	if len(cfg.Workspaces) > 0 {
+}
+
+func printWorkspaces(store *storage.WorkspaceStore) {
+	workspaces := store.List()
+	if len(workspaces) > 0 {
+		defaultID := store.Default()
 		fmt.Println("📁 Workspaces")
 		fmt.Println(strings.Repeat("─", 50))
-		for _, ws := range cfg.Workspaces {
+		for _, ws := range workspaces {
 			isDefault := ""
-			if ws.ID == cfg.DefaultWorkspace {
+			if ws.ID == defaultID {
 				isDefault = " (default)"
 			}
 			fmt.Printf("   %s%s\n", ws.Name, isDefault)
@@ -135,14 +234,20 @@ func getPermissionLabel(mode string) string {
 	}
 }
 
-func printServerBanner(port string) {
-	fmt.Printf(`
-╔════════════════════════════════════════════════╗
-║           acpone Web Interface                 ║
-╠════════════════════════════════════════════════╣
-║  Open http://localhost:%s in your browser   ║
-║  Press Ctrl+C to stop                          ║
-╚════════════════════════════════════════════════╝
+// printServerBanner prints the URL the server listens on, the box growing
+// with it
+func printServerBanner(url string) {
+	title := "          acpone Web Interface"
+	lines := []string{" Open " + url + " in your browser", " Press Ctrl+C to stop"}
+	width := 48
+	for _, l := range lines {
+		width = max(width, len(l)+2)
+	}
+	bar := strings.Repeat("═", width)
 
-`, port)
+	fmt.Printf("\n╔%s╗\n║ %-*s║\n╠%s╣\n", bar, width-1, title, bar)
+	for _, l := range lines {
+		fmt.Printf("║ %-*s║\n", width-1, l)
+	}
+	fmt.Printf("╚%s╝\n\n", bar)
 }
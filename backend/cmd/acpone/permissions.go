@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/daodao97/acpone/internal/api"
+)
+
+// runPermissions implements `acpone permissions [-server url] list` and
+// `acpone permissions [-server url] approve|deny <id> [-option id]`,
+// answering permission requests of a running server from the terminal
+func runPermissions(args []string) {
+	fs := flag.NewFlagSet("permissions", flag.ExitOnError)
+	server := fs.String("server", "http://localhost:3000", "acpone server URL")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "  acpone permissions [-server url] list")
+		fmt.Fprintln(os.Stderr, "  acpone permissions [-server url] approve <id> [-option allow]")
+		fmt.Fprintln(os.Stderr, "  acpone permissions [-server url] deny <id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	base := strings.TrimRight(*server, "/")
+
+	switch fs.Arg(0) {
+	case "list", "":
+		listPermissions(base)
+	case "approve", "deny":
+		answer := flag.NewFlagSet(fs.Arg(0), flag.ExitOnError)
+		option := answer.String("option", "allow", "Option id, or allow/deny for the first option of that kind")
+		// The id may come before or after the flags
+		answer.Parse(fs.Args()[1:])
+		id := answer.Arg(0)
+		answer.Parse(answer.Args()[min(1, answer.NArg()):])
+		if id == "" {
+			fs.Usage()
+			os.Exit(2)
+		}
+		choice := *option
+		if fs.Arg(0) == "deny" {
+			choice = "deny"
+		}
+		answerPermission(base, id, choice)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func listPermissions(base string) {
+	resp, err := apiRequest("GET", base+"/api/permissions", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach acpone: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Permissions []api.PendingPermission `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(data.Permissions) == 0 {
+		fmt.Println("No pending permission requests")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tAGENT\tREQUEST\tOPTIONS\tWAITING")
+	for _, p := range data.Permissions {
+		var options []string
+		for _, o := range p.Request.Options {
+			options = append(options, o.OptionID)
+		}
+		waiting := time.Since(time.UnixMilli(p.CreatedAt)).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.ID, p.AgentID, p.Title, strings.Join(options, ","), waiting)
+	}
+	w.Flush()
+}
+
+func answerPermission(base, id, option string) {
+	body, _ := json.Marshal(map[string]string{"id": id, "option": option})
+	resp, err := apiRequest("POST", base+"/api/permissions/answer", body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach acpone: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var data struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&data)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", data.Error)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Answered %s with %s\n", id, option)
+}
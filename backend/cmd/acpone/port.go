@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/daodao97/acpone/internal/api"
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// claimPort makes sure addr can be listened on. Another acpone found there
+// is asked to shut down when takeover is set, and reported otherwise.
+func claimPort(cfg *config.Config, addr string, takeover bool) error {
+	occ := api.ProbeAddr(addr)
+	if occ == nil {
+		return nil
+	}
+	if !occ.ACPone {
+		return fmt.Errorf("%s is in use by another program\n   Choose another port with -port or $%s", occ.URL, config.EnvPort)
+	}
+	version := ""
+	if occ.Version != "" {
+		version = " " + occ.Version
+	}
+	if !takeover {
+		return fmt.Errorf("acpone%s is already running at %s\n   Open it there, or start with -takeover to replace it", version, occ.URL)
+	}
+
+	fmt.Printf("🔁 Taking over %s from acpone%s...\n", occ.URL, version)
+	url := occ.URL
+	if admin := cfg.Server.AdminAddr(); admin != "" {
+		url = api.LocalURL(admin)
+	}
+	token := ""
+	if secrets := cfg.Server.Secrets(); len(secrets) > 0 {
+		token = secrets[0]
+	}
+	return api.Takeover(url, token, addr)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/api"
+)
+
+// setupCheckTimeout bounds how long serving waits for the setup checks
+const setupCheckTimeout = 2 * time.Minute
+
+// checkSetup waits for the server's dependency checks and prints them, as a
+// headless server has no setup page to show them. It returns false when
+// strict and a dependency is missing.
+func checkSetup(server *api.Server, strict bool) bool {
+	fmt.Println("🔧 Setup")
+	fmt.Println(strings.Repeat("─", 50))
+
+	ctx, cancel := context.WithTimeout(context.Background(), setupCheckTimeout)
+	defer cancel()
+	status, err := server.WaitSetup(ctx)
+	if err != nil {
+		fmt.Printf("   ⚠️  Checks did not finish: %v\n\n", err)
+		return !strict
+	}
+
+	groups := []struct {
+		name  string
+		items []api.DependencyItem
+	}{
+		{"Environment", status.Environment},
+		{"Agents", status.Agents},
+		{"ACP packages", status.ACPPackages},
+	}
+	for _, g := range groups {
+		if len(g.items) == 0 {
+			continue
+		}
+		fmt.Printf("   %s\n", g.name)
+		for _, item := range g.items {
+			mark := "✗"
+			switch item.Status {
+			case "ready":
+				mark = "✓"
+			case "needs_login":
+				mark = "!"
+			}
+			name := item.Name
+			if item.Package != "" {
+				name += " (" + item.Package + ")"
+			}
+			fmt.Printf("     %s %-40s %s\n", mark, name, item.Message)
+			if item.Install != "" && item.Status != "ready" {
+				fmt.Printf("       Install: %s\n", item.Install)
+			}
+			if item.Login != "" {
+				fmt.Printf("       Log in: %s\n", item.Login)
+			}
+		}
+	}
+	if status.Ready {
+		fmt.Println("   All dependencies ready")
+	} else {
+		fmt.Println("   Missing dependencies can be installed from the setup page")
+	}
+	fmt.Println()
+
+	return status.Ready || !strict
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/daodao97/acpone/gotray"
+	"github.com/daodao97/acpone/internal/api"
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// occupiedError 表示默认端口上已运行另一个 ACPone，例如同时启动的 CLI
+type occupiedError struct {
+	occ *api.Occupant
+	cfg *config.Config
+}
+
+func (e *occupiedError) Error() string {
+	return "ACPone is already running at " + e.occ.URL
+}
+
+// instanceMenu 在默认端口被另一个 ACPone 占用时出现：打开已有的实例，
+// 或者让它退出并接管端口
+type instanceMenu struct {
+	open     *gotray.MenuItem
+	takeover *gotray.MenuItem
+
+	url string
+	// 通知对方退出用的地址和令牌，有管理端口时走管理端口
+	shutdownURL string
+	token       string
+}
+
+func addInstanceMenu(app *gotray.App) *instanceMenu {
+	m := &instanceMenu{}
+	m.open = app.AddMenu("Open Existing ACPone", func(*gotray.MenuItem) {
+		if m.url != "" {
+			gotray.OpenURL(m.url + authQuery)
+		}
+	})
+	m.takeover = app.AddMenu("Take Over Port "+defaultPort, func(item *gotray.MenuItem) {
+		item.Disable()
+		defer item.Enable()
+
+		if err := api.Takeover(m.shutdownURL, m.token, ":"+defaultPort); err != nil {
+			gotray.NotifySimple(appName, "Failed to take over: "+err.Error())
+			return
+		}
+		launch()
+	})
+	m.hide()
+	return m
+}
+
+// show 显示菜单，对方与本应用共用配置，令牌和管理端口取自 cfg
+func (m *instanceMenu) show(occ *api.Occupant, cfg *config.Config) {
+	m.url = occ.URL
+	m.shutdownURL = occ.URL
+	if addr := cfg.Server.AdminAddr(); addr != "" {
+		m.shutdownURL = api.LocalURL(addr)
+	}
+	m.token = ""
+	if secrets := cfg.Server.Secrets(); len(secrets) > 0 {
+		m.token = secrets[0]
+	}
+	m.open.Show()
+	m.takeover.Show()
+}
+
+func (m *instanceMenu) hide() {
+	m.open.Hide()
+	m.takeover.Hide()
+}
+
+// watchTakeover 在另一个 ACPone 接管端口时停止本机服务，返回停止函数
+func watchTakeover(s *api.Server) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+		case <-s.ShutdownRequested():
+			stopServer()
+			setStopped()
+			gotray.NotifySimple(appName, "Another ACPone took over port "+defaultPort+", server stopped")
+		}
+	}()
+	return func() { close(stop) }
+}
@@ -2,155 +2,22 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"net"
-	"os"
-	"path/filepath"
-	"strings"
+	"net/url"
 
 	"github.com/daodao97/acpone/gotray"
 	"github.com/daodao97/acpone/internal/api"
 	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/logging"
+	"github.com/daodao97/acpone/internal/sysutil"
 	"github.com/daodao97/acpone/web"
 )
 
 func init() {
 	// GUI 应用不会继承终端的 PATH，需要手动设置
-	setupPath()
-}
-
-func setupPath() {
-	home, _ := os.UserHomeDir()
-	if home == "" {
-		return
-	}
-
-	var extraPaths []string
-	var pathSep string
-
-	switch gotray.OS() {
-	case "windows":
-		pathSep = ";"
-		extraPaths = getWindowsPaths(home)
-	case "darwin":
-		pathSep = ":"
-		extraPaths = getMacOSPaths(home)
-	default: // linux
-		pathSep = ":"
-		extraPaths = getLinuxPaths(home)
-	}
-
-	// 获取当前 PATH
-	currentPath := os.Getenv("PATH")
-	pathSet := make(map[string]bool)
-	for _, p := range strings.Split(currentPath, pathSep) {
-		pathSet[p] = true
-	}
-
-	// 添加新路径（如果存在且不重复）
-	var newPaths []string
-	for _, p := range extraPaths {
-		if _, err := os.Stat(p); err == nil && !pathSet[p] {
-			newPaths = append(newPaths, p)
-			pathSet[p] = true
-		}
-	}
-
-	if len(newPaths) > 0 {
-		finalPath := strings.Join(newPaths, pathSep) + pathSep + currentPath
-		os.Setenv("PATH", finalPath)
-	}
-}
-
-func getMacOSPaths(home string) []string {
-	paths := []string{
-		"/usr/local/bin",
-		"/opt/homebrew/bin", // Homebrew (Apple Silicon)
-		"/opt/homebrew/sbin",
-		filepath.Join(home, ".local", "bin"),      // pipx, etc.
-		filepath.Join(home, ".cargo", "bin"),      // Rust
-		filepath.Join(home, "go", "bin"),          // Go
-		filepath.Join(home, ".npm-global", "bin"), // npm global
-		filepath.Join(home, ".bun", "bin"),        // Bun
-	}
-
-	// nvm 安装的 node
-	paths = append(paths, findNodeVersions(filepath.Join(home, ".nvm", "versions", "node"), "bin")...)
-
-	// fnm 安装的 node
-	fnmDir := filepath.Join(home, "Library", "Application Support", "fnm", "node-versions")
-	paths = append(paths, findNodeVersions(fnmDir, "installation", "bin")...)
-
-	return paths
-}
-
-func getLinuxPaths(home string) []string {
-	paths := []string{
-		"/usr/local/bin",
-		"/snap/bin",                               // Snap packages
-		filepath.Join(home, ".local", "bin"),      // pipx, etc.
-		filepath.Join(home, ".cargo", "bin"),      // Rust
-		filepath.Join(home, "go", "bin"),          // Go
-		filepath.Join(home, ".npm-global", "bin"), // npm global
-		filepath.Join(home, ".bun", "bin"),        // Bun
-	}
-
-	// nvm 安装的 node
-	paths = append(paths, findNodeVersions(filepath.Join(home, ".nvm", "versions", "node"), "bin")...)
-
-	// fnm 安装的 node (Linux)
-	fnmDir := filepath.Join(home, ".local", "share", "fnm", "node-versions")
-	paths = append(paths, findNodeVersions(fnmDir, "installation", "bin")...)
-
-	return paths
-}
-
-func getWindowsPaths(home string) []string {
-	appData := os.Getenv("APPDATA")
-	localAppData := os.Getenv("LOCALAPPDATA")
-	programFiles := os.Getenv("ProgramFiles")
-
-	paths := []string{
-		filepath.Join(programFiles, "nodejs"),                                     // Node.js
-		filepath.Join(appData, "npm"),                                             // npm global
-		filepath.Join(localAppData, "Programs", "Python", "Python311", "Scripts"), // Python
-		filepath.Join(localAppData, "Programs", "Python", "Python312", "Scripts"),
-		filepath.Join(home, ".cargo", "bin"),           // Rust
-		filepath.Join(home, "go", "bin"),               // Go
-		filepath.Join(home, ".bun", "bin"),             // Bun
-		filepath.Join(appData, "fnm", "node-versions"), // fnm
-	}
-
-	// nvm-windows
-	nvmHome := os.Getenv("NVM_HOME")
-	if nvmHome != "" {
-		paths = append(paths, nvmHome)
-		nvmSymlink := os.Getenv("NVM_SYMLINK")
-		if nvmSymlink != "" {
-			paths = append(paths, nvmSymlink)
-		}
-	}
-
-	// fnm 安装的 node (Windows)
-	fnmDir := filepath.Join(appData, "fnm", "node-versions")
-	paths = append(paths, findNodeVersions(fnmDir, "installation")...)
-
-	return paths
-}
-
-func findNodeVersions(baseDir string, subPaths ...string) []string {
-	var paths []string
-	entries, err := os.ReadDir(baseDir)
-	if err != nil {
-		return paths
-	}
-	for _, entry := range entries {
-		if entry.IsDir() {
-			parts := append([]string{baseDir, entry.Name()}, subPaths...)
-			paths = append(paths, filepath.Join(parts...))
-		}
-	}
-	return paths
+	sysutil.RefreshPath()
 }
 
 //go:embed icon/*
@@ -184,10 +51,30 @@ var (
 	server    *api.Server
 	isRunning bool
 	serverURL string
+	// Query signing the browser in when the config sets server.authToken or apiKeys
+	authQuery string
+
+	permMenu        *permissionMenu
+	stopPermissions func()
+
+	statMenu   *statusMenu
+	stopStatus func()
+
+	instMenu     *instanceMenu
+	stopTakeover func()
+
+	// 随服务启停切换的托盘菜单
+	tray        *gotray.App
+	openMenu    *gotray.MenuItem
+	serviceMenu *gotray.MenuItem
+
+	// 关闭日志文件，重启服务时按新配置重新打开
+	closeLog func()
 )
 
 func main() {
 	loadIcons()
+	api.Version = appVersion
 
 	app := &gotray.App{
 		Name:        appName,
@@ -200,56 +87,51 @@ func main() {
 		IconOffWin:  iconOffWin,
 		OnReady:     onReady,
 		OnExit:      onExit,
+
+		OnTrayUnavailable: onTrayUnavailable,
 	}
 
 	app.Run()
 }
 
 func onReady(app *gotray.App) {
+	tray = app
 	app.SetTooltip(appName + " - ACP Gateway")
 
 	// 打开浏览器菜单
-	openMenu := app.AddMenu("Open Dashboard", func(item *gotray.MenuItem) {
+	openMenu = app.AddMenu("Open Dashboard", func(item *gotray.MenuItem) {
 		if serverURL != "" {
-			gotray.OpenURL(serverURL)
+			gotray.OpenURL(serverURL + authQuery)
 		}
 	})
 
+	// 默认端口上已运行的另一个 ACPone
+	instMenu = addInstanceMenu(app)
+
+	// 待确认的权限请求
+	permMenu = addPermissionMenu(app)
+
+	// Agent 状态
+	statMenu = addStatusMenu(app)
+
 	app.AddSeparator()
 
 	// 启动/停止服务菜单
-	serviceMenu := app.AddMenu("Start Server", func(item *gotray.MenuItem) {
+	serviceMenu = app.AddMenu("Start Server", func(item *gotray.MenuItem) {
 		item.Disable()
 		defer item.Enable()
 
 		if isRunning {
 			stopServer()
-			item.SetTitle("Start Server")
-			app.SetIconOff()
-			openMenu.Disable()
+			setStopped()
 			gotray.NotifySimple(appName, "Server stopped")
 		} else {
-			if err := startServer(); err != nil {
-				gotray.NotifySimple(appName, "Failed to start: "+err.Error())
-				return
-			}
-			item.SetTitle("Stop Server")
-			app.SetIconOn()
-			openMenu.Enable()
-			gotray.NotifySimple(appName, "Server started at "+serverURL)
+			launch()
 		}
 	})
 
 	// 自动启动服务器
-	if err := startServer(); err != nil {
-		gotray.NotifySimple(appName, "Failed to start: "+err.Error())
-		serviceMenu.SetTitle("Start Server")
-		openMenu.Disable()
-	} else {
-		serviceMenu.SetTitle("Stop Server")
-		app.SetIconOn()
-		gotray.NotifySimple(appName, "Server started at "+serverURL)
-	}
+	launch()
 
 	app.AddSeparator()
 
@@ -296,11 +178,56 @@ func onReady(app *gotray.App) {
 	})
 }
 
+// onTrayUnavailable opens the dashboard when the tray icon can't be shown,
+// e.g. on GNOME without the AppIndicator extension, so the app doesn't seem
+// dead
+func onTrayUnavailable(app *gotray.App) {
+	const warning = "No system tray found, the ACPone icon won't appear. " +
+		"On GNOME, install the AppIndicator and KStatusNotifierItem Support extension."
+	fmt.Println("!!! " + warning)
+	gotray.NotifySimple(appName, warning)
+	if serverURL != "" {
+		gotray.OpenURL(serverURL + authQuery)
+	}
+}
+
 func onExit() {
 	stopServer()
 	fmt.Println("ACPone exited")
 }
 
+// launch 启动服务并刷新菜单，默认端口已运行另一个 ACPone 时改为提供打开或接管
+func launch() {
+	err := startServer()
+	var occupied *occupiedError
+	switch {
+	case errors.As(err, &occupied):
+		setStopped()
+		instMenu.show(occupied.occ, occupied.cfg)
+		gotray.NotifySimple(appName, "ACPone is already running at "+occupied.occ.URL+", open it or take over the port from the menu")
+	case err != nil:
+		setStopped()
+		gotray.NotifySimple(appName, "Failed to start: "+err.Error())
+	default:
+		instMenu.hide()
+		serviceMenu.SetTitle("Stop Server")
+		tray.SetIconOn()
+		openMenu.Enable()
+		msg := "Server started at " + serverURL
+		if serverURL != api.LocalURL(":"+defaultPort) {
+			msg = "Port " + defaultPort + " is used by another program, server started at " + serverURL
+		}
+		gotray.NotifySimple(appName, msg)
+	}
+}
+
+// setStopped 把菜单和图标切换到服务已停止的状态
+func setStopped() {
+	serviceMenu.SetTitle("Start Server")
+	tray.SetIconOff()
+	openMenu.Disable()
+}
+
 func startServer() error {
 	// 确保配置存在
 	if err := config.EnsureConfigExists(); err != nil {
@@ -317,15 +244,40 @@ func startServer() error {
 		return fmt.Errorf("validate config: %w", err)
 	}
 
+	authQuery = ""
+	if secrets := cfg.Server.Secrets(); len(secrets) > 0 {
+		authQuery = "/?token=" + url.QueryEscape(secrets[0])
+	}
+
+	// 默认端口上是另一个 ACPone 时交给用户选择，是其他程序时换一个可用端口
+	port := defaultPort
+	if occ := api.ProbeAddr(":" + port); occ != nil {
+		if occ.ACPone {
+			return &occupiedError{occ: occ, cfg: cfg}
+		}
+		port = findAvailablePort(defaultPort)
+	}
+
+	var logErr error
+	closeLog, logErr = logging.Setup(cfg.Log)
+	if logErr != nil {
+		fmt.Printf("Log file unavailable: %v\n", logErr)
+	}
+
 	// 获取静态文件
 	staticFS, _ := web.FS()
 
-	// 查找可用端口
-	port := findAvailablePort(defaultPort)
 	serverURL = fmt.Sprintf("http://localhost:%s", port)
 
 	// 创建并启动服务器
 	server = api.NewServer(cfg, staticFS)
+	if permMenu != nil {
+		stopPermissions = server.OnPermissions(permMenu.update)
+	}
+	if statMenu != nil {
+		stopStatus = statMenu.watch(server)
+	}
+	stopTakeover = watchTakeover(server)
 
 	go func() {
 		addr := ":" + port
@@ -339,10 +291,28 @@ func startServer() error {
 }
 
 func stopServer() {
+	if stopPermissions != nil {
+		stopPermissions()
+		stopPermissions = nil
+		permMenu.update(nil)
+	}
+	if stopStatus != nil {
+		stopStatus()
+		stopStatus = nil
+		statMenu.update(nil)
+	}
+	if stopTakeover != nil {
+		stopTakeover()
+		stopTakeover = nil
+	}
 	if server != nil {
 		server.Shutdown()
 		server = nil
 	}
+	if closeLog != nil {
+		closeLog()
+		closeLog = nil
+	}
 	isRunning = false
 	serverURL = ""
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/daodao97/acpone/gotray"
+	"github.com/daodao97/acpone/internal/api"
+)
+
+// 托盘菜单最多同时显示的待确认权限数
+const permissionSlots = 5
+
+// permissionMenu 在托盘中列出待确认的权限请求，可直接允许或拒绝，
+// 无需打开浏览器
+type permissionMenu struct {
+	mu     sync.Mutex
+	parent *gotray.MenuItem
+	items  []*gotray.MenuItem
+	ids    []string        // 每个菜单位对应的权限 ID
+	seen   map[string]bool // 已通知过的权限
+}
+
+func addPermissionMenu(app *gotray.App) *permissionMenu {
+	m := &permissionMenu{
+		ids:  make([]string, permissionSlots),
+		seen: make(map[string]bool),
+	}
+	m.parent = app.AddMenuWithOptions(&gotray.MenuItem{Title: "Permissions", Hidden: true})
+	for i := 0; i < permissionSlots; i++ {
+		slot := i
+		item := m.parent.AddSubMenu("", nil)
+		item.AddSubMenu("Allow", func(*gotray.MenuItem) { m.answer(slot, "allow") })
+		item.AddSubMenu("Deny", func(*gotray.MenuItem) { m.answer(slot, "deny") })
+		item.Hide()
+		m.items = append(m.items, item)
+	}
+	return m
+}
+
+// update 刷新菜单，并为新出现的请求发送系统通知
+func (m *permissionMenu) update(list []api.PendingPermission) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending := make(map[string]bool)
+	for i, item := range m.items {
+		if i >= len(list) {
+			m.ids[i] = ""
+			item.Hide()
+			continue
+		}
+		p := list[i]
+		m.ids[i] = p.ID
+		item.SetTitle(fmt.Sprintf("%s: %s", p.AgentID, p.Title))
+		item.Show()
+	}
+	for _, p := range list {
+		pending[p.ID] = true
+		if !m.seen[p.ID] {
+			m.seen[p.ID] = true
+			msg := fmt.Sprintf("%s wants to: %s", p.AgentID, p.Title)
+			go gotray.NotifySimple(appName, msg+" (approve from the tray menu)")
+		}
+	}
+	for id := range m.seen {
+		if !pending[id] {
+			delete(m.seen, id)
+		}
+	}
+
+	if len(list) == 0 {
+		m.parent.Hide()
+		return
+	}
+	m.parent.SetTitle(fmt.Sprintf("Permissions (%d)", len(list)))
+	m.parent.Show()
+}
+
+func (m *permissionMenu) answer(slot int, choice string) {
+	m.mu.Lock()
+	id := m.ids[slot]
+	m.mu.Unlock()
+	if id == "" || server == nil {
+		return
+	}
+	if err := server.AnswerPermission(id, choice); err != nil {
+		gotray.NotifySimple(appName, "Permission: "+err.Error())
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/gotray"
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/api"
+)
+
+// 托盘菜单最多显示的 Agent 数
+const agentSlots = 8
+
+// 状态轮询间隔
+const statusInterval = 2 * time.Second
+
+// statusMenu 轮询 server.Status()（即 /api/status），刷新托盘提示文字
+// 和 Agent 状态子菜单
+type statusMenu struct {
+	app    *gotray.App
+	parent *gotray.MenuItem
+	items  []*gotray.MenuItem
+	pause  *gotray.MenuItem
+}
+
+func addStatusMenu(app *gotray.App) *statusMenu {
+	m := &statusMenu{app: app}
+	m.parent = app.AddMenuWithOptions(&gotray.MenuItem{Title: "Agents", Hidden: true})
+	// 保持可用，禁用的菜单项在 macOS 上会把状态圆点画成灰色；点击打开主界面
+	for i := 0; i < agentSlots; i++ {
+		item := m.parent.AddSubMenu("", func(*gotray.MenuItem) {
+			gotray.OpenURL(serverURL + authQuery)
+		})
+		item.Hide()
+		m.items = append(m.items, item)
+	}
+	// 暂停后不再接受新的对话，进行中的对话结束后停止所有 Agent 进程，腾出 CPU 和电量
+	m.pause = app.AddCheckbox("Pause Agents", false, func(item *gotray.MenuItem) {
+		if server == nil {
+			return
+		}
+		paused := !server.AgentsPaused()
+		server.SetAgentsPaused(paused)
+		if paused {
+			item.Check()
+			gotray.NotifySimple(appName, "Agents paused, running chats finish first")
+		} else {
+			item.Uncheck()
+			gotray.NotifySimple(appName, "Agents resumed")
+		}
+	})
+	m.pause.Hide()
+	return m
+}
+
+// watch 定时读取服务器状态，返回停止函数
+func (m *statusMenu) watch(s *api.Server) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+		for {
+			st := s.Status()
+			m.update(&st)
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// update 刷新提示文字和子菜单，st 为 nil 表示服务已停止
+func (m *statusMenu) update(st *api.Status) {
+	if st == nil {
+		m.app.SetTooltip(appName + " - ACP Gateway")
+		m.parent.Hide()
+		m.pause.Hide()
+		return
+	}
+
+	turns := make(map[string]int)
+	for _, t := range st.ActiveTurns {
+		turns[t.AgentID]++
+	}
+	for i, item := range m.items {
+		if i >= len(st.Agents) {
+			item.Hide()
+			continue
+		}
+		a := st.Agents[i]
+		title := fmt.Sprintf("%s: %s", a.ID, agentState(a))
+		if n := turns[a.ID]; n > 0 {
+			title += fmt.Sprintf(" · %d running", n)
+		}
+		item.SetTitle(title)
+		item.SetTooltip(a.Error)
+		item.SetStatus(agentDot(a))
+		item.Show()
+	}
+	m.parent.Show()
+	// 也可能通过 /api/agents/pause 切换
+	if st.Paused != m.pause.Checked() {
+		if st.Paused {
+			m.pause.Check()
+		} else {
+			m.pause.Uncheck()
+		}
+	}
+	m.pause.Show()
+
+	parts := []string{appName + " " + st.Version}
+	if st.Paused {
+		parts = append(parts, "paused")
+	}
+	if !st.Ready {
+		parts = append(parts, "setup incomplete")
+	}
+	if n := len(st.ActiveTurns); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d running", n))
+	}
+	if st.Permissions > 0 {
+		parts = append(parts, fmt.Sprintf("%d awaiting permission", st.Permissions))
+	}
+	m.app.SetTooltip(strings.Join(parts, " · "))
+}
+
+// agentDot 返回 Agent 状态圆点的颜色：就绪为绿色，出错或无响应为红色，
+// 启动中为黄色，未启动或已禁用为灰色（Linux 托盘不显示菜单图标）
+func agentDot(a api.AgentStatus) color.Color {
+	switch {
+	case !a.Enabled:
+		return gotray.DotGray
+	case !a.Healthy || a.Init == "error" || a.Process == agent.StatusError:
+		return gotray.DotRed
+	case a.Process == agent.StatusRunning && a.Init == "ready":
+		return gotray.DotGreen
+	case a.Process == agent.StatusStarting || a.Process == agent.StatusRunning:
+		return gotray.DotYellow
+	default:
+		return gotray.DotGray
+	}
+}
+
+// agentState 把 Agent 状态压缩成一个词
+func agentState(a api.AgentStatus) string {
+	switch {
+	case !a.Enabled:
+		return "disabled"
+	case !a.Healthy:
+		return "unresponsive"
+	case a.Init != "":
+		return a.Init
+	default:
+		return string(a.Process)
+	}
+}
@@ -5,6 +5,8 @@ go 1.21
 require (
 	github.com/daodao97/acpone/gotray v0.0.0
 	github.com/daodao97/acpone/web v0.0.0
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.33
 )
 
 require (
@@ -19,7 +21,7 @@ require (
 	github.com/go-stack/stack v1.8.0 // indirect
 	github.com/oxtoacart/bpool v0.0.0-20190530202638-03653db5a59c // indirect
 	github.com/skratchdot/open-golang v0.0.0-20200116055534-eef842397966 // indirect
-	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
 
 replace (
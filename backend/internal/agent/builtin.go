@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+// ServeFunc runs an in-process ACP agent reading requests from r and writing to w
+type ServeFunc func(r io.Reader, w io.Writer) error
+
+// builtinAgents maps builtin command names to their serve functions
+var (
+	builtinAgents = map[string]ServeFunc{
+		"builtin:mock": mockagent.Serve,
+	}
+	builtinMu sync.RWMutex
+)
+
+// RegisterBuiltin registers an in-process agent under a command name
+func RegisterBuiltin(command string, serve ServeFunc) {
+	builtinMu.Lock()
+	builtinAgents[command] = serve
+	builtinMu.Unlock()
+}
+
+// IsBuiltin reports whether the command refers to an in-process agent
+func IsBuiltin(command string) bool {
+	return lookupBuiltin(command) != nil
+}
+
+func lookupBuiltin(command string) ServeFunc {
+	builtinMu.RLock()
+	defer builtinMu.RUnlock()
+	return builtinAgents[command]
+}
+
+// startBuiltin connects the process to an in-process agent over pipes
+func (p *Process) startBuiltin(serve ServeFunc) error {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+
+	p.mu.Lock()
+	p.stdin = stdinW
+	p.stdout = stdoutR
+	p.stderr = nil
+	p.status = StatusRunning
+	p.lastActivity = time.Now()
+	p.startedAt = p.lastActivity
+	p.generation++
+	generation := p.generation
+	p.sessionDirs = nil
+	p.mu.Unlock()
+
+	go func() {
+		err := serve(stdinR, stdoutW)
+		stdoutW.CloseWithError(err)
+	}()
+	go p.readLoop()
+	go p.monitorHealth(generation)
+	return nil
+}
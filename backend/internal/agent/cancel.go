@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+// cancelGrace is how long an agent gets to end a canceled prompt itself
+// before it is ended without the agent's answer
+const cancelGrace = 10 * time.Second
+
+// Cancel stops the prompt running in an agent session. Permission requests
+// of the session waiting for an answer are answered as cancelled and the
+// agent is sent session/cancel; a prompt the agent hasn't ended within
+// cancelGrace returns with stopReason cancelled anyway.
+func (p *Process) Cancel(sessionID string) error {
+	p.mu.Lock()
+	var perms []*PendingPermission
+	for toolCallID, perm := range p.permissions {
+		if perm.SessionID == sessionID {
+			delete(p.permissions, toolCallID)
+			perms = append(perms, perm)
+		}
+	}
+	var prompts []int
+	for id, req := range p.pending {
+		if req.Method == "session/prompt" && req.SessionID == sessionID {
+			prompts = append(prompts, id)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, perm := range perms {
+		perm.Response <- ""
+	}
+	if err := p.Notify("session/cancel", map[string]string{"sessionId": sessionID}); err != nil {
+		return err
+	}
+	if len(prompts) > 0 {
+		time.AfterFunc(cancelGrace, func() { p.endCancelled(prompts) })
+	}
+	return nil
+}
+
+// endCancelled answers the canceled prompts the agent still hasn't ended
+func (p *Process) endCancelled(ids []int) {
+	for _, id := range ids {
+		p.mu.Lock()
+		req, ok := p.pending[id]
+		if ok {
+			delete(p.pending, id)
+		}
+		p.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		logger.Warn("prompt not ended after session/cancel, ending it", "agent", p.ID, "request", id, "grace", cancelGrace)
+		req.Result <- &jsonrpc.Message{
+			JSONRPC: jsonrpc.Version,
+			ID:      &id,
+			Result:  json.RawMessage(`{"stopReason":"cancelled"}`),
+		}
+	}
+}
+
+// paramsSession returns the sessionId of request params, if any
+func paramsSession(params any) string {
+	switch params := params.(type) {
+	case map[string]any:
+		id, _ := params["sessionId"].(string)
+		return id
+	case map[string]string:
+		return params["sessionId"]
+	}
+	return ""
+}
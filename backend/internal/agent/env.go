@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SetProjectEnv sets env vars merged over the agent config on the next start.
+// Returns true when the running process was started with different vars
+// and must be restarted for them to apply.
+func (p *Process) SetProjectEnv(env map[string]string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.projectEnv = env
+	return p.status == StatusRunning && !maps.Equal(env, p.startedEnv)
+}
+
+// agentEnv returns the configured env merged with the project env and
+// remembers the project env the process is started with
+func (p *Process) agentEnv() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.startedEnv = p.projectEnv
+
+	env := make(map[string]string, len(p.config.Env)+len(p.projectEnv))
+	for k, v := range p.config.Env {
+		env[k] = v
+	}
+	for k, v := range p.projectEnv {
+		env[k] = v
+	}
+	return env
+}
+
+// shellEnvMarker separates shellInit output from the environment dump
+const shellEnvMarker = "__ACPONE_ENV__"
+
+// shellInitTimeout bounds how long shellInit may take
+const shellInitTimeout = 30 * time.Second
+
+// processEnv builds the agent process environment: our own environment, the
+// variables exported by shellInit, npm's offline switch in offline mode, the
+// configured and project env, and pathPrepend in front of PATH. Later
+// entries win.
+func (p *Process) processEnv() ([]string, error) {
+	env := os.Environ()
+
+	if p.config.ShellInit != "" {
+		shell, err := shellEnv(p.config.ShellInit)
+		if err != nil {
+			return nil, fmt.Errorf("shellInit: %w", err)
+		}
+		logger.Debug("env from shellInit", "agent", p.ID, "vars", len(shell))
+		env = append(env, shell...)
+	}
+
+	if p.offline {
+		// npx resolves from the cache and global installs only, failing
+		// fast with ENOTCACHED instead of waiting on the registry
+		logger.Debug("env", "agent", p.ID, "var", "npm_config_offline=true")
+		env = append(env, "npm_config_offline=true")
+	}
+
+	for k, v := range p.agentEnv() {
+		envVar := fmt.Sprintf("%s=%s", k, v)
+		env = append(env, envVar)
+		// Log env vars (mask sensitive values)
+		if k == "ANTHROPIC_API_KEY" || k == "OPENAI_API_KEY" {
+			logger.Debug("env", "agent", p.ID, "var", k+"=***")
+		} else {
+			logger.Debug("env", "agent", p.ID, "var", envVar)
+		}
+	}
+
+	if len(p.config.PathPrepend) > 0 {
+		home, _ := os.UserHomeDir()
+		dirs := make([]string, 0, len(p.config.PathPrepend)+1)
+		for _, dir := range p.config.PathPrepend {
+			if rest, ok := strings.CutPrefix(dir, "~"); ok && home != "" {
+				dir = home + rest
+			}
+			dirs = append(dirs, dir)
+		}
+		dirs = append(dirs, envValue(env, "PATH"))
+		path := strings.Join(dirs, string(os.PathListSeparator))
+		logger.Debug("env", "agent", p.ID, "var", "PATH="+path)
+		env = append(env, "PATH="+path)
+	}
+
+	return env, nil
+}
+
+// shellEnv runs a shell snippet, such as sourcing nvm and selecting a Node
+// version, and returns the environment it leaves behind
+func shellEnv(script string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shellInitTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", script+" >nul 2>&1 && echo "+shellEnvMarker+" && set")
+	} else {
+		shell := "/bin/sh"
+		if bash, err := exec.LookPath("bash"); err == nil {
+			shell = bash
+		}
+		cmd = exec.CommandContext(ctx, shell, "-c",
+			"{\n"+script+"\n} >/dev/null 2>&1 || exit $?\necho "+shellEnvMarker+"\nenv")
+	}
+	hideWindow(cmd)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	_, dump, ok := strings.Cut(string(out), shellEnvMarker)
+	if !ok {
+		return nil, fmt.Errorf("no environment in output")
+	}
+
+	var env []string
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimRight(line, "\r")
+		k, _, ok := strings.Cut(line, "=")
+		if !ok || k == "" {
+			continue
+		}
+		switch k {
+		case "PWD", "OLDPWD", "SHLVL", "_":
+			continue
+		}
+		env = append(env, line)
+	}
+	return env, nil
+}
+
+// envValue returns the last value of key in env, ignoring case on Windows
+func envValue(env []string, key string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		k, v, _ := strings.Cut(env[i], "=")
+		if k == key || runtime.GOOS == "windows" && strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// lookCommand resolves a bare command against the PATH of the agent's env,
+// which may differ from ours. Falls back to the command itself.
+func lookCommand(command string, env []string) string {
+	if strings.ContainsAny(command, `/\`) {
+		return command
+	}
+	path := envValue(env, "PATH")
+	if path == os.Getenv("PATH") {
+		return command
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		if found, err := exec.LookPath(filepath.Join(dir, command)); err == nil {
+			return found
+		}
+	}
+	return command
+}
@@ -9,7 +9,8 @@ import (
 
 func (p *Process) handleReadFile(msg *jsonrpc.Message) {
 	var params struct {
-		Path string `json:"path"`
+		SessionID string `json:"sessionId"`
+		Path      string `json:"path"`
 	}
 	if err := msg.ParseParams(&params); err != nil {
 		if msg.ID != nil {
@@ -18,7 +19,7 @@ func (p *Process) handleReadFile(msg *jsonrpc.Message) {
 		return
 	}
 
-	filePath := p.resolvePath(params.Path)
+	filePath := p.resolvePath(params.SessionID, params.Path)
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		if msg.ID != nil {
@@ -27,6 +28,7 @@ func (p *Process) handleReadFile(msg *jsonrpc.Message) {
 		return
 	}
 
+	p.emitFileAccess(params.SessionID, filePath, false)
 	if msg.ID != nil {
 		p.sendResponse(*msg.ID, map[string]string{"content": string(content)})
 	}
@@ -34,8 +36,9 @@ func (p *Process) handleReadFile(msg *jsonrpc.Message) {
 
 func (p *Process) handleWriteFile(msg *jsonrpc.Message) {
 	var params struct {
-		Path    string `json:"path"`
-		Content string `json:"content"`
+		SessionID string `json:"sessionId"`
+		Path      string `json:"path"`
+		Content   string `json:"content"`
 	}
 	if err := msg.ParseParams(&params); err != nil {
 		if msg.ID != nil {
@@ -44,7 +47,7 @@ func (p *Process) handleWriteFile(msg *jsonrpc.Message) {
 		return
 	}
 
-	filePath := p.resolvePath(params.Path)
+	filePath := p.resolvePath(params.SessionID, params.Path)
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		if msg.ID != nil {
@@ -60,17 +63,65 @@ func (p *Process) handleWriteFile(msg *jsonrpc.Message) {
 		return
 	}
 
+	p.emitFileAccess(params.SessionID, filePath, true)
 	if msg.ID != nil {
 		p.sendResponse(*msg.ID, nil)
 	}
 }
 
-func (p *Process) resolvePath(targetPath string) string {
-	if targetPath == "" {
-		return p.workingDir
-	}
+// resolvePath resolves a path an agent sent against the cwd of the session
+// it came from, or the working directory for sessions not created through
+// Request
+func (p *Process) resolvePath(sessionID, targetPath string) string {
 	if filepath.IsAbs(targetPath) {
 		return targetPath
 	}
-	return filepath.Join(p.workingDir, targetPath)
+	p.mu.Lock()
+	dir, ok := p.sessionDirs[sessionID]
+	if !ok {
+		dir = p.workingDir
+	}
+	p.mu.Unlock()
+	return filepath.Join(dir, targetPath)
+}
+
+// fileCallback is a registered file access callback with cleanup support
+type fileCallback struct {
+	id      int
+	handler func(sessionID, path string, write bool)
+}
+
+// OnFileAccess registers an observer of fs/read_text_file and
+// fs/write_text_file calls, which come from any session of the agent, and
+// returns a cleanup function. fn runs on the read loop.
+func (p *Process) OnFileAccess(fn func(sessionID, path string, write bool)) func() {
+	p.mu.Lock()
+	p.handlerID++
+	id := p.handlerID
+	p.fileHandlers = append(p.fileHandlers, fileCallback{id: id, handler: fn})
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, h := range p.fileHandlers {
+			if h.id == id {
+				p.fileHandlers = append(p.fileHandlers[:i], p.fileHandlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (p *Process) emitFileAccess(sessionID, path string, write bool) {
+	p.mu.Lock()
+	handlers := make([]func(string, string, bool), len(p.fileHandlers))
+	for i, h := range p.fileHandlers {
+		handlers[i] = h.handler
+	}
+	p.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(sessionID, path, write)
+	}
 }
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+func TestRelativePathsResolveAgainstSessionCwd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	dirs := map[string]string{"a": t.TempDir(), "b": t.TempDir()}
+	for id, dir := range dirs {
+		os.WriteFile(filepath.Join(dir, "note.txt"), []byte("from "+id), 0644)
+	}
+
+	// Each session reads note.txt through the client and a terminal
+	read := make(map[string][2]string)
+	p := startTestAgent(t, func(c *mockagent.Conn, sessionID string) (any, error) {
+		msg, err := c.Call("fs/read_text_file", map[string]any{"sessionId": sessionID, "path": "note.txt"})
+		if err != nil {
+			return nil, err
+		}
+		var file struct {
+			Content string `json:"content"`
+		}
+		json.Unmarshal(msg.Result, &file)
+
+		msg, err = c.Call("terminal/create", map[string]any{"sessionId": sessionID, "command": "cat", "args": []string{"note.txt"}})
+		if err != nil {
+			return nil, err
+		}
+		var term struct {
+			TerminalID string `json:"terminalId"`
+		}
+		json.Unmarshal(msg.Result, &term)
+		terminal := map[string]any{"sessionId": sessionID, "terminalId": term.TerminalID}
+		if _, err := c.Call("terminal/wait_for_exit", terminal); err != nil {
+			return nil, err
+		}
+		msg, err = c.Call("terminal/output", terminal)
+		if err != nil {
+			return nil, err
+		}
+		var output struct {
+			Output string `json:"output"`
+		}
+		json.Unmarshal(msg.Result, &output)
+
+		read[sessionID] = [2]string{file.Content, strings.TrimSpace(output.Output)}
+		return map[string]any{"stopReason": "end_turn"}, nil
+	})
+
+	for id, dir := range dirs {
+		if _, err := p.Request("session/load", map[string]any{"sessionId": id, "cwd": dir, "mcpServers": []any{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The process working directory follows the last turn of any session
+	p.SetWorkingDir(dirs["b"])
+	for id := range dirs {
+		if _, err := p.Request("session/prompt", promptParams(id)); err != nil {
+			t.Fatal(err)
+		}
+		want := "from " + id
+		if got := read[id]; got != [2]string{want, want} {
+			t.Errorf("session %s read %q through the client and the terminal, want %q", id, got, want)
+		}
+	}
+}
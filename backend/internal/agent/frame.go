@@ -0,0 +1,62 @@
+package agent
+
+import "time"
+
+// Frame directions
+const (
+	FrameSend = "send" // acpone -> agent
+	FrameRecv = "recv" // agent -> acpone
+)
+
+// Frame is a raw JSON-RPC line exchanged with the agent process
+type Frame struct {
+	AgentID string
+	Dir     string
+	Data    []byte
+	Time    time.Time
+}
+
+// frameCallback is a registered frame callback with cleanup support
+type frameCallback struct {
+	id      int
+	handler func(Frame)
+}
+
+// OnFrame registers a raw frame observer and returns a cleanup function.
+// Handlers run synchronously on the I/O path and must not block.
+func (p *Process) OnFrame(fn func(Frame)) func() {
+	p.mu.Lock()
+	p.handlerID++
+	id := p.handlerID
+	p.frameHandlers = append(p.frameHandlers, frameCallback{id: id, handler: fn})
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, h := range p.frameHandlers {
+			if h.id == id {
+				p.frameHandlers = append(p.frameHandlers[:i], p.frameHandlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (p *Process) emitFrame(dir string, data []byte) {
+	p.mu.Lock()
+	if len(p.frameHandlers) == 0 {
+		p.mu.Unlock()
+		return
+	}
+	handlers := make([]func(Frame), len(p.frameHandlers))
+	for i, h := range p.frameHandlers {
+		handlers[i] = h.handler
+	}
+	p.mu.Unlock()
+
+	frame := Frame{AgentID: p.ID, Dir: dir, Data: append([]byte(nil), data...), Time: time.Now()}
+	for _, handler := range handlers {
+		handler(frame)
+	}
+}
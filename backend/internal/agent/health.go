@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultHeartbeatInterval = 5 * time.Second
+	defaultUnhealthyAfter    = 2 * time.Minute
+)
+
+// HealthEvent reports a change of an agent's liveness
+type HealthEvent struct {
+	AgentID   string `json:"agent"`
+	Healthy   bool   `json:"healthy"`
+	SilenceMs int64  `json:"silenceMs"`
+	Restarted bool   `json:"restarted"`
+}
+
+// Message returns a human readable description of the event
+func (e *HealthEvent) Message() string {
+	if e.Healthy {
+		return fmt.Sprintf("%s is responding again", e.AgentID)
+	}
+	msg := fmt.Sprintf("%s has been unresponsive for %s", e.AgentID, time.Duration(e.SilenceMs)*time.Millisecond)
+	if e.Restarted {
+		msg += ", restarting"
+	}
+	return msg
+}
+
+// healthCallback is a registered health callback with cleanup support
+type healthCallback struct {
+	id      int
+	handler func(*HealthEvent)
+}
+
+// OnHealth registers a liveness observer and returns a cleanup function
+func (p *Process) OnHealth(fn func(*HealthEvent)) func() {
+	p.mu.Lock()
+	p.handlerID++
+	id := p.handlerID
+	p.healthHandlers = append(p.healthHandlers, healthCallback{id: id, handler: fn})
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, h := range p.healthHandlers {
+			if h.id == id {
+				p.healthHandlers = append(p.healthHandlers[:i], p.healthHandlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Healthy reports whether the agent is considered alive
+func (p *Process) Healthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.unhealthy
+}
+
+// monitorHealth watches a running process until it stops or is restarted.
+// An agent is unhealthy when a prompt is in flight and no frame has arrived
+// for the configured period; pending permission prompts do not count.
+func (p *Process) monitorHealth(generation int) {
+	hb := p.config.Heartbeat
+	if hb == nil {
+		return
+	}
+	interval := time.Duration(hb.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	unhealthyAfter := time.Duration(hb.UnhealthyAfterMs) * time.Millisecond
+	if unhealthyAfter <= 0 {
+		unhealthyAfter = defaultUnhealthyAfter
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.mu.Lock()
+		if p.generation != generation || p.status != StatusRunning {
+			p.unhealthy = false
+			p.mu.Unlock()
+			return
+		}
+		silence := time.Since(p.lastActivity)
+		stalled := p.inTurn() && len(p.permissions) == 0 && silence >= unhealthyAfter
+		changed := stalled != p.unhealthy
+		p.unhealthy = stalled
+		p.mu.Unlock()
+
+		if !changed {
+			continue
+		}
+
+		restart := stalled && hb.Restart
+		p.emitHealth(&HealthEvent{
+			AgentID:   p.ID,
+			Healthy:   !stalled,
+			SilenceMs: silence.Milliseconds(),
+			Restarted: restart,
+		})
+		if restart {
+			p.Stop()
+			return
+		}
+	}
+}
+
+// inTurn reports whether a prompt is awaiting its response (caller holds p.mu)
+func (p *Process) inTurn() bool {
+	for _, req := range p.pending {
+		if req.Method == "session/prompt" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Process) emitHealth(event *HealthEvent) {
+	p.mu.Lock()
+	handlers := make([]func(*HealthEvent), len(p.healthHandlers))
+	for i, h := range p.healthHandlers {
+		handlers[i] = h.handler
+	}
+	p.mu.Unlock()
+
+	logger.Warn("health changed", "agent", p.ID, "message", event.Message())
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
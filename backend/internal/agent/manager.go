@@ -16,6 +16,9 @@ type Manager struct {
 	defaultAgent string
 	mu           sync.RWMutex
 	handlers     []NotificationHandler
+
+	restarts        map[string]*restartState
+	restartHandlers []func(*RestartEvent)
 }
 
 // NewManager creates a new agent manager
@@ -23,16 +26,45 @@ func NewManager(cfg *config.Config) *Manager {
 	m := &Manager{
 		agents:       make(map[string]*Process),
 		defaultAgent: cfg.DefaultAgent,
+		restarts:     make(map[string]*restartState),
 	}
 
 	for i := range cfg.Agents {
 		agent := &cfg.Agents[i]
-		m.agents[agent.ID] = NewProcess(agent)
+		p := NewProcess(agent)
+		p.offline = cfg.Offline
+		m.agents[agent.ID] = p
+		m.supervise(p)
 	}
 
 	return m
 }
 
+// Sync points processes at the current entries of cfg.Agents, which move
+// when the slice grows, and creates processes for added agents. It returns
+// the new processes.
+func (m *Manager) Sync(cfg *config.Config) []*Process {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var added []*Process
+	for i := range cfg.Agents {
+		agent := &cfg.Agents[i]
+		if p, ok := m.agents[agent.ID]; ok {
+			p.mu.Lock()
+			p.config = agent
+			p.mu.Unlock()
+			continue
+		}
+		p := NewProcess(agent)
+		p.offline = cfg.Offline
+		m.agents[agent.ID] = p
+		m.supervise(p)
+		added = append(added, p)
+	}
+	return added
+}
+
 // DefaultID returns the default agent ID
 func (m *Manager) DefaultID() string {
 	return m.defaultAgent
@@ -119,11 +151,12 @@ func (m *Manager) Request(agentID, method string, params any) (any, error) {
 	return result, nil
 }
 
-// Stop stops a specific agent by ID
+// Stop stops a specific agent by ID, dropping a pending restart
 func (m *Manager) Stop(id string) error {
-	m.mu.RLock()
+	m.mu.Lock()
 	agent, ok := m.agents[id]
-	m.mu.RUnlock()
+	m.cancelRestart(id)
+	m.mu.Unlock()
 
 	if !ok {
 		return fmt.Errorf("agent not found: %s", id)
@@ -137,7 +170,8 @@ func (m *Manager) Shutdown() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, agent := range m.agents {
+	for id, agent := range m.agents {
+		m.cancelRestart(id)
 		agent.Stop()
 	}
 	return nil
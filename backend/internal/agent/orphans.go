@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// pidRecord is an agent process spawned by an acpone instance
+type pidRecord struct {
+	PID       int    `json:"pid"`
+	Agent     string `json:"agent"`
+	Command   string `json:"command"`
+	Owner     int    `json:"owner"` // PID of the acpone process that spawned it
+	StartedAt int64  `json:"startedAt"`
+	// Start time of the process as the OS reports it, telling it apart from
+	// a later process reusing the PID
+	ProcStart string `json:"procStart,omitempty"`
+}
+
+var pidFileMu sync.Mutex
+
+// pidFilePath returns the file recording spawned agent processes
+func pidFilePath() string {
+	return filepath.Join(sysutil.DataDir(), "agents.pid.json")
+}
+
+func loadPIDRecords() []pidRecord {
+	data, err := os.ReadFile(pidFilePath())
+	if err != nil {
+		return nil
+	}
+	var records []pidRecord
+	json.Unmarshal(data, &records)
+	return records
+}
+
+func savePIDRecords(records []pidRecord) {
+	path := pidFilePath()
+	if len(records) == 0 {
+		os.Remove(path)
+		return
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, data, 0644)
+}
+
+// recordPID remembers a spawned agent so it can be cleaned up after a crash
+func recordPID(agentID, command string, pid int) {
+	pidFileMu.Lock()
+	defer pidFileMu.Unlock()
+	records := append(loadPIDRecords(), pidRecord{
+		PID:       pid,
+		Agent:     agentID,
+		Command:   command,
+		Owner:     os.Getpid(),
+		StartedAt: time.Now().Unix(),
+		ProcStart: sysutil.ProcessStartTime(pid),
+	})
+	savePIDRecords(records)
+}
+
+// forgetPID removes an agent that exited normally
+func forgetPID(pid int) {
+	pidFileMu.Lock()
+	defer pidFileMu.Unlock()
+	records := loadPIDRecords()
+	kept := records[:0]
+	for _, r := range records {
+		if r.PID != pid {
+			kept = append(kept, r)
+		}
+	}
+	savePIDRecords(kept)
+}
+
+// CleanupOrphans kills agent process trees left behind by acpone instances
+// that are no longer running (e.g. killed hard). Agents of live instances,
+// including this one, are kept; a recorded PID now belonging to another
+// process is forgotten without killing it. Returns the number of killed
+// processes.
+func CleanupOrphans() int {
+	pidFileMu.Lock()
+	defer pidFileMu.Unlock()
+
+	records := loadPIDRecords()
+	kept := records[:0]
+	killed := 0
+	for _, r := range records {
+		if r.Owner == os.Getpid() || sysutil.ProcessAlive(r.Owner) {
+			kept = append(kept, r)
+			continue
+		}
+		if !sysutil.ProcessAlive(r.PID) {
+			continue
+		}
+		// After a reboot or PID wrap-around the PID may be another program's
+		if r.ProcStart == "" || sysutil.ProcessStartTime(r.PID) != r.ProcStart {
+			logger.Info("not killing reused agent pid", "agent", r.Agent, "pid", r.PID)
+			continue
+		}
+		if err := sysutil.KillTree(r.PID); err != nil {
+			logger.Error("failed to kill orphaned agent", "agent", r.Agent, "pid", r.PID, "error", err)
+			continue
+		}
+		logger.Info("killed orphaned agent", "agent", r.Agent, "pid", r.PID, "command", r.Command)
+		killed++
+	}
+	savePIDRecords(kept)
+	return killed
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// startOrphan starts a process recorded as spawned by an acpone instance
+// that is no longer running, returning a channel closed when it exits
+func startOrphan(t *testing.T, procStart func(pid int) string) <-chan struct{} {
+	t.Helper()
+	cmd := exec.Command("sleep", "30")
+	sysutil.SetProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep unavailable:", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-exited
+	})
+
+	owner := exec.Command("true")
+	if err := owner.Run(); err != nil {
+		t.Skip("true unavailable:", err)
+	}
+	savePIDRecords([]pidRecord{{
+		PID:       cmd.Process.Pid,
+		Agent:     "test",
+		Command:   "sleep",
+		Owner:     owner.Process.Pid,
+		ProcStart: procStart(cmd.Process.Pid),
+	}})
+	return exited
+}
+
+// exitedWithin reports whether the process exits within d
+func exitedWithin(exited <-chan struct{}, d time.Duration) bool {
+	select {
+	case <-exited:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+func TestCleanupOrphansKillsRecordedProcess(t *testing.T) {
+	t.Setenv(sysutil.DataDirEnv, t.TempDir())
+	if sysutil.ProcessStartTime(1) == "" {
+		t.Skip("process start times unavailable")
+	}
+	exited := startOrphan(t, sysutil.ProcessStartTime)
+
+	if n := CleanupOrphans(); n != 1 {
+		t.Fatalf("killed %d orphans, want 1", n)
+	}
+	if !exitedWithin(exited, 2*time.Second) {
+		t.Fatal("orphan still running")
+	}
+	if len(loadPIDRecords()) != 0 {
+		t.Fatal("record of the killed orphan kept")
+	}
+}
+
+func TestCleanupOrphansSparesReusedPID(t *testing.T) {
+	t.Setenv(sysutil.DataDirEnv, t.TempDir())
+	for name, procStart := range map[string]func(int) string{
+		"different start time": func(int) string { return "another process" },
+		"unknown start time":   func(int) string { return "" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			exited := startOrphan(t, procStart)
+			if n := CleanupOrphans(); n != 0 {
+				t.Fatalf("killed %d processes, want 0", n)
+			}
+			if exitedWithin(exited, 200*time.Millisecond) {
+				t.Fatal("process reusing the PID was killed")
+			}
+			if len(loadPIDRecords()) != 0 {
+				t.Fatal("stale record kept")
+			}
+		})
+	}
+}
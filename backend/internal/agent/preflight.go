@@ -55,6 +55,10 @@ func PreflightCheck(agents []config.AgentConfig) error {
 }
 
 func checkAgent(agent config.AgentConfig) CheckResult {
+	if IsBuiltin(agent.Command) {
+		return CheckResult{AgentID: agent.ID, Status: "builtin"}
+	}
+
 	packageName := extractPackageName(agent)
 
 	if packageName != "" {
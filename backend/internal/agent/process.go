@@ -1,15 +1,17 @@
 package agent
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/daodao97/acpone/internal/config"
 	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/logging"
+	"github.com/daodao97/acpone/internal/sysutil"
 )
 
 // Status represents agent process status
@@ -42,14 +44,17 @@ type PermissionRequest struct {
 
 // PendingRequest tracks an in-flight request
 type PendingRequest struct {
-	Result chan *jsonrpc.Message
-	Method string
+	Result    chan *jsonrpc.Message
+	Err       error // Why Result was closed without a response, if known
+	Method    string
+	SessionID string // Session of a session/prompt, for Cancel
 }
 
 // PendingPermission tracks permission request
 type PendingPermission struct {
 	RequestID int
-	Response  chan string // optionId
+	SessionID string
+	Response  chan string // optionId, empty when the prompt was canceled
 }
 
 // notificationCallback is a registered notification callback with cleanup support
@@ -76,7 +81,9 @@ type Process struct {
 	status     Status
 	requestID  int
 	workingDir string
-	handlerID  int // Counter for handler IDs
+	// Cwd of each session created or loaded, resolving the paths it sends
+	sessionDirs map[string]string
+	handlerID   int // Counter for handler IDs
 
 	pending     map[int]*PendingRequest
 	permissions map[string]*PendingPermission
@@ -85,8 +92,38 @@ type Process struct {
 	// Event handlers (support multiple concurrent handlers)
 	notificationHandlers []notificationCallback
 	permissionHandlers   []permissionCallback
+	frameHandlers        []frameCallback
+	timeoutHandlers      []timeoutCallback
+	healthHandlers       []healthCallback
+	exitHandlers         []exitCallback
+	terminalHandlers     []terminalCallback
+	fileHandlers         []fileCallback
+
+	// Commands the agent runs through terminal/create, by terminal ID, and
+	// the released ones kept for their output, oldest first
+	terminals map[string]*terminal
+	released  []string
+
+	// Notification channels of the sessions chats are following
+	subscribers map[string][]*sessionSubscriber
+
+	// Time of the last frame received from the agent
+	lastActivity time.Time
+	startedAt    time.Time
+	unhealthy    bool
+	generation   int // Incremented on every start
+
+	// Per-project env vars (desired, and those the process was started with)
+	projectEnv map[string]string
+	startedEnv map[string]string
+
+	// Offline keeps npm/npx from contacting the registry
+	offline bool
 }
 
+// logger logs process lifecycle, stderr and, at debug level, ACP traffic
+var logger = logging.Component("agent")
+
 // NewProcess creates a new agent process
 func NewProcess(cfg *config.AgentConfig) *Process {
 	cwd, _ := os.Getwd()
@@ -108,6 +145,35 @@ func (p *Process) Status() Status {
 	return p.status
 }
 
+// Info is a snapshot of a process for status reports
+type Info struct {
+	Status       Status
+	Healthy      bool
+	PID          int       // 0 unless an external process is running
+	StartedAt    time.Time // Start of the current or last process
+	LastActivity time.Time // Last frame received from the agent
+	Starts       int       // Processes started so far
+	Pending      int       // Requests awaiting a response
+}
+
+// Info returns a snapshot of the process
+func (p *Process) Info() Info {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info := Info{
+		Status:       p.status,
+		Healthy:      !p.unhealthy,
+		StartedAt:    p.startedAt,
+		LastActivity: p.lastActivity,
+		Starts:       p.generation,
+		Pending:      len(p.pending),
+	}
+	if p.cmd != nil && p.cmd.Process != nil {
+		info.PID = p.cmd.Process.Pid
+	}
+	return info
+}
+
 // SetWorkingDir sets the working directory
 func (p *Process) SetWorkingDir(dir string) {
 	p.mu.Lock()
@@ -115,7 +181,9 @@ func (p *Process) SetWorkingDir(dir string) {
 	p.workingDir = dir
 }
 
-// OnNotification registers a notification handler and returns a cleanup function
+// OnNotification registers a handler for every notification of the process
+// and returns a cleanup function. Chats follow their own session with
+// Subscribe instead.
 func (p *Process) OnNotification(fn func(*jsonrpc.Message)) func() {
 	p.mu.Lock()
 	p.handlerID++
@@ -167,21 +235,22 @@ func (p *Process) Start() error {
 	p.status = StatusStarting
 	p.mu.Unlock()
 
-	cmd := exec.Command(p.config.Command, p.config.Args...)
-	cmd.Env = os.Environ()
-	for k, v := range p.config.Env {
-		envVar := fmt.Sprintf("%s=%s", k, v)
-		cmd.Env = append(cmd.Env, envVar)
-		// Log env vars (mask sensitive values)
-		if k == "ANTHROPIC_API_KEY" || k == "OPENAI_API_KEY" {
-			fmt.Printf("ENV [%s] %s=***\n", p.ID, k)
-		} else {
-			fmt.Printf("ENV [%s] %s\n", p.ID, envVar)
-		}
+	if serve := lookupBuiltin(p.config.Command); serve != nil {
+		return p.startBuiltin(serve)
 	}
 
+	env, err := p.processEnv()
+	if err != nil {
+		p.setStatus(StatusError)
+		return err
+	}
+	cmd := exec.Command(lookCommand(p.config.Command, env), p.config.Args...)
+	cmd.Env = env
+
 	// Windows: 隐藏控制台窗口
 	hideWindow(cmd)
+	// Run the agent in its own process group so its whole tree can be killed
+	sysutil.SetProcessGroup(cmd)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -206,6 +275,10 @@ func (p *Process) Start() error {
 		p.setStatus(StatusError)
 		return err
 	}
+	if err := sysutil.AttachToJob(cmd.Process.Pid); err != nil {
+		logger.Warn("failed to assign job object", "agent", p.ID, "error", err)
+	}
+	recordPID(p.ID, p.config.Command, cmd.Process.Pid)
 
 	p.mu.Lock()
 	p.cmd = cmd
@@ -213,10 +286,16 @@ func (p *Process) Start() error {
 	p.stdout = stdout
 	p.stderr = stderr
 	p.status = StatusRunning
+	p.lastActivity = time.Now()
+	p.startedAt = p.lastActivity
+	p.generation++
+	generation := p.generation
+	p.sessionDirs = nil
 	p.mu.Unlock()
 
 	go p.readLoop()
 	go p.readStderr()
+	go p.monitorHealth(generation)
 	return nil
 }
 
@@ -234,7 +313,7 @@ func (p *Process) readStderr() {
 	for {
 		n, err := stderr.Read(buf)
 		if n > 0 {
-			fmt.Printf("!!! [%s] stderr: %s", p.ID, string(buf[:n]))
+			logger.Info("stderr", "agent", p.ID, "output", strings.TrimRight(string(buf[:n]), "\n"))
 		}
 		if err != nil {
 			break
@@ -245,7 +324,7 @@ func (p *Process) readStderr() {
 // Stop stops the agent process and waits for it to exit
 func (p *Process) Stop() error {
 	p.mu.Lock()
-	if p.cmd == nil {
+	if p.cmd == nil && p.stdin == nil {
 		p.mu.Unlock()
 		return nil
 	}
@@ -258,21 +337,30 @@ func (p *Process) Stop() error {
 	p.stdin = nil
 	p.stdout = nil
 	p.status = StatusStopped
+	p.unhealthy = false
 
 	// Reject pending requests
 	for id, req := range p.pending {
 		close(req.Result)
 		delete(p.pending, id)
 	}
+	p.dropPermissions()
 	p.mu.Unlock()
+	p.killTerminals()
 
 	// Close stdin to signal the process
 	if stdin != nil {
 		stdin.Close()
 	}
 
-	// Send interrupt signal
+	// Builtin agents exit once stdin is closed
+	if cmd == nil {
+		return nil
+	}
+
+	// Send interrupt signal to the whole process tree
 	_ = cmd.Process.Signal(os.Interrupt)
+	_ = sysutil.InterruptTree(cmd.Process.Pid)
 
 	// Wait with timeout
 	done := make(chan error, 1)
@@ -288,6 +376,9 @@ func (p *Process) Stop() error {
 		_ = cmd.Process.Kill()
 		<-done
 	}
+	// Children (e.g. the node process behind npx) may outlive the parent
+	_ = sysutil.KillTree(cmd.Process.Pid)
+	forgetPID(cmd.Process.Pid)
 
 	return nil
 }
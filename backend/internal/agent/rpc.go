@@ -20,8 +20,9 @@ func (p *Process) Request(method string, params any) (*jsonrpc.Message, error) {
 	p.mu.Lock()
 	p.requestID++
 	id := p.requestID
-	resultCh := make(chan *jsonrpc.Message, 1)
-	p.pending[id] = &PendingRequest{Result: resultCh, Method: method}
+	pending := &PendingRequest{Result: make(chan *jsonrpc.Message, 1), Method: method, SessionID: paramsSession(params)}
+	p.pending[id] = pending
+	p.lastActivity = time.Now()
 	p.mu.Unlock()
 
 	req := jsonrpc.NewRequest(id, method, params)
@@ -32,19 +33,48 @@ func (p *Process) Request(method string, params any) (*jsonrpc.Message, error) {
 		return nil, err
 	}
 
-	// Wait for response (no timeout - agent may take long)
-	msg, ok := <-resultCh
-	if !ok {
-		return nil, fmt.Errorf("request cancelled")
+	// Wait for response, bounded only by configured timeouts
+	msg, err := p.await(id, method, pending)
+	if err != nil {
+		return nil, err
 	}
 
 	if msg.Error != nil {
 		return nil, msg.Error
 	}
 
+	if method == "session/new" || method == "session/load" {
+		p.rememberSessionDir(params, msg)
+	}
 	return msg, nil
 }
 
+// rememberSessionDir records the cwd a session was created or loaded with
+func (p *Process) rememberSessionDir(params any, msg *jsonrpc.Message) {
+	args, ok := params.(map[string]any)
+	if !ok {
+		return
+	}
+	cwd, _ := args["cwd"].(string)
+	sessionID := paramsSession(params)
+	if sessionID == "" {
+		var result struct {
+			SessionID string `json:"sessionId"`
+		}
+		msg.ParseResult(&result)
+		sessionID = result.SessionID
+	}
+	if cwd == "" || sessionID == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sessionDirs == nil {
+		p.sessionDirs = make(map[string]string)
+	}
+	p.sessionDirs[sessionID] = cwd
+}
+
 // ConfirmPermission responds to a permission request
 func (p *Process) ConfirmPermission(toolCallID, optionID string) {
 	p.mu.Lock()
@@ -82,7 +112,8 @@ func (p *Process) write(v any) error {
 		return err
 	}
 
-	fmt.Printf(">>> [%s] %s\n", p.ID, string(data))
+	logger.Debug("send", "agent", p.ID, "message", string(data))
+	p.emitFrame(FrameSend, data)
 	_, err = fmt.Fprintf(stdin, "%s\n", data)
 	return err
 }
@@ -108,8 +139,13 @@ func (p *Process) readLoop() {
 			continue
 		}
 
+		p.mu.Lock()
+		p.lastActivity = time.Now()
+		p.mu.Unlock()
+
 		lineStr := string(line)
-		fmt.Printf("<<< [%s] %s\n", p.ID, lineStr)
+		logger.Debug("recv", "agent", p.ID, "message", lineStr)
+		p.emitFrame(FrameRecv, line)
 
 		var msg jsonrpc.Message
 		if err := json.Unmarshal(line, &msg); err != nil {
@@ -119,12 +155,17 @@ func (p *Process) readLoop() {
 		p.handleMessage(&msg)
 	}
 
-	// Only set status if this is still the active process
+	// Only set status if this is still the active process. Stop clears
+	// stdout first, so a loop ending on the current one saw the agent exit.
 	p.mu.Lock()
-	if p.stdout == currentStdout || p.stdout == nil {
+	crashed := p.stdout == currentStdout
+	if crashed || p.stdout == nil {
 		p.status = StatusStopped
 	}
 	p.mu.Unlock()
+	if crashed {
+		p.exited(currentStdout)
+	}
 }
 
 func (p *Process) handleMessage(msg *jsonrpc.Message) {
@@ -161,6 +202,7 @@ func (p *Process) handleMessage(msg *jsonrpc.Message) {
 		for _, handler := range handlers {
 			handler(msg)
 		}
+		p.routeNotification(msg)
 	}
 }
 
@@ -175,6 +217,21 @@ func (p *Process) handleRequest(msg *jsonrpc.Message) {
 	case "fs/write_text_file":
 		p.handleWriteFile(msg)
 
+	case "terminal/create":
+		p.handleTerminalCreate(msg)
+
+	case "terminal/output":
+		p.handleTerminalOutput(msg)
+
+	case "terminal/wait_for_exit":
+		p.handleTerminalWaitForExit(msg)
+
+	case "terminal/kill":
+		p.handleTerminalKill(msg)
+
+	case "terminal/release":
+		p.handleTerminalRelease(msg)
+
 	default:
 		if msg.ID != nil {
 			p.sendError(*msg.ID, jsonrpc.MethodNotFound, "Method not found: "+msg.Method)
@@ -191,43 +248,59 @@ func (p *Process) handlePermissionRequest(msg *jsonrpc.Message) {
 	toolCallID := req.ToolCall.ToolCallID
 	if toolCallID == "" {
 		toolCallID = fmt.Sprintf("perm-%d", time.Now().UnixMilli())
+		req.ToolCall.ToolCallID = toolCallID
 	}
 
-	// Emit permission request to all registered handlers
+	// Register before emitting so handlers may confirm immediately
+	respCh := make(chan string, 1)
 	p.mu.Lock()
+	generation := p.generation
+	p.permissions[toolCallID] = &PendingPermission{
+		RequestID: *msg.ID,
+		SessionID: req.SessionID,
+		Response:  respCh,
+	}
 	permHandlers := make([]func(*PermissionRequest), len(p.permissionHandlers))
 	for i, h := range p.permissionHandlers {
 		permHandlers[i] = h.handler
 	}
 	p.mu.Unlock()
 
+	// Emit permission request to all registered handlers
 	for _, handler := range permHandlers {
 		handler(&req)
 	}
 
-	// Wait for response
-	respCh := make(chan string, 1)
-	p.mu.Lock()
-	p.permissions[toolCallID] = &PendingPermission{
-		RequestID: *msg.ID,
-		Response:  respCh,
-	}
-	p.mu.Unlock()
-
-	optionID := <-respCh
+	// Answer once the user decides, without holding up the read loop and
+	// with it the other sessions of the process
+	go func() {
+		optionID := <-respCh
+		p.mu.Lock()
+		current := p.generation == generation
+		p.mu.Unlock()
+		if !current {
+			return // Asked by an agent process that has exited since
+		}
 
-	outcome := "selected"
-	if len(optionID) > 6 && optionID[:6] == "reject" {
-		outcome = "rejected"
-	}
+		// A canceled prompt's permission requests are answered as cancelled
+		result := map[string]any{"outcome": "cancelled"}
+		if optionID != "" {
+			outcome := "selected"
+			if len(optionID) > 6 && optionID[:6] == "reject" {
+				outcome = "rejected"
+			}
+			result = map[string]any{"outcome": outcome, "optionId": optionID}
+		}
+		p.sendResponse(*msg.ID, map[string]any{"outcome": result})
+	}()
+}
 
-	if msg.ID != nil {
-		p.sendResponse(*msg.ID, map[string]any{
-			"outcome": map[string]any{
-				"outcome":  outcome,
-				"optionId": optionID,
-			},
-		})
+// dropPermissions answers the permission requests still waiting as
+// cancelled once the process that asked is gone (caller holds p.mu)
+func (p *Process) dropPermissions() {
+	for toolCallID, perm := range p.permissions {
+		delete(p.permissions, toolCallID)
+		perm.Response <- ""
 	}
 }
 
@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+var testAgentSeq atomic.Int32
+
+// startTestAgent starts a process talking to an in-process agent whose
+// session/prompt requests handle answers
+func startTestAgent(t *testing.T, prompt func(c *mockagent.Conn, sessionID string) (any, error)) *Process {
+	t.Helper()
+	command := fmt.Sprintf("builtin:agent-test-%d", testAgentSeq.Add(1))
+	RegisterBuiltin(command, func(r io.Reader, w io.Writer) error {
+		return mockagent.NewConn(w, func(c *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+			if msg.Method != "session/prompt" {
+				return map[string]any{}, nil
+			}
+			sessionID, _ := mockagent.PromptText(msg)
+			return prompt(c, sessionID)
+		}, nil).Serve(r)
+	})
+	p := NewProcess(&config.AgentConfig{ID: "test", Name: "test", Command: command})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Stop() })
+	return p
+}
+
+func promptParams(sessionID string) map[string]any {
+	return map[string]any{"sessionId": sessionID, "prompt": []map[string]any{{"type": "text", "text": "hi"}}}
+}
+
+func TestPendingPermissionDoesNotBlockOtherSessions(t *testing.T) {
+	p := startTestAgent(t, func(c *mockagent.Conn, sessionID string) (any, error) {
+		if sessionID == "asking" {
+			if _, err := c.Call("session/request_permission", map[string]any{
+				"sessionId": sessionID,
+				"toolCall":  map[string]any{"toolCallId": "tool-1"},
+				"options":   []map[string]any{{"optionId": "allow", "kind": "allow_once"}},
+			}); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]any{"stopReason": "end_turn"}, nil
+	})
+
+	asked := make(chan struct{})
+	defer p.OnPermission(func(req *PermissionRequest) { close(asked) })()
+
+	asking := make(chan error, 1)
+	go func() {
+		_, err := p.Request("session/prompt", promptParams("asking"))
+		asking <- err
+	}()
+	<-asked
+
+	// Another session's prompt completes while the permission waits
+	other := make(chan error, 1)
+	go func() {
+		_, err := p.Request("session/prompt", promptParams("other"))
+		other <- err
+	}()
+	select {
+	case err := <-other:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a pending permission request blocked another session's prompt")
+	}
+
+	p.ConfirmPermission("tool-1", "allow")
+	select {
+	case err := <-asking:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the prompt asking for permission did not end after the answer")
+	}
+}
+
+func TestStopAnswersPendingPermissions(t *testing.T) {
+	p := startTestAgent(t, func(c *mockagent.Conn, sessionID string) (any, error) {
+		c.Call("session/request_permission", map[string]any{
+			"sessionId": sessionID,
+			"toolCall":  map[string]any{"toolCallId": "tool-1"},
+		})
+		return map[string]any{"stopReason": "end_turn"}, nil
+	})
+	asked := make(chan struct{})
+	defer p.OnPermission(func(req *PermissionRequest) { close(asked) })()
+	go p.Request("session/prompt", promptParams("s1"))
+	<-asked
+
+	p.Stop()
+	p.mu.Lock()
+	left := len(p.permissions)
+	p.mu.Unlock()
+	if left != 0 {
+		t.Fatalf("%d permission requests still pending after Stop", left)
+	}
+}
+
+func TestFileAccessReportsSession(t *testing.T) {
+	dir := t.TempDir()
+	p := startTestAgent(t, func(c *mockagent.Conn, sessionID string) (any, error) {
+		_, err := c.Call("fs/write_text_file", map[string]any{
+			"sessionId": sessionID,
+			"path":      filepath.Join(dir, sessionID+".txt"),
+			"content":   "x",
+		})
+		return map[string]any{"stopReason": "end_turn"}, err
+	})
+
+	var mu sync.Mutex
+	writes := make(map[string]string)
+	defer p.OnFileAccess(func(sessionID, path string, write bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if write {
+			writes[sessionID] = filepath.Base(path)
+		}
+	})()
+
+	for _, sessionID := range []string{"s1", "s2"} {
+		if _, err := p.Request("session/prompt", promptParams(sessionID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if writes["s1"] != "s1.txt" || writes["s2"] != "s2.txt" {
+		t.Fatalf("writes by session: %v", writes)
+	}
+}
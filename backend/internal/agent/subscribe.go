@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+// subscriberBuffer is how many notifications a session subscriber may fall
+// behind before the read loop waits for it
+const subscriberBuffer = 256
+
+// sessionSubscriber receives the notifications of one session
+type sessionSubscriber struct {
+	ch   chan *jsonrpc.Message
+	done chan struct{} // Closed on unsubscribe, releasing a waiting delivery
+	once sync.Once
+
+	mu     sync.Mutex // Held while delivering, so ch isn't closed meanwhile
+	closed bool
+}
+
+func (s *sessionSubscriber) deliver(msg *jsonrpc.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- msg:
+	case <-s.done:
+	}
+}
+
+func (s *sessionSubscriber) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.ch)
+	})
+}
+
+// Subscribe routes the notifications of an agent session, such as its
+// session/update, to the returned channel until unsubscribe is called, which
+// closes it. Notifications arrive in order, and those the agent sent before
+// answering a request are queued by the time the request returns.
+// OnNotification handlers still see every notification of the process.
+func (p *Process) Subscribe(sessionID string) (<-chan *jsonrpc.Message, func()) {
+	sub := &sessionSubscriber{
+		ch:   make(chan *jsonrpc.Message, subscriberBuffer),
+		done: make(chan struct{}),
+	}
+	p.mu.Lock()
+	if p.subscribers == nil {
+		p.subscribers = make(map[string][]*sessionSubscriber)
+	}
+	p.subscribers[sessionID] = append(p.subscribers[sessionID], sub)
+	p.mu.Unlock()
+
+	return sub.ch, func() {
+		p.mu.Lock()
+		subs := p.subscribers[sessionID]
+		for i, s := range subs {
+			if s == sub {
+				subs = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(subs) == 0 {
+			delete(p.subscribers, sessionID)
+		} else {
+			p.subscribers[sessionID] = subs
+		}
+		p.mu.Unlock()
+		sub.close()
+	}
+}
+
+// routeNotification passes a notification to the subscribers of its session
+func (p *Process) routeNotification(msg *jsonrpc.Message) {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+	if msg.ParseParams(&params) != nil || params.SessionID == "" {
+		return
+	}
+	p.mu.Lock()
+	subs := append([]*sessionSubscriber(nil), p.subscribers[params.SessionID]...)
+	p.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.deliver(msg)
+	}
+}
+
+// RequestWithUpdates sends a request, calling handle on the caller's
+// goroutine with the notifications of a subscription until the response
+// arrives, including all the agent sent before it
+func (p *Process) RequestWithUpdates(method string, params any, updates <-chan *jsonrpc.Message, handle func(*jsonrpc.Message)) (*jsonrpc.Message, error) {
+	type reply struct {
+		msg *jsonrpc.Message
+		err error
+	}
+	replied := make(chan reply, 1)
+	go func() {
+		msg, err := p.Request(method, params)
+		replied <- reply{msg, err}
+	}()
+
+	for {
+		select {
+		case msg, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			handle(msg)
+		case r := <-replied:
+			for {
+				select {
+				case msg, ok := <-updates:
+					if ok {
+						handle(msg)
+						continue
+					}
+				default:
+				}
+				return r.msg, r.err
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// restartStableAfter is how long a restarted agent must run before a later
+// exit counts as a new failure rather than the next attempt in a row
+const restartStableAfter = time.Minute
+
+// ExitError fails the requests pending when an agent process exited on its
+// own, and is passed to exit observers
+type ExitError struct {
+	AgentID string
+	Err     error         // How the process ended, nil for a clean exit
+	Uptime  time.Duration // How long the process had been running
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("agent %s exited: %v", e.AgentID, e.Err)
+	}
+	return fmt.Sprintf("agent %s exited", e.AgentID)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// exitCallback is a registered exit callback with cleanup support
+type exitCallback struct {
+	id      int
+	handler func(*ExitError)
+}
+
+// OnExit registers an observer of the process exiting on its own, which
+// Stop does not count as, and returns a cleanup function
+func (p *Process) OnExit(fn func(*ExitError)) func() {
+	p.mu.Lock()
+	p.handlerID++
+	id := p.handlerID
+	p.exitHandlers = append(p.exitHandlers, exitCallback{id: id, handler: fn})
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, h := range p.exitHandlers {
+			if h.id == id {
+				p.exitHandlers = append(p.exitHandlers[:i], p.exitHandlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// exited cleans up after the read loop of stdout ended without Stop: the
+// process is reaped, pending requests fail with an ExitError and exit
+// observers are told
+func (p *Process) exited(stdout io.ReadCloser) {
+	p.mu.Lock()
+	if p.stdout != stdout {
+		p.mu.Unlock()
+		return
+	}
+	cmd, stdin := p.cmd, p.stdin
+	p.cmd = nil
+	p.stdin = nil
+	p.stdout = nil
+	p.status = StatusStopped
+	p.unhealthy = false
+	pending := p.pending
+	p.pending = make(map[int]*PendingRequest)
+	p.dropPermissions()
+	uptime := time.Since(p.startedAt)
+	handlers := make([]func(*ExitError), len(p.exitHandlers))
+	for i, h := range p.exitHandlers {
+		handlers[i] = h.handler
+	}
+	p.mu.Unlock()
+
+	p.killTerminals()
+	if stdin != nil {
+		stdin.Close()
+	}
+	var err error
+	if cmd != nil {
+		// The loop also ends on an unreadable frame with the agent still running
+		_ = cmd.Process.Kill()
+		err = cmd.Wait()
+		_ = sysutil.KillTree(cmd.Process.Pid)
+		forgetPID(cmd.Process.Pid)
+	}
+
+	exitErr := &ExitError{AgentID: p.ID, Err: err, Uptime: uptime}
+	logger.Warn("agent exited", "agent", p.ID, "error", exitErr.Err, "uptime", uptime.Round(time.Second))
+	for _, req := range pending {
+		req.Err = exitErr
+		close(req.Result)
+	}
+	for _, handler := range handlers {
+		handler(exitErr)
+	}
+}
+
+// Restart states
+const (
+	RestartScheduled = "scheduled" // The agent exited, a restart follows after DelayMs
+	RestartDone      = "restarted"
+	RestartGaveUp    = "gave_up" // Out of attempts, the next request starts the agent
+)
+
+// RestartEvent reports the supervision of an agent that exited on its own
+type RestartEvent struct {
+	AgentID string `json:"agent"`
+	State   string `json:"state"`
+	Attempt int    `json:"attempt"`
+	DelayMs int64  `json:"delayMs,omitempty"`
+	Error   string `json:"error,omitempty"` // Why the agent exited or failed to start
+}
+
+// restartState is the supervision of one agent
+type restartState struct {
+	attempt int
+	timer   *time.Timer
+}
+
+// OnRestart registers an observer of supervised restarts
+func (m *Manager) OnRestart(handler func(*RestartEvent)) {
+	m.mu.Lock()
+	m.restartHandlers = append(m.restartHandlers, handler)
+	m.mu.Unlock()
+}
+
+// supervise restarts the process with exponential backoff whenever it
+// exits on its own, as configured by the agent's restart settings
+func (m *Manager) supervise(p *Process) {
+	p.OnExit(func(err *ExitError) {
+		m.mu.Lock()
+		st := m.restarts[p.ID]
+		if st == nil {
+			st = &restartState{}
+			m.restarts[p.ID] = st
+		}
+		if err.Uptime >= restartStableAfter {
+			st.attempt = 0
+		}
+		st.attempt++
+		attempt := st.attempt
+		m.mu.Unlock()
+
+		m.scheduleRestart(p, attempt, err.Error())
+	})
+}
+
+// scheduleRestart starts the process again after the backoff of attempt,
+// unless the agent is disabled or out of attempts
+func (m *Manager) scheduleRestart(p *Process, attempt int, reason string) {
+	p.mu.Lock()
+	policy, enabled := p.config.Restart, p.config.IsEnabled()
+	p.mu.Unlock()
+
+	event := &RestartEvent{AgentID: p.ID, State: RestartScheduled, Attempt: attempt, Error: reason}
+	if !enabled || attempt > policy.Attempts() {
+		event.State = RestartGaveUp
+		m.mu.Lock()
+		delete(m.restarts, p.ID)
+		m.mu.Unlock()
+		m.emitRestart(event)
+		return
+	}
+
+	delay := policy.Delay(attempt)
+	event.DelayMs = delay.Milliseconds()
+	m.mu.Lock()
+	if st := m.restarts[p.ID]; st != nil && st.attempt == attempt {
+		st.timer = time.AfterFunc(delay, func() { m.restart(p, attempt) })
+	}
+	m.mu.Unlock()
+	m.emitRestart(event)
+}
+
+func (m *Manager) restart(p *Process, attempt int) {
+	m.mu.Lock()
+	st := m.restarts[p.ID]
+	if st == nil || st.attempt != attempt {
+		m.mu.Unlock()
+		return // Canceled by Stop
+	}
+	st.timer = nil
+	m.mu.Unlock()
+
+	// A request may have started the agent meanwhile
+	if p.Status() == StatusRunning {
+		return
+	}
+	if err := p.Start(); err != nil {
+		m.mu.Lock()
+		st.attempt++
+		next := st.attempt
+		m.mu.Unlock()
+		m.scheduleRestart(p, next, err.Error())
+		return
+	}
+	m.emitRestart(&RestartEvent{AgentID: p.ID, State: RestartDone, Attempt: attempt})
+}
+
+// cancelRestart drops a pending restart of the agent (caller holds m.mu)
+func (m *Manager) cancelRestart(id string) {
+	if st := m.restarts[id]; st != nil && st.timer != nil {
+		st.timer.Stop()
+	}
+	delete(m.restarts, id)
+}
+
+func (m *Manager) emitRestart(event *RestartEvent) {
+	m.mu.RLock()
+	handlers := append([]func(*RestartEvent){}, m.restartHandlers...)
+	m.mu.RUnlock()
+
+	logger.Info("supervisor", "agent", event.AgentID, "state", event.State, "attempt", event.Attempt)
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
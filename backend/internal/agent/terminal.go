@@ -0,0 +1,394 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+const (
+	// defaultTerminalOutputLimit caps the output kept of a terminal whose
+	// agent sets no outputByteLimit
+	defaultTerminalOutputLimit = 1 << 20
+	// keptTerminals is how many released terminals keep their output, as
+	// tool calls still show it
+	keptTerminals = 32
+	// ptyDrainWait is how long output is still read after the command exits,
+	// since background children may keep the terminal open
+	ptyDrainWait = 200 * time.Millisecond
+)
+
+// escapeSequence matches the ANSI escape sequences of terminal output, such
+// as colors and window titles, which agents and the web UI show as text
+var escapeSequence = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// plainText removes escape sequences and the carriage returns a pseudo
+// terminal puts before line feeds
+func plainText(data []byte) string {
+	text := escapeSequence.ReplaceAllString(string(data), "")
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+// TerminalExit is how a terminal command ended
+type TerminalExit struct {
+	ExitCode *int    `json:"exitCode"`
+	Signal   *string `json:"signal"`
+}
+
+// TerminalEvent reports output or the exit of a terminal an agent created
+type TerminalEvent struct {
+	SessionID  string        `json:"sessionId"`
+	TerminalID string        `json:"terminalId"`
+	Data       string        `json:"data,omitempty"`
+	Exit       *TerminalExit `json:"exitStatus,omitempty"`
+}
+
+// terminal is a command an agent runs through terminal/create
+type terminal struct {
+	id        string
+	sessionID string
+	cmd       *exec.Cmd
+	limit     int
+
+	mu        sync.Mutex
+	output    []byte
+	truncated bool
+	exit      *TerminalExit
+	released  bool
+	done      chan struct{} // Closed once the command exited and its output was read
+}
+
+// write keeps output up to the byte limit, dropping the oldest at a
+// character boundary
+func (t *terminal) write(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.output = append(t.output, data...)
+	if over := len(t.output) - t.limit; over > 0 {
+		for over < len(t.output) && !utf8.RuneStart(t.output[over]) {
+			over++
+		}
+		t.output = append(t.output[:0], t.output[over:]...)
+		t.truncated = true
+	}
+}
+
+func (t *terminal) snapshot() (string, bool, *TerminalExit) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return plainText(t.output), t.truncated, t.exit
+}
+
+// kill ends the command and its children, keeping its output
+func (t *terminal) kill() {
+	select {
+	case <-t.done:
+	default:
+		_ = sysutil.KillTree(t.cmd.Process.Pid)
+		_ = t.cmd.Process.Kill()
+	}
+}
+
+// terminalWriter passes command output to the terminal and its observers
+type terminalWriter struct {
+	p *Process
+	t *terminal
+}
+
+func (w terminalWriter) Write(data []byte) (int, error) {
+	w.t.write(data)
+	w.p.emitTerminal(&TerminalEvent{SessionID: w.t.sessionID, TerminalID: w.t.id, Data: plainText(data)})
+	return len(data), nil
+}
+
+// terminalCallback is a registered terminal callback with cleanup support
+type terminalCallback struct {
+	id      int
+	handler func(*TerminalEvent)
+}
+
+// OnTerminal registers an observer of the output and exit of the terminals
+// agents create and returns a cleanup function
+func (p *Process) OnTerminal(fn func(*TerminalEvent)) func() {
+	p.mu.Lock()
+	p.handlerID++
+	id := p.handlerID
+	p.terminalHandlers = append(p.terminalHandlers, terminalCallback{id: id, handler: fn})
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, h := range p.terminalHandlers {
+			if h.id == id {
+				p.terminalHandlers = append(p.terminalHandlers[:i], p.terminalHandlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (p *Process) emitTerminal(event *TerminalEvent) {
+	p.mu.Lock()
+	handlers := make([]func(*TerminalEvent), len(p.terminalHandlers))
+	for i, h := range p.terminalHandlers {
+		handlers[i] = h.handler
+	}
+	p.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// TerminalOutput returns the output of a terminal, also after it was
+// released, and whether it is known
+func (p *Process) TerminalOutput(terminalID string) (string, bool) {
+	p.mu.Lock()
+	t := p.terminals[terminalID]
+	p.mu.Unlock()
+	if t == nil {
+		return "", false
+	}
+	output, _, _ := t.snapshot()
+	return output, true
+}
+
+// terminalParams identifies a terminal in terminal/* requests
+type terminalParams struct {
+	SessionID  string `json:"sessionId"`
+	TerminalID string `json:"terminalId"`
+}
+
+// handleTerminalCreate starts a command on a pseudo terminal (pipes on
+// Windows) in the session's cwd and answers with its terminalId
+func (p *Process) handleTerminalCreate(msg *jsonrpc.Message) {
+	var params struct {
+		SessionID string   `json:"sessionId"`
+		Command   string   `json:"command"`
+		Args      []string `json:"args"`
+		Env       []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"env"`
+		Cwd             string `json:"cwd"`
+		OutputByteLimit int    `json:"outputByteLimit"`
+	}
+	if err := msg.ParseParams(&params); err != nil || params.Command == "" {
+		p.sendError(*msg.ID, jsonrpc.InvalidParams, "Invalid params")
+		return
+	}
+
+	env := os.Environ()
+	for _, e := range params.Env {
+		env = append(env, e.Name+"="+e.Value)
+	}
+	cmd := exec.Command(lookCommand(params.Command, env), params.Args...)
+	cmd.Dir = p.resolvePath(params.SessionID, params.Cwd)
+	cmd.Env = env
+	hideWindow(cmd)
+
+	p.mu.Lock()
+	p.handlerID++
+	t := &terminal{
+		id:        fmt.Sprintf("term-%d", p.handlerID),
+		sessionID: params.SessionID,
+		cmd:       cmd,
+		limit:     defaultTerminalOutputLimit,
+		done:      make(chan struct{}),
+	}
+	p.mu.Unlock()
+	if params.OutputByteLimit > 0 {
+		t.limit = params.OutputByteLimit
+	}
+
+	if err := p.startTerminal(t); err != nil {
+		p.sendError(*msg.ID, jsonrpc.InternalError, err.Error())
+		return
+	}
+
+	p.mu.Lock()
+	if p.terminals == nil {
+		p.terminals = make(map[string]*terminal)
+	}
+	p.terminals[t.id] = t
+	p.mu.Unlock()
+	p.sendResponse(*msg.ID, map[string]string{"terminalId": t.id})
+}
+
+// startTerminal runs the command on a pseudo terminal where there is one,
+// so it behaves as in an interactive shell, and on pipes otherwise
+func (p *Process) startTerminal(t *terminal) error {
+	out := terminalWriter{p: p, t: t}
+	ptmx, tty, err := sysutil.OpenPTY()
+	if err != nil {
+		t.cmd.Stdout = out
+		t.cmd.Stderr = out
+		sysutil.SetProcessGroup(t.cmd)
+		if err := t.cmd.Start(); err != nil {
+			return err
+		}
+		_ = sysutil.AttachToJob(t.cmd.Process.Pid)
+		go p.waitTerminal(t, nil)
+		return nil
+	}
+
+	if envValue(t.cmd.Env, "TERM") == "" {
+		t.cmd.Env = append(t.cmd.Env, "TERM=xterm-256color")
+	}
+	t.cmd.Stdin, t.cmd.Stdout, t.cmd.Stderr = tty, tty, tty
+	sysutil.SetControllingTerminal(t.cmd)
+	err = t.cmd.Start()
+	tty.Close()
+	if err != nil {
+		ptmx.Close()
+		return err
+	}
+
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		io.Copy(out, ptmx) // Ends with EIO once no process has the terminal open
+	}()
+	go func() {
+		p.waitTerminal(t, func() {
+			select {
+			case <-read:
+			case <-time.After(ptyDrainWait):
+			}
+			ptmx.Close()
+		})
+	}()
+	return nil
+}
+
+// waitTerminal records the exit of a terminal's command after drain has
+// read its remaining output
+func (p *Process) waitTerminal(t *terminal, drain func()) {
+	err := t.cmd.Wait()
+	if drain != nil {
+		drain()
+	}
+
+	exit := &TerminalExit{}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		code := 0
+		exit.ExitCode = &code
+	case errors.As(err, &exitErr):
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			signal := ws.Signal().String()
+			exit.Signal = &signal
+		} else {
+			code := exitErr.ExitCode()
+			exit.ExitCode = &code
+		}
+	}
+
+	t.mu.Lock()
+	t.exit = exit
+	t.mu.Unlock()
+	close(t.done)
+	p.emitTerminal(&TerminalEvent{SessionID: t.sessionID, TerminalID: t.id, Exit: exit})
+}
+
+// lookupTerminal parses terminal/* params and finds the terminal, answering
+// the request with an error when there is none
+func (p *Process) lookupTerminal(msg *jsonrpc.Message) *terminal {
+	var params terminalParams
+	if err := msg.ParseParams(&params); err != nil {
+		p.sendError(*msg.ID, jsonrpc.InvalidParams, "Invalid params")
+		return nil
+	}
+	p.mu.Lock()
+	t := p.terminals[params.TerminalID]
+	released := t != nil && t.released
+	p.mu.Unlock()
+	if t == nil || released {
+		p.sendError(*msg.ID, jsonrpc.InvalidParams, "Unknown terminal: "+params.TerminalID)
+		return nil
+	}
+	return t
+}
+
+func (p *Process) handleTerminalOutput(msg *jsonrpc.Message) {
+	t := p.lookupTerminal(msg)
+	if t == nil {
+		return
+	}
+	output, truncated, exit := t.snapshot()
+	result := map[string]any{"output": output, "truncated": truncated}
+	if exit != nil {
+		result["exitStatus"] = exit
+	}
+	p.sendResponse(*msg.ID, result)
+}
+
+// handleTerminalWaitForExit answers once the command exited, without
+// holding up the read loop meanwhile
+func (p *Process) handleTerminalWaitForExit(msg *jsonrpc.Message) {
+	t := p.lookupTerminal(msg)
+	if t == nil {
+		return
+	}
+	go func() {
+		<-t.done
+		_, _, exit := t.snapshot()
+		p.sendResponse(*msg.ID, exit)
+	}()
+}
+
+func (p *Process) handleTerminalKill(msg *jsonrpc.Message) {
+	t := p.lookupTerminal(msg)
+	if t == nil {
+		return
+	}
+	t.kill()
+	p.sendResponse(*msg.ID, map[string]any{})
+}
+
+// handleTerminalRelease kills the command; its output stays available to
+// TerminalOutput for the tool calls showing it
+func (p *Process) handleTerminalRelease(msg *jsonrpc.Message) {
+	t := p.lookupTerminal(msg)
+	if t == nil {
+		return
+	}
+	t.kill()
+
+	p.mu.Lock()
+	t.released = true
+	p.released = append(p.released, t.id)
+	if len(p.released) > keptTerminals {
+		delete(p.terminals, p.released[0])
+		p.released = p.released[1:]
+	}
+	p.mu.Unlock()
+	p.sendResponse(*msg.ID, map[string]any{})
+}
+
+// killTerminals ends the commands of all terminals, as the agent that
+// created them is gone
+func (p *Process) killTerminals() {
+	p.mu.Lock()
+	terminals := p.terminals
+	p.terminals = nil
+	p.released = nil
+	p.mu.Unlock()
+
+	for _, t := range terminals {
+		t.kill()
+	}
+}
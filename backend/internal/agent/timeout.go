@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+// TimeoutError reports a request that exceeded its configured timeout
+type TimeoutError struct {
+	AgentID string        `json:"agent"`
+	Method  string        `json:"method"`
+	After   time.Duration `json:"-"`
+	Quiet   bool          `json:"quiet"`  // Agent went silent rather than exceeding the total limit
+	Action  string        `json:"action"` // error, restart or notify
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Quiet {
+		return fmt.Sprintf("%s: no activity from %s for %s", e.Method, e.AgentID, e.After)
+	}
+	return fmt.Sprintf("%s: %s did not respond within %s", e.Method, e.AgentID, e.After)
+}
+
+// timeoutCallback is a registered timeout callback with cleanup support
+type timeoutCallback struct {
+	id      int
+	handler func(*TimeoutError)
+}
+
+// OnTimeout registers a timeout observer and returns a cleanup function
+func (p *Process) OnTimeout(fn func(*TimeoutError)) func() {
+	p.mu.Lock()
+	p.handlerID++
+	id := p.handlerID
+	p.timeoutHandlers = append(p.timeoutHandlers, timeoutCallback{id: id, handler: fn})
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, h := range p.timeoutHandlers {
+			if h.id == id {
+				p.timeoutHandlers = append(p.timeoutHandlers[:i], p.timeoutHandlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// timeoutsFor returns the total limit, quiet period and expiry action of a method
+func (p *Process) timeoutsFor(method string) (limit, quiet time.Duration, action string) {
+	p.mu.Lock()
+	t := p.config.Timeouts
+	p.mu.Unlock()
+	if t == nil {
+		return 0, 0, ""
+	}
+	ms := func(v int) time.Duration { return time.Duration(v) * time.Millisecond }
+
+	switch method {
+	case "initialize":
+		limit = ms(t.Initialize)
+	case "session/new":
+		limit = ms(t.SessionNew)
+	case "session/prompt":
+		limit = ms(t.Prompt)
+		quiet = ms(t.ToolQuiet)
+	}
+
+	action = t.OnExpiry
+	if action == "" {
+		action = config.TimeoutError
+	}
+	return limit, quiet, action
+}
+
+// await waits for the response to request id, enforcing configured timeouts
+func (p *Process) await(id int, method string, req *PendingRequest) (*jsonrpc.Message, error) {
+	limit, quiet, action := p.timeoutsFor(method)
+
+	var deadline, tick <-chan time.Time
+	if limit > 0 {
+		timer := time.NewTimer(limit)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	if quiet > 0 {
+		ticker := time.NewTicker(quietCheckInterval(quiet))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case msg, ok := <-req.Result:
+			if !ok {
+				if req.Err != nil {
+					return nil, req.Err
+				}
+				return nil, fmt.Errorf("request cancelled")
+			}
+			return msg, nil
+
+		case <-deadline:
+			deadline = nil
+			err := &TimeoutError{AgentID: p.ID, Method: method, After: limit, Action: action}
+			if p.expire(id, req, err) {
+				return nil, err
+			}
+
+		case <-tick:
+			if !p.isQuiet(quiet) {
+				continue
+			}
+			tick = nil // Report silence once
+			err := &TimeoutError{AgentID: p.ID, Method: method, After: quiet, Quiet: true, Action: action}
+			if p.expire(id, req, err) {
+				return nil, err
+			}
+		}
+	}
+}
+
+// expire notifies observers and, unless the action is notify, abandons the
+// request, sending session/cancel for an abandoned prompt so the agent stops
+// working on it. Returns true when the request was abandoned.
+func (p *Process) expire(id int, req *PendingRequest, err *TimeoutError) bool {
+	p.mu.Lock()
+	handlers := make([]func(*TimeoutError), len(p.timeoutHandlers))
+	for i, h := range p.timeoutHandlers {
+		handlers[i] = h.handler
+	}
+	if err.Action != config.TimeoutNotify {
+		delete(p.pending, id)
+	}
+	p.mu.Unlock()
+
+	logger.Warn("request timed out", "agent", p.ID, "error", err, "action", err.Action)
+	for _, handler := range handlers {
+		handler(err)
+	}
+
+	if err.Action == config.TimeoutNotify {
+		return false
+	}
+	if req.Method == "session/prompt" && req.SessionID != "" {
+		if cerr := p.Cancel(req.SessionID); cerr != nil {
+			logger.Warn("failed to cancel timed out prompt", "agent", p.ID, "session", req.SessionID, "error", cerr)
+		}
+	}
+	if err.Action == config.TimeoutRestart {
+		// Stopped agents are restarted by the next request
+		go p.Stop()
+	}
+	return true
+}
+
+// isQuiet reports whether the agent has been silent for d.
+// Pending permission prompts wait on the user, so they never count as silence.
+func (p *Process) isQuiet(d time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.permissions) > 0 {
+		return false
+	}
+	return time.Since(p.lastActivity) >= d
+}
+
+func quietCheckInterval(quiet time.Duration) time.Duration {
+	interval := quiet / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	if interval > time.Second {
+		interval = time.Second
+	}
+	return interval
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+func TestPromptTimeoutCancelsSession(t *testing.T) {
+	cancelled := make(chan string, 1)
+	release := make(chan struct{})
+	defer close(release)
+
+	command := fmt.Sprintf("builtin:agent-test-%d", testAgentSeq.Add(1))
+	RegisterBuiltin(command, func(r io.Reader, w io.Writer) error {
+		return mockagent.NewConn(w, func(c *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+			if msg.Method == "session/prompt" {
+				<-release // Never ends the prompt in time
+			}
+			return map[string]any{"stopReason": "end_turn"}, nil
+		}, func(c *mockagent.Conn, msg *jsonrpc.Message) {
+			if msg.Method == "session/cancel" {
+				sessionID, _ := mockagent.PromptText(msg)
+				cancelled <- sessionID
+			}
+		}).Serve(r)
+	})
+	p := NewProcess(&config.AgentConfig{
+		ID: "test", Name: "test", Command: command,
+		Timeouts: &config.TimeoutConfig{Prompt: 100},
+	})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	_, err := p.Request("session/prompt", promptParams("s1"))
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("want a TimeoutError, got %v", err)
+	}
+	select {
+	case sessionID := <-cancelled:
+		if sessionID != "s1" {
+			t.Fatalf("cancelled session %q, want s1", sessionID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out prompt was not cancelled")
+	}
+}
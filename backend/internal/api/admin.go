@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Admin endpoints control the machine rather than chats: installing and
+// logging in agents, changing agents, workspaces and the UI bundle, backups,
+// debugging and shutting down. With server.adminPort set they are only
+// served there.
+var (
+	adminPaths = map[string]bool{
+		"/api/setup/install":        true,
+		"/api/setup/install/cancel": true,
+		"/api/setup/login":          true,
+		"/api/setup/login/input":    true,
+		"/api/setup/refresh-path":   true,
+		"/api/catalog/add":          true,
+		"/api/backup":               true,
+		"/api/restore":              true,
+		"/api/frontend/upload":      true,
+		"/api/frontend/rollback":    true,
+		"/api/shutdown":             true,
+	}
+	adminPrefixes = []string{
+		"/api/agents/", // update, trace, status, stop and restart
+		"/api/debug/",
+	}
+	// Endpoints whose GET is public but other methods are admin, along with
+	// the paths under them such as /api/workspaces/{id}
+	adminWrites = []string{
+		"/api/workspaces",
+		"/api/sync",
+		"/api/frontend",
+	}
+	// Chat actions under adminWrites that stay public
+	userWrites = map[string]bool{
+		"/api/workspaces/files/reindex": true,
+	}
+)
+
+// publicKey marks requests that came in on the public listener
+type publicKey struct{}
+
+// isPublic reports whether r came in on the public listener, where admin
+// data such as agent env is withheld
+func isPublic(r *http.Request) bool {
+	public, _ := r.Context().Value(publicKey{}).(bool)
+	return public
+}
+
+// isAdminRequest reports whether r is for an admin endpoint
+func isAdminRequest(r *http.Request) bool {
+	path := r.URL.Path
+	if adminPaths[path] {
+		return true
+	}
+	if r.Method != "GET" && !userWrites[path] {
+		for _, prefix := range adminWrites {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		}
+	}
+	for _, prefix := range adminPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// publicMiddleware refuses admin endpoints on the public listener
+func publicMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAdminRequest(r) {
+			writeError(w, "Admin endpoints are only served on the admin port", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), publicKey{}, true)))
+	})
+}
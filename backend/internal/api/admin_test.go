@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+func TestIsAdminRequest(t *testing.T) {
+	tests := []struct {
+		method, path string
+		admin        bool
+	}{
+		{"GET", "/api/workspaces", false},
+		{"POST", "/api/workspaces", true},
+		{"PUT", "/api/workspaces/ws1", true},
+		{"DELETE", "/api/workspaces/ws1", true},
+		{"PUT", "/api/workspaces/ws1/memory", true},
+		{"GET", "/api/workspaces/ws1/usage", false},
+		{"POST", "/api/workspaces/files/reindex", false},
+		{"GET", "/api/sync", false},
+		{"POST", "/api/sync", true},
+		{"POST", "/api/frontend/upload", true},
+		{"GET", "/api/agents", false},
+		{"POST", "/api/agents/update", true},
+		{"POST", "/api/chat", false},
+		{"GET", "/api/workspacesx", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := isAdminRequest(r); got != tt.admin {
+			t.Errorf("%s %s: admin %v, want %v", tt.method, tt.path, got, tt.admin)
+		}
+	}
+}
+
+func TestAgentsEnvOnlyOnAdminListener(t *testing.T) {
+	s, admin := newTestServer(t, "claude", sessionCountingAgent(nil))
+	s.configMu.Lock()
+	agents := slices.Clone(s.config.Agents)
+	agents[0].Env = map[string]string{"ANTHROPIC_API_KEY": "secret"}
+	s.setAgents(agents)
+	s.configMu.Unlock()
+	public := httptest.NewServer(s.handler(true))
+	defer public.Close()
+
+	env := func(hs *httptest.Server) map[string]string {
+		t.Helper()
+		resp, err := hs.Client().Get(hs.URL + "/api/agents")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var data struct {
+			Agents []config.AgentConfig `json:"agents"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil || len(data.Agents) != 1 {
+			t.Fatalf("agents: %s", body)
+		}
+		return data.Agents[0].Env
+	}
+	if got := env(admin)["ANTHROPIC_API_KEY"]; got != "secret" {
+		t.Errorf("admin listener env: %q", got)
+	}
+	if got := env(public); got != nil {
+		t.Errorf("public listener leaked env: %v", got)
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/agent"
+)
+
+// handleAgentByID dispatches /api/agents/{id}/... routes
+func (s *Server) handleAgentByID(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/agents/")
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	agentID, action := parts[0], parts[1]
+	if !s.agents.Has(agentID) {
+		writeError(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "trace":
+		s.handleAgentTrace(w, r, agentID)
+	case "status":
+		s.handleAgentStatus(w, r, agentID)
+	case "stop":
+		s.handleAgentStop(w, r, agentID)
+	case "restart":
+		s.handleAgentRestart(w, r, agentID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleAgentTrace returns recent JSON-RPC exchanges of an agent.
+// ?since=<seq> returns only entries newer than the given sequence number.
+func (s *Server) handleAgentTrace(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	entries := s.tracer.Since(agentID, since)
+
+	var last int64 = since
+	if len(entries) > 0 {
+		last = entries[len(entries)-1].Seq
+	}
+
+	writeJSON(w, map[string]any{
+		"agent":   agentID,
+		"entries": entries,
+		"last":    last,
+	})
+}
+
+// handleAgentStatus reports an agent's process: GET
+func (s *Server) handleAgentStatus(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.agentStatusByID(agentID))
+}
+
+// handleAgentStop stops an agent's process: POST. Turns it is running fail
+// and its sessions are gone; the next turn starts it again.
+func (s *Server) handleAgentStop(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.bounceAgent(agentID, "stop")
+	writeJSON(w, s.agentStatusByID(agentID))
+}
+
+// handleAgentRestart stops an agent's process and starts and initializes a
+// new one: POST. Like a stop, turns it is running fail.
+func (s *Server) handleAgentRestart(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.AgentsPaused() {
+		writeError(w, errAgentsPaused.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if a := s.findAgent(agentID); a != nil && !a.IsEnabled() {
+		writeError(w, "Agent is disabled", http.StatusBadRequest)
+		return
+	}
+	s.bounceAgent(agentID, "restart")
+	if err := s.ensureAgentInitialized(agentID, prestartTimeout); err != nil {
+		writeError(w, "Restart failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, s.agentStatusByID(agentID))
+}
+
+// bounceAgent stops an agent's process on a user's request, forgetting its
+// initialization and sessions
+func (s *Server) bounceAgent(agentID, action string) {
+	st := s.agentStatusByID(agentID)
+	logger.Info("agent "+action+" requested", "agent", agentID, "status", st.Process, "turns", st.Turns)
+	if err := s.agents.Stop(agentID); err != nil {
+		logger.Warn("failed to stop agent", "agent", agentID, "error", err)
+	}
+	s.resetAgentState(agentID)
+}
+
+// agentStatusByID reports a configured agent, see agentStatus
+func (s *Server) agentStatusByID(agentID string) AgentStatus {
+	a := s.findAgent(agentID)
+	if a == nil {
+		return AgentStatus{ID: agentID, Process: agent.StatusIdle, Healthy: true}
+	}
+	return s.agentStatus(*a, s.turns.list())
+}
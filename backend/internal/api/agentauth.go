@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daodao97/acpone/internal/catalog"
+)
+
+// authStatusTimeout bounds how long a status command may take
+const authStatusTimeout = 10 * time.Second
+
+// loggedIn reports whether a CLI has the credentials p looks for. env
+// holds the variables configured for the agents that run it.
+func loggedIn(p *catalog.Auth, env map[string]string) bool {
+	for _, key := range p.Env {
+		if env[key] != "" || os.Getenv(key) != "" {
+			return true
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	for _, file := range p.Files {
+		if _, err := os.Stat(filepath.Join(home, file)); err == nil {
+			return true
+		}
+	}
+	for file, key := range p.JSONKeys {
+		data, err := os.ReadFile(filepath.Join(home, file))
+		if err != nil {
+			continue
+		}
+		var doc map[string]json.RawMessage
+		if json.Unmarshal(data, &doc) == nil && len(doc[key]) > 0 && string(doc[key]) != "null" {
+			return true
+		}
+	}
+
+	if len(p.Status) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), authStatusTimeout)
+		defer cancel()
+		// Runs in its own process group so the timeout kills it completely
+		cmd := installCommand(ctx, p.Status[0], p.Status[1:]...)
+		return cmd.Run() == nil
+	}
+	return false
+}
+
+// agentCommandEnv merges the env of the enabled agents that run command,
+// either directly or through their ACP package
+func (s *Server) agentCommandEnv(command string) map[string]string {
+	env := make(map[string]string)
+	for _, a := range s.agentList() {
+		if !a.IsEnabled() {
+			continue
+		}
+		runs := a.Command == command
+		if tool := s.catalog.RequiredTool(extractPackageName(a.Command, a.Args)); tool != nil && tool.Command == command {
+			runs = true
+		}
+		if !runs {
+			continue
+		}
+		for k, v := range a.Env {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// checkAgentAuth returns the login command when the agent CLI is installed
+// but not logged in, or "" when it is logged in or can't be probed
+func (s *Server) checkAgentAuth(command string) string {
+	tool := s.catalog.Tool(command)
+	if tool == nil || tool.Auth == nil || loggedIn(tool.Auth, s.agentCommandEnv(command)) {
+		return ""
+	}
+	return tool.Auth.Login
+}
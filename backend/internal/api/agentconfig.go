@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/router"
+)
+
+// Agents are added and changed while requests are served. A change builds
+// an updated copy of config.Agents and swaps it in, with a router built
+// from it, under configMu; the slice in use is never modified in place, so
+// what these accessors return stays valid after a swap.
+
+var (
+	errAgentNotFound = errors.New("Agent not found")
+	errAgentExists   = errors.New("Agent already exists")
+	errConfigSave    = errors.New("Failed to save config")
+)
+
+// agentList returns the configured agents
+func (s *Server) agentList() []config.AgentConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config.Agents
+}
+
+// findAgent returns the config of an agent, nil if unknown
+func (s *Server) findAgent(id string) *config.AgentConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config.FindAgent(id)
+}
+
+// findAgentByName returns the config of an agent by ID or alias
+func (s *Server) findAgentByName(name string) *config.AgentConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config.FindAgentByName(name)
+}
+
+// currentRouter returns the router for the current agents
+func (s *Server) currentRouter() *router.Router {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.router
+}
+
+// changeAgents applies change to a copy of the agents, then validates and
+// saves the config with the result before swapping it in, so a failure
+// leaves the config untouched
+func (s *Server) changeAgents(change func(agents []config.AgentConfig) ([]config.AgentConfig, error)) error {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	agents, err := change(slices.Clone(s.config.Agents))
+	if err != nil {
+		return err
+	}
+	candidate := *s.config
+	candidate.Agents = agents
+	if err := candidate.Validate(); err != nil {
+		return err
+	}
+	if err := candidate.Save(""); err != nil {
+		return errConfigSave
+	}
+	s.setAgents(agents)
+	return nil
+}
+
+// setAgents swaps in a new agent list and router, pointing running agent
+// processes at their new config and starting supervision of added ones
+// (caller holds configMu for writing)
+func (s *Server) setAgents(agents []config.AgentConfig) {
+	s.config.Agents = agents
+	s.router = router.New(s.config)
+	for _, proc := range s.agents.Sync(s.config) {
+		s.tracer.Attach(proc)
+		if s.recorder != nil {
+			s.recorder.Attach(proc)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+func TestAddAgentsWhileServing(t *testing.T) {
+	s, hs := newTestServer(t, "claude", sessionCountingAgent(nil))
+	if err := os.WriteFile(filepath.Join(sysutil.DataDir(), "acpone.config.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(path string, body any) int {
+		data, _ := json.Marshal(body)
+		resp, err := hs.Client().Post(hs.URL+path, "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Adds race each other and the requests reading agents and routes
+	const adds = 5
+	var wg sync.WaitGroup
+	conflicts := make(chan int, adds)
+	for i := 0; i < adds; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			post("/api/catalog/add", map[string]any{"id": "codex", "agentId": fmt.Sprintf("codex-%d", i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			conflicts <- post("/api/catalog/add", map[string]any{"id": "gemini"})
+		}()
+		go func() {
+			defer wg.Done()
+			for _, path := range []string{"/api/agents", "/api/route/explain?text=hi"} {
+				if resp, err := hs.Client().Get(hs.URL + path); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(conflicts)
+
+	if n := len(s.agentList()); n != 1+adds+1 {
+		t.Fatalf("%d agents after adding %d, want %d", n, adds+1, 1+adds+1)
+	}
+	created := 0
+	for status := range conflicts {
+		if status == http.StatusOK {
+			created++
+		} else if status != http.StatusConflict {
+			t.Errorf("adding a duplicate agent: status %d", status)
+		}
+	}
+	if created != 1 {
+		t.Errorf("the same agent was added %d times", created)
+	}
+	for i := 0; i < adds; i++ {
+		if !s.currentRouter().HasAgent(fmt.Sprintf("codex-%d", i)) {
+			t.Errorf("router misses codex-%d", i)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/events"
+)
+
+// prestartTimeout bounds how long a single agent may take to initialize at boot
+const prestartTimeout = 60 * time.Second
+
+// Agent initialization states
+const (
+	initPending = "pending"
+	initReady   = "ready"
+	initError   = "error"
+)
+
+// agentInit tracks the initialize handshake of one agent
+type agentInit struct {
+	State      string `json:"state"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	done       chan struct{}
+
+	prompt promptCaps // Content the agent accepts in prompts
+}
+
+// promptCaps are the promptCapabilities an agent reports on initialize
+type promptCaps struct {
+	EmbeddedContext bool `json:"embeddedContext"` // Resource blocks
+	Image           bool `json:"image"`           // Image blocks
+}
+
+// ensureAgentInitialized runs the initialize handshake once per agent.
+// Concurrent callers wait for the in-flight attempt; failed attempts are retried.
+func (s *Server) ensureAgentInitialized(agentID string, timeout time.Duration) error {
+	s.initMu.Lock()
+	st := s.initialized[agentID]
+	if st != nil && st.State != initError {
+		s.initMu.Unlock()
+		<-st.done
+		return s.initErr(st)
+	}
+	st = &agentInit{State: initPending, done: make(chan struct{})}
+	s.initialized[agentID] = st
+	s.initMu.Unlock()
+
+	start := time.Now()
+	err := s.initializeAgentWithTimeout(agentID, timeout)
+
+	s.initMu.Lock()
+	st.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		st.State = initError
+		st.Error = err.Error()
+	} else {
+		st.State = initReady
+	}
+	s.initMu.Unlock()
+	close(st.done)
+
+	s.events.Publish(events.Event{Topic: events.Agent, Type: "init", Data: map[string]any{
+		"agent":      agentID,
+		"state":      st.State,
+		"error":      st.Error,
+		"durationMs": st.DurationMs,
+	}})
+	return err
+}
+
+func (s *Server) initErr(st *agentInit) error {
+	s.initMu.Lock()
+	defer s.initMu.Unlock()
+	if st.State == initError {
+		return fmt.Errorf("%s", st.Error)
+	}
+	return nil
+}
+
+// initializeAgentWithTimeout stops the agent if it does not answer in time,
+// which releases the pending initialize request. A zero timeout waits forever.
+func (s *Server) initializeAgentWithTimeout(agentID string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return s.initializeAgent(agentID)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.initializeAgent(agentID)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		_ = s.agents.Stop(agentID)
+		<-done
+		return fmt.Errorf("initialize timed out after %s", timeout)
+	}
+}
+
+// recordCapabilities stores the prompt capabilities from an initialize result
+func (s *Server) recordCapabilities(agentID string, result any) {
+	var caps struct {
+		AgentCapabilities struct {
+			PromptCapabilities promptCaps `json:"promptCapabilities"`
+		} `json:"agentCapabilities"`
+	}
+	data, _ := json.Marshal(result)
+	if json.Unmarshal(data, &caps) != nil {
+		return
+	}
+
+	s.initMu.Lock()
+	defer s.initMu.Unlock()
+	if st := s.initialized[agentID]; st != nil {
+		st.prompt = caps.AgentCapabilities.PromptCapabilities
+	}
+}
+
+// promptCapabilities returns what the agent accepts in prompts, nothing
+// before it is initialized
+func (s *Server) promptCapabilities(agentID string) promptCaps {
+	s.initMu.Lock()
+	defer s.initMu.Unlock()
+	if st := s.initialized[agentID]; st != nil {
+		return st.prompt
+	}
+	return promptCaps{}
+}
+
+// resetAgentState forgets the initialization state and all agent sessions
+// so the next chat re-initializes the agent
+func (s *Server) resetAgentState(agentID string) {
+	s.initMu.Lock()
+	delete(s.initialized, agentID)
+	s.initMu.Unlock()
+
+	s.agentSessions.forgetAgent(agentID)
+
+	s.events.Publish(events.Event{Topic: events.Agent, Type: "reset", Data: map[string]any{"agent": agentID}})
+}
+
+// resetIfExited clears state of an initialized agent whose process has since
+// stopped (crash, timeout restart), since its sessions died with it
+func (s *Server) resetIfExited(agentID string) {
+	proc, err := s.agents.Get(agentID)
+	if err != nil || proc.Status() == agent.StatusRunning {
+		return
+	}
+	if st := s.agentInitSnapshot(agentID); st != nil && st.State == initReady {
+		logger.Info("re-initializing agent", "agent", agentID, "status", proc.Status())
+		s.resetAgentState(agentID)
+	}
+}
+
+// agentInitSnapshot returns a copy of the agent's initialization state, or nil
+func (s *Server) agentInitSnapshot(agentID string) *agentInit {
+	s.initMu.Lock()
+	defer s.initMu.Unlock()
+	st, ok := s.initialized[agentID]
+	if !ok {
+		return nil
+	}
+	return &agentInit{State: st.State, Error: st.Error, DurationMs: st.DurationMs}
+}
+
+// setupAgentRestarts follows the agents the manager restarts after they
+// exit on their own: their sessions died with the process, and restarted
+// agents are initialized again so the next chat finds them ready
+func (s *Server) setupAgentRestarts() {
+	s.agents.OnRestart(func(ev *agent.RestartEvent) {
+		s.events.Publish(events.Event{Topic: events.Agent, Type: "restart", Data: ev})
+		if ev.State != agent.RestartDone {
+			s.resetAgentState(ev.AgentID)
+			return
+		}
+		if s.AgentsPaused() {
+			return
+		}
+		go func() {
+			if err := s.ensureAgentInitialized(ev.AgentID, prestartTimeout); err != nil {
+				logger.Warn("initialize after restart failed", "agent", ev.AgentID, "error", err)
+			}
+		}()
+	})
+}
+
+// prestartAgents initializes all prestart-flagged agents concurrently
+func (s *Server) prestartAgents() {
+	var wg sync.WaitGroup
+	for _, a := range s.agentList() {
+		if !a.Prestart || !a.IsEnabled() {
+			continue
+		}
+		wg.Add(1)
+		go func(agentID string) {
+			defer wg.Done()
+			start := time.Now()
+			if err := s.ensureAgentInitialized(agentID, prestartTimeout); err != nil {
+				logger.Warn("prestart failed", "agent", agentID, "error", err)
+				return
+			}
+			logger.Info("prestarted", "agent", agentID, "duration", time.Since(start).Round(time.Millisecond))
+		}(a.ID)
+	}
+	wg.Wait()
+}
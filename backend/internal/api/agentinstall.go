@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/daodao97/acpone/internal/installer"
+)
+
+// installerCheckTimeout bounds listing a tool's installed packages
+const installerCheckTimeout = 30 * time.Second
+
+// agentInstaller returns the installer of the agent with an "install"
+// config, or nil
+func (s *Server) agentInstaller(agentID string) installer.Installer {
+	if agentID == "" {
+		return nil
+	}
+	for _, a := range s.agentList() {
+		if a.ID != agentID || a.Install == nil {
+			continue
+		}
+		inst, err := installer.New(a.Install, a.Command)
+		if err != nil {
+			return nil
+		}
+		return inst
+	}
+	return nil
+}
+
+// checkInstalledAgent checks an agent with an "install" config, updating
+// dep, and reports whether it is installed
+func (s *Server) checkInstalledAgent(dep *DependencyItem, item DependencyItem) bool {
+	inst := s.agentInstaller(item.Agent)
+	tool := ""
+	installed := false
+	login := ""
+	if inst != nil {
+		tool = inst.Tool()
+		if tool == "" || commandExists(tool) {
+			ctx, cancel := context.WithTimeout(context.Background(), installerCheckTimeout)
+			installed = inst.Check(ctx)
+			cancel()
+			tool = ""
+		}
+	}
+	var arch, note string
+	if installed {
+		login = s.checkAgentAuth(item.Command)
+		arch, note = executableArch(item.Command)
+	}
+
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+	dep.Arch = arch
+	switch {
+	case inst == nil:
+		dep.Status = "error"
+		dep.Message = "Unknown install type"
+	case installed && login != "":
+		dep.Status = "needs_login"
+		dep.Message = "Not logged in"
+		dep.Login = login
+	case installed:
+		dep.Status = "ready"
+		dep.Message = "Installed" + note
+	case s.config.Offline:
+		dep.Status = "missing_offline"
+		dep.Message = "Not installed, install it while online"
+	case tool != "":
+		// Installing fails until the tool is installed
+		dep.Status = "missing"
+		dep.Message = "Requires " + tool
+		dep.Install = installer.ToolInstructions[tool]
+	default:
+		dep.Status = "missing"
+		dep.Message = "Not installed"
+		dep.Install = inst.String()
+	}
+	return installed
+}
+
+// installWith installs, or updates, an agent with its installer
+func installWith(ctx context.Context, inst installer.Installer, update bool, logFn func(string)) error {
+	if tool := inst.Tool(); tool != "" && !commandExists(tool) {
+		return fmt.Errorf("%s is required, see %s", tool, installer.ToolInstructions[tool])
+	}
+	if update {
+		return inst.Update(ctx, logFn)
+	}
+	return inst.Install(ctx, logFn)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/events"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// agentLogFile is the agent log, relative to the workspace root
+const agentLogFile = "AGENT_LOG.md"
+
+// Limits keeping entries of the agent log short
+const (
+	agentLogMaxFiles   = 30
+	agentLogMaxSummary = 200
+)
+
+// setupAgentLog rewrites AGENT_LOG.md in workspaces with "agentLog": true
+// in .acpone.json after each turn, so the project documents what agents
+// did to it
+func (s *Server) setupAgentLog() {
+	var mu sync.Mutex // One rewrite at a time
+	s.events.Subscribe(events.Filter{Topics: []events.Topic{events.Turn}}, func(ev events.Event) {
+		if ev.ConversationID == "" || (ev.Type != "done" && ev.Type != "error") {
+			return
+		}
+		conv := s.conversations.Get(ev.ConversationID)
+		if conv == nil {
+			return
+		}
+		if pc := s.projectConfig(conv.WorkspaceID); pc == nil || !pc.AgentLog {
+			return
+		}
+		go func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if err := s.writeAgentLog(conv.WorkspaceID, ev.ConversationID); err != nil {
+				storageLog.Warn("agent log failed", "workspace", conv.WorkspaceID, "error", err)
+			}
+		}()
+	})
+}
+
+// writeAgentLog regenerates the workspace's agent log from its stored
+// conversations and the one whose turn just ended, leaving the file alone
+// when nothing changed
+func (s *Server) writeAgentLog(workspaceID, convID string) error {
+	var sessions []*storage.StoredSession
+	for _, meta := range s.sessionStore.List() {
+		if meta.WorkspaceID != workspaceID || meta.ID == convID {
+			continue
+		}
+		if stored, err := s.sessionStore.Load(meta.ID); err == nil && stored.DeletedAt == 0 {
+			sessions = append(sessions, stored)
+		}
+	}
+	if current := s.storedConversation(convID); current != nil && current.WorkspaceID == workspaceID {
+		sessions = append(sessions, current)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].UpdatedAt > sessions[j].UpdatedAt })
+
+	path := filepath.Join(s.resolveWorkspacePath(workspaceID), agentLogFile)
+	content := renderAgentLog(sessions)
+	if old, err := os.ReadFile(path); err == nil && string(old) == content {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// renderAgentLog lists conversations, newest first, with their title,
+// dates, agents, outcome and the files their turns changed
+func renderAgentLog(sessions []*storage.StoredSession) string {
+	var b strings.Builder
+	b.WriteString("# Agent Log\n\n")
+	b.WriteString("_Conversations agents had in this workspace, newest first. Generated by acpone after each turn; edits are overwritten._\n")
+	for _, session := range sessions {
+		fmt.Fprintf(&b, "\n## %s\n\n", oneLine(session.Title))
+		date := formatLogTime(session.CreatedAt)
+		if updated := formatLogTime(session.UpdatedAt); updated != date {
+			date += " (last turn " + updated + ")"
+		}
+		fmt.Fprintf(&b, "- **Date:** %s\n", date)
+		fmt.Fprintf(&b, "- **Conversation:** `%s`\n", session.ID)
+		if agents := turnAgents(session); len(agents) > 0 {
+			fmt.Fprintf(&b, "- **Agents:** %s\n", strings.Join(agents, ", "))
+		}
+		outcome := turnOutcome(session.Turns)
+		if summary := lastReply(session.Messages); summary != "" {
+			outcome += " — " + summary
+		}
+		fmt.Fprintf(&b, "- **Outcome:** %s\n", outcome)
+
+		files := turnFiles(session.Turns)
+		if len(files) == 0 {
+			b.WriteString("- **Files changed:** none\n")
+			continue
+		}
+		b.WriteString("- **Files changed:**\n")
+		for i, f := range files {
+			if i == agentLogMaxFiles {
+				fmt.Fprintf(&b, "  - … and %d more\n", len(files)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  - `%s`\n", f)
+		}
+	}
+	return b.String()
+}
+
+// turnOutcome describes how the conversation's last turn ended
+func turnOutcome(turns []conversation.Turn) string {
+	if len(turns) == 0 {
+		return "No recorded turns"
+	}
+	last := turns[len(turns)-1]
+	switch {
+	case last.Truncated:
+		return "Stopped at a limit"
+	case last.StopReason == "end_turn":
+		return "Completed"
+	case last.StopReason == "cancelled":
+		return "Cancelled"
+	case last.StopReason == conversation.StopError:
+		return "Failed"
+	case last.StopReason == "refusal":
+		return "Refused"
+	case last.StopReason == "max_tokens" || last.StopReason == "max_turn_requests":
+		return "Stopped at a limit"
+	default:
+		return "Ended (" + last.StopReason + ")"
+	}
+}
+
+// lastReply returns the first line of prose of the agents' last reply,
+// shortened
+func lastReply(messages []conversation.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role != "assistant" || msg.Kind != "" || msg.ToolCall != nil || msg.Agent == "" {
+			continue
+		}
+		for _, line := range strings.Split(msg.Content, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "```") {
+				continue
+			}
+			line = strings.TrimLeft(line, ">*- ")
+			if r := []rune(line); len(r) > agentLogMaxSummary {
+				line = string(r[:agentLogMaxSummary]) + "…"
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// turnAgents lists the agents that answered, in the order they first did
+func turnAgents(session *storage.StoredSession) []string {
+	var agents []string
+	for _, t := range session.Turns {
+		if t.Agent != "" && !slices.Contains(agents, t.Agent) {
+			agents = append(agents, t.Agent)
+		}
+	}
+	if len(agents) == 0 && session.ActiveAgent != "" {
+		agents = append(agents, session.ActiveAgent)
+	}
+	return agents
+}
+
+// turnFiles returns the files changed by any of the turns, sorted
+func turnFiles(turns []conversation.Turn) []string {
+	var files []string
+	for _, t := range turns {
+		for _, f := range t.Files {
+			if !slices.Contains(files, f) {
+				files = append(files, f)
+			}
+		}
+	}
+	slices.Sort(files)
+	return files
+}
+
+func formatLogTime(ms int64) string {
+	return time.UnixMilli(ms).Format("2006-01-02 15:04")
+}
+
+// oneLine joins the lines of a title
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	// ansiRegex matches terminal escape sequences in CLI output
+	ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|\x1b\][^\x07]*\x07`)
+	// loginURLRegex finds verification URLs printed by login commands
+	loginURLRegex = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	// loginCodeRegex finds device codes such as ABCD-1234
+	loginCodeRegex = regexp.MustCompile(`\b[A-Z0-9]{4,5}-[A-Z0-9]{4,5}\b`)
+)
+
+// agentLogins tracks the stdin of running login commands, so codes the
+// user pastes in the browser reach the CLI
+type agentLogins struct {
+	mu     sync.Mutex
+	stdins map[string]io.Writer
+}
+
+// start registers the stdin of command's login, failing if one runs already
+func (l *agentLogins) start(command string, stdin io.Writer) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.stdins[command]; ok {
+		return false
+	}
+	if l.stdins == nil {
+		l.stdins = make(map[string]io.Writer)
+	}
+	l.stdins[command] = stdin
+	return true
+}
+
+func (l *agentLogins) end(command string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.stdins, command)
+}
+
+// write sends a line to the running login of command
+func (l *agentLogins) write(command, text string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stdin, ok := l.stdins[command]
+	if !ok {
+		return errors.New("no login running for " + command)
+	}
+	_, err := io.WriteString(stdin, text+"\n")
+	return err
+}
+
+// handleSetupLogin runs an agent's login command and streams its output as
+// SSE: "output" chunks, the "url" and "code" found in them, and "done". The
+// login is killed when the client disconnects.
+func (s *Server) handleSetupLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Command string `json:"command"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	tool := s.catalog.Tool(req.Command)
+	if tool == nil || tool.Auth == nil || tool.Auth.Login == "" {
+		writeError(w, "no login command for "+req.Command, http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	login := tool.Auth.Login
+	args := strings.Fields(login)
+	cmd := installCommand(r.Context(), args[0], args[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !s.logins.start(req.Command, stdin) {
+		writeError(w, "login already running for "+req.Command, http.StatusConflict)
+		return
+	}
+	defer s.logins.end(req.Command)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	stream, closeStream := s.newEventStream(setupTopic, sseWriter(w, flusher))
+	defer closeStream()
+	sendEvent := stream.Send
+
+	setupLog.Info("logging in", "command", login)
+	sendEvent("output", map[string]any{"text": "$ " + login + "\n"})
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		sendEvent("done", map[string]any{"success": false, "error": err.Error()})
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		seen := make(map[string]bool)
+		scan := func(lines string) {
+			for _, re := range []*regexp.Regexp{loginURLRegex, loginCodeRegex} {
+				for _, match := range re.FindAllString(lines, -1) {
+					if seen[match] {
+						continue
+					}
+					seen[match] = true
+					if re == loginURLRegex {
+						sendEvent("url", map[string]any{"url": match})
+					} else {
+						sendEvent("code", map[string]any{"code": match})
+					}
+				}
+			}
+		}
+
+		// Only complete lines are scanned, a URL may continue in the next chunk
+		var pending string
+		buf := make([]byte, 4096)
+		for {
+			n, err := pr.Read(buf)
+			if n > 0 {
+				text := ansiRegex.ReplaceAllString(string(buf[:n]), "")
+				sendEvent("output", map[string]any{"text": text})
+
+				pending += text
+				if i := strings.LastIndexAny(pending, "\r\n"); i >= 0 {
+					scan(pending[:i])
+					pending = pending[i+1:]
+				}
+			}
+			if err != nil {
+				scan(pending)
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	pw.Close()
+	<-done
+
+	// The exit code of interactive logins is unreliable, probe again instead
+	stillNeeded := s.checkAgentAuth(req.Command)
+	success := stillNeeded == ""
+	s.setAgentLogin(req.Command, stillNeeded)
+
+	result := map[string]any{"success": success}
+	switch {
+	case r.Context().Err() != nil:
+		setupLog.Info("login canceled", "command", req.Command)
+		return
+	case !success && err != nil:
+		result["error"] = err.Error()
+	case !success:
+		result["error"] = "still not logged in"
+	}
+	setupLog.Info("login finished", "command", req.Command, "success", success)
+	sendEvent("done", result)
+}
+
+// handleSetupLoginInput passes a line typed in the browser, such as an
+// authorization code, to a running login
+func (s *Server) handleSetupLoginInput(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Command string `json:"command"`
+		Text    string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := s.logins.write(req.Command, req.Text); err != nil {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]any{"success": true})
+}
+
+// setAgentLogin updates the setup items of command after a login attempt
+func (s *Server) setAgentLogin(command, login string) {
+	s.setupMu.Lock()
+	for i := range s.setupStatus.Agents {
+		dep := &s.setupStatus.Agents[i]
+		if dep.Command != command || (dep.Status != "ready" && dep.Status != "needs_login") {
+			continue
+		}
+		dep.Login = login
+		if login == "" {
+			dep.Status = "ready"
+			dep.Message = "Logged in"
+		} else {
+			dep.Status = "needs_login"
+			dep.Message = "Not logged in"
+		}
+	}
+	s.setupMu.Unlock()
+	s.broadcastSetupStatus()
+}
@@ -0,0 +1,46 @@
+package api
+
+import "github.com/daodao97/acpone/internal/config"
+
+// mergeAgentParams overlays a turn's agent parameters on the conversation's,
+// a nil value removing the parameter
+func mergeAgentParams(params, override map[string]any) map[string]any {
+	if len(override) == 0 {
+		return params
+	}
+	merged := make(map[string]any, len(params)+len(override))
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range override {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// promptParams builds the session/prompt params, carrying the agent
+// parameters in _meta or, for agents configured with paramsIn "prompt", as
+// fields of their own
+func (s *Server) promptParams(agentID, sessionID string, prompt []map[string]any, params map[string]any) map[string]any {
+	req := map[string]any{
+		"sessionId": sessionID,
+		"prompt":    prompt,
+	}
+	if len(params) == 0 {
+		return req
+	}
+	if a := s.findAgent(agentID); a != nil && a.ParamsIn == config.ParamsPrompt {
+		for k, v := range params {
+			if _, reserved := req[k]; !reserved {
+				req[k] = v
+			}
+		}
+		return req
+	}
+	req["_meta"] = params
+	return req
+}
@@ -0,0 +1,174 @@
+package api
+
+import "sync"
+
+// agentSessions holds the agent sessions of each conversation, which
+// concurrent chats, resets and deletes all update
+type agentSessions struct {
+	mu     sync.Mutex
+	byConv map[string]map[string]string // convID -> agentID -> sessionID
+	// Sessions left in the workspaces a conversation switched away from:
+	// convID -> workspaceID -> agentID -> sessionID
+	parked map[string]map[string]map[string]string
+	// Conversations whose next turn carries the recent messages over as
+	// context, after a workspace switch or when seeded with earlier ones
+	carry map[string]bool
+	// Sessions being created, so concurrent turns wait for and share them
+	creating map[convAgent]*sessionCreation
+}
+
+// convAgent identifies a conversation's session with an agent
+type convAgent struct {
+	convID, agentID string
+}
+
+// sessionCreation is a session/new in flight for a conversation and agent
+type sessionCreation struct {
+	done      chan struct{}
+	sessionID string
+	err       error
+	dropped   bool // Forgotten meanwhile, so not recorded
+}
+
+// get returns the conversation's session with an agent, "" when it has none
+func (a *agentSessions) get(convID, agentID string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.byConv[convID][agentID]
+}
+
+// getOrCreate returns the conversation's session with an agent, creating
+// it with create when there is none. Turns asking while it is created wait
+// and share it; created reports whether this call created it.
+func (a *agentSessions) getOrCreate(convID, agentID string, create func() (string, error)) (sessionID string, created bool, err error) {
+	a.mu.Lock()
+	if sessionID := a.byConv[convID][agentID]; sessionID != "" {
+		a.mu.Unlock()
+		return sessionID, false, nil
+	}
+	key := convAgent{convID, agentID}
+	if c := a.creating[key]; c != nil {
+		a.mu.Unlock()
+		<-c.done
+		return c.sessionID, false, c.err
+	}
+	c := &sessionCreation{done: make(chan struct{})}
+	if a.creating == nil {
+		a.creating = make(map[convAgent]*sessionCreation)
+	}
+	a.creating[key] = c
+	a.mu.Unlock()
+
+	c.sessionID, c.err = create()
+
+	a.mu.Lock()
+	if a.creating[key] == c {
+		delete(a.creating, key)
+	}
+	if c.err == nil && !c.dropped {
+		a.set(convID, agentID, c.sessionID)
+	}
+	a.mu.Unlock()
+	close(c.done)
+	return c.sessionID, true, c.err
+}
+
+// dropCreating keeps sessions being created for which match from being
+// recorded (caller holds a.mu)
+func (a *agentSessions) dropCreating(match func(convAgent) bool) {
+	for key, c := range a.creating {
+		if match(key) {
+			c.dropped = true
+			delete(a.creating, key)
+		}
+	}
+}
+
+// set records the conversation's session with an agent (caller holds a.mu)
+func (a *agentSessions) set(convID, agentID, sessionID string) {
+	if a.byConv == nil {
+		a.byConv = make(map[string]map[string]string)
+	}
+	if a.byConv[convID] == nil {
+		a.byConv[convID] = make(map[string]string)
+	}
+	a.byConv[convID][agentID] = sessionID
+}
+
+// forget drops all agent sessions of a conversation, so its next turn
+// starts new ones
+func (a *agentSessions) forget(convID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.byConv, convID)
+	delete(a.parked, convID)
+	delete(a.carry, convID)
+	a.dropCreating(func(key convAgent) bool { return key.convID == convID })
+}
+
+// forgetAgent drops the sessions of an agent whose process went away
+func (a *agentSessions) forgetAgent(agentID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, sessions := range a.byConv {
+		delete(sessions, agentID)
+	}
+	for _, workspaces := range a.parked {
+		for _, sessions := range workspaces {
+			delete(sessions, agentID)
+		}
+	}
+	a.dropCreating(func(key convAgent) bool { return key.agentID == agentID })
+}
+
+// switchWorkspace sets the conversation's sessions, which run in the
+// workspace it leaves, aside and brings back those it left in the one it
+// returns to, reporting whether there were any
+func (a *agentSessions) switchWorkspace(convID, from, to string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.parked == nil {
+		a.parked = make(map[string]map[string]map[string]string)
+	}
+	if a.parked[convID] == nil {
+		a.parked[convID] = make(map[string]map[string]string)
+	}
+	if sessions := a.byConv[convID]; len(sessions) > 0 {
+		a.parked[convID][from] = sessions
+	}
+	resumed := a.parked[convID][to]
+	delete(a.parked[convID], to)
+	// Sessions still being created run in the workspace left
+	a.dropCreating(func(key convAgent) bool { return key.convID == convID })
+	if a.byConv == nil {
+		a.byConv = make(map[string]map[string]string)
+	}
+	a.byConv[convID] = resumed
+	a.carryLocked(convID)
+	return len(resumed) > 0
+}
+
+// carryContext makes the conversation's next turn carry the recent
+// messages over as context
+func (a *agentSessions) carryContext(convID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.carryLocked(convID)
+}
+
+func (a *agentSessions) carryLocked(convID string) {
+	if a.carry == nil {
+		a.carry = make(map[string]bool)
+	}
+	a.carry[convID] = true
+}
+
+// takeCarry reports whether the conversation's next turn carries context,
+// which then no longer applies to the turns after it
+func (a *agentSessions) takeCarry(convID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	carry := a.carry[convID]
+	delete(a.carry, convID)
+	return carry
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/mockagent"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+func TestAgentSessionsGetOrCreateOnce(t *testing.T) {
+	var a agentSessions
+	var creates atomic.Int32
+	create := func() (string, error) {
+		n := creates.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return fmt.Sprintf("s%d", n), nil
+	}
+
+	const turns = 8
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	ids := make([]string, turns)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, isNew, err := a.getOrCreate("c1", "claude", create)
+			if err != nil {
+				t.Error(err)
+			}
+			if isNew {
+				created.Add(1)
+			}
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	if creates.Load() != 1 || created.Load() != 1 {
+		t.Fatalf("created %d sessions, %d turns saw them as new; want 1 and 1", creates.Load(), created.Load())
+	}
+	for _, id := range ids {
+		if id != "s1" {
+			t.Fatalf("turns got sessions %v, want all s1", ids)
+		}
+	}
+	if got := a.get("c1", "claude"); got != "s1" {
+		t.Errorf("recorded session %q, want s1", got)
+	}
+}
+
+func TestAgentSessionsCreateFailure(t *testing.T) {
+	var a agentSessions
+	_, _, err := a.getOrCreate("c1", "claude", func() (string, error) { return "", errors.New("boom") })
+	if err == nil {
+		t.Fatal("want the creation error")
+	}
+	if got := a.get("c1", "claude"); got != "" {
+		t.Fatalf("failed creation recorded %q", got)
+	}
+	id, created, err := a.getOrCreate("c1", "claude", func() (string, error) { return "s2", nil })
+	if err != nil || !created || id != "s2" {
+		t.Fatalf("retry: %q, %v, %v", id, created, err)
+	}
+}
+
+func TestAgentSessionsForgetWhileCreating(t *testing.T) {
+	for name, forget := range map[string]func(a *agentSessions){
+		"conversation": func(a *agentSessions) { a.forget("c1") },
+		"agent":        func(a *agentSessions) { a.forgetAgent("claude") },
+		"workspace":    func(a *agentSessions) { a.switchWorkspace("c1", "w1", "w2") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var a agentSessions
+			started, release := make(chan struct{}), make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				a.getOrCreate("c1", "claude", func() (string, error) {
+					close(started)
+					<-release
+					return "stale", nil
+				})
+			}()
+			<-started
+			forget(&a)
+			close(release)
+			<-done
+
+			if got := a.get("c1", "claude"); got != "" {
+				t.Fatalf("session created before the reset was recorded: %q", got)
+			}
+		})
+	}
+}
+
+func TestAgentSessionsLifecycle(t *testing.T) {
+	var a agentSessions
+	session := func(id string) func() (string, error) {
+		return func() (string, error) { return id, nil }
+	}
+	a.getOrCreate("c1", "claude", session("c1-claude"))
+	a.getOrCreate("c1", "codex", session("c1-codex"))
+	a.getOrCreate("c2", "claude", session("c2-claude"))
+
+	// Switching workspaces parks the sessions and brings them back
+	if a.switchWorkspace("c1", "w1", "w2") {
+		t.Fatal("resumed sessions in a workspace never used")
+	}
+	if got := a.get("c1", "claude"); got != "" {
+		t.Fatalf("session of the workspace left still active: %q", got)
+	}
+	if !a.takeCarry("c1") || a.takeCarry("c1") {
+		t.Fatal("a workspace switch carries context to exactly the next turn")
+	}
+	if !a.switchWorkspace("c1", "w2", "w1") || a.get("c1", "codex") != "c1-codex" {
+		t.Fatal("sessions not resumed on switching back")
+	}
+
+	// An agent exiting ends its sessions in every conversation, parked ones too
+	a.switchWorkspace("c2", "w1", "w2")
+	a.getOrCreate("c2", "claude", session("c2-claude-w2"))
+	a.forgetAgent("claude")
+	if a.get("c1", "claude") != "" || a.get("c2", "claude") != "" {
+		t.Fatal("sessions of the exited agent survived")
+	}
+	if a.get("c1", "codex") != "c1-codex" {
+		t.Fatal("sessions of other agents were dropped")
+	}
+	if a.switchWorkspace("c2", "w2", "w1") {
+		t.Fatal("parked session of the exited agent survived")
+	}
+
+	// Deleting a conversation drops everything about it
+	a.carryContext("c1")
+	a.forget("c1")
+	if a.get("c1", "codex") != "" || a.takeCarry("c1") {
+		t.Fatal("state of the deleted conversation survived")
+	}
+}
+
+// sessionCountingAgent serves a minimal agent whose session/new is slow, so
+// concurrent turns overlap, and counted
+func sessionCountingAgent(sessions *atomic.Int32) agent.ServeFunc {
+	return func(r io.Reader, w io.Writer) error {
+		conn := mockagent.NewConn(w, func(c *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+			switch msg.Method {
+			case "initialize":
+				return map[string]any{"protocolVersion": 1, "agentCapabilities": map[string]any{}}, nil
+			case "session/new":
+				time.Sleep(100 * time.Millisecond)
+				return map[string]any{"sessionId": fmt.Sprintf("session-%d", sessions.Add(1))}, nil
+			case "session/prompt":
+				sessionID, _ := mockagent.PromptText(msg)
+				c.Update(sessionID, map[string]any{
+					"sessionUpdate": "agent_message_chunk",
+					"content":       map[string]string{"type": "text", "text": "ok"},
+				})
+				return map[string]any{"stopReason": "end_turn"}, nil
+			default:
+				return map[string]any{}, nil
+			}
+		}, nil)
+		return conn.Serve(r)
+	}
+}
+
+var testAgentSeq atomic.Int32
+
+// newTestServer starts a server with an agent served in process, its data
+// in a temp dir
+func newTestServer(t *testing.T, agentID string, serve agent.ServeFunc) (*Server, *httptest.Server) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(sysutil.DataDirEnv, t.TempDir())
+
+	command := fmt.Sprintf("builtin:api-test-%d", testAgentSeq.Add(1))
+	agent.RegisterBuiltin(command, serve)
+	cfg := &config.Config{
+		DefaultAgent:     agentID,
+		Agents:           []config.AgentConfig{{ID: agentID, Name: agentID, Command: command}},
+		Workspaces:       []config.WorkspaceConfig{{ID: "default", Name: "Default", Path: t.TempDir()}},
+		DefaultWorkspace: "default",
+	}
+	s := NewServer(cfg, nil)
+	hs := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		hs.Close()
+		s.Shutdown()
+	})
+	return s, hs
+}
+
+// chat runs a turn of the conversation through /api/chat
+func chat(t *testing.T, hs *httptest.Server, convID string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"conversationId": convID, "message": "hi"})
+	resp, err := hs.Client().Post(hs.URL+"/api/chat", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+}
+
+// newConversation creates a conversation through /api/sessions/new
+func newConversation(t *testing.T, hs *httptest.Server) string {
+	t.Helper()
+	resp, err := hs.Client().Post(hs.URL+"/api/sessions/new", "application/json", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data struct {
+		Session struct {
+			ID string `json:"id"`
+		} `json:"session"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil || data.Session.ID == "" {
+		t.Fatalf("create conversation: %v", err)
+	}
+	return data.Session.ID
+}
+
+func TestConcurrentTurnsShareAgentSession(t *testing.T) {
+	var sessions atomic.Int32
+	s, hs := newTestServer(t, "slow", sessionCountingAgent(&sessions))
+	convID := newConversation(t, hs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chat(t, hs, convID)
+		}()
+	}
+	wg.Wait()
+
+	if n := sessions.Load(); n != 1 {
+		t.Fatalf("concurrent turns created %d agent sessions, want 1", n)
+	}
+	if got := s.agentSessions.get(convID, "slow"); got != "session-1" {
+		t.Fatalf("recorded session %q, want session-1", got)
+	}
+}
+
+func TestAgentSessionsEndWithAgentAndConversation(t *testing.T) {
+	var sessions atomic.Int32
+	s, hs := newTestServer(t, "slow", sessionCountingAgent(&sessions))
+	convID := newConversation(t, hs)
+	chat(t, hs, convID)
+	if s.agentSessions.get(convID, "slow") == "" {
+		t.Fatal("turn recorded no agent session")
+	}
+
+	// The agent exiting ends its sessions; the next turn starts a new one
+	proc, err := s.agents.Get("slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.Stop()
+	chat(t, hs, convID)
+	if got := s.agentSessions.get(convID, "slow"); got != "session-2" || sessions.Load() != 2 {
+		t.Fatalf("after the agent exited: session %q, %d created; want session-2, 2", got, sessions.Load())
+	}
+
+	// Deleting the conversation drops its sessions
+	req, _ := http.NewRequest("DELETE", hs.URL+"/api/sessions/"+convID, nil)
+	resp, err := hs.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := s.agentSessions.get(convID, "slow"); got != "" {
+		t.Fatalf("deleted conversation kept session %q", got)
+	}
+}
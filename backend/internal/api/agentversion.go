@@ -0,0 +1,351 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/events"
+	"github.com/daodao97/acpone/internal/installer"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+const (
+	// versionCheckInterval is how often newer agent versions are looked for
+	versionCheckInterval = 24 * time.Hour
+	// versionLookupTimeout bounds one version command or registry lookup
+	versionLookupTimeout = 30 * time.Second
+)
+
+// packageVersion is what is known about the version of an agent package,
+// CLI or installer-managed agent
+type packageVersion struct {
+	Installed       string `json:"installed,omitempty"`
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable bool   `json:"updateAvailable,omitempty"`
+	Checked         int64  `json:"checked,omitempty"` // When it was last looked up, unix ms
+}
+
+// versionSource looks up the versions of one package
+type versionSource struct {
+	installed func(ctx context.Context) string
+	latest    func(ctx context.Context) string // nil when it can't be looked up
+}
+
+// versionStore keeps package versions, keyed like setup items: the npm
+// package of an npx agent, the command of a CLI, or the installer
+// description of an agent with an "install" config. It is saved in
+// ~/.acpone/versions.json so the daily check survives restarts.
+type versionStore struct {
+	mu    sync.Mutex
+	items map[string]packageVersion
+}
+
+func versionsPath() string {
+	return filepath.Join(sysutil.DataDir(), "versions.json")
+}
+
+func (v *versionStore) load() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.items = make(map[string]packageVersion)
+	if data, err := os.ReadFile(versionsPath()); err == nil {
+		if err := json.Unmarshal(data, &v.items); err != nil {
+			versionLog.Warn("invalid versions.json", "error", err)
+		}
+	}
+}
+
+func (v *versionStore) get(key string) (packageVersion, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	pv, ok := v.items[key]
+	return pv, ok
+}
+
+func (v *versionStore) set(key string, pv packageVersion) {
+	pv.UpdateAvailable = pv.Installed != "" && pv.Latest != "" && compareVersions(pv.Latest, pv.Installed) > 0
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.items == nil {
+		v.items = make(map[string]packageVersion)
+	}
+	v.items[key] = pv
+}
+
+func (v *versionStore) save() {
+	v.mu.Lock()
+	data, _ := json.MarshalIndent(v.items, "", "  ")
+	v.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(versionsPath()), 0755); err != nil {
+		return
+	}
+	if err := os.WriteFile(versionsPath(), data, 0644); err != nil {
+		versionLog.Error("failed to save versions", "error", err)
+	}
+}
+
+// versionKey returns the key an agent's own package is recorded under
+func (s *Server) versionKey(a config.AgentConfig) string {
+	if a.Install != nil {
+		if inst, err := installer.New(a.Install, a.Command); err == nil {
+			return inst.String()
+		}
+		return ""
+	}
+	if a.Command == "npx" {
+		return extractPackageName(a.Command, a.Args)
+	}
+	if agent.IsBuiltin(a.Command) {
+		return ""
+	}
+	return a.Command
+}
+
+// versionSources lists the packages of the enabled agents and the CLIs
+// they drive
+func (s *Server) versionSources() map[string]versionSource {
+	sources := make(map[string]versionSource)
+	addTool := func(command string) {
+		tool := s.catalog.Tool(command)
+		if tool == nil {
+			// Unknown commands aren't run with --version
+			return
+		}
+		src := versionSource{installed: func(ctx context.Context) string { return commandVersion(ctx, command) }}
+		if tool.Package != "" {
+			src.installed = func(ctx context.Context) string { return npmGlobalVersion(ctx, tool.Package) }
+			src.latest = func(ctx context.Context) string { return npmLatestVersion(ctx, tool.Package) }
+		}
+		sources[command] = src
+	}
+
+	for _, a := range s.agentList() {
+		if !a.IsEnabled() {
+			continue
+		}
+		key := s.versionKey(a)
+		switch {
+		case key == "":
+		case a.Install != nil:
+			if inst := s.agentInstaller(a.ID); inst != nil {
+				sources[key] = versionSource{installed: inst.Version, latest: inst.Latest}
+			}
+		case a.Command == "npx":
+			sources[key] = versionSource{
+				installed: func(ctx context.Context) string { return npmPackageVersion(ctx, key) },
+				latest:    func(ctx context.Context) string { return npmLatestVersion(ctx, key) },
+			}
+			if tool := s.catalog.RequiredTool(key); tool != nil {
+				addTool(tool.Command)
+			}
+		default:
+			addTool(a.Command)
+		}
+	}
+	return sources
+}
+
+// setupVersionCheck records the installed agent versions at startup and
+// looks for newer ones once a day
+func (s *Server) setupVersionCheck() {
+	s.versions.load()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		s.refreshVersions(true)
+		for range ticker.C {
+			s.refreshVersions(false)
+		}
+	}()
+}
+
+// refreshVersions looks up the versions of packages not checked within
+// versionCheckInterval, and with installed set re-reads every installed
+// version, e.g. after installs. Newest versions aren't looked up offline.
+func (s *Server) refreshVersions(installed bool) {
+	var updates []string
+	for key, src := range s.versionSources() {
+		pv, _ := s.versions.get(key)
+		stale := time.Since(time.UnixMilli(pv.Checked)) >= versionCheckInterval
+		if !stale && !installed {
+			continue
+		}
+		had := pv.UpdateAvailable
+
+		ctx, cancel := context.WithTimeout(context.Background(), versionLookupTimeout)
+		pv.Installed = src.installed(ctx)
+		cancel()
+		if stale {
+			if src.latest != nil && !s.config.Offline {
+				ctx, cancel := context.WithTimeout(context.Background(), versionLookupTimeout)
+				if latest := src.latest(ctx); latest != "" {
+					pv.Latest = latest
+				}
+				cancel()
+			}
+			pv.Checked = time.Now().UnixMilli()
+		}
+		s.versions.set(key, pv)
+
+		if pv, _ := s.versions.get(key); pv.UpdateAvailable && !had {
+			versionLog.Info("update available", "package", key, "installed", pv.Installed, "latest", pv.Latest)
+			updates = append(updates, key)
+		}
+	}
+	s.versions.save()
+
+	s.applyVersions()
+	s.broadcastSetupStatus()
+	if len(updates) > 0 {
+		s.events.Publish(events.Event{Topic: events.Setup, Type: "updates", Data: map[string]any{"packages": updates}})
+	}
+}
+
+// applyVersions copies the recorded versions onto the setup items
+func (s *Server) applyVersions() {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+	if s.setupStatus == nil {
+		return
+	}
+	apply := func(item *DependencyItem, key string) {
+		pv, ok := s.versions.get(key)
+		if !ok {
+			return
+		}
+		item.Version = pv.Installed
+		item.Latest = ""
+		if pv.UpdateAvailable {
+			item.Latest = pv.Latest
+		}
+	}
+	for i := range s.setupStatus.Agents {
+		item := &s.setupStatus.Agents[i]
+		if item.Agent != "" {
+			apply(item, item.Package)
+		} else {
+			apply(item, item.Command)
+		}
+	}
+	for i := range s.setupStatus.ACPPackages {
+		apply(&s.setupStatus.ACPPackages[i], s.setupStatus.ACPPackages[i].Package)
+	}
+}
+
+// npmPackageVersion returns the version of a package installed globally or
+// in the npx cache, the newest one when several are cached
+func npmPackageVersion(ctx context.Context, pkg string) string {
+	if v := npmGlobalVersion(ctx, pkg); v != "" {
+		return v
+	}
+	newest := ""
+	for _, dir := range npxCacheDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(dir, e.Name(), "node_modules", filepath.FromSlash(pkg), "package.json"))
+			if err != nil {
+				continue
+			}
+			var manifest struct {
+				Version string `json:"version"`
+			}
+			if json.Unmarshal(data, &manifest) == nil && compareVersions(manifest.Version, newest) > 0 {
+				newest = manifest.Version
+			}
+		}
+	}
+	return newest
+}
+
+// npmGlobalVersion returns the version of a globally installed package
+func npmGlobalVersion(ctx context.Context, pkg string) string {
+	if !commandExists("npm") {
+		return ""
+	}
+	out, _ := installCommand(ctx, "npm", "list", "-g", "--depth=0", "--json", pkg).Output()
+	var list struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if json.Unmarshal(out, &list) != nil {
+		return ""
+	}
+	return list.Dependencies[pkg].Version
+}
+
+// npmLatestVersion returns the version the registry tags latest
+func npmLatestVersion(ctx context.Context, pkg string) string {
+	if !commandExists("npm") {
+		return ""
+	}
+	out, err := installCommand(ctx, "npm", "view", pkg, "version").Output()
+	if err != nil {
+		return ""
+	}
+	return installer.ParseVersion(string(out))
+}
+
+// commandVersion runs a CLI with --version
+func commandVersion(ctx context.Context, command string) string {
+	if !commandExists(command) {
+		return ""
+	}
+	out, _ := installCommand(ctx, command, "--version").Output()
+	return installer.ParseVersion(string(out))
+}
+
+// compareVersions compares dotted version numbers, returning 1 when a is
+// newer than b. A prerelease is older than its release.
+func compareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	if b == "" {
+		if a == "" {
+			return 0
+		}
+		return 1
+	}
+	if a == "" {
+		return -1
+	}
+	coreA, preA, _ := strings.Cut(strings.SplitN(a, "+", 2)[0], "-")
+	coreB, preB, _ := strings.Cut(strings.SplitN(b, "+", 2)[0], "-")
+	partsA, partsB := strings.Split(coreA, "."), strings.Split(coreB, ".")
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			y, _ = strconv.Atoi(partsB[i])
+		}
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case preA > preB:
+		return 1
+	}
+	return -1
+}
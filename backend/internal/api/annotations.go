@@ -0,0 +1,154 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conversation"
+)
+
+// annotationExcerpt bounds the message text listed with an annotation
+const annotationExcerpt = 200
+
+// handleSessionAnnotations manages the annotations of a conversation's
+// messages: GET lists them, PUT sets {message, rating?, note?, todo?} and
+// DELETE ?message= clears one
+func (s *Server) handleSessionAnnotations(w http.ResponseWriter, r *http.Request, id string) {
+	if !s.conversations.Has(id) {
+		session, err := s.sessionStore.Load(id)
+		if err != nil {
+			writeError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		s.restoreConversation(session)
+	}
+
+	var a conversation.Annotation
+	switch r.Method {
+	case "GET":
+		writeJSON(w, map[string]any{"annotations": s.annotationsOf(id)})
+		return
+
+	case "PUT":
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			writeError(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		switch a.Rating {
+		case "", conversation.RatingUp, conversation.RatingDown:
+		default:
+			writeError(w, "rating must be up, down or empty", http.StatusBadRequest)
+			return
+		}
+		a.UpdatedAt = time.Now().UnixMilli()
+
+	case "DELETE":
+		n, err := strconv.Atoi(r.URL.Query().Get("message"))
+		if err != nil {
+			writeError(w, "message index required", http.StatusBadRequest)
+			return
+		}
+		a.Message = n
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.conversations.Annotate(id, a) {
+		writeError(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	s.persistConversation(id)
+	writeJSON(w, map[string]any{"annotations": s.annotationsOf(id)})
+}
+
+// annotationsOf returns the conversation's annotations, never nil so they
+// encode as []
+func (s *Server) annotationsOf(convID string) []conversation.Annotation {
+	annotations := s.conversations.Annotations(convID)
+	if annotations == nil {
+		annotations = []conversation.Annotation{}
+	}
+	return annotations
+}
+
+// annotatedMessage is an annotation listed with its message
+type annotatedMessage struct {
+	SessionID   string                  `json:"sessionId"`
+	Title       string                  `json:"title"`
+	WorkspaceID string                  `json:"workspaceId,omitempty"`
+	Role        string                  `json:"role"`
+	Agent       string                  `json:"agent,omitempty"`
+	Excerpt     string                  `json:"excerpt"`
+	Annotation  conversation.Annotation `json:"annotation"`
+}
+
+// handleAnnotations serves GET /api/annotations, the annotated messages of
+// all sessions, newest first. ?rating=up|down, ?todo=1, ?note=1, ?agent= and
+// ?workspaceId= filter them.
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	keep := func(a conversation.Annotation, msg conversation.Message) bool {
+		switch {
+		case q.Get("rating") != "" && a.Rating != q.Get("rating"):
+		case q.Get("todo") != "" && !a.Todo:
+		case q.Get("note") != "" && a.Note == "":
+		case q.Get("agent") != "" && msg.Agent != q.Get("agent"):
+		default:
+			return true
+		}
+		return false
+	}
+
+	results := []annotatedMessage{}
+	for _, meta := range s.sessionStore.List() {
+		if meta.Annotations == 0 {
+			continue
+		}
+		if ws := q.Get("workspaceId"); ws != "" && meta.WorkspaceID != ws {
+			continue
+		}
+		session, err := s.sessionStore.Load(meta.ID)
+		if err != nil {
+			continue
+		}
+		for _, a := range session.Annotations {
+			if a.Message < 0 || a.Message >= len(session.Messages) {
+				continue
+			}
+			msg := session.Messages[a.Message]
+			if !keep(a, msg) {
+				continue
+			}
+			excerpt := msg.Content
+			if msg.ToolCall != nil {
+				excerpt = msg.ToolCall.Title
+			}
+			if r := []rune(excerpt); len(r) > annotationExcerpt {
+				excerpt = string(r[:annotationExcerpt]) + "..."
+			}
+			results = append(results, annotatedMessage{
+				SessionID:   session.ID,
+				Title:       session.Title,
+				WorkspaceID: session.WorkspaceID,
+				Role:        msg.Role,
+				Agent:       msg.Agent,
+				Excerpt:     excerpt,
+				Annotation:  a,
+			})
+		}
+	}
+	slices.SortFunc(results, func(a, b annotatedMessage) int {
+		return cmp.Compare(b.Annotation.UpdatedAt, a.Annotation.UpdatedAt)
+	})
+	writeJSON(w, map[string]any{"annotations": results})
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// authCookie carries the auth token of browsers, which can't add headers to
+// EventSource streams
+const authCookie = "acpone_token"
+
+// authPrefix holds the login endpoints, served without a token
+const authPrefix = "/api/auth/"
+
+// validSecret reports whether t is one of the accepted secrets
+func validSecret(secrets []string, t string) bool {
+	if t == "" {
+		return false
+	}
+	ok := false
+	for _, secret := range secrets {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(secret)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// authorized reports whether a request carries an accepted secret as a
+// bearer token, in the X-API-Key header or in the acpone_token cookie
+func authorized(secrets []string, r *http.Request) bool {
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && validSecret(secrets, t) {
+		return true
+	}
+	if validSecret(secrets, r.Header.Get("X-API-Key")) {
+		return true
+	}
+	c, err := r.Cookie(authCookie)
+	return err == nil && validSecret(secrets, c.Value)
+}
+
+// requestSecret returns the secret a request presents, wherever it is sent,
+// telling API clients apart; empty when it sends none
+func requestSecret(r *http.Request) string {
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && t != "" {
+		return t
+	}
+	if t := r.Header.Get("X-API-Key"); t != "" {
+		return t
+	}
+	if c, err := r.Cookie(authCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	return r.URL.Query().Get("token")
+}
+
+// setAuthCookie signs a browser in with a secret, or out when it is empty
+func setAuthCookie(w http.ResponseWriter, secret string) {
+	cookie := &http.Cookie{
+		Name:     authCookie,
+		Value:    secret,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if secret == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// authMiddleware requires one of the configured secrets (auth token or API
+// keys) on /api/ requests, /metrics and the OpenAI compatible /v1/, sent
+// as a bearer token, in the X-API-Key header,
+// in the acpone_token cookie or as ?token=. A token in the query also sets
+// the cookie, so opening /?token=... once signs a browser in. Pages are
+// served regardless so the web UI can show its login form, which signs in
+// through /api/auth/login.
+func authMiddleware(secrets []string, next http.Handler) http.Handler {
+	if len(secrets) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t := r.URL.Query().Get("token"); validSecret(secrets, t) {
+			setAuthCookie(w, t)
+			// Page loads drop the token from the address bar
+			if r.Method == "GET" && !strings.HasPrefix(r.URL.Path, "/api/") {
+				q := r.URL.Query()
+				q.Del("token")
+				u := *r.URL
+				u.RawQuery = q.Encode()
+				http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		protected := strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/v1/") || r.URL.Path == metricsPath
+		if authorized(secrets, r) || !protected || strings.HasPrefix(r.URL.Path, authPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeError(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// handleAuthStatus serves GET /api/auth/status: whether the server requires
+// a token and whether this client has signed in
+func (s *Server) handleAuthStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	secrets := s.config.Server.Secrets()
+	writeJSON(w, map[string]any{
+		"required":      len(secrets) > 0,
+		"authenticated": len(secrets) == 0 || authorized(secrets, r),
+	})
+}
+
+// handleAuthLogin serves POST /api/auth/login {token}, signing the browser
+// in with a cookie when the token is accepted
+func (s *Server) handleAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	secrets := s.config.Server.Secrets()
+	if len(secrets) > 0 && !validSecret(secrets, req.Token) {
+		writeError(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if len(secrets) > 0 {
+		setAuthCookie(w, req.Token)
+	}
+	writeJSON(w, map[string]any{"authenticated": true})
+}
+
+// handleAuthLogout serves POST /api/auth/logout, clearing the browser's cookie
+func (s *Server) handleAuthLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	setAuthCookie(w, "")
+	writeJSON(w, map[string]any{"authenticated": false})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/daodao97/acpone/internal/backup"
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// maxRestoreSize limits uploaded backup archives
+const maxRestoreSize = 1 << 30
+
+// handleBackup downloads a backup archive of config and data
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := backup.Create(&buf, s.dataBackend, config.LoadedConfigPath); err != nil {
+		writeError(w, "Backup failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := fmt.Sprintf("acpone-backup-%s.zip", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Write(buf.Bytes())
+}
+
+// handleRestore restores a backup archive sent as the request body.
+// Config changes take effect after a restart.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRestoreSize))
+	if err != nil {
+		writeError(w, "Failed to read archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := backup.RestoreBytes(data, s.dataBackend, config.LoadedConfigPath)
+	if err != nil {
+		writeError(w, "Restore failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.workspaceStore.Reload()
+
+	writeJSON(w, map[string]any{
+		"success":         true,
+		"manifest":        manifest,
+		"restartRequired": true,
+	})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// defaultBranchPrefix starts conversation branch names
+const defaultBranchPrefix = "acpone/"
+
+// currentBranch returns the checked out branch of a git workspace, or ""
+// when it is not a repository or HEAD is detached
+func currentBranch(root string) string {
+	out, err := git(root, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// checkoutConversationBranch switches the workspace back to the
+// conversation's branch before a turn, e.g. after another conversation ran
+func (s *Server) checkoutConversationBranch(convID, root string, project *config.ProjectConfig, sendEvent func(string, any)) {
+	branch := s.conversations.Branch(convID)
+	if project == nil || project.Branch == nil || branch == "" {
+		return
+	}
+	if current := currentBranch(root); current == "" || current == branch {
+		return
+	}
+	if _, err := git(root, "checkout", branch); err != nil {
+		sendEvent("warning", map[string]any{
+			"message": "Could not check out branch " + branch + ": " + gitError(err).Error(),
+			"branch":  branch,
+		})
+	}
+}
+
+// branchConversation creates and checks out the conversation's branch once
+// a turn changed files. Uncommitted changes move along to the new branch.
+func (s *Server) branchConversation(convID, root string, project *config.ProjectConfig, changed []string, sendEvent func(string, any)) string {
+	if branch := s.conversations.Branch(convID); branch != "" || project == nil || project.Branch == nil {
+		return branch
+	}
+	if len(changed) == 0 || currentBranch(root) == "" {
+		return ""
+	}
+
+	prefix := project.Branch.Prefix
+	if prefix == "" {
+		prefix = defaultBranchPrefix
+	}
+	conv := s.conversations.Get(convID)
+	name := prefix + branchSlug(storage.GenerateTitle(conv.Messages), convID)
+	if _, err := git(root, "checkout", "-b", name); err != nil {
+		sendEvent("warning", map[string]any{
+			"message": "Could not create branch " + name + ": " + gitError(err).Error(),
+			"branch":  name,
+		})
+		return ""
+	}
+
+	s.conversations.SetBranch(convID, name)
+	s.persistConversation(convID)
+	sendEvent("status", map[string]string{"message": "Switched to branch " + name})
+	return name
+}
+
+// branchSlug names a conversation branch after its title, suffixed with the
+// start of its ID to stay unique
+func branchSlug(title, convID string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	slug := strings.Trim(b.String(), "-")
+	id := convID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	if slug == "" {
+		return id
+	}
+	return slug + "-" + id
+}
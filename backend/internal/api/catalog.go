@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/daodao97/acpone/internal/catalog"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/events"
+)
+
+// loadCatalog loads the agent catalog, keeping the built-in entries when
+// the user's overrides are broken
+func (s *Server) loadCatalog() {
+	c, err := catalog.Load()
+	if err != nil {
+		catalogLog.Warn("catalog unavailable", "error", err)
+	}
+	if c == nil {
+		c = &catalog.Catalog{}
+	}
+	s.catalog = c
+}
+
+// toolPackage returns the npm package that installs the CLI command, or ""
+func (s *Server) toolPackage(command string) string {
+	if tool := s.catalog.Tool(command); tool != nil {
+		return tool.Package
+	}
+	return ""
+}
+
+// toolInstall returns install instructions for command, or ""
+func (s *Server) toolInstall(command string) string {
+	if tool := s.catalog.Tool(command); tool != nil {
+		return tool.Install
+	}
+	return installInstructions[command]
+}
+
+// catalogAdded reports whether the config already has an agent like a
+func (s *Server) catalogAdded(a *catalog.Agent) bool {
+	for _, c := range s.agentList() {
+		if c.ID == a.ID || c.Command == a.Command && slices.Equal(c.Args, a.Args) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleCatalog lists catalog agents, marking those already configured,
+// and the CLIs they need
+func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agents := make([]map[string]any, 0, len(s.catalog.Agents))
+	for i := range s.catalog.Agents {
+		a := &s.catalog.Agents[i]
+		agents = append(agents, map[string]any{
+			"id":          a.ID,
+			"name":        a.Name,
+			"description": a.Description,
+			"command":     a.Command,
+			"args":        a.Args,
+			"requires":    a.Requires,
+			"envHints":    a.EnvHints,
+			"added":       s.catalogAdded(a),
+		})
+	}
+
+	writeJSON(w, map[string]any{
+		"agents":   agents,
+		"tools":    s.catalog.Tools,
+		"userPath": catalog.UserPath(),
+	})
+}
+
+// handleCatalogAdd adds a catalog agent to the config. The id may be
+// overridden to add the same agent twice, and env vars set right away.
+func (s *Server) handleCatalogAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID      string            `json:"id"`
+		AgentID string            `json:"agentId,omitempty"`
+		Env     map[string]string `json:"env,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	entry := s.catalog.Agent(req.ID)
+	if entry == nil {
+		writeError(w, "Unknown catalog agent: "+req.ID, http.StatusNotFound)
+		return
+	}
+	agentID := req.AgentID
+	if agentID == "" {
+		agentID = entry.ID
+	}
+	added := config.AgentConfig{
+		ID:      agentID,
+		Name:    entry.Name,
+		Command: entry.Command,
+		Args:    slices.Clone(entry.Args),
+		Env:     req.Env,
+	}
+	err := s.changeAgents(func(agents []config.AgentConfig) ([]config.AgentConfig, error) {
+		if slices.ContainsFunc(agents, func(a config.AgentConfig) bool { return a.ID == agentID }) {
+			return nil, errAgentExists
+		}
+		return append(agents, added), nil
+	})
+	switch {
+	case errors.Is(err, errAgentExists):
+		writeError(w, "Agent already exists: "+agentID, http.StatusConflict)
+		return
+	case errors.Is(err, errConfigSave):
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	case err != nil:
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	catalogLog.Info("added agent", "agent", agentID, "entry", entry.ID)
+
+	agent := s.findAgent(agentID)
+	s.events.Publish(events.Event{Topic: events.Config, Type: "agent_added", Data: agent})
+
+	// The new agent may need its CLI or package installed
+	s.initSetupStatus()
+	go s.checkDependenciesAsync()
+
+	writeJSON(w, map[string]any{"success": true, "agent": agent})
+}
@@ -3,14 +3,14 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"path/filepath"
 	"strings"
 
-	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
 	"github.com/daodao97/acpone/internal/conversation"
-	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/recentfiles"
+	"github.com/daodao97/acpone/internal/router"
 )
 
 type chatFileInfo struct {
@@ -23,7 +23,17 @@ type chatRequest struct {
 	Message        string         `json:"message"`
 	ConversationID string         `json:"conversationId"`
 	WorkspaceID    string         `json:"workspaceId"`
-	Files          []chatFileInfo `json:"files"` // Uploaded files with info
+	Files          []chatFileInfo `json:"files"`    // Uploaded files with info
+	Pipeline       string         `json:"pipeline"` // Pipeline ID, also detected from "@<id>"
+	Team           string         `json:"team"`     // Team ID, also detected from "@<id>"
+	Agent          string         `json:"-"`        // Answers the turn, bypassing routing
+	TurnID         string         `json:"turnId"`   // Chosen by the client to poll the turn's events, generated if empty
+	// AgentParams override the conversation's agent parameters for this
+	// turn, a null value removing one
+	AgentParams map[string]any `json:"agentParams"`
+	// Instructions of an API client, sent before the first prompt of a new
+	// agent session without becoming part of the conversation
+	Instructions string `json:"-"`
 }
 
 type streamItem struct {
@@ -44,96 +54,111 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
+	if s.AgentsPaused() {
+		writeError(w, errAgentsPaused.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// Events are also buffered, so a client whose proxy holds back the
+	// stream can switch to /api/chat/poll
+	if req.TurnID == "" {
+		req.TurnID = generateUUID()
+	}
+	buf, ok := s.polls.start(req.TurnID)
+	if !ok {
+		writeError(w, "turnId already in use", http.StatusConflict)
+		return
+	}
+	defer s.polls.finish(req.TurnID)
+
+	// Events stream as SSE, or as NDJSON for clients accepting it
+	enc := negotiateStream(r)
+	w.Header().Set("Content-Type", enc.contentType)
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
-	sendEvent := func(event string, data any) {
-		jsonData, _ := json.Marshal(data)
-		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, jsonData)
-		flusher.Flush()
+	// This response subscribes to the events the request publishes,
+	// with optional text chunk coalescing (?coalesceMs=&coalesceBytes=)
+	write := streamWriter(w, flusher, enc)
+	deliver := func(event string, data any) {
+		buf.add(event, data)
+		write(event, data)
 	}
+	deliver("turn", map[string]string{"turnId": req.TurnID})
+	coalescer := newChunkCoalescer(r, deliver)
+	if coalescer != nil {
+		deliver = coalescer.Deliver
+	}
+	stream, closeStream := s.newEventStream(chatTopic, deliver)
+	defer closeStream()
+	if coalescer != nil {
+		defer coalescer.Flush()
+	}
+	s.runChat(req, stream)
+}
+
+// runChat handles one user message: routes it, runs the turn with its
+// pipeline, team, hooks and review, and publishes the events on stream
+func (s *Server) runChat(req chatRequest, stream *eventStream) {
+	sendEvent := stream.Send
 
 	// Get or create conversation
 	convID, isNew := s.getOrCreateConversation(req)
+	stream.convID = convID
 	conv := s.conversations.Get(convID)
 
-	// Determine agent
-	mentionedAgent := s.router.DetectMention(req.Message)
-	previousAgent := conv.ActiveAgent
-	agentID := previousAgent
-
-	if mentionedAgent != "" {
-		agentID = mentionedAgent
-		if agentID != previousAgent {
-			s.conversations.SetActiveAgent(convID, agentID)
-			log.Printf("Agent switched via @mention: %s -> %s", previousAgent, agentID)
-		}
-	}
-
-	agentChanged := previousAgent != agentID && len(conv.Messages) > 0
-
-	// Initialize agent if needed
-	if !s.initialized[agentID] {
-		sendEvent("status", map[string]string{"message": fmt.Sprintf("Initializing %s...", agentID)})
-		if err := s.initializeAgent(agentID); err != nil {
-			sendEvent("error", map[string]string{"message": err.Error()})
-			return
-		}
-		s.initialized[agentID] = true
+	// A conversation runs in its own workspace, which /workspace switches,
+	// whichever one the client last knew
+	if conv.WorkspaceID != "" {
+		req.WorkspaceID = conv.WorkspaceID
 	}
-
-	// Get or create agent session
-	// Get agent process and set up handlers early (before session/new)
-	// This ensures we capture available_commands_update sent after session/new
-	agentProc, err := s.agents.Get(agentID)
-	if err != nil {
-		sendEvent("error", map[string]string{"message": "Failed to get agent: " + err.Error()})
+	if ref, ok := workspaceCommand(req.Message); ok {
+		s.runWorkspaceCommand(convID, isNew, req.Message, ref, sendEvent)
 		return
 	}
-	agentProc.SetWorkingDir(s.resolveWorkspacePath(req.WorkspaceID))
 
-	streamItems := make([]streamItem, 0)
-	currentText := ""
-	toolCallMap := make(map[string]int)
-
-	// Register handlers and get cleanup functions
-	cleanupNotification := agentProc.OnNotification(func(msg *jsonrpc.Message) {
-		s.handleNotification(msg, sendEvent, &streamItems, &currentText, toolCallMap, agentID)
-	})
-	defer cleanupNotification()
-
-	cleanupPermission := agentProc.OnPermission(func(req *agent.PermissionRequest) {
-		sendEvent("permission_request", req)
-	})
-	defer cleanupPermission()
-
-	sessionsMap := s.agentSessions[convID]
-	if sessionsMap == nil {
-		sessionsMap = make(map[string]string)
-		s.agentSessions[convID] = sessionsMap
+	// Determine agent. Pipelines and teams start with their first agent and
+	// leave the conversation's active agent unchanged.
+	pipeline := s.findPipeline(req)
+	var team *config.TeamConfig
+	if pipeline == nil {
+		team = s.findTeam(req)
 	}
-
-	sessionID := sessionsMap[agentID]
-	if sessionID == "" {
-		cwd := s.resolveWorkspacePath(req.WorkspaceID)
-		var err error
-		sessionID, err = s.createAgentSession(agentID, cwd)
-		if err != nil {
-			sendEvent("error", map[string]string{"message": err.Error()})
-			return
+	previousAgent := conv.ActiveAgent
+	routing := s.routeChat(conv, req.Message, pipeline, team)
+	if req.Agent != "" && pipeline == nil && team == nil {
+		routing = router.Decision{Agent: req.Agent, Strategy: "request", Reason: "chosen by the client"}
+	}
+	agentID := routing.Agent
+	askSwitch := false
+	if pipeline == nil && team == nil && agentID != previousAgent {
+		// Only a sticky mention mode makes a routed agent the active one
+		switch mode := s.mentionMode(conv); {
+		case routing.Strategy == "fallback" || mode == config.MentionSticky:
+			s.conversations.SetActiveAgent(convID, agentID)
+			logger.Info("agent switched", "strategy", routing.Strategy, "from", previousAgent, "to", agentID)
+		case mode == config.MentionAsk:
+			askSwitch = true
 		}
-		sessionsMap[agentID] = sessionID
 	}
 
-	s.conversations.SetSessionID(convID, sessionID)
+	defer s.turns.begin(convID, agentID)()
+
+	// The agent lacks the turns another agent answered since it last did,
+	// those of the previous workspace after a switch and the messages a
+	// conversation was seeded with
+	agentChanged := lastAgent(conv) != agentID && len(conv.Messages) > 0
+	carryContext := s.agentSessions.takeCarry(convID)
+
+	// Per-project settings from <workspace>/.acpone.json
+	project := s.projectConfig(req.WorkspaceID)
+	workspaceRoot := s.resolveWorkspacePath(req.WorkspaceID)
 
 	// Build prompt with context if agent changed
 	promptText := req.Message
@@ -143,14 +168,6 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		promptText = formatFileReferences(req.Files) + " " + promptText
 	}
 
-	if agentChanged {
-		context := s.conversations.GetContextSummary(convID, 10)
-		if context != "" {
-			promptText = context + "User: " + promptText
-			sendEvent("status", map[string]string{"message": fmt.Sprintf("Switching to %s with context...", agentID)})
-		}
-	}
-
 	// Convert file info for persistence
 	var messageFiles []conversation.MessageFile
 	for _, f := range req.Files {
@@ -160,52 +177,127 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 			Size: f.Size,
 		})
 	}
-	s.conversations.AddUserMessage(convID, req.Message, messageFiles)
 
-	sendEvent("session", map[string]any{
-		"conversationId": convID,
-		"sessionId":      sessionID,
-		"agent":          agentID,
-		"isNew":          isNew,
-	})
-	sendEvent("status", map[string]string{"message": "Processing..."})
+	t := &turn{
+		convID:      convID,
+		agentID:     agentID,
+		workspaceID: req.WorkspaceID,
+		project:     project,
+		params:      mergeAgentParams(s.conversations.AgentParams(convID), req.AgentParams),
+		sendEvent:   sendEvent,
+		prompt: func() []map[string]any {
+			text := promptText
+			if agentChanged || carryContext {
+				context := s.conversations.GetContextSummary(convID, 10)
+				if context != "" {
+					text = context + "User: " + text
+					message := fmt.Sprintf("Switching to %s with context...", agentID)
+					if !agentChanged {
+						message = "Continuing with context..."
+					}
+					sendEvent("status", map[string]string{"message": message})
+				}
+			}
+			pins := s.conversations.Pins(convID)
+			files := append(pinnedFiles(pins, workspaceRoot), req.Files...)
+			blocks := promptBlocks(text, req.Message, files, workspaceRoot, s.promptCapabilities(agentID))
+			return withPins(blocks, pins)
+		},
+		instructions: req.Instructions,
+		ready: func(sessionID string) {
+			s.conversations.AddUserMessage(convID, s.maskSecrets(req.Message), messageFiles)
+			for _, p := range mentionedFiles(req.Message, workspaceRoot) {
+				s.recentFiles.Record(workspaceRoot, p, recentfiles.Mentioned)
+			}
+
+			usage := s.contextUsage(convID, agentID)
+			sendEvent("session", map[string]any{
+				"conversationId": convID,
+				"sessionId":      sessionID,
+				"agent":          agentID,
+				"isNew":          isNew,
+				"context":        usage,
+			})
+			sendEvent("routing", routing)
+			if askSwitch {
+				sendEvent("agent_switch", map[string]string{"agent": agentID, "activeAgent": previousAgent})
+			}
+			s.conversations.SetRouting(convID, &conversation.Routing{
+				Agent:    routing.Agent,
+				Strategy: routing.Strategy,
+				Rule:     routing.Rule,
+				Match:    routing.Match,
+				Reason:   routing.Reason,
+			})
+			warnContextUsage(usage, sendEvent)
+			if pipeline != nil {
+				s.beginStage(convID, pipeline, 0, sendEvent)
+			}
+			sendEvent("status", map[string]string{"message": "Processing..."})
+		},
+	}
 
-	// Call session/prompt
-	response, err := agentProc.Request("session/prompt", map[string]any{
-		"sessionId": sessionID,
-		"prompt":    []map[string]string{{"type": "text", "text": promptText}},
-	})
+	s.checkoutConversationBranch(convID, workspaceRoot, project, sendEvent)
 
+	// Snapshot git status so hooks, the review pass and /commit can tell
+	// which files changed
+	review := s.reviewConfig(project)
+	statusBefore := workspaceStatus(workspaceRoot)
+	changed := func(res *turnResult) bool {
+		return len(res.Edited) > 0 || workspaceStatus(workspaceRoot) != statusBefore
+	}
+
+	var res *turnResult
+	var err error
+	if team != nil {
+		res, err = s.runTeam(team, *t, req.Message)
+	} else {
+		res, err = s.runTurn(t)
+		if err == nil && pipeline != nil {
+			s.persistConversation(convID)
+			res, err = s.runPipelineStages(pipeline, *t, req.Message, res)
+		}
+	}
 	if err != nil {
 		sendEvent("error", map[string]string{"message": err.Error()})
 		return
 	}
 
-	// Finalize stream items
-	if currentText != "" {
-		streamItems = append(streamItems, streamItem{Type: "text", Text: currentText})
+	s.persistConversation(convID)
+
+	if project != nil && len(project.Hooks) > 0 && res.Result["stopReason"] != "cancelled" {
+		res, err = s.runPostTurnHooks(*t, res, changed(res))
+		if err != nil {
+			sendEvent("error", map[string]string{"message": err.Error()})
+			return
+		}
+		s.persistConversation(convID)
 	}
 
-	for _, item := range streamItems {
-		if item.Type == "text" {
-			s.conversations.AddAssistantMessage(convID, item.Text, agentID)
-		} else if item.Tool != nil {
-			s.conversations.AddToolCall(convID, item.Tool, agentID)
+	if review != nil && res.Result["stopReason"] != "cancelled" && changed(res) {
+		err := s.runReview(review, *t, req.Message, agentID, res.Edited)
+		if err != nil {
+			sendEvent("warning", map[string]string{"message": "Review failed: " + err.Error()})
 		}
+		s.persistConversation(convID)
+		res.Result["reviewed"] = err == nil
 	}
 
-	s.persistConversation(convID)
+	turnFiles := s.recordTurnChanges(convID, workspaceRoot, statusBefore, res.Edited)
+	if branch := s.branchConversation(convID, workspaceRoot, project, turnFiles, sendEvent); branch != "" {
+		res.Result["branch"] = branch
+	}
+
+	s.scheduleMemory(convID, project)
 
 	// Send done
-	var result map[string]any
-	response.ParseResult(&result)
-	if result == nil {
-		result = make(map[string]any)
+	if pipeline != nil {
+		res.Result["pipeline"] = pipeline.ID
 	}
-	if result["stopReason"] == nil {
-		result["stopReason"] = "end_turn"
+	if team != nil {
+		res.Result["team"] = team.ID
 	}
-	sendEvent("done", result)
+	sendEvent("done", res.Result)
 }
 
 func (s *Server) getOrCreateConversation(req chatRequest) (string, bool) {
@@ -225,28 +317,33 @@ func (s *Server) getOrCreateConversation(req chatRequest) (string, bool) {
 	convID := generateUUID()
 	workspaceID := req.WorkspaceID
 	if workspaceID == "" {
-		workspaceID = s.config.DefaultWorkspace
+		workspaceID = s.workspaceStore.Default()
 	}
-	s.conversations.Create(convID, s.config.DefaultAgent, workspaceID)
-	s.agentSessions[convID] = make(map[string]string)
+	s.conversations.Create(convID, s.defaultAgentFor(workspaceID), workspaceID)
+	s.agentSessions.forget(convID)
 	return convID, true
 }
 
 func (s *Server) initializeAgent(agentID string) error {
-	_, err := s.agents.Request(agentID, "initialize", map[string]any{
+	result, err := s.agents.Request(agentID, "initialize", map[string]any{
 		"protocolVersion": 1,
 		"clientCapabilities": map[string]any{
-			"fs": map[string]bool{"readTextFile": true, "writeTextFile": true},
+			"fs":       map[string]bool{"readTextFile": true, "writeTextFile": true},
+			"terminal": true,
 		},
 		"clientInfo": map[string]string{"name": "acpone-go", "version": "0.1.0"},
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	s.recordCapabilities(agentID, result)
+	return nil
 }
 
-func (s *Server) createAgentSession(agentID, cwd string) (string, error) {
+func (s *Server) createAgentSession(agentID, cwd string, project *config.ProjectConfig) (string, error) {
 	result, err := s.agents.Request(agentID, "session/new", map[string]any{
 		"cwd":        cwd,
-		"mcpServers": []any{},
+		"mcpServers": mcpServersFor(project),
 	})
 	if err != nil {
 		return "", err
@@ -263,8 +360,7 @@ func (s *Server) createAgentSession(agentID, cwd string) (string, error) {
 	}
 
 	// Set permission mode
-	agentConfig := s.config.FindAgent(agentID)
-	if agentConfig != nil && agentConfig.PermissionMode == "bypass" {
+	if s.permissionModeFor(agentID, project) == "bypass" {
 		modeID := "bypassPermissions"
 		if agentID == "codex" {
 			modeID = "auto"
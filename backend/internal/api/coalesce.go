@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// textChunk is an "update" event carrying agent text. It marshals as the
+// original session/update params; coalescing subscribers merge its text.
+type textChunk struct {
+	Kind   string // agent_message_chunk or agent_thought_chunk
+	Text   string
+	Params any
+}
+
+func (c textChunk) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Params)
+}
+
+// chunkCoalescer merges consecutive text chunks into fewer SSE events.
+// Buffered text is flushed after interval, once maxBytes is reached,
+// or before any other event is sent so ordering is preserved.
+type chunkCoalescer struct {
+	send     func(string, any)
+	interval time.Duration
+	maxBytes int
+
+	mu    sync.Mutex
+	kind  string // sessionUpdate of the buffered chunks
+	buf   strings.Builder
+	timer *time.Timer
+}
+
+// newChunkCoalescer reads coalescing options from the request query:
+// coalesceMs (flush interval) and coalesceBytes (flush size).
+// Returns nil when the client did not ask for coalescing.
+func newChunkCoalescer(r *http.Request, send func(string, any)) *chunkCoalescer {
+	q := r.URL.Query()
+	ms, _ := strconv.Atoi(q.Get("coalesceMs"))
+	maxBytes, _ := strconv.Atoi(q.Get("coalesceBytes"))
+	if ms <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	if ms <= 0 {
+		ms = 50
+	}
+	return &chunkCoalescer{
+		send:     send,
+		interval: time.Duration(ms) * time.Millisecond,
+		maxBytes: maxBytes,
+	}
+}
+
+// AddText buffers a text chunk of the given sessionUpdate kind
+func (c *chunkCoalescer) AddText(kind, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.kind != kind {
+		c.flushLocked()
+		c.kind = kind
+	}
+	c.buf.WriteString(text)
+
+	if c.maxBytes > 0 && c.buf.Len() >= c.maxBytes {
+		c.flushLocked()
+		return
+	}
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.interval, c.Flush)
+	}
+}
+
+// Deliver buffers text chunks and sends any other event
+func (c *chunkCoalescer) Deliver(event string, data any) {
+	if chunk, ok := data.(textChunk); ok {
+		c.AddText(chunk.Kind, chunk.Text)
+		return
+	}
+	c.Send(event, data)
+}
+
+// Send flushes buffered text and then sends the event
+func (c *chunkCoalescer) Send(event string, data any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+	c.send(event, data)
+}
+
+// Flush sends any buffered text
+func (c *chunkCoalescer) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+func (c *chunkCoalescer) flushLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.buf.Len() == 0 {
+		return
+	}
+	c.send("update", map[string]any{
+		"update": map[string]any{
+			"sessionUpdate": c.kind,
+			"content":       map[string]string{"type": "text", "text": c.buf.String()},
+		},
+	})
+	c.buf.Reset()
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// defaultCommitPrompt asks the agent for a commit message of its last turn
+const defaultCommitPrompt = `Write a git commit message for the changes below, made for the request that follows. Use a short imperative subject line of at most 72 characters, then a blank line and a brief body if needed. Reply with the commit message only, no code fences. Do not modify any files.
+
+## Request
+{{input}}
+
+## Changes
+` + "```diff\n{{diff}}\n```\n"
+
+// maxCommitBody caps the turn summary used as the default commit body
+const maxCommitBody = 2000
+
+// turnChanges holds the files each conversation's last turn changed,
+// relative to the workspace root, until they are committed
+type turnChanges struct {
+	mu    sync.Mutex
+	files map[string][]string
+}
+
+func (c *turnChanges) set(convID string, files []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.files == nil {
+		c.files = make(map[string][]string)
+	}
+	if len(files) == 0 {
+		delete(c.files, convID)
+		return
+	}
+	c.files[convID] = files
+}
+
+func (c *turnChanges) get(convID string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.files[convID])
+}
+
+// changedFiles lists workspace files a turn changed: entries of git status
+// that differ from before the turn, plus files the agent reported editing
+func changedFiles(root, statusBefore string, edited []string) []string {
+	before := strings.Split(statusBefore, "\n")
+	var files []string
+	add := func(path string) {
+		if path != "" && !slices.Contains(files, path) {
+			files = append(files, path)
+		}
+	}
+
+	for _, line := range strings.Split(workspaceStatus(root), "\n") {
+		if len(line) < 4 || slices.Contains(before, line) {
+			continue
+		}
+		path := line[3:]
+		if _, to, ok := strings.Cut(path, " -> "); ok {
+			path = to
+		}
+		add(strings.Trim(path, `"`))
+	}
+	for _, p := range edited {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		add(filepath.ToSlash(rel))
+	}
+	slices.Sort(files)
+	return files
+}
+
+// recordTurnChanges remembers what a turn changed for a later commit and
+// the agent log. The log itself, rewritten after turns, isn't a change.
+func (s *Server) recordTurnChanges(convID, root, statusBefore string, edited []string) []string {
+	files := slices.DeleteFunc(changedFiles(root, statusBefore, edited), func(f string) bool {
+		return f == agentLogFile
+	})
+	s.turnChanges.set(convID, files)
+	if len(files) > 0 {
+		s.conversations.SetTurnFiles(convID, files)
+		s.persistConversation(convID)
+	}
+	return files
+}
+
+// handleSessionCommit stages the files changed by the conversation's last
+// turn and commits them. The message is taken from the request, generated
+// by the agent (generate: true) or built from the turn.
+func (s *Server) handleSessionCommit(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Message  string `json:"message"`
+		Generate bool   `json:"generate"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	conv := s.conversations.Get(id)
+	if conv == nil {
+		writeError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	files := s.turnChanges.get(id)
+	if len(files) == 0 {
+		writeError(w, "No files changed in the last turn", http.StatusBadRequest)
+		return
+	}
+	root := s.resolveWorkspacePath(conv.WorkspaceID)
+	if _, err := git(root, "rev-parse", "--git-dir"); err != nil {
+		writeError(w, "Workspace is not a git repository", http.StatusBadRequest)
+		return
+	}
+
+	message := strings.TrimSpace(req.Message)
+	if message == "" && req.Generate {
+		generated, err := s.generateCommitMessage(id, conv.WorkspaceID, lastAgent(conv), root, files)
+		if err != nil {
+			writeError(w, "Generate commit message: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		message = generated
+	}
+	if message == "" {
+		message = commitMessage(s.conversations.LastUserMessage(id), s.conversations.LastReply(id))
+	}
+
+	args := append([]string{"add", "-A", "--"}, files...)
+	if _, err := git(root, args...); err != nil {
+		writeError(w, "git add: "+gitError(err).Error(), http.StatusInternalServerError)
+		return
+	}
+	args = append([]string{"commit", "-m", message, "--"}, files...)
+	if _, err := git(root, args...); err != nil {
+		writeError(w, "git commit: "+gitError(err).Error(), http.StatusInternalServerError)
+		return
+	}
+	hash, _ := git(root, "rev-parse", "HEAD")
+
+	s.turnChanges.set(id, nil)
+	writeJSON(w, map[string]any{
+		"commit":  strings.TrimSpace(hash),
+		"message": message,
+		"files":   files,
+	})
+}
+
+// generateCommitMessage asks the agent for a commit message in a turn of
+// its own, recorded in the conversation as a "commit" message
+func (s *Server) generateCommitMessage(convID, workspaceID, agentID, root string, files []string) (string, error) {
+	args := append([]string{"diff", "HEAD", "--no-color", "--"}, files...)
+	diff, _ := git(root, args...)
+	if diff == "" {
+		diff = "Changed files:\n" + strings.Join(files, "\n")
+	}
+	if len(diff) > maxDiffBytes {
+		diff = diff[:maxDiffBytes] + "\n... (diff truncated)\n"
+	}
+
+	stream, closeStream := s.newEventStream(chatTopic, func(string, any) {})
+	defer closeStream()
+	stream.convID = convID
+
+	t := &turn{
+		convID:      convID,
+		agentID:     agentID,
+		workspaceID: workspaceID,
+		project:     s.projectConfig(workspaceID),
+		sendEvent:   stream.Send,
+		kind:        "commit",
+		prompt: textPrompt(expandPrompt(defaultCommitPrompt, "", map[string]string{
+			"{{input}}": s.conversations.LastUserMessage(convID),
+			"{{diff}}":  diff,
+		})),
+	}
+	res, err := s.runTurn(t)
+	if err != nil {
+		return "", err
+	}
+	s.persistConversation(convID)
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(res.Text), "`")), nil
+}
+
+// commitMessage builds a message from the turn: the request's first line as
+// the subject and the agent's reply as the body
+func commitMessage(request, reply string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(request), "\n")
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		subject = "Apply agent changes"
+	}
+	if utf8.RuneCountInString(subject) > 72 {
+		subject = string([]rune(subject)[:69]) + "..."
+	}
+
+	body := strings.TrimSpace(reply)
+	if len(body) > maxCommitBody {
+		body = strings.ToValidUTF8(body[:maxCommitBody], "") + "\n..."
+	}
+	if body == "" {
+		return subject
+	}
+	return subject + "\n\n" + body
+}
+
+// gitError returns git's stderr in place of a bare exit status
+func gitError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
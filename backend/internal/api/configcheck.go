@@ -0,0 +1,46 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// maxConfigSize limits config files posted for validation
+const maxConfigSize = 1 << 20
+
+// handleConfigValidate serves /api/config/validate: GET checks the loaded
+// config file as it is on disk now, POST checks the config in the body
+// without saving it. Both return {valid, problems}, where problems carry
+// the line and column of syntax errors, type mismatches and unknown fields.
+func (s *Server) handleConfigValidate(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var err error
+	status := http.StatusBadRequest
+	switch r.Method {
+	case "GET":
+		if config.LoadedConfigPath == "" {
+			writeError(w, "No config file loaded", http.StatusNotFound)
+			return
+		}
+		data, err = os.ReadFile(config.LoadedConfigPath)
+		status = http.StatusInternalServerError
+	case "POST":
+		data, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeError(w, err.Error(), status)
+		return
+	}
+
+	problems := config.Check(data)
+	if problems == nil {
+		problems = []config.Problem{}
+	}
+	writeJSON(w, map[string]any{"valid": !config.HasErrors(problems), "problems": problems})
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/recorder"
+	"github.com/daodao97/acpone/internal/trace"
+)
+
+// setupDebug attaches the trace buffer, and the traffic recorder when enabled, to every agent
+func (s *Server) setupDebug() {
+	var secrets []string
+	for _, a := range s.agentList() {
+		secrets = append(secrets, recorder.SecretsFromEnv(a.Env)...)
+	}
+
+	s.tracer = trace.New(0, secrets)
+	if s.config.Debug != nil && s.config.Debug.Record {
+		s.recorder = recorder.New(s.config.Debug.RecordingDir, secrets)
+	}
+
+	for _, id := range s.agents.IDs() {
+		proc, err := s.agents.Get(id)
+		if err != nil {
+			continue
+		}
+		s.tracer.Attach(proc)
+		if s.recorder != nil {
+			s.recorder.Attach(proc)
+		}
+	}
+}
+
+// handleRecordings lists recordings or downloads one by name
+func (s *Server) handleRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.recorder == nil {
+		writeError(w, "Recording is disabled (set debug.record in config)", http.StatusNotFound)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/debug/recordings")
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		files := s.recorder.List()
+		if files == nil {
+			files = []recorder.FileInfo{}
+		}
+		writeJSON(w, map[string]any{"recordings": files, "dir": s.recorder.Dir()})
+		return
+	}
+
+	path, err := s.recorder.Path(name)
+	if err != nil {
+		writeError(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(path)+`"`)
+	http.ServeFile(w, r, path)
+}
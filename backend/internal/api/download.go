@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// handleFileDownload serves a workspace file, typically a build output or
+// log an agent produced: GET /api/workspaces/files/download?workspaceId=&path=
+//
+// The file is streamed from disk rather than read into memory. Range and
+// If-Range requests are honored, so large files can be fetched in parts
+// and interrupted downloads resumed; the ETag changes when the file does.
+// ?download=1 asks the browser to save it instead of displaying it.
+func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	root := s.resolveWorkspacePath(q.Get("workspaceId"))
+	path, err := workspaceFile(root, q.Get("path"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		writeError(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		writeError(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	name := filepath.Base(path)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+	w.Header().Set("Cache-Control", "no-cache")
+	if q.Get("download") != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// workspaceFile resolves a path relative to the workspace root, rejecting
+// paths that leave it, including through symlinks
+func workspaceFile(root, path string) (string, error) {
+	if root == "" || root == "." {
+		return "", fmt.Errorf("workspace not found")
+	}
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("path required")
+	}
+	p := path
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, filepath.FromSlash(p))
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("workspace not found")
+	}
+	realPath, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		// Missing files are reported by the caller
+		realPath = filepath.Clean(p)
+		realRoot = filepath.Clean(root)
+	}
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file must be inside the workspace")
+	}
+	return realPath, nil
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/eventlog"
+	"github.com/daodao97/acpone/internal/events"
+)
+
+// setupEventLog persists bus events unless disabled in the debug config
+func (s *Server) setupEventLog() {
+	dir := ""
+	if d := s.config.Debug; d != nil {
+		if d.NoEventLog {
+			return
+		}
+		dir = d.EventLogDir
+	}
+	l, err := eventlog.Open(dir)
+	if err != nil {
+		storageLog.Warn("event log disabled", "error", err)
+		return
+	}
+	s.eventLog = l
+	s.events.Resume(l.LastSeq())
+	s.events.Subscribe(events.Filter{}, l.Append)
+}
+
+// eventStream publishes the events of one SSE request on the bus. The
+// request's own writer receives them back as a bus subscriber, like any
+// other observer.
+type eventStream struct {
+	bus     *events.Bus
+	id      string
+	convID  string
+	topicOf func(event string) events.Topic
+}
+
+// newEventStream subscribes deliver to a new stream and returns the stream
+// with a cleanup function
+func (s *Server) newEventStream(topicOf func(string) events.Topic, deliver func(string, any)) (*eventStream, func()) {
+	es := &eventStream{bus: s.events, id: generateUUID(), topicOf: topicOf}
+	cleanup := s.events.Subscribe(events.Filter{Stream: es.id}, func(ev events.Event) {
+		deliver(ev.Type, ev.Data)
+	})
+	return es, cleanup
+}
+
+// Send publishes an event of the stream
+func (es *eventStream) Send(event string, data any) {
+	es.bus.Publish(events.Event{
+		Topic:          es.topicOf(event),
+		Type:           event,
+		ConversationID: es.convID,
+		Stream:         es.id,
+		Data:           data,
+	})
+}
+
+// chatTopic maps chat SSE event names to bus topics
+func chatTopic(event string) events.Topic {
+	switch event {
+	case "tool_call":
+		return events.Tool
+	case "permission_request", "permission_resolved":
+		return events.Permission
+	case "commands", "warning":
+		return events.Agent
+	default:
+		return events.Turn
+	}
+}
+
+// setupTopic puts all setup install events on the setup topic
+func setupTopic(string) events.Topic {
+	return events.Setup
+}
+
+// sseWriter returns a function writing named SSE events to w
+func sseWriter(w http.ResponseWriter, flusher http.Flusher) func(string, any) {
+	return streamWriter(w, flusher, sseEncoder)
+}
+
+// handleEvents streams bus events as SSE, optionally filtered by
+// ?topics=turn,tool and ?conversationId=
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	filter := eventFilter(r)
+	ch, cancel := s.events.Channel(filter, 256)
+	defer cancel()
+
+	// Send headers right away so clients see the stream open
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	write := func(ev events.Event) {
+		jsonData, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Topic, jsonData)
+		flusher.Flush()
+	}
+
+	// Reconnecting clients catch up from the log first; live events that
+	// were also replayed are skipped
+	last := eventSince(r)
+	if last > 0 && s.eventLog != nil {
+		history, _ := s.eventLog.History(last, filter, 0)
+		for _, ev := range history {
+			write(ev)
+			last = ev.Seq
+		}
+	}
+
+	for {
+		select {
+		case ev := <-ch:
+			if ev.Seq > last {
+				write(ev)
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleEventHistory returns logged events after ?since= (a sequence number)
+func (s *Server) handleEventHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.eventLog == nil {
+		writeError(w, "Event log is disabled", http.StatusNotImplemented)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	history, more := s.eventLog.History(eventSince(r), eventFilter(r), limit)
+	writeJSON(w, map[string]any{
+		"events": history,
+		"more":   more,
+		"seq":    s.events.Seq(),
+	})
+}
+
+// eventSince reads the last seen sequence number from ?since= or the SSE
+// Last-Event-ID header
+func eventSince(r *http.Request) uint64 {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		v = r.Header.Get("Last-Event-ID")
+	}
+	since, _ := strconv.ParseUint(v, 10, 64)
+	return since
+}
+
+// eventFilter reads a bus filter from the request query
+func eventFilter(r *http.Request) events.Filter {
+	q := r.URL.Query()
+	f := events.Filter{ConversationID: q.Get("conversationId")}
+	for _, t := range strings.Split(q.Get("topics"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Topics = append(f.Topics, events.Topic(t))
+		}
+	}
+	return f
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/daodao97/acpone/internal/export"
+)
+
+// handleSessionExport downloads a session as a self-contained HTML file
+func (s *Server) handleSessionExport(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "html" {
+		writeError(w, "unsupported format: "+format, http.StatusBadRequest)
+		return
+	}
+
+	session, err := s.sessionStore.Load(id)
+	if err != nil {
+		writeError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.HTML(&buf, session); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="acpone-%s.html"`, id))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"cmp"
+	"encoding/csv"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conversation"
+)
+
+// feedbackColumns heads the rows of the feedback export
+var feedbackColumns = []string{
+	"agent", "turns", "end_turn", "cancelled", "truncated", "errors", "other_stops",
+	"avg_duration_s", "median_duration_s", "p90_duration_s", "median_first_output_s",
+	"tool_calls", "avg_tool_calls", "thumbs_up", "thumbs_down", "approval", "todos", "notes",
+}
+
+// agentFeedback accumulates the turns and ratings of one agent
+type agentFeedback struct {
+	day, agent  string
+	durations   []int64
+	firstOutput []int64
+	stops       map[string]int
+	truncated   int
+	toolCalls   int
+	up, down    int
+	todos       int
+	notes       int
+}
+
+// handleFeedbackExport serves GET /api/stats/feedback.csv: per agent, how
+// its turns ended, how long they took and how its messages were rated.
+// ?days= limits it to recent activity, ?workspaceId= to one workspace and
+// ?by=day adds a row per day.
+func (s *Server) handleFeedbackExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	var since int64
+	if v := q.Get("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			writeError(w, "days must be a positive number", http.StatusBadRequest)
+			return
+		}
+		since = time.Now().AddDate(0, 0, -days).UnixMilli()
+	}
+	byDay := q.Get("by") == "day"
+
+	stats := make(map[[2]string]*agentFeedback)
+	get := func(agentID string, at int64) *agentFeedback {
+		day := ""
+		if byDay {
+			day = time.UnixMilli(at).Format(time.DateOnly)
+		}
+		key := [2]string{day, agentID}
+		if stats[key] == nil {
+			stats[key] = &agentFeedback{day: day, agent: agentID, stops: make(map[string]int)}
+		}
+		return stats[key]
+	}
+
+	for _, meta := range s.sessionStore.List() {
+		if ws := q.Get("workspaceId"); ws != "" && meta.WorkspaceID != ws {
+			continue
+		}
+		if meta.UpdatedAt < since {
+			continue
+		}
+		session, err := s.sessionStore.Load(meta.ID)
+		if err != nil {
+			continue
+		}
+		for _, t := range session.Turns {
+			if t.StartedAt < since || t.Agent == "" {
+				continue
+			}
+			f := get(t.Agent, t.StartedAt)
+			f.durations = append(f.durations, t.DurationMs)
+			if t.FirstOutputMs > 0 {
+				f.firstOutput = append(f.firstOutput, t.FirstOutputMs)
+			}
+			f.stops[t.StopReason]++
+			if t.Truncated {
+				f.truncated++
+			}
+			f.toolCalls += t.ToolCalls
+		}
+		// Ratings count for the agent that wrote the message, when given
+		for _, a := range session.Annotations {
+			if a.UpdatedAt < since || a.Message < 0 || a.Message >= len(session.Messages) {
+				continue
+			}
+			agentID := session.Messages[a.Message].Agent
+			if agentID == "" {
+				continue
+			}
+			f := get(agentID, a.UpdatedAt)
+			switch a.Rating {
+			case conversation.RatingUp:
+				f.up++
+			case conversation.RatingDown:
+				f.down++
+			}
+			if a.Todo {
+				f.todos++
+			}
+			if a.Note != "" {
+				f.notes++
+			}
+		}
+	}
+
+	rows := make([]*agentFeedback, 0, len(stats))
+	for _, f := range stats {
+		rows = append(rows, f)
+	}
+	slices.SortFunc(rows, func(a, b *agentFeedback) int {
+		if c := cmp.Compare(a.day, b.day); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.agent, b.agent)
+	})
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="acpone-feedback.csv"`)
+	out := csv.NewWriter(w)
+	header := feedbackColumns
+	if byDay {
+		header = append([]string{"day"}, header...)
+	}
+	out.Write(header)
+	for _, f := range rows {
+		record := f.record()
+		if byDay {
+			record = append([]string{f.day}, record...)
+		}
+		out.Write(record)
+	}
+	out.Flush()
+}
+
+// record formats the accumulated stats as a row of feedbackColumns
+func (f *agentFeedback) record() []string {
+	turns := len(f.durations)
+	other := turns - f.stops["end_turn"] - f.stops["cancelled"] - f.stops[conversation.StopError]
+
+	var total int64
+	for _, d := range f.durations {
+		total += d
+	}
+	seconds := func(ms int64) string { return strconv.FormatFloat(float64(ms)/1000, 'f', 1, 64) }
+	ratio := func(n, d int) string {
+		if d == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(float64(n)/float64(d), 'f', 2, 64)
+	}
+	avg := ""
+	if turns > 0 {
+		avg = seconds(total / int64(turns))
+	}
+
+	return []string{
+		f.agent,
+		strconv.Itoa(turns),
+		strconv.Itoa(f.stops["end_turn"]),
+		strconv.Itoa(f.stops["cancelled"]),
+		strconv.Itoa(f.truncated),
+		strconv.Itoa(f.stops[conversation.StopError]),
+		strconv.Itoa(other),
+		avg,
+		percentile(f.durations, 50, seconds),
+		percentile(f.durations, 90, seconds),
+		percentile(f.firstOutput, 50, seconds),
+		strconv.Itoa(f.toolCalls),
+		ratio(f.toolCalls, turns),
+		strconv.Itoa(f.up),
+		strconv.Itoa(f.down),
+		ratio(f.up, f.up+f.down),
+		strconv.Itoa(f.todos),
+		strconv.Itoa(f.notes),
+	}
+}
+
+// percentile formats the p-th percentile of values, empty when there are none
+func percentile(values []int64, p int, format func(int64) string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return format(sorted[(len(sorted)-1)*p/100])
+}
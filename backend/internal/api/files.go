@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -23,112 +24,6 @@ type FileInfo struct {
 	IsDir  bool   `json:"isDir"`  // Is directory
 }
 
-// handleWorkspaceFiles returns files in the current workspace
-func (s *Server) handleWorkspaceFiles(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	workspaceID := r.URL.Query().Get("workspaceId")
-	query := r.URL.Query().Get("q")
-	limitStr := r.URL.Query().Get("limit")
-
-	limit := 50 // Default limit
-	if limitStr != "" {
-		if n, err := parseInt(limitStr); err == nil && n > 0 {
-			limit = n
-		}
-	}
-
-	workspacePath := s.resolveWorkspacePath(workspaceID)
-	if workspacePath == "" || workspacePath == "." {
-		writeJSON(w, map[string]any{"files": []FileInfo{}})
-		return
-	}
-
-	files := listWorkspaceFiles(workspacePath, query, limit)
-	writeJSON(w, map[string]any{"files": files})
-}
-
-// listWorkspaceFiles walks the workspace and returns matching files
-func listWorkspaceFiles(root, query string, limit int) []FileInfo {
-	var files []FileInfo
-	query = strings.ToLower(query)
-
-	// Skip these directories
-	skipDirs := map[string]bool{
-		".git":         true,
-		"node_modules": true,
-		".idea":        true,
-		".vscode":      true,
-		"vendor":       true,
-		"dist":         true,
-		"build":        true,
-		"__pycache__":  true,
-		".next":        true,
-		".nuxt":        true,
-		"coverage":     true,
-		".cache":       true,
-	}
-
-	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-
-		// Get relative path
-		relPath, err := filepath.Rel(root, path)
-		if err != nil || relPath == "." {
-			return nil
-		}
-
-		// Skip hidden files/dirs (except query matches)
-		name := info.Name()
-		if strings.HasPrefix(name, ".") && !strings.Contains(strings.ToLower(name), query) {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Skip certain directories
-		if info.IsDir() {
-			if skipDirs[name] {
-				return filepath.SkipDir
-			}
-			return nil // Don't add directories to results
-		}
-
-		// Check limit
-		if len(files) >= limit {
-			return filepath.SkipAll
-		}
-
-		// Match query (case insensitive)
-		if query != "" {
-			lowerPath := strings.ToLower(relPath)
-			lowerName := strings.ToLower(name)
-			if !strings.Contains(lowerPath, query) && !strings.Contains(lowerName, query) {
-				return nil
-			}
-		}
-
-		// Use forward slashes for consistency
-		relPath = filepath.ToSlash(relPath)
-
-		files = append(files, FileInfo{
-			Path:  relPath,
-			Name:  name,
-			IsDir: info.IsDir(),
-		})
-
-		return nil
-	})
-
-	return files
-}
-
 func parseInt(s string) (int, error) {
 	var n int
 	for _, c := range s {
@@ -154,23 +49,19 @@ func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Limit request size
-	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	policy := s.config.Upload
+	limit := uploadFileLimit(policy)
+	r.Body = http.MaxBytesReader(w, r.Body, max(limit, maxUploadSize))
 
 	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
-		writeError(w, "File too large (max 10MB)", http.StatusBadRequest)
+		writeError(w, fmt.Sprintf("File too large (max %dMB)", max(limit, maxUploadSize)>>20), http.StatusBadRequest)
 		return
 	}
 
 	// Get workspace ID from form
 	workspaceID := r.FormValue("workspaceId")
 	workspacePath := s.resolveWorkspacePath(workspaceID)
-
-	// Create upload directory
 	uploadPath := filepath.Join(workspacePath, uploadDir)
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		writeError(w, "Failed to create upload directory", http.StatusInternalServerError)
-		return
-	}
 
 	files := r.MultipartForm.File["files"]
 	if len(files) == 0 {
@@ -178,51 +69,77 @@ func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject the whole upload if any file breaks the policy
+	if err := checkUploads(policy, uploadPath, files); err != nil {
+		writeError(w, err.Error(), err.(*uploadError).status)
+		return
+	}
+	if err := s.checkUsageQuota(workspaceID, workspacePath, files); err != nil {
+		writeError(w, err.Error(), err.(*uploadError).status)
+		return
+	}
+
+	// Create upload directory
+	if err := ensureUploadDir(uploadPath); err != nil {
+		writeError(w, "Failed to create upload directory", http.StatusInternalServerError)
+		return
+	}
+
 	uploadedFiles := make([]UploadedFile, 0, len(files))
 
 	for _, fileHeader := range files {
-		// Open uploaded file
-		file, err := fileHeader.Open()
-		if err != nil {
-			writeError(w, "Failed to read uploaded file", http.StatusInternalServerError)
-			return
-		}
-		defer file.Close()
-
 		// Generate unique filename to avoid conflicts
 		ext := filepath.Ext(fileHeader.Filename)
 		baseName := strings.TrimSuffix(fileHeader.Filename, ext)
 		uniqueName := fmt.Sprintf("%s_%d%s", baseName, time.Now().UnixNano(), ext)
 		destPath := filepath.Join(uploadPath, uniqueName)
 
-		// Create destination file
-		dst, err := os.Create(destPath)
+		size, err := saveUpload(fileHeader, destPath)
 		if err != nil {
+			removeFiles(uploadedFiles)
 			writeError(w, "Failed to save file", http.StatusInternalServerError)
 			return
 		}
-		defer dst.Close()
-
-		// Copy file content
-		size, err := io.Copy(dst, file)
-		if err != nil {
-			writeError(w, "Failed to save file", http.StatusInternalServerError)
-			return
-		}
-
 		uploadedFiles = append(uploadedFiles, UploadedFile{
 			Name: fileHeader.Filename,
 			Path: destPath,
 			Size: size,
 		})
+
+		if err := scanUpload(policy, workspacePath, destPath, fileHeader.Filename); err != nil {
+			removeFiles(uploadedFiles)
+			writeError(w, err.Error(), err.(*uploadError).status)
+			return
+		}
 	}
 
+	s.usage.invalidate(workspacePath)
+
 	writeJSON(w, map[string]any{
 		"success": true,
 		"files":   uploadedFiles,
 	})
 }
 
+// saveUpload copies an uploaded file to destPath
+func saveUpload(fileHeader *multipart.FileHeader, destPath string) (int64, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	dst, err := os.Create(destPath)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(dst, file)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return size, err
+}
+
 func (s *Server) handleFileCleanup(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/fileindex"
+	"github.com/daodao97/acpone/internal/fuzzy"
+)
+
+// handleWorkspaceFiles returns files in the current workspace
+func (s *Server) handleWorkspaceFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspaceID := r.URL.Query().Get("workspaceId")
+	query := r.URL.Query().Get("q")
+	limitStr := r.URL.Query().Get("limit")
+
+	limit := 50 // Default limit
+	if limitStr != "" {
+		if n, err := parseInt(limitStr); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	workspacePath := s.resolveWorkspacePath(workspaceID)
+	if workspacePath == "" || workspacePath == "." {
+		writeJSON(w, map[string]any{"files": []FileInfo{}})
+		return
+	}
+
+	files := s.fileIndex.Files(workspacePath, s.ignorePatterns(workspaceID))
+	writeJSON(w, map[string]any{"files": searchFiles(files, query, limit)})
+}
+
+// handleReindexFiles rebuilds the file index of a workspace
+func (s *Server) handleReindexFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspaceID := r.URL.Query().Get("workspaceId")
+	workspacePath := s.resolveWorkspacePath(workspaceID)
+	if workspacePath == "" || workspacePath == "." {
+		writeError(w, "workspace not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, s.fileIndex.Reindex(workspacePath, s.ignorePatterns(workspaceID)))
+}
+
+// ignorePatterns returns the project ignore patterns of a workspace
+func (s *Server) ignorePatterns(workspaceID string) []string {
+	if pc := s.projectConfig(workspaceID); pc != nil {
+		return pc.Ignore
+	}
+	return nil
+}
+
+// scoredFile is a search candidate with its ranking inputs
+type scoredFile struct {
+	file  fileindex.File
+	score int
+	depth int
+}
+
+// searchFiles filters indexed files by query. With a query, files are fuzzy
+// matched (fzf-style) and ranked by match quality, then recency, then path
+// depth; without one the first limit files are returned in walk order.
+func searchFiles(files []fileindex.File, query string, limit int) []FileInfo {
+	var candidates []scoredFile
+	lowerQuery := strings.ToLower(query)
+	now := time.Now()
+
+	for _, f := range files {
+		if query == "" && len(candidates) >= limit {
+			break
+		}
+		// Hidden paths only show up when the query names them
+		if hiddenUnlessMatched(f.Path, lowerQuery) {
+			continue
+		}
+		score, ok := fuzzy.ScorePath(query, f.Path)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, scoredFile{
+			file:  f,
+			score: score + recencyBonus(now.Sub(f.ModTime)),
+			depth: strings.Count(f.Path, "/"),
+		})
+	}
+
+	if query != "" {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			if a.depth != b.depth {
+				return a.depth < b.depth
+			}
+			return a.file.ModTime.After(b.file.ModTime)
+		})
+	}
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	result := make([]FileInfo, len(candidates))
+	for i, c := range candidates {
+		result[i] = FileInfo{Path: c.file.Path, Name: c.file.Name}
+	}
+	return result
+}
+
+// hiddenUnlessMatched reports whether path has a dot-prefixed element whose
+// name does not contain the query
+func hiddenUnlessMatched(path, lowerQuery string) bool {
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ".") && !strings.Contains(strings.ToLower(part), lowerQuery) {
+			return true
+		}
+	}
+	return false
+}
+
+// recencyBonus boosts recently modified files in search results
+func recencyBonus(age time.Duration) int {
+	switch {
+	case age < time.Hour:
+		return 12
+	case age < 24*time.Hour:
+		return 8
+	case age < 7*24*time.Hour:
+		return 4
+	}
+	return 0
+}
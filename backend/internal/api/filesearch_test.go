@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/fileindex"
+)
+
+// resultPaths returns the paths of search results in order
+func resultPaths(files []FileInfo) []string {
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i] = f.Path
+	}
+	return out
+}
+
+func TestSearchFiles(t *testing.T) {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	file := func(path string, modTime time.Time) fileindex.File {
+		return fileindex.File{Path: path, Name: filepath.Base(path), ModTime: modTime}
+	}
+	files := []fileindex.File{
+		file("docs/server-notes.md", old),
+		file("internal/api/server.go", old),
+		file("server.go", old),
+		file("internal/api/session.go", old),
+		file(".github/workflows/ci.yml", old),
+		file("cmd/srv/main.go", old),
+	}
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		// Base name matches first, shallower paths breaking ties
+		{"server.go", 10, []string{"server.go", "internal/api/server.go"}},
+		{"srvgo", 10, []string{"server.go", "internal/api/server.go", "cmd/srv/main.go"}},
+		{"srvgo", 1, []string{"server.go"}},
+		// Hidden paths only when the query names them
+		{"workflows", 10, []string{}},
+		{"github", 10, []string{".github/workflows/ci.yml"}},
+		{"zzz", 10, []string{}},
+		// Without a query, the first files in walk order
+		{"", 2, []string{"docs/server-notes.md", "internal/api/server.go"}},
+	}
+	for _, tt := range tests {
+		got := resultPaths(searchFiles(files, tt.query, tt.limit))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("searchFiles(%q, %d) = %v, want %v", tt.query, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestSearchFilesPrefersRecent(t *testing.T) {
+	now := time.Now()
+	files := []fileindex.File{
+		{Path: "a/handler.go", Name: "handler.go", ModTime: now.Add(-90 * 24 * time.Hour)},
+		{Path: "b/handler.go", Name: "handler.go", ModTime: now.Add(-time.Minute)},
+	}
+	got := resultPaths(searchFiles(files, "handler", 10))
+	if want := []string{"b/handler.go", "a/handler.go"}; !slices.Equal(got, want) {
+		t.Errorf("equal matches ranked %v, want the recently modified first %v", got, want)
+	}
+}
+
+func TestWorkspaceFilesHandler(t *testing.T) {
+	s, hs := newTestServer(t, "claude", sessionCountingAgent(new(atomic.Int32)))
+	root := s.resolveWorkspacePath("default")
+	for _, p := range []string{"src/userService.ts", "src/utils.ts", "README.md", "node_modules/x/user.js"} {
+		path := filepath.Join(root, filepath.FromSlash(p))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query string) []string {
+		t.Helper()
+		resp, err := hs.Client().Get(hs.URL + "/api/workspaces/files?workspaceId=default&q=" + url.QueryEscape(query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var data struct {
+			Files []FileInfo `json:"files"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		return resultPaths(data.Files)
+	}
+	if got, want := search("usrsvc"), []string{"src/userService.ts"}; !slices.Equal(got, want) {
+		t.Errorf("search usrsvc = %v, want %v", got, want)
+	}
+	if got := search("user"); !slices.Equal(got, []string{"src/userService.ts"}) {
+		t.Errorf("search user = %v, want skipped directories left out", got)
+	}
+}
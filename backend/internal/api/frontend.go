@@ -0,0 +1,379 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// maxBundleSize limits uploaded frontend bundle archives
+const maxBundleSize = 200 << 20
+
+// maxFrontendHistory caps the bundles kept for rollback
+const maxFrontendHistory = 10
+
+var (
+	bundleName = regexp.MustCompile(`^[\w.-]+$`)
+	// Root-relative asset references in index.html, e.g. src="/assets/index.js"
+	bundleAsset = regexp.MustCompile(`(?:src|href)="/([^"/][^"?#]*)`)
+)
+
+// frontend selects the web UI served at /: the embedded web/dist or a
+// bundle directory deployed at runtime, with the previous ones kept for
+// rollback. The selection is saved in ~/.acpone/frontends/state.json.
+type frontend struct {
+	mu       sync.RWMutex
+	embedded fs.FS
+	dir      string    // Active bundle directory, "" for the embedded one
+	files    fs.FS     // Files of dir
+	history  []string  // Previously active directories, most recent last
+	since    time.Time // When the active bundle was selected
+}
+
+// frontendState is the persisted selection
+type frontendState struct {
+	Active  string   `json:"active,omitempty"`
+	History []string `json:"history,omitempty"`
+}
+
+// frontendDir returns ~/.acpone/frontends, where uploaded bundles live
+func frontendDir() string {
+	return filepath.Join(sysutil.DataDir(), "frontends")
+}
+
+// current returns the files to serve, nil when there are none
+func (f *frontend) current() fs.FS {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.files != nil {
+		return f.files
+	}
+	return f.embedded
+}
+
+// load restores the saved selection, falling back to the embedded bundle
+// when the saved directory is no longer a valid bundle
+func (f *frontend) load() {
+	data, err := os.ReadFile(filepath.Join(frontendDir(), "state.json"))
+	if err != nil {
+		return
+	}
+	var state frontendState
+	if err := json.Unmarshal(data, &state); err != nil {
+		frontendLog.Warn("invalid state.json", "error", err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.history = state.History
+	if state.Active == "" {
+		return
+	}
+	if err := validateBundle(state.Active); err != nil {
+		frontendLog.Warn("serving embedded UI, bundle unusable", "bundle", state.Active, "error", err)
+		return
+	}
+	f.dir, f.files, f.since = state.Active, os.DirFS(state.Active), time.Now()
+	frontendLog.Info("serving bundle", "bundle", state.Active)
+}
+
+// activate validates dir and serves it, "" meaning the embedded bundle. The
+// bundle being replaced is pushed onto the rollback history.
+func (f *frontend) activate(dir string) error {
+	if dir != "" {
+		if err := validateBundle(dir); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if dir == f.dir {
+		return nil
+	}
+	f.history = append(f.history, f.dir)
+	if len(f.history) > maxFrontendHistory {
+		f.history = f.history[len(f.history)-maxFrontendHistory:]
+	}
+	f.set(dir)
+	return f.save()
+}
+
+// rollback returns to the most recent previous bundle that is still valid
+func (f *frontend) rollback() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.history) > 0 {
+		dir := f.history[len(f.history)-1]
+		f.history = f.history[:len(f.history)-1]
+		if dir != "" {
+			if err := validateBundle(dir); err != nil {
+				frontendLog.Warn("skipping bundle on rollback", "bundle", dir, "error", err)
+				continue
+			}
+		}
+		f.set(dir)
+		return dir, f.save()
+	}
+	return "", errors.New("no previous frontend to roll back to")
+}
+
+// set switches the served bundle, the caller holding mu
+func (f *frontend) set(dir string) {
+	f.dir, f.files, f.since = dir, nil, time.Now()
+	if dir != "" {
+		f.files = os.DirFS(dir)
+	}
+	if dir == "" {
+		frontendLog.Info("serving embedded UI")
+	} else {
+		frontendLog.Info("serving bundle", "bundle", dir)
+	}
+}
+
+// save writes the selection, the caller holding mu
+func (f *frontend) save() error {
+	data, _ := json.MarshalIndent(frontendState{Active: f.dir, History: f.history}, "", "  ")
+	if err := os.MkdirAll(frontendDir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(frontendDir(), "state.json"), data, 0644)
+}
+
+// validateBundle checks that dir holds a built web UI: an index.html whose
+// root-relative scripts and stylesheets exist
+func validateBundle(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("bundle path must be absolute: %s", dir)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return fmt.Errorf("bundle has no index.html: %w", err)
+	}
+	for _, m := range bundleAsset.FindAllSubmatch(index, -1) {
+		name := path.Clean(string(m[1]))
+		if !fs.ValidPath(name) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			return fmt.Errorf("index.html references missing file /%s", name)
+		}
+	}
+	return nil
+}
+
+// extractBundle unpacks a zip archive into dir. An archive whose files are
+// all inside one folder (e.g. a zipped dist/) is unpacked from that folder.
+func extractBundle(data []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+
+	prefix := ""
+	if _, err := fs.Stat(zr, "index.html"); err != nil {
+		entries, _ := fs.ReadDir(zr, ".")
+		if len(entries) == 1 && entries[0].IsDir() {
+			prefix = entries[0].Name() + "/"
+		}
+	}
+
+	for _, f := range zr.File {
+		name, ok := strings.CutPrefix(f.Name, prefix)
+		if !ok || name == "" || f.FileInfo().IsDir() {
+			continue
+		}
+		if !fs.ValidPath(name) {
+			return fmt.Errorf("invalid path in archive: %s", f.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractFile(f, target); err != nil {
+			return fmt.Errorf("extract %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// frontendInfo describes the served bundle and the rollback history
+func (s *Server) frontendInfo() map[string]any {
+	f := &s.frontend
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var bundles []string
+	entries, _ := os.ReadDir(frontendDir())
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			bundles = append(bundles, e.Name())
+		}
+	}
+	info := map[string]any{
+		"active":   f.dir,
+		"embedded": f.dir == "",
+		"history":  f.history,
+		"bundles":  bundles,
+	}
+	if !f.since.IsZero() {
+		info["since"] = f.since.UnixMilli()
+	}
+	return info
+}
+
+// handleFrontend reports the served bundle (GET) or switches to another
+// (POST {name} for an uploaded bundle, {path} for a directory on disk or
+// {embedded: true})
+func (s *Server) handleFrontend(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, s.frontendInfo())
+	case "POST":
+		var req struct {
+			Name     string `json:"name"`
+			Path     string `json:"path"`
+			Embedded bool   `json:"embedded"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		dir := req.Path
+		switch {
+		case req.Embedded:
+			dir = ""
+		case req.Name != "":
+			if !validBundleName(req.Name) {
+				writeError(w, "Invalid bundle name", http.StatusBadRequest)
+				return
+			}
+			dir = filepath.Join(frontendDir(), req.Name)
+		case dir == "":
+			writeError(w, "name, path or embedded is required", http.StatusBadRequest)
+			return
+		}
+		if err := s.frontend.activate(dir); err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.publishVersion()
+		writeJSON(w, s.frontendInfo())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validBundleName reports whether name is a single plain path element,
+// naming a bundle directory in ~/.acpone/frontends
+func validBundleName(name string) bool {
+	return bundleName.MatchString(name) && filepath.Base(name) == name && name != "." && name != ".."
+}
+
+// handleFrontendUpload stores a zipped bundle sent as the request body under
+// ~/.acpone/frontends/<name> and serves it unless ?activate=false
+func (s *Server) handleFrontendUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = time.Now().Format("20060102-150405")
+	}
+	if !validBundleName(name) || strings.HasPrefix(name, ".") {
+		writeError(w, "Invalid bundle name", http.StatusBadRequest)
+		return
+	}
+	dir := filepath.Join(frontendDir(), name)
+	if _, err := os.Stat(dir); err == nil {
+		writeError(w, "Bundle already exists: "+name, http.StatusConflict)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBundleSize))
+	if err != nil {
+		writeError(w, "Failed to read archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Unpack next to the final location so a failed upload leaves nothing
+	if err := os.MkdirAll(frontendDir(), 0755); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmp, err := os.MkdirTemp(frontendDir(), ".upload-")
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractBundle(data, tmp); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateBundle(tmp); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	frontendLog.Info("uploaded bundle", "bundle", name)
+
+	if r.URL.Query().Get("activate") != "false" {
+		if err := s.frontend.activate(dir); err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.publishVersion()
+	}
+	writeJSON(w, s.frontendInfo())
+}
+
+// handleFrontendRollback serves the previously active bundle again
+func (s *Server) handleFrontendRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, err := s.frontend.rollback(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.publishVersion()
+	writeJSON(w, s.frontendInfo())
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+func TestValidBundleName(t *testing.T) {
+	tests := map[string]bool{
+		"v1":              true,
+		"20240101-120000": true,
+		"ui.v2_beta":      true,
+		"":                false,
+		".":               false,
+		"..":              false,
+		"../evil":         false,
+		"a/b":             false,
+		`a\b`:             false,
+		"v 1":             false,
+	}
+	for name, want := range tests {
+		if got := validBundleName(name); got != want {
+			t.Errorf("validBundleName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFrontendRejectsDotNames(t *testing.T) {
+	s, hs := newTestServer(t, "claude", sessionCountingAgent(new(atomic.Int32)))
+	// A valid bundle right above ~/.acpone/frontends, where ".." points
+	if err := os.WriteFile(filepath.Join(sysutil.DataDir(), "index.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{".", ".."} {
+		resp, err := hs.Client().Post(hs.URL+"/api/frontend", "application/json", bytes.NewReader([]byte(`{"name": "`+name+`"}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("name %q: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+	if dir := s.frontendInfo()["active"]; dir != "" {
+		t.Fatalf("activated bundle %q", dir)
+	}
+}
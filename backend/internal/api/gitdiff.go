@@ -0,0 +1,46 @@
+package api
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// maxDiffBytes caps diffs handed to agents
+const maxDiffBytes = 100 << 10
+
+// git runs a git command in dir and returns its stdout
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	sysutil.HideWindow(cmd)
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// workspaceStatus returns `git status --porcelain` of a workspace, or "" when
+// it is not a git repository
+func workspaceStatus(root string) string {
+	out, _ := git(root, "status", "--porcelain", "--untracked-files=all")
+	return out
+}
+
+// workspaceDiff returns the uncommitted changes of a git workspace, listing
+// untracked files by name. Long diffs are truncated.
+func workspaceDiff(root string) string {
+	diff, err := git(root, "diff", "HEAD", "--no-color")
+	if err != nil {
+		// No commits yet
+		diff, _ = git(root, "diff", "--no-color")
+	}
+	if untracked, _ := git(root, "ls-files", "--others", "--exclude-standard"); untracked != "" {
+		for _, f := range strings.Split(strings.TrimSpace(untracked), "\n") {
+			diff += "new file: " + f + "\n"
+		}
+	}
+	if len(diff) > maxDiffBytes {
+		diff = diff[:maxDiffBytes] + "\n... (diff truncated)\n"
+	}
+	return diff
+}
@@ -2,33 +2,54 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/events"
+	"github.com/daodao97/acpone/internal/storage"
 )
 
 func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
 	s.agentCommandsMu.RLock()
 	defer s.agentCommandsMu.RUnlock()
 
-	agents := make([]map[string]any, 0, len(s.config.Agents))
-	for _, a := range s.config.Agents {
+	configured := s.agentList()
+	agents := make([]map[string]any, 0, len(configured))
+	for _, a := range configured {
 		agentData := map[string]any{
 			"id":             a.ID,
 			"name":           a.Name,
+			"aliases":        a.Aliases,
+			"enabled":        a.IsEnabled(),
 			"permissionMode": a.PermissionMode,
 			"command":        a.Command,
 			"args":           a.Args,
-			"env":            a.Env,
+		}
+		// Env often holds API keys: only the admin listener shows it
+		if !isPublic(r) {
+			agentData["env"] = a.Env
 		}
 		// Include cached commands if available
 		if cmds, ok := s.agentCommands[a.ID]; ok {
 			agentData["commands"] = cmds
 		}
+		// Include process and initialization status
+		if proc, err := s.agents.Get(a.ID); err == nil {
+			agentData["status"] = proc.Status()
+			agentData["healthy"] = proc.Healthy()
+		}
+		if st := s.agentInitSnapshot(a.ID); st != nil {
+			agentData["init"] = st
+		}
+		if v, ok := s.versions.get(s.versionKey(a)); ok {
+			agentData["version"] = v
+		}
 		agents = append(agents, agentData)
 	}
 
@@ -49,47 +70,64 @@ func (s *Server) handleAgentUpdate(w http.ResponseWriter, r *http.Request) {
 		PermissionMode string            `json:"permissionMode,omitempty"`
 		Env            map[string]string `json:"env,omitempty"`
 		UpdateEnv      bool              `json:"updateEnv,omitempty"`
+		Enabled        *bool             `json:"enabled,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		writeError(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	agent := s.config.FindAgent(data.AgentID)
-	if agent == nil {
-		writeError(w, "Agent not found", http.StatusNotFound)
-		return
-	}
+	var agent config.AgentConfig
+	err := s.changeAgents(func(agents []config.AgentConfig) ([]config.AgentConfig, error) {
+		i := slices.IndexFunc(agents, func(a config.AgentConfig) bool { return a.ID == data.AgentID })
+		if i < 0 {
+			return nil, errAgentNotFound
+		}
+		a := &agents[i]
 
-	// Update permission mode if provided
-	if data.PermissionMode != "" {
-		agent.PermissionMode = data.PermissionMode
-	}
+		// Update permission mode if provided
+		if data.PermissionMode != "" {
+			a.PermissionMode = data.PermissionMode
+		}
 
-	// Update env if requested
-	if data.UpdateEnv {
-		agent.Env = data.Env
-	}
+		// Update env if requested
+		if data.UpdateEnv {
+			a.Env = data.Env
+		}
 
-	if err := s.config.Save(""); err != nil {
-		writeError(w, "Failed to save config", http.StatusInternalServerError)
+		// Enable or disable; the default agent must stay enabled
+		if data.Enabled != nil {
+			if !*data.Enabled && a.ID == s.config.DefaultAgent {
+				return nil, errors.New("The default agent cannot be disabled")
+			}
+			a.Enabled = data.Enabled
+			if *data.Enabled {
+				a.Enabled = nil
+			}
+		}
+		agent = *a
+		return agents, nil
+	})
+	switch {
+	case errors.Is(err, errAgentNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, errConfigSave):
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	case err != nil:
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	s.events.Publish(events.Event{Topic: events.Config, Type: "agent_updated", Data: &agent})
 
 	// Stop the agent process so it will be recreated with new config on next request
 	_ = s.agents.Stop(data.AgentID)
 
-	// Clear agent initialization state so it will re-initialize
-	delete(s.initialized, data.AgentID)
-
-	// Clear all session mappings for this agent
-	for convID, sessions := range s.agentSessions {
-		if _, ok := sessions[data.AgentID]; ok {
-			delete(s.agentSessions[convID], data.AgentID)
-		}
-	}
+	// Clear initialization and session state so it will re-initialize
+	s.resetAgentState(data.AgentID)
 
-	writeJSON(w, map[string]any{"success": true, "agent": agent})
+	writeJSON(w, map[string]any{"success": true, "agent": &agent})
 }
 
 func (s *Server) handleWorkspaces(w http.ResponseWriter, r *http.Request) {
@@ -104,8 +142,9 @@ func (s *Server) handleWorkspaces(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) listWorkspaces(w http.ResponseWriter, r *http.Request) {
-	workspaces := make([]map[string]any, 0, len(s.config.Workspaces))
-	for _, ws := range s.config.Workspaces {
+	list := s.workspaceStore.List()
+	workspaces := make([]map[string]any, 0, len(list))
+	for _, ws := range list {
 		workspaces = append(workspaces, map[string]any{
 			"id":   ws.ID,
 			"name": ws.Name,
@@ -115,7 +154,7 @@ func (s *Server) listWorkspaces(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, map[string]any{
 		"workspaces": workspaces,
-		"default":    s.config.DefaultWorkspace,
+		"default":    s.workspaceStore.Default(),
 	})
 }
 
@@ -145,17 +184,16 @@ func (s *Server) createWorkspace(w http.ResponseWriter, r *http.Request) {
 	id = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(id, "-")
 	id = strings.Trim(id, "-")
 
-	// Check duplicate
-	for _, ws := range s.config.Workspaces {
-		if ws.ID == id {
+	ws := config.WorkspaceConfig{ID: id, Name: data.Name, Path: data.Path}
+	if err := s.workspaceStore.Add(ws); err != nil {
+		if errors.Is(err, storage.ErrWorkspaceExists) {
 			writeError(w, "Workspace with this name already exists", http.StatusBadRequest)
 			return
 		}
+		writeError(w, "Failed to save workspace: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-
-	ws := config.WorkspaceConfig{ID: id, Name: data.Name, Path: data.Path}
-	s.config.Workspaces = append(s.config.Workspaces, ws)
-	s.workspaceStore.Add(ws)
+	s.events.Publish(events.Event{Topic: events.Config, Type: "workspace_added", Data: ws})
 
 	writeJSON(w, map[string]any{"workspace": ws})
 }
@@ -226,9 +264,12 @@ func (s *Server) handlePermissionConfirm(w http.ResponseWriter, r *http.Request)
 	}
 
 	agent.ConfirmPermission(data.ToolCallID, data.OptionID)
+	s.permissionResolved(data.AgentID, data.ToolCallID, data.OptionID)
 	writeJSON(w, map[string]any{"success": true})
 }
 
+// handleChatCancel stops a running turn, given its agent and agent session
+// or just its conversation
 func (s *Server) handleChatCancel(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -236,13 +277,32 @@ func (s *Server) handleChatCancel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var data struct {
-		AgentID   string `json:"agentId"`
-		SessionID string `json:"sessionId"`
+		AgentID        string `json:"agentId"`
+		SessionID      string `json:"sessionId"`
+		ConversationID string `json:"conversationId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		writeError(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	if data.SessionID == "" && data.ConversationID != "" {
+		for _, t := range s.turns.list() {
+			if t.ConversationID == data.ConversationID {
+				data.AgentID = t.AgentID
+				data.SessionID = s.agentSessions.get(t.ConversationID, t.AgentID)
+			}
+		}
+	}
+	if data.SessionID == "" {
+		writeError(w, "No running turn to cancel", http.StatusNotFound)
+		return
+	}
+
+	// A turn waiting to retry a rate-limited prompt stops waiting
+	if s.retries.cancel(data.SessionID) {
+		writeJSON(w, map[string]any{"success": true})
+		return
+	}
 
 	agent, err := s.agents.Get(data.AgentID)
 	if err != nil {
@@ -250,11 +310,7 @@ func (s *Server) handleChatCancel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Send session/cancel notification to agent
-	err = agent.Notify("session/cancel", map[string]string{
-		"sessionId": data.SessionID,
-	})
-	if err != nil {
+	if err := agent.Cancel(data.SessionID); err != nil {
 		writeError(w, "Failed to cancel: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -263,21 +319,5 @@ func (s *Server) handleChatCancel(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) resolveWorkspacePath(workspaceID string) string {
-	if workspaceID != "" {
-		if ws := s.config.FindWorkspace(workspaceID); ws != nil {
-			return ws.Path
-		}
-	}
-
-	if s.config.DefaultWorkspace != "" {
-		if ws := s.config.FindWorkspace(s.config.DefaultWorkspace); ws != nil {
-			return ws.Path
-		}
-	}
-
-	if len(s.config.Workspaces) > 0 {
-		return s.config.Workspaces[0].Path
-	}
-
-	return "."
+	return s.workspaceStore.ResolvePath(workspaceID)
 }
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+const (
+	defaultHookTimeout = 300 * time.Second
+	// maxHookOutput keeps the tail of long hook output, where test
+	// summaries and build errors usually end up
+	maxHookOutput = 20 << 10
+	// hookWaitDelay bounds waiting for a killed hook's output to close
+	hookWaitDelay = 2 * time.Second
+)
+
+// runPostTurnHooks runs the workspace hooks matching a finished turn. Output
+// of failing "prompt" hooks is sent back to the agent as a follow-up turn,
+// after which matching hooks run again. Returns the last turn's result.
+func (s *Server) runPostTurnHooks(base turn, res *turnResult, changed bool) (*turnResult, error) {
+	root := s.resolveWorkspacePath(base.workspaceID)
+	edited := res.Edited
+
+	for attempt := 0; ; attempt++ {
+		var failures []string
+		for i := range base.project.Hooks {
+			hook := &base.project.Hooks[i]
+			if !hook.Matches(base.agentID, changed) {
+				continue
+			}
+			output, err := s.runHook(base, hook, root)
+			if err != nil && attempt < hook.Retries() {
+				failures = append(failures, fmt.Sprintf("The `%s` hook failed (%v):\n```\n%s\n```", hook.Label(), err, strings.TrimSpace(output)))
+			}
+		}
+		if len(failures) == 0 || res.Result["stopReason"] == "cancelled" {
+			res.Edited = edited
+			return res, nil
+		}
+
+		base.sendEvent("status", map[string]string{"message": "Sending hook failures to " + base.agentID + "..."})
+		before := workspaceStatus(root)
+		t := base
+		t.ready = nil
+		t.prompt = textPrompt(strings.Join(failures, "\n\n") + "\n\nPlease fix the problems above.")
+		next, err := s.runTurn(&t)
+		if err != nil {
+			return res, err
+		}
+		s.persistConversation(base.convID)
+
+		res = next
+		edited = append(edited, next.Edited...)
+		changed = len(next.Edited) > 0 || workspaceStatus(root) != before
+	}
+}
+
+// runHook runs one hook command and records it as a tool call of the turn's
+// agent. Returns the (possibly truncated) output and an error when the
+// command failed.
+func (s *Server) runHook(base turn, hook *config.HookConfig, root string) (string, error) {
+	tool := &conversation.ToolCallInfo{
+		ToolCallID: fmt.Sprintf("hook-%d", time.Now().UnixNano()),
+		ToolName:   "Hook",
+		Kind:       "execute",
+		Title:      "Hook: " + hook.Label(),
+		Status:     "pending",
+		Input:      hook.Command,
+	}
+	sendTool := func(update string) {
+		base.sendEvent("tool_call", map[string]any{
+			"toolCallId":    tool.ToolCallID,
+			"toolName":      tool.ToolName,
+			"kind":          tool.Kind,
+			"title":         tool.Title,
+			"status":        tool.Status,
+			"input":         tool.Input,
+			"output":        tool.Output,
+			"error":         tool.Error,
+			"sessionUpdate": update,
+		})
+	}
+	sendTool("tool_call")
+
+	timeout := defaultHookTimeout
+	if hook.TimeoutSeconds > 0 {
+		timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+	}
+	var env map[string]string
+	if base.project != nil {
+		env = base.project.Env
+	}
+	output, err := runShell(root, hook.Command, env, timeout)
+
+	tool.Status = "completed"
+	tool.Output = output
+	if err != nil {
+		tool.Status = "error"
+		tool.Error = err.Error()
+	}
+	sendTool("tool_call_update")
+	s.conversations.AddToolCall(base.convID, tool, base.agentID)
+	return output, err
+}
+
+// runShell runs command with the system shell in dir and returns its
+// combined output
+func runShell(dir, command string, env map[string]string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	sysutil.HideWindow(cmd)
+	// Killing only the shell would leave what it started running, holding
+	// the output pipe open; kill the whole group and stop waiting for the
+	// pipe shortly after
+	sysutil.SetProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return sysutil.KillTree(cmd.Process.Pid)
+	}
+	cmd.WaitDelay = hookWaitDelay
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	output := out.String()
+	if len(output) > maxHookOutput {
+		output = "...\n" + output[len(output)-maxHookOutput:]
+	}
+	return output, err
+}
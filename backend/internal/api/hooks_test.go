@@ -0,0 +1,33 @@
+package api
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunShell(t *testing.T) {
+	output, err := runShell(t.TempDir(), "echo $HOOK_VAR", map[string]string{"HOOK_VAR": "from env"}, time.Minute)
+	if err != nil || strings.TrimSpace(output) != "from env" {
+		t.Fatalf("output %q, error %v", output, err)
+	}
+	if _, err := runShell(t.TempDir(), "exit 3", nil, time.Minute); err == nil {
+		t.Fatal("failing command reported no error")
+	}
+}
+
+func TestRunShellTimeoutKillsChildren(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	// The background sleep keeps the output pipe open after sh is gone
+	start := time.Now()
+	_, err := runShell(t.TempDir(), "sleep 30 & sleep 30", nil, 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("want a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("hook returned after %s", elapsed)
+	}
+}
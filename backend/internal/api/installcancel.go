@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sync"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// installJobs tracks running setup installs so they can be canceled
+type installJobs struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	aborted bool // Everything was canceled, items not started yet are skipped
+}
+
+// installKey identifies a setup item, e.g. "acp:1" or "environment"
+func installKey(kind string, index int) string {
+	if kind == "environment" {
+		return kind
+	}
+	return fmt.Sprintf("%s:%d", kind, index)
+}
+
+// reset forgets a previous cancel-all before a new install run
+func (j *installJobs) reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.aborted = false
+}
+
+// begin returns the context the install of key runs under. It is already
+// canceled when the whole run was aborted.
+func (j *installJobs) begin(key string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.aborted {
+		cancel()
+		return ctx
+	}
+	if j.cancels == nil {
+		j.cancels = make(map[string]context.CancelFunc)
+	}
+	j.cancels[key] = cancel
+	return ctx
+}
+
+// end releases the install of key once it finished
+func (j *installJobs) end(key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if cancel, ok := j.cancels[key]; ok {
+		cancel()
+		delete(j.cancels, key)
+	}
+}
+
+// cancel stops the running install of key, reporting whether there was one
+func (j *installJobs) cancel(key string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cancel, ok := j.cancels[key]
+	if ok {
+		cancel()
+		delete(j.cancels, key)
+	}
+	return ok
+}
+
+// cancelAll stops every running install and skips the remaining items
+func (j *installJobs) cancelAll() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.aborted = true
+	n := len(j.cancels)
+	for key, cancel := range j.cancels {
+		cancel()
+		delete(j.cancels, key)
+	}
+	return n
+}
+
+// installFailure returns the status and message for a failed install,
+// telling a cancel apart from an error
+func installFailure(ctx context.Context, err error) (string, string) {
+	if ctx.Err() != nil {
+		return "canceled", "Canceled"
+	}
+	return "error", err.Error()
+}
+
+// installCommand creates an install command in its own process group, so
+// canceling ctx kills npm together with the processes it spawned
+func installCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	sysutil.HideWindow(cmd)
+	sysutil.SetProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return sysutil.KillTree(cmd.Process.Pid)
+	}
+	return cmd
+}
+
+// handleSetupInstallCancel cancels one running install, or all of them when
+// no item is given. The install stream reports the item as "canceled".
+func (s *Server) handleSetupInstallCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Type  string `json:"type"` // environment, agent or acp
+		Index *int   `json:"index"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	if req.Type == "" {
+		n := s.installs.cancelAll()
+		setupLog.Info("canceled all installs", "running", n)
+		writeJSON(w, map[string]any{"canceled": n})
+		return
+	}
+
+	index := 0
+	if req.Index != nil {
+		index = *req.Index
+	}
+	key := installKey(req.Type, index)
+	if !s.installs.cancel(key) {
+		writeError(w, "no running install for "+key, http.StatusNotFound)
+		return
+	}
+	setupLog.Info("canceled install", "package", key)
+	writeJSON(w, map[string]any{"canceled": 1})
+}
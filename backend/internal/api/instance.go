@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Another acpone, typically the desktop app and the CLI started together,
+// may already own the port. It can be opened instead, or asked to shut down
+// so this instance takes over.
+
+const (
+	// probeTimeout bounds the requests identifying a port's occupant
+	probeTimeout = 2 * time.Second
+	// takeoverWait is how long the previous instance gets to free the port,
+	// beyond its own shutdownGrace
+	takeoverWait = 15 * time.Second
+)
+
+// Occupant is the server found on an address this instance wants
+type Occupant struct {
+	URL     string `json:"url"`     // e.g. http://localhost:3000
+	ACPone  bool   `json:"acpone"`  // An acpone server answers there
+	Version string `json:"version"` // Its version, unless it requires a token
+}
+
+// PortInUse reports whether addr can't be listened on
+func PortInUse(addr string) bool {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return true
+	}
+	l.Close()
+	return false
+}
+
+// LocalURL returns the URL of a listen address as seen from this machine
+func LocalURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// ProbeAddr identifies what listens on addr, nil when the port is free
+func ProbeAddr(addr string) *Occupant {
+	if !PortInUse(addr) {
+		return nil
+	}
+	o := &Occupant{URL: LocalURL(addr)}
+	client := &http.Client{Timeout: probeTimeout}
+
+	var v versionInfo
+	if getJSON(client, o.URL+"/api/version", &v) && v.Client != "" {
+		o.ACPone, o.Version = true, v.Version
+		return o
+	}
+	// With a token required /api/version is refused, /api/auth/status isn't
+	var auth struct {
+		Required *bool `json:"required"`
+	}
+	if getJSON(client, o.URL+"/api/auth/status", &auth) && auth.Required != nil {
+		o.ACPone = true
+	}
+	return o
+}
+
+// getJSON decodes the response of a successful GET
+func getJSON(client *http.Client, url string, v any) bool {
+	resp, err := client.Get(url)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(v) == nil
+}
+
+// Takeover asks the acpone server at url (its admin URL when it has one) to
+// shut down, sending token when it requires one, and waits until addr is free
+func Takeover(url, token, addr string) error {
+	req, err := http.NewRequest("POST", url+"/api/shutdown", nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := (&http.Client{Timeout: probeTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return fmt.Errorf("acpone at %s refused to shut down: %s", url, body.Error)
+	}
+
+	deadline := time.Now().Add(takeoverWait)
+	for PortInUse(addr) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acpone at %s didn't free the port within %s", url, takeoverWait)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil
+}
+
+// ShutdownRequested is closed when another instance takes over the port.
+// The server has answered by then; whoever runs it shuts it down.
+func (s *Server) ShutdownRequested() <-chan struct{} {
+	return s.shutdownReq
+}
+
+// handleShutdown serves POST /api/shutdown for an instance on the same
+// machine taking over the port
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Browsers send Origin, so pages open on this machine can't use it
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() || r.Header.Get("Origin") != "" {
+		writeError(w, "Only local clients may shut the server down", http.StatusForbidden)
+		return
+	}
+	logger.Info("shutdown requested by another instance")
+	writeJSON(w, map[string]any{"stopping": true})
+	s.shutdownOnce.Do(func() { close(s.shutdownReq) })
+}
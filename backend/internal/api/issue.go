@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/github"
+)
+
+// handleIssueStart fetches a GitHub issue, opens a conversation in the
+// workspace cloned from its repository and sends the issue as the first
+// prompt. The turn runs in the background; its events go to the bus.
+func (s *Server) handleIssueStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Issue       string `json:"issue"`       // Issue URL, owner/repo#123 or #123
+		WorkspaceID string `json:"workspaceId"` // Defaults to the workspace whose origin is the issue's repository
+		Agent       string `json:"agent"`       // Defaults to the workspace's default agent
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	ref, err := github.ParseIssue(req.Issue)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Agent != "" && !s.currentRouter().HasAgent(req.Agent) {
+		writeError(w, "Agent not found", http.StatusBadRequest)
+		return
+	}
+
+	workspaceID := req.WorkspaceID
+	if workspaceID == "" && ref.Owner != "" {
+		workspaceID = s.workspaceForRepo(ref.Repository())
+		if workspaceID == "" {
+			writeError(w, "No workspace is a clone of "+ref.Repository()+", pass workspaceId", http.StatusBadRequest)
+			return
+		}
+	}
+	if workspaceID == "" {
+		workspaceID = s.workspaceStore.Default()
+	}
+	if ref.Owner == "" {
+		// "#123" refers to the workspace's own repository
+		remote, _ := git(s.resolveWorkspacePath(workspaceID), "remote", "get-url", "origin")
+		repo := github.RepoFromRemote(remote)
+		if repo == "" {
+			writeError(w, "Workspace has no GitHub origin, use the issue URL", http.StatusBadRequest)
+			return
+		}
+		ref.Owner, ref.Repo, _ = strings.Cut(repo, "/")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	issue, err := github.FetchIssue(ctx, ref)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	convID, _ := s.getOrCreateConversation(chatRequest{WorkspaceID: workspaceID})
+	if req.Agent != "" {
+		s.conversations.SetActiveAgent(convID, req.Agent)
+	}
+	s.persistConversation(convID)
+
+	stream, closeStream := s.newEventStream(chatTopic, func(string, any) {})
+	go func() {
+		defer closeStream()
+		s.runChat(chatRequest{
+			Message:        issue.Prompt(),
+			ConversationID: convID,
+			WorkspaceID:    workspaceID,
+		}, stream)
+	}()
+	logger.Info("started issue", "issue", ref, "conversation", convID)
+
+	writeJSON(w, map[string]any{
+		"conversationId": convID,
+		"workspaceId":    workspaceID,
+		"issue": map[string]any{
+			"ref":   ref.String(),
+			"title": issue.Title,
+			"url":   issue.URL,
+		},
+	})
+}
+
+// workspaceForRepo returns the workspace whose origin remote is the GitHub
+// repository "owner/repo", or ""
+func (s *Server) workspaceForRepo(repo string) string {
+	for _, ws := range s.workspaceStore.List() {
+		remote, err := git(s.resolveWorkspacePath(ws.ID), "remote", "get-url", "origin")
+		if err == nil && strings.EqualFold(github.RepoFromRemote(remote), repo) {
+			return ws.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+// turnLimits returns the limits of a conversation's turns: its own where
+// set, the configured ones otherwise, with negative values turned off
+func (s *Server) turnLimits(convID string) conversation.Limits {
+	c := s.config.Limits
+	limits := conversation.Limits{
+		MaxRepeats: c.Repeats(),
+		OnRepeat:   c.RepeatAction(),
+	}
+	if c != nil {
+		limits.MaxChars = c.MaxChars
+		limits.MaxToolCalls = c.MaxToolCalls
+		limits.MaxDurationMs = c.MaxDurationMs
+	}
+	if own := s.conversations.Limits(convID); own != nil {
+		pick := func(configured, override int) int {
+			if override != 0 {
+				return max(override, 0)
+			}
+			return configured
+		}
+		limits.MaxChars = pick(limits.MaxChars, own.MaxChars)
+		limits.MaxToolCalls = pick(limits.MaxToolCalls, own.MaxToolCalls)
+		limits.MaxDurationMs = pick(limits.MaxDurationMs, own.MaxDurationMs)
+		limits.MaxRepeats = pick(limits.MaxRepeats, own.MaxRepeats)
+		if own.OnRepeat != "" {
+			limits.OnRepeat = own.OnRepeat
+		}
+	}
+	return limits
+}
+
+// turnGuard enforces a turn's limits on the agent's streamed output. The
+// first limit exceeded stops the turn once; notifications before start, such
+// as those of session/new, are not counted.
+type turnGuard struct {
+	limits conversation.Limits
+
+	mu      sync.Mutex
+	stop    func(reason string)
+	warn    func(message string)
+	timer   *time.Timer
+	chars   int
+	toolIDs map[string]bool
+	reason  string
+
+	// Repeated tool calls: kinds of calls whose input is still unknown, and
+	// the last call's kind and input with how often it came in a row
+	pending  map[string]string
+	lastCall string
+	repeats  int
+}
+
+// start begins counting, calling stop when a limit is exceeded and warn
+// when the agent repeats a tool call without being stopped for it
+func (g *turnGuard) start(stop func(reason string), warn func(message string)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stop, g.warn = stop, warn
+	g.toolIDs = make(map[string]bool)
+	g.pending = make(map[string]string)
+	if g.limits.MaxDurationMs > 0 {
+		d := time.Duration(g.limits.MaxDurationMs) * time.Millisecond
+		g.timer = time.AfterFunc(d, func() {
+			g.exceed(fmt.Sprintf("ran longer than %s", d))
+		})
+	}
+}
+
+// close stops the wall-clock limit once the turn is over
+func (g *turnGuard) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.stop = nil
+}
+
+// reset discards the counts of a failed attempt that is retried
+func (g *turnGuard) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.chars = 0
+	clear(g.toolIDs)
+	clear(g.pending)
+	g.lastCall, g.repeats = "", 0
+}
+
+// observe counts the assistant text and tool calls of a session/update
+func (g *turnGuard) observe(msg *jsonrpc.Message) {
+	if msg.Method != "session/update" {
+		return
+	}
+	var params struct {
+		Update sessionUpdate `json:"update"`
+	}
+	if err := msg.ParseParams(&params); err != nil {
+		return
+	}
+	update := params.Update
+
+	g.mu.Lock()
+	if g.stop == nil || g.reason != "" {
+		g.mu.Unlock()
+		return
+	}
+	reason, warning := "", ""
+	switch update.SessionUpdate {
+	case "agent_message_chunk", "agent_thought_chunk":
+		g.chars += utf8.RuneCountInString(extractTextContent(update.Content))
+		if g.limits.MaxChars > 0 && g.chars > g.limits.MaxChars {
+			reason = fmt.Sprintf("wrote more than %d characters", g.limits.MaxChars)
+		}
+	case "tool_call", "tool_call_update":
+		if update.SessionUpdate == "tool_call" && update.ToolCallID != "" && !g.toolIDs[update.ToolCallID] {
+			g.toolIDs[update.ToolCallID] = true
+			g.pending[update.ToolCallID] = update.Kind
+		}
+		if g.limits.MaxToolCalls > 0 && len(g.toolIDs) > g.limits.MaxToolCalls {
+			reason = fmt.Sprintf("made more than %d tool calls", g.limits.MaxToolCalls)
+		} else if n, kind := g.repeated(update); n > 0 {
+			what := fmt.Sprintf("repeated the same %s call %d times in a row", kind, n)
+			if g.limits.OnRepeat == config.RepeatCancel {
+				reason = what
+			} else {
+				warning = "The agent " + what
+			}
+		}
+	}
+	warn := g.warn
+	g.mu.Unlock()
+
+	if reason != "" {
+		g.exceed(reason)
+	} else if warning != "" {
+		warn(warning)
+	}
+}
+
+// repeated tracks a tool call once its input is known, returning the number
+// of identical calls in a row and their kind when it reaches the repeat
+// limit. Agents may send the input with the call or in a later update.
+func (g *turnGuard) repeated(update sessionUpdate) (int, string) {
+	kind, ok := g.pending[update.ToolCallID]
+	if !ok || len(update.RawInput) == 0 {
+		return 0, ""
+	}
+	delete(g.pending, update.ToolCallID)
+	if kind == "" {
+		kind = update.Kind
+	}
+	input, err := json.Marshal(update.RawInput)
+	if err != nil {
+		return 0, ""
+	}
+	call := kind + " " + string(input)
+	if call != g.lastCall {
+		g.lastCall, g.repeats = call, 0
+	}
+	g.repeats++
+	if g.limits.MaxRepeats == 0 || g.repeats != g.limits.MaxRepeats {
+		return 0, ""
+	}
+	if kind == "" {
+		kind = "tool"
+	}
+	return g.repeats, kind
+}
+
+// exceed records the first limit exceeded and stops the turn
+func (g *turnGuard) exceed(what string) {
+	g.mu.Lock()
+	if g.stop == nil || g.reason != "" {
+		g.mu.Unlock()
+		return
+	}
+	g.reason = "Turn stopped: the agent " + what
+	stop, reason := g.stop, g.reason
+	g.mu.Unlock()
+
+	stop(reason)
+}
+
+// exceeded returns why the turn was stopped, or "" if it wasn't
+func (g *turnGuard) exceeded() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.reason
+}
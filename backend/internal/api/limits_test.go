@@ -0,0 +1,347 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+// updateMsg builds a session/update notification
+func updateMsg(update map[string]any) *jsonrpc.Message {
+	params, _ := json.Marshal(map[string]any{"sessionId": "s1", "update": update})
+	return &jsonrpc.Message{JSONRPC: jsonrpc.Version, Method: "session/update", Params: params}
+}
+
+func messageChunk(text string) *jsonrpc.Message {
+	return updateMsg(map[string]any{
+		"sessionUpdate": "agent_message_chunk",
+		"content":       map[string]string{"type": "text", "text": text},
+	})
+}
+
+func toolCall(id, kind string, input map[string]any) *jsonrpc.Message {
+	update := map[string]any{"sessionUpdate": "tool_call", "toolCallId": id, "kind": kind}
+	if input != nil {
+		update["rawInput"] = input
+	}
+	return updateMsg(update)
+}
+
+// startGuard starts a guard recording the reasons it stops the turn for and
+// the warnings it sends
+func startGuard(limits conversation.Limits) (g *turnGuard, stops, warnings func() []string) {
+	var mu sync.Mutex
+	var stopped, warned []string
+	g = &turnGuard{limits: limits}
+	g.start(func(reason string) {
+		mu.Lock()
+		stopped = append(stopped, reason)
+		mu.Unlock()
+	}, func(message string) {
+		mu.Lock()
+		warned = append(warned, message)
+		mu.Unlock()
+	})
+	get := func(s *[]string) func() []string {
+		return func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), *s...)
+		}
+	}
+	return g, get(&stopped), get(&warned)
+}
+
+func TestTurnLimits(t *testing.T) {
+	s, _ := newTestServer(t, "claude", sessionCountingAgent(new(atomic.Int32)))
+	s.conversations.Create("c1", "claude", "default")
+
+	if got := s.turnLimits("c1"); got != (conversation.Limits{MaxRepeats: config.DefaultRepeats, OnRepeat: config.RepeatWarn}) {
+		t.Errorf("unconfigured limits %+v", got)
+	}
+
+	s.config.Limits = &config.LimitsConfig{MaxChars: 1000, MaxToolCalls: 20, MaxDurationMs: 60000, OnRepeat: config.RepeatCancel}
+	s.conversations.SetLimits("c1", &conversation.Limits{MaxChars: 50, MaxToolCalls: -1})
+	want := conversation.Limits{
+		MaxChars:      50,    // Overridden
+		MaxToolCalls:  0,     // Turned off by the conversation
+		MaxDurationMs: 60000, // Configured
+		MaxRepeats:    config.DefaultRepeats,
+		OnRepeat:      config.RepeatCancel,
+	}
+	if got := s.turnLimits("c1"); got != want {
+		t.Errorf("turnLimits = %+v, want %+v", got, want)
+	}
+}
+
+func TestTurnGuardStops(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits conversation.Limits
+		msgs   []*jsonrpc.Message
+		want   string
+	}{
+		{"chars", conversation.Limits{MaxChars: 10}, []*jsonrpc.Message{messageChunk("12345"), messageChunk("678901")},
+			"Turn stopped: the agent wrote more than 10 characters"},
+		{"chars counted as runes", conversation.Limits{MaxChars: 4}, []*jsonrpc.Message{messageChunk("日本語です")},
+			"Turn stopped: the agent wrote more than 4 characters"},
+		{"chars within limit", conversation.Limits{MaxChars: 10}, []*jsonrpc.Message{messageChunk("1234567890")}, ""},
+		{"tool calls", conversation.Limits{MaxToolCalls: 2}, []*jsonrpc.Message{
+			toolCall("t1", "read", nil), toolCall("t2", "read", nil), toolCall("t3", "read", nil)},
+			"Turn stopped: the agent made more than 2 tool calls"},
+		{"tool call updates not counted", conversation.Limits{MaxToolCalls: 1}, []*jsonrpc.Message{
+			toolCall("t1", "read", nil),
+			updateMsg(map[string]any{"sessionUpdate": "tool_call_update", "toolCallId": "t1", "status": "completed"}),
+			toolCall("t1", "read", nil)}, ""},
+		{"no limits", conversation.Limits{}, []*jsonrpc.Message{messageChunk(strings.Repeat("x", 10000))}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, stops, _ := startGuard(tt.limits)
+			defer g.close()
+			for _, msg := range tt.msgs {
+				g.observe(msg)
+			}
+
+			got := stops()
+			if tt.want == "" {
+				if len(got) > 0 {
+					t.Fatalf("stopped: %v", got)
+				}
+				return
+			}
+			g.observe(toolCall("t9", "read", nil)) // Stops only once
+			g.observe(messageChunk(strings.Repeat("x", 100)))
+			if got = stops(); len(got) != 1 || got[0] != tt.want || g.exceeded() != tt.want {
+				t.Fatalf("stopped %v (exceeded %q), want once for %q", got, g.exceeded(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTurnGuardDuration(t *testing.T) {
+	g, stops, _ := startGuard(conversation.Limits{MaxDurationMs: 20})
+	defer g.close()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(stops()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := stops(); len(got) != 1 || !strings.Contains(got[0], "ran longer than 20ms") {
+		t.Fatalf("stopped %v, want the time limit", got)
+	}
+
+	// A closed guard neither times out nor counts
+	g, stops, _ = startGuard(conversation.Limits{MaxDurationMs: 20, MaxChars: 1})
+	g.close()
+	g.observe(messageChunk("too long"))
+	time.Sleep(50 * time.Millisecond)
+	if got := stops(); len(got) > 0 {
+		t.Fatalf("closed guard stopped the turn: %v", got)
+	}
+}
+
+func TestTurnGuardReset(t *testing.T) {
+	g, stops, _ := startGuard(conversation.Limits{MaxChars: 10, MaxToolCalls: 1})
+	defer g.close()
+	g.observe(messageChunk("123456789"))
+	g.observe(toolCall("t1", "read", nil))
+	g.reset() // The attempt failed and is retried
+	g.observe(messageChunk("123456789"))
+	g.observe(toolCall("t2", "read", nil))
+	if got := stops(); len(got) > 0 {
+		t.Fatalf("counts of the failed attempt carried over: %v", got)
+	}
+}
+
+// streamingAgent writes chunks of text until the prompt is canceled
+func streamingAgent() agent.ServeFunc {
+	return func(r io.Reader, w io.Writer) error {
+		canceled := make(chan struct{})
+		var once sync.Once
+		return mockagent.NewConn(w, func(c *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+			switch msg.Method {
+			case "initialize":
+				return map[string]any{"protocolVersion": 1, "agentCapabilities": map[string]any{}}, nil
+			case "session/new":
+				return map[string]any{"sessionId": "s1"}, nil
+			case "session/prompt":
+				for {
+					select {
+					case <-canceled:
+						return map[string]any{"stopReason": "cancelled"}, nil
+					case <-time.After(5 * time.Millisecond):
+					}
+					c.Update("s1", map[string]any{
+						"sessionUpdate": "agent_message_chunk",
+						"content":       map[string]string{"type": "text", "text": "0123456789"},
+					})
+				}
+			default:
+				return map[string]any{}, nil
+			}
+		}, func(c *mockagent.Conn, msg *jsonrpc.Message) {
+			if msg.Method == "session/cancel" {
+				once.Do(func() { close(canceled) })
+			}
+		}).Serve(r)
+	}
+}
+
+// patchSession sends a PATCH /api/sessions/:id update
+func patchSession(t *testing.T, hs *httptest.Server, convID string, body any) {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest("PATCH", hs.URL+"/api/sessions/"+convID, bytes.NewReader(data))
+	resp, err := hs.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH session: status %d", resp.StatusCode)
+	}
+}
+
+func TestTurnStoppedAtLimit(t *testing.T) {
+	s, hs := newTestServer(t, "claude", streamingAgent())
+	convID := newConversation(t, hs)
+	patchSession(t, hs, convID, map[string]any{"limits": map[string]any{"maxChars": 25}})
+
+	stream := chatMessage(t, hs, convID, "count forever")
+	if !strings.Contains(stream, `"truncated":"Turn stopped: the agent wrote more than 25 characters"`) {
+		t.Errorf("stream does not report the truncated turn:\n%s", stream)
+	}
+	conv := s.conversations.Snapshot(convID)
+	last := conv.Messages[len(conv.Messages)-1]
+	if last.Kind != "truncated" || !strings.Contains(last.Content, "more than 25 characters") {
+		t.Errorf("last message %+v, want the truncation note", last)
+	}
+}
+
+func TestTurnGuardRepeats(t *testing.T) {
+	ls := map[string]any{"command": "ls"}
+	inputLater := func(id string, input map[string]any) []*jsonrpc.Message {
+		return []*jsonrpc.Message{
+			toolCall(id, "execute", nil),
+			updateMsg(map[string]any{"sessionUpdate": "tool_call_update", "toolCallId": id, "rawInput": input}),
+		}
+	}
+	repeat := func(n int, call func(id string) []*jsonrpc.Message) []*jsonrpc.Message {
+		var msgs []*jsonrpc.Message
+		for i := 0; i < n; i++ {
+			msgs = append(msgs, call(fmt.Sprintf("t%d", i))...)
+		}
+		return msgs
+	}
+	withInput := func(id string) []*jsonrpc.Message { return []*jsonrpc.Message{toolCall(id, "execute", ls)} }
+
+	tests := []struct {
+		name   string
+		limits conversation.Limits
+		msgs   []*jsonrpc.Message
+		warns  int
+		stop   string
+	}{
+		{"warn", conversation.Limits{MaxRepeats: 3, OnRepeat: config.RepeatWarn}, repeat(3, withInput), 1, ""},
+		{"warned once per run", conversation.Limits{MaxRepeats: 3, OnRepeat: config.RepeatWarn}, repeat(5, withInput), 1, ""},
+		{"input in a later update", conversation.Limits{MaxRepeats: 3, OnRepeat: config.RepeatWarn},
+			repeat(3, func(id string) []*jsonrpc.Message { return inputLater(id, ls) }), 1, ""},
+		{"cancel", conversation.Limits{MaxRepeats: 3, OnRepeat: config.RepeatCancel}, repeat(3, withInput), 0,
+			"Turn stopped: the agent repeated the same execute call 3 times in a row"},
+		{"below the limit", conversation.Limits{MaxRepeats: 3, OnRepeat: config.RepeatCancel}, repeat(2, withInput), 0, ""},
+		{"different input breaks the run", conversation.Limits{MaxRepeats: 3, OnRepeat: config.RepeatCancel}, append(append(
+			repeat(2, withInput),
+			toolCall("other", "execute", map[string]any{"command": "pwd"})),
+			toolCall("again", "execute", ls)), 0, ""},
+		{"same call updated", conversation.Limits{MaxRepeats: 2, OnRepeat: config.RepeatCancel}, append(
+			withInput("t1"),
+			updateMsg(map[string]any{"sessionUpdate": "tool_call_update", "toolCallId": "t1", "rawInput": ls})), 0, ""},
+		{"off", conversation.Limits{OnRepeat: config.RepeatCancel}, repeat(10, withInput), 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, stops, warnings := startGuard(tt.limits)
+			defer g.close()
+			for _, msg := range tt.msgs {
+				g.observe(msg)
+			}
+
+			if got := warnings(); len(got) != tt.warns {
+				t.Errorf("warnings %q, want %d", got, tt.warns)
+			} else if tt.warns > 0 && got[0] != "The agent repeated the same execute call 3 times in a row" {
+				t.Errorf("warning %q", got[0])
+			}
+			got := stops()
+			if tt.stop == "" && len(got) > 0 || tt.stop != "" && (len(got) != 1 || got[0] != tt.stop) {
+				t.Errorf("stopped %q, want %q", got, tt.stop)
+			}
+		})
+	}
+}
+
+// repeatingAgent runs the same tool call on every prompt until canceled
+func repeatingAgent() agent.ServeFunc {
+	return func(r io.Reader, w io.Writer) error {
+		canceled := make(chan struct{})
+		var once sync.Once
+		return mockagent.NewConn(w, func(c *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+			switch msg.Method {
+			case "initialize":
+				return map[string]any{"protocolVersion": 1, "agentCapabilities": map[string]any{}}, nil
+			case "session/new":
+				return map[string]any{"sessionId": "s1"}, nil
+			case "session/prompt":
+				for i := 0; ; i++ {
+					select {
+					case <-canceled:
+						return map[string]any{"stopReason": "cancelled"}, nil
+					case <-time.After(5 * time.Millisecond):
+					}
+					c.Update("s1", map[string]any{
+						"sessionUpdate": "tool_call",
+						"toolCallId":    fmt.Sprintf("t%d", i),
+						"kind":          "read",
+						"title":         "Read main.go",
+						"rawInput":      map[string]any{"path": "main.go"},
+					})
+				}
+			default:
+				return map[string]any{}, nil
+			}
+		}, func(c *mockagent.Conn, msg *jsonrpc.Message) {
+			if msg.Method == "session/cancel" {
+				once.Do(func() { close(canceled) })
+			}
+		}).Serve(r)
+	}
+}
+
+func TestRepeatedToolCallsCancelTurn(t *testing.T) {
+	s, hs := newTestServer(t, "claude", repeatingAgent())
+	convID := newConversation(t, hs)
+	patchSession(t, hs, convID, map[string]any{"limits": map[string]any{"maxRepeats": 4, "onRepeat": "cancel"}})
+
+	stream := chatMessage(t, hs, convID, "read main.go")
+	want := "Turn stopped: the agent repeated the same read call 4 times in a row"
+	if !strings.Contains(stream, `"truncated":"`+want+`"`) {
+		t.Errorf("stream does not report the canceled turn:\n%s", stream)
+	}
+	conv := s.conversations.Snapshot(convID)
+	if last := conv.Messages[len(conv.Messages)-1]; last.Kind != "truncated" || last.Content != want {
+		t.Errorf("last message %+v, want the truncation note", last)
+	}
+}
@@ -0,0 +1,17 @@
+package api
+
+import "github.com/daodao97/acpone/internal/logging"
+
+// Loggers of the server's parts, each record tagged with its component
+var (
+	logger      = logging.Component("server") // Startup, chats, agents and pausing
+	setupLog    = logging.Component("setup")  // Node.js and agent installs, logins
+	catalogLog  = logging.Component("catalog")
+	versionLog  = logging.Component("versions")
+	frontendLog = logging.Component("frontend")
+	turnLog     = logging.Component("turn") // Limits and retries
+	scanLog     = logging.Component("scan")
+	memoryLog   = logging.Component("memory")
+	slackLog    = logging.Component("slack")
+	storageLog  = logging.Component("storage") // Sync, uploads, trash, transcripts, event log
+)
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+const (
+	// memoryFile holds a workspace's memory, relative to the workspace root
+	memoryFile = ".acpone/memory.md"
+	// memoryNoChanges is the reply of an agent that found nothing to keep
+	memoryNoChanges = "NO_CHANGES"
+	// memoryTranscriptChars bounds the conversation sent for extraction,
+	// keeping its end
+	memoryTranscriptChars = 40000
+)
+
+// defaultMemoryPrompt asks the agent for the updated memory of a workspace
+const defaultMemoryPrompt = `You maintain the long-term memory of a software project. Below are the current memory and a conversation that took place in the project. Extract durable facts and decisions worth knowing in future conversations: conventions, architecture choices, commands, known pitfalls, preferences stated by the user. Skip anything specific to this one task or already in the memory; drop memory entries the conversation proved wrong.
+
+Reply with the complete updated memory as a Markdown bullet list, one fact per bullet, and nothing else. Reply ` + memoryNoChanges + ` if the memory needs no changes. Do not use any tools or modify any files.
+
+## Current memory
+{{memory}}
+
+## Conversation
+{{conversation}}
+`
+
+// memoryJobs schedules memory extraction of idle conversations
+type memoryJobs struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer // By conversation
+	// Messages of each conversation already extracted
+	extracted map[string]int
+	// run allows one extraction at a time, as they rewrite the same files
+	run sync.Mutex
+}
+
+// schedule (re)starts the idle timer of a conversation
+func (j *memoryJobs) schedule(convID string, idle time.Duration, fn func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.timers == nil {
+		j.timers = make(map[string]*time.Timer)
+	}
+	if t := j.timers[convID]; t != nil {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(idle, func() {
+		j.mu.Lock()
+		if j.timers[convID] == t {
+			delete(j.timers, convID)
+		}
+		j.mu.Unlock()
+		fn()
+	})
+	j.timers[convID] = t
+}
+
+// since returns how many messages of a conversation were extracted
+func (j *memoryJobs) since(convID string) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.extracted[convID]
+}
+
+func (j *memoryJobs) setExtracted(convID string, n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.extracted == nil {
+		j.extracted = make(map[string]int)
+	}
+	j.extracted[convID] = n
+}
+
+// memoryPath returns the memory file of a workspace root
+func memoryPath(root string) string {
+	return filepath.Join(root, filepath.FromSlash(memoryFile))
+}
+
+// readMemory returns a workspace's memory, "" when it has none
+func readMemory(root string) string {
+	data, err := os.ReadFile(memoryPath(root))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeMemory replaces a workspace's memory
+func writeMemory(root, content string) error {
+	path := memoryPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.TrimSpace(content)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// memoryContext is the memory block included in new sessions
+func memoryContext(root string) string {
+	memory := readMemory(root)
+	if memory == "" {
+		return ""
+	}
+	return "## Workspace memory\nFacts and decisions from earlier conversations in this workspace (" + memoryFile + "):\n\n" + memory
+}
+
+// scheduleMemory extracts the conversation's memory once it has been idle
+// for the workspace's configured time
+func (s *Server) scheduleMemory(convID string, project *config.ProjectConfig) {
+	if project == nil || project.Memory == nil {
+		return
+	}
+	s.memory.schedule(convID, project.Memory.Idle(), func() {
+		// A turn started since; its end schedules again
+		for _, t := range s.turns.list() {
+			if t.ConversationID == convID {
+				return
+			}
+		}
+		if _, err := s.extractMemory(convID, ""); err != nil {
+			memoryLog.Warn("memory update failed", "conversation", convID, "error", err)
+		}
+	})
+}
+
+// extractMemory has an agent merge the conversation's messages since the
+// last extraction into the workspace memory, returning the new memory. A
+// non-empty workspaceID must be the conversation's workspace.
+func (s *Server) extractMemory(convID, workspaceID string) (string, error) {
+	stored, err := s.sessionStore.Load(convID)
+	if err != nil || (workspaceID != "" && stored.WorkspaceID != workspaceID) {
+		return "", errors.New("session not found in this workspace")
+	}
+	project := s.projectConfig(stored.WorkspaceID)
+	if project == nil || project.Memory == nil {
+		return "", errors.New("memory is not enabled for this workspace")
+	}
+	s.memory.run.Lock()
+	defer s.memory.run.Unlock()
+	root := s.resolveWorkspacePath(stored.WorkspaceID)
+	memory := readMemory(root)
+
+	from := s.memory.since(convID)
+	if from > len(stored.Messages) {
+		from = 0
+	}
+	transcript := memoryTranscript(stored.Messages[from:])
+	if transcript == "" {
+		return memory, nil
+	}
+
+	agentID := project.Memory.Agent
+	if agentID == "" {
+		agentID = stored.ActiveAgent
+	}
+	current := memory
+	if current == "" {
+		current = "(empty)"
+	}
+	reply, err := s.askAgent(agentID, root, project, expandPrompt(defaultMemoryPrompt, project.Memory.Prompt, map[string]string{
+		"{{memory}}":       current,
+		"{{conversation}}": transcript,
+	}))
+	if err != nil {
+		return "", err
+	}
+	s.memory.setExtracted(convID, len(stored.Messages))
+
+	reply = stripFence(strings.TrimSpace(reply))
+	if reply == "" || strings.Contains(reply, memoryNoChanges) {
+		return memory, nil
+	}
+	if limit := project.Memory.Limit(); len([]rune(reply)) > limit {
+		reply = string([]rune(reply)[:limit])
+		// Don't keep a fact cut in half
+		if i := strings.LastIndex(reply, "\n"); i > 0 {
+			reply = reply[:i]
+		}
+	}
+	if err := writeMemory(root, reply); err != nil {
+		return "", err
+	}
+	memoryLog.Info("updated memory", "path", memoryPath(root), "conversation", convID)
+	return readMemory(root), nil
+}
+
+// memoryTranscript renders messages for extraction: user and assistant text
+// and tool call titles, without tool output
+func memoryTranscript(messages []conversation.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		switch {
+		case msg.ToolCall != nil:
+			fmt.Fprintf(&b, "[Tool] %s\n\n", msg.ToolCall.Title)
+		case msg.Content == "" || msg.Kind == "truncated":
+		case msg.Role == "user":
+			fmt.Fprintf(&b, "User: %s\n\n", msg.Content)
+		default:
+			fmt.Fprintf(&b, "Assistant: %s\n\n", msg.Content)
+		}
+	}
+	text := strings.TrimSpace(b.String())
+	if len(text) > memoryTranscriptChars {
+		text = "…" + text[len(text)-memoryTranscriptChars:]
+	}
+	return text
+}
+
+// stripFence removes a code fence an agent wrapped its reply in
+func stripFence(s string) string {
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	if i := strings.Index(s, "\n"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
+
+// askAgent prompts an agent in a session of its own, outside any
+// conversation, and returns its reply
+func (s *Server) askAgent(agentID, root string, project *config.ProjectConfig, text string) (string, error) {
+	if a := s.findAgent(agentID); a == nil || !a.IsEnabled() {
+		return "", fmt.Errorf("agent %s is not available", agentID)
+	}
+	if s.AgentsPaused() {
+		return "", errAgentsPaused
+	}
+	defer s.applyProjectEnv(agentID, project, func(string, any) {})()
+	s.resetIfExited(agentID)
+	if err := s.ensureAgentInitialized(agentID, 0); err != nil {
+		return "", err
+	}
+	proc, err := s.agents.Get(agentID)
+	if err != nil {
+		return "", err
+	}
+	sessionID, err := s.createAgentSession(agentID, root, project)
+	if err != nil {
+		return "", err
+	}
+	updates, unsubscribe := proc.Subscribe(sessionID)
+	defer unsubscribe()
+
+	var reply strings.Builder
+	prompt := []map[string]any{{"type": "text", "text": text}}
+	_, err = proc.RequestWithUpdates("session/prompt", s.promptParams(agentID, sessionID, prompt, nil), updates, func(msg *jsonrpc.Message) {
+		var params struct {
+			Update sessionUpdate `json:"update"`
+		}
+		if msg.Method != "session/update" || msg.ParseParams(&params) != nil || params.Update.SessionUpdate != "agent_message_chunk" {
+			return
+		}
+		reply.WriteString(extractTextContent(params.Update.Content))
+	})
+	if err != nil {
+		return "", err
+	}
+	return reply.String(), nil
+}
+
+// handleWorkspaceMemory serves GET and PUT /api/workspaces/{id}/memory and
+// POST /api/workspaces/{id}/memory/extract
+func (s *Server) handleWorkspaceMemory(w http.ResponseWriter, r *http.Request, workspaceID, root string, extract bool) {
+	project := s.projectConfig(workspaceID)
+	enabled := project != nil && project.Memory != nil
+	respond := func(content string) {
+		var updatedAt int64
+		if info, err := os.Stat(memoryPath(root)); err == nil {
+			updatedAt = info.ModTime().UnixMilli()
+		}
+		writeJSON(w, map[string]any{
+			"workspaceId": workspaceID,
+			"path":        memoryFile,
+			"content":     content,
+			"enabled":     enabled,
+			"updatedAt":   updatedAt,
+		})
+	}
+
+	switch {
+	case extract && r.Method == "POST":
+		var req struct {
+			SessionID string `json:"sessionId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SessionID == "" {
+			writeError(w, "sessionId is required", http.StatusBadRequest)
+			return
+		}
+		content, err := s.extractMemory(req.SessionID, workspaceID)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respond(content)
+	case extract:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case r.Method == "GET":
+		respond(readMemory(root))
+	case r.Method == "PUT":
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Content) == "" {
+			if err := os.Remove(memoryPath(root)); err != nil && !os.IsNotExist(err) {
+				writeError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			respond("")
+			return
+		}
+		if err := writeMemory(root, req.Content); err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respond(readMemory(root))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxInlineFileSize  = 64 << 10  // Larger files are only linked
+	maxInlineTotalSize = 256 << 10 // Inline budget per prompt
+	maxImageSize       = 5 << 20   // Larger images are only linked
+	maxImageTotalSize  = 20 << 20  // Image budget per prompt
+)
+
+// imageTypes are the image formats sent to vision-capable agents as image
+// blocks, by file extension
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// mentionPattern matches @path mentions, e.g. "@internal/api/chat.go"
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([\w\-./]+)`)
+
+// promptBlocks builds the ACP prompt: the text followed by one content block
+// per referenced file. Files are @-mentioned workspace paths or uploads.
+// Images are sent as base64 image blocks when the agent supports images, and
+// small text files are embedded as resource blocks when it supports embedded
+// context; everything else becomes a resource_link.
+func promptBlocks(text, message string, files []chatFileInfo, root string, caps promptCaps) []map[string]any {
+	blocks := []map[string]any{{"type": "text", "text": text}}
+
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	paths = append(paths, mentionedFiles(message, root)...)
+
+	seen := make(map[string]bool)
+	budget := maxInlineTotalSize
+	imageBudget := maxImageTotalSize
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil || info.IsDir() || seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		uri := fileURI(p)
+		mimeType := mime.TypeByExtension(filepath.Ext(p))
+
+		if imageType, ok := imageTypes[strings.ToLower(filepath.Ext(p))]; ok && caps.Image &&
+			info.Size() <= maxImageSize && info.Size() <= int64(imageBudget) {
+			if data, err := os.ReadFile(p); err == nil {
+				imageBudget -= len(data)
+				blocks = append(blocks, map[string]any{
+					"type":     "image",
+					"data":     base64.StdEncoding.EncodeToString(data),
+					"mimeType": imageType,
+					"uri":      uri,
+				})
+				continue
+			}
+		}
+
+		if caps.EmbeddedContext && info.Size() <= maxInlineFileSize && int(info.Size()) <= budget {
+			if content, ok := readTextFile(p); ok {
+				budget -= len(content)
+				resource := map[string]any{"uri": uri, "text": content}
+				if mimeType != "" {
+					resource["mimeType"] = mimeType
+				}
+				blocks = append(blocks, map[string]any{"type": "resource", "resource": resource})
+				continue
+			}
+		}
+
+		link := map[string]any{
+			"type": "resource_link",
+			"uri":  uri,
+			"name": filepath.Base(p),
+			"size": info.Size(),
+		}
+		if mimeType != "" {
+			link["mimeType"] = mimeType
+		}
+		blocks = append(blocks, link)
+	}
+	return blocks
+}
+
+// mentionedFiles returns absolute paths of @-mentioned files inside root
+func mentionedFiles(message, root string) []string {
+	if root == "" || root == "." {
+		return nil
+	}
+	var paths []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(message, -1) {
+		rel := strings.TrimRight(m[1], ".,")
+		if rel == "" || filepath.IsAbs(rel) {
+			continue
+		}
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		// Mentions must stay inside the workspace
+		if r, err := filepath.Rel(root, p); err != nil || strings.HasPrefix(r, "..") {
+			continue
+		}
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// readTextFile returns the content of a UTF-8 text file
+func readTextFile(p string) (string, bool) {
+	data, err := os.ReadFile(p)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+// fileURI converts an absolute path to a file:// URI
+func fileURI(p string) string {
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows drive paths
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
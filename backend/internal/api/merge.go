@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// Merge modes
+const (
+	mergeInterleave = "interleave" // Order all messages by timestamp
+	mergeAppend     = "append"     // Each conversation's messages after the previous one's
+)
+
+// handleSessionMerge combines conversations into a new session. The sources
+// are left untouched.
+func (s *Server) handleSessionMerge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		IDs  []string `json:"ids"`
+		Mode string   `json:"mode"` // interleave (default) or append
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = mergeInterleave
+	}
+	if req.Mode != mergeInterleave && req.Mode != mergeAppend {
+		writeError(w, "Invalid mode: "+req.Mode, http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) < 2 {
+		writeError(w, "At least two session IDs required", http.StatusBadRequest)
+		return
+	}
+
+	sources := make([]*storage.StoredSession, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		session, err := s.sessionStore.Load(id)
+		if err != nil {
+			writeError(w, "Session not found: "+id, http.StatusNotFound)
+			return
+		}
+		if len(sources) > 0 && session.WorkspaceID != sources[0].WorkspaceID {
+			writeError(w, "Sessions belong to different workspaces", http.StatusBadRequest)
+			return
+		}
+		sources = append(sources, session)
+	}
+
+	merged := mergeSessions(generateUUID(), sources, req.Mode)
+	if err := s.sessionStore.Save(merged); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.restoreConversation(merged)
+
+	writeJSON(w, map[string]any{"session": merged})
+}
+
+// mergeSessions builds a session holding the messages, annotations, turns
+// and pins of sources.
+// Settings such as the active agent come from the first source.
+func mergeSessions(id string, sources []*storage.StoredSession, mode string) *storage.StoredSession {
+	first := sources[0]
+	merged := storage.CreateSession(id, first.ActiveAgent, first.WorkspaceID)
+	merged.MentionMode = first.MentionMode
+	merged.AgentParams = first.AgentParams
+	merged.Limits = first.Limits
+
+	// Annotations follow their messages to their merged positions
+	type item struct {
+		msg        conversation.Message
+		annotation *conversation.Annotation
+	}
+	var items []item
+	for _, src := range sources {
+		start := len(items)
+		for _, msg := range src.Messages {
+			items = append(items, item{msg: msg})
+		}
+		for i := range src.Annotations {
+			if a := &src.Annotations[i]; a.Message >= 0 && a.Message < len(src.Messages) {
+				items[start+a.Message].annotation = a
+			}
+		}
+		merged.Turns = append(merged.Turns, src.Turns...)
+		for _, pin := range src.Pins {
+			dup := slices.ContainsFunc(merged.Pins, func(p conversation.Pin) bool {
+				return p.Type == pin.Type && p.Value == pin.Value
+			})
+			if !dup {
+				merged.Pins = append(merged.Pins, pin)
+			}
+		}
+	}
+	if mode == mergeInterleave {
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].msg.Timestamp < items[j].msg.Timestamp
+		})
+	}
+	for i, it := range items {
+		merged.Messages = append(merged.Messages, it.msg)
+		if it.annotation != nil {
+			a := *it.annotation
+			a.Message = i
+			merged.Annotations = append(merged.Annotations, a)
+		}
+	}
+	sort.SliceStable(merged.Turns, func(i, j int) bool {
+		return merged.Turns[i].StartedAt < merged.Turns[j].StartedAt
+	})
+	merged.Title = storage.GenerateTitle(merged.Messages)
+	merged.UpdatedAt = time.Now().UnixMilli()
+	return merged
+}
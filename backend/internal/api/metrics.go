@@ -0,0 +1,148 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/metrics"
+)
+
+// metricsPath serves the Prometheus metrics, requiring a token like /api/*
+const metricsPath = "/metrics"
+
+// serverMetrics are the gateway's Prometheus metrics. Requests are labeled
+// by the route pattern they matched, which keeps the series bounded.
+type serverMetrics struct {
+	registry  *metrics.Registry
+	requests  *metrics.Counter
+	durations *metrics.Histogram
+	streams   *metrics.Histogram
+	prompts   *metrics.Histogram
+	toolCalls *metrics.Counter
+}
+
+// setupMetrics registers the metrics, the gauges read at each scrape
+func (s *Server) setupMetrics() {
+	r := metrics.NewRegistry()
+	s.metrics = serverMetrics{
+		registry: r,
+		requests: r.Counter("acpone_http_requests_total",
+			"HTTP requests by method, route and status code.", "method", "route", "code"),
+		durations: r.Histogram("acpone_http_request_duration_seconds",
+			"Duration of HTTP requests other than event streams, by route.", metrics.RequestBuckets, "route"),
+		streams: r.Histogram("acpone_stream_duration_seconds",
+			"How long SSE and NDJSON streams stayed open, by route.", metrics.TurnBuckets, "route"),
+		prompts: r.Histogram("acpone_prompt_duration_seconds",
+			"Duration of agent turns from prompting to the end of the reply, by agent and stop reason.", metrics.TurnBuckets, "agent", "stop_reason"),
+		toolCalls: r.Counter("acpone_tool_calls_total",
+			"Tool calls agents made in completed turns, by kind and final status.", "agent", "kind", "status"),
+	}
+	r.GaugeFunc("acpone_agent_processes",
+		"Agent processes by status, 1 for each agent's current status.", []string{"agent", "status"}, s.agentProcessSamples)
+	r.GaugeFunc("acpone_active_turns", "Chat turns in progress.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(len(s.turns.list()))}}
+	})
+	r.GaugeFunc("acpone_build_info", "Always 1, labeled with the backend version.", []string{"version"}, func() []metrics.Sample {
+		return []metrics.Sample{{Labels: []string{Version}, Value: 1}}
+	})
+}
+
+// agentProcessSamples reports the process status of each configured agent
+func (s *Server) agentProcessSamples() []metrics.Sample {
+	configured := s.agentList()
+	samples := make([]metrics.Sample, 0, len(configured))
+	for _, a := range configured {
+		status := agent.StatusIdle
+		if proc, err := s.agents.Get(a.ID); err == nil {
+			status = proc.Status()
+		}
+		samples = append(samples, metrics.Sample{Labels: []string{a.ID, string(status)}, Value: 1})
+	}
+	return samples
+}
+
+// observeTurn records a turn's prompt latency and tool calls. Failed turns
+// have the stop reason "error".
+func (s *Server) observeTurn(agentID string, elapsed time.Duration, stopReason string, tools []*conversation.ToolCallInfo) {
+	s.metrics.prompts.Observe(elapsed.Seconds(), agentID, stopReason)
+	for _, tool := range tools {
+		kind := tool.Kind
+		if kind == "" {
+			kind = "other"
+		}
+		s.metrics.toolCalls.Inc(agentID, kind, tool.Status)
+	}
+}
+
+// metricsMiddleware counts the requests mux serves and times them, event
+// streams separately as they last as long as the client listens
+func (s *Server) metricsMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		elapsed := time.Since(start).Seconds()
+
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "other"
+		}
+		s.metrics.requests.Inc(methodLabel(r.Method), route, strconv.Itoa(rec.code))
+		if isStream(rec.Header().Get("Content-Type")) {
+			s.metrics.streams.Observe(elapsed, route)
+		} else {
+			s.metrics.durations.Observe(elapsed, route)
+		}
+	})
+}
+
+// methodLabel returns a standard request method as is and OTHER for any
+// other, so clients can't create series with made-up methods
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// isStream reports whether a response content type is an event stream
+func isStream(contentType string) bool {
+	return strings.HasPrefix(contentType, mimeSSE) || strings.HasPrefix(contentType, mimeNDJSON)
+}
+
+// statusRecorder keeps the status code of a response, passing flushes on
+// for streaming handlers
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code, r.wroteHeader = code, true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
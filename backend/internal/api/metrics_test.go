@@ -0,0 +1,40 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMetricsMethodLabels(t *testing.T) {
+	_, hs := newTestServer(t, "claude", sessionCountingAgent(new(atomic.Int32)))
+	for _, method := range []string{"GET", "DELETE", "FOO", "get", "X-RANDOM-1"} {
+		req, _ := http.NewRequest(method, hs.URL+"/api/status", nil)
+		resp, err := hs.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := hs.Client().Get(hs.URL + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+
+	methods := map[string]int{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "acpone_http_requests_total{") || !strings.Contains(line, `route="/api/status"`) {
+			continue
+		}
+		start := strings.Index(line, `method="`) + len(`method="`)
+		methods[line[start:start+strings.Index(line[start:], `"`)]]++
+	}
+	if len(methods) != 3 || methods["GET"] != 1 || methods["DELETE"] != 1 || methods["OTHER"] != 1 {
+		t.Fatalf("method labels %v, want GET, DELETE and OTHER:\n%s", methods, data)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// moveError is a refused move or switch of a conversation's workspace, with
+// its HTTP status
+type moveError struct {
+	msg    string
+	status int
+}
+
+func (e *moveError) Error() string { return e.msg }
+
+// handleSessionMove moves a conversation to another workspace: POST
+// {workspaceId}. Its messages, pins and settings are kept while agent
+// sessions, which run in the old workspace, start over. File pins missing
+// from the new workspace are reported.
+func (s *Server) handleSessionMove(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		WorkspaceID string `json:"workspaceId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.WorkspaceID == "" {
+		writeError(w, "workspaceId required", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.checkMove(id, req.WorkspaceID); err != nil {
+		writeMoveError(w, err)
+		return
+	}
+
+	s.agentSessions.forget(id)
+	session, err := s.relocateConversation(id, req.WorkspaceID)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"session": session, "missingPins": s.missingPins(session)})
+}
+
+// checkMove loads a conversation that may move to another workspace: one
+// that exists, isn't there already and has no turn running
+func (s *Server) checkMove(id, workspaceID string) (*conversation.Conversation, error) {
+	if _, ok := s.workspaceStore.Find(workspaceID); !ok {
+		return nil, &moveError{"Workspace not found", http.StatusNotFound}
+	}
+	for _, t := range s.turns.list() {
+		if t.ConversationID == id {
+			return nil, &moveError{"Wait for the running turn to finish", http.StatusConflict}
+		}
+	}
+
+	if !s.conversations.Has(id) {
+		session, err := s.sessionStore.Load(id)
+		if err != nil {
+			return nil, &moveError{"Session not found", http.StatusNotFound}
+		}
+		s.restoreConversation(session)
+	}
+	conv := s.conversations.Get(id)
+	if conv.WorkspaceID == workspaceID {
+		return nil, &moveError{"Session is already in this workspace", http.StatusBadRequest}
+	}
+	return conv, nil
+}
+
+// writeMoveError responds with the status of a moveError
+func writeMoveError(w http.ResponseWriter, err error) {
+	var moveErr *moveError
+	if errors.As(err, &moveErr) {
+		writeError(w, moveErr.msg, moveErr.status)
+		return
+	}
+	writeError(w, err.Error(), http.StatusInternalServerError)
+}
+
+// relocateConversation points a conversation at another workspace and
+// moves its stored session there. Its agent sessions are left to the
+// caller.
+func (s *Server) relocateConversation(id, workspaceID string) (*storage.StoredSession, error) {
+	// The conversation's branch and last turn's changes belong to the old
+	// workspace's repository, and its messages so far to the old memory
+	s.conversations.SetWorkspace(id, workspaceID)
+	s.conversations.SetBranch(id, "")
+	s.conversations.SetSessionID(id, "")
+	s.turnChanges.set(id, nil)
+	s.memory.setExtracted(id, len(s.conversations.Get(id).Messages))
+
+	session := s.storedConversation(id)
+	return session, s.sessionStore.Move(session)
+}
+
+// missingPins lists the pinned files of a session its workspace lacks
+func (s *Server) missingPins(session *storage.StoredSession) []string {
+	root := s.resolveWorkspacePath(session.WorkspaceID)
+	missing := []string{}
+	for _, pin := range session.Pins {
+		if pin.Type != conversation.PinFile {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(pin.Value))); err != nil {
+			missing = append(missing, pin.Value)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// nodeInstaller installs Node.js with a system package manager
+type nodeInstaller struct {
+	Tool string   // Package manager binary that must be on PATH
+	Args []string // Arguments of the install command
+}
+
+// String returns the install command line shown to the user
+func (n *nodeInstaller) String() string {
+	return n.Tool + " " + strings.Join(n.Args, " ")
+}
+
+// nodeInstallers lists the supported package managers per OS, in order of
+// preference
+var nodeInstallers = map[string][]nodeInstaller{
+	"darwin": {
+		{Tool: "brew", Args: []string{"install", "node"}},
+	},
+	"windows": {
+		{Tool: "winget", Args: []string{"install", "-e", "--id", "OpenJS.NodeJS.LTS", "--accept-source-agreements", "--accept-package-agreements"}},
+	},
+	"linux": {
+		{Tool: "brew", Args: []string{"install", "node"}},
+		// sudo -n fails instead of prompting when a password is needed
+		{Tool: "sudo", Args: []string{"-n", "apt-get", "install", "-y", "nodejs", "npm"}},
+		{Tool: "sudo", Args: []string{"-n", "dnf", "install", "-y", "nodejs", "npm"}},
+	},
+}
+
+// findNodeInstaller returns the first available Node.js installer, or nil
+func findNodeInstaller() *nodeInstaller {
+	for _, n := range nodeInstallers[runtime.GOOS] {
+		if !commandExists(n.Tool) {
+			continue
+		}
+		// sudo entries also need the package manager they run
+		if n.Tool == "sudo" && !commandExists(n.Args[1]) {
+			continue
+		}
+		return &n
+	}
+	return nil
+}
+
+// installNode runs a Node.js installer, streaming its output line by line
+func installNode(ctx context.Context, n *nodeInstaller, logFn func(string)) error {
+	setupLog.Info("installing Node.js", "version", n)
+	logFn(fmt.Sprintf("Running: %s", n))
+
+	cmd := installCommand(ctx, n.Tool, n.Args...)
+	if err := runStreaming(cmd, logFn); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		setupLog.Error("failed to install Node.js", "error", err)
+		return fmt.Errorf("%s failed: %w", n.Tool, err)
+	}
+
+	setupLog.Info("installed Node.js")
+	logFn("Installation completed")
+	return nil
+}
+
+// runStreaming runs cmd and passes each line of its combined output to logFn
+func runStreaming(cmd *exec.Cmd, logFn func(string)) error {
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				setupLog.Debug("install output", "line", line)
+				logFn(line)
+			}
+		}
+		io.Copy(io.Discard, pr)
+	}()
+
+	err := cmd.Wait()
+	pw.Close()
+	<-done
+	return err
+}
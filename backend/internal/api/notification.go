@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/daodao97/acpone/internal/conversation"
 	"github.com/daodao97/acpone/internal/jsonrpc"
@@ -45,6 +46,36 @@ type sessionUpdateMeta struct {
 	} `json:"claudeCode,omitempty"`
 }
 
+// commandsUpdate returns the commands of an available_commands_update
+// notification, ok false for other notifications
+func commandsUpdate(msg *jsonrpc.Message) (commands []SlashCommand, ok bool) {
+	if msg.Method != "session/update" {
+		return nil, false
+	}
+	var params struct {
+		Update sessionUpdate `json:"update"`
+	}
+	if msg.ParseParams(&params) != nil || params.Update.SessionUpdate != "available_commands_update" {
+		return nil, false
+	}
+	return params.Update.AvailableCommands, true
+}
+
+// advertiseCommands stores the commands of an agent and sends them to the client
+func (s *Server) advertiseCommands(agentID string, commands []SlashCommand, sendEvent func(string, any)) {
+	if len(commands) == 0 {
+		return
+	}
+	s.agentCommandsMu.Lock()
+	s.agentCommands[agentID] = commands
+	s.agentCommandsMu.Unlock()
+
+	sendEvent("commands", map[string]any{
+		"agent":    agentID,
+		"commands": commands,
+	})
+}
+
 func (s *Server) handleNotification(
 	msg *jsonrpc.Message,
 	sendEvent func(string, any),
@@ -68,25 +99,20 @@ func (s *Server) handleNotification(
 
 	switch update.SessionUpdate {
 	case "agent_message_chunk", "agent_thought_chunk":
-		if text := extractTextContent(update.Content); text != "" {
+		text := extractTextContent(update.Content)
+		if text != "" {
 			*currentText += text
 		}
-		// Forward text chunks to frontend
+		// Forward text chunks to frontend; subscribers may merge them
+		if text != "" {
+			sendEvent("update", textChunk{Kind: update.SessionUpdate, Text: text, Params: params})
+			return
+		}
 		sendEvent("update", params)
 		return
 
 	case "available_commands_update":
-		if len(update.AvailableCommands) > 0 {
-			// Store commands for this agent
-			s.agentCommandsMu.Lock()
-			s.agentCommands[agentID] = update.AvailableCommands
-			s.agentCommandsMu.Unlock()
-
-			sendEvent("commands", map[string]any{
-				"agent":    agentID,
-				"commands": update.AvailableCommands,
-			})
-		}
+		s.advertiseCommands(agentID, update.AvailableCommands, sendEvent)
 		return // Don't forward raw update for commands
 
 	case "tool_call", "tool_call_update":
@@ -121,6 +147,10 @@ func (s *Server) handleNotification(
 
 		input := extractInput(update.RawInput)
 		output, errMsg := extractOutput(update)
+		terminals := terminalIDs(update.Content)
+		if output == "" {
+			output = s.terminalOutput(agentID, terminals)
+		}
 		// Only extract description if status is not completed (completed content is output, not description)
 		var description string
 		if update.Status != "completed" {
@@ -195,6 +225,7 @@ func (s *Server) handleNotification(
 			"rawInput":      rawInputJSON,
 			"output":        output,
 			"error":         errMsg,
+			"terminalIds":   terminals,
 			"sessionUpdate": update.SessionUpdate,
 		})
 		return // Don't send raw params for tool calls
@@ -277,6 +308,38 @@ func extractOutput(update sessionUpdate) (output, errMsg string) {
 	return
 }
 
+// terminalIDs returns the terminals a tool call's content embeds
+// Content format: [{"type":"terminal","terminalId":"term-1"}]
+func terminalIDs(content any) []string {
+	arr, _ := content.([]any)
+	var ids []string
+	for _, item := range arr {
+		block, _ := item.(map[string]any)
+		if id, _ := block["terminalId"].(string); block["type"] == "terminal" && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// terminalOutput joins the output the agent's terminals have so far
+func (s *Server) terminalOutput(agentID string, terminals []string) string {
+	if len(terminals) == 0 {
+		return ""
+	}
+	proc, err := s.agents.Get(agentID)
+	if err != nil {
+		return ""
+	}
+	var parts []string
+	for _, id := range terminals {
+		if output, ok := proc.TerminalOutput(id); ok && output != "" {
+			parts = append(parts, output)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
 // extractDescription extracts description text from content array
 // Content format: [{"type":"content","content":{"type":"text","text":"description"}}]
 func extractDescription(content any) string {
@@ -0,0 +1,499 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conversation"
+)
+
+// openaiRoutedModel is the model that leaves the choice of agent to the
+// routing rules, as a chat in the web UI does
+const openaiRoutedModel = "acpone"
+
+// openaiThreadLimit bounds the remembered threads of OpenAI clients
+const openaiThreadLimit = 1000
+
+// openaiRequest is a chat completions request. Tools, temperature and the
+// like are ignored: the agents bring their own tools and settings.
+type openaiRequest struct {
+	Model         string                 `json:"model"`
+	Messages      []openaiRequestMessage `json:"messages"`
+	Stream        bool                   `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	// End user of the client, keeping the threads of users sharing an API
+	// key apart
+	User string `json:"user"`
+	// Workspace ID or name the conversation runs in, an acpone extension
+	Workspace string `json:"workspace"`
+}
+
+type openaiRequestMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"` // A string or an array of content parts
+}
+
+// text returns the message's text, joining the text parts of an array
+func (m openaiRequestMessage) text() string {
+	var s string
+	if json.Unmarshal(m.Content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	json.Unmarshal(m.Content, &parts)
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type openaiMessage struct {
+	Role      string           `json:"role,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	ToolCalls []openaiToolCall `json:"tool_calls,omitempty"`
+}
+
+// openaiToolCall reports a tool call the agent made. The agent ran it
+// already, so finish_reason stays "stop" and clients don't run it again.
+type openaiToolCall struct {
+	Index    *int   `json:"index,omitempty"` // Only in stream chunks
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openaiChoice struct {
+	Index        int            `json:"index"`
+	Message      *openaiMessage `json:"message,omitempty"`
+	Delta        *openaiMessage `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type openaiUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// openaiCompletion is a chat.completion response or a chat.completion.chunk
+type openaiCompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openaiChoice `json:"choices"`
+	Usage   *openaiUsage   `json:"usage,omitempty"`
+}
+
+// writeOpenAIError sends an error in the shape OpenAI SDKs raise
+func writeOpenAIError(w http.ResponseWriter, message, typ string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "type": typ, "code": nil},
+	})
+}
+
+// handleOpenAIModels serves GET /v1/models: the agents, pipelines and teams
+// a request may name, and the routed model
+func (s *Server) handleOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ids := []string{openaiRoutedModel}
+	for _, a := range s.agentList() {
+		if a.IsEnabled() {
+			ids = append(ids, a.ID)
+		}
+	}
+	for _, p := range s.config.Pipelines {
+		ids = append(ids, p.ID)
+	}
+	for _, t := range s.config.Teams {
+		ids = append(ids, t.ID)
+	}
+	models := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		models = append(models, map[string]any{"id": id, "object": "model", "created": 0, "owned_by": "acpone"})
+	}
+	writeJSON(w, map[string]any{"object": "list", "data": models})
+}
+
+// handleChatCompletions serves POST /v1/chat/completions, running the last
+// user message as a chat turn. A request continuing a thread this endpoint
+// answered continues its conversation; any other starts one seeded with
+// the earlier messages.
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req openaiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, "Invalid request: "+err.Error(), "invalid_request_error", http.StatusBadRequest)
+		return
+	}
+	last := len(req.Messages) - 1
+	if last < 0 || req.Messages[last].Role != "user" || req.Messages[last].text() == "" {
+		writeOpenAIError(w, "The last message must be a user message with text", "invalid_request_error", http.StatusBadRequest)
+		return
+	}
+	if s.AgentsPaused() {
+		writeOpenAIError(w, errAgentsPaused.Error(), "server_error", http.StatusServiceUnavailable)
+		return
+	}
+
+	chat := chatRequest{Message: req.Messages[last].text(), TurnID: generateUUID()}
+	if req.Workspace != "" {
+		ws, ok := s.findWorkspace(req.Workspace)
+		if !ok {
+			writeOpenAIError(w, "Workspace not found: "+req.Workspace, "invalid_request_error", http.StatusBadRequest)
+			return
+		}
+		chat.WorkspaceID = ws.ID
+	}
+	if req.Model == "" {
+		req.Model = openaiRoutedModel
+	}
+	switch a := s.findAgentByName(req.Model); {
+	case req.Model == openaiRoutedModel:
+	case a != nil && a.IsEnabled():
+		chat.Agent = a.ID
+	case s.config.FindPipeline(req.Model) != nil:
+		chat.Pipeline = req.Model
+	case s.config.FindTeam(req.Model) != nil:
+		chat.Team = req.Model
+	default:
+		writeOpenAIError(w, "The model `"+req.Model+"` does not exist", "invalid_request_error", http.StatusNotFound)
+		return
+	}
+	history := req.Messages[:last]
+	client := openaiClient(r, req.User)
+	chat.ConversationID = s.openaiConversation(client, history, chat.Agent, chat.WorkspaceID)
+	chat.Instructions = openaiInstructions(req.Messages)
+
+	c := &completionCollector{
+		id:      "chatcmpl-" + chat.TurnID,
+		model:   req.Model,
+		created: time.Now().Unix(),
+		tools:   make(map[string]int),
+	}
+	if req.Stream {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeOpenAIError(w, "Streaming not supported", "server_error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mimeSSE)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		c.emit = func(data any) {
+			b, _ := json.Marshal(data)
+			w.Write([]byte("data: " + string(b) + "\n\n"))
+			flusher.Flush()
+		}
+		c.emitDelta(openaiMessage{Role: "assistant"})
+	}
+
+	stream, closeStream := s.newEventStream(chatTopic, c.deliver)
+	s.runChat(chat, stream)
+	closeStream()
+
+	agentID := chat.Agent
+	if conv := s.conversations.Get(stream.convID); conv != nil {
+		agentID = conv.ActiveAgent
+	}
+	charsPerToken := s.tokenizerFor(agentID).charsPerToken
+	usage := &openaiUsage{CompletionTokens: conversation.EstimateTokens(c.content.String(), charsPerToken)}
+	for _, m := range req.Messages {
+		usage.PromptTokens += conversation.EstimateTokens(m.text(), charsPerToken)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	if c.err == "" {
+		// The client sends the reply back with its next message
+		reply := openaiRequestMessage{Role: "assistant"}
+		reply.Content, _ = json.Marshal(c.content.String())
+		s.openaiThreads.remember(openaiThreadKey(client, append(req.Messages[:last+1:last+1], reply)), stream.convID)
+	}
+
+	if req.Stream {
+		if c.err != "" {
+			c.emit(map[string]any{"error": map[string]any{"message": c.err, "type": "server_error"}})
+		} else {
+			c.finish()
+			if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+				c.emit(openaiCompletion{ID: c.id, Object: "chat.completion.chunk", Created: c.created, Model: c.model, Choices: []openaiChoice{}, Usage: usage})
+			}
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+		return
+	}
+	if c.err != "" {
+		writeOpenAIError(w, c.err, "server_error", http.StatusInternalServerError)
+		return
+	}
+	reason := c.finishReason()
+	writeJSON(w, openaiCompletion{
+		ID:      c.id,
+		Object:  "chat.completion",
+		Created: c.created,
+		Model:   c.model,
+		Choices: []openaiChoice{{
+			Message:      &openaiMessage{Role: "assistant", Content: c.content.String(), ToolCalls: c.calls},
+			FinishReason: &reason,
+		}},
+		Usage: usage,
+	})
+}
+
+// openaiConversation returns the client's conversation continuing a thread,
+// else creates one holding its messages, which the first turn carries over
+// as context. Pinning an agent makes it the conversation's active agent.
+// System and developer messages aren't part of the conversation, they are
+// sent as instructions (openaiInstructions).
+func (s *Server) openaiConversation(client string, history []openaiRequestMessage, agentID, workspaceID string) string {
+	if id, ok := s.openaiThreads.lookup(openaiThreadKey(client, history)); ok && s.conversations.Has(id) {
+		if agentID != "" {
+			s.conversations.SetActiveAgent(id, agentID)
+		}
+		return id
+	}
+
+	id := generateUUID()
+	if workspaceID == "" {
+		workspaceID = s.workspaceStore.Default()
+	}
+	if agentID == "" {
+		agentID = s.defaultAgentFor(workspaceID)
+	}
+	s.conversations.Create(id, agentID, workspaceID)
+	s.agentSessions.forget(id)
+	seeded := false
+	for _, m := range history {
+		text := m.text()
+		if text == "" {
+			continue
+		}
+		switch m.Role {
+		case "user":
+			s.conversations.AddUserMessage(id, s.maskSecrets(text), nil)
+		case "assistant":
+			s.conversations.AddAssistantMessage(id, text, agentID)
+		default:
+			continue
+		}
+		seeded = true
+	}
+	if seeded {
+		s.agentSessions.carryContext(id)
+	}
+	return id
+}
+
+// openaiInstructions joins the texts of the system and developer messages
+func openaiInstructions(messages []openaiRequestMessage) string {
+	var texts []string
+	for _, m := range messages {
+		if m.Role != "system" && m.Role != "developer" {
+			continue
+		}
+		if text := strings.TrimSpace(m.text()); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// completionCollector turns the events of a chat turn into a completion,
+// streaming chunks through emit when set
+type completionCollector struct {
+	id      string
+	model   string
+	created int64
+	emit    func(any)
+
+	mu         sync.Mutex
+	content    strings.Builder
+	calls      []openaiToolCall
+	done       []bool         // Per call, whether it finished
+	tools      map[string]int // toolCallId -> index in calls
+	sent       int            // Calls streamed so far
+	stopReason string
+	err        string
+}
+
+func (c *completionCollector) deliver(event string, data any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch event {
+	case "update":
+		if chunk, ok := data.(textChunk); ok && chunk.Kind == "agent_message_chunk" {
+			c.content.WriteString(chunk.Text)
+			c.emitDelta(openaiMessage{Content: chunk.Text})
+		}
+	case "tool_call":
+		c.toolCall(data.(map[string]any))
+	case "done":
+		if result, ok := data.(map[string]any); ok {
+			c.stopReason, _ = result["stopReason"].(string)
+		}
+	case "error":
+		if m, ok := data.(map[string]string); ok {
+			c.err = m["message"]
+		}
+	}
+}
+
+// toolCall records a tool call, which is streamed once it finished with
+// its input complete
+func (c *completionCollector) toolCall(info map[string]any) {
+	id, _ := info["toolCallId"].(string)
+	name, _ := info["toolName"].(string)
+	if name == "" {
+		name, _ = info["kind"].(string)
+	}
+	args, _ := info["rawInput"].(string)
+	if args == "" {
+		args = "{}"
+	}
+	i, ok := c.tools[id]
+	if !ok {
+		i = len(c.calls)
+		c.tools[id] = i
+		call := openaiToolCall{ID: id, Type: "function"}
+		call.Function.Name = "other"
+		c.calls = append(c.calls, call)
+		c.done = append(c.done, false)
+	}
+	if name != "" {
+		c.calls[i].Function.Name = name
+	}
+	c.calls[i].Function.Arguments = args
+	if status, _ := info["status"].(string); status != "pending" {
+		c.done[i] = true
+		c.flushCalls(false)
+	}
+}
+
+// flushCalls streams the calls in order as they finish, all of them when
+// the turn is over
+func (c *completionCollector) flushCalls(all bool) {
+	for ; c.sent < len(c.calls) && c.emit != nil; c.sent++ {
+		if !all && !c.done[c.sent] {
+			return
+		}
+		call := c.calls[c.sent]
+		index := c.sent
+		call.Index = &index
+		c.emitDelta(openaiMessage{ToolCalls: []openaiToolCall{call}})
+	}
+}
+
+func (c *completionCollector) emitDelta(delta openaiMessage) {
+	if c.emit == nil {
+		return
+	}
+	c.emit(openaiCompletion{
+		ID:      c.id,
+		Object:  "chat.completion.chunk",
+		Created: c.created,
+		Model:   c.model,
+		Choices: []openaiChoice{{Delta: &delta}},
+	})
+}
+
+// finish streams the remaining calls and the finish reason
+func (c *completionCollector) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushCalls(true)
+	reason := c.finishReason()
+	c.emit(openaiCompletion{
+		ID:      c.id,
+		Object:  "chat.completion.chunk",
+		Created: c.created,
+		Model:   c.model,
+		Choices: []openaiChoice{{Delta: &openaiMessage{}, FinishReason: &reason}},
+	})
+}
+
+// finishReason maps the ACP stop reason
+func (c *completionCollector) finishReason() string {
+	switch c.stopReason {
+	case "max_tokens", "max_turn_requests":
+		return "length"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// openaiThreads maps the messages of threads OpenAI clients hold to the
+// conversations answering them, so a client resending its history with a
+// new message continues the conversation and its agent sessions
+type openaiThreads struct {
+	mu    sync.Mutex
+	convs map[string]string // thread key -> convID
+	order []string          // Keys oldest first, for evicting
+}
+
+func (t *openaiThreads) lookup(key string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id, ok := t.convs[key]
+	return id, ok
+}
+
+func (t *openaiThreads) remember(key, convID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.convs == nil {
+		t.convs = make(map[string]string)
+	}
+	if _, ok := t.convs[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.convs[key] = convID
+	for len(t.order) > openaiThreadLimit {
+		delete(t.convs, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// openaiClient identifies the client of a request by the secret it
+// presents and the user it names, so clients sending the same messages
+// don't share a conversation
+func openaiClient(r *http.Request, user string) string {
+	return requestSecret(r) + "\x00" + user
+}
+
+// openaiThreadKey hashes the client with the roles and texts of messages,
+// ignoring the whitespace around texts which clients may trim
+func openaiThreadKey(client string, messages []openaiRequestMessage) string {
+	h := sha256.New()
+	h.Write([]byte(client + "\x00"))
+	for _, m := range messages {
+		h.Write([]byte(m.Role + "\x00" + strings.TrimSpace(m.text()) + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+// openaiTestServer starts a server whose agent answers "reply <n>" and
+// records the text of each prompt
+func openaiTestServer(t *testing.T) (*Server, func(key string, body map[string]any) string, func() []string) {
+	var mu sync.Mutex
+	var prompts []string
+	sessions := 0
+	s, hs := newTestServer(t, "claude", func(r io.Reader, w io.Writer) error {
+		return mockagent.NewConn(w, func(c *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch msg.Method {
+			case "initialize":
+				return map[string]any{"protocolVersion": 1, "agentCapabilities": map[string]any{}}, nil
+			case "session/new":
+				sessions++
+				return map[string]any{"sessionId": fmt.Sprintf("s%d", sessions)}, nil
+			case "session/prompt":
+				sessionID, text := mockagent.PromptText(msg)
+				prompts = append(prompts, text)
+				c.Update(sessionID, map[string]any{
+					"sessionUpdate": "agent_message_chunk",
+					"content":       map[string]string{"type": "text", "text": fmt.Sprintf("reply %d", len(prompts))},
+				})
+				return map[string]any{"stopReason": "end_turn"}, nil
+			}
+			return map[string]any{}, nil
+		}, nil).Serve(r)
+	})
+
+	complete := func(key string, body map[string]any) string {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", hs.URL+"/v1/chat/completions", bytes.NewReader(data))
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := hs.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var completion openaiCompletion
+		if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil || len(completion.Choices) == 0 {
+			t.Fatalf("completion: %v", err)
+		}
+		return completion.Choices[0].Message.Content
+	}
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), prompts...)
+	}
+	return s, complete, received
+}
+
+func msg(role, content string) map[string]string {
+	return map[string]string{"role": role, "content": content}
+}
+
+func TestOpenAISystemMessagesAreInstructions(t *testing.T) {
+	s, complete, received := openaiTestServer(t)
+	system := msg("system", "Answer in French.")
+
+	first := complete("k", map[string]any{"model": "claude", "messages": []any{system, msg("user", "hi")}})
+	complete("k", map[string]any{"model": "claude", "messages": []any{system, msg("user", "hi"), msg("assistant", first), msg("user", "more")}})
+
+	prompts := received()
+	if len(prompts) != 2 || !strings.HasPrefix(prompts[0], "Answer in French.") || strings.Contains(prompts[1], "French") {
+		t.Fatalf("instructions not sent once ahead of the first prompt: %q", prompts)
+	}
+	for _, id := range openaiConversations(s) {
+		for _, m := range s.conversations.Snapshot(id).Messages {
+			if strings.Contains(m.Content, "French") {
+				t.Errorf("instructions recorded as a %s message: %q", m.Role, m.Content)
+			}
+		}
+	}
+}
+
+func TestOpenAIThreadsAreClientScoped(t *testing.T) {
+	s, complete, _ := openaiTestServer(t)
+	opening := []any{msg("user", "hi"), msg("assistant", "reply 1"), msg("user", "next")}
+
+	// The thread of key a, user u continues only for that client
+	complete("a", map[string]any{"model": "claude", "user": "u", "messages": []any{msg("user", "hi")}})
+	for _, client := range []struct{ key, user string }{{"b", "u"}, {"a", "other"}, {"a", "u"}} {
+		complete(client.key, map[string]any{"model": "claude", "user": client.user, "messages": opening})
+	}
+	if n := len(openaiConversations(s)); n != 3 {
+		t.Fatalf("%d conversations, want 3: the first continued by its client, one per other client", n)
+	}
+}
+
+// openaiConversations returns the conversations answering threads
+func openaiConversations(s *Server) []string {
+	s.openaiThreads.mu.Lock()
+	defer s.openaiThreads.mu.Unlock()
+	var ids []string
+	for _, id := range s.openaiThreads.convs {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/events"
+)
+
+// pauseCheckInterval is how often a pause checks whether running turns
+// have finished
+const pauseCheckInterval = time.Second
+
+// errAgentsPaused rejects turns while agents are paused
+var errAgentsPaused = errors.New("Agents are paused, resume them to continue")
+
+// agentPause is the switch pausing all agents, e.g. to get CPU and battery
+// back for a while
+type agentPause struct {
+	mu     sync.Mutex
+	paused bool
+	since  time.Time
+	epoch  int // Incremented on every change, ending the wait of an earlier pause
+}
+
+func (p *agentPause) get() (bool, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused, p.since
+}
+
+// set changes the switch, returning the new epoch and whether it changed
+func (p *agentPause) set(paused bool) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused == paused {
+		return p.epoch, false
+	}
+	p.paused = paused
+	p.since = time.Now()
+	p.epoch++
+	return p.epoch, true
+}
+
+// current reports whether the pause of epoch is still on
+func (p *agentPause) current(epoch int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused && p.epoch == epoch
+}
+
+// AgentsPaused reports whether agents are paused
+func (s *Server) AgentsPaused() bool {
+	paused, _ := s.pause.get()
+	return paused
+}
+
+// SetAgentsPaused pauses or resumes all agents. While paused new turns are
+// rejected, and agent processes stop once the running turns have finished.
+// Resuming prestarts agents again; the others start with their next turn.
+func (s *Server) SetAgentsPaused(paused bool) {
+	epoch, changed := s.pause.set(paused)
+	if !changed {
+		return
+	}
+	logger.Info("agents paused", "paused", paused)
+	s.events.Publish(events.Event{Topic: events.Agent, Type: "pause", Data: map[string]any{"paused": paused}})
+	if paused {
+		go s.stopAgentsWhenIdle(epoch)
+	} else {
+		go s.prestartAgents()
+	}
+}
+
+// stopAgentsWhenIdle stops all agent processes once no turn is running,
+// unless agents were resumed meanwhile
+func (s *Server) stopAgentsWhenIdle(epoch int) {
+	for len(s.turns.list()) > 0 {
+		time.Sleep(pauseCheckInterval)
+		if !s.pause.current(epoch) {
+			return
+		}
+	}
+	if !s.pause.current(epoch) {
+		return
+	}
+	for _, id := range s.agents.IDs() {
+		proc, err := s.agents.Get(id)
+		if err != nil || proc.Status() != agent.StatusRunning {
+			continue
+		}
+		if err := s.agents.Stop(id); err != nil {
+			logger.Warn("failed to stop agent", "agent", id, "error", err)
+		}
+		s.resetAgentState(id)
+	}
+	logger.Info("agents stopped")
+}
+
+// handleAgentsPause serves /api/agents/pause: GET reports the switch, POST
+// {paused} pauses or resumes all agents
+func (s *Server) handleAgentsPause(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "POST":
+		var req struct {
+			Paused *bool `json:"paused"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Paused == nil {
+			writeError(w, "paused required", http.StatusBadRequest)
+			return
+		}
+		s.SetAgentsPaused(*req.Paused)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paused, since := s.pause.get()
+	resp := map[string]any{"paused": paused, "activeTurns": len(s.turns.list())}
+	if paused {
+		resp["since"] = since.UnixMilli()
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/events"
+)
+
+// PendingPermission is a permission request waiting for an answer. It is
+// listed at /api/permissions and streamed on the permission topic, so the
+// tray or the CLI can answer it when no browser tab is attached.
+type PendingPermission struct {
+	ID             string                   `json:"id"`
+	AgentID        string                   `json:"agentId"`
+	ConversationID string                   `json:"conversationId,omitempty"`
+	Title          string                   `json:"title"`
+	Request        *agent.PermissionRequest `json:"request"`
+	CreatedAt      int64                    `json:"createdAt"`
+
+	sendEvent func(string, any) // The chat stream of the turn that asked
+}
+
+// Option returns the option id answering the request with choice, which
+// is an option id or "allow"/"deny" for the first option of that kind
+func (p *PendingPermission) Option(choice string) (string, error) {
+	prefix := choice
+	switch choice {
+	case "allow", "approve":
+		prefix = "allow"
+	case "deny", "reject":
+		prefix = "reject"
+	}
+	for _, o := range p.Request.Options {
+		if o.OptionID == choice {
+			return o.OptionID, nil
+		}
+	}
+	for _, o := range p.Request.Options {
+		if strings.HasPrefix(o.Kind, prefix) {
+			return o.OptionID, nil
+		}
+	}
+	return "", fmt.Errorf("permission %s has no %s option", p.ID, choice)
+}
+
+// pendingPermissions tracks unanswered permission requests
+type pendingPermissions struct {
+	mu    sync.Mutex
+	items []*PendingPermission
+}
+
+func (q *pendingPermissions) add(p *PendingPermission) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, p)
+}
+
+// remove drops the request of agentID's tool call, returning it if pending
+func (q *pendingPermissions) remove(agentID, toolCallID string) *PendingPermission {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, p := range q.items {
+		if p.AgentID == agentID && p.Request.ToolCall.ToolCallID == toolCallID {
+			q.items = append(q.items[:i:i], q.items[i+1:]...)
+			return p
+		}
+	}
+	return nil
+}
+
+// removeTurn drops the requests of agentID in conversation convID
+func (q *pendingPermissions) removeTurn(convID, agentID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.items[:0:0]
+	for _, p := range q.items {
+		if p.ConversationID != convID || p.AgentID != agentID {
+			kept = append(kept, p)
+		}
+	}
+	removed := len(kept) != len(q.items)
+	q.items = kept
+	return removed
+}
+
+func (q *pendingPermissions) find(id string) *PendingPermission {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, p := range q.items {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (q *pendingPermissions) list() []PendingPermission {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]PendingPermission, 0, len(q.items))
+	for _, p := range q.items {
+		list = append(list, *p)
+	}
+	return list
+}
+
+// permissionRequested records a request from an agent during a turn
+func (s *Server) permissionRequested(convID, agentID string, req *agent.PermissionRequest, sendEvent func(string, any)) *PendingPermission {
+	title := req.ToolCall.Title
+	if title == "" {
+		title = req.ToolCall.Kind
+	}
+	p := &PendingPermission{
+		ID:             generateUUID()[:8],
+		AgentID:        agentID,
+		ConversationID: convID,
+		Title:          title,
+		Request:        req,
+		CreatedAt:      time.Now().UnixMilli(),
+		sendEvent:      sendEvent,
+	}
+	s.permissions.add(p)
+	s.broadcastPermissions()
+	return p
+}
+
+// permissionResolved drops an answered request and tells the chat stream
+// that asked, so its prompt closes when answered elsewhere
+func (s *Server) permissionResolved(agentID, toolCallID, optionID string) {
+	p := s.permissions.remove(agentID, toolCallID)
+	if p == nil {
+		return
+	}
+	p.sendEvent("permission_resolved", map[string]any{
+		"id":         p.ID,
+		"agentId":    agentID,
+		"toolCallId": toolCallID,
+		"optionId":   optionID,
+	})
+	s.broadcastPermissions()
+}
+
+// permissionsAbandoned drops the requests of a turn that ended
+func (s *Server) permissionsAbandoned(convID, agentID string) {
+	if s.permissions.removeTurn(convID, agentID) {
+		s.broadcastPermissions()
+	}
+}
+
+// broadcastPermissions publishes the pending list on the permission topic
+func (s *Server) broadcastPermissions() {
+	s.events.Publish(events.Event{Topic: events.Permission, Type: "pending", Data: s.permissions.list()})
+}
+
+// PendingPermissions returns the unanswered permission requests
+func (s *Server) PendingPermissions() []PendingPermission {
+	return s.permissions.list()
+}
+
+// AnswerPermission answers a pending request by id with an option id or
+// "allow"/"deny"
+func (s *Server) AnswerPermission(id, choice string) error {
+	p := s.permissions.find(id)
+	if p == nil {
+		return errors.New("permission not found: " + id)
+	}
+	optionID, err := p.Option(choice)
+	if err != nil {
+		return err
+	}
+	proc, err := s.agents.Get(p.AgentID)
+	if err != nil {
+		return err
+	}
+	proc.ConfirmPermission(p.Request.ToolCall.ToolCallID, optionID)
+	s.permissionResolved(p.AgentID, p.Request.ToolCall.ToolCallID, optionID)
+	return nil
+}
+
+// OnPermissions calls fn with the pending list whenever it changes, until
+// the returned function is called
+func (s *Server) OnPermissions(fn func([]PendingPermission)) func() {
+	return s.events.Subscribe(events.Filter{Topics: []events.Topic{events.Permission}}, func(ev events.Event) {
+		if list, ok := ev.Data.([]PendingPermission); ok && ev.Type == "pending" {
+			fn(list)
+		}
+	})
+}
+
+// handlePermissions lists pending permission requests
+func (s *Server) handlePermissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]any{"permissions": s.permissions.list()})
+}
+
+// handlePermissionAnswer answers a pending request by id: {id, option}
+// where option is an option id or "allow"/"deny" (default "allow")
+func (s *Server) handlePermissionAnswer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID     string `json:"id"`
+		Option string `json:"option"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Option == "" {
+		req.Option = "allow"
+	}
+	if s.permissions.find(req.ID) == nil {
+		writeError(w, "Permission not found: "+req.ID, http.StatusNotFound)
+		return
+	}
+	if err := s.AnswerPermission(req.ID, req.Option); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]any{"success": true})
+}
+
+// handlePermissionsSubscribe streams the pending list as SSE, first the
+// current list and then every change, like /api/setup/subscribe
+func (s *Server) handlePermissionsSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, cancel := s.events.Channel(events.Filter{Topics: []events.Topic{events.Permission}}, 10)
+	defer cancel()
+
+	write := func(list any) {
+		jsonData, _ := json.Marshal(list)
+		fmt.Fprintf(w, "data: %s\n\n", jsonData)
+		flusher.Flush()
+	}
+	write(s.permissions.list())
+
+	for {
+		select {
+		case ev := <-ch:
+			if ev.Type == "pending" {
+				write(ev.Data)
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
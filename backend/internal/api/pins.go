@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conversation"
+)
+
+// handleSessionContext manages the context pinned to a conversation:
+// GET lists it, POST pins {type, value, name?} and DELETE ?id= unpins.
+// Pinned context is sent with every prompt of the conversation.
+func (s *Server) handleSessionContext(w http.ResponseWriter, r *http.Request, id string) {
+	if !s.conversations.Has(id) {
+		session, err := s.sessionStore.Load(id)
+		if err != nil {
+			writeError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		s.restoreConversation(session)
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, map[string]any{"pins": s.pinsOf(id)})
+
+	case "POST":
+		var pin conversation.Pin
+		if err := json.NewDecoder(r.Body).Decode(&pin); err != nil {
+			writeError(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		value, err := s.validatePin(id, pin)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pin.ID = generateUUID()
+		pin.Value = value
+		pin.CreatedAt = time.Now().UnixMilli()
+		s.conversations.AddPin(id, pin)
+		s.persistConversation(id)
+		writeJSON(w, map[string]any{"pin": pin, "pins": s.pinsOf(id)})
+
+	case "DELETE":
+		if !s.conversations.RemovePin(id, r.URL.Query().Get("id")) {
+			writeError(w, "Pin not found", http.StatusNotFound)
+			return
+		}
+		s.persistConversation(id)
+		writeJSON(w, map[string]any{"pins": s.pinsOf(id)})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pinsOf returns the conversation's pins, never nil so they encode as []
+func (s *Server) pinsOf(convID string) []conversation.Pin {
+	pins := s.conversations.Pins(convID)
+	if pins == nil {
+		pins = []conversation.Pin{}
+	}
+	return pins
+}
+
+// validatePin checks a pin and returns its normalized value. Files must be
+// inside the conversation's workspace and are stored relative to it.
+func (s *Server) validatePin(convID string, pin conversation.Pin) (string, error) {
+	value := strings.TrimSpace(pin.Value)
+	if value == "" {
+		return "", errors.New("value required")
+	}
+
+	switch pin.Type {
+	case conversation.PinFile:
+		root := s.resolveWorkspacePath(s.conversations.Get(convID).WorkspaceID)
+		p := value
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, filepath.FromSlash(p))
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", errors.New("file must be inside the workspace")
+		}
+		if info, err := os.Stat(p); err != nil || info.IsDir() {
+			return "", errors.New("file not found: " + value)
+		}
+		return filepath.ToSlash(rel), nil
+
+	case conversation.PinURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", errors.New("invalid URL: " + value)
+		}
+		return value, nil
+
+	case conversation.PinNote:
+		return value, nil
+	}
+	return "", errors.New("type must be file, url or note")
+}
+
+type errPin string
+
+func (e errPin) Error() string { return string(e) }
+
+// pinnedFiles returns the pinned files as attachments for promptBlocks
+func pinnedFiles(pins []conversation.Pin, root string) []chatFileInfo {
+	var files []chatFileInfo
+	for _, pin := range pins {
+		if pin.Type == conversation.PinFile {
+			p := filepath.Join(root, filepath.FromSlash(pin.Value))
+			files = append(files, chatFileInfo{Name: filepath.Base(p), Path: p})
+		}
+	}
+	return files
+}
+
+// withPins adds pinned notes before the prompt and pinned URLs after it.
+// Pinned files are passed to promptBlocks with the message's own files.
+func withPins(blocks []map[string]any, pins []conversation.Pin) []map[string]any {
+	var notes []string
+	var links []map[string]any
+	for _, pin := range pins {
+		switch pin.Type {
+		case conversation.PinNote:
+			if pin.Name != "" {
+				notes = append(notes, pin.Name+": "+pin.Value)
+			} else {
+				notes = append(notes, pin.Value)
+			}
+		case conversation.PinURL:
+			name := pin.Name
+			if name == "" {
+				name = pin.Value
+			}
+			links = append(links, map[string]any{"type": "resource_link", "uri": pin.Value, "name": name})
+		}
+	}
+
+	if len(notes) > 0 {
+		note := map[string]any{"type": "text", "text": "Pinned context:\n" + strings.Join(notes, "\n")}
+		blocks = append([]map[string]any{note}, blocks...)
+	}
+	return append(blocks, links...)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// defaultHandoff is the prompt of pipeline stages after the first
+const defaultHandoff = `You are a stage in a multi-agent pipeline. {{agent}} handled the request below in the previous stage.
+
+## Request
+{{input}}
+
+## Output of {{agent}}
+{{output}}
+
+## Workspace changes
+` + "```diff\n{{diff}}\n```\n"
+
+// handlePipelines lists configured pipelines
+func (s *Server) handlePipelines(w http.ResponseWriter, r *http.Request) {
+	pipelines := s.config.Pipelines
+	if pipelines == nil {
+		pipelines = []config.PipelineConfig{}
+	}
+	writeJSON(w, map[string]any{"pipelines": pipelines})
+}
+
+// findPipeline returns the pipeline requested explicitly or via "@<id>"
+func (s *Server) findPipeline(req chatRequest) *config.PipelineConfig {
+	id := req.Pipeline
+	if id == "" {
+		id = s.currentRouter().DetectPipeline(req.Message)
+	}
+	if id == "" {
+		return nil
+	}
+	return s.config.FindPipeline(id)
+}
+
+// beginStage announces a pipeline stage to the client and records a stage
+// boundary in the conversation
+func (s *Server) beginStage(convID string, p *config.PipelineConfig, i int, sendEvent func(string, any)) {
+	stage := p.Stages[i]
+	name := stage.Name
+	if name == "" {
+		name = stage.Agent
+	}
+	label := fmt.Sprintf("Stage %d/%d · %s", i+1, len(p.Stages), name)
+
+	sendEvent("stage", map[string]any{
+		"pipeline": p.ID,
+		"index":    i,
+		"total":    len(p.Stages),
+		"name":     name,
+		"agent":    stage.Agent,
+		"label":    label,
+	})
+	s.conversations.AddAssistantMessage(convID, "**"+label+"**", stage.Agent)
+}
+
+// runPipelineStages runs the stages after the first, feeding each stage the
+// previous output and the workspace diff. Returns the last stage's result.
+func (s *Server) runPipelineStages(p *config.PipelineConfig, base turn, input string, prev *turnResult) (*turnResult, error) {
+	root := s.resolveWorkspacePath(base.workspaceID)
+	for i := 1; i < len(p.Stages); i++ {
+		if prev.Result["stopReason"] == "cancelled" {
+			break
+		}
+		stage := p.Stages[i]
+		s.beginStage(base.convID, p, i, base.sendEvent)
+
+		prompt := expandPrompt(defaultHandoff, stage.Prompt, map[string]string{
+			"{{input}}":  input,
+			"{{output}}": orNone(prev.Text),
+			"{{diff}}":   orNone(workspaceDiff(root)),
+			"{{agent}}":  p.Stages[i-1].Agent,
+		})
+
+		t := base
+		t.agentID = stage.Agent
+		t.prompt = textPrompt(prompt)
+		t.ready = nil
+		res, err := s.runTurn(&t)
+		if err != nil {
+			return prev, fmt.Errorf("stage %d (%s): %w", i+1, stage.Agent, err)
+		}
+		s.persistConversation(base.convID)
+		prev = res
+	}
+	return prev, nil
+}
+
+// expandPrompt expands a configured prompt. Instructions without
+// placeholders are placed before the default template.
+func expandPrompt(defaultTmpl, instructions string, vars map[string]string) string {
+	tmpl := defaultTmpl
+	if instructions != "" {
+		if strings.Contains(instructions, "{{") {
+			tmpl = instructions
+		} else {
+			tmpl = instructions + "\n\n" + defaultTmpl
+		}
+	}
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, k, v)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+func orNone(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// pollWait is how long a poll waits for new events, below the idle
+	// timeouts of common proxies
+	pollWait = 25 * time.Second
+	// pollKeep is how long a finished turn's events stay available
+	pollKeep = 5 * time.Minute
+)
+
+// polledEvent is one event of a turn as returned by /api/chat/poll
+type polledEvent struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// turnEvents buffers the events of one chat turn for polling clients
+type turnEvents struct {
+	mu     sync.Mutex
+	events []polledEvent
+	done   bool
+	wake   chan struct{} // Closed when events are added or the turn ends
+}
+
+func (t *turnEvents) add(event string, data any) {
+	raw, _ := json.Marshal(data)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, polledEvent{Event: event, Data: raw})
+	close(t.wake)
+	t.wake = make(chan struct{})
+}
+
+func (t *turnEvents) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done = true
+	close(t.wake)
+	t.wake = make(chan struct{})
+}
+
+// since returns the events after cursor with the cursor following them,
+// whether the turn ended and a channel closed on the next change
+func (t *turnEvents) since(cursor int) ([]polledEvent, int, bool, <-chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cursor = min(max(cursor, 0), len(t.events))
+	return append([]polledEvent{}, t.events[cursor:]...), len(t.events), t.done, t.wake
+}
+
+// turnBuffers holds the events of recent chat turns by turn ID
+type turnBuffers struct {
+	mu   sync.Mutex
+	byID map[string]*turnEvents
+}
+
+// start creates the buffer of a turn, false when the ID is taken
+func (b *turnBuffers) start(turnID string) (*turnEvents, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.byID == nil {
+		b.byID = make(map[string]*turnEvents)
+	}
+	if _, ok := b.byID[turnID]; ok {
+		return nil, false
+	}
+	t := &turnEvents{wake: make(chan struct{})}
+	b.byID[turnID] = t
+	return t, true
+}
+
+func (b *turnBuffers) get(turnID string) *turnEvents {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.byID[turnID]
+}
+
+// finish ends a turn, dropping its events after pollKeep
+func (b *turnBuffers) finish(turnID string) {
+	if t := b.get(turnID); t != nil {
+		t.finish()
+	}
+	time.AfterFunc(pollKeep, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.byID, turnID)
+	})
+}
+
+// handleChatPoll is the long-poll transport for proxies that buffer
+// streams: POST starts a chat turn like /api/chat and returns its turnId
+// right away, GET ?turnId=&cursor= returns the events after cursor as soon
+// as there are any, or after pollWait
+func (s *Server) handleChatPoll(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if s.AgentsPaused() {
+			writeError(w, errAgentsPaused.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if req.TurnID == "" {
+			req.TurnID = generateUUID()
+		}
+		buf, ok := s.polls.start(req.TurnID)
+		if !ok {
+			writeError(w, "turnId already in use", http.StatusConflict)
+			return
+		}
+
+		deliver := buf.add
+		coalescer := newChunkCoalescer(r, deliver)
+		if coalescer != nil {
+			deliver = coalescer.Deliver
+		}
+		go func() {
+			defer s.polls.finish(req.TurnID)
+			stream, closeStream := s.newEventStream(chatTopic, deliver)
+			defer closeStream()
+			if coalescer != nil {
+				defer coalescer.Flush()
+			}
+			s.runChat(req, stream)
+		}()
+		writeJSON(w, map[string]any{"turnId": req.TurnID})
+
+	case "GET":
+		buf := s.polls.get(r.URL.Query().Get("turnId"))
+		if buf == nil {
+			writeError(w, "Unknown or expired turn", http.StatusNotFound)
+			return
+		}
+		cursor, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+
+		timeout := time.NewTimer(pollWait)
+		defer timeout.Stop()
+		for {
+			events, next, done, wake := buf.since(cursor)
+			if len(events) == 0 && !done {
+				select {
+				case <-wake:
+					continue
+				case <-timeout.C:
+				case <-r.Context().Done():
+					return
+				}
+			}
+			writeJSON(w, map[string]any{"events": events, "cursor": next, "done": done})
+			return
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// projectConfig returns the .acpone.json settings of a workspace, or nil.
+// Unless the user trusts the workspace, settings that run commands or turn
+// approvals off are dropped: the file comes with whatever repo was cloned.
+func (s *Server) projectConfig(workspaceID string) *config.ProjectConfig {
+	root := s.resolveWorkspacePath(workspaceID)
+	pc, err := config.LoadProject(root)
+	if err != nil {
+		logger.Warn("ignoring project settings", "error", err)
+		return nil
+	}
+	if pc != nil && !s.config.TrustsProject(root) {
+		if dropped := pc.Restrict(); len(dropped) > 0 {
+			if _, warned := s.untrustedProjects.LoadOrStore(root, true); !warned {
+				logger.Warn("ignoring project settings of an untrusted workspace, add it to trustedProjects to allow them",
+					"workspace", root, "settings", dropped)
+			}
+		}
+	}
+	return pc
+}
+
+// defaultAgentFor returns the project's default agent, falling back to the global one
+func (s *Server) defaultAgentFor(workspaceID string) string {
+	if pc := s.projectConfig(workspaceID); pc != nil && pc.DefaultAgent != "" {
+		if s.currentRouter().HasAgent(pc.DefaultAgent) {
+			return pc.DefaultAgent
+		}
+		logger.Warn("project default agent not found", "agent", pc.DefaultAgent)
+	}
+	return s.config.DefaultAgent
+}
+
+// applyProjectEnv sets the project's env vars on the agent for the duration
+// of a turn and returns the function ending it. Agent processes are shared
+// between workspaces: a turn needing other vars than the running process was
+// started with waits until the turns using it have ended, then restarts it
+// (its sessions are re-created on demand).
+func (s *Server) applyProjectEnv(agentID string, pc *config.ProjectConfig, sendEvent func(string, any)) func() {
+	var env map[string]string
+	if pc != nil {
+		env = pc.Env
+	}
+	release := s.agentEnvs.acquire(agentID, env, func() {
+		sendEvent("status", map[string]string{"message": fmt.Sprintf("Waiting for %s to finish turns in another project...", agentID)})
+	})
+	proc, err := s.agents.Get(agentID)
+	if err != nil {
+		return release
+	}
+	if proc.SetProjectEnv(env) {
+		logger.Info("restarting agent to apply project env", "agent", agentID)
+		s.agents.Stop(agentID)
+		s.resetAgentState(agentID)
+	}
+	return release
+}
+
+// agentEnvs tracks the project env each agent's turns run with. Turns with
+// the same env share the process; a turn with another env waits until no
+// turn uses it.
+type agentEnvs struct {
+	mu     sync.Mutex
+	ended  *sync.Cond
+	agents map[string]*agentEnvUse
+}
+
+type agentEnvUse struct {
+	env   map[string]string
+	turns int
+}
+
+// acquire waits until the agent is free to run with env, calling waiting
+// once if it has to, and returns the function ending the turn's use
+func (a *agentEnvs) acquire(agentID string, env map[string]string, waiting func()) func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.agents == nil {
+		a.agents = make(map[string]*agentEnvUse)
+		a.ended = sync.NewCond(&a.mu)
+	}
+	use := a.agents[agentID]
+	if use == nil {
+		use = &agentEnvUse{}
+		a.agents[agentID] = use
+	}
+	busy := func() bool { return use.turns > 0 && !maps.Equal(use.env, env) }
+	if busy() {
+		a.mu.Unlock()
+		waiting()
+		a.mu.Lock()
+		for busy() {
+			a.ended.Wait()
+		}
+	}
+	use.env = env
+	use.turns++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if use.turns--; use.turns == 0 {
+				a.ended.Broadcast()
+			}
+		})
+	}
+}
+
+// permissionModeFor returns the effective permission mode of an agent
+func (s *Server) permissionModeFor(agentID string, pc *config.ProjectConfig) string {
+	if pc != nil && pc.PermissionMode != "" {
+		return pc.PermissionMode
+	}
+	if a := s.findAgent(agentID); a != nil {
+		return a.PermissionMode
+	}
+	return ""
+}
+
+// mcpServersFor returns the MCP servers passed to session/new
+func mcpServersFor(pc *config.ProjectConfig) []any {
+	servers := []any{}
+	if pc != nil {
+		for _, srv := range pc.MCPServers {
+			servers = append(servers, srv)
+		}
+	}
+	return servers
+}
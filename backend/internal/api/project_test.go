@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+func TestUntrustedProjectSettings(t *testing.T) {
+	s, _ := newTestServer(t, "claude", sessionCountingAgent(nil))
+	root := s.resolveWorkspacePath("default")
+	project := `{"defaultAgent": "claude", "permissionMode": "bypass", "env": {"NODE_OPTIONS": "-r ./x.js"},
+		"mcpServers": [{"name": "x", "command": "./x"}], "hooks": [{"command": "./x"}], "ignore": ["*.log"]}`
+	if err := os.WriteFile(filepath.Join(root, ".acpone.json"), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pc := s.projectConfig("default")
+	if pc == nil || len(pc.Ignore) != 1 {
+		t.Fatalf("project settings not loaded: %+v", pc)
+	}
+	if pc.Env != nil || pc.Hooks != nil || len(mcpServersFor(pc)) != 0 || s.permissionModeFor("claude", pc) == "bypass" {
+		t.Fatalf("untrusted project kept restricted settings: %+v", pc)
+	}
+
+	s.config.TrustedProjects = []string{root}
+	pc = s.projectConfig("default")
+	if pc.Env == nil || len(pc.Hooks) != 1 || len(mcpServersFor(pc)) != 1 || s.permissionModeFor("claude", pc) != "bypass" {
+		t.Fatalf("trusted project lost settings: %+v", pc)
+	}
+}
+
+func TestAgentEnvsWaitForOtherEnv(t *testing.T) {
+	var envs agentEnvs
+	a, b := map[string]string{"X": "a"}, map[string]string{"X": "b"}
+	waited := func() { t.Error("same env waited") }
+
+	release1 := envs.acquire("claude", a, waited)
+	release2 := envs.acquire("claude", map[string]string{"X": "a"}, waited)
+	envs.acquire("codex", b, waited)()
+
+	acquired := make(chan func())
+	waiting := make(chan struct{})
+	go func() { acquired <- envs.acquire("claude", b, func() { close(waiting) }) }()
+	<-waiting
+	release1()
+	select {
+	case <-acquired:
+		t.Fatal("other env acquired while a turn still runs")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release2()
+	release2() // Ending twice is harmless
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(2 * time.Second):
+		t.Fatal("other env not acquired after the turns ended")
+	}
+}
+
+func TestProjectEnvSwitchWaitsForTurn(t *testing.T) {
+	var prompts atomic.Int32
+	s, hs := newTestServer(t, "claude", promptingAgent(&prompts, func(int32) (string, any, error) {
+		time.Sleep(200 * time.Millisecond)
+		return "ok", map[string]any{"stopReason": "end_turn"}, nil
+	}))
+	for i, id := range []string{"a", "b"} {
+		root := t.TempDir()
+		project := fmt.Sprintf(`{"env": {"PROJECT": "%d"}}`, i)
+		if err := os.WriteFile(filepath.Join(root, ".acpone.json"), []byte(project), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.workspaceStore.Add(config.WorkspaceConfig{ID: id, Name: id, Path: root}); err != nil {
+			t.Fatal(err)
+		}
+		s.config.TrustedProjects = append(s.config.TrustedProjects, root)
+	}
+
+	// The second project's turn must not restart the agent under the first
+	var wg sync.WaitGroup
+	replies := make([]string, 2)
+	for i, id := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			replies[i] = chatIn(t, hs, id)
+		}(i, id)
+		time.Sleep(50 * time.Millisecond)
+	}
+	wg.Wait()
+
+	for i, reply := range replies {
+		if !strings.Contains(reply, "event: done") || strings.Contains(reply, "event: error") {
+			t.Errorf("turn %d failed: %s", i, reply)
+		}
+	}
+	if proc, _ := s.agents.Get("claude"); proc.Info().Starts != 2 {
+		t.Errorf("agent started %d times, want 2", proc.Info().Starts)
+	}
+}
+
+// chatIn runs a turn of a new conversation in a workspace and returns the
+// streamed events
+func chatIn(t *testing.T, hs *httptest.Server, workspaceID string) string {
+	body, _ := json.Marshal(map[string]any{"workspaceId": workspaceID, "message": "hi"})
+	resp, err := hs.Client().Post(hs.URL+"/api/chat", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return string(data)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/recentfiles"
+)
+
+// handleRecentFiles returns recently mentioned, read or edited files
+func (s *Server) handleRecentFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 20
+	if n, err := parseInt(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+
+	root := s.resolveWorkspacePath(r.URL.Query().Get("workspaceId"))
+	writeJSON(w, map[string]any{"files": s.recentFiles.List(root, limit)})
+}
+
+// toolFiles returns the file locations reported by a tool call update and
+// whether the tool read or edited them
+func toolFiles(msg *jsonrpc.Message) ([]string, string) {
+	var params struct {
+		Update struct {
+			SessionUpdate string `json:"sessionUpdate"`
+			Kind          string `json:"kind"`
+			Locations     []struct {
+				Path string `json:"path"`
+			} `json:"locations"`
+		} `json:"update"`
+	}
+	if msg.Method != "session/update" || msg.ParseParams(&params) != nil {
+		return nil, ""
+	}
+	update := params.Update
+	if update.SessionUpdate != "tool_call" && update.SessionUpdate != "tool_call_update" {
+		return nil, ""
+	}
+
+	var action string
+	switch update.Kind {
+	case "read":
+		action = recentfiles.Read
+	case "edit", "delete", "move":
+		action = recentfiles.Edited
+	default:
+		return nil, ""
+	}
+	var paths []string
+	for _, loc := range update.Locations {
+		if loc.Path != "" {
+			paths = append(paths, loc.Path)
+		}
+	}
+	return paths, action
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+var (
+	// Provider rate-limit and overload errors as agents relay them, e.g.
+	// "API Error: 429 rate_limit_error", "529 Overloaded", "RESOURCE_EXHAUSTED"
+	rateLimitPattern = regexp.MustCompile(`(?i)rate[ _-]?limit|too many requests|\b429\b|overloaded|\b529\b|resource[ _]exhausted|quota exceeded`)
+	// Wait hints such as "retry after 30 seconds" or "Retry-After: 12"
+	retryAfterPattern = regexp.MustCompile(`(?i)retry[ _-]?after\D{0,4}(\d+(?:\.\d+)?)\s*(ms|milliseconds?)?`)
+)
+
+// rateLimitedError returns the message of a JSON-RPC error the agent
+// relayed for a provider rate limit or overload, which goes away by waiting.
+// Other failures, such as the agent exiting, are never retried.
+func rateLimitedError(err error) string {
+	var rpcErr *jsonrpc.Error
+	if !errors.As(err, &rpcErr) || !rateLimitPattern.MatchString(rpcErr.Error()) {
+		return ""
+	}
+	return rpcErr.Error()
+}
+
+// retryAfter returns the wait a rate-limit message asks for, or 0
+func retryAfter(msg string) time.Duration {
+	m := retryAfterPattern.FindStringSubmatch(msg)
+	if m == nil {
+		return 0
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0
+	}
+	if m[2] != "" {
+		return time.Duration(n * float64(time.Millisecond))
+	}
+	return time.Duration(n * float64(time.Second))
+}
+
+// rateLimitedStop returns the stop reason of a completed prompt that ended
+// on a rate limit, for agents reporting it as e.g. "rate_limited". The reply
+// text is never inspected, an answer may well talk about rate limits.
+func rateLimitedStop(response *jsonrpc.Message) string {
+	var result struct {
+		StopReason string `json:"stopReason"`
+	}
+	response.ParseResult(&result)
+	if !rateLimitPattern.MatchString(result.StopReason) {
+		return ""
+	}
+	return result.StopReason
+}
+
+// retryWaits are turns waiting out a rate limit, by agent session. Canceling
+// the chat ends the wait, the agent has nothing running to cancel.
+type retryWaits struct {
+	mu    sync.Mutex
+	waits map[string]chan struct{}
+}
+
+func (r *retryWaits) begin(sessionID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.waits == nil {
+		r.waits = make(map[string]chan struct{})
+	}
+	r.waits[sessionID] = ch
+	return ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.waits[sessionID] == ch {
+			delete(r.waits, sessionID)
+		}
+	}
+}
+
+// cancel ends the wait of a session, reporting whether it was waiting
+func (r *retryWaits) cancel(sessionID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.waits[sessionID]
+	if ok {
+		close(ch)
+		delete(r.waits, sessionID)
+	}
+	return ok
+}
+
+// waitRetry waits before retry attempt of a rate-limited prompt, sending a
+// "retry" event with the remaining time every second. It returns false when
+// the chat was canceled meanwhile.
+func (s *Server) waitRetry(sessionID string, attempt int, reason string, sendEvent func(string, any)) bool {
+	retries := s.config.Retry.Retries()
+	delay := s.config.Retry.Delay(attempt)
+	if hint := retryAfter(reason); hint > delay {
+		delay = min(hint, s.config.Retry.MaxDelay())
+	}
+
+	canceled, done := s.retries.begin(sessionID)
+	defer done()
+	send := func(remaining time.Duration, message string) {
+		sendEvent("retry", map[string]any{
+			"attempt":     attempt,
+			"maxRetries":  retries,
+			"delayMs":     delay.Milliseconds(),
+			"remainingMs": remaining.Milliseconds(),
+			"reason":      reason,
+			"message":     message,
+		})
+	}
+	deadline := time.Now().Add(delay)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			send(0, fmt.Sprintf("Retrying (%d/%d)...", attempt, retries))
+			return true
+		}
+		seconds := (remaining + time.Second - 1) / time.Second
+		send(remaining, fmt.Sprintf("Rate limited, retrying in %ds (%d/%d)", seconds, attempt, retries))
+		select {
+		case <-canceled:
+			return false
+		case <-time.After(min(remaining, time.Second)):
+		}
+	}
+}
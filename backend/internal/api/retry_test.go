@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+func TestRateLimitSignals(t *testing.T) {
+	errorTests := []struct {
+		err  error
+		want bool
+	}{
+		{&jsonrpc.Error{Code: jsonrpc.InternalError, Message: "API Error: 429 rate_limit_error"}, true},
+		{fmt.Errorf("prompt: %w", &jsonrpc.Error{Code: jsonrpc.InternalError, Message: "529 Overloaded"}), true},
+		{&jsonrpc.Error{Code: jsonrpc.InternalError, Message: "invalid api key"}, false},
+		{errors.New("agent exited: 429 too many requests"), false},
+		{nil, false},
+	}
+	for _, tt := range errorTests {
+		if got := rateLimitedError(tt.err) != ""; got != tt.want {
+			t.Errorf("rateLimitedError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+
+	stopTests := map[string]bool{"rate_limited": true, "overloaded": true, "end_turn": false, "refusal": false}
+	for stopReason, want := range stopTests {
+		result, _ := json.Marshal(map[string]string{"stopReason": stopReason})
+		if got := rateLimitedStop(&jsonrpc.Message{Result: result}) != ""; got != want {
+			t.Errorf("rateLimitedStop(%q) = %v, want %v", stopReason, got, want)
+		}
+	}
+}
+
+// promptingAgent answers each prompt through reply, given the prompt's
+// number from 1, and counts them
+func promptingAgent(prompts *atomic.Int32, reply func(n int32) (string, any, error)) agent.ServeFunc {
+	return func(r io.Reader, w io.Writer) error {
+		return mockagent.NewConn(w, func(c *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+			switch msg.Method {
+			case "initialize":
+				return map[string]any{"protocolVersion": 1, "agentCapabilities": map[string]any{}}, nil
+			case "session/new":
+				return map[string]any{"sessionId": "s1"}, nil
+			case "session/prompt":
+				text, result, err := reply(prompts.Add(1))
+				if text != "" {
+					c.Update("s1", map[string]any{
+						"sessionUpdate": "agent_message_chunk",
+						"content":       map[string]string{"type": "text", "text": text},
+					})
+				}
+				return result, err
+			default:
+				return map[string]any{}, nil
+			}
+		}, nil).Serve(r)
+	}
+}
+
+func TestReplyAboutRateLimitsIsNotRetried(t *testing.T) {
+	var prompts atomic.Int32
+	s, hs := newTestServer(t, "claude", promptingAgent(&prompts, func(int32) (string, any, error) {
+		return "HTTP 429 means too many requests", map[string]any{"stopReason": "end_turn"}, nil
+	}))
+	s.config.Retry = &config.RetryConfig{InitialDelayMs: 1}
+	convID := newConversation(t, hs)
+	chat(t, hs, convID)
+
+	if n := prompts.Load(); n != 1 {
+		t.Fatalf("agent prompted %d times, want 1", n)
+	}
+	conv := s.conversations.Snapshot(convID)
+	if last := conv.Messages[len(conv.Messages)-1]; last.Content != "HTTP 429 means too many requests" {
+		t.Errorf("reply not kept: %q", last.Content)
+	}
+}
+
+func TestRateLimitErrorIsRetried(t *testing.T) {
+	var prompts atomic.Int32
+	s, hs := newTestServer(t, "claude", promptingAgent(&prompts, func(n int32) (string, any, error) {
+		if n == 1 {
+			return "", nil, &jsonrpc.Error{Code: jsonrpc.InternalError, Message: "API Error: 429 rate_limit_error"}
+		}
+		return "done", map[string]any{"stopReason": "end_turn"}, nil
+	}))
+	s.config.Retry = &config.RetryConfig{InitialDelayMs: 1}
+	chat(t, hs, newConversation(t, hs))
+
+	if n := prompts.Load(); n != 2 {
+		t.Fatalf("agent prompted %d times, want 2", n)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// defaultReviewPrompt asks the reviewer for findings on a turn's changes
+const defaultReviewPrompt = `Review the uncommitted workspace changes {{agent}} made for the request below. List bugs, risky changes and missing tests concisely, most important first. Reply "LGTM" if there is nothing to flag. Do not modify any files.
+
+## Request
+{{input}}
+
+## Changes
+` + "```diff\n{{diff}}\n```\n"
+
+// reviewConfig returns the workspace's review settings when its reviewer
+// agent exists
+func (s *Server) reviewConfig(project *config.ProjectConfig) *config.ReviewConfig {
+	if project == nil || project.Review == nil || s.findAgent(project.Review.Agent) == nil {
+		return nil
+	}
+	return project.Review
+}
+
+// runReview sends the changes of a turn to the reviewer agent. Its findings
+// are recorded as "review" messages. edited lists files the author reported
+// changing, used when the workspace is not a git repository.
+func (s *Server) runReview(review *config.ReviewConfig, base turn, input, author string, edited []string) error {
+	diff := workspaceDiff(s.resolveWorkspacePath(base.workspaceID))
+	if diff == "" {
+		diff = "Edited files:\n" + strings.Join(edited, "\n")
+	}
+
+	label := "Review · " + review.Agent
+	base.sendEvent("review", map[string]any{
+		"agent": review.Agent,
+		"label": label,
+	})
+	s.conversations.AddAnnotatedMessage(base.convID, "**"+label+"**", review.Agent, "review")
+
+	t := base
+	t.agentID = review.Agent
+	t.kind = "review"
+	t.ready = nil
+	t.prompt = textPrompt(expandPrompt(defaultReviewPrompt, review.Prompt, map[string]string{
+		"{{input}}": input,
+		"{{diff}}":  diff,
+		"{{agent}}": author,
+	}))
+	_, err := s.runTurn(&t)
+	return err
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/router"
+)
+
+// handleRouteExplain dry-runs the router on ?text= and reports which
+// strategy and rule picked the agent
+func (s *Server) handleRouteExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	text := r.URL.Query().Get("text")
+	writeJSON(w, s.currentRouter().Explain(router.RouteContext{PromptText: text}))
+}
+
+// routeChat picks the agent of a chat turn. Without a pipeline, team or
+// matching routing strategy the conversation stays with its active agent,
+// falling back to the default agent when that one is unavailable.
+func (s *Server) routeChat(conv *conversation.Conversation, message string, pipeline *config.PipelineConfig, team *config.TeamConfig) router.Decision {
+	if team != nil {
+		m := firstMember(team)
+		return router.Decision{
+			Agent:    m.Agent,
+			Strategy: "team",
+			Rule:     team.ID,
+			Reason:   fmt.Sprintf("team %s starts with its %s %s", team.ID, m.Role, m.Agent),
+		}
+	}
+	if pipeline != nil {
+		return router.Decision{
+			Agent:    pipeline.Stages[0].Agent,
+			Strategy: "pipeline",
+			Rule:     pipeline.ID,
+			Reason:   fmt.Sprintf("pipeline %s starts with %s", pipeline.ID, pipeline.Stages[0].Agent),
+		}
+	}
+	rt := s.currentRouter()
+	if d := rt.Match(router.RouteContext{PromptText: message}); d != nil {
+		return *d
+	}
+	if !rt.HasAgent(conv.ActiveAgent) {
+		return router.Decision{
+			Agent:    rt.DefaultAgent(),
+			Strategy: "fallback",
+			Reason:   fmt.Sprintf("%s is unavailable", conv.ActiveAgent),
+		}
+	}
+	if len(conv.Messages) == 0 {
+		return router.Decision{Agent: conv.ActiveAgent, Strategy: "default", Reason: "new conversation"}
+	}
+	return router.Decision{Agent: conv.ActiveAgent, Strategy: "active", Reason: "no routing rule matched"}
+}
+
+// mentionMode returns the conversation's mention mode, defaulting to the
+// configured one
+func (s *Server) mentionMode(conv *conversation.Conversation) string {
+	if conv.MentionMode != "" {
+		return conv.MentionMode
+	}
+	return s.config.MentionMode()
+}
+
+// lastAgent returns the agent of the latest assistant message, or the active
+// agent when none has answered yet
+func lastAgent(conv *conversation.Conversation) string {
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		if m := conv.Messages[i]; m.Role == "assistant" && m.Agent != "" {
+			return m.Agent
+		}
+	}
+	return conv.ActiveAgent
+}
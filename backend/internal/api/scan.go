@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// scanPrompt checks the text and embedded file content of a prompt for
+// secrets. With action "block" the prompt is refused; with "mask" the
+// secrets are redacted and the prompt sent. Either way a warning event
+// names the patterns that matched.
+func (s *Server) scanPrompt(agentID string, prompt []map[string]any, sendEvent func(string, any)) ([]map[string]any, error) {
+	scanner := s.scanner
+	if scanner == nil {
+		return prompt, nil
+	}
+
+	var names []string
+	scan := func(text string) string {
+		masked, found := scanner.Scan(text)
+		for _, name := range found {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		return masked
+	}
+
+	out := make([]map[string]any, 0, len(prompt))
+	for _, block := range prompt {
+		block = maps.Clone(block)
+		if text, ok := block["text"].(string); ok {
+			block["text"] = scan(text)
+		}
+		if res, ok := block["resource"].(map[string]any); ok {
+			if text, ok := res["text"].(string); ok {
+				res = maps.Clone(res)
+				res["text"] = scan(text)
+				block["resource"] = res
+			}
+		}
+		out = append(out, block)
+	}
+	if len(names) == 0 {
+		return prompt, nil
+	}
+
+	scanLog.Warn("prompt matched secret rules", "agent", agentID, "rules", strings.Join(names, ", "), "action", scanner.Action)
+	if scanner.Action == config.ScanBlock {
+		sendEvent("warning", map[string]any{
+			"message":  "Prompt blocked: it contains " + strings.Join(names, ", "),
+			"patterns": names,
+			"action":   scanner.Action,
+		})
+		return nil, fmt.Errorf("prompt blocked: it contains %s", strings.Join(names, ", "))
+	}
+	sendEvent("warning", map[string]any{
+		"message":  "Redacted " + strings.Join(names, ", ") + " from the prompt",
+		"patterns": names,
+		"action":   scanner.Action,
+	})
+	return out, nil
+}
+
+// maskSecrets redacts secrets from text kept in a conversation, so the
+// store and exports never hold what the prompt had masked
+func (s *Server) maskSecrets(text string) string {
+	if s.scanner == nil {
+		return text
+	}
+	masked, _ := s.scanner.Scan(text)
+	return masked
+}
@@ -1,19 +1,31 @@
 package api
 
 import (
+	"context"
 	"embed"
-	"io"
+	"errors"
 	"io/fs"
-	"log"
 	"net/http"
+	"runtime/debug"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/catalog"
 	"github.com/daodao97/acpone/internal/config"
 	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/dlp"
+	"github.com/daodao97/acpone/internal/eventlog"
+	"github.com/daodao97/acpone/internal/events"
+	"github.com/daodao97/acpone/internal/fileindex"
+	"github.com/daodao97/acpone/internal/recentfiles"
+	"github.com/daodao97/acpone/internal/recorder"
 	"github.com/daodao97/acpone/internal/router"
+	"github.com/daodao97/acpone/internal/sessionsearch"
+	"github.com/daodao97/acpone/internal/sessionsync"
 	"github.com/daodao97/acpone/internal/storage"
+	"github.com/daodao97/acpone/internal/trace"
 )
 
 // Server is the HTTP server
@@ -22,13 +34,30 @@ type Server struct {
 	agents         *agent.Manager
 	router         *router.Router
 	conversations  *conversation.Manager
-	sessionStore   *storage.SessionStore
+	dataBackend    storage.Backend
+	sessionStore   storage.Store
+	search         *sessionsearch.Index
 	workspaceStore *storage.WorkspaceStore
-	staticFS       fs.FS
+	frontend       frontend
+	recorder       *recorder.Recorder
+	tracer         *trace.Tracer
+	sync           *sessionsync.Service
+	fileIndex      *fileindex.Manager
+	recentFiles    *recentfiles.Tracker
+	events         *events.Bus
+	eventLog       *eventlog.Log
+	metrics        serverMetrics
+	// Guards config.Agents and router, swapped when agents are added or
+	// changed (agentconfig.go)
+	configMu sync.RWMutex
 
-	// Per-conversation agent sessions: convID -> agentID -> sessionID
-	agentSessions map[string]map[string]string
-	initialized   map[string]bool
+	// Per-conversation agent sessions
+	agentSessions agentSessions
+	// Conversations answering the threads of OpenAI clients
+	openaiThreads openaiThreads
+	// Initialize handshake of each agent, guarded by initMu
+	initialized map[string]*agentInit
+	initMu      sync.Mutex
 
 	// Cached commands per agent
 	agentCommands   map[string][]SlashCommand
@@ -37,120 +66,279 @@ type Server struct {
 	// Setup status cache
 	setupStatus *SetupStatus
 	setupMu     sync.RWMutex
-	setupSubs   map[chan SetupStatus]struct{}
-	setupSubsMu sync.RWMutex
+	installs    installJobs
+	logins      agentLogins
+	// Installed and newest agent package versions
+	versions versionStore
+
+	// Permission requests waiting for an answer
+	permissions pendingPermissions
+	// Turns waiting to retry a rate-limited prompt
+	retries retryWaits
+
+	// Files changed by each conversation's last turn, for /commit
+	turnChanges turnChanges
+	// Chat turns in progress, for /api/status
+	turns activeTurns
+	pause agentPause
+	// Buffered events of recent chat turns, for /api/chat/poll
+	polls turnBuffers
+	// Project env of the turns using each agent
+	agentEnvs agentEnvs
+	// Secret scanner of outgoing prompts, nil when scanning is off
+	scanner *dlp.Scanner
+	// Untrusted workspaces already warned about ignored project settings
+	untrustedProjects sync.Map
+	// Measured workspace disk usage, for /api/workspaces/{id}/usage
+	usage usageCache
+	// Idle conversations waiting for memory extraction
+	memory memoryJobs
+
+	// Known agents and CLIs, for setup and one-click adding
+	catalog *catalog.Catalog
+
+	// The listening HTTP servers, closed by Shutdown
+	httpServers []*http.Server
+	httpMu      sync.Mutex
+	// Closed when another instance asks to take over the port
+	shutdownReq  chan struct{}
+	shutdownOnce sync.Once
 }
 
+// shutdownGrace is how long Shutdown waits for requests in flight before
+// closing the connections left, such as event streams
+const shutdownGrace = 5 * time.Second
+
 // NewServer creates a new HTTP server
 func NewServer(cfg *config.Config, staticFS fs.FS) *Server {
 	s := &Server{
-		config:         cfg,
-		agents:         agent.NewManager(cfg),
-		router:         router.New(cfg),
-		conversations:  conversation.NewManager(),
-		sessionStore:   storage.NewSessionStore(""),
-		workspaceStore: storage.NewWorkspaceStore(""),
-		staticFS:       staticFS,
-		agentSessions:  make(map[string]map[string]string),
-		initialized:    make(map[string]bool),
-		agentCommands:  make(map[string][]SlashCommand),
-		setupSubs:      make(map[chan SetupStatus]struct{}),
+		config:        cfg,
+		agents:        agent.NewManager(cfg),
+		router:        router.New(cfg),
+		scanner:       dlp.New(cfg.Scan),
+		conversations: conversation.NewManager(),
+		initialized:   make(map[string]*agentInit),
+		agentCommands: make(map[string][]SlashCommand),
+		fileIndex:     fileindex.NewManager(),
+		recentFiles:   recentfiles.New(),
+		events:        events.New(),
+		shutdownReq:   make(chan struct{}),
 	}
+	s.frontend.embedded = staticFS
 
-	s.loadPersistedWorkspaces()
+	s.setupMetrics()
+	s.loadCatalog()
+	s.setupStorage()
+	s.setupAgentRestarts()
+	s.frontend.load()
+	// Kill agents left running by a previous acpone that was killed hard
+	agent.CleanupOrphans()
+	s.migrateWorkspaces()
+	s.setupSync()
+	s.setupDebug()
+	s.setupEventLog()
+	s.setupTranscripts()
+	s.setupAgentLog()
+	s.setupSlack()
+	s.setupUploadCleanup()
+	s.setupTrashPurge()
 	s.initSetupStatus()
+	s.setupVersionCheck()
+	s.publishVersion()
 	go s.checkDependenciesAsync()
+	go s.prestartAgents()
 	return s
 }
 
-func (s *Server) loadPersistedWorkspaces() {
-	persisted := s.workspaceStore.Load()
-	for _, ws := range persisted {
-		exists := false
-		for _, existing := range s.config.Workspaces {
-			if existing.ID == ws.ID {
-				exists = true
-				break
-			}
-		}
-		if !exists {
-			s.config.Workspaces = append(s.config.Workspaces, ws)
-		}
+// setupStorage creates the session and workspace stores on the configured
+// backend, falling back to local storage and JSON session files when the
+// backend or session database is unusable
+func (s *Server) setupStorage() {
+	backend, err := storage.NewBackend(s.config.Storage)
+	if err != nil {
+		storageLog.Error("storage backend unavailable, using local storage", "error", err)
+		backend, _ = storage.NewBackend(nil)
+	}
+	s.sessionStore, s.dataBackend, err = storage.OpenSessions(s.config.Storage, backend)
+	if err != nil {
+		storageLog.Error("session database unavailable, using JSON session files", "error", err)
 	}
+	s.search = sessionsearch.New(s.sessionStore)
+	s.workspaceStore = storage.NewWorkspaceStoreWithBackend(backend, "workspaces.json")
 }
 
-// Handler returns the HTTP handler
+// migrateWorkspaces moves workspaces declared in the config file into the
+// workspace store, which is the single source of truth, and rewrites the
+// config file without them
+func (s *Server) migrateWorkspaces() {
+	migrated, err := s.workspaceStore.Migrate(s.config)
+	if err != nil {
+		logger.Error("failed to migrate workspaces", "error", err)
+		return
+	}
+	if !migrated || config.LoadedConfigPath == "" {
+		return
+	}
+	if err := s.config.Save(config.LoadedConfigPath); err != nil {
+		logger.Error("failed to remove migrated workspaces from config", "error", err)
+		return
+	}
+	logger.Info("migrated workspaces to the workspace store", "config", config.LoadedConfigPath)
+}
+
+// Workspaces returns the workspace store
+func (s *Server) Workspaces() *storage.WorkspaceStore {
+	return s.workspaceStore
+}
+
+// Handler returns the HTTP handler serving all endpoints
 func (s *Server) Handler() http.Handler {
+	return s.handler(false)
+}
+
+// handler returns the HTTP handler, without the admin endpoints when public
+// is set
+func (s *Server) handler(public bool) http.Handler {
 	mux := http.NewServeMux()
 
 	// API routes
+	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/version", s.handleVersion)
+	mux.Handle(metricsPath, s.metrics.registry.Handler())
+	mux.HandleFunc("/api/shutdown", s.handleShutdown)
+	mux.HandleFunc("/api/auth/status", s.handleAuthStatus)
+	mux.HandleFunc("/api/auth/login", s.handleAuthLogin)
+	mux.HandleFunc("/api/auth/logout", s.handleAuthLogout)
 	mux.HandleFunc("/api/setup/status", s.handleSetupStatus)
 	mux.HandleFunc("/api/setup/subscribe", s.handleSetupSubscribe)
 	mux.HandleFunc("/api/setup/install", s.handleSetupInstall)
+	mux.HandleFunc("/api/setup/install/cancel", s.handleSetupInstallCancel)
+	mux.HandleFunc("/api/setup/login", s.handleSetupLogin)
+	mux.HandleFunc("/api/setup/login/input", s.handleSetupLoginInput)
+	mux.HandleFunc("/api/setup/refresh-path", s.handleSetupRefreshPath)
+	mux.HandleFunc("/api/config/validate", s.handleConfigValidate)
 	mux.HandleFunc("/api/agents", s.handleAgents)
+	mux.HandleFunc("/api/catalog", s.handleCatalog)
+	mux.HandleFunc("/api/catalog/add", s.handleCatalogAdd)
 	mux.HandleFunc("/api/agents/update", s.handleAgentUpdate)
+	mux.HandleFunc("/api/agents/pause", s.handleAgentsPause)
+	mux.HandleFunc("/api/agents/", s.handleAgentByID)
 	mux.HandleFunc("/api/workspaces", s.handleWorkspaces)
 	mux.HandleFunc("/api/workspaces/files", s.handleWorkspaceFiles)
+	mux.HandleFunc("/api/workspaces/files/reindex", s.handleReindexFiles)
+	mux.HandleFunc("/api/workspaces/files/download", s.handleFileDownload)
+	mux.HandleFunc("/api/workspaces/", s.handleWorkspaceByID)
 	mux.HandleFunc("/api/sessions", s.handleSessions)
 	mux.HandleFunc("/api/sessions/new", s.handleSessionNew)
+	mux.HandleFunc("/api/sessions/merge", s.handleSessionMerge)
+	mux.HandleFunc("/api/sessions/search", s.handleSessionSearch)
+	mux.HandleFunc("/api/issues/start", s.handleIssueStart)
+	mux.HandleFunc("/api/annotations", s.handleAnnotations)
+	mux.HandleFunc("/api/stats/feedback.csv", s.handleFeedbackExport)
 	mux.HandleFunc("/api/sessions/", s.handleSessionByID)
 	mux.HandleFunc("/api/chat", s.handleChat)
 	mux.HandleFunc("/api/chat/cancel", s.handleChatCancel)
+	mux.HandleFunc("/api/chat/poll", s.handleChatPoll)
+	mux.HandleFunc("/api/events", s.handleEvents)
+	mux.HandleFunc("/api/events/history", s.handleEventHistory)
+	mux.HandleFunc("/api/pipelines", s.handlePipelines)
+	mux.HandleFunc("/api/teams", s.handleTeams)
+	mux.HandleFunc("/api/route/explain", s.handleRouteExplain)
 	mux.HandleFunc("/api/permission/confirm", s.handlePermissionConfirm)
+	mux.HandleFunc("/api/permissions", s.handlePermissions)
+	mux.HandleFunc("/api/permissions/subscribe", s.handlePermissionsSubscribe)
+	mux.HandleFunc("/api/permissions/answer", s.handlePermissionAnswer)
+	mux.HandleFunc("/api/files/recent", s.handleRecentFiles)
 	mux.HandleFunc("/api/upload", s.handleFileUpload)
+	mux.HandleFunc("/api/transcribe", s.handleTranscribe)
 	mux.HandleFunc("/api/upload/cleanup", s.handleFileCleanup)
+	mux.HandleFunc("/api/debug/recordings", s.handleRecordings)
+	mux.HandleFunc("/api/debug/recordings/", s.handleRecordings)
+	mux.HandleFunc("/api/sync", s.handleSync)
+	mux.HandleFunc("/api/backup", s.handleBackup)
+	mux.HandleFunc("/api/restore", s.handleRestore)
+	mux.HandleFunc("/api/frontend", s.handleFrontend)
+	mux.HandleFunc("/api/frontend/upload", s.handleFrontendUpload)
+	mux.HandleFunc("/api/frontend/rollback", s.handleFrontendRollback)
+	mux.HandleFunc("/v1/models", s.handleOpenAIModels)
+	mux.HandleFunc("/v1/chat/completions", s.handleChatCompletions)
 
-	// Static files
-	if s.staticFS != nil {
-		fileServer := http.FileServer(http.FS(s.staticFS))
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			path := strings.TrimPrefix(r.URL.Path, "/")
-			if path == "" {
-				path = "index.html"
-			}
-
-			// Try to open the file
-			f, err := s.staticFS.Open(path)
-			if err != nil {
-				// SPA fallback: serve index.html content directly
-				indexFile, err := s.staticFS.Open("index.html")
-				if err != nil {
-					http.NotFound(w, r)
-					return
-				}
-				defer indexFile.Close()
+	// Static files, from the bundle selected at request time
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		staticFS := s.frontend.current()
+		if staticFS == nil {
+			http.NotFound(w, r)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if path == "" || path == "index.html" {
+			serveIndex(w, r, staticFS)
+			return
+		}
 
-				stat, err := indexFile.Stat()
-				if err != nil {
-					http.NotFound(w, r)
-					return
-				}
+		// Try to open the file
+		f, err := staticFS.Open(path)
+		if err != nil {
+			// SPA fallback: serve index.html content directly
+			serveIndex(w, r, staticFS)
+			return
+		}
+		f.Close()
 
-				// Serve index.html with correct content type
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				http.ServeContent(w, r, "index.html", stat.ModTime(), indexFile.(io.ReadSeeker))
-				return
-			}
-			f.Close()
+		// Serve the actual file
+		cacheStatic(w, path)
+		http.FileServer(http.FS(staticFS)).ServeHTTP(w, r)
+	})
 
-			// Serve the actual file
-			fileServer.ServeHTTP(w, r)
-		})
+	var next http.Handler = mux
+	if public {
+		next = publicMiddleware(mux)
 	}
-
-	return recoveryMiddleware(corsMiddleware(mux))
+	return recoveryMiddleware(s.metricsMiddleware(mux, corsMiddleware(authMiddleware(s.config.Server.Secrets(), next))))
 }
 
-// Shutdown stops all agents
+// Shutdown stops serving, letting requests in flight finish, then stops all
+// agents
 func (s *Server) Shutdown() error {
-	return s.agents.Shutdown()
+	s.httpMu.Lock()
+	servers := s.httpServers
+	s.httpServers = nil
+	s.httpMu.Unlock()
+	if len(servers) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+		var wg sync.WaitGroup
+		for _, srv := range servers {
+			wg.Add(1)
+			go func(srv *http.Server) {
+				defer wg.Done()
+				if srv.Shutdown(ctx) != nil {
+					srv.Close()
+				}
+			}(srv)
+		}
+		wg.Wait()
+		cancel()
+	}
+
+	err := s.agents.Shutdown()
+	if s.recorder != nil {
+		s.recorder.Close()
+	}
+	if s.sync != nil {
+		s.sync.Stop()
+	}
+	s.fileIndex.Stop()
+	if s.eventLog != nil {
+		s.eventLog.Close()
+	}
+	return err
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -165,7 +353,7 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("PANIC recovered: %v\nPath: %s", err, r.URL.Path)
+				logger.Error("panic recovered", "error", err, "path", r.URL.Path, "stack", string(debug.Stack()))
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 			}
 		}()
@@ -173,9 +361,39 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// ListenAndServe starts the server
+// ListenAndServe starts the server, returning nil once Shutdown stops it.
+// With an admin port configured, addr serves everything but the admin
+// endpoints, which only the admin address serves.
 func (s *Server) ListenAndServe(addr string) error {
-	return http.ListenAndServe(addr, s.Handler())
+	var servers []*http.Server
+	if adminAddr := s.config.Server.AdminAddr(); adminAddr != "" {
+		servers = []*http.Server{
+			{Addr: addr, Handler: s.handler(true)},
+			{Addr: adminAddr, Handler: s.handler(false)},
+		}
+	} else {
+		servers = []*http.Server{{Addr: addr, Handler: s.handler(false)}}
+	}
+	s.httpMu.Lock()
+	s.httpServers = servers
+	s.httpMu.Unlock()
+
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			errs <- srv.ListenAndServe()
+		}(srv)
+	}
+	for range servers {
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			// Don't leave the other listener serving on its own
+			for _, srv := range servers {
+				srv.Close()
+			}
+			return err
+		}
+	}
+	return nil
 }
 
 // StaticFS is embedded static files (set from main)
@@ -2,16 +2,30 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/conversation"
 	"github.com/daodao97/acpone/internal/storage"
 )
 
+// handleSessions lists sessions, most recently updated first, filtered by
+// ?workspaceId=&agent=&updatedAfter= (Unix ms) and paged by ?offset=&limit=
 func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
-	sessions := s.sessionStore.List()
-	writeJSON(w, map[string]any{"sessions": sessions})
+	q := r.URL.Query()
+	query := storage.SessionQuery{
+		WorkspaceID: q.Get("workspaceId"),
+		Agent:       q.Get("agent"),
+	}
+	query.UpdatedAfter, _ = strconv.ParseInt(q.Get("updatedAfter"), 10, 64)
+	query.Offset, _ = strconv.Atoi(q.Get("offset"))
+	query.Limit, _ = strconv.Atoi(q.Get("limit"))
+	writeJSON(w, s.sessionStore.Query(query))
 }
 
 func (s *Server) handleSessionNew(w http.ResponseWriter, r *http.Request) {
@@ -28,13 +42,14 @@ func (s *Server) handleSessionNew(w http.ResponseWriter, r *http.Request) {
 	id := generateUUID()
 	workspaceID := data.WorkspaceID
 	if workspaceID == "" {
-		workspaceID = s.config.DefaultWorkspace
+		workspaceID = s.workspaceStore.Default()
 	}
 
-	session := storage.CreateSession(id, s.config.DefaultAgent, workspaceID)
+	defaultAgent := s.defaultAgentFor(workspaceID)
+	session := storage.CreateSession(id, defaultAgent, workspaceID)
 	s.sessionStore.Save(session)
-	s.conversations.Create(id, s.config.DefaultAgent, workspaceID)
-	s.agentSessions[id] = make(map[string]string)
+	s.conversations.Create(id, defaultAgent, workspaceID)
+	s.agentSessions.forget(id)
 
 	writeJSON(w, map[string]any{
 		"session": map[string]any{
@@ -55,6 +70,38 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 		writeError(w, "Session ID required", http.StatusBadRequest)
 		return
 	}
+	if id == "trash" {
+		s.handleTrash(w, r)
+		return
+	}
+	if sessionID, ok := strings.CutSuffix(id, "/restore"); ok {
+		s.handleSessionRestore(w, r, sessionID)
+		return
+	}
+	if sessionID, ok := strings.CutSuffix(id, "/export"); ok {
+		s.handleSessionExport(w, r, sessionID)
+		return
+	}
+	if sessionID, ok := strings.CutSuffix(id, "/context"); ok {
+		s.handleSessionContext(w, r, sessionID)
+		return
+	}
+	if sessionID, ok := strings.CutSuffix(id, "/annotations"); ok {
+		s.handleSessionAnnotations(w, r, sessionID)
+		return
+	}
+	if sessionID, ok := strings.CutSuffix(id, "/move"); ok {
+		s.handleSessionMove(w, r, sessionID)
+		return
+	}
+	if sessionID, ok := strings.CutSuffix(id, "/workspace"); ok {
+		s.handleSessionWorkspace(w, r, sessionID)
+		return
+	}
+	if sessionID, ok := strings.CutSuffix(id, "/commit"); ok {
+		s.handleSessionCommit(w, r, sessionID)
+		return
+	}
 
 	switch r.Method {
 	case "GET":
@@ -64,12 +111,35 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		s.restoreConversation(session)
-		writeJSON(w, map[string]any{"session": session})
+		writeJSON(w, map[string]any{
+			"session": session,
+			"context": s.contextUsage(id, session.ActiveAgent),
+		})
+
+	case "PATCH":
+		s.handleSessionUpdate(w, r, id)
 
 	case "DELETE":
+		// Sessions go to the trash unless it is off or ?permanent=1 asks
+		// to delete them right away
+		if s.config.Trash.Retention() > 0 && r.URL.Query().Get("permanent") == "" {
+			err := s.sessionStore.Trash(id)
+			if err == nil {
+				s.conversations.Delete(id)
+				s.agentSessions.forget(id)
+				writeJSON(w, map[string]any{"success": true, "trashed": true})
+				return
+			}
+			// Never stored, so there's nothing to restore later
+			if !errors.Is(err, os.ErrNotExist) {
+				writeError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		s.removeConversationUploads(id)
 		s.sessionStore.Delete(id)
 		s.conversations.Delete(id)
-		delete(s.agentSessions, id)
+		s.agentSessions.forget(id)
 		writeJSON(w, map[string]any{"success": true})
 
 	default:
@@ -77,33 +147,124 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleSessionUpdate changes a conversation's active agent, mention mode,
+// agent parameters or turn limits
+func (s *Server) handleSessionUpdate(w http.ResponseWriter, r *http.Request, id string) {
+	var data struct {
+		ActiveAgent *string         `json:"activeAgent"`
+		MentionMode *string         `json:"mentionMode"` // Empty to use the configured mode
+		AgentParams json.RawMessage `json:"agentParams"` // Replaces the parameters, null clears them
+		Limits      json.RawMessage `json:"limits"`      // Replaces the turn limits, null for the configured ones
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	var params map[string]any
+	if len(data.AgentParams) > 0 {
+		if err := json.Unmarshal(data.AgentParams, &params); err != nil {
+			writeError(w, "agentParams must be an object", http.StatusBadRequest)
+			return
+		}
+	}
+	var limits *conversation.Limits
+	if len(data.Limits) > 0 {
+		if err := json.Unmarshal(data.Limits, &limits); err != nil {
+			writeError(w, "limits must be an object", http.StatusBadRequest)
+			return
+		}
+	}
+	if limits != nil && limits.OnRepeat != "" && limits.OnRepeat != config.RepeatWarn && limits.OnRepeat != config.RepeatCancel {
+		writeError(w, "Invalid onRepeat", http.StatusBadRequest)
+		return
+	}
+	if data.ActiveAgent != nil && !s.currentRouter().HasAgent(*data.ActiveAgent) {
+		writeError(w, "Agent not found", http.StatusBadRequest)
+		return
+	}
+	if data.MentionMode != nil && !config.ValidMentionMode(*data.MentionMode) {
+		writeError(w, "Invalid mention mode", http.StatusBadRequest)
+		return
+	}
+
+	if !s.conversations.Has(id) {
+		session, err := s.sessionStore.Load(id)
+		if err != nil {
+			writeError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		s.restoreConversation(session)
+	}
+	if data.ActiveAgent != nil {
+		s.conversations.SetActiveAgent(id, *data.ActiveAgent)
+	}
+	if data.MentionMode != nil {
+		s.conversations.SetMentionMode(id, *data.MentionMode)
+	}
+	if len(data.AgentParams) > 0 {
+		s.conversations.SetAgentParams(id, params)
+	}
+	if len(data.Limits) > 0 {
+		s.conversations.SetLimits(id, limits)
+	}
+	s.persistConversation(id)
+
+	conv := s.conversations.Get(id)
+	writeJSON(w, map[string]any{
+		"activeAgent": conv.ActiveAgent,
+		"mentionMode": s.mentionMode(conv),
+		"agentParams": s.conversations.AgentParams(id),
+		"limits":      s.conversations.Limits(id),
+	})
+}
+
 func (s *Server) restoreConversation(session *storage.StoredSession) {
 	s.conversations.Create(session.ID, session.ActiveAgent, session.WorkspaceID)
+	s.conversations.SetMentionMode(session.ID, session.MentionMode)
+	s.conversations.SetAgentParams(session.ID, session.AgentParams)
+	s.conversations.SetLimits(session.ID, session.Limits)
+	s.conversations.SetPins(session.ID, session.Pins)
+	s.conversations.SetBranch(session.ID, session.Branch)
 	for _, msg := range session.Messages {
 		if msg.Role == "user" {
 			s.conversations.AddUserMessage(session.ID, msg.Content, msg.Files)
 		} else {
-			s.conversations.AddAssistantMessage(session.ID, msg.Content, msg.Agent)
+			s.conversations.AddAnnotatedMessage(session.ID, msg.Content, msg.Agent, msg.Kind)
 		}
 	}
-	s.agentSessions[session.ID] = make(map[string]string)
+	s.conversations.SetAnnotations(session.ID, session.Annotations)
+	s.conversations.SetTurns(session.ID, session.Turns)
+	s.agentSessions.forget(session.ID)
 }
 
 func (s *Server) persistConversation(convID string) {
-	conv := s.conversations.Get(convID)
+	if session := s.storedConversation(convID); session != nil {
+		s.sessionStore.Save(session)
+	}
+}
+
+// storedConversation is the session persisted for a conversation, nil when
+// it doesn't exist
+func (s *Server) storedConversation(convID string) *storage.StoredSession {
+	conv := s.conversations.Snapshot(convID)
 	if conv == nil {
-		return
+		return nil
 	}
 
-	session := &storage.StoredSession{
+	return &storage.StoredSession{
 		ID:          convID,
 		Title:       storage.GenerateTitle(conv.Messages),
 		Messages:    conv.Messages,
 		ActiveAgent: conv.ActiveAgent,
 		WorkspaceID: conv.WorkspaceID,
+		MentionMode: conv.MentionMode,
+		AgentParams: s.conversations.AgentParams(convID),
+		Limits:      s.conversations.Limits(convID),
+		Pins:        s.conversations.Pins(convID),
+		Annotations: s.conversations.Annotations(convID),
+		Turns:       s.conversations.Turns(convID),
+		Branch:      conv.Branch,
 		CreatedAt:   conv.CreatedAt,
 		UpdatedAt:   time.Now().UnixMilli(),
 	}
-
-	s.sessionStore.Save(session)
 }
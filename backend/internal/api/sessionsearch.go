@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/daodao97/acpone/internal/sessionsearch"
+)
+
+// defaultSearchLimit bounds the sessions a search returns
+const defaultSearchLimit = 50
+
+// handleSessionSearch serves GET /api/sessions/search?q=...&workspaceId=...
+// over titles, messages, tool calls and tool outputs
+func (s *Server) handleSessionSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	q := query.Get("q")
+	writeJSON(w, map[string]any{
+		"query":   sessionsearch.Parse(q),
+		"results": s.search.Search(q, query.Get("workspaceId"), limit),
+	})
+}
@@ -1,17 +1,23 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/events"
+	"github.com/daodao97/acpone/internal/installer"
 	"github.com/daodao97/acpone/internal/sysutil"
 )
 
@@ -20,79 +26,116 @@ type DependencyItem struct {
 	Name    string `json:"name"`
 	Command string `json:"command,omitempty"`
 	Package string `json:"package,omitempty"`
-	Status  string `json:"status"` // "checking", "ready", "missing", "not_installed", "installing", "error", "blocked"
+	Status  string `json:"status"` // "checking", "ready", "missing", "not_installed", "missing_offline", "needs_login", "installing", "error", "blocked"
 	Message string `json:"message,omitempty"`
 	Install string `json:"install,omitempty"`
+	// Installer is the package manager command setup can run for a missing
+	// tool, e.g. "brew install node"
+	Installer string `json:"installer,omitempty"`
+	// Login is the command that logs in an agent reported as "needs_login"
+	Login string `json:"login,omitempty"`
+	// Agent is the ID of an agent with an "install" config, which setup
+	// installs with that source's installer instead of npm
+	Agent  string `json:"agent,omitempty"`
+	Source string `json:"source,omitempty"` // uvx, pipx, cargo, binary or docker
+	// Version is the installed version; Latest is set when a newer one exists
+	Version string `json:"version,omitempty"`
+	Latest  string `json:"latest,omitempty"`
+	// Arch lists the architectures of the installed executable, e.g. "amd64"
+	// or "amd64/arm64", when it is a binary rather than a script
+	Arch string `json:"arch,omitempty"`
+}
+
+// SystemInfo tells which architecture acpone and the machine run, to
+// explain agents built for another one
+type SystemInfo struct {
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`     // Of the acpone build
+	HostArch string `json:"hostArch"` // Of the machine, differs when acpone runs emulated
 }
 
 // SetupStatus represents the overall setup status
 type SetupStatus struct {
 	Ready       bool             `json:"ready"`
-	Environment []DependencyItem `json:"environment"` // npm, npx
+	Environment []DependencyItem `json:"environment"` // npm, npx, unless no agent needs them
 	Agents      []DependencyItem `json:"agents"`      // claude, codex commands
 	ACPPackages []DependencyItem `json:"acpPackages"` // @zed-industries/xxx-acp
-}
+	Offline     bool             `json:"offline,omitempty"`
+	System      SystemInfo       `json:"system"`
 
-// Install instructions for common tools
-var installInstructions = map[string]string{
-	"npm":    "https://nodejs.org/en/download",
-	"npx":    "https://nodejs.org/en/download",
-	"node":   "https://nodejs.org/en/download",
-	"claude": "npm install -g @anthropic-ai/claude-code",
-	"codex":  "npm install -g @openai/codex",
+	// checked is closed once every check has completed
+	checked chan struct{}
 }
 
-// Agent command to npm package mapping (for auto-install)
-var agentNpmPackages = map[string]string{
-	"claude": "@anthropic-ai/claude-code",
-	"codex":  "@openai/codex",
-}
-
-// ACP package to agent command mapping
-var acpToAgentCommand = map[string]struct {
-	Name    string
-	Command string
-}{
-	"@zed-industries/claude-code-acp": {Name: "Claude", Command: "claude"},
-	"@zed-industries/codex-acp":       {Name: "Codex", Command: "codex"},
+// Install instructions for the Node.js tool chain; agent CLIs come from the
+// catalog
+var installInstructions = map[string]string{
+	"npm":  "https://nodejs.org/en/download",
+	"npx":  "https://nodejs.org/en/download",
+	"node": "https://nodejs.org/en/download",
 }
 
 // initSetupStatus initializes status with all checks in "checking" state
 func (s *Server) initSetupStatus() {
-	// Environment: npm, npx
-	env := []DependencyItem{
-		{Name: "npm", Command: "npm", Status: "checking", Message: "Checking..."},
-		{Name: "npx", Command: "npx", Status: "checking", Message: "Checking..."},
-	}
-
 	// Collect ACP packages and their required agent commands
 	acpPkgs := []DependencyItem{}
 	requiredAgents := map[string]struct {
 		Name    string
 		Command string
 	}{}
+	// Agents installed by uv, pipx, cargo, a download or docker
+	var installed []DependencyItem
+	needsNode := false
 
-	for _, agent := range s.config.Agents {
-		if agent.Command == "npx" {
-			pkgName := extractPackageName(agent.Command, agent.Args)
+	for _, a := range s.agentList() {
+		if !a.IsEnabled() {
+			continue
+		}
+		if a.Install != nil {
+			item := DependencyItem{
+				Name:    a.Name,
+				Command: a.Command,
+				Source:  a.Install.Type,
+				Agent:   a.ID,
+				Status:  "checking",
+				Message: "Checking...",
+			}
+			if inst, err := installer.New(a.Install, a.Command); err == nil {
+				item.Package = inst.String()
+			}
+			installed = append(installed, item)
+			continue
+		}
+		if !agent.IsBuiltin(a.Command) {
+			needsNode = true
+		}
+		if a.Command == "npx" {
+			pkgName := extractPackageName(a.Command, a.Args)
 			if pkgName != "" {
 				acpPkgs = append(acpPkgs, DependencyItem{
-					Name:    agent.Name,
+					Name:    a.Name,
 					Package: pkgName,
 					Status:  "checking",
 					Message: "Waiting...",
 				})
 				// Check if this ACP package requires an agent command
-				if agentInfo, ok := acpToAgentCommand[pkgName]; ok {
-					requiredAgents[agentInfo.Command] = agentInfo
+				if tool := s.catalog.RequiredTool(pkgName); tool != nil {
+					requiredAgents[tool.Command] = struct {
+						Name    string
+						Command string
+					}{Name: tool.Name, Command: tool.Command}
 				}
 			}
-		} else {
+		} else if !agent.IsBuiltin(a.Command) {
 			// Non-npx command, add to required agents
-			requiredAgents[agent.Command] = struct {
+			name := a.Command
+			if tool := s.catalog.Tool(a.Command); tool != nil {
+				name = tool.Name
+			}
+			requiredAgents[a.Command] = struct {
 				Name    string
 				Command string
-			}{Name: agent.Command, Command: agent.Command}
+			}{Name: name, Command: a.Command}
 		}
 	}
 
@@ -106,139 +149,240 @@ func (s *Server) initSetupStatus() {
 			Message: "Checking...",
 		})
 	}
+	agents = append(agents, installed...)
+
+	// Environment: npm, npx. Setups whose agents all come from other
+	// installers don't need Node.js.
+	env := []DependencyItem{}
+	if needsNode || len(installed) == 0 {
+		env = []DependencyItem{
+			{Name: "npm", Command: "npm", Status: "checking", Message: "Checking..."},
+			{Name: "npx", Command: "npx", Status: "checking", Message: "Checking..."},
+		}
+	}
 
 	s.setupMu.Lock()
 	s.setupStatus = &SetupStatus{
 		Ready:       false,
+		Offline:     s.config.Offline,
 		Environment: env,
 		Agents:      agents,
 		ACPPackages: acpPkgs,
+		System: SystemInfo{
+			OS:       runtime.GOOS,
+			Arch:     runtime.GOARCH,
+			HostArch: sysutil.HostArch(),
+		},
+		checked: make(chan struct{}),
 	}
 	s.setupMu.Unlock()
 }
 
-// checkDependenciesAsync checks all dependencies asynchronously
+// setupCheckWorkers bounds how many dependency checks run at once
+const setupCheckWorkers = 4
+
+// checkDependenciesAsync checks all dependencies concurrently, broadcasting
+// each result as soon as it completes. npm and npx are checked first since
+// the package checks need them.
 func (s *Server) checkDependenciesAsync() {
-	npmReady := false
-	npxReady := false
+	// Results go to the status being checked, even if a re-check replaces it
+	s.setupMu.RLock()
+	st := s.setupStatus
+	env := append([]DependencyItem{}, st.Environment...)
+	agents := append([]DependencyItem{}, st.Agents...)
+	pkgs := append([]DependencyItem{}, st.ACPPackages...)
+	s.setupMu.RUnlock()
+
+	sem := make(chan struct{}, setupCheckWorkers)
+	var wg sync.WaitGroup
+	check := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			fn()
+			<-sem
+			s.broadcastSetupStatus()
+		}()
+	}
 
 	// Phase 1: Check environment (npm, npx)
-	for i := 0; i < 2; i++ {
-		s.setupMu.RLock()
-		item := s.setupStatus.Environment[i]
-		s.setupMu.RUnlock()
+	envReady := true
+	for i, item := range env {
+		i, item := i, item
+		check(func() {
+			exists := commandExists(item.Command)
+			var installer string
+			if !exists && !s.config.Offline {
+				if n := findNodeInstaller(); n != nil {
+					installer = n.String()
+				}
+			}
+			// npm and npx are scripts run by node
+			arch, note := executableArch("node")
 
-		exists := commandExists(item.Command)
+			s.setupMu.Lock()
+			defer s.setupMu.Unlock()
+			dep := &st.Environment[i]
+			switch {
+			case exists:
+				dep.Status = "ready"
+				dep.Message = "Installed" + note
+				dep.Arch = arch
+			case s.config.Offline:
+				dep.Status = "missing_offline"
+				dep.Message = "Not found, install Node.js from a local installer"
+				envReady = false
+			default:
+				dep.Status = "missing"
+				dep.Message = "Not found"
+				dep.Install = installInstructions[item.Command]
+				dep.Installer = installer
+				envReady = false
+			}
+		})
+	}
+	wg.Wait()
+
+	// Phase 2: Check agent commands (claude, codex) and ACP packages
+	allReady := true
+	for i, item := range agents {
+		i, item := i, item
+		if item.Agent != "" {
+			check(func() {
+				if !s.checkInstalledAgent(&st.Agents[i], item) {
+					s.setupMu.Lock()
+					allReady = false
+					s.setupMu.Unlock()
+				}
+			})
+			continue
+		}
+		check(func() {
+			exists := commandExists(item.Command)
+			var login, arch, note string
+			if exists {
+				login = s.checkAgentAuth(item.Command)
+				arch, note = executableArch(item.Command)
+			}
 
-		s.setupMu.Lock()
-		if exists {
-			s.setupStatus.Environment[i].Status = "ready"
-			s.setupStatus.Environment[i].Message = "Installed"
-			if item.Command == "npm" {
-				npmReady = true
+			s.setupMu.Lock()
+			defer s.setupMu.Unlock()
+			dep := &st.Agents[i]
+			dep.Arch = arch
+			if exists && login != "" {
+				// Installed, so it doesn't block setup, but can't be used yet
+				dep.Status = "needs_login"
+				dep.Message = "Not logged in"
+				dep.Login = login
+				return
 			}
-			if item.Command == "npx" {
-				npxReady = true
+			if exists {
+				dep.Status = "ready"
+				dep.Message = "Installed" + note
+				return
 			}
-		} else {
-			s.setupStatus.Environment[i].Status = "missing"
-			s.setupStatus.Environment[i].Message = "Not found"
-			if inst, ok := installInstructions[item.Command]; ok {
-				s.setupStatus.Environment[i].Install = inst
+			dep.Status = "missing"
+			dep.Message = "Not found"
+			if s.config.Offline {
+				dep.Status = "missing_offline"
+				dep.Message = offlineHint(s.toolPackage(item.Command))
 			}
-		}
-		s.setupMu.Unlock()
-		s.broadcastSetupStatus()
+			dep.Install = s.toolInstall(item.Command)
+			allReady = false
+		})
 	}
 
-	// Phase 2: Check agent commands (claude, codex)
-	s.setupMu.RLock()
-	agentCount := len(s.setupStatus.Agents)
-	s.setupMu.RUnlock()
-
-	allAgentsReady := true
-	for i := 0; i < agentCount; i++ {
-		s.setupMu.RLock()
-		item := s.setupStatus.Agents[i]
-		s.setupMu.RUnlock()
-
-		exists := commandExists(item.Command)
+	for i, item := range pkgs {
+		i, item := i, item
+		if !envReady {
+			s.setupMu.Lock()
+			st.ACPPackages[i].Status = "blocked"
+			st.ACPPackages[i].Message = "Requires npm/npx"
+			allReady = false
+			s.setupMu.Unlock()
+			continue
+		}
+		check(func() {
+			cached := isPackageCached(item.Package)
 
-		s.setupMu.Lock()
-		if exists {
-			s.setupStatus.Agents[i].Status = "ready"
-			s.setupStatus.Agents[i].Message = "Installed"
-		} else {
-			s.setupStatus.Agents[i].Status = "missing"
-			s.setupStatus.Agents[i].Message = "Not found"
-			if inst, ok := installInstructions[item.Command]; ok {
-				s.setupStatus.Agents[i].Install = inst
+			s.setupMu.Lock()
+			defer s.setupMu.Unlock()
+			dep := &st.ACPPackages[i]
+			switch {
+			case cached:
+				dep.Status = "ready"
+				dep.Message = "Cached"
+			case s.config.Offline:
+				dep.Status = "missing_offline"
+				dep.Message = offlineHint(item.Package)
+				allReady = false
+			default:
+				dep.Status = "not_installed"
+				dep.Message = "Not installed"
+				allReady = false
 			}
-			allAgentsReady = false
-		}
-		s.setupMu.Unlock()
-		s.broadcastSetupStatus()
+		})
 	}
-
-	// Phase 3: Check ACP packages
-	s.setupMu.RLock()
-	acpCount := len(s.setupStatus.ACPPackages)
-	s.setupMu.RUnlock()
-
-	allACPReady := true
-	for i := 0; i < acpCount; i++ {
-		s.setupMu.RLock()
-		item := s.setupStatus.ACPPackages[i]
-		s.setupMu.RUnlock()
-
-		var status, message string
-		if !npmReady || !npxReady {
-			status = "blocked"
-			message = "Requires npm/npx"
-			allACPReady = false
-		} else if isPackageCached(item.Package) {
-			status = "ready"
-			message = "Cached"
-		} else {
-			status = "not_installed"
-			message = "Not installed"
-			allACPReady = false
-		}
-
-		s.setupMu.Lock()
-		s.setupStatus.ACPPackages[i].Status = status
-		s.setupStatus.ACPPackages[i].Message = message
-		s.setupMu.Unlock()
+	if !envReady {
 		s.broadcastSetupStatus()
 	}
+	wg.Wait()
 
 	// Final ready state
-	envReady := npmReady && npxReady
 	s.setupMu.Lock()
-	s.setupStatus.Ready = envReady && allAgentsReady && allACPReady
+	st.Ready = envReady && allReady
 	s.setupMu.Unlock()
+	s.applyVersions()
 	s.broadcastSetupStatus()
+	close(st.checked)
 }
 
-// broadcastSetupStatus sends current status to all subscribers
-func (s *Server) broadcastSetupStatus() {
+// WaitSetup waits for the dependency checks started with the server, e.g.
+// to report them before serving without a browser, and returns their result
+func (s *Server) WaitSetup(ctx context.Context) (SetupStatus, error) {
 	s.setupMu.RLock()
-	status := SetupStatus{
+	checked := s.setupStatus.checked
+	s.setupMu.RUnlock()
+
+	select {
+	case <-checked:
+		return s.setupSnapshot(), nil
+	case <-ctx.Done():
+		return s.setupSnapshot(), ctx.Err()
+	}
+}
+
+// setupSnapshot copies the current setup status
+func (s *Server) setupSnapshot() SetupStatus {
+	s.setupMu.RLock()
+	defer s.setupMu.RUnlock()
+	return SetupStatus{
 		Ready:       s.setupStatus.Ready,
+		Offline:     s.setupStatus.Offline,
+		System:      s.setupStatus.System,
 		Environment: append([]DependencyItem{}, s.setupStatus.Environment...),
 		Agents:      append([]DependencyItem{}, s.setupStatus.Agents...),
 		ACPPackages: append([]DependencyItem{}, s.setupStatus.ACPPackages...),
 	}
-	s.setupMu.RUnlock()
+}
 
-	s.setupSubsMu.RLock()
-	for ch := range s.setupSubs {
-		select {
-		case ch <- status:
-		default:
+// packagesReady reports whether every agent command and ACP package is
+// installed
+func (st *SetupStatus) packagesReady() bool {
+	for _, items := range [][]DependencyItem{st.Agents, st.ACPPackages} {
+		for _, item := range items {
+			if item.Status != "ready" && item.Status != "needs_login" {
+				return false
+			}
 		}
 	}
-	s.setupSubsMu.RUnlock()
+	return true
+}
+
+// broadcastSetupStatus sends current status to all subscribers
+func (s *Server) broadcastSetupStatus() {
+	s.events.Publish(events.Event{Topic: events.Setup, Type: "status", Data: s.setupSnapshot()})
 }
 
 func (s *Server) handleSetupStatus(w http.ResponseWriter, r *http.Request) {
@@ -270,17 +414,8 @@ func (s *Server) handleSetupSubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ch := make(chan SetupStatus, 10)
-	s.setupSubsMu.Lock()
-	s.setupSubs[ch] = struct{}{}
-	s.setupSubsMu.Unlock()
-
-	defer func() {
-		s.setupSubsMu.Lock()
-		delete(s.setupSubs, ch)
-		s.setupSubsMu.Unlock()
-		close(ch)
-	}()
+	ch, cancel := s.events.Channel(events.Filter{Topics: []events.Topic{events.Setup}}, 10)
+	defer cancel()
 
 	// Re-initialize and re-check dependencies on each subscribe
 	s.initSetupStatus()
@@ -290,6 +425,8 @@ func (s *Server) handleSetupSubscribe(w http.ResponseWriter, r *http.Request) {
 	s.setupMu.RLock()
 	currentStatus := SetupStatus{
 		Ready:       s.setupStatus.Ready,
+		Offline:     s.setupStatus.Offline,
+		System:      s.setupStatus.System,
 		Environment: append([]DependencyItem{}, s.setupStatus.Environment...),
 		Agents:      append([]DependencyItem{}, s.setupStatus.Agents...),
 		ACPPackages: append([]DependencyItem{}, s.setupStatus.ACPPackages...),
@@ -302,8 +439,11 @@ func (s *Server) handleSetupSubscribe(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		select {
-		case status := <-ch:
-			jsonData, _ := json.Marshal(status)
+		case ev := <-ch:
+			if ev.Type != "status" {
+				continue
+			}
+			jsonData, _ := json.Marshal(ev.Data)
 			fmt.Fprintf(w, "data: %s\n\n", jsonData)
 			flusher.Flush()
 		case <-r.Context().Done():
@@ -312,6 +452,34 @@ func (s *Server) handleSetupSubscribe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// installTarget selects a setup item to install, by index or by package
+// (an agent item also matches its command name)
+type installTarget struct {
+	Type    string `json:"type"` // agent or acp
+	Index   *int   `json:"index,omitempty"`
+	Package string `json:"package,omitempty"`
+}
+
+// selectedForInstall reports whether item index of kind, known by names, is
+// among targets. No targets selects everything.
+func selectedForInstall(targets []installTarget, kind string, index int, names ...string) bool {
+	if len(targets) == 0 {
+		return true
+	}
+	for _, t := range targets {
+		if t.Type != kind {
+			continue
+		}
+		if t.Index != nil && *t.Index == index {
+			return true
+		}
+		if t.Package != "" && slices.Contains(names, t.Package) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -328,14 +496,45 @@ func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sendEvent := func(eventType string, data any) {
-		jsonData, _ := json.Marshal(data)
-		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, jsonData)
-		flusher.Flush()
+	stream, closeStream := s.newEventStream(setupTopic, sseWriter(w, flusher))
+	defer closeStream()
+	sendEvent := stream.Send
+
+	if s.config.Offline {
+		sendEvent("done", map[string]any{
+			"success": false,
+			"error":   "Offline mode: remote installs are disabled. Install the missing packages while online, or turn off offline mode.",
+		})
+		return
+	}
+
+	s.installs.reset()
+
+	// Optionally install Node.js with the system package manager, and
+	// optionally only some of the missing items. Update reinstalls the
+	// selected packages at their newest version even when installed.
+	var opts struct {
+		InstallNode bool            `json:"installNode"`
+		Items       []installTarget `json:"items"`
+		Update      bool            `json:"update"`
 	}
+	json.NewDecoder(r.Body).Decode(&opts)
 
-	// Check environment first
+	// Check environment first, picking up a Node.js installed since startup.
+	// Node.js isn't needed when every agent has its own installer.
+	s.setupMu.RLock()
+	needsNode := len(s.setupStatus.Environment) > 0
+	s.setupMu.RUnlock()
 	if !commandExists("npm") || !commandExists("npx") {
+		s.refreshPath()
+	}
+	if needsNode && (!commandExists("npm") || !commandExists("npx")) && opts.InstallNode {
+		if err := s.installNodeWithProgress(sendEvent); err != nil {
+			sendEvent("done", map[string]any{"success": false, "error": err.Error()})
+			return
+		}
+	}
+	if needsNode && (!commandExists("npm") || !commandExists("npx")) {
 		sendEvent("done", map[string]any{
 			"success": false,
 			"error":   "npm and npx are required. Please install Node.js first.",
@@ -355,19 +554,24 @@ func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 		item := s.setupStatus.Agents[i]
 		s.setupMu.RUnlock()
 
-		if item.Status == "ready" {
+		if !selectedForInstall(opts.Items, "agent", i, s.toolPackage(item.Command), item.Command, item.Agent) {
+			continue
+		}
+		inst := s.agentInstaller(item.Agent)
+		npmPkg := s.toolPackage(item.Command)
+		update := opts.Update && (inst != nil || npmPkg != "")
+		if (item.Status == "ready" || item.Status == "needs_login") && !update {
 			sendEvent("progress", map[string]any{
 				"index":   i,
 				"type":    "agent",
-				"status":  "ready",
+				"status":  item.Status,
 				"message": "Already installed",
 			})
 			continue
 		}
 
 		// Check if we can install this agent
-		npmPkg, canInstall := agentNpmPackages[item.Command]
-		if !canInstall {
+		if inst == nil && npmPkg == "" {
 			sendEvent("progress", map[string]any{
 				"index":   i,
 				"type":    "agent",
@@ -378,10 +582,18 @@ func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		action, desc := "Installing", npmPkg
+		if inst != nil {
+			desc = inst.String()
+		}
+		if update {
+			action = "Updating"
+		}
+
 		// Update to installing
 		s.setupMu.Lock()
 		s.setupStatus.Agents[i].Status = "installing"
-		s.setupStatus.Agents[i].Message = "Installing..."
+		s.setupStatus.Agents[i].Message = action + "..."
 		s.setupMu.Unlock()
 		s.broadcastSetupStatus()
 
@@ -389,43 +601,62 @@ func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 			"index":   i,
 			"type":    "agent",
 			"status":  "installing",
-			"message": fmt.Sprintf("Installing %s...", npmPkg),
+			"message": fmt.Sprintf("%s %s...", action, desc),
 		})
 
-		err := installGlobalPackage(npmPkg, func(msg string) {
+		key := installKey("agent", i)
+		ctx := s.installs.begin(key)
+		logFn := func(msg string) {
 			sendEvent("log", map[string]any{
 				"index":   i,
 				"type":    "agent",
 				"message": msg,
 			})
-		})
+		}
+		var err error
+		if inst != nil {
+			err = installWith(ctx, inst, update, logFn)
+		} else {
+			err = installGlobalPackage(ctx, npmPkg, logFn)
+		}
+		s.installs.end(key)
 
 		if err != nil {
+			status, message := installFailure(ctx, err)
 			s.setupMu.Lock()
-			s.setupStatus.Agents[i].Status = "error"
-			s.setupStatus.Agents[i].Message = err.Error()
+			s.setupStatus.Agents[i].Status = status
+			s.setupStatus.Agents[i].Message = message
 			s.setupMu.Unlock()
 			s.broadcastSetupStatus()
 
 			sendEvent("progress", map[string]any{
 				"index":   i,
 				"type":    "agent",
-				"status":  "error",
-				"message": err.Error(),
+				"status":  status,
+				"message": message,
 			})
 			allSuccess = false
 		} else {
+			// A fresh install usually still has to log in
+			status, message := "ready", "Installed"
+			login := s.checkAgentAuth(item.Command)
+			if login != "" {
+				status, message = "needs_login", "Installed, not logged in"
+			}
+
 			s.setupMu.Lock()
-			s.setupStatus.Agents[i].Status = "ready"
-			s.setupStatus.Agents[i].Message = "Installed"
+			s.setupStatus.Agents[i].Status = status
+			s.setupStatus.Agents[i].Message = message
+			s.setupStatus.Agents[i].Login = login
 			s.setupMu.Unlock()
 			s.broadcastSetupStatus()
 
 			sendEvent("progress", map[string]any{
 				"index":   i,
 				"type":    "agent",
-				"status":  "ready",
-				"message": "Installed",
+				"status":  status,
+				"message": message,
+				"login":   login,
 			})
 		}
 	}
@@ -440,7 +671,10 @@ func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 		item := s.setupStatus.ACPPackages[i]
 		s.setupMu.RUnlock()
 
-		if item.Status == "ready" {
+		if !selectedForInstall(opts.Items, "acp", i, item.Package) {
+			continue
+		}
+		if item.Status == "ready" && !opts.Update {
 			sendEvent("progress", map[string]any{
 				"index":   i,
 				"type":    "acp",
@@ -457,33 +691,41 @@ func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 		s.setupMu.Unlock()
 		s.broadcastSetupStatus()
 
+		action := "Installing"
+		if opts.Update {
+			action = "Updating"
+		}
 		sendEvent("progress", map[string]any{
 			"index":   i,
 			"type":    "acp",
 			"status":  "installing",
-			"message": fmt.Sprintf("Installing %s...", item.Package),
+			"message": fmt.Sprintf("%s %s...", action, item.Package),
 		})
 
-		err := installPackageWithProgress(item.Package, func(msg string) {
+		key := installKey("acp", i)
+		ctx := s.installs.begin(key)
+		err := installPackageWithProgress(ctx, item.Package, opts.Update, func(msg string) {
 			sendEvent("log", map[string]any{
 				"index":   i,
 				"type":    "acp",
 				"message": msg,
 			})
 		})
+		s.installs.end(key)
 
 		if err != nil {
+			status, message := installFailure(ctx, err)
 			s.setupMu.Lock()
-			s.setupStatus.ACPPackages[i].Status = "error"
-			s.setupStatus.ACPPackages[i].Message = err.Error()
+			s.setupStatus.ACPPackages[i].Status = status
+			s.setupStatus.ACPPackages[i].Message = message
 			s.setupMu.Unlock()
 			s.broadcastSetupStatus()
 
 			sendEvent("progress", map[string]any{
 				"index":   i,
 				"type":    "acp",
-				"status":  "error",
-				"message": err.Error(),
+				"status":  status,
+				"message": message,
 			})
 			allSuccess = false
 		} else {
@@ -502,13 +744,114 @@ func (s *Server) handleSetupInstall(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Update final ready state
+	// Newly installed binaries may live in directories not yet on PATH
+	s.refreshPath()
+	s.refreshVersions(true)
+
+	// Update final ready state, items not selected may still be missing.
+	// npm and npx were verified above when needed.
 	s.setupMu.Lock()
-	s.setupStatus.Ready = allSuccess
+	ready := allSuccess && s.setupStatus.packagesReady()
+	s.setupStatus.Ready = ready
 	s.setupMu.Unlock()
 	s.broadcastSetupStatus()
 
-	sendEvent("done", map[string]any{"success": allSuccess})
+	sendEvent("done", map[string]any{"success": allSuccess, "ready": ready})
+}
+
+// handleSetupRefreshPath re-scans toolchain directories into PATH, so Node
+// or agents installed while acpone runs are found without a restart
+func (s *Server) handleSetupRefreshPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	added := s.refreshPath()
+	if added == nil {
+		added = []string{}
+	}
+
+	// Re-check dependencies against the new PATH
+	s.initSetupStatus()
+	go s.checkDependenciesAsync()
+
+	writeJSON(w, map[string]any{
+		"added": added,
+		"path":  os.Getenv("PATH"),
+	})
+}
+
+// offlineHint tells the user how to make a package available without
+// network access from acpone
+func offlineHint(packageName string) string {
+	if packageName == "" {
+		return "Not found, offline mode can't install it"
+	}
+	return fmt.Sprintf("Not cached, run `npm install -g %s` while online or from a local tarball", packageName)
+}
+
+// refreshPath updates the PATH agents are spawned with
+func (s *Server) refreshPath() []string {
+	added := sysutil.RefreshPath()
+	if len(added) > 0 {
+		setupLog.Info("added to PATH", "dirs", strings.Join(added, string(os.PathListSeparator)))
+	}
+	return added
+}
+
+// installNodeWithProgress installs Node.js through the detected package
+// manager, reporting progress on the environment items
+func (s *Server) installNodeWithProgress(sendEvent func(string, any)) error {
+	n := findNodeInstaller()
+	if n == nil {
+		return fmt.Errorf("no supported package manager found, please install Node.js from https://nodejs.org")
+	}
+
+	setEnvStatus := func(status, message string) {
+		s.setupMu.Lock()
+		count := len(s.setupStatus.Environment)
+		for i := range s.setupStatus.Environment {
+			s.setupStatus.Environment[i].Status = status
+			s.setupStatus.Environment[i].Message = message
+		}
+		s.setupMu.Unlock()
+		s.broadcastSetupStatus()
+
+		for i := 0; i < count; i++ {
+			sendEvent("progress", map[string]any{
+				"index":   i,
+				"type":    "environment",
+				"status":  status,
+				"message": message,
+			})
+		}
+	}
+
+	setEnvStatus("installing", fmt.Sprintf("Running %s...", n))
+	key := installKey("environment", 0)
+	ctx := s.installs.begin(key)
+	err := installNode(ctx, n, func(msg string) {
+		sendEvent("log", map[string]any{
+			"type":    "environment",
+			"message": msg,
+		})
+	})
+	s.installs.end(key)
+	if err != nil {
+		status, message := installFailure(ctx, err)
+		setEnvStatus(status, message)
+		return errors.New(message)
+	}
+
+	s.refreshPath()
+	if !commandExists("npm") || !commandExists("npx") {
+		err := fmt.Errorf("Node.js was installed but npm/npx are not on PATH yet, please restart acpone")
+		setEnvStatus("error", err.Error())
+		return err
+	}
+	setEnvStatus("ready", "Installed")
+	return nil
 }
 
 func extractPackageName(command string, args []string) string {
@@ -523,36 +866,40 @@ func extractPackageName(command string, args []string) string {
 	return ""
 }
 
-func isPackageCached(packageName string) bool {
-	// 检查全局安装
-	cmd := exec.Command("npm", "list", "-g", "--depth=0", packageName)
-	sysutil.HideWindow(cmd)
-	if err := cmd.Run(); err == nil {
-		return true
-	}
-
-	// 检查 npx 缓存
+// npxCacheDirs returns the directories npx caches packages in
+func npxCacheDirs() []string {
 	home, _ := os.UserHomeDir()
-	var npxCacheDirs []string
+	var dirs []string
 
 	if isWindows() {
 		// Windows: %LOCALAPPDATA%\npm-cache\_npx 或 %APPDATA%\npm-cache\_npx
 		localAppData := os.Getenv("LOCALAPPDATA")
 		appData := os.Getenv("APPDATA")
 		if localAppData != "" {
-			npxCacheDirs = append(npxCacheDirs, filepath.Join(localAppData, "npm-cache", "_npx"))
+			dirs = append(dirs, filepath.Join(localAppData, "npm-cache", "_npx"))
 		}
 		if appData != "" {
-			npxCacheDirs = append(npxCacheDirs, filepath.Join(appData, "npm-cache", "_npx"))
+			dirs = append(dirs, filepath.Join(appData, "npm-cache", "_npx"))
 		}
 		// 也检查用户目录下的 .npm
-		npxCacheDirs = append(npxCacheDirs, filepath.Join(home, ".npm", "_npx"))
+		dirs = append(dirs, filepath.Join(home, ".npm", "_npx"))
 	} else {
 		// macOS/Linux
-		npxCacheDirs = append(npxCacheDirs, filepath.Join(home, ".npm", "_npx"))
+		dirs = append(dirs, filepath.Join(home, ".npm", "_npx"))
+	}
+	return dirs
+}
+
+func isPackageCached(packageName string) bool {
+	// 检查全局安装
+	cmd := exec.Command("npm", "list", "-g", "--depth=0", packageName)
+	sysutil.HideWindow(cmd)
+	if err := cmd.Run(); err == nil {
+		return true
 	}
 
-	for _, npxCacheDir := range npxCacheDirs {
+	// 检查 npx 缓存
+	for _, npxCacheDir := range npxCacheDirs() {
 		entries, err := os.ReadDir(npxCacheDir)
 		if err != nil {
 			continue
@@ -579,6 +926,26 @@ func commandExists(command string) bool {
 	return err == nil
 }
 
+// executableArch returns the architectures a command's executable is built
+// for, with a note for the status message when none is the machine's, e.g.
+// an x64 Node.js on Apple Silicon or Windows on ARM, which runs emulated,
+// or an amd64 binary on linux/arm64, which doesn't run at all
+func executableArch(command string) (arch, note string) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", ""
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	archs := sysutil.BinaryArchs(path)
+	arch = strings.Join(archs, "/")
+	if sysutil.RunsNatively(path) {
+		return arch, ""
+	}
+	return arch, fmt.Sprintf(" (%s build on %s machine)", arch, sysutil.HostArch())
+}
+
 // npm registry URLs
 var npmRegistries = []struct {
 	Name string
@@ -598,7 +965,7 @@ var (
 // selectFastestRegistry tests registries and returns the fastest one
 func selectFastestRegistry() string {
 	registryOnce.Do(func() {
-		log.Println("[Setup] Testing npm registry speeds...")
+		setupLog.Info("testing npm registry speeds")
 
 		type result struct {
 			url      string
@@ -632,10 +999,10 @@ func selectFastestRegistry() string {
 		for i := 0; i < len(npmRegistries); i++ {
 			r := <-results
 			if r.err != nil {
-				log.Printf("[Setup]   %s: failed (%v)", r.name, r.err)
+				setupLog.Debug("registry failed", "registry", r.name, "error", r.err)
 				continue
 			}
-			log.Printf("[Setup]   %s: %v", r.name, r.duration.Round(time.Millisecond))
+			setupLog.Debug("registry responded", "registry", r.name, "duration", r.duration.Round(time.Millisecond))
 			if r.duration < fastest.duration {
 				fastest = r
 			}
@@ -643,92 +1010,104 @@ func selectFastestRegistry() string {
 
 		if fastest.url != "" {
 			cachedRegistry = fastest.url
-			log.Printf("[Setup] Selected registry: %s (%s)", fastest.name, fastest.url)
+			setupLog.Info("selected registry", "registry", fastest.name, "url", fastest.url)
 		} else {
 			cachedRegistry = npmRegistries[1].URL // Fallback to official
-			log.Printf("[Setup] All registries failed, using official: %s", cachedRegistry)
+			setupLog.Warn("all registries failed, using official", "url", cachedRegistry)
 		}
 	})
 
 	return cachedRegistry
 }
 
-func installPackageWithProgress(packageName string, logFn func(string)) error {
+func installPackageWithProgress(ctx context.Context, packageName string, update bool, logFn func(string)) error {
 	registry := selectFastestRegistry()
 
-	cmdStr := fmt.Sprintf("npx -y --registry=%s %s --help", registry, packageName)
-	log.Printf("[Setup] Installing ACP package: %s", packageName)
-	log.Printf("[Setup] Command: %s", cmdStr)
+	args := []string{"-y", "--registry=" + registry}
+	if update {
+		// Revalidate the cached package metadata so npx fetches the newest version
+		args = append(args, "--prefer-online")
+	}
+	args = append(args, packageName, "--help")
+	cmdStr := "npx " + strings.Join(args, " ")
+	setupLog.Info("installing ACP package", "package", packageName)
+	setupLog.Debug("install command", "command", cmdStr)
 	logFn(fmt.Sprintf("Running: %s", cmdStr))
 
-	cmd := exec.Command("npx", "-y", "--registry="+registry, packageName, "--help")
-	sysutil.HideWindow(cmd)
+	cmd := installCommand(ctx, "npx", args...)
 	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	outputStr := string(output)
 
 	if outputStr != "" {
 		for _, line := range strings.Split(outputStr, "\n") {
 			if line = strings.TrimSpace(line); line != "" {
-				log.Printf("[Setup]   %s", line)
+				setupLog.Debug("install output", "line", line)
 			}
 		}
 	}
 
 	if strings.Contains(outputStr, "npm ERR!") || strings.Contains(outputStr, "404 Not Found") {
-		log.Printf("[Setup] Failed to install %s", packageName)
+		setupLog.Error("failed to install", "package", packageName)
 		return fmt.Errorf("failed to install: %s", strings.TrimSpace(outputStr))
 	}
 
 	if err != nil && strings.Contains(outputStr, "npm ERR!") {
-		log.Printf("[Setup] Failed to install %s: %v", packageName, err)
+		setupLog.Error("failed to install", "package", packageName, "error", err)
 		return fmt.Errorf("install failed: %w", err)
 	}
 
-	log.Printf("[Setup] Successfully installed %s", packageName)
+	setupLog.Info("installed", "package", packageName)
 	logFn("Installation completed")
 	return nil
 }
 
-func installGlobalPackage(packageName string, logFn func(string)) error {
+func installGlobalPackage(ctx context.Context, packageName string, logFn func(string)) error {
 	registry := selectFastestRegistry()
 
 	// First, try to uninstall existing package to avoid ENOTEMPTY errors
-	log.Printf("[Setup] Uninstalling existing %s (if any)...", packageName)
-	uninstallCmd := exec.Command("npm", "uninstall", "-g", packageName)
-	sysutil.HideWindow(uninstallCmd)
+	setupLog.Info("uninstalling existing package", "package", packageName)
+	uninstallCmd := installCommand(ctx, "npm", "uninstall", "-g", packageName)
 	uninstallCmd.Run() // Ignore errors, package may not exist
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	// Clean up leftover temp directories that cause ENOTEMPTY errors
 	cleanupNpmTempDirs(packageName)
 
 	// Install the package
 	cmdStr := fmt.Sprintf("npm install -g --registry=%s %s", registry, packageName)
-	log.Printf("[Setup] Installing global package: %s", packageName)
-	log.Printf("[Setup] Command: %s", cmdStr)
+	setupLog.Info("installing global package", "package", packageName)
+	setupLog.Debug("install command", "command", cmdStr)
 	logFn(fmt.Sprintf("Running: %s", cmdStr))
 
-	cmd := exec.Command("npm", "install", "-g", "--registry="+registry, packageName)
-	sysutil.HideWindow(cmd)
+	cmd := installCommand(ctx, "npm", "install", "-g", "--registry="+registry, packageName)
 	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	outputStr := string(output)
 
 	if outputStr != "" {
 		for _, line := range strings.Split(outputStr, "\n") {
 			if line = strings.TrimSpace(line); line != "" {
-				log.Printf("[Setup]   %s", line)
+				setupLog.Debug("install output", "line", line)
 			}
 		}
 	}
 
 	if err != nil {
-		log.Printf("[Setup] Failed to install %s: %v", packageName, err)
+		setupLog.Error("failed to install", "package", packageName, "error", err)
 		if strings.Contains(outputStr, "npm ERR!") {
 			return fmt.Errorf("failed to install: %s", strings.TrimSpace(outputStr))
 		}
 		return fmt.Errorf("install failed: %w", err)
 	}
 
-	log.Printf("[Setup] Successfully installed %s", packageName)
+	setupLog.Info("installed", "package", packageName)
 	logFn("Installation completed")
 	return nil
 }
@@ -767,7 +1146,7 @@ func cleanupNpmTempDirs(packageName string) {
 		// Match patterns like ".claude-code-xxxxx" or "claude-code"
 		if strings.HasPrefix(entryName, "."+name+"-") || entryName == name {
 			targetPath := filepath.Join(scopeDir, entryName)
-			log.Printf("[Setup] Cleaning up: %s", targetPath)
+			setupLog.Info("cleaning up", "path", targetPath)
 			os.RemoveAll(targetPath)
 		}
 	}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/events"
+	"github.com/daodao97/acpone/internal/slack"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// maxSlackReply caps the reply excerpt of completion notifications
+const maxSlackReply = 500
+
+// setupSlack posts conversation events to Slack when configured. Posting
+// happens in order on a worker goroutine, so conversation threads start
+// before their replies and publishers never wait on Slack.
+func (s *Server) setupSlack() {
+	cfg := s.config.Slack
+	if cfg == nil {
+		return
+	}
+	client := slack.New(cfg)
+	ch, _ := s.events.Channel(events.Filter{Topics: []events.Topic{events.Turn, events.Permission}}, 256)
+
+	go func() {
+		threads := make(map[string]string) // convID -> thread timestamp
+		for ev := range ch {
+			if ev.ConversationID == "" {
+				continue
+			}
+			text := s.slackText(cfg, ev)
+			if text == "" {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			thread := threads[ev.ConversationID]
+			if client.Threaded() && thread == "" {
+				ts, err := client.Post(ctx, "💬 "+s.slackTitle(ev.ConversationID), "")
+				if err != nil {
+					slackLog.Warn("post failed", "error", err)
+				} else {
+					thread = ts
+					threads[ev.ConversationID] = ts
+				}
+			} else if !client.Threaded() {
+				text = "*" + s.slackTitle(ev.ConversationID) + "*\n" + text
+			}
+			if _, err := client.Post(ctx, text, thread); err != nil {
+				slackLog.Warn("post failed", "error", err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// slackText formats a notification for ev, or "" when it isn't posted
+func (s *Server) slackText(cfg *config.SlackConfig, ev events.Event) string {
+	convID := ev.ConversationID
+	agentID := ""
+	if conv := s.conversations.Get(convID); conv != nil {
+		agentID = lastAgent(conv)
+	}
+
+	switch ev.Type {
+	case "done":
+		if !cfg.Notifies(config.SlackDone) {
+			return ""
+		}
+		if result, _ := ev.Data.(map[string]any); result["stopReason"] == "cancelled" {
+			return fmt.Sprintf("⏹ *%s* was cancelled", agentID)
+		}
+		text := fmt.Sprintf("✅ *%s* finished", agentID)
+		if reply := strings.TrimSpace(s.conversations.LastReply(convID)); reply != "" {
+			if len(reply) > maxSlackReply {
+				reply = strings.ToValidUTF8(reply[:maxSlackReply], "") + "…"
+			}
+			text += "\n>" + strings.ReplaceAll(reply, "\n", "\n>")
+		}
+		return text
+
+	case "error":
+		if !cfg.Notifies(config.SlackError) {
+			return ""
+		}
+		data, _ := ev.Data.(map[string]string)
+		return fmt.Sprintf("❌ *%s* failed: %s", agentID, data["message"])
+
+	case "permission_request":
+		req, ok := ev.Data.(*agent.PermissionRequest)
+		if !ok || !cfg.Notifies(config.SlackPermission) {
+			return ""
+		}
+		for _, p := range s.permissions.list() {
+			if p.Request == req {
+				return fmt.Sprintf("⏳ *%s* is waiting for permission: %s\nAnswer with `acpone permissions approve %s` or `deny %s`", p.AgentID, p.Title, p.ID, p.ID)
+			}
+		}
+		return fmt.Sprintf("⏳ *%s* is waiting for permission: %s", agentID, req.ToolCall.Title)
+	}
+	return ""
+}
+
+// slackTitle names a conversation in Slack posts
+func (s *Server) slackTitle(convID string) string {
+	conv := s.conversations.Get(convID)
+	if conv == nil {
+		return convID
+	}
+	title := storage.GenerateTitle(conv.Messages)
+	if ws, ok := s.workspaceStore.Find(conv.WorkspaceID); ok {
+		title += " · " + ws.Name
+	}
+	return title
+}
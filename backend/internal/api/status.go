@@ -0,0 +1,143 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// Version is the build reported by /api/status, set with
+// -ldflags "-X github.com/daodao97/acpone/internal/api.Version=1.2.3"
+var Version = "dev"
+
+// Status is a compact snapshot of the gateway for the desktop tray and
+// other pollers, in place of several endpoints
+type Status struct {
+	Version     string        `json:"version"`
+	Ready       bool          `json:"ready"`       // Setup dependencies are installed
+	Agents      []AgentStatus `json:"agents"`      // Configured agents in config order
+	ActiveTurns []ActiveTurn  `json:"activeTurns"` // Chat turns in progress, oldest first
+	Permissions int           `json:"permissions"` // Pending permission requests
+	Paused      bool          `json:"paused"`      // Agents are paused, new turns are rejected
+
+	ConfigMigration *config.Migration `json:"configMigration,omitempty"` // Upgrade of the config file at startup
+}
+
+// AgentStatus is the state of one configured agent
+type AgentStatus struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	Enabled bool         `json:"enabled"`
+	Process agent.Status `json:"process"`         // idle, starting, running, error or stopped
+	Init    string       `json:"init,omitempty"`  // initializing, ready or error
+	Healthy bool         `json:"healthy"`         // False while a prompt hangs
+	Error   string       `json:"error,omitempty"` // Last initialization error
+
+	PID          int   `json:"pid,omitempty"`          // Of the running process, 0 for builtin agents
+	StartedAt    int64 `json:"startedAt,omitempty"`    // Unix ms the running process started
+	LastActivity int64 `json:"lastActivity,omitempty"` // Unix ms of the agent's last message
+	Starts       int   `json:"starts,omitempty"`       // Processes started, restarts included
+	Pending      int   `json:"pending,omitempty"`      // Requests awaiting the agent's response
+	Turns        int   `json:"turns,omitempty"`        // Chat turns in progress
+}
+
+// ActiveTurn is a chat turn in progress
+type ActiveTurn struct {
+	ConversationID string `json:"conversationId"`
+	AgentID        string `json:"agentId"`
+	StartedAt      int64  `json:"startedAt"`
+}
+
+// activeTurns tracks chat turns in progress by conversation
+type activeTurns struct {
+	mu    sync.Mutex
+	turns map[string]ActiveTurn
+}
+
+// begin records a turn and returns the function ending it
+func (a *activeTurns) begin(convID, agentID string) func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.turns == nil {
+		a.turns = make(map[string]ActiveTurn)
+	}
+	a.turns[convID] = ActiveTurn{ConversationID: convID, AgentID: agentID, StartedAt: time.Now().UnixMilli()}
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.turns, convID)
+	}
+}
+
+func (a *activeTurns) list() []ActiveTurn {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]ActiveTurn, 0, len(a.turns))
+	for _, t := range a.turns {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt < list[j].StartedAt })
+	return list
+}
+
+// Status returns a snapshot of agents, turns, permissions and setup
+func (s *Server) Status() Status {
+	s.setupMu.RLock()
+	ready := s.setupStatus.Ready
+	s.setupMu.RUnlock()
+
+	turns := s.turns.list()
+	configured := s.agentList()
+	agents := make([]AgentStatus, 0, len(configured))
+	for _, a := range configured {
+		agents = append(agents, s.agentStatus(a, turns))
+	}
+
+	return Status{
+		Version:     Version,
+		Ready:       ready,
+		Agents:      agents,
+		ActiveTurns: turns,
+		Permissions: len(s.permissions.list()),
+		Paused:      s.AgentsPaused(),
+
+		ConfigMigration: config.LastMigration,
+	}
+}
+
+// agentStatus reports the state of an agent and its process, counting its
+// turns among those in progress
+func (s *Server) agentStatus(a config.AgentConfig, turns []ActiveTurn) AgentStatus {
+	st := AgentStatus{ID: a.ID, Name: a.Name, Enabled: a.IsEnabled(), Process: agent.StatusIdle, Healthy: true}
+	if proc, err := s.agents.Get(a.ID); err == nil {
+		info := proc.Info()
+		st.Process, st.Healthy = info.Status, info.Healthy
+		st.PID, st.Starts, st.Pending = info.PID, info.Starts, info.Pending
+		if info.Status == agent.StatusRunning {
+			st.StartedAt = info.StartedAt.UnixMilli()
+			st.LastActivity = info.LastActivity.UnixMilli()
+		}
+	}
+	if init := s.agentInitSnapshot(a.ID); init != nil {
+		st.Init, st.Error = init.State, init.Error
+	}
+	for _, t := range turns {
+		if t.AgentID == a.ID {
+			st.Turns++
+		}
+	}
+	return st
+}
+
+// handleStatus serves the status snapshot
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.Status())
+}
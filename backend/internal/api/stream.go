@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Formats of streamed responses
+const (
+	mimeSSE    = "text/event-stream"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// streamEncoder formats the events of a streamed response. Every format
+// carries the same named events with JSON data.
+type streamEncoder struct {
+	contentType string
+	encode      func(event string, data json.RawMessage) []byte
+}
+
+// sseEncoder writes events as server-sent events
+var sseEncoder = streamEncoder{
+	contentType: mimeSSE,
+	encode: func(event string, data json.RawMessage) []byte {
+		return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+	},
+}
+
+// ndjsonEncoder writes each event as a {"event", "data"} line
+var ndjsonEncoder = streamEncoder{
+	contentType: mimeNDJSON,
+	encode: func(event string, data json.RawMessage) []byte {
+		line, _ := json.Marshal(struct {
+			Event string          `json:"event"`
+			Data  json.RawMessage `json:"data"`
+		}{event, data})
+		return append(line, '\n')
+	},
+}
+
+// negotiateStream picks the format the client's Accept header lists first,
+// SSE when it lists neither
+func negotiateStream(r *http.Request) streamEncoder {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case mimeNDJSON:
+			return ndjsonEncoder
+		case mimeSSE:
+			return sseEncoder
+		}
+	}
+	return sseEncoder
+}
+
+// streamWriter returns a function writing events to w in enc's format.
+// Writes are serialized, events may come from the agent or other requests.
+func streamWriter(w http.ResponseWriter, flusher http.Flusher, enc streamEncoder) func(string, any) {
+	var mu sync.Mutex
+	return func(event string, data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		jsonData, _ := json.Marshal(data)
+		w.Write(enc.encode(event, jsonData))
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/daodao97/acpone/internal/sessionsync"
+)
+
+// setupSync starts cross-machine session sync when configured
+func (s *Server) setupSync() {
+	if s.config.Sync == nil {
+		return
+	}
+	svc, err := sessionsync.New(s.sessionStore, s.config.Sync)
+	if err != nil {
+		storageLog.Warn("sync disabled", "error", err)
+		return
+	}
+	s.sync = svc
+	svc.Start()
+}
+
+// handleSync returns the sync status (GET) or runs a sync immediately (POST)
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	if s.sync == nil {
+		writeError(w, "Sync is not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, s.sync.Status())
+	case "POST":
+		status, err := s.sync.Run()
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, status)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemPrompt returns the agent's configured instructions: the inline
+// systemPrompt followed by the contents of systemPromptFile. A relative
+// file is resolved against the workspace root.
+func (s *Server) systemPrompt(agentID, root string) (string, error) {
+	a := s.findAgent(agentID)
+	if a == nil {
+		return "", nil
+	}
+
+	parts := make([]string, 0, 2)
+	if text := strings.TrimSpace(a.SystemPrompt); text != "" {
+		parts = append(parts, text)
+	}
+	if path := a.SystemPromptFile; path != "" {
+		if rest, ok := strings.CutPrefix(path, "~"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = home + rest
+			}
+		}
+		if !filepath.IsAbs(path) && root != "" {
+			path = filepath.Join(root, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return strings.Join(parts, "\n\n"), fmt.Errorf("system prompt file: %w", err)
+		}
+		if text := strings.TrimSpace(string(data)); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// withSystemPrompt prepends the agent's instructions to the first prompt of
+// a new session. The file is read each time so edits apply to new sessions
+// without a restart; an unreadable file is reported and skipped.
+func (s *Server) withSystemPrompt(agentID, root string, prompt []map[string]any, sendEvent func(string, any)) []map[string]any {
+	text, err := s.systemPrompt(agentID, root)
+	if err != nil {
+		logger.Warn("system prompt unavailable", "agent", agentID, "error", err)
+		sendEvent("warning", map[string]any{"message": err.Error()})
+	}
+	if memory := memoryContext(root); memory != "" {
+		text = strings.TrimSpace(text + "\n\n" + memory)
+	}
+	if text == "" {
+		return prompt
+	}
+
+	block := map[string]any{"type": "text", "text": text}
+	return append([]map[string]any{block}, prompt...)
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// teamRoles is the order team members run in
+var teamRoles = []string{config.RolePlanner, config.RoleImplementer, config.RoleTester}
+
+var roleDuties = map[string]string{
+	config.RolePlanner:     "Break the request into a short, concrete plan for the implementer. Do not edit files.",
+	config.RoleImplementer: "Carry out the plan and address any feedback from the tester.",
+	config.RoleTester:      "Check the implementer's work, running the tests where possible. End your reply with a line reading VERDICT: PASS or VERDICT: FAIL.",
+}
+
+var verdictRegex = regexp.MustCompile(`(?i)VERDICT:\s*(PASS|FAIL)`)
+
+// teamIntro precedes the user's prompt for the first member of a team
+const teamIntro = `You are the {{role}} of a team of agents. {{duty}} The request follows.`
+
+// defaultTeamPrompt is the prompt of later team members
+const defaultTeamPrompt = `You are the {{role}} of a team of agents working on the request below. {{duty}}
+
+## Request
+{{input}}
+
+## Team so far
+{{context}}
+
+## Workspace changes
+` + "```diff\n{{diff}}\n```\n"
+
+// handleTeams lists configured teams
+func (s *Server) handleTeams(w http.ResponseWriter, r *http.Request) {
+	teams := s.config.Teams
+	if teams == nil {
+		teams = []config.TeamConfig{}
+	}
+	writeJSON(w, map[string]any{"teams": teams})
+}
+
+// findTeam returns the team requested explicitly or via "@<id>"
+func (s *Server) findTeam(req chatRequest) *config.TeamConfig {
+	id := req.Team
+	if id == "" {
+		id = s.currentRouter().DetectTeam(req.Message)
+	}
+	if id == "" {
+		return nil
+	}
+	return s.config.FindTeam(id)
+}
+
+// firstMember returns the team member that answers the user's prompt
+func firstMember(team *config.TeamConfig) *config.TeamMember {
+	for _, role := range teamRoles {
+		if m := team.Member(role); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// beginMember announces a team member's turn to the client and records it
+// in the conversation, attributed to the member's agent
+func (s *Server) beginMember(convID string, team *config.TeamConfig, m *config.TeamMember, round int, sendEvent func(string, any)) {
+	name := team.Name
+	if name == "" {
+		name = team.ID
+	}
+	label := fmt.Sprintf("%s · %s (%s)", name, m.Role, m.Agent)
+	if round > 1 {
+		label += fmt.Sprintf(" · round %d", round)
+	}
+
+	sendEvent("stage", map[string]any{
+		"team":  team.ID,
+		"role":  m.Role,
+		"round": round,
+		"agent": m.Agent,
+		"label": label,
+	})
+	s.conversations.AddAssistantMessage(convID, "**"+label+"**", m.Agent)
+}
+
+// runTeam runs a team on the user's prompt: the planner plans, then the
+// implementer and tester alternate until the tester passes the work or the
+// rounds run out. Every member sees the replies of the members before it.
+// The base turn carries the user's prompt for the first member.
+func (s *Server) runTeam(team *config.TeamConfig, base turn, input string) (*turnResult, error) {
+	root := s.resolveWorkspacePath(base.workspaceID)
+	var replies []string
+	var edited []string
+	var res *turnResult
+
+	run := func(m *config.TeamMember, round int) error {
+		vars := map[string]string{
+			"{{role}}":    m.Role,
+			"{{duty}}":    roleDuties[m.Role],
+			"{{input}}":   input,
+			"{{context}}": orNone(strings.Join(replies, "\n\n")),
+		}
+
+		t := base
+		t.agentID = m.Agent
+		if res == nil {
+			intro := expandPrompt(teamIntro, m.Prompt, vars)
+			userPrompt, ready := base.prompt, base.ready
+			t.prompt = func() []map[string]any {
+				return append([]map[string]any{{"type": "text", "text": intro}}, userPrompt()...)
+			}
+			t.ready = func(sessionID string) {
+				if ready != nil {
+					ready(sessionID)
+				}
+				s.beginMember(base.convID, team, m, round, base.sendEvent)
+			}
+		} else {
+			s.beginMember(base.convID, team, m, round, base.sendEvent)
+			vars["{{diff}}"] = orNone(workspaceDiff(root))
+			t.prompt = textPrompt(expandPrompt(defaultTeamPrompt, m.Prompt, vars))
+			t.ready = nil
+		}
+
+		r, err := s.runTurn(&t)
+		if err != nil {
+			return fmt.Errorf("%s (%s): %w", m.Role, m.Agent, err)
+		}
+		s.persistConversation(base.convID)
+		res = r
+		edited = append(edited, r.Edited...)
+		replies = append(replies, fmt.Sprintf("### %s (%s)\n%s", m.Role, m.Agent, orNone(r.Text)))
+		return nil
+	}
+	cancelled := func() bool {
+		return res != nil && res.Result["stopReason"] == "cancelled"
+	}
+
+	if m := team.Member(config.RolePlanner); m != nil {
+		if err := run(m, 1); err != nil {
+			return res, err
+		}
+	}
+	implementer, tester := team.Member(config.RoleImplementer), team.Member(config.RoleTester)
+	for round := 1; !cancelled(); round++ {
+		if err := run(implementer, round); err != nil {
+			return res, err
+		}
+		if tester == nil || cancelled() {
+			break
+		}
+		if err := run(tester, round); err != nil {
+			return res, err
+		}
+		if testsPassed(res.Text) {
+			break
+		}
+		if round >= team.Rounds() {
+			base.sendEvent("warning", map[string]string{
+				"message": fmt.Sprintf("Team %s stopped after %d rounds without passing tests", team.ID, round),
+			})
+			break
+		}
+	}
+
+	res.Edited = edited
+	return res, nil
+}
+
+// testsPassed reports whether a tester's reply passes the work. A reply
+// without a verdict passes so that the loop always ends.
+func testsPassed(text string) bool {
+	m := verdictRegex.FindAllStringSubmatch(text, -1)
+	return len(m) == 0 || !strings.EqualFold(m[len(m)-1][1], "FAIL")
+}
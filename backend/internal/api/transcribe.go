@@ -0,0 +1,69 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/transcribe"
+)
+
+const maxAudioSize = 25 << 20 // 25MB, the OpenAI API limit
+
+// handleTranscribe converts an uploaded audio recording ("audio" form field)
+// to text for the prompt box. GET reports whether transcription is enabled.
+func (s *Server) handleTranscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		writeJSON(w, map[string]bool{"enabled": s.config.Transcribe != nil})
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.Transcribe == nil {
+		writeError(w, "transcription is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAudioSize)
+	if err := r.ParseMultipartForm(maxAudioSize); err != nil {
+		writeError(w, "audio too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("audio")
+	if err != nil {
+		writeError(w, "missing audio file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Keep the extension so transcribers can detect the format
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext == "" {
+		ext = ".webm"
+	}
+	tmp, err := os.CreateTemp("", "acpone-audio-*"+ext)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, file)
+	tmp.Close()
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	text, err := transcribe.New(s.config.Transcribe).Transcribe(r.Context(), tmp.Name(), r.FormValue("language"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, map[string]string{"text": text})
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/daodao97/acpone/internal/events"
+)
+
+// transcriptDir holds live transcripts, relative to the workspace root
+const transcriptDir = ".acpone/transcripts"
+
+// transcripts tees conversations of workspaces with "transcript": true in
+// .acpone.json into markdown files as their events are published, so the
+// agent's tools and editors can follow the ongoing conversation
+type transcripts struct {
+	mu    sync.Mutex
+	paths map[string]string // convID -> transcript file of the running turn, "" when off
+}
+
+// setupTranscripts subscribes the transcript writer to turn and tool events
+func (s *Server) setupTranscripts() {
+	t := &transcripts{paths: make(map[string]string)}
+	s.events.Subscribe(events.Filter{Topics: []events.Topic{events.Turn, events.Tool}}, func(ev events.Event) {
+		if ev.ConversationID != "" {
+			s.writeTranscript(t, ev)
+		}
+	})
+}
+
+func (s *Server) writeTranscript(t *transcripts, ev events.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	convID := ev.ConversationID
+	if ev.Type == "session" {
+		// Each turn re-reads the project setting
+		t.paths[convID] = s.transcriptPath(convID)
+	}
+	path := t.paths[convID]
+	if path == "" {
+		return
+	}
+
+	var text string
+	switch ev.Type {
+	case "session":
+		data, _ := ev.Data.(map[string]any)
+		text = fmt.Sprintf("## You\n\n%s\n\n## %v\n\n", s.conversations.LastUserMessage(convID), data["agent"])
+	case "update":
+		if chunk, ok := ev.Data.(textChunk); ok && chunk.Kind == "agent_message_chunk" {
+			text = chunk.Text
+		}
+	case "tool_call":
+		data, _ := ev.Data.(map[string]any)
+		if status := data["status"]; status == "completed" || status == "failed" || status == "error" {
+			text = fmt.Sprintf("\n\n> 🔧 %v · %v\n\n", data["title"], status)
+		}
+	case "workspace":
+		if sw, ok := ev.Data.(*workspaceSwitch); ok {
+			text = fmt.Sprintf("> 📁 %s\n", sw.Message)
+		}
+	case "stage", "review":
+		data, _ := ev.Data.(map[string]any)
+		text = fmt.Sprintf("\n\n## %v\n\n", data["label"])
+	case "error":
+		data, _ := ev.Data.(map[string]string)
+		text = fmt.Sprintf("\n\n> ❌ %s\n\n---\n\n", data["message"])
+		delete(t.paths, convID)
+	case "done":
+		text = "\n\n---\n\n"
+		delete(t.paths, convID)
+	}
+	if text == "" {
+		return
+	}
+
+	if err := appendTranscript(path, convID, text); err != nil {
+		storageLog.Warn("transcript failed", "conversation", convID, "error", err)
+		delete(t.paths, convID)
+	}
+}
+
+// transcriptPath returns the transcript file of the conversation, or "" when
+// its workspace doesn't enable transcripts
+func (s *Server) transcriptPath(convID string) string {
+	conv := s.conversations.Get(convID)
+	if conv == nil {
+		return ""
+	}
+	if pc := s.projectConfig(conv.WorkspaceID); pc == nil || !pc.Transcript {
+		return ""
+	}
+	root := s.resolveWorkspacePath(conv.WorkspaceID)
+	return filepath.Join(root, filepath.FromSlash(transcriptDir), convID+".md")
+}
+
+// appendTranscript appends text, starting new files with a title
+func appendTranscript(path, convID, text string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		text = fmt.Sprintf("# Conversation %s\n\n", convID) + text
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
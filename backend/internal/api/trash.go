@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// trashPurgeInterval is how often expired sessions are purged from the trash
+const trashPurgeInterval = time.Hour
+
+// setupTrashPurge periodically deletes sessions that have been in the trash
+// longer than the configured retention
+func (s *Server) setupTrashPurge() {
+	retention := s.config.Trash.Retention()
+	if retention == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if n := s.purgeTrash(time.Now().Add(-retention)); n > 0 {
+				storageLog.Info("purged expired sessions", "count", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// purgeTrash deletes the sessions trashed before the cutoff along with their
+// uploads, returning how many were deleted
+func (s *Server) purgeTrash(before time.Time) int {
+	purged, err := s.sessionStore.PurgeTrash(before)
+	if err != nil {
+		storageLog.Error("trash purge failed", "error", err)
+	}
+	for _, session := range purged {
+		s.removeUploads(session.ID, session.Messages, session.WorkspaceID)
+	}
+	return len(purged)
+}
+
+// handleTrash lists the trashed sessions (GET) or empties the trash (DELETE)
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, map[string]any{
+			"sessions":      s.sessionStore.ListTrash(),
+			"retentionDays": int(s.config.Trash.Retention().Hours() / 24),
+		})
+
+	case "DELETE":
+		n := s.purgeTrash(time.Now())
+		writeJSON(w, map[string]any{"success": true, "purged": n})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSessionRestore takes a session out of the trash
+func (s *Server) handleSessionRestore(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, err := s.sessionStore.Restore(id)
+	if err != nil {
+		writeError(w, "Session not found in the trash", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]any{"session": session})
+}
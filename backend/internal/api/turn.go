@@ -0,0 +1,346 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/recentfiles"
+)
+
+// turn is one prompt sent to one agent within a conversation. The user's
+// message, pipeline stages, reviews and hook follow-ups all run as turns.
+type turn struct {
+	convID      string
+	agentID     string
+	workspaceID string
+	project     *config.ProjectConfig
+	params      map[string]any // Agent parameters sent with the prompt
+	sendEvent   func(string, any)
+	kind        string // Message kind recorded for the agent's text, e.g. "review"
+	// instructions precede the first prompt of a new agent session, after
+	// the agent's system prompt
+	instructions string
+
+	// prompt builds the prompt blocks once the agent is initialized
+	prompt func() []map[string]any
+	// ready runs after the agent session exists, right before prompting
+	ready func(sessionID string)
+}
+
+// turnResult is the outcome of a completed turn
+type turnResult struct {
+	Result      map[string]any               // session/prompt result with stopReason
+	Text        string                       // Agent text of the turn
+	Edited      []string                     // Files the agent reported editing
+	Tools       []*conversation.ToolCallInfo // Tool calls recorded in the conversation
+	FirstOutput time.Time                    // When the agent's first update arrived
+}
+
+// runTurn runs a turn and records its timing and outcome in the conversation
+func (s *Server) runTurn(t *turn) (*turnResult, error) {
+	start := time.Now()
+	res, err := s.promptTurn(t)
+
+	record := conversation.Turn{
+		Agent:      t.agentID,
+		Kind:       t.kind,
+		StartedAt:  start.UnixMilli(),
+		DurationMs: time.Since(start).Milliseconds(),
+		StopReason: conversation.StopError,
+	}
+	var tools []*conversation.ToolCallInfo
+	if res != nil {
+		record.StopReason, _ = res.Result["stopReason"].(string)
+		record.Truncated = res.Result["truncated"] != nil
+		record.ToolCalls = len(res.Tools)
+		if !res.FirstOutput.IsZero() {
+			record.FirstOutputMs = res.FirstOutput.Sub(start).Milliseconds()
+		}
+		tools = res.Tools
+	}
+	s.conversations.AddTurn(t.convID, record)
+	s.observeTurn(t.agentID, time.Since(start), record.StopReason, tools)
+	return res, err
+}
+
+// promptTurn initializes the agent if needed, prompts it in the conversation's
+// agent session and records the streamed reply in the conversation
+func (s *Server) promptTurn(t *turn) (*turnResult, error) {
+	agentID, convID := t.agentID, t.convID
+	sendEvent := t.sendEvent
+	root := s.resolveWorkspacePath(t.workspaceID)
+
+	if a := s.findAgent(agentID); a != nil && !a.IsEnabled() {
+		return nil, fmt.Errorf("agent %s is disabled", agentID)
+	}
+	// Pipeline stages, reviews and hook follow-ups of a running turn too
+	if s.AgentsPaused() {
+		return nil, errAgentsPaused
+	}
+
+	defer s.applyProjectEnv(agentID, t.project, sendEvent)()
+
+	// Initialize agent if needed
+	s.resetIfExited(agentID)
+	if st := s.agentInitSnapshot(agentID); st == nil || st.State != initReady {
+		sendEvent("status", map[string]string{"message": fmt.Sprintf("Initializing %s...", agentID)})
+		if err := s.ensureAgentInitialized(agentID, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	agentProc, err := s.agents.Get(agentID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get agent: %w", err)
+	}
+	agentProc.SetWorkingDir(root)
+
+	streamItems := make([]streamItem, 0)
+	currentText := ""
+	toolCallMap := make(map[string]int)
+	var edited editedFiles
+	var firstOutput time.Time
+	guard := &turnGuard{limits: s.turnLimits(convID)}
+
+	// Commands belong to the agent whichever session advertises them, and
+	// may follow session/new before the turn subscribes to its session
+	cleanupCommands := agentProc.OnNotification(func(msg *jsonrpc.Message) {
+		if commands, ok := commandsUpdate(msg); ok {
+			s.advertiseCommands(agentID, commands, sendEvent)
+		}
+	})
+	defer cleanupCommands()
+
+	cleanupTimeout := agentProc.OnTimeout(func(err *agent.TimeoutError) {
+		sendEvent("warning", map[string]any{
+			"message": err.Error(),
+			"method":  err.Method,
+			"action":  err.Action,
+		})
+	})
+	defer cleanupTimeout()
+
+	cleanupHealth := agentProc.OnHealth(func(ev *agent.HealthEvent) {
+		sendEvent("warning", map[string]any{
+			"message": ev.Message(),
+			"healthy": ev.Healthy,
+		})
+	})
+	defer cleanupHealth()
+
+	sessionID, newSession, err := s.agentSessions.getOrCreate(convID, agentID, func() (string, error) {
+		return s.createAgentSession(agentID, root, t.project)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.conversations.SetSessionID(convID, sessionID)
+
+	// Only the turn's own session is followed, as other chats and memory
+	// extraction may prompt the same agent meanwhile
+	updates, unsubscribe := agentProc.Subscribe(sessionID)
+	defer unsubscribe()
+	handleUpdate := func(msg *jsonrpc.Message) {
+		if _, ok := commandsUpdate(msg); ok {
+			return
+		}
+		if firstOutput.IsZero() && msg.Method == "session/update" {
+			firstOutput = time.Now()
+		}
+		if paths, action := toolFiles(msg); len(paths) > 0 {
+			for _, p := range paths {
+				s.recentFiles.Record(root, p, action)
+			}
+			if action == recentfiles.Edited {
+				edited.add(paths...)
+			}
+		}
+		s.handleNotification(msg, sendEvent, &streamItems, &currentText, toolCallMap, agentID)
+		guard.observe(msg)
+	}
+
+	// Files read and written through the client by the turn's session
+	cleanupFiles := agentProc.OnFileAccess(func(fileSession, path string, write bool) {
+		if fileSession != sessionID {
+			return
+		}
+		action := recentfiles.Read
+		if write {
+			action = recentfiles.Edited
+			edited.add(path)
+		}
+		s.recentFiles.Record(root, path, action)
+	})
+	defer cleanupFiles()
+
+	// Output of the commands the agent runs in terminals, for the tool
+	// calls showing them while they run
+	cleanupTerminal := agentProc.OnTerminal(func(ev *agent.TerminalEvent) {
+		if ev.SessionID == sessionID {
+			sendEvent("terminal", ev)
+		}
+	})
+	defer cleanupTerminal()
+
+	cleanupPermission := agentProc.OnPermission(func(req *agent.PermissionRequest) {
+		if req.SessionID != sessionID {
+			return
+		}
+		s.permissionRequested(convID, agentID, req, sendEvent)
+		sendEvent("permission_request", req)
+	})
+	defer cleanupPermission()
+	// Requests left unanswered when the turn ends are no longer pending
+	defer s.permissionsAbandoned(convID, agentID)
+
+	prompt := t.prompt()
+	if newSession {
+		if t.instructions != "" {
+			prompt = append([]map[string]any{{"type": "text", "text": t.instructions}}, prompt...)
+		}
+		prompt = s.withSystemPrompt(agentID, root, prompt, sendEvent)
+	}
+	prompt, err = s.scanPrompt(agentID, prompt, sendEvent)
+	if err != nil {
+		return nil, err
+	}
+	if t.ready != nil {
+		t.ready(sessionID)
+	}
+
+	// A turn exceeding its limits is canceled, also while waiting to retry
+	guard.start(func(reason string) {
+		turnLog.Warn("turn limit reached", "agent", agentID, "conversation", convID, "reason", reason)
+		sendEvent("warning", map[string]any{"message": reason, "truncated": true})
+		if !s.retries.cancel(sessionID) {
+			agentProc.Cancel(sessionID)
+		}
+	}, func(message string) {
+		turnLog.Warn("turn limit reached", "agent", agentID, "conversation", convID, "reason", message)
+		sendEvent("warning", map[string]any{"message": message, "loop": true})
+	})
+	defer guard.close()
+
+	// Call session/prompt, retrying while the provider is rate limited
+	var response *jsonrpc.Message
+	for attempt := 1; ; attempt++ {
+		response, err = agentProc.RequestWithUpdates("session/prompt", s.promptParams(agentID, sessionID, prompt, t.params), updates, handleUpdate)
+		reason := rateLimitedError(err)
+		if err == nil {
+			reason = rateLimitedStop(response)
+		}
+		if reason == "" {
+			break
+		}
+		if attempt > s.config.Retry.Retries() {
+			if err != nil && attempt > 1 {
+				err = fmt.Errorf("%w (gave up after %d retries)", err, attempt-1)
+			}
+			break
+		}
+		turnLog.Warn("rate limited, retrying", "agent", agentID, "attempt", attempt, "retries", s.config.Retry.Retries(), "reason", reason)
+
+		// The failed attempt's output isn't part of the conversation
+		streamItems, currentText = streamItems[:0], ""
+		edited.reset()
+		clear(toolCallMap)
+		guard.reset()
+		if !s.waitRetry(sessionID, attempt, reason, sendEvent) {
+			result := map[string]any{"stopReason": "cancelled"}
+			if truncated := guard.exceeded(); truncated != "" {
+				s.conversations.AddAnnotatedMessage(convID, truncated, agentID, "truncated")
+				result["truncated"] = truncated
+			}
+			return &turnResult{Result: result}, nil
+		}
+	}
+	var exited *agent.ExitError
+	if errors.As(err, &exited) {
+		return nil, fmt.Errorf("%w; send the message again to continue in a new agent session", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Finalize stream items
+	if currentText != "" {
+		streamItems = append(streamItems, streamItem{Type: "text", Text: currentText})
+	}
+
+	var text []string
+	var tools []*conversation.ToolCallInfo
+	for _, item := range streamItems {
+		if item.Type == "text" {
+			s.conversations.AddAnnotatedMessage(convID, item.Text, agentID, t.kind)
+			text = append(text, item.Text)
+		} else if item.Tool != nil {
+			s.conversations.AddToolCall(convID, item.Tool, agentID)
+			tools = append(tools, item.Tool)
+		}
+	}
+
+	var result map[string]any
+	response.ParseResult(&result)
+	if result == nil {
+		result = make(map[string]any)
+	}
+	if result["stopReason"] == nil {
+		result["stopReason"] = "end_turn"
+	}
+	// Whatever the agent reports, a truncated turn ends like a canceled one
+	if truncated := guard.exceeded(); truncated != "" {
+		s.conversations.AddAnnotatedMessage(convID, truncated, agentID, "truncated")
+		result["stopReason"] = "cancelled"
+		result["truncated"] = truncated
+	}
+
+	return &turnResult{
+		Result:      result,
+		Text:        strings.Join(text, "\n\n"),
+		Edited:      edited.list(),
+		Tools:       tools,
+		FirstOutput: firstOutput,
+	}, nil
+}
+
+// editedFiles collects the files a turn edits, which its updates report on
+// the turn's goroutine and fs/write_text_file calls on the agent's read loop
+type editedFiles struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (e *editedFiles) add(paths ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paths = append(e.paths, paths...)
+}
+
+// reset forgets the files of a failed attempt
+func (e *editedFiles) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paths = nil
+}
+
+// list returns a copy of the files edited so far
+func (e *editedFiles) list() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.paths...)
+}
+
+// textPrompt is a prompt consisting of a single text block
+func textPrompt(text string) func() []map[string]any {
+	return func() []map[string]any {
+		return []map[string]any{{"type": "text", "text": text}}
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daodao97/acpone/internal/conversation"
+)
+
+// uploadSweepInterval is how often expired uploads are looked for
+const uploadSweepInterval = 30 * time.Minute
+
+// ensureUploadDir creates a workspace's upload folder with a .gitignore
+// keeping its files out of git status
+func ensureUploadDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		return os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	return nil
+}
+
+// messageUploads returns the uploaded files attached to messages, as paths
+// inside dir
+func messageUploads(messages []conversation.Message, dir string) []string {
+	var paths []string
+	for _, m := range messages {
+		for _, f := range m.Files {
+			if filepath.Dir(filepath.Clean(f.Path)) == dir {
+				paths = append(paths, filepath.Clean(f.Path))
+			}
+		}
+	}
+	return paths
+}
+
+// setupUploadCleanup periodically deletes uploads whose conversations have
+// been idle longer than the upload keepHours
+func (s *Server) setupUploadCleanup() {
+	keep := s.config.Upload.Keep()
+	if keep == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(uploadSweepInterval)
+		defer ticker.Stop()
+		for {
+			s.sweepUploads(keep)
+			<-ticker.C
+		}
+	}()
+}
+
+// sweepUploads deletes uploads older than keep that no conversation active
+// within keep refers to. Uploads never sent in a message expire the same way.
+func (s *Server) sweepUploads(keep time.Duration) {
+	cutoff := time.Now().Add(-keep)
+	for _, ws := range s.workspaceStore.List() {
+		dir := filepath.Join(s.resolveWorkspacePath(ws.ID), uploadDir)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		// Files written after the cutoff belong to recent activity
+		var expired []string
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || e.IsDir() || e.Name() == ".gitignore" || info.ModTime().After(cutoff) {
+				continue
+			}
+			expired = append(expired, filepath.Join(dir, e.Name()))
+		}
+		if len(expired) == 0 {
+			continue
+		}
+
+		inUse := s.recentUploads(dir, cutoff.UnixMilli())
+		removed := 0
+		for _, path := range expired {
+			if inUse[path] {
+				continue
+			}
+			if err := os.Remove(path); err == nil {
+				removed++
+			}
+		}
+		if removed > 0 {
+			storageLog.Info("removed expired uploads", "count", removed, "workspace", ws.Name)
+		}
+	}
+}
+
+// recentUploads returns the uploads in dir referred to by conversations
+// updated since the cutoff or with a turn in progress
+func (s *Server) recentUploads(dir string, since int64) map[string]bool {
+	inUse := make(map[string]bool)
+	for _, meta := range s.sessionStore.List() {
+		if meta.UpdatedAt < since {
+			continue
+		}
+		if session, err := s.sessionStore.Load(meta.ID); err == nil {
+			for _, p := range messageUploads(session.Messages, dir) {
+				inUse[p] = true
+			}
+		}
+	}
+	for _, t := range s.turns.list() {
+		if conv := s.conversations.Get(t.ConversationID); conv != nil {
+			for _, p := range messageUploads(conv.Messages, dir) {
+				inUse[p] = true
+			}
+		}
+	}
+	return inUse
+}
+
+// removeConversationUploads deletes the uploads of a conversation being
+// deleted, except those another conversation (e.g. a merge) also refers to
+func (s *Server) removeConversationUploads(convID string) {
+	var messages []conversation.Message
+	var workspaceID string
+	if conv := s.conversations.Get(convID); conv != nil {
+		messages, workspaceID = conv.Messages, conv.WorkspaceID
+	} else if session, err := s.sessionStore.Load(convID); err == nil {
+		messages, workspaceID = session.Messages, session.WorkspaceID
+	}
+	s.removeUploads(convID, messages, workspaceID)
+}
+
+// removeUploads deletes the uploads attached to the messages of a deleted
+// conversation that no other conversation refers to
+func (s *Server) removeUploads(convID string, messages []conversation.Message, workspaceID string) {
+	dir := filepath.Join(s.resolveWorkspacePath(workspaceID), uploadDir)
+	paths := messageUploads(messages, dir)
+	if len(paths) == 0 {
+		return
+	}
+
+	shared := make(map[string]bool)
+	for _, meta := range s.sessionStore.List() {
+		if meta.ID == convID {
+			continue
+		}
+		if session, err := s.sessionStore.Load(meta.ID); err == nil {
+			for _, p := range messageUploads(session.Messages, dir) {
+				shared[p] = true
+			}
+		}
+	}
+	for _, p := range paths {
+		if !shared[p] {
+			os.Remove(p)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// uploadError is an upload rejected by the policy, with its HTTP status
+type uploadError struct {
+	msg    string
+	status int
+}
+
+func (e *uploadError) Error() string { return e.msg }
+
+// executableMagic are the leading bytes of ELF, PE and Mach-O binaries
+var executableMagic = [][]byte{
+	[]byte("\x7fELF"),
+	[]byte("MZ"),
+	{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal, also Java class files
+}
+
+// uploadFileLimit returns the largest accepted file in bytes
+func uploadFileLimit(policy *config.UploadConfig) int64 {
+	if policy != nil && policy.MaxFileMB > 0 {
+		return int64(policy.MaxFileMB) << 20
+	}
+	return maxUploadSize
+}
+
+// checkUploads applies the size, type and quota rules to the files of one
+// upload before any is saved. dir is the workspace's upload folder.
+func checkUploads(policy *config.UploadConfig, dir string, files []*multipart.FileHeader) error {
+	limit := uploadFileLimit(policy)
+	var total int64
+	for _, fh := range files {
+		if fh.Size > limit {
+			return &uploadError{fmt.Sprintf("%s is too large (max %dMB)", fh.Filename, limit>>20), 413}
+		}
+		if policy != nil && !policy.AllowsExtension(filepath.Ext(fh.Filename)) {
+			return &uploadError{fmt.Sprintf("%s: file type not allowed (allowed: %s)", fh.Filename, strings.Join(policy.Extensions, ", ")), 415}
+		}
+		if policy != nil && !policy.AllowExecutables {
+			if exe, err := isExecutable(fh); err == nil && exe {
+				return &uploadError{fh.Filename + ": executables are not allowed", 415}
+			}
+		}
+		total += fh.Size
+	}
+
+	if policy != nil && policy.MaxWorkspaceMB > 0 {
+		quota := int64(policy.MaxWorkspaceMB) << 20
+		if used := dirSize(dir); used+total > quota {
+			return &uploadError{fmt.Sprintf("Workspace upload quota exceeded (%dMB of %dMB used)", used>>20, policy.MaxWorkspaceMB), 413}
+		}
+	}
+	return nil
+}
+
+// isExecutable sniffs the file header for a native binary
+func isExecutable(fh *multipart.FileHeader) (bool, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	n, _ := f.Read(head)
+	for _, magic := range executableMagic {
+		if bytes.HasPrefix(head[:n], magic) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// scanUpload runs the policy's scan command on a saved file, uploaded as
+// name. A non-zero exit, as clamscan returns for infected files, rejects it.
+func scanUpload(policy *config.UploadConfig, root, path, name string) error {
+	if policy == nil || policy.ScanCommand == "" {
+		return nil
+	}
+	timeout := 60 * time.Second
+	if policy.ScanTimeoutSeconds > 0 {
+		timeout = time.Duration(policy.ScanTimeoutSeconds) * time.Second
+	}
+	output, err := runShell(root, policy.ScanCommand+" "+shellQuote(path), nil, timeout)
+	if err != nil {
+		msg := strings.TrimSpace(output)
+		if msg == "" {
+			msg = err.Error()
+		}
+		return &uploadError{fmt.Sprintf("%s rejected by scan: %s", name, msg), 422}
+	}
+	return nil
+}
+
+// shellQuote quotes an argument for the shell runShell uses
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// removeFiles deletes the files saved by a rejected upload
+func removeFiles(files []UploadedFile) {
+	for _, f := range files {
+		os.Remove(f.Path)
+	}
+}
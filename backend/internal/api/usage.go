@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextWarnPercent is the share of the context window at which turns warn
+const contextWarnPercent = 80
+
+// tokenizerProfile holds rough tokenizer and context window figures for an
+// agent family, matched against the agent's ID and command line
+type tokenizerProfile struct {
+	match         string
+	charsPerToken float64
+	window        int
+}
+
+var tokenizerProfiles = []tokenizerProfile{
+	{match: "claude", charsPerToken: 3.5, window: 200_000},
+	{match: "codex", charsPerToken: 4, window: 272_000},
+	{match: "gemini", charsPerToken: 4, window: 1_000_000},
+	{match: "qwen", charsPerToken: 4, window: 256_000},
+}
+
+var defaultTokenizerProfile = tokenizerProfile{charsPerToken: 4, window: 128_000}
+
+// contextUsage is the estimated size of a conversation's accumulated context
+// measured against the agent's context window
+type contextUsage struct {
+	Agent   string `json:"agent"`
+	Tokens  int    `json:"tokens"`
+	Window  int    `json:"window"`
+	Percent int    `json:"percent"`
+	Warning bool   `json:"warning,omitempty"` // At or above contextWarnPercent
+}
+
+// tokenizerFor returns the profile of an agent, with its configured
+// contextWindow taking precedence
+func (s *Server) tokenizerFor(agentID string) tokenizerProfile {
+	p := defaultTokenizerProfile
+	a := s.findAgent(agentID)
+	if a == nil {
+		return p
+	}
+	ident := strings.ToLower(a.ID + " " + a.Command + " " + strings.Join(a.Args, " "))
+	for _, candidate := range tokenizerProfiles {
+		if strings.Contains(ident, candidate.match) {
+			p = candidate
+			break
+		}
+	}
+	if a.ContextWindow > 0 {
+		p.window = a.ContextWindow
+	}
+	return p
+}
+
+// contextUsage estimates how much of the agent's window the conversation
+// history takes up
+func (s *Server) contextUsage(convID, agentID string) contextUsage {
+	p := s.tokenizerFor(agentID)
+	tokens := s.conversations.EstimateTokens(convID, p.charsPerToken)
+	percent := tokens * 100 / p.window
+	return contextUsage{
+		Agent:   agentID,
+		Tokens:  tokens,
+		Window:  p.window,
+		Percent: percent,
+		Warning: percent >= contextWarnPercent,
+	}
+}
+
+// warnContextUsage tells the client when the conversation nears the agent's
+// context window
+func warnContextUsage(usage contextUsage, sendEvent func(string, any)) {
+	if !usage.Warning {
+		return
+	}
+	sendEvent("warning", map[string]any{
+		"message": fmt.Sprintf("Conversation context is about %d%% of %s's window (~%dk of %dk tokens). Consider compacting or starting a new conversation.",
+			usage.Percent, usage.Agent, usage.Tokens/1000, usage.Window/1000),
+		"context": usage,
+	})
+}
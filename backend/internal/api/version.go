@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/events"
+)
+
+// versionInfo identifies the running backend and the UI bundle it serves.
+// Client is injected into index.html; a page whose injected value differs
+// was loaded from another build and should reload.
+type versionInfo struct {
+	Version string `json:"version"` // Backend version
+	Build   string `json:"build"`   // Hash of the served index.html
+	Client  string `json:"client"`  // version+build, as injected into index.html
+}
+
+// bundleBuild hashes a bundle's index.html, whose asset names change with
+// every frontend build
+func bundleBuild(staticFS fs.FS) string {
+	if staticFS == nil {
+		return ""
+	}
+	data, err := fs.ReadFile(staticFS, "index.html")
+	if err != nil {
+		return ""
+	}
+	return buildOf(data)
+}
+
+func buildOf(index []byte) string {
+	sum := sha256.Sum256(index)
+	return hex.EncodeToString(sum[:6])
+}
+
+// version describes the backend and the currently served bundle
+func (s *Server) version() versionInfo {
+	build := bundleBuild(s.frontend.current())
+	return versionInfo{Version: Version, Build: build, Client: Version + "+" + build}
+}
+
+// publishVersion tells connected clients which build is current. It is sent
+// at startup, so clients replaying the event log after an update see it,
+// and whenever the served bundle changes.
+func (s *Server) publishVersion() {
+	s.events.Publish(events.Event{Topic: events.Config, Type: "version", Data: s.version()})
+}
+
+// handleVersion returns the backend and frontend versions
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.version())
+}
+
+// serveIndex serves index.html with the client version injected as
+// <meta name="acpone-version">. It is never cached, so a reload always
+// picks up the current bundle's asset names.
+func serveIndex(w http.ResponseWriter, r *http.Request, staticFS fs.FS) {
+	data, err := fs.ReadFile(staticFS, "index.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	client := Version + "+" + buildOf(data)
+	meta := `<meta name="acpone-version" content="` + html.EscapeString(client) + `">`
+	if i := bytes.Index(data, []byte("</head>")); i >= 0 {
+		data = append(data[:i:i], append([]byte(meta+"\n"), data[i:]...)...)
+	} else {
+		data = append([]byte(meta+"\n"), data...)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "index.html", time.Time{}, bytes.NewReader(data))
+}
+
+// cacheStatic sets caching headers for a static file. Vite puts
+// content-hashed files under assets/, which never change.
+func cacheStatic(w http.ResponseWriter, path string) {
+	if strings.HasPrefix(path, "assets/") {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// workspaceKind marks the transcript message recording a workspace switch
+const workspaceKind = "workspace"
+
+// workspaceSwitch is the outcome of switching a conversation's workspace,
+// sent as the workspace event of /workspace
+type workspaceSwitch struct {
+	ConversationID string `json:"conversationId"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+	Resumed        bool   `json:"resumed"` // The agents resume the sessions they had there
+	Message        string `json:"message"` // Recorded in the transcript
+}
+
+// switchWorkspace continues a conversation in another workspace. Unlike a
+// move its agent sessions are kept for a switch back: the agents resume
+// those they had in the new workspace or start new ones there, the next
+// turn carrying the recent messages over as context.
+func (s *Server) switchWorkspace(id, workspaceID string) (*workspaceSwitch, *storage.StoredSession, error) {
+	conv, err := s.checkMove(id, workspaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	sw := &workspaceSwitch{ConversationID: id, From: conv.WorkspaceID, To: workspaceID}
+	sw.Resumed = s.agentSessions.switchWorkspace(id, sw.From, sw.To)
+	sw.Message = fmt.Sprintf("Switched workspace from %s to %s", s.describeWorkspace(sw.From), s.describeWorkspace(sw.To))
+	s.conversations.AddAnnotatedMessage(id, sw.Message, "", workspaceKind)
+
+	session, err := s.relocateConversation(id, workspaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sw, session, nil
+}
+
+// describeWorkspace names a workspace with its path, which the agents see
+// in the carried over context
+func (s *Server) describeWorkspace(id string) string {
+	ws, ok := s.workspaceStore.Find(id)
+	if !ok {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", ws.Name, ws.Path)
+}
+
+// handleSessionWorkspace switches a conversation's workspace mid-thread:
+// POST {workspaceId}
+func (s *Server) handleSessionWorkspace(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		WorkspaceID string `json:"workspaceId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.WorkspaceID == "" {
+		writeError(w, "workspaceId required", http.StatusBadRequest)
+		return
+	}
+	sw, session, err := s.switchWorkspace(id, req.WorkspaceID)
+	if err != nil {
+		writeMoveError(w, err)
+		return
+	}
+	writeJSON(w, map[string]any{"session": session, "switch": sw, "missingPins": s.missingPins(session)})
+}
+
+// workspaceCommand returns the argument of a "/workspace <id or name>"
+// chat message
+func workspaceCommand(message string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(message), "/workspace")
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// findWorkspace looks a workspace up by ID, or else by name
+func (s *Server) findWorkspace(ref string) (config.WorkspaceConfig, bool) {
+	if ws, ok := s.workspaceStore.Find(ref); ok {
+		return ws, true
+	}
+	for _, ws := range s.workspaceStore.List() {
+		if strings.EqualFold(ws.Name, ref) {
+			return ws, true
+		}
+	}
+	return config.WorkspaceConfig{}, false
+}
+
+// runWorkspaceCommand handles /workspace in the chat without prompting an
+// agent. A refused switch isn't recorded; an unknown workspace lists them.
+func (s *Server) runWorkspaceCommand(convID string, isNew bool, message, ref string, sendEvent func(string, any)) {
+	ws, ok := s.findWorkspace(ref)
+	if !ok {
+		var names []string
+		for _, ws := range s.workspaceStore.List() {
+			names = append(names, fmt.Sprintf("%s (%s)", ws.ID, ws.Name))
+		}
+		msg := "Usage: /workspace <id or name>"
+		if ref != "" {
+			msg = "Workspace not found: " + ref
+		}
+		sendEvent("error", map[string]string{"message": msg + ". Workspaces: " + strings.Join(names, ", ")})
+		return
+	}
+	if _, err := s.checkMove(convID, ws.ID); err != nil {
+		sendEvent("error", map[string]string{"message": err.Error()})
+		return
+	}
+
+	s.conversations.AddUserMessage(convID, message, nil)
+	sendEvent("session", map[string]any{
+		"conversationId": convID,
+		"agent":          s.conversations.Get(convID).ActiveAgent,
+		"isNew":          isNew,
+	})
+	sw, _, err := s.switchWorkspace(convID, ws.ID)
+	if err != nil {
+		sendEvent("error", map[string]string{"message": err.Error()})
+		return
+	}
+	sendEvent("workspace", sw)
+	sendEvent("done", map[string]any{"stopReason": "end_turn", "workspace": sw.To})
+}
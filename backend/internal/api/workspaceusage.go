@@ -0,0 +1,220 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// usageTTL is how long a measured workspace usage is reused, as walking
+	// dependency directories takes a while
+	usageTTL = time.Minute
+	// usageMaxDepth bounds how deep heavy directories are looked for
+	usageMaxDepth = 6
+)
+
+// usageEntry is the size of one directory
+type usageEntry struct {
+	Path  string `json:"path"` // Relative to the workspace root
+	Bytes int64  `json:"bytes"`
+	Files int    `json:"files"`
+}
+
+// workspaceUsage is the disk space a workspace takes up in uploads,
+// transcripts and dependency or build output directories
+type workspaceUsage struct {
+	WorkspaceID string       `json:"workspaceId"`
+	Uploads     usageEntry   `json:"uploads"`
+	Transcripts usageEntry   `json:"transcripts"`
+	HeavyDirs   []usageEntry `json:"heavyDirs"`
+	Total       int64        `json:"total"` // Bytes of all of the above
+	Quotas      usageQuotas  `json:"quotas"`
+	Exceeded    []string     `json:"exceeded,omitempty"` // One message per exceeded quota
+	MeasuredAt  int64        `json:"measuredAt"`
+}
+
+// usageQuotas are the configured quotas in MB, 0 when unlimited
+type usageQuotas struct {
+	UploadsMB     int `json:"uploadsMB,omitempty"`
+	TranscriptsMB int `json:"transcriptsMB,omitempty"`
+	TotalMB       int `json:"totalMB,omitempty"`
+}
+
+// usageCache holds measured usage by workspace root
+type usageCache struct {
+	mu      sync.Mutex
+	entries map[string]*workspaceUsage
+}
+
+func (c *usageCache) get(root string) *workspaceUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := c.entries[root]
+	if u == nil || time.Since(time.UnixMilli(u.MeasuredAt)) > usageTTL {
+		return nil
+	}
+	return u
+}
+
+func (c *usageCache) put(root string, u *workspaceUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*workspaceUsage)
+	}
+	c.entries[root] = u
+}
+
+// invalidate drops a workspace's usage after it changed
+func (c *usageCache) invalidate(root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, root)
+}
+
+// handleWorkspaceByID serves /api/workspaces/{id}/usage and
+// /api/workspaces/{id}/memory
+func (s *Server) handleWorkspaceByID(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/workspaces/"), "/")
+	switch {
+	case id == "":
+		http.NotFound(w, r)
+		return
+	case action != "usage" && action != "memory" && action != "memory/extract":
+		http.NotFound(w, r)
+		return
+	}
+	ws, ok := s.workspaceStore.Find(id)
+	if !ok {
+		writeError(w, "Workspace not found", http.StatusNotFound)
+		return
+	}
+	if action != "usage" {
+		s.handleWorkspaceMemory(w, r, ws.ID, ws.Path, action == "memory/extract")
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.workspaceUsage(ws.ID, ws.Path, r.URL.Query().Get("refresh") != ""))
+}
+
+// workspaceUsage measures a workspace, reusing a recent measurement unless
+// refresh is set
+func (s *Server) workspaceUsage(id, root string, refresh bool) *workspaceUsage {
+	if !refresh {
+		if u := s.usage.get(root); u != nil {
+			return u
+		}
+	}
+	u := s.measureUsage(root)
+	u.WorkspaceID = id
+	s.usage.put(root, u)
+	return u
+}
+
+func (s *Server) measureUsage(root string) *workspaceUsage {
+	u := &workspaceUsage{
+		Uploads:     dirUsage(root, filepath.Join(root, uploadDir)),
+		Transcripts: dirUsage(root, filepath.Join(root, transcriptDir)),
+		HeavyDirs:   []usageEntry{},
+		MeasuredAt:  time.Now().UnixMilli(),
+	}
+
+	heavy := s.config.Usage.Heavy()
+	skip := map[string]bool{
+		filepath.Join(root, ".git"):        true,
+		filepath.Join(root, uploadDir):     true,
+		filepath.Join(root, transcriptDir): true,
+	}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == root {
+			return nil
+		}
+		if skip[path] {
+			return filepath.SkipDir
+		}
+		if slices.Contains(heavy, d.Name()) {
+			u.HeavyDirs = append(u.HeavyDirs, dirUsage(root, path))
+			return filepath.SkipDir
+		}
+		if strings.Count(strings.TrimPrefix(path, root), string(filepath.Separator)) >= usageMaxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	slices.SortFunc(u.HeavyDirs, func(a, b usageEntry) int {
+		return cmp.Compare(b.Bytes, a.Bytes)
+	})
+
+	u.Total = u.Uploads.Bytes + u.Transcripts.Bytes
+	for _, e := range u.HeavyDirs {
+		u.Total += e.Bytes
+	}
+
+	if policy := s.config.Upload; policy != nil {
+		u.Quotas.UploadsMB = policy.MaxWorkspaceMB
+	}
+	if quota := s.config.Usage; quota != nil {
+		u.Quotas.TranscriptsMB = quota.MaxTranscriptsMB
+		u.Quotas.TotalMB = quota.MaxMB
+	}
+	exceeded := func(what string, used int64, quotaMB int) {
+		if quotaMB > 0 && used > int64(quotaMB)<<20 {
+			u.Exceeded = append(u.Exceeded, fmt.Sprintf("%s use %dMB of %dMB", what, used>>20, quotaMB))
+		}
+	}
+	exceeded("Uploads", u.Uploads.Bytes, u.Quotas.UploadsMB)
+	exceeded("Transcripts", u.Transcripts.Bytes, u.Quotas.TranscriptsMB)
+	exceeded("Workspace files", u.Total, u.Quotas.TotalMB)
+	return u
+}
+
+// dirUsage returns the size and file count of dir
+func dirUsage(root, dir string) usageEntry {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		rel = dir
+	}
+	e := usageEntry{Path: filepath.ToSlash(rel)}
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				e.Bytes += info.Size()
+				e.Files++
+			}
+		}
+		return nil
+	})
+	return e
+}
+
+// checkUsageQuota refuses an upload that would take the workspace past its
+// total quota, or while its transcripts are over theirs
+func (s *Server) checkUsageQuota(workspaceID, root string, files []*multipart.FileHeader) error {
+	quota := s.config.Usage
+	if quota == nil || (quota.MaxMB == 0 && quota.MaxTranscriptsMB == 0) {
+		return nil
+	}
+	u := s.workspaceUsage(workspaceID, root, false)
+	var total int64
+	for _, fh := range files {
+		total += fh.Size
+	}
+	if quota.MaxMB > 0 && u.Total+total > int64(quota.MaxMB)<<20 {
+		return &uploadError{fmt.Sprintf("Workspace quota exceeded (%dMB of %dMB used)", u.Total>>20, quota.MaxMB), 413}
+	}
+	if quota.MaxTranscriptsMB > 0 && u.Transcripts.Bytes > int64(quota.MaxTranscriptsMB)<<20 {
+		return &uploadError{fmt.Sprintf("Transcript quota exceeded (%dMB of %dMB used)", u.Transcripts.Bytes>>20, quota.MaxTranscriptsMB), 413}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// Package backup creates and restores single-file archives of acpone data.
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+const (
+	manifestName = "manifest.json"
+	configName   = "config.json"
+	dataPrefix   = "data/"
+)
+
+// excluded are machine-local keys never included in a backup
+var excluded = []string{
+	"recordings/",
+	"sync-git/",
+	"agents.pid.json",
+	"sync-state.json",
+	"acpone.config.json", // Stored separately as config.json
+	"sessions.db",        // Its sessions are backed up under sessions/
+	"sessions.db-wal",
+	"sessions.db-shm",
+}
+
+// Manifest describes a backup archive
+type Manifest struct {
+	Version    int      `json:"version"`
+	CreatedAt  string   `json:"createdAt"`
+	Host       string   `json:"host,omitempty"`
+	ConfigPath string   `json:"configPath,omitempty"` // Original location of the config file
+	Files      []string `json:"files"`                // Data keys
+}
+
+func isExcluded(key string) bool {
+	if strings.HasSuffix(key, ".tmp") {
+		return true
+	}
+	for _, e := range excluded {
+		if key == e || (strings.HasSuffix(e, "/") && strings.HasPrefix(key, e)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Create writes a zip archive of the config file and all data in backend
+// (sessions, workspace registry, permission lists, ...) to w
+func Create(w io.Writer, backend storage.Backend, configPath string) (*Manifest, error) {
+	host, _ := os.Hostname()
+	manifest := &Manifest{
+		Version:    1,
+		CreatedAt:  time.Now().Format(time.RFC3339),
+		Host:       host,
+		ConfigPath: configPath,
+	}
+
+	zw := zip.NewWriter(w)
+
+	if configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		if err := writeEntry(zw, configName, data); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := backend.List("")
+	if err != nil {
+		return nil, fmt.Errorf("list data: %w", err)
+	}
+	for _, key := range keys {
+		if isExcluded(key) {
+			continue
+		}
+		data, err := backend.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+		if err := writeEntry(zw, dataPrefix+key, data); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, key)
+	}
+
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := writeEntry(zw, manifestName, data); err != nil {
+		return nil, err
+	}
+	return manifest, zw.Close()
+}
+
+func writeEntry(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Restore replaces the data in backend with the archive's contents and
+// writes its config file to configPath (skipped when configPath is empty).
+// Existing sessions not present in the archive are removed. The archive is
+// read in full first: a damaged one fails without changing anything.
+func Restore(r io.ReaderAt, size int64, backend storage.Backend, configPath string) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	mf, ok := files[manifestName]
+	if !ok {
+		return nil, errors.New("not an acpone backup: missing manifest.json")
+	}
+	var manifest Manifest
+	if err := readJSON(mf, &manifest); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	// Everything is read and checked before the first write, so a damaged
+	// archive leaves the current data untouched
+	data := make(map[string][]byte)
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, dataPrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(f.Name, dataPrefix)
+		if isExcluded(key) {
+			continue
+		}
+		if err := validKey(key); err != nil {
+			return nil, err
+		}
+		content, err := readEntry(f)
+		if err != nil {
+			return nil, err
+		}
+		data[key] = content
+	}
+	var config []byte
+	if cf, ok := files[configName]; ok && configPath != "" {
+		if config, err = readEntry(cf); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sessions are replaced as a whole so the result matches the backup
+	existing, err := backend.List("sessions/")
+	if err != nil {
+		return nil, fmt.Errorf("list data: %w", err)
+	}
+	for _, key := range existing {
+		if _, ok := data[key]; ok {
+			continue
+		}
+		if err := backend.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+	for key, content := range data {
+		if err := backend.Put(key, content); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", key, err)
+		}
+	}
+
+	if config != nil {
+		if err := writeConfig(configPath, config); err != nil {
+			return nil, fmt.Errorf("restore config: %w", err)
+		}
+	}
+	return &manifest, nil
+}
+
+// RestoreBytes restores an archive held in memory
+func RestoreBytes(data []byte, backend storage.Backend, configPath string) (*Manifest, error) {
+	return Restore(bytes.NewReader(data), int64(len(data)), backend, configPath)
+}
+
+// readEntry reads an archive entry, checking its checksum and, for JSON
+// files, that it parses
+func readEntry(f *zip.File) ([]byte, error) {
+	data, err := readAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", f.Name, err)
+	}
+	if path.Ext(f.Name) == ".json" && !json.Valid(data) {
+		return nil, fmt.Errorf("read %s: invalid JSON", f.Name)
+	}
+	return data, nil
+}
+
+// validKey rejects data keys that could escape the data directory
+func validKey(key string) error {
+	if !fs.ValidPath(key) || key == "." || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid data key in archive: %q", key)
+	}
+	return nil
+}
+
+// writeConfig replaces the config file, readable by the owner only as it
+// can hold API keys
+func writeConfig(configPath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, configPath)
+}
+
+func readAll(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func readJSON(f *zip.File, v any) error {
+	data, err := readAll(f)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+func archive(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range entries {
+		if err := writeEntry(zw, name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestore(t *testing.T) {
+	backend := storage.NewLocalBackend(t.TempDir())
+	backend.Put("sessions/_default/old.json", []byte(`{"id":"old"}`))
+	configPath := filepath.Join(t.TempDir(), "acpone.config.json")
+
+	data := archive(t, map[string]string{
+		manifestName:                      `{"version":1}`,
+		configName:                        `{"agents":[]}`,
+		dataPrefix + "sessions/ws/a.json": `{"id":"a"}`,
+		dataPrefix + "workspaces.json":    `{}`,
+		dataPrefix + "sessions.db":        "excluded",
+		dataPrefix + "sync-state.json":    "excluded",
+	})
+	if _, err := RestoreBytes(data, backend, configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, _ := backend.List("")
+	slices.Sort(keys)
+	want := []string{"sessions/ws/a.json", "workspaces.json"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("restored keys %v, want %v", keys, want)
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("config restored with mode %o, want 600", mode)
+	}
+}
+
+func TestRestoreDamagedArchiveChangesNothing(t *testing.T) {
+	for name, entries := range map[string]map[string]string{
+		"invalid session": {
+			dataPrefix + "sessions/ws/a.json": `{"id":"a"}`,
+			dataPrefix + "sessions/ws/b.json": `{"id":`,
+		},
+		"invalid config": {
+			configName:                        `{`,
+			dataPrefix + "sessions/ws/a.json": `{"id":"a"}`,
+		},
+		"key escaping the data dir": {
+			dataPrefix + "../outside.json": `{}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			backend := storage.NewLocalBackend(t.TempDir())
+			backend.Put("sessions/_default/old.json", []byte(`{"id":"old"}`))
+			configPath := filepath.Join(t.TempDir(), "acpone.config.json")
+
+			entries[manifestName] = `{"version":1}`
+			if _, err := RestoreBytes(archive(t, entries), backend, configPath); err == nil {
+				t.Fatal("restored a damaged archive")
+			}
+			if keys, _ := backend.List(""); !slices.Equal(keys, []string{"sessions/_default/old.json"}) {
+				t.Errorf("data changed by a failed restore: %v", keys)
+			}
+			if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+				t.Errorf("config written by a failed restore: %v", err)
+			}
+		})
+	}
+}
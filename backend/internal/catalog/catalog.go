@@ -0,0 +1,140 @@
+// Package catalog lists known ACP agents and the CLIs they drive, so setup
+// can check and install them and users can add them in one click.
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+//go:embed catalog.json
+var builtinData []byte
+
+// Auth describes how to tell whether a CLI is logged in
+type Auth struct {
+	Env      []string          `json:"env,omitempty"`      // Any of these set means an API key is configured
+	Files    []string          `json:"files,omitempty"`    // Credential files, relative to home
+	JSONKeys map[string]string `json:"jsonKeys,omitempty"` // Home-relative JSON file -> key present once logged in
+	Status   []string          `json:"status,omitempty"`   // Status command that exits 0 when logged in
+	Login    string            `json:"login,omitempty"`    // Command the user runs to log in
+}
+
+// Tool is a CLI an agent needs on PATH
+type Tool struct {
+	Command string `json:"command"`
+	Name    string `json:"name"`
+	Package string `json:"package,omitempty"` // npm package setup can install globally
+	Install string `json:"install,omitempty"` // Install command or URL shown to the user
+	Auth    *Auth  `json:"auth,omitempty"`
+}
+
+// Agent is an agent config template
+type Agent struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Command     string   `json:"command"`
+	Args        []string `json:"args,omitempty"`
+	Requires    string   `json:"requires,omitempty"` // Tool command the agent drives
+	EnvHints    []string `json:"envHints,omitempty"` // Env vars the user will likely set
+}
+
+// Package returns the npm package an npx agent runs, or ""
+func (a *Agent) Package() string {
+	if a.Command != "npx" {
+		return ""
+	}
+	for _, arg := range a.Args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+// Catalog is the built-in catalog merged with the user's overrides
+type Catalog struct {
+	Tools  []Tool  `json:"tools"`
+	Agents []Agent `json:"agents"`
+}
+
+// UserPath returns the override file ~/.acpone/catalog.json
+func UserPath() string {
+	return filepath.Join(sysutil.DataDir(), "catalog.json")
+}
+
+// Load returns the built-in catalog with entries from the user's file
+// replacing those with the same command or id, and new ones appended
+func Load() (*Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(builtinData, &c); err != nil {
+		return nil, fmt.Errorf("builtin catalog: %w", err)
+	}
+
+	data, err := os.ReadFile(UserPath())
+	if os.IsNotExist(err) {
+		return &c, nil
+	}
+	if err != nil {
+		return &c, err
+	}
+	var user Catalog
+	if err := json.Unmarshal(data, &user); err != nil {
+		return &c, fmt.Errorf("%s: %w", UserPath(), err)
+	}
+	c.merge(&user)
+	return &c, nil
+}
+
+func (c *Catalog) merge(user *Catalog) {
+	for _, t := range user.Tools {
+		if existing := c.Tool(t.Command); existing != nil {
+			*existing = t
+		} else {
+			c.Tools = append(c.Tools, t)
+		}
+	}
+	for _, a := range user.Agents {
+		if existing := c.Agent(a.ID); existing != nil {
+			*existing = a
+		} else {
+			c.Agents = append(c.Agents, a)
+		}
+	}
+}
+
+// Tool returns the tool run as command, or nil
+func (c *Catalog) Tool(command string) *Tool {
+	for i := range c.Tools {
+		if c.Tools[i].Command == command {
+			return &c.Tools[i]
+		}
+	}
+	return nil
+}
+
+// Agent returns the agent template with id, or nil
+func (c *Catalog) Agent(id string) *Agent {
+	for i := range c.Agents {
+		if c.Agents[i].ID == id {
+			return &c.Agents[i]
+		}
+	}
+	return nil
+}
+
+// RequiredTool returns the tool an npx agent running pkg drives, or nil
+func (c *Catalog) RequiredTool(pkg string) *Tool {
+	for i := range c.Agents {
+		if a := &c.Agents[i]; a.Requires != "" && a.Package() == pkg {
+			return c.Tool(a.Requires)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var aliasRegex = regexp.MustCompile(`^\w+$`)
+
+// FindAgentByName returns the agent whose ID or alias is name
+func (c *Config) FindAgentByName(name string) *AgentConfig {
+	if a := c.FindAgent(name); a != nil {
+		return a
+	}
+	for i := range c.Agents {
+		for _, alias := range c.Agents[i].Aliases {
+			if alias == name {
+				return &c.Agents[i]
+			}
+		}
+	}
+	return nil
+}
+
+// validateAliases checks that aliases are valid @mention names that do not
+// collide with agent IDs or each other
+func (c *Config) validateAliases() error {
+	owner := make(map[string]string)
+	for _, a := range c.Agents {
+		owner[a.ID] = a.ID
+	}
+	for _, a := range c.Agents {
+		for _, alias := range a.Aliases {
+			if !aliasRegex.MatchString(alias) {
+				return fmt.Errorf("agent %s: invalid alias: %q", a.ID, alias)
+			}
+			if other, ok := owner[alias]; ok {
+				return fmt.Errorf("agent %s: alias %s is already used by %s", a.ID, alias, other)
+			}
+			owner[alias] = a.ID
+		}
+	}
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/daodao97/acpone/internal/sysutil"
 )
 
 //go:embed acpone.config.example.json
@@ -21,57 +23,102 @@ type WorkspaceConfig struct {
 
 // AgentConfig defines an ACP agent
 type AgentConfig struct {
-	ID             string            `json:"id"`
-	Name           string            `json:"name"`
-	Command        string            `json:"command"`
-	Args           []string          `json:"args,omitempty"`
-	Env            map[string]string `json:"env,omitempty"`
-	Prestart       bool              `json:"prestart,omitempty"`
-	PermissionMode string            `json:"permissionMode,omitempty"`
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Aliases          []string          `json:"aliases,omitempty"` // Extra @mention names, e.g. "cc"
+	Enabled          *bool             `json:"enabled,omitempty"` // nil means enabled
+	Command          string            `json:"command"`
+	Args             []string          `json:"args,omitempty"`
+	Env              map[string]string `json:"env,omitempty"`
+	PathPrepend      []string          `json:"pathPrepend,omitempty"` // Dirs put in front of PATH, "~" expanded
+	ShellInit        string            `json:"shellInit,omitempty"`   // Shell snippet whose exported env the agent runs with
+	Prestart         bool              `json:"prestart,omitempty"`
+	PermissionMode   string            `json:"permissionMode,omitempty"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`     // Prepended to the first prompt of each new session
+	SystemPromptFile string            `json:"systemPromptFile,omitempty"` // File read for the same purpose, "~" expanded
+	ContextWindow    int               `json:"contextWindow,omitempty"`    // Tokens, overrides the estimate for the agent family
+	Timeouts         *TimeoutConfig    `json:"timeouts,omitempty"`
+	Heartbeat        *HeartbeatConfig  `json:"heartbeat,omitempty"`
+	Restart          *RestartConfig    `json:"restart,omitempty"`  // Restarts after the process exits on its own
+	Install          *InstallConfig    `json:"install,omitempty"`  // How setup installs a non-npm agent
+	ParamsIn         string            `json:"paramsIn,omitempty"` // Where prompts carry conversation agentParams: meta (default) or prompt
+}
+
+// IsEnabled reports whether the agent may be routed to and started
+func (a *AgentConfig) IsEnabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+// Where session/prompt carries a conversation's agent parameters
+const (
+	ParamsMeta   = "meta"   // In _meta (default)
+	ParamsPrompt = "prompt" // As top-level session/prompt fields, for agents reading their own
+)
+
+// HeartbeatConfig enables liveness checks of a running agent
+type HeartbeatConfig struct {
+	IntervalMs       int  `json:"intervalMs,omitempty"`       // Check interval (default 5s)
+	UnhealthyAfterMs int  `json:"unhealthyAfterMs,omitempty"` // Silence during a turn before unhealthy (default 2m)
+	Restart          bool `json:"restart,omitempty"`          // Restart unhealthy agents
+}
+
+// Timeout expiry actions
+const (
+	TimeoutError   = "error"   // Fail the request (default)
+	TimeoutRestart = "restart" // Fail the request and restart the agent
+	TimeoutNotify  = "notify"  // Warn and keep waiting
+)
+
+// TimeoutConfig defines per-request timeouts in milliseconds (0 = no timeout)
+type TimeoutConfig struct {
+	Initialize int    `json:"initialize,omitempty"`
+	SessionNew int    `json:"sessionNew,omitempty"`
+	Prompt     int    `json:"prompt,omitempty"`
+	ToolQuiet  int    `json:"toolQuiet,omitempty"` // Max silence from the agent during a prompt
+	OnExpiry   string `json:"onExpiry,omitempty"`  // error, restart or notify
 }
 
 // RoutingConfig defines routing rules
 type RoutingConfig struct {
-	Keywords map[string]string `json:"keywords,omitempty"`
-	Meta     bool              `json:"meta,omitempty"`
+	Keywords    map[string]string `json:"keywords,omitempty"` // Keyword -> agent ID, matched as whole words
+	Rules       []RoutingRule     `json:"rules,omitempty"`    // Checked before keywords, by priority
+	Meta        bool              `json:"meta,omitempty"`
+	MentionMode string            `json:"mentionMode,omitempty"` // sticky, once or ask
+}
+
+// DebugConfig defines debugging options
+type DebugConfig struct {
+	Record       bool   `json:"record,omitempty"`       // Record raw ACP traffic to .acprec files
+	RecordingDir string `json:"recordingDir,omitempty"` // Defaults to ~/.acpone/recordings
+	NoEventLog   bool   `json:"noEventLog,omitempty"`   // Don't persist bus events
+	EventLogDir  string `json:"eventLogDir,omitempty"`  // Defaults to ~/.acpone/events
 }
 
 // Config is the main acpone configuration
 type Config struct {
+	Version          int               `json:"version,omitempty"` // File layout, see CurrentVersion
 	Agents           []AgentConfig     `json:"agents"`
 	DefaultAgent     string            `json:"defaultAgent"`
 	Routing          *RoutingConfig    `json:"routing,omitempty"`
-	Workspaces       []WorkspaceConfig `json:"workspaces,omitempty"`
-	DefaultWorkspace string            `json:"defaultWorkspace,omitempty"`
-}
-
-// rawConfig supports legacy field names
-type rawConfig struct {
-	Agents           []AgentConfig     `json:"agents,omitempty"`
-	Backends         []AgentConfig     `json:"backends,omitempty"`
-	DefaultAgent     string            `json:"defaultAgent,omitempty"`
-	DefaultBackend   string            `json:"defaultBackend,omitempty"`
-	Routing          *RoutingConfig    `json:"routing,omitempty"`
-	Workspaces       []WorkspaceConfig `json:"workspaces,omitempty"`
+	Workspaces       []WorkspaceConfig `json:"workspaces,omitempty"` // Legacy: migrated to ~/.acpone/workspaces.json
 	DefaultWorkspace string            `json:"defaultWorkspace,omitempty"`
-}
-
-func (r *rawConfig) normalize() *Config {
-	agents := r.Agents
-	if len(agents) == 0 {
-		agents = r.Backends
-	}
-	defaultAgent := r.DefaultAgent
-	if defaultAgent == "" {
-		defaultAgent = r.DefaultBackend
-	}
-	return &Config{
-		Agents:           agents,
-		DefaultAgent:     defaultAgent,
-		Routing:          r.Routing,
-		Workspaces:       r.Workspaces,
-		DefaultWorkspace: r.DefaultWorkspace,
-	}
+	Debug            *DebugConfig      `json:"debug,omitempty"`
+	Storage          *StorageConfig    `json:"storage,omitempty"`
+	Sync             *SyncConfig       `json:"sync,omitempty"`
+	Transcribe       *TranscribeConfig `json:"transcribe,omitempty"`
+	Pipelines        []PipelineConfig  `json:"pipelines,omitempty"`
+	Teams            []TeamConfig      `json:"teams,omitempty"`
+	Offline          bool              `json:"offline,omitempty"`         // Never reach the npm registry, use cached packages only
+	Scan             *ScanConfig       `json:"scan,omitempty"`            // Secret scanning of outgoing prompts
+	Slack            *SlackConfig      `json:"slack,omitempty"`           // Turn notifications posted to Slack
+	Upload           *UploadConfig     `json:"upload,omitempty"`          // Upload policy: extensions, quotas, virus scan
+	Retry            *RetryConfig      `json:"retry,omitempty"`           // Retries of rate-limited turns
+	Limits           *LimitsConfig     `json:"limits,omitempty"`          // Per-turn output, tool call and time limits
+	Usage            *UsageConfig      `json:"usage,omitempty"`           // Workspace disk usage quotas
+	Trash            *TrashConfig      `json:"trash,omitempty"`           // Retention of deleted sessions
+	Log              *LogConfig        `json:"log,omitempty"`             // Log level, format and rotated files
+	Server           *ServerConfig     `json:"server,omitempty"`          // Port, bind address, data dir and auth token
+	TrustedProjects  []string          `json:"trustedProjects,omitempty"` // Workspace dirs whose .acpone.json may run commands or bypass permissions
 }
 
 // LoadedConfigPath stores the path of loaded config file
@@ -108,12 +155,40 @@ func loadFromFile(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	var raw rawConfig
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("failed to parse config:\n%w", CheckError(path, checkSchema(data)))
+	}
+
+	// Older layouts are upgraded first, in memory when the file can't be
+	// rewritten
+	LastMigration = nil
+	doc, m, err := migrateData(data)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		if err := writeMigration(path, data, doc, m); err != nil {
+			fmt.Printf("⚠️  Config migration not saved: %v\n", err)
+		}
+		printMigration(path, m)
+		LastMigration = m
+		data = migratedData(doc) // As written, so positions match the file
+	}
+
+	// Type mismatches fail with their position, unknown fields are ignored
+	problems := checkSchema(data)
+	if err := CheckError(path, problems); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	for _, p := range problems {
+		fmt.Printf("⚠️  %s:%s\n", path, p)
 	}
 
-	return raw.normalize(), nil
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return &cfg, nil
 }
 
 func defaultPaths() []string {
@@ -124,26 +199,28 @@ func defaultPaths() []string {
 	return []string{
 		"./acpone.config.json",
 		"./acpone.json",
-		filepath.Join(home, ".acpone", "acpone.config.json"),
+		userConfigPath(),
 		filepath.Join(home, ".config", "acpone", "config.json"),
 	}
 }
 
-// userConfigPath returns the user config path ~/.acpone/acpone.config.json
+// userConfigPath returns the user config path, ~/.acpone/acpone.config.json
+// unless the data directory is relocated
 func userConfigPath() string {
-	home := os.Getenv("HOME")
-	if home == "" {
-		home = os.Getenv("USERPROFILE")
-	}
-	return filepath.Join(home, ".acpone", "acpone.config.json")
+	return filepath.Join(sysutil.DataDir(), "acpone.config.json")
 }
 
-// EnsureConfigExists creates config from example if it doesn't exist
+// EnsureConfigExists creates config from example if it doesn't exist, at
+// $ACPONE_CONFIG when set so a mounted config volume starts out populated
 func EnsureConfigExists() error {
 	configPath := userConfigPath()
+	paths := defaultPaths()
+	if p := os.Getenv(EnvConfig); p != "" {
+		configPath, paths = p, []string{p}
+	}
 
 	// Check if any config file exists
-	for _, p := range defaultPaths() {
+	for _, p := range paths {
 		if _, err := os.Stat(p); err == nil {
 			return nil // Config exists, nothing to do
 		}
@@ -198,12 +275,114 @@ func (c *Config) Validate() error {
 		if ids[agent.ID] {
 			return fmt.Errorf("duplicate agent id: %s", agent.ID)
 		}
+		if t := agent.Timeouts; t != nil {
+			switch t.OnExpiry {
+			case "", TimeoutError, TimeoutRestart, TimeoutNotify:
+			default:
+				return fmt.Errorf("agent %s: invalid timeouts.onExpiry: %s", agent.ID, t.OnExpiry)
+			}
+		}
+		if agent.Restart != nil {
+			if err := agent.Restart.validate(agent.ID); err != nil {
+				return err
+			}
+		}
+		if agent.Install != nil {
+			if err := agent.Install.validate(agent.ID); err != nil {
+				return err
+			}
+		}
+		switch agent.ParamsIn {
+		case "", ParamsMeta, ParamsPrompt:
+		default:
+			return fmt.Errorf("agent %s: invalid paramsIn: %s", agent.ID, agent.ParamsIn)
+		}
 		ids[agent.ID] = true
 	}
 
+	if err := c.validateAliases(); err != nil {
+		return err
+	}
+
 	if !ids[c.DefaultAgent] {
 		return fmt.Errorf("default agent not found: %s", c.DefaultAgent)
 	}
+	if !c.FindAgent(c.DefaultAgent).IsEnabled() {
+		return fmt.Errorf("default agent is disabled: %s", c.DefaultAgent)
+	}
+
+	if c.Storage != nil {
+		if err := c.Storage.validate("storage", false); err != nil {
+			return err
+		}
+	}
+	if c.Sync != nil {
+		if c.Sync.Remote.Type == "" || c.Sync.Remote.Type == StorageLocal {
+			return errors.New("sync.remote.type must be s3, webdav or git")
+		}
+		if err := c.Sync.Remote.validate("sync.remote", true); err != nil {
+			return err
+		}
+	}
+	if c.Transcribe != nil {
+		if err := c.Transcribe.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Scan != nil {
+		if err := c.Scan.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Slack != nil {
+		if err := c.Slack.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Upload != nil {
+		if err := c.Upload.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Retry != nil {
+		if err := c.Retry.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Limits != nil {
+		if err := c.Limits.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Usage != nil {
+		if err := c.Usage.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Trash != nil {
+		if err := c.Trash.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Log != nil {
+		if err := c.Log.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Server != nil {
+		if err := c.Server.validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.validateRouting(); err != nil {
+		return err
+	}
+	if err := c.validatePipelines(); err != nil {
+		return err
+	}
+	if err := c.validateTeams(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -217,13 +396,3 @@ func (c *Config) FindAgent(id string) *AgentConfig {
 	}
 	return nil
 }
-
-// FindWorkspace returns workspace config by ID
-func (c *Config) FindWorkspace(id string) *WorkspaceConfig {
-	for i := range c.Workspaces {
-		if c.Workspaces[i].ID == id {
-			return &c.Workspaces[i]
-		}
-	}
-	return nil
-}
@@ -0,0 +1,54 @@
+package config
+
+import "slices"
+
+// Hook trigger and failure modes
+const (
+	HookOnEdit = "edit" // After turns that modified files (default)
+	HookOnTurn = "turn" // After every completed turn
+
+	HookAnnotate = "annotate" // Record the failure in the conversation (default)
+	HookPrompt   = "prompt"   // Also send the output back to the agent
+)
+
+// HookConfig is a command run in the workspace root after a turn, e.g.
+// `go test ./...`. Its output is recorded like a tool call.
+type HookConfig struct {
+	Name           string   `json:"name,omitempty"`
+	Command        string   `json:"command"`                  // Run by the system shell
+	On             string   `json:"on,omitempty"`             // "edit" or "turn"
+	Agents         []string `json:"agents,omitempty"`         // Limit to turns of these agents
+	OnFailure      string   `json:"onFailure,omitempty"`      // "annotate" or "prompt"
+	MaxRetries     int      `json:"maxRetries,omitempty"`     // Follow-up prompts per message for "prompt" (default 1)
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"` // Default 300
+}
+
+// Matches reports whether the hook runs after a turn of agentID
+func (h *HookConfig) Matches(agentID string, changed bool) bool {
+	if h.Command == "" {
+		return false
+	}
+	if len(h.Agents) > 0 && !slices.Contains(h.Agents, agentID) {
+		return false
+	}
+	return changed || h.On == HookOnTurn
+}
+
+// Retries returns how many follow-up prompts a failing hook may trigger
+func (h *HookConfig) Retries() int {
+	if h.OnFailure != HookPrompt {
+		return 0
+	}
+	if h.MaxRetries <= 0 {
+		return 1
+	}
+	return h.MaxRetries
+}
+
+// Label returns the hook's display name
+func (h *HookConfig) Label() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Command
+}
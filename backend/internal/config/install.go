@@ -0,0 +1,34 @@
+package config
+
+import "fmt"
+
+// Install source types for agents not run through npx
+const (
+	InstallUvx    = "uvx"    // Python package installed with `uv tool install`
+	InstallPipx   = "pipx"   // Python package installed with pipx
+	InstallCargo  = "cargo"  // Rust crate installed with `cargo install`
+	InstallBinary = "binary" // Executable downloaded to ~/.acpone/bin
+	InstallDocker = "docker" // Image pulled with `docker pull`
+)
+
+// InstallConfig tells setup how to check, install and update an agent
+// that isn't an npm package, e.g. {"type": "uvx", "package": "my-acp"}
+type InstallConfig struct {
+	Type    string `json:"type"`
+	Package string `json:"package,omitempty"` // Package, crate or image; defaults to the agent command
+	Version string `json:"version,omitempty"` // Pinned version or image tag
+	URL     string `json:"url,omitempty"`     // binary: download URL, {os} and {arch} are replaced
+}
+
+func (i *InstallConfig) validate(agentID string) error {
+	switch i.Type {
+	case InstallUvx, InstallPipx, InstallCargo, InstallDocker:
+	case InstallBinary:
+		if i.URL == "" {
+			return fmt.Errorf("agent %s: install.url is required for binary installs", agentID)
+		}
+	default:
+		return fmt.Errorf("agent %s: invalid install.type: %q (uvx, pipx, cargo, binary or docker)", agentID, i.Type)
+	}
+	return nil
+}
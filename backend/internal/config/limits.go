@@ -0,0 +1,53 @@
+package config
+
+import "fmt"
+
+// LimitsConfig bounds each turn so an agent stuck in a loop is stopped
+// rather than running unattended. Zero leaves a limit off; conversations
+// may override each limit.
+type LimitsConfig struct {
+	MaxChars      int    `json:"maxChars,omitempty"`      // Assistant text per turn, in characters
+	MaxToolCalls  int    `json:"maxToolCalls,omitempty"`  // Tool calls per turn
+	MaxDurationMs int    `json:"maxDurationMs,omitempty"` // Wall-clock time per turn
+	MaxRepeats    int    `json:"maxRepeats,omitempty"`    // Identical tool calls in a row (default 5, -1 disables)
+	OnRepeat      string `json:"onRepeat,omitempty"`      // What repeated tool calls do: warn (default) or cancel
+}
+
+// Repeated tool call handling
+const (
+	DefaultRepeats = 5
+	RepeatWarn     = "warn"   // Send a warning event, the turn goes on
+	RepeatCancel   = "cancel" // Stop the turn like an exceeded limit
+)
+
+// Repeats returns after how many identical tool calls in a row the turn is
+// warned or canceled, 0 when never
+func (l *LimitsConfig) Repeats() int {
+	if l == nil || l.MaxRepeats == 0 {
+		return DefaultRepeats
+	}
+	return max(l.MaxRepeats, 0)
+}
+
+// RepeatAction returns what repeated tool calls do
+func (l *LimitsConfig) RepeatAction() string {
+	if l == nil || l.OnRepeat == "" {
+		return RepeatWarn
+	}
+	return l.OnRepeat
+}
+
+func (l *LimitsConfig) validate() error {
+	if l.MaxChars < 0 || l.MaxToolCalls < 0 || l.MaxDurationMs < 0 {
+		return fmt.Errorf("limits: values must not be negative")
+	}
+	if l.MaxRepeats < -1 {
+		return fmt.Errorf("limits: maxRepeats must be -1 or more")
+	}
+	switch l.OnRepeat {
+	case "", RepeatWarn, RepeatCancel:
+	default:
+		return fmt.Errorf("limits: invalid onRepeat: %s", l.OnRepeat)
+	}
+	return nil
+}
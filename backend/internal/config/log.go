@@ -0,0 +1,77 @@
+package config
+
+import "fmt"
+
+// LogConfig controls the server log, written to stderr and to rotated files
+// in ~/.acpone/logs
+type LogConfig struct {
+	Level     string `json:"level,omitempty"`     // debug, info (default), warn or error; debug adds ACP traffic
+	Format    string `json:"format,omitempty"`    // text (default) or json
+	Dir       string `json:"dir,omitempty"`       // Defaults to ~/.acpone/logs
+	NoFile    bool   `json:"noFile,omitempty"`    // Only log to stderr
+	MaxSizeMB int    `json:"maxSizeMB,omitempty"` // Rotate the current file beyond this size (default 10)
+	MaxFiles  int    `json:"maxFiles,omitempty"`  // Current file plus rotated ones (default 5)
+}
+
+// Log levels and formats
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// Log file defaults
+const (
+	DefaultLogSizeMB = 10
+	DefaultLogFiles  = 5
+)
+
+// LevelName returns the configured level, info by default
+func (l *LogConfig) LevelName() string {
+	if l == nil || l.Level == "" {
+		return LogInfo
+	}
+	return l.Level
+}
+
+// JSON reports whether records are written as JSON lines
+func (l *LogConfig) JSON() bool {
+	return l != nil && l.Format == LogJSON
+}
+
+// MaxSize returns the size in bytes beyond which the log file is rotated
+func (l *LogConfig) MaxSize() int64 {
+	if l == nil || l.MaxSizeMB <= 0 {
+		return DefaultLogSizeMB << 20
+	}
+	return int64(l.MaxSizeMB) << 20
+}
+
+// Files returns how many log files are kept, the current one included
+func (l *LogConfig) Files() int {
+	if l == nil || l.MaxFiles <= 0 {
+		return DefaultLogFiles
+	}
+	return l.MaxFiles
+}
+
+func (l *LogConfig) validate() error {
+	switch l.Level {
+	case "", LogDebug, LogInfo, LogWarn, LogError:
+	default:
+		return fmt.Errorf("log: invalid level: %s", l.Level)
+	}
+	switch l.Format {
+	case "", LogText, LogJSON:
+	default:
+		return fmt.Errorf("log: invalid format: %s", l.Format)
+	}
+	if l.MaxSizeMB < 0 || l.MaxFiles < 0 {
+		return fmt.Errorf("log: maxSizeMB and maxFiles must not be negative")
+	}
+	return nil
+}
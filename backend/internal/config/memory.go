@@ -0,0 +1,36 @@
+package config
+
+import "time"
+
+// MemoryConfig keeps a workspace memory: once a conversation goes idle, an
+// agent extracts its durable facts and decisions into .acpone/memory.md,
+// which is included in new sessions of the workspace. Prompt may use
+// {{memory}} and {{conversation}} like pipeline stages.
+type MemoryConfig struct {
+	Agent       string `json:"agent,omitempty"`       // Default: the conversation's agent
+	Prompt      string `json:"prompt,omitempty"`      // Replaces or precedes the extraction prompt
+	IdleMinutes int    `json:"idleMinutes,omitempty"` // Idle time before extracting (default 10)
+	MaxChars    int    `json:"maxChars,omitempty"`    // Size the memory is kept under (default 8000)
+}
+
+// Memory defaults
+const (
+	DefaultMemoryIdle     = 10 * time.Minute
+	DefaultMemoryMaxChars = 8000
+)
+
+// Idle returns how long a conversation is idle before it is extracted
+func (m *MemoryConfig) Idle() time.Duration {
+	if m == nil || m.IdleMinutes <= 0 {
+		return DefaultMemoryIdle
+	}
+	return time.Duration(m.IdleMinutes) * time.Minute
+}
+
+// Limit returns the maximum size of the memory in characters
+func (m *MemoryConfig) Limit() int {
+	if m == nil || m.MaxChars <= 0 {
+		return DefaultMemoryMaxChars
+	}
+	return m.MaxChars
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CurrentVersion is the config file layout this build reads and writes.
+// Files without a version field are version 0.
+const CurrentVersion = 1
+
+// Migration reports how a config file was upgraded
+type Migration struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Changes []string `json:"changes"`
+	Backup  string   `json:"backup,omitempty"` // Copy of the original file
+}
+
+// migrations upgrade a parsed config file, migrations[v] from version v to
+// v+1, returning a description of each change
+var migrations = []func(doc map[string]any) []string{
+	migrateLegacyAgents,
+}
+
+// LastMigration is the upgrade applied by the last Load, nil when the file
+// was current
+var LastMigration *Migration
+
+// writeMigration keeps the original data as <path>.v<version>.bak and
+// rewrites the file with the upgraded document, recording the backup in m
+func writeMigration(path string, original []byte, doc map[string]any, m *Migration) error {
+	backup := fmt.Sprintf("%s.v%d.bak", path, m.From)
+	if err := os.WriteFile(backup, original, 0644); err != nil {
+		return fmt.Errorf("failed to back up config: %w", err)
+	}
+	m.Backup = backup
+	if err := os.WriteFile(path, migratedData(doc), 0644); err != nil {
+		return fmt.Errorf("failed to write migrated config: %w", err)
+	}
+	return nil
+}
+
+// migratedData formats an upgraded document like Save
+func migratedData(doc map[string]any) []byte {
+	out, _ := json.MarshalIndent(doc, "", "  ")
+	return append(out, '\n')
+}
+
+// printMigration reports an upgrade at startup
+func printMigration(path string, m *Migration) {
+	fmt.Printf("🔄 Migrated config %s from version %d to %d\n", path, m.From, m.To)
+	for _, c := range m.Changes {
+		fmt.Printf("   - %s\n", c)
+	}
+	if m.Backup != "" {
+		fmt.Printf("   Original kept as %s\n", m.Backup)
+	}
+}
+
+// migrateData applies the migrations the config data needs, returning the
+// upgraded document and a nil Migration when nothing changed. Data must be
+// valid JSON.
+func migrateData(data []byte) (map[string]any, *Migration, error) {
+	var doc map[string]any
+	if json.Unmarshal(data, &doc) != nil {
+		return nil, nil, nil // Not an object, which checkSchema reports
+	}
+	version := 0
+	if v, ok := doc["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > CurrentVersion {
+		return nil, nil, fmt.Errorf("config version %d is newer than this build supports (%d), please upgrade acpone", version, CurrentVersion)
+	}
+
+	m := &Migration{From: version, To: CurrentVersion}
+	for v := version; v < CurrentVersion; v++ {
+		m.Changes = append(m.Changes, migrations[v](doc)...)
+	}
+	if len(m.Changes) == 0 {
+		return doc, nil, nil
+	}
+	doc["version"] = CurrentVersion
+	return doc, m, nil
+}
+
+// migrateLegacyAgents upgrades version 0 files: "backends" and
+// "defaultBackend" become "agents" and "defaultAgent", agents keyed by ID
+// become a list, and args given as one string are split into a list
+func migrateLegacyAgents(doc map[string]any) []string {
+	var changes []string
+	if backends, ok := doc["backends"]; ok {
+		if agents, ok := doc["agents"]; !ok || isEmpty(agents) {
+			doc["agents"] = backends
+			changes = append(changes, `renamed "backends" to "agents"`)
+		} else {
+			changes = append(changes, `removed "backends", which "agents" replaces`)
+		}
+		delete(doc, "backends")
+	}
+	if backend, ok := doc["defaultBackend"]; ok {
+		if agent, _ := doc["defaultAgent"].(string); agent == "" {
+			doc["defaultAgent"] = backend
+			changes = append(changes, `renamed "defaultBackend" to "defaultAgent"`)
+		} else {
+			changes = append(changes, `removed "defaultBackend", which "defaultAgent" replaces`)
+		}
+		delete(doc, "defaultBackend")
+	}
+
+	if byID, ok := doc["agents"].(map[string]any); ok {
+		ids := make([]string, 0, len(byID))
+		for id := range byID {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		list := make([]any, 0, len(ids))
+		for _, id := range ids {
+			if agent, ok := byID[id].(map[string]any); ok {
+				if _, ok := agent["id"]; !ok {
+					agent["id"] = id
+				}
+				list = append(list, agent)
+			}
+		}
+		doc["agents"] = list
+		changes = append(changes, `converted "agents" from an object keyed by ID to a list`)
+	}
+
+	agents, _ := doc["agents"].([]any)
+	for _, a := range agents {
+		agent, ok := a.(map[string]any)
+		if !ok {
+			continue
+		}
+		if args, ok := agent["args"].(string); ok {
+			agent["args"] = strings.Fields(args)
+			changes = append(changes, fmt.Sprintf(`agent %v: split "args" string into a list`, agent["id"]))
+		}
+	}
+	return changes
+}
+
+// isEmpty reports whether a JSON value is null, an empty list or object
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
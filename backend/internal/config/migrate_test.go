@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateData(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string   // Upgraded document, empty when unchanged
+		changes []string // Substrings of the reported changes, in order
+	}{
+		{"current", `{"version": 1, "agents": [], "backends": {"a": {}}}`, "", nil},
+		{"version 0 without legacy fields", `{"agents": [{"id": "a"}]}`, "", nil},
+		{"renamed",
+			`{"backends": [{"id": "a", "command": "a"}], "defaultBackend": "a"}`,
+			`{"version": 1, "agents": [{"id": "a", "command": "a"}], "defaultAgent": "a"}`,
+			[]string{`renamed "backends"`, `renamed "defaultBackend"`}},
+		{"replaced",
+			`{"backends": [{"id": "old"}], "agents": [{"id": "a"}], "defaultBackend": "old", "defaultAgent": "a"}`,
+			`{"version": 1, "agents": [{"id": "a"}], "defaultAgent": "a"}`,
+			[]string{`removed "backends"`, `removed "defaultBackend"`}},
+		{"empty agents replaced",
+			`{"backends": [{"id": "a"}], "agents": []}`,
+			`{"version": 1, "agents": [{"id": "a"}]}`,
+			[]string{`renamed "backends"`}},
+		{"keyed by ID",
+			`{"agents": {"codex": {"command": "codex"}, "claude": {"id": "cc", "command": "claude"}}}`,
+			`{"version": 1, "agents": [{"id": "cc", "command": "claude"}, {"id": "codex", "command": "codex"}]}`,
+			[]string{"object keyed by ID"}},
+		{"args string",
+			`{"agents": [{"id": "a", "args": "--acp  --verbose"}]}`,
+			`{"version": 1, "agents": [{"id": "a", "args": ["--acp", "--verbose"]}]}`,
+			[]string{`agent a: split "args"`}},
+		{"all at once",
+			`{"backends": {"a": {"args": "x y"}}, "defaultBackend": "a"}`,
+			`{"version": 1, "agents": [{"id": "a", "args": ["x", "y"]}], "defaultAgent": "a"}`,
+			[]string{`renamed "backends"`, `renamed "defaultBackend"`, "keyed by ID", "split"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, m, err := migrateData([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if m != nil {
+					t.Fatalf("migrated a current file: %+v", m)
+				}
+				return
+			}
+			if m == nil || m.From != 0 || m.To != CurrentVersion || len(m.Changes) != len(tt.changes) {
+				t.Fatalf("migration %+v, want %d changes from 0 to %d", m, len(tt.changes), CurrentVersion)
+			}
+			for i, c := range tt.changes {
+				if !strings.Contains(m.Changes[i], c) {
+					t.Errorf("change %d is %q, want it to mention %q", i, m.Changes[i], c)
+				}
+			}
+			var want map[string]any
+			json.Unmarshal([]byte(tt.want), &want)
+			// Compare as decoded JSON, as the migrated document holds Go types
+			var got map[string]any
+			json.Unmarshal(migratedData(doc), &got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("migrated to %s, want %s", migratedData(doc), tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateDataRejects(t *testing.T) {
+	if _, _, err := migrateData([]byte(`{"version": 99}`)); err == nil || !strings.Contains(err.Error(), "newer than this build") {
+		t.Errorf("newer version: %v, want an error", err)
+	}
+	if doc, m, err := migrateData([]byte(`[1, 2]`)); doc != nil || m != nil || err != nil {
+		t.Errorf("non-object: %v, %v, %v; want it left to the schema check", doc, m, err)
+	}
+}
+
+func TestLoadMigratesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acpone.config.json")
+	legacy := []byte(`{"backends": {"claude": {"name": "Claude", "command": "claude", "args": "--acp"}}, "defaultBackend": "claude"}`)
+	if err := os.WriteFile(path, legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Agents) != 1 || cfg.Agents[0].ID != "claude" || !reflect.DeepEqual(cfg.Agents[0].Args, []string{"--acp"}) || cfg.DefaultAgent != "claude" {
+		t.Fatalf("loaded %+v, default %q", cfg.Agents, cfg.DefaultAgent)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("loaded version %d, want %d", cfg.Version, CurrentVersion)
+	}
+	m := LastMigration
+	if m == nil || m.Backup != path+".v0.bak" {
+		t.Fatalf("LastMigration = %+v, want the backup recorded", m)
+	}
+	if backup, _ := os.ReadFile(m.Backup); string(backup) != string(legacy) {
+		t.Errorf("backup holds %q, want the original file", backup)
+	}
+	rewritten, _ := os.ReadFile(path)
+	if !strings.Contains(string(rewritten), `"version": 1`) || strings.Contains(string(rewritten), "backends") {
+		t.Errorf("file not rewritten in the current layout:\n%s", rewritten)
+	}
+
+	// The rewritten file loads without another migration
+	if _, err := Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if LastMigration != nil {
+		t.Errorf("migrated again: %+v", LastMigration)
+	}
+}
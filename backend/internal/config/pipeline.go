@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var pipelineIDRegex = regexp.MustCompile(`^[\w-]+$`)
+
+// PipelineConfig chains agents: the first stage answers the user's prompt,
+// each later stage receives the previous stage's output and the workspace
+// diff. Start one with "@<id>" in a message.
+type PipelineConfig struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name,omitempty"`
+	Stages []PipelineStage `json:"stages"`
+}
+
+// PipelineStage is one agent run within a pipeline
+type PipelineStage struct {
+	Agent string `json:"agent"`
+	Name  string `json:"name,omitempty"` // e.g. "implement", "review"
+	// Prompt holds instructions for stages after the first. It may use
+	// {{input}} (user prompt), {{output}} (previous stage reply), {{diff}}
+	// (workspace git diff) and {{agent}} (previous agent); without any
+	// placeholder it is prepended to a default hand-off message.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// FindPipeline returns pipeline config by ID
+func (c *Config) FindPipeline(id string) *PipelineConfig {
+	for i := range c.Pipelines {
+		if c.Pipelines[i].ID == id {
+			return &c.Pipelines[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) validatePipelines() error {
+	ids := make(map[string]bool)
+	for _, p := range c.Pipelines {
+		if !pipelineIDRegex.MatchString(p.ID) {
+			return fmt.Errorf("invalid pipeline id: %q", p.ID)
+		}
+		if ids[p.ID] || c.FindAgentByName(p.ID) != nil {
+			return fmt.Errorf("duplicate pipeline id: %s", p.ID)
+		}
+		ids[p.ID] = true
+		if len(p.Stages) == 0 {
+			return fmt.Errorf("pipeline %s: at least one stage is required", p.ID)
+		}
+		for i, stage := range p.Stages {
+			if c.FindAgent(stage.Agent) == nil {
+				return fmt.Errorf("pipeline %s: stage %d: agent not found: %s", p.ID, i+1, stage.Agent)
+			}
+		}
+	}
+	return nil
+}
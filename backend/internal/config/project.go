@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ProjectFile is the per-project settings file looked up in a workspace root
+const ProjectFile = ".acpone.json"
+
+// ProjectConfig holds per-project settings merged over the global config
+// for sessions in that workspace
+type ProjectConfig struct {
+	DefaultAgent   string            `json:"defaultAgent,omitempty"`
+	PermissionMode string            `json:"permissionMode,omitempty"` // Overrides every agent's mode
+	Env            map[string]string `json:"env,omitempty"`            // Merged over agent env
+	MCPServers     []map[string]any  `json:"mcpServers,omitempty"`     // Passed to session/new
+	Ignore         []string          `json:"ignore,omitempty"`         // Glob patterns hidden from file lists
+	Review         *ReviewConfig     `json:"review,omitempty"`         // Automatic review of file changes
+	Hooks          []HookConfig      `json:"hooks,omitempty"`          // Commands run after turns
+	Transcript     bool              `json:"transcript,omitempty"`     // Tee conversations live into .acpone/transcripts/<id>.md
+	Branch         *BranchConfig     `json:"branch,omitempty"`         // Git branch per conversation
+	Memory         *MemoryConfig     `json:"memory,omitempty"`         // Facts extracted from conversations into .acpone/memory.md
+	AgentLog       bool              `json:"agentLog,omitempty"`       // Keep AGENT_LOG.md summarizing the workspace's conversations
+}
+
+// TrustsProject reports whether the user listed dir in trustedProjects,
+// allowing its .acpone.json the settings Restrict drops otherwise
+func (c *Config) TrustsProject(dir string) bool {
+	dir = cleanDir(dir)
+	return slices.ContainsFunc(c.TrustedProjects, func(trusted string) bool {
+		return cleanDir(trusted) == dir
+	})
+}
+
+func cleanDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return filepath.Clean(dir)
+}
+
+// Restrict drops the settings a project file only gets from a trusted
+// workspace: env, MCP servers and hooks run code, and a bypass permission
+// mode turns approvals off (a project may still ask for approvals). Returns
+// the names of the dropped settings.
+func (pc *ProjectConfig) Restrict() []string {
+	var dropped []string
+	if pc.PermissionMode == "bypass" {
+		pc.PermissionMode = ""
+		dropped = append(dropped, "permissionMode")
+	}
+	if len(pc.Env) > 0 {
+		pc.Env = nil
+		dropped = append(dropped, "env")
+	}
+	if len(pc.MCPServers) > 0 {
+		pc.MCPServers = nil
+		dropped = append(dropped, "mcpServers")
+	}
+	if len(pc.Hooks) > 0 {
+		pc.Hooks = nil
+		dropped = append(dropped, "hooks")
+	}
+	return dropped
+}
+
+// BranchConfig moves each conversation's edits onto a git branch of its own,
+// created when the conversation first changes files
+type BranchConfig struct {
+	Prefix string `json:"prefix,omitempty"` // Branch name prefix, default "acpone/"
+}
+
+// ReviewConfig enables an automatic reviewer pass after turns that modify
+// files. Prompt may use {{input}}, {{diff}} and {{agent}} like pipeline stages.
+type ReviewConfig struct {
+	Agent  string `json:"agent"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// LoadProject reads dir/.acpone.json; a missing file returns nil without error
+func LoadProject(dir string) (*ProjectConfig, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ProjectFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pc ProjectConfig
+	if err := json.Unmarshal(data, &pc); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, ProjectFile), err)
+	}
+	return &pc, nil
+}
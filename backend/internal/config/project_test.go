@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestProjectRestrict(t *testing.T) {
+	pc := &ProjectConfig{
+		DefaultAgent:   "codex",
+		PermissionMode: "bypass",
+		Env:            map[string]string{"NODE_OPTIONS": "--require ./x.js"},
+		MCPServers:     []map[string]any{{"name": "fs", "command": "mcp-fs"}},
+		Hooks:          []HookConfig{{Command: "make"}},
+		Ignore:         []string{"*.log"},
+	}
+	dropped := pc.Restrict()
+	if want := []string{"permissionMode", "env", "mcpServers", "hooks"}; !slices.Equal(dropped, want) {
+		t.Errorf("dropped %v, want %v", dropped, want)
+	}
+	if pc.PermissionMode != "" || pc.Env != nil || pc.MCPServers != nil || pc.Hooks != nil {
+		t.Errorf("restricted settings kept: %+v", pc)
+	}
+	if pc.DefaultAgent != "codex" || len(pc.Ignore) != 1 {
+		t.Errorf("harmless settings dropped: %+v", pc)
+	}
+
+	// Asking for approvals is always allowed
+	strict := &ProjectConfig{PermissionMode: "default"}
+	if dropped := strict.Restrict(); dropped != nil || strict.PermissionMode != "default" {
+		t.Errorf("default permission mode dropped: %v", dropped)
+	}
+}
+
+func TestTrustsProject(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{TrustedProjects: []string{dir + string(filepath.Separator)}}
+	if !cfg.TrustsProject(dir) {
+		t.Error("listed dir not trusted")
+	}
+	if cfg.TrustsProject(filepath.Join(dir, "sub")) || cfg.TrustsProject(os.TempDir()) {
+		t.Error("unlisted dir trusted")
+	}
+	if (&Config{}).TrustsProject(dir) {
+		t.Error("trusted without trustedProjects")
+	}
+}
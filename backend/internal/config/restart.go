@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RestartConfig controls how an agent process that exits on its own (crash,
+// out of memory, npx failure) is restarted. Without it such an agent is
+// restarted up to DefaultRestarts times in a row.
+type RestartConfig struct {
+	MaxAttempts    int `json:"maxAttempts,omitempty"`    // Restarts in a row (default 5, -1 disables)
+	InitialDelayMs int `json:"initialDelayMs,omitempty"` // Wait before the first restart (default 1s), doubled for each next one
+	MaxDelayMs     int `json:"maxDelayMs,omitempty"`     // Longest wait (default 1m)
+}
+
+// Restart defaults
+const (
+	DefaultRestarts        = 5
+	DefaultRestartDelay    = time.Second
+	DefaultRestartMaxDelay = time.Minute
+)
+
+// Attempts returns how often in a row a crashed agent is restarted, 0 when never
+func (r *RestartConfig) Attempts() int {
+	if r == nil || r.MaxAttempts == 0 {
+		return DefaultRestarts
+	}
+	return max(r.MaxAttempts, 0)
+}
+
+// Delay returns the wait before restart attempt (1 for the first), doubling
+// from the initial delay up to the maximum
+func (r *RestartConfig) Delay(attempt int) time.Duration {
+	delay, limit := DefaultRestartDelay, DefaultRestartMaxDelay
+	if r != nil && r.InitialDelayMs > 0 {
+		delay = time.Duration(r.InitialDelayMs) * time.Millisecond
+	}
+	if r != nil && r.MaxDelayMs > 0 {
+		limit = time.Duration(r.MaxDelayMs) * time.Millisecond
+	}
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+func (r *RestartConfig) validate(agentID string) error {
+	if r.MaxAttempts < -1 {
+		return fmt.Errorf("agent %s: restart.maxAttempts must be -1 or more", agentID)
+	}
+	if r.InitialDelayMs < 0 || r.MaxDelayMs < 0 {
+		return fmt.Errorf("agent %s: restart delays must not be negative", agentID)
+	}
+	return nil
+}
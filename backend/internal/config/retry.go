@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryConfig controls automatic retries of turns failing because the
+// agent's model provider is rate limited or overloaded. Without it such a
+// turn is retried DefaultRetries times.
+type RetryConfig struct {
+	MaxRetries     int `json:"maxRetries,omitempty"`     // Retries per turn (default 3, -1 disables)
+	InitialDelayMs int `json:"initialDelayMs,omitempty"` // Wait before the first retry (default 5s), doubled for each next one
+	MaxDelayMs     int `json:"maxDelayMs,omitempty"`     // Longest wait (default 2m)
+}
+
+// Retry defaults
+const (
+	DefaultRetries       = 3
+	DefaultRetryDelay    = 5 * time.Second
+	DefaultRetryMaxDelay = 2 * time.Minute
+)
+
+// Retries returns how often a rate-limited turn is retried, 0 when never
+func (r *RetryConfig) Retries() int {
+	if r == nil || r.MaxRetries == 0 {
+		return DefaultRetries
+	}
+	return max(r.MaxRetries, 0)
+}
+
+// Delay returns the wait before retry attempt (1 for the first), doubling
+// from the initial delay up to the maximum
+func (r *RetryConfig) Delay(attempt int) time.Duration {
+	delay, limit := DefaultRetryDelay, DefaultRetryMaxDelay
+	if r != nil && r.InitialDelayMs > 0 {
+		delay = time.Duration(r.InitialDelayMs) * time.Millisecond
+	}
+	if r != nil && r.MaxDelayMs > 0 {
+		limit = time.Duration(r.MaxDelayMs) * time.Millisecond
+	}
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// MaxDelay returns the longest wait, which also caps delays providers ask for
+func (r *RetryConfig) MaxDelay() time.Duration {
+	if r != nil && r.MaxDelayMs > 0 {
+		return time.Duration(r.MaxDelayMs) * time.Millisecond
+	}
+	return DefaultRetryMaxDelay
+}
+
+func (r *RetryConfig) validate() error {
+	if r.MaxRetries < -1 {
+		return fmt.Errorf("retry: maxRetries must be -1 or more")
+	}
+	if r.InitialDelayMs < 0 || r.MaxDelayMs < 0 {
+		return fmt.Errorf("retry: delays must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Routing rule match modes
+const (
+	MatchWord      = "word"      // Keyword as a whole word (default)
+	MatchSubstring = "substring" // Keyword anywhere in the text
+	MatchRegex     = "regex"     // Keyword is a regular expression
+)
+
+// Mention modes: whether routing to another agent by @mention or rule
+// switches the conversation's active agent
+const (
+	MentionSticky = "sticky" // Switch for the rest of the conversation (default)
+	MentionOnce   = "once"   // Route this turn only, then return to the active agent
+	MentionAsk    = "ask"    // Route this turn only and ask whether to switch
+)
+
+// ValidMentionMode reports whether mode is a mention mode, empty meaning
+// the default
+func ValidMentionMode(mode string) bool {
+	switch mode {
+	case "", MentionSticky, MentionOnce, MentionAsk:
+		return true
+	}
+	return false
+}
+
+// MentionMode returns the configured mention mode, sticky by default
+func (c *Config) MentionMode() string {
+	if c.Routing == nil || c.Routing.MentionMode == "" {
+		return MentionSticky
+	}
+	return c.Routing.MentionMode
+}
+
+// RoutingRule routes prompts containing any of its keywords to an agent.
+// Rules are tried by descending priority, then in config order.
+type RoutingRule struct {
+	Name     string   `json:"name,omitempty"`
+	Agent    string   `json:"agent"`
+	Keywords []string `json:"keywords"`
+	Match    string   `json:"match,omitempty"` // word, substring or regex
+	Priority int      `json:"priority,omitempty"`
+}
+
+// Label returns the rule name, or its position when unnamed
+func (r *RoutingRule) Label(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rules[%d]", index)
+}
+
+func (c *Config) validateRouting() error {
+	if c.Routing == nil {
+		return nil
+	}
+	if !ValidMentionMode(c.Routing.MentionMode) {
+		return fmt.Errorf("invalid routing.mentionMode: %s", c.Routing.MentionMode)
+	}
+	for i, rule := range c.Routing.Rules {
+		label := rule.Label(i)
+		if c.FindAgent(rule.Agent) == nil {
+			return fmt.Errorf("routing rule %s: agent not found: %s", label, rule.Agent)
+		}
+		if len(rule.Keywords) == 0 {
+			return fmt.Errorf("routing rule %s: keywords are required", label)
+		}
+		switch rule.Match {
+		case "", MatchWord, MatchSubstring:
+		case MatchRegex:
+			for _, k := range rule.Keywords {
+				// Compiled as the router does
+				if _, err := regexp.Compile("(?i)" + k); err != nil {
+					return fmt.Errorf("routing rule %s: invalid regex %q: %v", label, k, err)
+				}
+			}
+		default:
+			return fmt.Errorf("routing rule %s: invalid match: %s", label, rule.Match)
+		}
+	}
+	return nil
+}
@@ -38,17 +38,69 @@ func (c *Config) Save(configPath string) error {
 	mergedAgents := c.mergeAgents(existing)
 
 	output := map[string]any{
+		"version":      CurrentVersion,
 		"agents":       mergedAgents,
 		"defaultAgent": c.DefaultAgent,
 	}
 	if c.Routing != nil {
 		output["routing"] = c.Routing
 	}
-	if len(c.Workspaces) > 0 {
-		output["workspaces"] = c.Workspaces
+	if c.Debug != nil {
+		output["debug"] = c.Debug
 	}
-	if c.DefaultWorkspace != "" {
-		output["defaultWorkspace"] = c.DefaultWorkspace
+	if c.Storage != nil {
+		output["storage"] = c.Storage
+	}
+	if c.Sync != nil {
+		output["sync"] = c.Sync
+	}
+	if c.Transcribe != nil {
+		output["transcribe"] = c.Transcribe
+	}
+	if len(c.Pipelines) > 0 {
+		output["pipelines"] = c.Pipelines
+	}
+	if len(c.Teams) > 0 {
+		output["teams"] = c.Teams
+	}
+	if c.Offline {
+		output["offline"] = true
+	}
+	if c.Scan != nil {
+		output["scan"] = c.Scan
+	}
+	if c.Slack != nil {
+		output["slack"] = c.Slack
+	}
+	if c.Upload != nil {
+		output["upload"] = c.Upload
+	}
+	if c.Retry != nil {
+		output["retry"] = c.Retry
+	}
+	if c.Limits != nil {
+		output["limits"] = c.Limits
+	}
+	if c.Usage != nil {
+		output["usage"] = c.Usage
+	}
+	if c.Trash != nil {
+		output["trash"] = c.Trash
+	}
+	if c.Log != nil {
+		output["log"] = c.Log
+	}
+	if len(c.TrustedProjects) > 0 {
+		output["trustedProjects"] = c.TrustedProjects
+	}
+	// Environment overrides stay out of the file, which keeps its own values
+	if server, ok := existing["server"]; ok {
+		output["server"] = server
+	}
+	if env := os.Getenv(EnvDefaultAgent); env != "" && env == c.DefaultAgent {
+		if agent, ok := existing["defaultAgent"]; ok {
+			output["defaultAgent"] = agent
+		}
 	}
 
 	data, err := json.MarshalIndent(output, "", "  ")
@@ -95,14 +147,14 @@ func (c *Config) mergeAgents(existing map[string]any) []map[string]any {
 		if agent.PermissionMode != "" {
 			merged["permissionMode"] = agent.PermissionMode
 		}
+		if agent.Enabled != nil {
+			merged["enabled"] = *agent.Enabled
+		} else {
+			delete(merged, "enabled")
+		}
 
 		result = append(result, merged)
 	}
 
 	return result
 }
-
-// AddWorkspace adds a workspace to config
-func (c *Config) AddWorkspace(ws WorkspaceConfig) {
-	c.Workspaces = append(c.Workspaces, ws)
-}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Outbound scan actions
+const (
+	ScanBlock = "block" // Refuse to send the prompt (default)
+	ScanMask  = "mask"  // Replace matches with [REDACTED:<name>] and send
+)
+
+// ScanConfig enables scanning prompts and attached file content for
+// secrets before they are sent to an agent
+type ScanConfig struct {
+	Action    string        `json:"action,omitempty"`    // block or mask
+	NoBuiltin bool          `json:"noBuiltin,omitempty"` // Only use the configured patterns
+	Patterns  []ScanPattern `json:"patterns,omitempty"`  // Added to the built-in patterns
+}
+
+// ScanPattern is a named regular expression matching a secret
+type ScanPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
+func (s *ScanConfig) validate() error {
+	switch s.Action {
+	case "", ScanBlock, ScanMask:
+	default:
+		return fmt.Errorf("scan: invalid action: %s", s.Action)
+	}
+	for _, p := range s.Patterns {
+		if p.Name == "" || p.Regex == "" {
+			return fmt.Errorf("scan: pattern must have name and regex")
+		}
+		if _, err := regexp.Compile(p.Regex); err != nil {
+			return fmt.Errorf("scan: pattern %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
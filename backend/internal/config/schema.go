@@ -0,0 +1,324 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Problem severities
+const (
+	SeverityError   = "error"   // The config can't be loaded or is invalid
+	SeverityWarning = "warning" // Ignored when loading, e.g. unknown fields
+)
+
+// Problem is a finding of Check, located in the config file where possible
+type Problem struct {
+	Severity string `json:"severity"`
+	Line     int    `json:"line,omitempty"` // 1-based, 0 when not tied to a position
+	Column   int    `json:"column,omitempty"`
+	Path     string `json:"path,omitempty"` // e.g. agents[0].args
+	Message  string `json:"message"`
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d: ", p.Line, p.Column)
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// HasErrors reports whether any problem is an error
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Check validates config file data against the Config schema, reporting
+// syntax errors, type mismatches and unknown fields with their line and
+// column, then the errors of Validate. Older layouts are upgraded first,
+// their changes reported as warnings, and positions then refer to the
+// upgraded file as Load would write it.
+func Check(data []byte) []Problem {
+	var problems []Problem
+	if json.Valid(data) {
+		doc, m, err := migrateData(data)
+		if err != nil {
+			return []Problem{{Severity: SeverityError, Message: err.Error()}}
+		}
+		if m != nil {
+			for _, change := range m.Changes {
+				problems = append(problems, Problem{Severity: SeverityWarning, Message: fmt.Sprintf("upgraded from version %d: %s", m.From, change)})
+			}
+			data = migratedData(doc)
+		}
+	}
+	problems = append(problems, checkSchema(data)...)
+	if HasErrors(problems) {
+		return problems
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return append(problems, Problem{Severity: SeverityError, Message: err.Error()})
+	}
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, Problem{Severity: SeverityError, Message: err.Error()})
+	}
+	return problems
+}
+
+// checkSchema reports the syntax errors, type mismatches and unknown fields
+// of config file data
+func checkSchema(data []byte) []Problem {
+	c := &schemaChecker{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	c.dec.UseNumber()
+	if err := c.value(reflect.TypeOf(Config{}), ""); err != nil {
+		return append(c.problems, c.syntaxProblem(err))
+	}
+	if _, err := c.dec.Token(); err != io.EOF {
+		return append(c.problems, c.problemAt(c.offset(), SeverityError, "", "unexpected data after the config object"))
+	}
+	return c.problems
+}
+
+// CheckError returns the errors among Check's problems of the file at path
+// as one error, nil when there are none
+func CheckError(path string, problems []Problem) error {
+	var msgs []string
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			msgs = append(msgs, path+":"+p.String())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// schemaChecker walks the JSON tokens of a config along its Go type
+type schemaChecker struct {
+	data     []byte
+	dec      *json.Decoder
+	problems []Problem
+}
+
+// offset returns the position of the next token, past the separators the
+// decoder hasn't consumed yet
+func (c *schemaChecker) offset() int {
+	off := int(c.dec.InputOffset())
+	for off < len(c.data) && strings.IndexByte(" \t\r\n,:", c.data[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+// problemAt locates a problem at a byte offset
+func (c *schemaChecker) problemAt(off int, severity, path, msg string) Problem {
+	line, col := position(c.data, off)
+	return Problem{Severity: severity, Line: line, Column: col, Path: path, Message: msg}
+}
+
+// syntaxProblem locates a decoding error
+func (c *schemaChecker) syntaxProblem(err error) Problem {
+	off := c.offset()
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		off = int(syntax.Offset)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return c.problemAt(len(c.data), SeverityError, "", "unexpected end of file")
+	}
+	return c.problemAt(off, SeverityError, "", err.Error())
+}
+
+// value checks the next JSON value against t, recording mismatches and
+// returning decoding errors
+func (c *schemaChecker) value(t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	off := c.offset()
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil // null is the zero value of any field
+	}
+
+	mismatch := func(got string) error {
+		c.problems = append(c.problems, c.problemAt(off, SeverityError, path, fmt.Sprintf("expected %s, got %s", typeName(t), got)))
+		return c.skip(tok)
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch {
+		case tok == '{' && t.Kind() == reflect.Struct:
+			return c.object(path, func(key string) (reflect.Type, bool) { return structField(t, key) })
+		case tok == '{' && t.Kind() == reflect.Map:
+			return c.object(path, func(string) (reflect.Type, bool) { return t.Elem(), true })
+		case tok == '{' && t.Kind() == reflect.Interface:
+			return c.object(path, func(string) (reflect.Type, bool) { return t, true })
+		case tok == '[' && (t.Kind() == reflect.Slice || t.Kind() == reflect.Interface):
+			elem := t
+			if t.Kind() == reflect.Slice {
+				elem = t.Elem()
+			}
+			for i := 0; c.dec.More(); i++ {
+				if err := c.value(elem, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err := c.dec.Token() // ]
+			return err
+		case tok == '{':
+			return mismatch("object")
+		default:
+			return mismatch("array")
+		}
+	case string:
+		if t.Kind() != reflect.String && t.Kind() != reflect.Interface {
+			return mismatch("string")
+		}
+	case bool:
+		if t.Kind() != reflect.Bool && t.Kind() != reflect.Interface {
+			return mismatch("boolean")
+		}
+	case json.Number:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if _, err := tok.Int64(); err != nil {
+				return mismatch("number " + tok.String())
+			}
+		case reflect.Float32, reflect.Float64, reflect.Interface:
+		default:
+			return mismatch("number")
+		}
+	}
+	return nil
+}
+
+// object checks the members of an object whose opening brace was read,
+// fieldType resolving the type of each key
+func (c *schemaChecker) object(path string, fieldType func(key string) (reflect.Type, bool)) error {
+	for c.dec.More() {
+		off := c.offset()
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		ft, ok := fieldType(key)
+		if !ok {
+			c.problems = append(c.problems, c.problemAt(off, SeverityWarning, keyPath, "unknown field, ignored"))
+			if err := c.skipValue(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.value(ft, keyPath); err != nil {
+			return err
+		}
+	}
+	_, err := c.dec.Token() // }
+	return err
+}
+
+// skip skips the rest of a value whose first token was read
+func (c *schemaChecker) skip(tok json.Token) error {
+	if d, ok := tok.(json.Delim); !ok || (d != '{' && d != '[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+// skipValue skips the next value
+func (c *schemaChecker) skipValue() error {
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+	return c.skip(tok)
+}
+
+// structField returns the type of the field a JSON key decodes into,
+// matching names case-insensitively like encoding/json
+func structField(t reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.EqualFold(name, key) {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// typeName describes a Go type in JSON terms
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice:
+		return "array of " + typeName(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	}
+	return "integer"
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, off int) (int, int) {
+	off = min(max(off, 0), len(data))
+	line := 1 + bytes.Count(data[:off], []byte("\n"))
+	col := off - bytes.LastIndexByte(data[:off], '\n')
+	return line, col
+}
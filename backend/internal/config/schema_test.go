@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name, data string
+		want       []string // Problems as severity + " " + String()
+	}{
+		{"valid", `{"version": 1, "agents": [{"id": "a", "name": "A", "command": "a"}], "defaultAgent": "a"}`, nil},
+		{"type mismatch", "{\n  \"agents\": [{\"id\": \"a\", \"name\": \"A\", \"command\": \"a\", \"args\": 3}],\n  \"defaultAgent\": \"a\"\n}",
+			[]string{"error 2:", "agents[0].args"}},
+		{"unknown field", `{"version": 1, "agents": [{"id": "a", "name": "A", "command": "a"}], "defaultAgent": "a", "colour": 1}`,
+			[]string{"warning 1:", "colour"}},
+		{"syntax error", "{\n  \"agents\": [\n}", []string{"error 3:"}},
+		{"invalid", `{"version": 1, "agents": [{"id": "a", "name": "A", "command": "a"}], "defaultAgent": "b"}`,
+			[]string{"error "}},
+	}
+	for _, tt := range tests {
+		problems := Check([]byte(tt.data))
+		var got []string
+		for _, p := range problems {
+			if p.Severity == SeverityWarning && strings.HasPrefix(p.Message, "upgraded from") {
+				continue
+			}
+			got = append(got, p.Severity+" "+p.String())
+		}
+		if len(tt.want) == 0 {
+			if len(got) > 0 {
+				t.Errorf("%s: unexpected problems %q", tt.name, got)
+			}
+			continue
+		}
+		if len(got) == 0 {
+			t.Errorf("%s: no problems, want %q", tt.name, tt.want)
+			continue
+		}
+		for _, part := range tt.want {
+			if !strings.Contains(got[0], part) {
+				t.Errorf("%s: problem %q lacks %q", tt.name, got[0], part)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// ServerConfig holds settings of the acpone server itself. Each may also be
+// set through its ACPONE_* environment variable, which takes precedence, so
+// containers can be configured without a config file.
+type ServerConfig struct {
+	Port      string `json:"port,omitempty"`      // Default 3000
+	Host      string `json:"host,omitempty"`      // Bind address, default all interfaces
+	DataDir   string `json:"dataDir,omitempty"`   // Sessions, workspaces and other state (default ~/.acpone)
+	AuthToken string `json:"authToken,omitempty"` // Required from clients when set
+
+	// APIKeys are accepted like the auth token, and also require one when
+	// set, e.g. a key per script or integration that can be revoked alone
+	APIKeys []string `json:"apiKeys,omitempty"`
+
+	// AdminPort moves the admin endpoints (setup installs, agent changes,
+	// backups) to a second listener on AdminHost, default 127.0.0.1, so
+	// the chat UI can be exposed without them
+	AdminPort string `json:"adminPort,omitempty"`
+	AdminHost string `json:"adminHost,omitempty"`
+}
+
+const (
+	// DefaultPort is the port the server listens on without configuration
+	DefaultPort = "3000"
+	// DefaultAdminHost keeps the admin listener local to the machine
+	DefaultAdminHost = "127.0.0.1"
+)
+
+// Environment variables overriding the configuration
+const (
+	EnvConfig       = "ACPONE_CONFIG" // Config file path
+	EnvPort         = "ACPONE_PORT"
+	EnvHost         = "ACPONE_HOST"
+	EnvDataDir      = sysutil.DataDirEnv
+	EnvAuthToken    = "ACPONE_AUTH_TOKEN"
+	EnvAdminPort    = "ACPONE_ADMIN_PORT"
+	EnvAdminHost    = "ACPONE_ADMIN_HOST"
+	EnvDefaultAgent = "ACPONE_DEFAULT_AGENT"
+	// EnvWorkspace is a directory used as the default workspace when none
+	// is configured, e.g. a volume mounted into a container
+	EnvWorkspace = "ACPONE_WORKSPACE"
+)
+
+// ApplyEnv overrides the configuration with the ACPONE_* environment
+// variables that are set
+func (c *Config) ApplyEnv() {
+	if c.Server == nil {
+		c.Server = &ServerConfig{}
+	}
+	env := func(name string, field *string) {
+		if v := os.Getenv(name); v != "" {
+			*field = v
+		}
+	}
+	env(EnvPort, &c.Server.Port)
+	env(EnvHost, &c.Server.Host)
+	env(EnvDataDir, &c.Server.DataDir)
+	env(EnvAuthToken, &c.Server.AuthToken)
+	env(EnvAdminPort, &c.Server.AdminPort)
+	env(EnvAdminHost, &c.Server.AdminHost)
+	env(EnvDefaultAgent, &c.DefaultAgent)
+	if dir := os.Getenv(EnvWorkspace); dir != "" && len(c.Workspaces) == 0 {
+		c.Workspaces = []WorkspaceConfig{{ID: "default", Name: filepath.Base(dir), Path: dir}}
+	}
+}
+
+// Addr returns the address to listen on
+func (s *ServerConfig) Addr() string {
+	port := DefaultPort
+	host := ""
+	if s != nil {
+		if s.Port != "" {
+			port = s.Port
+		}
+		host = s.Host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// AdminAddr returns the address of the admin listener, or "" when the admin
+// endpoints are served with the rest
+func (s *ServerConfig) AdminAddr() string {
+	if s == nil || s.AdminPort == "" {
+		return ""
+	}
+	host := s.AdminHost
+	if host == "" {
+		host = DefaultAdminHost
+	}
+	return net.JoinHostPort(host, s.AdminPort)
+}
+
+// Secrets returns the tokens clients may authenticate with, none when
+// authentication is off
+func (s *ServerConfig) Secrets() []string {
+	if s == nil {
+		return nil
+	}
+	var secrets []string
+	if s.AuthToken != "" {
+		secrets = append(secrets, s.AuthToken)
+	}
+	return append(secrets, s.APIKeys...)
+}
+
+func (s *ServerConfig) validate() error {
+	for i, key := range s.APIKeys {
+		if key == "" {
+			return fmt.Errorf("server: apiKeys[%d] is empty", i)
+		}
+	}
+	if s.AdminPort == "" {
+		return nil
+	}
+	port := s.Port
+	if port == "" {
+		port = DefaultPort
+	}
+	if s.AdminPort == port {
+		return fmt.Errorf("server: adminPort must differ from port %s", port)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Slack notification kinds
+const (
+	SlackDone       = "done"       // Turn completed
+	SlackError      = "error"      // Turn failed
+	SlackPermission = "permission" // Agent waits for a permission answer
+)
+
+// SlackConfig posts turn completions, errors and permission waits to Slack
+// through an incoming webhook, or with a bot token to a channel where each
+// conversation gets a thread of its own
+type SlackConfig struct {
+	WebhookURL string   `json:"webhookUrl,omitempty"`
+	BotToken   string   `json:"botToken,omitempty"` // xoxb-... with chat:write, defaults to $SLACK_BOT_TOKEN
+	Channel    string   `json:"channel,omitempty"`  // Channel ID or name for the bot
+	Events     []string `json:"events,omitempty"`   // done, error and permission (default all)
+}
+
+func (s *SlackConfig) validate() error {
+	if s.WebhookURL == "" && s.Channel == "" {
+		return errors.New("slack: webhookUrl or channel is required")
+	}
+	for _, e := range s.Events {
+		switch e {
+		case SlackDone, SlackError, SlackPermission:
+		default:
+			return fmt.Errorf("slack: invalid event: %s", e)
+		}
+	}
+	return nil
+}
+
+// Notifies reports whether the event kind is posted
+func (s *SlackConfig) Notifies(kind string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == kind {
+			return true
+		}
+	}
+	return false
+}
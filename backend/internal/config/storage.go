@@ -0,0 +1,95 @@
+package config
+
+import "fmt"
+
+// Storage backend types
+const (
+	StorageLocal  = "local"
+	StorageS3     = "s3"
+	StorageWebDAV = "webdav"
+	StorageGit    = "git" // Sync remotes only
+)
+
+// Session store types
+const (
+	SessionsJSON   = "json"   // One JSON file per session on the storage backend
+	SessionsSQLite = "sqlite" // A SQLite database in the data directory
+)
+
+// StorageConfig selects where sessions and workspaces are persisted
+type StorageConfig struct {
+	Type   string        `json:"type,omitempty"` // local (default), s3 or webdav
+	S3     *S3Config     `json:"s3,omitempty"`
+	WebDAV *WebDAVConfig `json:"webdav,omitempty"`
+	Git    *GitConfig    `json:"git,omitempty"`
+	// Sessions selects the session store: json (default) or sqlite. SQLite
+	// keeps its database on local disk, so it requires local storage.
+	Sessions string `json:"sessions,omitempty"`
+}
+
+// GitConfig defines a git repository used as a sync remote
+type GitConfig struct {
+	Repo   string `json:"repo"`             // Clone URL
+	Branch string `json:"branch,omitempty"` // Defaults to main
+	Dir    string `json:"dir,omitempty"`    // Local clone, defaults to ~/.acpone/sync-git
+}
+
+// SyncConfig mirrors local sessions to a remote shared between machines
+type SyncConfig struct {
+	Remote          StorageConfig `json:"remote"`
+	IntervalSeconds int           `json:"intervalSeconds,omitempty"` // Defaults to 60
+}
+
+// S3Config defines an S3-compatible bucket (AWS, MinIO, R2, ...)
+type S3Config struct {
+	Endpoint        string `json:"endpoint,omitempty"` // Defaults to AWS for the region
+	Region          string `json:"region,omitempty"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix,omitempty"`
+	AccessKeyID     string `json:"accessKeyId,omitempty"`     // Defaults to AWS_ACCESS_KEY_ID
+	SecretAccessKey string `json:"secretAccessKey,omitempty"` // Defaults to AWS_SECRET_ACCESS_KEY
+	PathStyle       bool   `json:"pathStyle,omitempty"`       // Use endpoint/bucket/key URLs
+}
+
+// WebDAVConfig defines a WebDAV collection
+type WebDAVConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+func (st *StorageConfig) validate(field string, allowGit bool) error {
+	switch st.Type {
+	case "", StorageLocal:
+	case StorageS3:
+		if st.S3 == nil || st.S3.Bucket == "" {
+			return fmt.Errorf("%s.s3.bucket is required", field)
+		}
+	case StorageWebDAV:
+		if st.WebDAV == nil || st.WebDAV.URL == "" {
+			return fmt.Errorf("%s.webdav.url is required", field)
+		}
+	case StorageGit:
+		if !allowGit {
+			return fmt.Errorf("%s.type git is only supported for sync", field)
+		}
+		if st.Git == nil || st.Git.Repo == "" {
+			return fmt.Errorf("%s.git.repo is required", field)
+		}
+	default:
+		return fmt.Errorf("invalid %s.type: %s", field, st.Type)
+	}
+	switch st.Sessions {
+	case "", SessionsJSON:
+	case SessionsSQLite:
+		if allowGit {
+			return fmt.Errorf("%s.sessions sqlite is only supported for storage", field)
+		}
+		if st.Type != "" && st.Type != StorageLocal {
+			return fmt.Errorf("%s.sessions sqlite requires local storage", field)
+		}
+	default:
+		return fmt.Errorf("invalid %s.sessions: %s", field, st.Sessions)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import "fmt"
+
+// Team member roles, run in this order
+const (
+	RolePlanner     = "planner"
+	RoleImplementer = "implementer"
+	RoleTester      = "tester"
+)
+
+// DefaultTeamRounds is how often the implementer may retry after the tester
+// reports failures
+const DefaultTeamRounds = 3
+
+// TeamConfig is a virtual agent made of member agents with roles. The
+// planner plans, the implementer carries out the plan and the tester checks
+// the result, sending it back to the implementer until it passes. Prompt a
+// team with "@<id>" in a message.
+type TeamConfig struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name,omitempty"`
+	Members   []TeamMember `json:"members"`
+	MaxRounds int          `json:"maxRounds,omitempty"` // Implement/test rounds, defaults to 3
+}
+
+// TeamMember is an agent playing a role within a team
+type TeamMember struct {
+	Agent string `json:"agent"`
+	Role  string `json:"role"` // planner, implementer or tester
+	// Prompt holds role instructions. It may use {{input}} (user prompt),
+	// {{context}} (earlier member replies), {{diff}} (workspace git diff)
+	// and {{role}}; without any placeholder it is prepended to the default
+	// team prompt.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// Member returns the member playing role, or nil
+func (t *TeamConfig) Member(role string) *TeamMember {
+	for i := range t.Members {
+		if t.Members[i].Role == role {
+			return &t.Members[i]
+		}
+	}
+	return nil
+}
+
+// Rounds returns the maximum number of implement/test rounds
+func (t *TeamConfig) Rounds() int {
+	if t.MaxRounds > 0 {
+		return t.MaxRounds
+	}
+	return DefaultTeamRounds
+}
+
+// FindTeam returns team config by ID
+func (c *Config) FindTeam(id string) *TeamConfig {
+	for i := range c.Teams {
+		if c.Teams[i].ID == id {
+			return &c.Teams[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateTeams() error {
+	ids := make(map[string]bool)
+	for _, t := range c.Teams {
+		if !pipelineIDRegex.MatchString(t.ID) {
+			return fmt.Errorf("invalid team id: %q", t.ID)
+		}
+		if ids[t.ID] || c.FindAgentByName(t.ID) != nil || c.FindPipeline(t.ID) != nil {
+			return fmt.Errorf("duplicate team id: %s", t.ID)
+		}
+		ids[t.ID] = true
+
+		roles := make(map[string]bool)
+		for _, m := range t.Members {
+			switch m.Role {
+			case RolePlanner, RoleImplementer, RoleTester:
+			default:
+				return fmt.Errorf("team %s: invalid role: %q", t.ID, m.Role)
+			}
+			if roles[m.Role] {
+				return fmt.Errorf("team %s: duplicate role: %s", t.ID, m.Role)
+			}
+			roles[m.Role] = true
+			if c.FindAgent(m.Agent) == nil {
+				return fmt.Errorf("team %s: %s: agent not found: %s", t.ID, m.Role, m.Agent)
+			}
+		}
+		if !roles[RoleImplementer] {
+			return fmt.Errorf("team %s: an implementer is required", t.ID)
+		}
+	}
+	return nil
+}
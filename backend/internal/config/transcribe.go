@@ -0,0 +1,28 @@
+package config
+
+import "errors"
+
+// TranscribeConfig selects the speech-to-text backend for /api/transcribe.
+// Exactly one of Command or URL must be set.
+type TranscribeConfig struct {
+	// Command runs a local transcriber such as whisper.cpp and reads the
+	// text from stdout. "{file}" is replaced by the audio file path and
+	// "{lang}" by the requested language, e.g.
+	// ["whisper-cli", "-m", "ggml-base.bin", "-nt", "-l", "{lang}", "-f", "{file}"]
+	Command []string `json:"command,omitempty"`
+
+	// URL is an OpenAI-compatible /v1/audio/transcriptions endpoint
+	URL      string `json:"url,omitempty"`
+	APIKey   string `json:"apiKey,omitempty"` // Defaults to OPENAI_API_KEY
+	Model    string `json:"model,omitempty"`  // Defaults to whisper-1
+	Language string `json:"language,omitempty"`
+
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"` // Default 120
+}
+
+func (t *TranscribeConfig) validate() error {
+	if (len(t.Command) == 0) == (t.URL == "") {
+		return errors.New("transcribe: set exactly one of command or url")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrashConfig controls how long deleted sessions can be restored. Without
+// it they stay in the trash for DefaultTrashDays.
+type TrashConfig struct {
+	RetentionDays int `json:"retentionDays,omitempty"` // Purge trashed sessions after this many days (default 30, -1 deletes immediately)
+}
+
+// DefaultTrashDays is how long deleted sessions are kept by default
+const DefaultTrashDays = 30
+
+// Retention returns how long trashed sessions are kept, 0 when sessions are
+// deleted right away
+func (t *TrashConfig) Retention() time.Duration {
+	days := DefaultTrashDays
+	if t != nil && t.RetentionDays != 0 {
+		days = t.RetentionDays
+	}
+	if days < 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (t *TrashConfig) validate() error {
+	if t.RetentionDays < -1 {
+		return fmt.Errorf("trash: retentionDays must be -1 or more")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UploadConfig restricts files uploaded into workspaces through the chat
+// input. Without it any file up to 10MB is accepted and kept for
+// DefaultUploadKeepHours after its conversation goes idle.
+type UploadConfig struct {
+	Extensions         []string `json:"extensions,omitempty"`         // Allowed extensions, e.g. ".png" (empty allows all)
+	MaxFileMB          int      `json:"maxFileMB,omitempty"`          // Per-file limit (default 10)
+	MaxWorkspaceMB     int      `json:"maxWorkspaceMB,omitempty"`     // Total of a workspace's uploads (0 = unlimited)
+	AllowExecutables   bool     `json:"allowExecutables,omitempty"`   // Accept ELF, PE and Mach-O binaries
+	ScanCommand        string   `json:"scanCommand,omitempty"`        // Run with the file path appended, e.g. "clamscan --no-summary"; non-zero exit rejects
+	ScanTimeoutSeconds int      `json:"scanTimeoutSeconds,omitempty"` // Default 60
+	KeepHours          int      `json:"keepHours,omitempty"`          // Delete uploads this long after their conversation goes idle (default 24, -1 keeps them)
+}
+
+// DefaultUploadKeepHours is how long uploads outlive their conversation's
+// last activity by default
+const DefaultUploadKeepHours = 24
+
+// Keep returns how long uploads are kept after their conversation goes
+// idle, 0 when they are never deleted automatically
+func (u *UploadConfig) Keep() time.Duration {
+	hours := DefaultUploadKeepHours
+	if u != nil && u.KeepHours != 0 {
+		hours = u.KeepHours
+	}
+	if hours < 0 {
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (u *UploadConfig) validate() error {
+	if u.MaxFileMB < 0 || u.MaxWorkspaceMB < 0 || u.ScanTimeoutSeconds < 0 {
+		return fmt.Errorf("upload: limits must not be negative")
+	}
+	if u.KeepHours < -1 {
+		return fmt.Errorf("upload: keepHours must be -1 or more")
+	}
+	for _, ext := range u.Extensions {
+		if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+			return fmt.Errorf("upload: extension must start with a dot: %q", ext)
+		}
+	}
+	return nil
+}
+
+// AllowsExtension reports whether a file with extension ext may be uploaded
+func (u *UploadConfig) AllowsExtension(ext string) bool {
+	if len(u.Extensions) == 0 {
+		return true
+	}
+	for _, e := range u.Extensions {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
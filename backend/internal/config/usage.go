@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UsageConfig sets quotas on the disk space a workspace takes up, as
+// reported by /api/workspaces/{id}/usage. An exceeded quota refuses new
+// uploads and is flagged in the UI.
+type UsageConfig struct {
+	MaxMB            int      `json:"maxMB,omitempty"`            // Uploads, transcripts and heavy directories together (0 = unlimited)
+	MaxTranscriptsMB int      `json:"maxTranscriptsMB,omitempty"` // Live transcripts (0 = unlimited)
+	HeavyDirs        []string `json:"heavyDirs,omitempty"`        // Directory names reported besides DefaultHeavyDirs, e.g. "out"
+}
+
+// DefaultHeavyDirs are dependency and build output directories reported
+// separately by workspace usage
+var DefaultHeavyDirs = []string{
+	"node_modules", ".venv", "venv", "__pycache__", "target", "vendor",
+	"dist", "build", ".next", ".nuxt", ".gradle", ".cache", "Pods", ".terraform",
+}
+
+// Heavy returns the directory names reported as heavy
+func (u *UsageConfig) Heavy() []string {
+	if u == nil || len(u.HeavyDirs) == 0 {
+		return DefaultHeavyDirs
+	}
+	return append(append([]string{}, DefaultHeavyDirs...), u.HeavyDirs...)
+}
+
+func (u *UsageConfig) validate() error {
+	if u.MaxMB < 0 || u.MaxTranscriptsMB < 0 {
+		return fmt.Errorf("usage: quotas must not be negative")
+	}
+	for _, dir := range u.HeavyDirs {
+		if dir == "" || strings.ContainsAny(dir, `/\`) {
+			return fmt.Errorf("usage: heavyDirs must be directory names: %q", dir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+// Result states
+const (
+	Pass    = "pass"
+	Fail    = "fail"
+	Skipped = "skipped" // Not observed; the agent may simply not use the feature
+)
+
+// Result is the outcome of one protocol feature check
+type Result struct {
+	Feature string `json:"feature"`
+	State   string `json:"state"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Checker exercises an ACP agent and records which features work
+type Checker struct {
+	proc    *agent.Process
+	dir     string
+	timeout time.Duration
+	results []Result
+
+	mu          sync.Mutex
+	updates     map[string]int // sessionUpdate kind -> count
+	callbacks   map[string]int // agent -> client request method -> count
+	permissions int
+}
+
+// Run spawns command with args and checks its ACP conformance
+func Run(command string, args []string, timeout time.Duration) ([]Result, error) {
+	dir, err := os.MkdirTemp("", "acpone-check-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config.AgentConfig{ID: "check", Name: "check", Command: command, Args: args}
+	c := &Checker{
+		proc:      agent.NewProcess(cfg),
+		dir:       dir,
+		timeout:   timeout,
+		updates:   make(map[string]int),
+		callbacks: make(map[string]int),
+	}
+	c.proc.SetWorkingDir(dir)
+	defer c.proc.Stop()
+
+	if err := c.proc.Start(); err != nil {
+		return nil, fmt.Errorf("spawn agent: %w", err)
+	}
+	c.observe()
+	c.run()
+	return c.results, nil
+}
+
+func (c *Checker) observe() {
+	c.proc.OnNotification(func(msg *jsonrpc.Message) {
+		var params struct {
+			Update struct {
+				SessionUpdate string `json:"sessionUpdate"`
+			} `json:"update"`
+		}
+		msg.ParseParams(&params)
+		c.mu.Lock()
+		c.updates[params.Update.SessionUpdate]++
+		c.mu.Unlock()
+	})
+	c.proc.OnFrame(func(f agent.Frame) {
+		var msg jsonrpc.Message
+		if f.Dir != agent.FrameRecv || json.Unmarshal(f.Data, &msg) != nil || !msg.IsRequest() {
+			return
+		}
+		c.mu.Lock()
+		c.callbacks[msg.Method]++
+		c.mu.Unlock()
+	})
+	c.proc.OnPermission(func(req *agent.PermissionRequest) {
+		c.mu.Lock()
+		c.permissions++
+		c.mu.Unlock()
+		optionID := ""
+		for _, opt := range req.Options {
+			if strings.HasPrefix(opt.Kind, "allow") {
+				optionID = opt.OptionID
+				break
+			}
+		}
+		if optionID == "" && len(req.Options) > 0 {
+			optionID = req.Options[0].OptionID
+		}
+		go c.proc.ConfirmPermission(req.ToolCall.ToolCallID, optionID)
+	})
+}
+
+func (c *Checker) add(feature, state, detail string) {
+	c.results = append(c.results, Result{Feature: feature, State: state, Detail: detail})
+}
+
+func (c *Checker) run() {
+	// initialize
+	var init struct {
+		ProtocolVersion   int            `json:"protocolVersion"`
+		AgentCapabilities map[string]any `json:"agentCapabilities"`
+	}
+	if err := c.request("initialize", map[string]any{
+		"protocolVersion": 1,
+		"clientCapabilities": map[string]any{
+			"fs": map[string]bool{"readTextFile": true, "writeTextFile": true},
+		},
+		"clientInfo": map[string]string{"name": "acpone-check", "version": "0.1.0"},
+	}, &init); err != nil {
+		c.add("initialize", Fail, err.Error())
+		return
+	}
+	detail := fmt.Sprintf("protocolVersion %d", init.ProtocolVersion)
+	if init.ProtocolVersion == 0 {
+		c.add("initialize", Fail, "missing protocolVersion")
+	} else {
+		c.add("initialize", Pass, detail)
+	}
+
+	// session/new
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := c.request("session/new", map[string]any{"cwd": c.dir, "mcpServers": []any{}}, &session); err != nil {
+		c.add("session/new", Fail, err.Error())
+		return
+	}
+	if session.SessionID == "" {
+		c.add("session/new", Fail, "no sessionId in response")
+		return
+	}
+	c.add("session/new", Pass, session.SessionID)
+
+	// session/prompt with streaming
+	c.prompt(session.SessionID, "prompt", "Reply with the single word OK.")
+	c.mu.Lock()
+	chunks := c.updates["agent_message_chunk"]
+	commands := c.updates["available_commands_update"]
+	c.mu.Unlock()
+	c.addObserved("streaming", chunks, "agent_message_chunk updates")
+	c.addObserved("slash commands", commands, "available_commands_update")
+
+	// fs callbacks and permissions
+	os.WriteFile(filepath.Join(c.dir, "input.txt"), []byte("acpone-check"), 0644)
+	c.prompt(session.SessionID, "fs prompt",
+		"Read the file input.txt in the current directory, then create a file named output.txt containing the same text.")
+
+	c.mu.Lock()
+	reads, writes, perms := c.callbacks["fs/read_text_file"], c.callbacks["fs/write_text_file"], c.permissions
+	c.mu.Unlock()
+	c.addObserved("fs/read_text_file", reads, "client callback")
+	c.addObserved("fs/write_text_file", writes, "client callback")
+	c.addObserved("session/request_permission", perms, "permission requests")
+
+	if data, err := os.ReadFile(filepath.Join(c.dir, "output.txt")); err == nil && strings.Contains(string(data), "acpone-check") {
+		c.add("file edit result", Pass, "output.txt written")
+	} else {
+		c.add("file edit result", Skipped, "output.txt not written")
+	}
+}
+
+func (c *Checker) prompt(sessionID, feature, text string) {
+	var result struct {
+		StopReason string `json:"stopReason"`
+	}
+	err := c.request("session/prompt", map[string]any{
+		"sessionId": sessionID,
+		"prompt":    []map[string]string{{"type": "text", "text": text}},
+	}, &result)
+	switch {
+	case err != nil:
+		c.add(feature, Fail, err.Error())
+	case result.StopReason == "":
+		c.add(feature, Fail, "missing stopReason")
+	default:
+		c.add(feature, Pass, "stopReason "+result.StopReason)
+	}
+}
+
+func (c *Checker) addObserved(feature string, count int, what string) {
+	if count > 0 {
+		c.add(feature, Pass, fmt.Sprintf("%d %s", count, what))
+	} else {
+		c.add(feature, Skipped, "no "+what+" observed")
+	}
+}
+
+// request sends a request and fails if no response arrives within the timeout
+func (c *Checker) request(method string, params, result any) error {
+	type reply struct {
+		msg *jsonrpc.Message
+		err error
+	}
+	done := make(chan reply, 1)
+	go func() {
+		msg, err := c.proc.Request(method, params)
+		done <- reply{msg, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.err
+		}
+		return r.msg.ParseResult(result)
+	case <-time.After(c.timeout):
+		return fmt.Errorf("no response within %s", c.timeout)
+	}
+}
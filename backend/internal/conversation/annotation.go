@@ -0,0 +1,66 @@
+package conversation
+
+import "sort"
+
+// Ratings of an annotated message
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// Annotation is feedback attached to one message of a conversation, e.g. to
+// mark which agent suggestions worked
+type Annotation struct {
+	Message   int    `json:"message"`          // Index in the conversation's messages
+	Rating    string `json:"rating,omitempty"` // up, down or empty
+	Note      string `json:"note,omitempty"`
+	Todo      bool   `json:"todo,omitempty"` // Flagged for follow-up
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// Empty reports whether the annotation holds nothing and can be dropped
+func (a Annotation) Empty() bool {
+	return a.Rating == "" && a.Note == "" && !a.Todo
+}
+
+// SetAnnotations replaces the annotations of a conversation
+func (m *Manager) SetAnnotations(id string, annotations []Annotation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.Annotations = annotations
+	}
+}
+
+// Annotate sets the annotation of a message, removing it when empty. It
+// reports false when the conversation or message doesn't exist.
+func (m *Manager) Annotate(id string, a Annotation) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conv, ok := m.conversations[id]
+	if !ok || a.Message < 0 || a.Message >= len(conv.Messages) {
+		return false
+	}
+	kept := conv.Annotations[:0:0]
+	for _, old := range conv.Annotations {
+		if old.Message != a.Message {
+			kept = append(kept, old)
+		}
+	}
+	if !a.Empty() {
+		kept = append(kept, a)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Message < kept[j].Message })
+	conv.Annotations = kept
+	return true
+}
+
+// Annotations returns a copy of the conversation's annotations
+func (m *Manager) Annotations(id string) []Annotation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if conv, ok := m.conversations[id]; ok {
+		return append([]Annotation(nil), conv.Annotations...)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package conversation
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -27,6 +28,15 @@ type MessageFile struct {
 	Size int64  `json:"size"`
 }
 
+// Routing records why a user message was routed to its agent
+type Routing struct {
+	Agent    string `json:"agent"`
+	Strategy string `json:"strategy"` // mention, keyword, meta, pipeline, team, active, default or fallback
+	Rule     string `json:"rule,omitempty"`
+	Match    string `json:"match,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
 // Message in conversation history
 type Message struct {
 	Role      string        `json:"role"` // user, assistant
@@ -34,17 +44,52 @@ type Message struct {
 	Agent     string        `json:"agent,omitempty"`
 	ToolCall  *ToolCallInfo `json:"toolCall,omitempty"`
 	Files     []MessageFile `json:"files,omitempty"`
+	Kind      string        `json:"kind,omitempty"` // "review" for automatic review findings
+	Routing   *Routing      `json:"routing,omitempty"`
 	Timestamp int64         `json:"timestamp"`
 }
 
+// Pin kinds
+const (
+	PinFile = "file" // Workspace-relative path, sent as file content or a link
+	PinURL  = "url"  // Sent as a resource link
+	PinNote = "note" // Text, optionally named like a variable
+)
+
+// Pin is context attached to every prompt of a conversation
+type Pin struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"` // file, url or note
+	Name      string `json:"name,omitempty"`
+	Value     string `json:"value"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// Limits override the configured per-turn limits of a conversation. Zero
+// keeps the configured limit, a negative value turns it off.
+type Limits struct {
+	MaxChars      int    `json:"maxChars,omitempty"`
+	MaxToolCalls  int    `json:"maxToolCalls,omitempty"`
+	MaxDurationMs int    `json:"maxDurationMs,omitempty"`
+	MaxRepeats    int    `json:"maxRepeats,omitempty"` // Identical tool calls in a row
+	OnRepeat      string `json:"onRepeat,omitempty"`   // warn or cancel, empty keeps the configured action
+}
+
 // Conversation with full history
 type Conversation struct {
-	ID               string    `json:"id"`
-	Messages         []Message `json:"messages"`
-	ActiveAgent      string    `json:"activeAgent"`
-	CurrentSessionID string    `json:"currentSessionId,omitempty"`
-	WorkspaceID      string    `json:"workspaceId,omitempty"`
-	CreatedAt        int64     `json:"createdAt"`
+	ID               string         `json:"id"`
+	Messages         []Message      `json:"messages"`
+	ActiveAgent      string         `json:"activeAgent"`
+	CurrentSessionID string         `json:"currentSessionId,omitempty"`
+	WorkspaceID      string         `json:"workspaceId,omitempty"`
+	MentionMode      string         `json:"mentionMode,omitempty"` // Overrides the configured mention mode
+	AgentParams      map[string]any `json:"agentParams,omitempty"` // Sent to agents with each prompt, e.g. {"effort": "high"}
+	Limits           *Limits        `json:"limits,omitempty"`
+	Pins             []Pin          `json:"pins,omitempty"`
+	Annotations      []Annotation   `json:"annotations,omitempty"` // Ratings, notes and TODO flags on messages
+	Turns            []Turn         `json:"turns,omitempty"`       // Timing and outcome of each agent turn
+	Branch           string         `json:"branch,omitempty"`      // Git branch holding the conversation's edits
+	CreatedAt        int64          `json:"createdAt"`
 }
 
 // Manager manages conversations
@@ -83,6 +128,23 @@ func (m *Manager) Get(id string) *Conversation {
 	return m.conversations[id]
 }
 
+// Snapshot returns a copy of a conversation that turns in progress do not
+// change underneath the caller, or nil
+func (m *Manager) Snapshot(id string) *Conversation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	conv, ok := m.conversations[id]
+	if !ok {
+		return nil
+	}
+	snapshot := *conv
+	snapshot.Messages = append([]Message(nil), conv.Messages...)
+	snapshot.Pins = append([]Pin(nil), conv.Pins...)
+	snapshot.Annotations = append([]Annotation(nil), conv.Annotations...)
+	snapshot.Turns = append([]Turn(nil), conv.Turns...)
+	return &snapshot
+}
+
 // Has checks if conversation exists
 func (m *Manager) Has(id string) bool {
 	m.mu.RLock()
@@ -114,6 +176,22 @@ func (m *Manager) AddUserMessage(id, content string, files []MessageFile) {
 	}
 }
 
+// SetRouting records the routing decision on the latest user message
+func (m *Manager) SetRouting(id string, routing *Routing) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conv, ok := m.conversations[id]
+	if !ok {
+		return
+	}
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		if conv.Messages[i].Role == "user" {
+			conv.Messages[i].Routing = routing
+			return
+		}
+	}
+}
+
 // AddAssistantMessage adds an assistant message
 func (m *Manager) AddAssistantMessage(id, content, agent string) {
 	m.mu.Lock()
@@ -128,6 +206,22 @@ func (m *Manager) AddAssistantMessage(id, content, agent string) {
 	}
 }
 
+// AddAnnotatedMessage adds an assistant message of a distinct kind, such as
+// a review
+func (m *Manager) AddAnnotatedMessage(id, content, agent, kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.Messages = append(conv.Messages, Message{
+			Role:      "assistant",
+			Content:   content,
+			Agent:     agent,
+			Kind:      kind,
+			Timestamp: time.Now().UnixMilli(),
+		})
+	}
+}
+
 // AddToolCall adds a tool call message
 func (m *Manager) AddToolCall(id string, toolCall *ToolCallInfo, agent string) {
 	m.mu.Lock()
@@ -152,6 +246,165 @@ func (m *Manager) SetActiveAgent(id, agent string) {
 	}
 }
 
+// SetMentionMode overrides the mention mode of a conversation
+func (m *Manager) SetMentionMode(id, mode string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.MentionMode = mode
+	}
+}
+
+// SetAgentParams replaces the parameters sent to agents with each prompt
+func (m *Manager) SetAgentParams(id string, params map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.AgentParams = params
+	}
+}
+
+// AgentParams returns a copy of the conversation's agent parameters
+func (m *Manager) AgentParams(id string) map[string]any {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	conv, ok := m.conversations[id]
+	if !ok || len(conv.AgentParams) == 0 {
+		return nil
+	}
+	params := make(map[string]any, len(conv.AgentParams))
+	for k, v := range conv.AgentParams {
+		params[k] = v
+	}
+	return params
+}
+
+// SetLimits replaces the conversation's per-turn limits, nil for the configured ones
+func (m *Manager) SetLimits(id string, limits *Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.Limits = limits
+	}
+}
+
+// Limits returns a copy of the conversation's per-turn limits, or nil
+func (m *Manager) Limits(id string) *Limits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	conv, ok := m.conversations[id]
+	if !ok || conv.Limits == nil {
+		return nil
+	}
+	limits := *conv.Limits
+	return &limits
+}
+
+// SetBranch records the git branch of the conversation's edits
+func (m *Manager) SetBranch(id, branch string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.Branch = branch
+	}
+}
+
+// Branch returns the git branch of the conversation's edits
+func (m *Manager) Branch(id string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if conv, ok := m.conversations[id]; ok {
+		return conv.Branch
+	}
+	return ""
+}
+
+// LastUserMessage returns the content of the latest user message
+func (m *Manager) LastUserMessage(id string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	conv, ok := m.conversations[id]
+	if !ok {
+		return ""
+	}
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		if conv.Messages[i].Role == "user" {
+			return conv.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// LastReply returns the agent text answering the latest user message,
+// leaving out tool calls and annotated messages such as reviews
+func (m *Manager) LastReply(id string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	conv, ok := m.conversations[id]
+	if !ok {
+		return ""
+	}
+	var parts []string
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		msg := conv.Messages[i]
+		if msg.Role == "user" {
+			break
+		}
+		if msg.ToolCall == nil && msg.Kind == "" && msg.Content != "" {
+			parts = append([]string{msg.Content}, parts...)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// SetPins replaces the pinned context of a conversation
+func (m *Manager) SetPins(id string, pins []Pin) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.Pins = pins
+	}
+}
+
+// AddPin pins context to a conversation
+func (m *Manager) AddPin(id string, pin Pin) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conv, ok := m.conversations[id]
+	if !ok {
+		return false
+	}
+	conv.Pins = append(conv.Pins, pin)
+	return true
+}
+
+// RemovePin unpins context, reporting whether it was pinned
+func (m *Manager) RemovePin(id, pinID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conv, ok := m.conversations[id]
+	if !ok {
+		return false
+	}
+	for i, pin := range conv.Pins {
+		if pin.ID == pinID {
+			conv.Pins = append(conv.Pins[:i:i], conv.Pins[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Pins returns a copy of the conversation's pinned context
+func (m *Manager) Pins(id string) []Pin {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if conv, ok := m.conversations[id]; ok {
+		return append([]Pin(nil), conv.Pins...)
+	}
+	return nil
+}
+
 // SetSessionID sets the current session ID
 func (m *Manager) SetSessionID(id, sessionID string) {
 	m.mu.Lock()
@@ -185,7 +438,9 @@ func (m *Manager) GetContextSummary(id string, maxMessages int) string {
 
 	for _, msg := range recent {
 		prefix := "User"
-		if msg.Role == "assistant" {
+		if msg.Role == "assistant" && msg.Agent == "" {
+			prefix = "Note" // e.g. a workspace switch
+		} else if msg.Role == "assistant" {
 			prefix = fmt.Sprintf("Assistant (%s)", msg.Agent)
 		}
 		content := msg.Content
@@ -0,0 +1,41 @@
+package conversation
+
+import "unicode/utf8"
+
+// EstimateTokens approximates the tokens of text for a tokenizer averaging
+// charsPerToken characters per token on Latin text. CJK and other non-ASCII
+// characters are counted as one token each.
+func EstimateTokens(text string, charsPerToken float64) int {
+	if charsPerToken <= 0 {
+		charsPerToken = 4
+	}
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return int(float64(ascii)/charsPerToken+0.5) + other
+}
+
+// EstimateTokens approximates the tokens taken by the conversation history,
+// including tool call input and output
+func (m *Manager) EstimateTokens(id string, charsPerToken float64) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	conv, ok := m.conversations[id]
+	if !ok {
+		return 0
+	}
+	total := 0
+	for _, msg := range conv.Messages {
+		total += EstimateTokens(msg.Content, charsPerToken)
+		if tc := msg.ToolCall; tc != nil {
+			total += EstimateTokens(tc.Title+tc.RawInput+tc.Output+tc.Error, charsPerToken)
+		}
+	}
+	return total
+}
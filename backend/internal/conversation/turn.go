@@ -0,0 +1,58 @@
+package conversation
+
+// StopError is the stop reason recorded for a turn that failed
+const StopError = "error"
+
+// Turn records how one agent turn of a conversation went, so agents can be
+// compared on real work
+type Turn struct {
+	Agent         string `json:"agent"`
+	Kind          string `json:"kind,omitempty"` // Message kind of the turn, e.g. "review"
+	StartedAt     int64  `json:"startedAt"`
+	DurationMs    int64  `json:"durationMs"`
+	FirstOutputMs int64  `json:"firstOutputMs,omitempty"` // Until the agent's first update
+	StopReason    string `json:"stopReason"`              // As reported by the agent, "error" when the turn failed
+	Truncated     bool   `json:"truncated,omitempty"`     // Canceled for exceeding its limits
+	ToolCalls     int    `json:"toolCalls,omitempty"`
+	// Workspace files changed while answering the message, recorded on
+	// the last turn answering it
+	Files []string `json:"files,omitempty"`
+}
+
+// SetTurns replaces the recorded turns of a conversation
+func (m *Manager) SetTurns(id string, turns []Turn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.Turns = turns
+	}
+}
+
+// AddTurn records a finished turn
+func (m *Manager) AddTurn(id string, turn Turn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok {
+		conv.Turns = append(conv.Turns, turn)
+	}
+}
+
+// SetTurnFiles records the files changed while answering the last message
+// on the conversation's last turn
+func (m *Manager) SetTurnFiles(id string, files []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conv, ok := m.conversations[id]; ok && len(conv.Turns) > 0 {
+		conv.Turns[len(conv.Turns)-1].Files = files
+	}
+}
+
+// Turns returns a copy of the conversation's recorded turns
+func (m *Manager) Turns(id string) []Turn {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if conv, ok := m.conversations[id]; ok {
+		return append([]Turn(nil), conv.Turns...)
+	}
+	return nil
+}
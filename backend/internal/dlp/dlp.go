@@ -0,0 +1,74 @@
+// Package dlp scans outgoing prompts for secrets such as cloud keys and
+// private keys, so they can be blocked or masked before reaching an agent.
+package dlp
+
+import (
+	"regexp"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/logging"
+)
+
+var logger = logging.Component("scan")
+
+// builtin patterns match common credentials
+var builtin = []config.ScanPattern{
+	{Name: "aws-access-key", Regex: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws-secret-key", Regex: `(?i)aws.{0,20}(?:secret|key).{0,5}[=:"'\s]+[A-Za-z0-9/+]{40}\b`},
+	{Name: "private-key", Regex: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{Name: "github-token", Regex: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{50,})\b`},
+	{Name: "slack-token", Regex: `\bxox[abprs]-[A-Za-z0-9-]{10,}\b`},
+	{Name: "api-key", Regex: `\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{32,}\b`},
+	{Name: "google-api-key", Regex: `\bAIza[0-9A-Za-z_-]{35}\b`},
+}
+
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Scanner finds secrets in text
+type Scanner struct {
+	Action   string // config.ScanBlock or config.ScanMask
+	patterns []pattern
+}
+
+// New creates a scanner for cfg, or returns nil when scanning is off
+func New(cfg *config.ScanConfig) *Scanner {
+	if cfg == nil {
+		return nil
+	}
+	s := &Scanner{Action: cfg.Action}
+	if s.Action == "" {
+		s.Action = config.ScanBlock
+	}
+
+	var defs []config.ScanPattern
+	if !cfg.NoBuiltin {
+		defs = append(defs, builtin...)
+	}
+	defs = append(defs, cfg.Patterns...)
+	for _, d := range defs {
+		re, err := regexp.Compile(d.Regex)
+		if err != nil {
+			logger.Warn("skipping pattern", "pattern", d.Name, "error", err)
+			continue
+		}
+		s.patterns = append(s.patterns, pattern{name: d.Name, re: re})
+	}
+	return s
+}
+
+// Scan returns text with secrets replaced by [REDACTED:<name>] and the
+// names of the patterns that matched
+func (s *Scanner) Scan(text string) (string, []string) {
+	var found []string
+	for _, p := range s.patterns {
+		if !p.re.MatchString(text) {
+			continue
+		}
+		found = append(found, p.name)
+		text = p.re.ReplaceAllLiteralString(text, "[REDACTED:"+p.name+"]")
+	}
+	return text, found
+}
@@ -0,0 +1,226 @@
+// Package eventlog persists event bus events to an append-only, rotated
+// JSON-lines log so clients can replay what they missed and turns can be
+// reconstructed after the fact.
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/daodao97/acpone/internal/events"
+	"github.com/daodao97/acpone/internal/logging"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+var logger = logging.Component("eventlog")
+
+const (
+	maxFileSize = 10 << 20 // Rotate the current file beyond this size
+	maxFiles    = 5        // Current file plus rotated ones
+	current     = "events.log"
+
+	// MaxHistory caps the events returned by one History call
+	MaxHistory = 5000
+)
+
+// Log appends events to <dir>/events.log, rotating it into events.1.log,
+// events.2.log, ... (higher is older)
+type Log struct {
+	dir string
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	lastSeq uint64
+}
+
+// DefaultDir returns ~/.acpone/events
+func DefaultDir() string {
+	return filepath.Join(sysutil.DataDir(), "events")
+}
+
+// Open opens the log in dir, creating it if needed
+func Open(dir string) (*Log, error) {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	l := &Log{dir: dir}
+	if err := l.openCurrent(); err != nil {
+		return nil, err
+	}
+
+	// Continue numbering after the newest logged event
+	for i := 0; i < maxFiles && l.lastSeq == 0; i++ {
+		scanFile(l.path(i), func(ev *events.Event) bool {
+			l.lastSeq = ev.Seq
+			return true
+		})
+	}
+	return l, nil
+}
+
+// LastSeq returns the sequence number of the newest logged event
+func (l *Log) LastSeq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastSeq
+}
+
+// Append writes an event, rotating files when the current one is full
+func (l *Log) Append(ev events.Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		logger.Warn("skipping event", "type", ev.Type, "error", err)
+		return
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	if l.size > 0 && l.size+int64(len(data)) > maxFileSize {
+		if err := l.rotate(); err != nil {
+			logger.Error("rotate failed", "error", err)
+			return
+		}
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		logger.Error("write failed", "error", err)
+		return
+	}
+	l.lastSeq = ev.Seq
+}
+
+// History returns up to limit logged events after seq since that match f,
+// oldest first, and whether more events follow
+func (l *Log) History(since uint64, f events.Filter, limit int) ([]events.Event, bool) {
+	if limit <= 0 || limit > MaxHistory {
+		limit = MaxHistory
+	}
+
+	// Hold the lock so rotation cannot move files mid-read
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]events.Event, 0)
+	more := false
+	for i := l.firstFile(since); i >= 0 && !more; i-- {
+		scanFile(l.path(i), func(ev *events.Event) bool {
+			if ev.Seq <= since || !f.Match(ev) {
+				return true
+			}
+			if len(result) == limit {
+				more = true
+				return false
+			}
+			result = append(result, *ev)
+			return true
+		})
+	}
+	return result, more
+}
+
+// Close closes the current file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// firstFile returns the index of the newest file that starts at or before
+// since, so older files need not be read
+func (l *Log) firstFile(since uint64) int {
+	for i := 0; i < maxFiles; i++ {
+		first, ok := firstSeq(l.path(i))
+		if !ok {
+			if i == 0 {
+				continue // Current file is empty
+			}
+			return i - 1
+		}
+		if first <= since+1 {
+			return i
+		}
+	}
+	return maxFiles - 1
+}
+
+func (l *Log) path(i int) string {
+	if i == 0 {
+		return filepath.Join(l.dir, current)
+	}
+	return filepath.Join(l.dir, fmt.Sprintf("events.%d.log", i))
+}
+
+func (l *Log) openCurrent() error {
+	f, err := os.OpenFile(l.path(0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+func (l *Log) rotate() error {
+	l.file.Close()
+	l.file = nil
+	os.Remove(l.path(maxFiles - 1))
+	for i := maxFiles - 2; i >= 0; i-- {
+		os.Rename(l.path(i), l.path(i+1))
+	}
+	return l.openCurrent()
+}
+
+// scanFile calls fn for each event in a log file until fn returns false.
+// Malformed lines, such as one cut short by a crash, are skipped.
+func scanFile(path string, fn func(*events.Event) bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFileSize)
+	for scanner.Scan() {
+		var ev events.Event
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		if !fn(&ev) {
+			return
+		}
+	}
+}
+
+// firstSeq returns the sequence number of a file's first event
+func firstSeq(path string) (uint64, bool) {
+	var seq uint64
+	found := false
+	scanFile(path, func(ev *events.Event) bool {
+		seq, found = ev.Seq, true
+		return false
+	})
+	return seq, found
+}
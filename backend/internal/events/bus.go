@@ -0,0 +1,132 @@
+// Package events is the server's internal event bus. Chat turns, tool calls,
+// permission requests, agent lifecycle, setup progress and config changes
+// are published here and fanned out to subscribers such as SSE streams.
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Topic groups related event types
+type Topic string
+
+const (
+	Turn       Topic = "turn"       // Chat turn progress: session, status, update, stage, retry, done, error
+	Tool       Topic = "tool"       // Tool call updates, including hook runs
+	Permission Topic = "permission" // Permission requests from agents
+	Agent      Topic = "agent"      // Agent lifecycle, commands and warnings
+	Setup      Topic = "setup"      // Dependency checks and installs
+	Config     Topic = "config"     // Configuration changes
+)
+
+// Event is one published event
+type Event struct {
+	Seq            uint64 `json:"seq"`
+	Time           int64  `json:"time"` // Unix milliseconds
+	Topic          Topic  `json:"topic"`
+	Type           string `json:"type"` // SSE event name, e.g. "tool_call"
+	ConversationID string `json:"conversationId,omitempty"`
+	Stream         string `json:"stream,omitempty"` // Request that produced the event
+	Data           any    `json:"data"`
+}
+
+// Filter selects events for a subscriber. Empty fields match everything.
+type Filter struct {
+	Topics         []Topic
+	ConversationID string
+	Stream         string
+}
+
+// Match reports whether ev passes the filter
+func (f Filter) Match(ev *Event) bool {
+	if len(f.Topics) > 0 && !slices.Contains(f.Topics, ev.Topic) {
+		return false
+	}
+	if f.ConversationID != "" && f.ConversationID != ev.ConversationID {
+		return false
+	}
+	return f.Stream == "" || f.Stream == ev.Stream
+}
+
+type subscriber struct {
+	id      int
+	filter  Filter
+	handler func(Event)
+}
+
+// Bus delivers published events to matching subscribers
+type Bus struct {
+	mu        sync.RWMutex
+	seq       uint64
+	handlerID int
+	subs      []subscriber
+}
+
+// New creates an event bus
+func New() *Bus {
+	return &Bus{}
+}
+
+// Publish stamps ev with a sequence number and time and delivers it to
+// matching subscribers in the caller's goroutine. Handlers must not block.
+func (b *Bus) Publish(ev Event) Event {
+	b.mu.Lock()
+	b.seq++
+	ev.Seq = b.seq
+	ev.Time = time.Now().UnixMilli()
+	subs := b.subs
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		if sub.filter.Match(&ev) {
+			sub.handler(ev)
+		}
+	}
+	return ev
+}
+
+// Subscribe registers handler for events matching f and returns a cleanup function
+func (b *Bus) Subscribe(f Filter, handler func(Event)) func() {
+	b.mu.Lock()
+	b.handlerID++
+	id := b.handlerID
+	// Copy on write so Publish can iterate without holding the lock
+	b.subs = append(slices.Clip(b.subs), subscriber{id: id, filter: f, handler: handler})
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		b.subs = slices.DeleteFunc(slices.Clone(b.subs), func(s subscriber) bool { return s.id == id })
+		b.mu.Unlock()
+	}
+}
+
+// Channel subscribes a buffered channel to events matching f. Events are
+// dropped while the channel is full, so slow readers never block publishers.
+// The cleanup function unsubscribes; the channel is not closed.
+func (b *Bus) Channel(f Filter, size int) (<-chan Event, func()) {
+	ch := make(chan Event, size)
+	cancel := b.Subscribe(f, func(ev Event) {
+		select {
+		case ch <- ev:
+		default:
+		}
+	})
+	return ch, cancel
+}
+
+// Resume continues sequence numbers after seq, e.g. the last persisted event
+func (b *Bus) Resume(seq uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq = max(b.seq, seq)
+}
+
+// Seq returns the sequence number of the last published event
+func (b *Bus) Seq() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.seq
+}
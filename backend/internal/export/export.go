@@ -0,0 +1,34 @@
+// Package export renders conversations as standalone documents.
+package export
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+//go:embed conversation.html
+var conversationTemplate string
+
+var tmpl = template.Must(template.New("conversation").Funcs(template.FuncMap{
+	"markdown": func(s string) template.HTML { return template.HTML(Markdown(s)) },
+	"code":     func(s, lang string) template.HTML { return template.HTML(CodeBlock(s, lang)) },
+	"time": func(ms int64) string {
+		if ms == 0 {
+			return ""
+		}
+		return time.UnixMilli(ms).Format("2006-01-02 15:04")
+	},
+}).Parse(conversationTemplate))
+
+// HTML writes a session as a single self-contained HTML page with rendered
+// markdown, highlighted code and collapsible tool calls
+func HTML(w io.Writer, session *storage.StoredSession) error {
+	return tmpl.Execute(w, map[string]any{
+		"Session":    session,
+		"ExportedAt": time.Now().Format("2006-01-02 15:04"),
+	})
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// keywords is a union of common keywords; good enough for transcripts
+var keywords = toSet(`func function def class return if else elif for while do switch case
+break continue import from package const let var type struct interface new try catch
+finally throw throws raise async await yield in of and or not is None True False nil null
+undefined true false this self super public private protected static void go defer select
+chan map range export default extends implements enum match fn pub use mod impl where as
+with lambda pass then fi done esac local echo`)
+
+// hashCommentLangs use # for line comments
+var hashCommentLangs = toSet(`python py sh bash shell zsh fish ruby rb yaml yml toml perl r
+dockerfile makefile make ini conf properties`)
+
+func toSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// CodeBlock renders a fenced code block with basic syntax highlighting
+func CodeBlock(code, lang string) string {
+	if fields := strings.Fields(lang); len(fields) > 0 {
+		lang = strings.ToLower(fields[0])
+	}
+	var body string
+	if lang == "" || lang == "text" || lang == "txt" || lang == "plaintext" {
+		body = html.EscapeString(code)
+	} else {
+		body = highlight(code, lang)
+	}
+
+	label := ""
+	if lang != "" {
+		label = `<span class="lang">` + html.EscapeString(lang) + `</span>`
+	}
+	return `<pre class="code">` + label + `<code>` + body + "</code></pre>\n"
+}
+
+// highlight tokenizes code into comments, strings, numbers and keywords
+func highlight(code, lang string) string {
+	hash := hashCommentLangs[lang]
+	slash := !hash && lang != "json"
+	src := []rune(code)
+	var b strings.Builder
+
+	span := func(class string, s []rune) {
+		b.WriteString(`<span class="` + class + `">` + html.EscapeString(string(s)) + `</span>`)
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		rest := string(src[i:min(i+2, len(src))])
+
+		switch {
+		case (hash && c == '#') || (slash && rest == "//"):
+			j := i
+			for j < len(src) && src[j] != '\n' {
+				j++
+			}
+			span("c", src[i:j])
+			i = j
+
+		case slash && rest == "/*":
+			j := i + 2
+			for j+1 < len(src) && !(src[j] == '*' && src[j+1] == '/') {
+				j++
+			}
+			j = min(j+2, len(src))
+			span("c", src[i:j])
+			i = j
+
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(src) && src[j] != c && (c == '`' || src[j] != '\n') {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(src) && src[j] == c {
+				j++ // Closing quote
+			}
+			j = min(j, len(src))
+			span("s", src[i:j])
+			i = j
+
+		case unicode.IsDigit(c) && (i == 0 || !isIdent(src[i-1])):
+			j := i
+			for j < len(src) && (isIdent(src[j]) || src[j] == '.') {
+				j++
+			}
+			span("n", src[i:j])
+			i = j
+
+		case isIdent(c):
+			j := i
+			for j < len(src) && isIdent(src[j]) {
+				j++
+			}
+			if word := string(src[i:j]); keywords[word] {
+				span("k", src[i:j])
+			} else {
+				b.WriteString(html.EscapeString(word))
+			}
+			i = j
+
+		default:
+			b.WriteString(html.EscapeString(string(c)))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
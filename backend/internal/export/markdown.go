@@ -0,0 +1,219 @@
+package export
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	ulItemRe    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	olItemRe    = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	hrRe        = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	tableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	codeSpanRe  = regexp.MustCompile("`([^`]+)`")
+	linkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe      = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	italicRe    = regexp.MustCompile(`\*([^*\s][^*]*?)\*`)
+	strikeRe    = regexp.MustCompile(`~~(.+?)~~`)
+	placeholder = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// Markdown renders the subset of Markdown agents commonly produce (headings,
+// lists, quotes, tables, fenced code and inline formatting) to HTML.
+// All text is escaped; raw HTML in the source is not passed through.
+func Markdown(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var b strings.Builder
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence := trimmed[:3]
+			lang := strings.TrimSpace(trimmed[3:])
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			i++ // Closing fence
+			b.WriteString(CodeBlock(strings.Join(code, "\n"), lang))
+
+		case headingRe.MatchString(trimmed):
+			m := headingRe.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case hrRe.MatchString(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, strings.TrimPrefix(q, " "))
+			}
+			b.WriteString("<blockquote>\n" + Markdown(strings.Join(quote, "\n")) + "</blockquote>\n")
+
+		case ulItemRe.MatchString(line) || olItemRe.MatchString(line):
+			i = renderList(&b, lines, i)
+
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableSepRe.MatchString(lines[i+1]):
+			i = renderTable(&b, lines, i)
+
+		default:
+			var para []string
+			for ; i < len(lines) && startsParagraph(lines, i); i++ {
+				para = append(para, inline(strings.TrimSpace(lines[i])))
+			}
+			b.WriteString("<p>" + strings.Join(para, "<br>\n") + "</p>\n")
+		}
+	}
+	return b.String()
+}
+
+// startsParagraph reports whether line i continues a paragraph
+func startsParagraph(lines []string, i int) bool {
+	line := lines[i]
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" &&
+		!strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") &&
+		!strings.HasPrefix(trimmed, ">") &&
+		!headingRe.MatchString(trimmed) && !hrRe.MatchString(trimmed) &&
+		!ulItemRe.MatchString(line) && !olItemRe.MatchString(line)
+}
+
+// renderList renders consecutive list items; indented items form nested lists
+func renderList(b *strings.Builder, lines []string, i int) int {
+	ordered := !ulItemRe.MatchString(lines[i])
+	indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " \t"))
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	open := false
+	for i < len(lines) {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			// A blank line ends the list unless another item follows
+			if i+1 < len(lines) && (ulItemRe.MatchString(lines[i+1]) || olItemRe.MatchString(lines[i+1])) {
+				i++
+				continue
+			}
+			break
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+		isItem := ulItemRe.MatchString(line) || olItemRe.MatchString(line)
+
+		switch {
+		case isItem && lineIndent > indent:
+			i = renderList(b, lines, i)
+			continue
+		case isItem && (lineIndent < indent || olItemRe.MatchString(line) != ordered):
+			goto done
+		case isItem:
+			if open {
+				b.WriteString("</li>\n")
+			}
+			m := ulItemRe.FindStringSubmatch(line)
+			if m == nil {
+				m = olItemRe.FindStringSubmatch(line)
+			}
+			b.WriteString("<li>" + inline(m[1]))
+			open = true
+		case lineIndent > indent:
+			b.WriteString("<br>" + inline(strings.TrimSpace(line)))
+		default:
+			goto done
+		}
+		i++
+	}
+done:
+	if open {
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderTable renders a GFM pipe table starting at the header line
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	b.WriteString("<table>\n<thead><tr>")
+	for _, cell := range tableCells(lines[i]) {
+		b.WriteString("<th>" + inline(cell) + "</th>")
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+		b.WriteString("<tr>")
+		for _, cell := range tableCells(lines[i]) {
+			b.WriteString("<td>" + inline(cell) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(strings.TrimSuffix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells
+}
+
+// inline renders code spans, links and emphasis within one line
+func inline(s string) string {
+	// Protect code spans from further formatting, behind NUL delimited
+	// placeholders; NULs in the text itself are dropped so they can't forge one
+	s = strings.ReplaceAll(s, "\x00", "")
+	var codes []string
+	s = codeSpanRe.ReplaceAllStringFunc(s, func(m string) string {
+		codes = append(codes, m[1:len(m)-1])
+		return "\x00" + strconv.Itoa(len(codes)-1) + "\x00"
+	})
+
+	s = html.EscapeString(s)
+	s = linkRe.ReplaceAllStringFunc(s, func(m string) string {
+		parts := linkRe.FindStringSubmatch(m)
+		if !safeURL(html.UnescapeString(parts[2])) {
+			return parts[1]
+		}
+		return `<a href="` + parts[2] + `">` + parts[1] + `</a>`
+	})
+	s = boldRe.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = italicRe.ReplaceAllString(s, "<em>$1</em>")
+	s = strikeRe.ReplaceAllString(s, "<del>$1</del>")
+
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		n, err := strconv.Atoi(strings.Trim(m, "\x00"))
+		if err != nil || n >= len(codes) {
+			return ""
+		}
+		return "<code>" + html.EscapeString(codes[n]) + "</code>"
+	})
+}
+
+// safeURL allows web links and relative paths but not javascript: and friends
+func safeURL(u string) bool {
+	lower := strings.ToLower(u)
+	for _, scheme := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return !strings.Contains(lower, ":")
+}
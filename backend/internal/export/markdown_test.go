@@ -0,0 +1,28 @@
+package export
+
+import "testing"
+
+func TestInline(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"run `go test` **now**", "run <code>go test</code> <strong>now</strong>"},
+		{"`a<b>` and `c`", "<code>a&lt;b&gt;</code> and <code>c</code>"},
+		{"[docs](https://example.com) [x](javascript:void)", `<a href="https://example.com">docs</a> x`},
+		// NULs in the text can't pass for code span placeholders
+		{"\x007\x00 and \x000\x00", "7 and 0"},
+		{"`a` \x005\x00", "<code>a</code> 5"},
+	}
+	for _, tt := range tests {
+		if got := inline(tt.in); got != tt.want {
+			t.Errorf("inline(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownWithNULs(t *testing.T) {
+	got := Markdown("# title\n\n- item \x0042\x00\n\n| a | `b` |\n|---|---|\n| \x001\x00 | c |")
+	if got == "" {
+		t.Fatal("nothing rendered")
+	}
+}
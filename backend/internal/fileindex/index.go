@@ -0,0 +1,178 @@
+// Package fileindex keeps in-memory file lists of active workspaces so file
+// search does not walk the whole tree on every keystroke.
+package fileindex
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+var logger = logging.Component("fileindex")
+
+const (
+	refreshInterval = 30 * time.Second // Rescan period of indexes without a watcher
+	idleTimeout     = 10 * time.Minute // Drop indexes of inactive workspaces
+	maxFiles        = 200000           // Stop indexing huge trees
+)
+
+// skipDirs are never indexed
+var skipDirs = map[string]bool{
+	".git":            true,
+	"node_modules":    true,
+	".idea":           true,
+	".vscode":         true,
+	"vendor":          true,
+	"dist":            true,
+	"build":           true,
+	"__pycache__":     true,
+	".next":           true,
+	".nuxt":           true,
+	"coverage":        true,
+	".cache":          true,
+	".acpone-uploads": true, // Chat attachments
+}
+
+// File is an indexed workspace file
+type File struct {
+	Path    string    // Slash separated path relative to the workspace root
+	Name    string    // Base name
+	ModTime time.Time // Last modification
+}
+
+// Status describes an index for the API
+type Status struct {
+	Root      string    `json:"root"`
+	Files     int       `json:"files"`
+	BuiltAt   time.Time `json:"builtAt"`
+	BuildMs   int64     `json:"buildMs"`
+	Truncated bool      `json:"truncated"`
+}
+
+// index is the file list of one workspace root
+type index struct {
+	root   string
+	ignore []string
+
+	mu        sync.RWMutex
+	files     []File
+	builtAt   time.Time
+	buildTime time.Duration
+	truncated bool
+	lastUsed  time.Time
+	watcher   *fsnotify.Watcher // Nil when rescanned periodically instead
+	buildMu   sync.Mutex        // Serializes rebuilds
+}
+
+// build rescans the tree and swaps in the new file list
+func (ix *index) build() {
+	ix.buildMu.Lock()
+	defer ix.buildMu.Unlock()
+
+	start := time.Now()
+	files, dirs, truncated := scan(ix.root, ix.ignore)
+
+	ix.mu.Lock()
+	ix.files = files
+	ix.builtAt = time.Now()
+	ix.buildTime = time.Since(start)
+	ix.truncated = truncated
+	ix.mu.Unlock()
+
+	if truncated {
+		logger.Warn("index stopped at file limit", "root", ix.root, "files", maxFiles)
+	}
+	ix.watchDirs(dirs)
+}
+
+func (ix *index) status() Status {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return Status{
+		Root:      ix.root,
+		Files:     len(ix.files),
+		BuiltAt:   ix.builtAt,
+		BuildMs:   ix.buildTime.Milliseconds(),
+		Truncated: ix.truncated,
+	}
+}
+
+// scan walks root and returns its files and the directories walked,
+// skipping ignored paths
+func scan(root string, ignore []string) ([]File, []string, bool) {
+	var files []File
+	var dirs []string
+	truncated := false
+
+	filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+
+		relPath, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		if relPath == "." {
+			dirs = append(dirs, p)
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if Ignored(ignore, relPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if skipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, p)
+			return nil
+		}
+
+		if len(files) >= maxFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		files = append(files, File{Path: relPath, Name: info.Name(), ModTime: info.ModTime()})
+		return nil
+	})
+
+	return files, dirs, truncated
+}
+
+// Ignored reports whether a workspace-relative path matches an ignore pattern.
+// Patterns match the full slash-separated path or any single path element;
+// a trailing slash is ignored, so "tmp/" hides the tmp directory.
+func Ignored(patterns []string, relPath string) bool {
+	relPath = strings.ReplaceAll(relPath, "\\", "/")
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(pattern, relPath); ok {
+			return true
+		}
+		if strings.Contains(pattern, "/") {
+			continue
+		}
+		for _, part := range strings.Split(relPath, "/") {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
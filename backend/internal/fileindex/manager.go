@@ -0,0 +1,129 @@
+package fileindex
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Manager owns the indexes of active workspaces and keeps them current with
+// filesystem watchers. Indexes are created on first use and dropped when idle.
+type Manager struct {
+	mu       sync.Mutex
+	indexes  map[string]*index
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewManager creates a manager and starts its refresh loop, which drops idle
+// indexes and rescans those without a watcher
+func NewManager() *Manager {
+	m := &Manager{
+		indexes: make(map[string]*index),
+		stop:    make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+// Files returns the indexed files of root. The first call for a root (or
+// after its ignore patterns changed) builds the index synchronously.
+func (m *Manager) Files(root string, ignore []string) []File {
+	ix := m.get(root, ignore)
+
+	ix.mu.Lock()
+	ix.lastUsed = time.Now()
+	built := !ix.builtAt.IsZero()
+	ix.mu.Unlock()
+	if !built {
+		ix.build()
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.files
+}
+
+// Reindex rebuilds the index of root immediately
+func (m *Manager) Reindex(root string, ignore []string) Status {
+	ix := m.get(root, ignore)
+	ix.mu.Lock()
+	ix.lastUsed = time.Now()
+	ix.mu.Unlock()
+	ix.build()
+	return ix.status()
+}
+
+// Statuses reports all active indexes
+func (m *Manager) Statuses() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]Status, 0, len(m.indexes))
+	for _, ix := range m.indexes {
+		statuses = append(statuses, ix.status())
+	}
+	return statuses
+}
+
+// Stop ends the refresh loop and closes the watchers
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for root, ix := range m.indexes {
+			ix.unwatch()
+			delete(m.indexes, root)
+		}
+	})
+}
+
+// get returns the index of root, replacing it when the ignore patterns changed
+func (m *Manager) get(root string, ignore []string) *index {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ix, ok := m.indexes[root]
+	if !ok || !slices.Equal(ix.ignore, ignore) {
+		if ok {
+			ix.unwatch()
+		}
+		ix = &index{root: root, ignore: slices.Clone(ignore)}
+		ix.watch()
+		m.indexes[root] = ix
+	}
+	return ix
+}
+
+// loop drops idle indexes and rescans the active ones without a watcher
+func (m *Manager) loop() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+
+		var active []*index
+		m.mu.Lock()
+		for root, ix := range m.indexes {
+			ix.mu.RLock()
+			idle := time.Since(ix.lastUsed) > idleTimeout
+			watched := ix.watcher != nil
+			ix.mu.RUnlock()
+			if idle {
+				ix.unwatch()
+				delete(m.indexes, root)
+			} else if !watched {
+				active = append(active, ix)
+			}
+		}
+		m.mu.Unlock()
+
+		for _, ix := range active {
+			ix.build()
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package fileindex
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// paths returns the sorted paths of files
+func paths(files []File) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f.Path)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// waitFiles waits for the index of root to list want
+func waitFiles(t *testing.T, m *Manager, root string, ignore []string, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := paths(m.Files(root, ignore))
+		if slices.Equal(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("index lists %v, want %v", got, want)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexFollowsChanges(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "main.go"))
+	writeFile(t, filepath.Join(root, "node_modules", "dep.js"))
+	writeFile(t, filepath.Join(root, "tmp", "scratch.txt"))
+	ignore := []string{"tmp/"}
+
+	m := NewManager()
+	defer m.Stop()
+	waitFiles(t, m, root, ignore, "main.go")
+
+	// New files, also in directories created after the index was built
+	writeFile(t, filepath.Join(root, "pkg", "sub", "util.go"))
+	waitFiles(t, m, root, ignore, "main.go", "pkg/sub/util.go")
+	writeFile(t, filepath.Join(root, "pkg", "sub", "more.go"))
+	waitFiles(t, m, root, ignore, "main.go", "pkg/sub/more.go", "pkg/sub/util.go")
+
+	// Renames and removals
+	if err := os.Rename(filepath.Join(root, "main.go"), filepath.Join(root, "app.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	waitFiles(t, m, root, ignore, "app.go")
+
+	if m.get(root, ignore).watching(nil) {
+		t.Error("index fell back to rescanning")
+	}
+}
+
+func TestIndexReplacedWhenIgnoreChanges(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.go"))
+	writeFile(t, filepath.Join(root, "b.log"))
+
+	m := NewManager()
+	defer m.Stop()
+	waitFiles(t, m, root, nil, "a.go", "b.log")
+	old := m.get(root, nil)
+	waitFiles(t, m, root, []string{"*.log"}, "a.go")
+
+	if !old.watching(nil) {
+		t.Error("replaced index kept its watcher")
+	}
+	m.Stop()
+	if len(m.Statuses()) != 0 {
+		t.Error("stopped manager kept its indexes")
+	}
+}
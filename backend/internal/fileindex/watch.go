@@ -0,0 +1,111 @@
+package fileindex
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay coalesces a burst of changes, like a checkout or a build, into
+// one rebuild
+const watchDelay = time.Second
+
+// watch follows changes under the index root with a filesystem watcher.
+// Without one the index is rescanned periodically instead.
+func (ix *index) watch() {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Warn("file watcher unavailable, rescanning periodically", "root", ix.root, "error", err)
+		return
+	}
+	ix.mu.Lock()
+	ix.watcher = w
+	ix.mu.Unlock()
+	go ix.follow(w)
+}
+
+// watching reports whether w is still the index's watcher
+func (ix *index) watching(w *fsnotify.Watcher) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.watcher == w
+}
+
+// watchDirs adds the directories found by a rebuild to the watcher, which
+// is not recursive; watches of removed directories end by themselves.
+// Running out of watches (the inotify limit) switches the index to
+// periodic rescans.
+func (ix *index) watchDirs(dirs []string) {
+	ix.mu.RLock()
+	w := ix.watcher
+	ix.mu.RUnlock()
+	if w == nil {
+		return
+	}
+	for _, dir := range dirs {
+		err := w.Add(dir)
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if !errors.Is(err, fsnotify.ErrClosed) {
+			logger.Warn("cannot watch directory, rescanning periodically", "root", ix.root, "dir", dir, "error", err)
+			ix.unwatch()
+		}
+		return
+	}
+}
+
+// unwatch closes the watcher, leaving the index to periodic rescans
+func (ix *index) unwatch() {
+	ix.mu.Lock()
+	w := ix.watcher
+	ix.watcher = nil
+	ix.mu.Unlock()
+	if w != nil {
+		w.Close()
+	}
+}
+
+// follow reads the watcher's events until it is closed, rebuilding the
+// index a moment after each burst of changes. Rebuilds run apart from the
+// event loop, which must keep draining events while they add watches.
+func (ix *index) follow(w *fsnotify.Watcher) {
+	changed := make(chan struct{}, 1)
+	defer close(changed)
+	go func() {
+		for range changed {
+			time.Sleep(watchDelay)
+			select {
+			case <-changed:
+			default:
+			}
+			if ix.watching(w) {
+				ix.build()
+			}
+		}
+	}()
+
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been lost (queue overflow); the rebuild catches up
+			logger.Debug("file watcher error", "root", ix.root, "error", err)
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package fuzzy implements fzf-style fuzzy matching for file paths.
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scoring weights (modeled after fzf)
+const (
+	scoreMatch        = 16
+	bonusBoundary     = 8  // After '_', '-', '.', ' '
+	bonusPathBoundary = 10 // After '/' or at the start
+	bonusCamel        = 7  // lowerUpper or letterDigit transition
+	bonusConsecutive  = 6
+	penaltyGapStart   = 3
+	penaltyGapExtend  = 1
+)
+
+// Score matches pattern as a case-insensitive subsequence of text and
+// returns the best score; ok is false when pattern does not match.
+// An empty pattern matches everything with score 0.
+func Score(pattern, text string) (score int, ok bool) {
+	p := []rune(pattern)
+	t := []rune(text)
+	if len(p) == 0 {
+		return 0, true
+	}
+	if len(p) > len(t) {
+		return 0, false
+	}
+
+	lp := make([]rune, len(p))
+	for i, r := range p {
+		lp[i] = unicode.ToLower(r)
+	}
+	lt := make([]rune, len(t))
+	bonus := make([]int, len(t))
+	for j, r := range t {
+		lt[j] = unicode.ToLower(r)
+		bonus[j] = charBonus(t, j)
+	}
+
+	const none = -1 << 30
+	// prev[j]: best score with pattern[i-1] matched at text[j]
+	prev := make([]int, len(t))
+	cur := make([]int, len(t))
+	for j := range prev {
+		prev[j] = none
+		if lt[j] == lp[0] {
+			prev[j] = scoreMatch + bonus[j]*2 // First char bonus counts double
+		}
+	}
+
+	for i := 1; i < len(p); i++ {
+		// best holds the best prev[k] - gap penalty for k < j-1
+		best := none
+		for j := range cur {
+			cur[j] = none
+			if j >= 2 && prev[j-2] > none {
+				// Extend the candidate gap by one char, or start a new gap at j-2
+				best = max(best-penaltyGapExtend, prev[j-2]-penaltyGapStart)
+			} else if best > none {
+				best -= penaltyGapExtend
+			}
+			if lt[j] != lp[i] || j == 0 {
+				continue
+			}
+			s := none
+			if prev[j-1] > none {
+				s = prev[j-1] + scoreMatch + max(bonus[j], bonusConsecutive)
+			}
+			if best > none {
+				s = max(s, best+scoreMatch+bonus[j])
+			}
+			cur[j] = s
+		}
+		prev, cur = cur, prev
+	}
+
+	score = none
+	for _, s := range prev {
+		score = max(score, s)
+	}
+	if score == none {
+		return 0, false
+	}
+	return score, true
+}
+
+// charBonus rewards matches at word and path boundaries
+func charBonus(t []rune, j int) int {
+	if j == 0 {
+		return bonusPathBoundary
+	}
+	prev, cur := t[j-1], t[j]
+	switch {
+	case prev == '/' || prev == '\\':
+		return bonusPathBoundary
+	case prev == '_' || prev == '-' || prev == '.' || prev == ' ':
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusCamel
+	case unicode.IsLetter(prev) && unicode.IsDigit(cur):
+		return bonusCamel
+	}
+	return 0
+}
+
+// bonusBasename favours matches that fall entirely within the file name
+const bonusBasename = 12
+
+// ScorePath scores pattern against a slash separated path, preferring
+// matches inside the base name over matches spread across directories
+func ScorePath(pattern, path string) (int, bool) {
+	score, ok := Score(pattern, path)
+	if !ok {
+		return 0, false
+	}
+	// A file at the root is all base name
+	base := path[strings.LastIndex(path, "/")+1:]
+	if s, ok := Score(pattern, base); ok {
+		score = max(score, s+bonusBasename)
+	}
+	return score, true
+}
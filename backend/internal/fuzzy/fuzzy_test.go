@@ -0,0 +1,60 @@
+package fuzzy
+
+import "testing"
+
+func TestScoreMatches(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		ok            bool
+	}{
+		{"", "anything", true},
+		{"abc", "abc", true},
+		{"ABC", "aXbXc", true},
+		{"mgo", "internal/api/manager.go", true},
+		{"cba", "abc", false},
+		{"abcd", "abc", false},
+		{"x", "", false},
+	}
+	for _, tt := range tests {
+		if _, ok := Score(tt.pattern, tt.text); ok != tt.ok {
+			t.Errorf("Score(%q, %q) ok = %v, want %v", tt.pattern, tt.text, ok, tt.ok)
+		}
+	}
+}
+
+func TestScoreRanking(t *testing.T) {
+	// Each pair: the pattern should score the first text higher
+	tests := []struct {
+		pattern, better, worse string
+	}{
+		{"main", "main.go", "mail_index.go"},      // Consecutive over scattered
+		{"fb", "foo_bar.go", "fabric.go"},         // Word boundaries
+		{"fb", "fooBar.go", "fabric.go"},          // camelCase boundaries
+		{"sg", "src/go.mod", "sag.txt"},           // Path boundaries
+		{"cfg", "cfg.go", "cafeteria_log.go"},     // No gaps over long gaps
+		{"readme", "README.md", "threadmemo.txt"}, // Case-insensitive, at the start
+	}
+	for _, tt := range tests {
+		b, okB := Score(tt.pattern, tt.better)
+		w, okW := Score(tt.pattern, tt.worse)
+		if !okB || !okW || b <= w {
+			t.Errorf("Score(%q): %q = %d, %q = %d; want the first higher", tt.pattern, tt.better, b, tt.worse, w)
+		}
+	}
+}
+
+func TestScorePathPrefersBaseName(t *testing.T) {
+	inName, _ := ScorePath("user", "internal/api/user.go")
+	acrossDirs, _ := ScorePath("user", "ui/src/e/r.go")
+	if inName <= acrossDirs {
+		t.Errorf("match in the base name %d, across directories %d; want the first higher", inName, acrossDirs)
+	}
+	atRoot, _ := ScorePath("user", "user.go")
+	nested, _ := ScorePath("user", "internal/api/user.go")
+	if atRoot < nested {
+		t.Errorf("file at the root %d, nested %d; want the base name bonus for both", atRoot, nested)
+	}
+	if _, ok := ScorePath("zzz", "internal/api/user.go"); ok {
+		t.Error("ScorePath matched a pattern not in the path")
+	}
+}
@@ -0,0 +1,173 @@
+// Package github fetches GitHub issues to start conversations from.
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIBase is the GitHub REST API root, changeable for GitHub Enterprise
+var APIBase = "https://api.github.com"
+
+// maxComments caps the comments fetched with an issue
+const maxComments = 50
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// IssueRef identifies an issue
+type IssueRef struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// Repository returns "owner/repo"
+func (r IssueRef) Repository() string {
+	return r.Owner + "/" + r.Repo
+}
+
+func (r IssueRef) String() string {
+	return fmt.Sprintf("%s#%d", r.Repository(), r.Number)
+}
+
+// Issue is an issue with its discussion
+type Issue struct {
+	IssueRef
+	Title    string
+	Body     string
+	URL      string
+	Author   string
+	Comments []Comment
+}
+
+// Comment is one comment of an issue
+type Comment struct {
+	Author string
+	Body   string
+}
+
+var (
+	issueURL   = regexp.MustCompile(`^https?://[^/]+/([\w.-]+)/([\w.-]+)/(?:issues|pull)/(\d+)`)
+	issueShort = regexp.MustCompile(`^(?:([\w.-]+)/([\w.-]+))?#(\d+)$`)
+)
+
+// ParseIssue parses an issue URL, "owner/repo#123" or "#123". The short
+// form leaves Owner and Repo empty for the caller to fill in.
+func ParseIssue(s string) (IssueRef, error) {
+	s = strings.TrimSpace(s)
+	m := issueURL.FindStringSubmatch(s)
+	if m == nil {
+		m = issueShort.FindStringSubmatch(s)
+	}
+	if m == nil {
+		return IssueRef{}, fmt.Errorf("not a GitHub issue: %q", s)
+	}
+	n, _ := strconv.Atoi(m[3])
+	return IssueRef{Owner: m[1], Repo: strings.TrimSuffix(m[2], ".git"), Number: n}, nil
+}
+
+var remoteURL = regexp.MustCompile(`github\.com[:/]([\w.-]+)/([\w.-]+?)(?:\.git)?/?$`)
+
+// RepoFromRemote returns "owner/repo" of a GitHub git remote URL, or ""
+func RepoFromRemote(remote string) string {
+	m := remoteURL.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil {
+		return ""
+	}
+	return m[1] + "/" + m[2]
+}
+
+// FetchIssue reads an issue and its comments. GITHUB_TOKEN or GH_TOKEN is
+// used when set, which private repositories require.
+func FetchIssue(ctx context.Context, ref IssueRef) (*Issue, error) {
+	var raw struct {
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+		User    struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Comments int `json:"comments"`
+	}
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d", ref.Owner, ref.Repo, ref.Number)
+	if err := get(ctx, path, &raw); err != nil {
+		return nil, err
+	}
+
+	issue := &Issue{
+		IssueRef: ref,
+		Title:    raw.Title,
+		Body:     raw.Body,
+		URL:      raw.HTMLURL,
+		Author:   raw.User.Login,
+	}
+	if raw.Comments == 0 {
+		return issue, nil
+	}
+
+	var comments []struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := get(ctx, fmt.Sprintf("%s/comments?per_page=%d", path, maxComments), &comments); err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		issue.Comments = append(issue.Comments, Comment{Author: c.User.Login, Body: c.Body})
+	}
+	return issue, nil
+}
+
+func get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(APIBase, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var data struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&data)
+		return fmt.Errorf("github: %s: %s", resp.Status, data.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Prompt formats the issue as the first message of a conversation
+func (i *Issue) Prompt() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Fix GitHub issue %s: %s\n%s\n", i, i.Title, i.URL)
+	if body := strings.TrimSpace(i.Body); body != "" {
+		fmt.Fprintf(&b, "\n%s\n", body)
+	}
+	if len(i.Comments) > 0 {
+		b.WriteString("\n## Comments\n")
+		for _, c := range i.Comments {
+			fmt.Fprintf(&b, "\n**%s**:\n%s\n", c.Author, strings.TrimSpace(c.Body))
+		}
+	}
+	return b.String()
+}
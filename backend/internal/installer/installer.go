@@ -0,0 +1,438 @@
+// Package installer checks, installs and updates agents distributed outside
+// npm: Python tools (uv, pipx), Rust crates, downloaded binaries and
+// Docker images. Each source type is an Installer.
+package installer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// Installer manages one agent package
+type Installer interface {
+	// Tool is the command the installer runs, e.g. "uv", or "" when none
+	Tool() string
+	// Check reports whether the package is installed
+	Check(ctx context.Context) bool
+	// Install installs the package, sending output lines to logFn
+	Install(ctx context.Context, logFn func(string)) error
+	// Update installs the latest (or pinned) version
+	Update(ctx context.Context, logFn func(string)) error
+	// Version returns the installed version, or "" when unknown
+	Version(ctx context.Context) string
+	// Latest returns the newest published version, or "" when it can't be
+	// looked up or the version is pinned
+	Latest(ctx context.Context) string
+	// String describes the package, e.g. "uvx my-acp"
+	String() string
+}
+
+// ToolInstructions tells users where to get each installer's tool
+var ToolInstructions = map[string]string{
+	"uv":     "https://docs.astral.sh/uv/getting-started/installation/",
+	"pipx":   "https://pipx.pypa.io/stable/installation/",
+	"cargo":  "https://rustup.rs",
+	"docker": "https://docs.docker.com/get-docker/",
+}
+
+// New returns the installer for an agent whose command is command
+func New(cfg *config.InstallConfig, command string) (Installer, error) {
+	pkg := cfg.Package
+	if pkg == "" {
+		pkg = filepath.Base(command)
+	}
+	switch cfg.Type {
+	case config.InstallUvx:
+		return &uvInstaller{pkg: pkg, version: cfg.Version}, nil
+	case config.InstallPipx:
+		return &pipxInstaller{pkg: pkg, version: cfg.Version}, nil
+	case config.InstallCargo:
+		return &cargoInstaller{pkg: pkg, version: cfg.Version}, nil
+	case config.InstallDocker:
+		image := pkg
+		if cfg.Version != "" && !strings.Contains(image, ":") {
+			image += ":" + cfg.Version
+		}
+		return &dockerInstaller{image: image}, nil
+	case config.InstallBinary:
+		name := filepath.Base(command)
+		if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+			name += ".exe"
+		}
+		return &binaryInstaller{name: name, url: expandURL(cfg.URL, cfg.Version)}, nil
+	}
+	return nil, fmt.Errorf("unknown install type: %s", cfg.Type)
+}
+
+// versioned returns pkg with a version suffix in a tool's syntax
+func versioned(pkg, version, sep string) string {
+	if version == "" {
+		return pkg
+	}
+	return pkg + sep + version
+}
+
+// uvInstaller installs Python tools with `uv tool install`, which is what
+// `uvx` caches ephemerally
+type uvInstaller struct{ pkg, version string }
+
+func (u *uvInstaller) Tool() string   { return "uv" }
+func (u *uvInstaller) String() string { return "uvx " + u.pkg }
+
+func (u *uvInstaller) Check(ctx context.Context) bool {
+	out, err := output(ctx, "uv", "tool", "list")
+	return err == nil && listed(out, u.pkg+" ")
+}
+
+func (u *uvInstaller) Install(ctx context.Context, logFn func(string)) error {
+	return run(ctx, logFn, "uv", "tool", "install", versioned(u.pkg, u.version, "=="))
+}
+
+func (u *uvInstaller) Update(ctx context.Context, logFn func(string)) error {
+	if u.version != "" {
+		return run(ctx, logFn, "uv", "tool", "install", "--force", versioned(u.pkg, u.version, "=="))
+	}
+	return run(ctx, logFn, "uv", "tool", "upgrade", u.pkg)
+}
+
+// Version reads `uv tool list`, whose lines look like "my-acp v1.2.0"
+func (u *uvInstaller) Version(ctx context.Context) string {
+	out, _ := output(ctx, "uv", "tool", "list")
+	return listedVersion(out, u.pkg)
+}
+
+func (u *uvInstaller) Latest(ctx context.Context) string {
+	if u.version != "" {
+		return ""
+	}
+	return pypiLatest(ctx, u.pkg)
+}
+
+// pipxInstaller installs Python tools with pipx
+type pipxInstaller struct{ pkg, version string }
+
+func (p *pipxInstaller) Tool() string   { return "pipx" }
+func (p *pipxInstaller) String() string { return "pipx " + p.pkg }
+
+func (p *pipxInstaller) Check(ctx context.Context) bool {
+	out, err := output(ctx, "pipx", "list", "--short")
+	return err == nil && listed(out, p.pkg+" ")
+}
+
+func (p *pipxInstaller) Install(ctx context.Context, logFn func(string)) error {
+	return run(ctx, logFn, "pipx", "install", versioned(p.pkg, p.version, "=="))
+}
+
+func (p *pipxInstaller) Update(ctx context.Context, logFn func(string)) error {
+	if p.version != "" {
+		return run(ctx, logFn, "pipx", "install", "--force", versioned(p.pkg, p.version, "=="))
+	}
+	return run(ctx, logFn, "pipx", "upgrade", p.pkg)
+}
+
+// Version reads `pipx list --short`, whose lines look like "my-acp 1.2.0"
+func (p *pipxInstaller) Version(ctx context.Context) string {
+	out, _ := output(ctx, "pipx", "list", "--short")
+	return listedVersion(out, p.pkg)
+}
+
+func (p *pipxInstaller) Latest(ctx context.Context) string {
+	if p.version != "" {
+		return ""
+	}
+	return pypiLatest(ctx, p.pkg)
+}
+
+// cargoInstaller installs Rust crates with `cargo install`
+type cargoInstaller struct{ pkg, version string }
+
+func (c *cargoInstaller) Tool() string   { return "cargo" }
+func (c *cargoInstaller) String() string { return "cargo " + c.pkg }
+
+func (c *cargoInstaller) Check(ctx context.Context) bool {
+	out, err := output(ctx, "cargo", "install", "--list")
+	return err == nil && listed(out, c.pkg+" v")
+}
+
+func (c *cargoInstaller) Install(ctx context.Context, logFn func(string)) error {
+	args := []string{"install", c.pkg}
+	if c.version != "" {
+		args = append(args, "--version", c.version)
+	}
+	return run(ctx, logFn, "cargo", args...)
+}
+
+// Update reinstalls when a newer version exists; cargo skips it otherwise
+func (c *cargoInstaller) Update(ctx context.Context, logFn func(string)) error {
+	return c.Install(ctx, logFn)
+}
+
+// Version reads `cargo install --list`, whose lines look like "my-acp v1.2.0:"
+func (c *cargoInstaller) Version(ctx context.Context) string {
+	out, _ := output(ctx, "cargo", "install", "--list")
+	return listedVersion(out, c.pkg)
+}
+
+func (c *cargoInstaller) Latest(ctx context.Context) string {
+	if c.version != "" {
+		return ""
+	}
+	var resp struct {
+		Crate struct {
+			MaxStableVersion string `json:"max_stable_version"`
+		} `json:"crate"`
+	}
+	if err := getJSON(ctx, "https://crates.io/api/v1/crates/"+c.pkg, &resp); err != nil {
+		return ""
+	}
+	return resp.Crate.MaxStableVersion
+}
+
+// dockerInstaller pulls an image the agent runs with `docker run`
+type dockerInstaller struct{ image string }
+
+func (d *dockerInstaller) Tool() string   { return "docker" }
+func (d *dockerInstaller) String() string { return "docker " + d.image }
+
+func (d *dockerInstaller) Check(ctx context.Context) bool {
+	_, err := output(ctx, "docker", "image", "inspect", d.image)
+	return err == nil
+}
+
+func (d *dockerInstaller) Install(ctx context.Context, logFn func(string)) error {
+	return run(ctx, logFn, "docker", "pull", d.image)
+}
+
+func (d *dockerInstaller) Update(ctx context.Context, logFn func(string)) error {
+	return d.Install(ctx, logFn)
+}
+
+// Version returns the image's OCI version label, which not every image sets
+func (d *dockerInstaller) Version(ctx context.Context) string {
+	out, err := output(ctx, "docker", "image", "inspect", "--format",
+		`{{index .Config.Labels "org.opencontainers.image.version"}}`, d.image)
+	if err != nil {
+		return ""
+	}
+	return ParseVersion(out)
+}
+
+// Latest is unknown without querying the registry; Update pulls the tag again
+func (d *dockerInstaller) Latest(ctx context.Context) string { return "" }
+
+// BinDir is where downloaded agent binaries are installed, ~/.acpone/bin.
+// It is added to PATH by sysutil.RefreshPath.
+func BinDir() string {
+	return filepath.Join(sysutil.DataDir(), "bin")
+}
+
+// binaryInstaller downloads an executable into BinDir
+type binaryInstaller struct{ name, url string }
+
+func (b *binaryInstaller) Tool() string   { return "" }
+func (b *binaryInstaller) String() string { return "download " + b.name }
+
+func (b *binaryInstaller) Check(ctx context.Context) bool {
+	if _, err := os.Stat(filepath.Join(BinDir(), b.name)); err == nil {
+		return true
+	}
+	_, err := exec.LookPath(strings.TrimSuffix(b.name, ".exe"))
+	return err == nil
+}
+
+func (b *binaryInstaller) Install(ctx context.Context, logFn func(string)) error {
+	logFn("Downloading " + b.url)
+	req, err := http.NewRequestWithContext(ctx, "GET", b.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", b.url, resp.Status)
+	}
+
+	if err := os.MkdirAll(BinDir(), 0755); err != nil {
+		return err
+	}
+	// Download next to the target so a failed download keeps the old binary
+	tmp, err := os.CreateTemp(BinDir(), "."+b.name+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %w", b.url, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	target := filepath.Join(BinDir(), b.name)
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	logFn(fmt.Sprintf("Installed %s (%d bytes)", target, n))
+	return nil
+}
+
+func (b *binaryInstaller) Update(ctx context.Context, logFn func(string)) error {
+	return b.Install(ctx, logFn)
+}
+
+// Version runs the binary with --version
+func (b *binaryInstaller) Version(ctx context.Context) string {
+	path := filepath.Join(BinDir(), b.name)
+	if _, err := os.Stat(path); err != nil {
+		path = strings.TrimSuffix(b.name, ".exe")
+	}
+	out, _ := output(ctx, path, "--version")
+	return ParseVersion(out)
+}
+
+// Latest is unknown for a plain download URL
+func (b *binaryInstaller) Latest(ctx context.Context) string { return "" }
+
+// expandURL fills in the {os}, {arch} and {version} placeholders of a
+// binary download URL. {arch} is the machine's, so an emulated acpone still
+// installs native agents.
+func expandURL(url, version string) string {
+	return strings.NewReplacer(
+		"{os}", runtime.GOOS,
+		"{arch}", sysutil.HostArch(),
+		"{version}", version,
+	).Replace(url)
+}
+
+// versionPattern matches a version number such as 1.2.0 or 0.9.1-beta.2
+var versionPattern = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?(?:[-+][0-9A-Za-z.-]+)?`)
+
+// ParseVersion returns the first version number in a command's output,
+// e.g. "1.2.0" in "my-acp v1.2.0 (abc123)"
+func ParseVersion(out string) string {
+	return versionPattern.FindString(out)
+}
+
+// listedVersion returns the version on pkg's line of a tool's package list
+func listedVersion(out, pkg string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == pkg {
+			return ParseVersion(fields[1])
+		}
+	}
+	return ""
+}
+
+// pypiLatest returns the newest release of a Python package
+func pypiLatest(ctx context.Context, pkg string) string {
+	var resp struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := getJSON(ctx, "https://pypi.org/pypi/"+pkg+"/json", &resp); err != nil {
+		return ""
+	}
+	return resp.Info.Version
+}
+
+// getJSON fetches and decodes a JSON document
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	// crates.io rejects requests without a User-Agent
+	req.Header.Set("User-Agent", "acpone")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// listed reports whether a line of a tool's package list starts with prefix
+func listed(out, prefix string) bool {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line)+" ", prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// command creates a command in its own process group, so canceling ctx
+// kills it together with the processes it spawned
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	sysutil.HideWindow(cmd)
+	sysutil.SetProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return sysutil.KillTree(cmd.Process.Pid)
+	}
+	return cmd
+}
+
+func output(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := command(ctx, name, args...).Output()
+	return string(out), err
+}
+
+// run runs a command, sending each output line to logFn as it arrives
+func run(ctx context.Context, logFn func(string), name string, args ...string) error {
+	logFn("Running: " + name + " " + strings.Join(args, " "))
+	cmd := command(ctx, name, args...)
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	done := make(chan struct{})
+	var last string
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				last = line
+				logFn(line)
+			}
+		}
+	}()
+
+	err := cmd.Run()
+	pw.Close()
+	<-done
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		if last != "" {
+			return fmt.Errorf("%s failed: %s", name, last)
+		}
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
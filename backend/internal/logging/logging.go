@@ -0,0 +1,125 @@
+// Package logging is the server log: slog records tagged with the component
+// writing them, sent to stderr and to rotated files under ~/.acpone/logs.
+// The standard log package is routed through it too.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+var (
+	level = new(slog.LevelVar)
+	root  atomic.Pointer[slog.Handler] // Where records go, replaced by Setup
+)
+
+func init() {
+	setRoot(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(slog.New(handler{}))
+}
+
+func setRoot(h slog.Handler) {
+	root.Store(&h)
+}
+
+// Dir returns the directory of the log files, ~/.acpone/logs by default,
+// or "" when the config writes none
+func Dir(cfg *config.LogConfig) string {
+	switch {
+	case cfg == nil:
+	case cfg.NoFile:
+		return ""
+	case cfg.Dir != "":
+		return cfg.Dir
+	}
+	return filepath.Join(sysutil.DataDir(), "logs")
+}
+
+// Component returns the logger of a part of the server, which tags its
+// records with component=name. It may be created before Setup.
+func Component(name string) *slog.Logger {
+	return slog.New(handler{}).With("component", name)
+}
+
+// Setup applies the log config and returns a function closing the log
+// file. When the file can't be opened, logging continues on stderr only
+// and the error is returned.
+func Setup(cfg *config.LogConfig) (func(), error) {
+	if err := level.UnmarshalText([]byte(cfg.LevelName())); err != nil {
+		return func() {}, err
+	}
+
+	var out io.Writer = os.Stderr
+	closeFile := func() {}
+	var err error
+	if dir := Dir(cfg); dir != "" {
+		var f *rotatingFile
+		if f, err = openRotating(dir, cfg.MaxSize(), cfg.Files()); err == nil {
+			out = tee{f, os.Stderr}
+			closeFile = func() { f.Close() }
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.JSON() {
+		setRoot(slog.NewJSONHandler(out, opts))
+	} else {
+		setRoot(slog.NewTextHandler(out, opts))
+	}
+	return closeFile, err
+}
+
+// handler passes records to the current root handler, so loggers created
+// before Setup follow it
+type handler struct {
+	with []func(slog.Handler) slog.Handler // WithAttrs and WithGroup calls, in order
+}
+
+func (h handler) current() slog.Handler {
+	r := *root.Load()
+	for _, with := range h.with {
+		r = with(r)
+	}
+	return r
+}
+
+func (h handler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= level.Level()
+}
+
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.add(func(r slog.Handler) slog.Handler { return r.WithAttrs(attrs) })
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return h.add(func(r slog.Handler) slog.Handler { return r.WithGroup(name) })
+}
+
+func (h handler) add(with func(slog.Handler) slog.Handler) handler {
+	return handler{with: append(h.with[:len(h.with):len(h.with)], with)}
+}
+
+// tee writes to all writers, also when one fails, e.g. the stderr of a
+// desktop app without a console
+type tee []io.Writer
+
+func (t tee) Write(p []byte) (int, error) {
+	var err error
+	for _, w := range t {
+		if _, werr := w.Write(p); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return len(p), err
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// rotatingFile appends to <dir>/acpone.log, rotating it into acpone.1.log,
+// acpone.2.log, ... (higher is older) once it grows beyond maxSize
+type rotatingFile struct {
+	dir      string
+	maxSize  int64
+	maxFiles int // Current file plus rotated ones
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotating(dir string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := f.openCurrent(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends one record; handlers write each record with a single call,
+// so records are never split across files
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *rotatingFile) path(i int) string {
+	if i == 0 {
+		return filepath.Join(f.dir, "acpone.log")
+	}
+	return filepath.Join(f.dir, fmt.Sprintf("acpone.%d.log", i))
+}
+
+func (f *rotatingFile) openCurrent() error {
+	file, err := os.OpenFile(f.path(0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+	f.file = nil
+	os.Remove(f.path(f.maxFiles - 1))
+	for i := f.maxFiles - 2; i >= 0; i-- {
+		os.Rename(f.path(i), f.path(i+1))
+	}
+	return f.openCurrent()
+}
@@ -0,0 +1,272 @@
+// Package metrics keeps counters, histograms and gauges and serves them in
+// the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Histogram buckets in seconds
+var (
+	// RequestBuckets suit HTTP requests and other short operations
+	RequestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// TurnBuckets suit agent prompts and streams lasting up to an hour
+	TurnBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+)
+
+// Registry holds the metrics served together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// metric writes its samples, after the HELP and TYPE lines
+type metric interface {
+	desc() *desc
+	samples(w *bufio.Writer)
+}
+
+// desc names a metric and its labels
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{d: desc{name: name, help: help, typ: "counter", labels: labels}, series: make(map[string]*counterSeries)}
+	r.add(c)
+	return c
+}
+
+// Histogram registers a histogram with the given upper bounds, in
+// increasing order, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{d: desc{name: name, help: help, typ: "histogram", labels: labels}, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.add(h)
+	return h
+}
+
+// Sample is a value of a gauge with its label values
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// GaugeFunc registers a gauge whose samples collect returns at each scrape
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func() []Sample) {
+	r.add(&gaugeFunc{d: desc{name: name, help: help, typ: "gauge", labels: labels}, collect: collect})
+}
+
+// Write writes all metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	list := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range list {
+		d := m.desc()
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+		m.samples(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry to Prometheus
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Counter is a value that only goes up, per combination of label values
+type Counter struct {
+	d      desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels []string
+	value  float64
+}
+
+func (c *Counter) desc() *desc { return &c.d }
+
+// Inc adds one for the label values
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds v, which must not be negative, for the label values
+func (c *Counter) Add(v float64, labels ...string) {
+	key := c.d.key(labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.series[key]
+	if s == nil {
+		s = &counterSeries{labels: append([]string(nil), labels...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) samples(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		writeSample(w, c.d.name, c.d.labelPairs(s.labels), s.value)
+	}
+}
+
+// Histogram counts observations in buckets, per combination of label values
+type Histogram struct {
+	d       desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *Histogram) desc() *desc { return &h.d }
+
+// Observe records v for the label values
+func (h *Histogram) Observe(v float64, labels ...string) {
+	key := h.d.key(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &histogramSeries{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) samples(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.d.name+"_bucket", h.d.labelPairs(s.labels, "le", formatFloat(le)), float64(cumulative))
+		}
+		writeSample(w, h.d.name+"_bucket", h.d.labelPairs(s.labels, "le", "+Inf"), float64(s.count))
+		writeSample(w, h.d.name+"_sum", h.d.labelPairs(s.labels), s.sum)
+		writeSample(w, h.d.name+"_count", h.d.labelPairs(s.labels), float64(s.count))
+	}
+}
+
+type gaugeFunc struct {
+	d       desc
+	collect func() []Sample
+}
+
+func (g *gaugeFunc) desc() *desc { return &g.d }
+
+func (g *gaugeFunc) samples(w *bufio.Writer) {
+	for _, s := range g.collect() {
+		writeSample(w, g.d.name, g.d.labelPairs(s.Labels), s.Value)
+	}
+}
+
+// key identifies a series by its label values, which must match the label
+// names in number
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats {name="value",...} for the label values followed by
+// extra name and value pairs, empty without labels
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	pair := func(name, value string) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + `="` + escapeLabel(value) + `"`)
+	}
+	for i, name := range d.labels {
+		pair(name, values[i])
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pair(extra[i], extra[i+1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+	w.WriteString(name + labels + " " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+// sortedKeys orders series so scrapes list them consistently
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
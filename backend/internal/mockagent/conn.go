@@ -0,0 +1,170 @@
+package mockagent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+// Handler answers a request from the client. Returning a *jsonrpc.Error
+// sends that error; any other error is reported as an internal error.
+type Handler func(c *Conn, msg *jsonrpc.Message) (any, error)
+
+// Conn is the agent side of an ACP connection over newline-delimited JSON-RPC
+type Conn struct {
+	w        io.Writer
+	writeMu  sync.Mutex
+	handler  Handler
+	onNotify func(c *Conn, msg *jsonrpc.Message)
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan *jsonrpc.Message
+}
+
+// NewConn creates an agent-side connection writing to w
+func NewConn(w io.Writer, handler Handler, onNotify func(*Conn, *jsonrpc.Message)) *Conn {
+	return &Conn{
+		w:        w,
+		handler:  handler,
+		onNotify: onNotify,
+		pending:  make(map[int]chan *jsonrpc.Message),
+	}
+}
+
+// Serve reads messages from r until EOF. Requests are handled concurrently
+// so a handler may call back into the client while the loop keeps reading.
+func (c *Conn) Serve(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	var wg sync.WaitGroup
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg jsonrpc.Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+
+		switch {
+		case msg.IsResponse():
+			c.mu.Lock()
+			ch, ok := c.pending[*msg.ID]
+			delete(c.pending, *msg.ID)
+			c.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+		case msg.IsRequest():
+			wg.Add(1)
+			go func(m jsonrpc.Message) {
+				defer wg.Done()
+				c.handle(&m)
+			}(msg)
+		case msg.IsNotification():
+			if c.onNotify != nil {
+				c.onNotify(c, &msg)
+			}
+		}
+	}
+
+	// Release callers waiting on responses that will never come
+	c.mu.Lock()
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+
+	wg.Wait()
+	return scanner.Err()
+}
+
+func (c *Conn) handle(msg *jsonrpc.Message) {
+	result, err := c.handler(c, msg)
+	if err != nil {
+		rpcErr, ok := err.(*jsonrpc.Error)
+		if !ok {
+			rpcErr = &jsonrpc.Error{Code: jsonrpc.InternalError, Message: err.Error()}
+		}
+		c.write(&jsonrpc.Response{JSONRPC: jsonrpc.Version, ID: *msg.ID, Error: rpcErr})
+		return
+	}
+	c.write(jsonrpc.NewResponse(*msg.ID, result))
+}
+
+// Notify sends a notification to the client
+func (c *Conn) Notify(method string, params any) error {
+	return c.write(jsonrpc.NewNotification(method, params))
+}
+
+// Update sends a session/update notification
+func (c *Conn) Update(sessionID string, update map[string]any) error {
+	return c.Notify("session/update", map[string]any{
+		"sessionId": sessionID,
+		"update":    update,
+	})
+}
+
+// Call sends a request to the client and waits for its response
+func (c *Conn) Call(method string, params any) (*jsonrpc.Message, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *jsonrpc.Message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.write(jsonrpc.NewRequest(id, method, params)); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	msg, ok := <-ch
+	if !ok {
+		return nil, fmt.Errorf("connection closed")
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg, nil
+}
+
+func (c *Conn) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = fmt.Fprintf(c.w, "%s\n", data)
+	return err
+}
+
+// PromptText joins the text blocks of a session/prompt request
+func PromptText(msg *jsonrpc.Message) (sessionID, text string) {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		Prompt    []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"prompt"`
+	}
+	msg.ParseParams(&params)
+	for _, block := range params.Prompt {
+		if block.Type == "text" {
+			text += block.Text
+		}
+	}
+	return params.SessionID, text
+}
@@ -0,0 +1,218 @@
+package mockagent
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+)
+
+// chunkDelay paces streamed words so the UI shows real streaming
+const chunkDelay = 30 * time.Millisecond
+
+var commands = []map[string]any{
+	{"name": "echo", "description": "Echo the text back"},
+	{"name": "tool", "description": "Emit a synthetic tool call"},
+	{"name": "permission", "description": "Ask for a permission before continuing"},
+	{"name": "read", "description": "Read a file through the client", "input": map[string]string{"hint": "path"}},
+}
+
+// mock is an in-process ACP agent for development and demos
+type mock struct {
+	mu        sync.Mutex
+	sessions  int
+	cancelled map[string]bool
+}
+
+// Serve runs the mock agent, reading requests from r and writing to w
+func Serve(r io.Reader, w io.Writer) error {
+	m := &mock{cancelled: make(map[string]bool)}
+	conn := NewConn(w, m.handle, m.notify)
+	return conn.Serve(r)
+}
+
+func (m *mock) handle(c *Conn, msg *jsonrpc.Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion":   1,
+			"agentCapabilities": map[string]any{"loadSession": false},
+			"authMethods":       []any{},
+			"agentInfo":         map[string]string{"name": "acpone-mock", "version": "0.1.0"},
+		}, nil
+
+	case "session/new":
+		m.mu.Lock()
+		m.sessions++
+		sessionID := fmt.Sprintf("mock-%d-%d", time.Now().Unix(), m.sessions)
+		m.mu.Unlock()
+
+		// Advertise commands once the client knows the session
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			c.Update(sessionID, map[string]any{
+				"sessionUpdate":     "available_commands_update",
+				"availableCommands": commands,
+			})
+		}()
+		return map[string]any{"sessionId": sessionID}, nil
+
+	case "session/set_mode":
+		return map[string]any{}, nil
+
+	case "session/prompt":
+		return m.prompt(c, msg)
+
+	default:
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: "Method not found: " + msg.Method}
+	}
+}
+
+func (m *mock) notify(c *Conn, msg *jsonrpc.Message) {
+	if msg.Method != "session/cancel" {
+		return
+	}
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+	msg.ParseParams(&params)
+	m.mu.Lock()
+	m.cancelled[params.SessionID] = true
+	m.mu.Unlock()
+}
+
+func (m *mock) isCancelled(sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cancelled := m.cancelled[sessionID]
+	delete(m.cancelled, sessionID)
+	return cancelled
+}
+
+func (m *mock) prompt(c *Conn, msg *jsonrpc.Message) (any, error) {
+	sessionID, text := PromptText(msg)
+	m.isCancelled(sessionID) // Reset stale cancellations
+
+	command, arg := splitCommand(text)
+	var reply string
+	switch command {
+	case "tool":
+		reply = m.toolCall(c, sessionID, arg)
+	case "permission":
+		reply = m.permission(c, sessionID, arg)
+	case "read":
+		reply = m.readFile(c, sessionID, arg)
+	case "echo":
+		reply = arg
+	default:
+		reply = "Echo: " + text
+	}
+
+	for _, word := range strings.SplitAfter(reply, " ") {
+		if m.isCancelled(sessionID) {
+			return map[string]any{"stopReason": "cancelled"}, nil
+		}
+		c.Update(sessionID, map[string]any{
+			"sessionUpdate": "agent_message_chunk",
+			"content":       map[string]string{"type": "text", "text": word},
+		})
+		time.Sleep(chunkDelay)
+	}
+	return map[string]any{"stopReason": "end_turn"}, nil
+}
+
+func (m *mock) toolCall(c *Conn, sessionID, arg string) string {
+	toolCallID := fmt.Sprintf("mock-tool-%d", time.Now().UnixNano())
+	command := strings.TrimSpace(arg)
+	if command == "" {
+		command = "ls"
+	}
+
+	c.Update(sessionID, map[string]any{
+		"sessionUpdate": "tool_call",
+		"toolCallId":    toolCallID,
+		"title":         "Run " + command,
+		"kind":          "execute",
+		"status":        "pending",
+		"rawInput":      map[string]string{"command": command},
+	})
+	time.Sleep(200 * time.Millisecond)
+	c.Update(sessionID, map[string]any{
+		"sessionUpdate": "tool_call_update",
+		"toolCallId":    toolCallID,
+		"status":        "completed",
+		"content": []map[string]any{{
+			"type":    "content",
+			"content": map[string]string{"type": "text", "text": "mock output of " + command},
+		}},
+	})
+	return "Ran synthetic tool call `" + command + "`."
+}
+
+func (m *mock) permission(c *Conn, sessionID, arg string) string {
+	toolCallID := fmt.Sprintf("mock-perm-%d", time.Now().UnixNano())
+	title := strings.TrimSpace(arg)
+	if title == "" {
+		title = "Write mock.txt"
+	}
+
+	resp, err := c.Call("session/request_permission", map[string]any{
+		"sessionId": sessionID,
+		"options": []map[string]string{
+			{"optionId": "allow", "name": "Allow", "kind": "allow_once"},
+			{"optionId": "reject", "name": "Reject", "kind": "reject_once"},
+		},
+		"toolCall": map[string]any{
+			"toolCallId": toolCallID,
+			"title":      title,
+			"kind":       "edit",
+			"status":     "pending",
+		},
+	})
+	if err != nil {
+		return "Permission request failed: " + err.Error()
+	}
+
+	var result struct {
+		Outcome struct {
+			Outcome  string `json:"outcome"`
+			OptionID string `json:"optionId"`
+		} `json:"outcome"`
+	}
+	resp.ParseResult(&result)
+	return fmt.Sprintf("Permission %s (option %s).", result.Outcome.Outcome, result.Outcome.OptionID)
+}
+
+func (m *mock) readFile(c *Conn, sessionID, path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "Usage: /read <path>"
+	}
+	resp, err := c.Call("fs/read_text_file", map[string]string{"sessionId": sessionID, "path": path})
+	if err != nil {
+		return "Read failed: " + err.Error()
+	}
+	var result struct {
+		Content string `json:"content"`
+	}
+	resp.ParseResult(&result)
+	return fmt.Sprintf("Read %d bytes from %s.", len(result.Content), path)
+}
+
+// splitCommand parses "/name rest" into its command name and argument,
+// skipping leading @mentions
+func splitCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	for strings.HasPrefix(text, "@") {
+		_, rest, _ := strings.Cut(text, " ")
+		text = strings.TrimSpace(rest)
+	}
+	if !strings.HasPrefix(text, "/") {
+		return "", text
+	}
+	name, rest, _ := strings.Cut(text[1:], " ")
+	return name, rest
+}
@@ -0,0 +1,107 @@
+// Package recentfiles tracks which workspace files were recently mentioned,
+// read or edited so file pickers can surface them first.
+package recentfiles
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxEntries bounds the files remembered per workspace
+const maxEntries = 200
+
+// File access kinds
+const (
+	Mentioned = "mentioned"
+	Read      = "read"
+	Edited    = "edited"
+)
+
+// Entry is a recently used file
+type Entry struct {
+	Path     string    `json:"path"` // Slash separated, relative to the workspace root
+	Name     string    `json:"name"`
+	Action   string    `json:"action"` // Most recent access kind
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// Tracker records file usage per workspace root. Safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	entries map[string]map[string]*Entry // root -> relative path -> entry
+}
+
+// New creates an empty tracker
+func New() *Tracker {
+	return &Tracker{entries: make(map[string]map[string]*Entry)}
+}
+
+// Record notes an access of path, which may be absolute or relative to root.
+// Paths outside root are ignored.
+func (t *Tracker) Record(root, path, action string) {
+	if root == "" || path == "" {
+		return
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files := t.entries[root]
+	if files == nil {
+		files = make(map[string]*Entry)
+		t.entries[root] = files
+	}
+	e := files[rel]
+	if e == nil {
+		e = &Entry{Path: rel, Name: filepath.Base(path)}
+		files[rel] = e
+	}
+	e.Action = action
+	e.Count++
+	e.LastUsed = time.Now()
+
+	if len(files) > maxEntries {
+		t.evictOldest(files)
+	}
+}
+
+// List returns up to limit files of root, most recently used first
+func (t *Tracker) List(root string, limit int) []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]Entry, 0, len(t.entries[root]))
+	for _, e := range t.entries[root] {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastUsed.After(list[j].LastUsed)
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// evictOldest drops the least recently used entry (caller holds t.mu)
+func (t *Tracker) evictOldest(files map[string]*Entry) {
+	var oldest *Entry
+	for _, e := range files {
+		if oldest == nil || e.LastUsed.Before(oldest.LastUsed) {
+			oldest = e
+		}
+	}
+	if oldest != nil {
+		delete(files, oldest.Path)
+	}
+}
@@ -0,0 +1,319 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/logging"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+var logger = logging.Component("recorder")
+
+// Ext is the file extension of ACP recordings
+const Ext = ".acprec"
+
+// Header is the first line of a recording file
+type Header struct {
+	Version   int    `json:"version"`
+	Agent     string `json:"agent"`
+	Session   string `json:"session,omitempty"`
+	StartedAt int64  `json:"startedAt"`
+}
+
+// Entry is one recorded frame
+type Entry struct {
+	T     int64           `json:"t"`   // Milliseconds since StartedAt
+	Dir   string          `json:"dir"` // send or recv
+	Frame json.RawMessage `json:"frame"`
+}
+
+// FileInfo describes a recording on disk
+type FileInfo struct {
+	Name    string `json:"name"`
+	Agent   string `json:"agent"`
+	Session string `json:"session,omitempty"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+// maxHandshake bounds the frames kept from before an agent's first session
+const maxHandshake = 50
+
+// Recorder writes raw ACP traffic into replayable .acprec files, one per
+// agent session. Frames are routed by their sessionId, responses by the
+// request they answer. Each file starts with the agent's initialize
+// handshake, so it replays on its own.
+type Recorder struct {
+	dir     string
+	secrets []string
+
+	mu     sync.Mutex
+	agents map[string]*agentRecording // agentID -> since its last initialize
+}
+
+// agentRecording holds the recordings of an agent process's sessions
+type agentRecording struct {
+	start     time.Time
+	handshake []agent.Frame          // Frames of no session, copied into each file
+	sessions  map[string]*recording  // sessionID -> its file
+	requests  map[string]string      // dir+id of requests in flight -> sessionID
+	creating  map[string]agent.Frame // dir+id of session/new requests -> the request
+}
+
+type recording struct {
+	file  *os.File
+	start time.Time
+}
+
+// frame holds the fields of a JSON-RPC message routing it to a session
+type frame struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+}
+
+// sessionID returns the sessionId field of params or a result, if any
+func sessionID(data json.RawMessage) string {
+	var v struct {
+		SessionID string `json:"sessionId"`
+	}
+	json.Unmarshal(data, &v)
+	return v.SessionID
+}
+
+// New creates a recorder writing to dir; secrets are redacted from frames
+func New(dir string, secrets []string) *Recorder {
+	if dir == "" {
+		dir = defaultDir()
+	}
+	os.MkdirAll(dir, 0755)
+	return &Recorder{
+		dir:     dir,
+		secrets: secrets,
+		agents:  make(map[string]*agentRecording),
+	}
+}
+
+func defaultDir() string {
+	return filepath.Join(sysutil.DataDir(), "recordings")
+}
+
+// Dir returns the recordings directory
+func (r *Recorder) Dir() string {
+	return r.dir
+}
+
+// Attach starts recording the frames of an agent process
+func (r *Recorder) Attach(p *agent.Process) func() {
+	return p.OnFrame(r.record)
+}
+
+func (r *Recorder) record(f agent.Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var msg frame
+	if json.Unmarshal(f.Data, &msg) != nil {
+		return
+	}
+	a := r.agents[f.AgentID]
+	if a == nil || (f.Dir == agent.FrameSend && msg.Method == "initialize") {
+		// A new process starts new sessions
+		if a != nil {
+			a.close()
+		}
+		a = &agentRecording{
+			start:    f.Time,
+			sessions: make(map[string]*recording),
+			requests: make(map[string]string),
+			creating: make(map[string]agent.Frame),
+		}
+		r.agents[f.AgentID] = a
+	}
+
+	session := sessionID(msg.Params)
+	id := string(msg.ID)
+	switch {
+	case msg.Method == "session/new" && id != "":
+		// Recorded with the response, which names the session
+		a.creating[f.Dir+id] = f
+		return
+	case msg.Method != "" && id != "":
+		if session != "" {
+			a.requests[f.Dir+id] = session
+		}
+	case msg.Method == "" && id != "":
+		key := opposite(f.Dir) + id
+		if req, ok := a.creating[key]; ok {
+			delete(a.creating, key)
+			session = sessionID(msg.Result)
+			if session == "" {
+				a.keep(req)
+				break
+			}
+			r.write(f.AgentID, a, session, req)
+		} else {
+			session = a.requests[key]
+			delete(a.requests, key)
+		}
+	}
+	if session == "" {
+		a.keep(f)
+		return
+	}
+	r.write(f.AgentID, a, session, f)
+}
+
+// keep holds a frame of no session for the files of sessions started later
+func (a *agentRecording) keep(f agent.Frame) {
+	if len(a.handshake) < maxHandshake {
+		a.handshake = append(a.handshake, f)
+	}
+}
+
+// write appends a frame to the session's file, starting the file with the
+// handshake
+func (r *Recorder) write(agentID string, a *agentRecording, session string, f agent.Frame) {
+	rec := a.sessions[session]
+	if rec == nil {
+		var err error
+		rec, err = r.open(agentID, session, a.start)
+		if err != nil {
+			logger.Warn("recorder write failed", "error", err)
+			return
+		}
+		a.sessions[session] = rec
+		for _, h := range a.handshake {
+			r.append(rec, h)
+		}
+	}
+	r.append(rec, f)
+}
+
+func (r *Recorder) append(rec *recording, f agent.Frame) {
+	line, err := json.Marshal(Entry{
+		T:     f.Time.Sub(rec.start).Milliseconds(),
+		Dir:   f.Dir,
+		Frame: Redact(f.Data, r.secrets),
+	})
+	if err != nil {
+		return
+	}
+	rec.file.Write(append(line, '\n'))
+}
+
+func opposite(dir string) string {
+	if dir == agent.FrameSend {
+		return agent.FrameRecv
+	}
+	return agent.FrameSend
+}
+
+// unsafeName matches characters kept out of file names
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+func (r *Recorder) open(agentID, session string, start time.Time) (*recording, error) {
+	short := unsafeName.ReplaceAllString(session, "_")
+	if len(short) > 40 {
+		short = short[:40]
+	}
+	name := fmt.Sprintf("%s-%s-%s%s", agentID, start.Format("20060102-150405.000"), short, Ext)
+	file, err := os.Create(filepath.Join(r.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	header, _ := json.Marshal(Header{Version: 1, Agent: agentID, Session: session, StartedAt: start.UnixMilli()})
+	file.Write(append(header, '\n'))
+	return &recording{file: file, start: start}, nil
+}
+
+func (a *agentRecording) close() {
+	for _, rec := range a.sessions {
+		rec.file.Close()
+	}
+}
+
+// Close closes all open recordings
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, a := range r.agents {
+		a.close()
+		delete(r.agents, id)
+	}
+}
+
+// List returns all recordings, newest first
+func (r *Recorder) List() []FileInfo {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []FileInfo
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != Ext {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		header, err := readHeader(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{
+			Name:    e.Name(),
+			Agent:   header.Agent,
+			Session: header.Session,
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixMilli(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime > files[j].ModTime
+	})
+	return files
+}
+
+// Path returns the full path of a recording, rejecting names outside the directory
+func (r *Recorder) Path(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || filepath.Ext(name) != Ext {
+		return "", fmt.Errorf("invalid recording name: %s", name)
+	}
+	path := filepath.Join(r.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// readHeader reads the header line of a recording
+func readHeader(path string) (*Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var h Header
+	if err := json.Unmarshal(line, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
@@ -0,0 +1,166 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+// readRecording returns the header and frames of a recording
+func readRecording(t *testing.T, path string) (Header, []Entry) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var header Header
+	var entries []Entry
+	for i := 0; scanner.Scan(); i++ {
+		if i == 0 {
+			if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+				t.Fatalf("header: %v", err)
+			}
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return header, entries
+}
+
+// methods returns the method of each entry, or "<dir> response" for responses
+func methods(entries []Entry) []string {
+	var list []string
+	for _, e := range entries {
+		var msg frame
+		json.Unmarshal(e.Frame, &msg)
+		if msg.Method == "" {
+			msg.Method = e.Dir + " response"
+		}
+		list = append(list, msg.Method)
+	}
+	return list
+}
+
+func TestRecordingPerSession(t *testing.T) {
+	r := New(t.TempDir(), nil)
+	start := time.Now()
+	n := 0
+	send := func(dir, data string) {
+		n++
+		r.record(agent.Frame{AgentID: "claude", Dir: dir, Data: []byte(data), Time: start.Add(time.Duration(n) * time.Millisecond)})
+	}
+
+	send(agent.FrameSend, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	send(agent.FrameRecv, `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":1}}`)
+	send(agent.FrameSend, `{"jsonrpc":"2.0","id":2,"method":"session/new","params":{"cwd":"/a"}}`)
+	send(agent.FrameSend, `{"jsonrpc":"2.0","id":3,"method":"session/new","params":{"cwd":"/b"}}`)
+	send(agent.FrameRecv, `{"jsonrpc":"2.0","id":3,"result":{"sessionId":"b"}}`)
+	send(agent.FrameRecv, `{"jsonrpc":"2.0","id":2,"result":{"sessionId":"a"}}`)
+	// Prompts of both sessions interleave
+	send(agent.FrameSend, `{"jsonrpc":"2.0","id":4,"method":"session/prompt","params":{"sessionId":"a"}}`)
+	send(agent.FrameSend, `{"jsonrpc":"2.0","id":5,"method":"session/prompt","params":{"sessionId":"b"}}`)
+	send(agent.FrameRecv, `{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"b"}}`)
+	send(agent.FrameRecv, `{"jsonrpc":"2.0","id":1,"method":"fs/read_text_file","params":{"sessionId":"a","path":"x"}}`)
+	send(agent.FrameSend, `{"jsonrpc":"2.0","id":1,"result":{"content":""}}`)
+	send(agent.FrameRecv, `{"jsonrpc":"2.0","id":5,"result":{"stopReason":"end_turn"}}`)
+	send(agent.FrameRecv, `{"jsonrpc":"2.0","id":4,"result":{"stopReason":"end_turn"}}`)
+	r.Close()
+
+	files := r.List()
+	if len(files) != 2 {
+		t.Fatalf("%d recordings, want one per session: %+v", len(files), files)
+	}
+	want := map[string][]string{
+		"a": {"initialize", "recv response", "session/new", "recv response", "session/prompt", "fs/read_text_file", "send response", "recv response"},
+		"b": {"initialize", "recv response", "session/new", "recv response", "session/prompt", "session/update", "recv response"},
+	}
+	for _, info := range files {
+		header, entries := readRecording(t, filepath.Join(r.Dir(), info.Name))
+		if header.Agent != "claude" || header.Session != info.Session || info.Agent != "claude" {
+			t.Errorf("%s: header %+v, listed as %+v", info.Name, header, info)
+		}
+		if got := strings.Join(methods(entries), ", "); got != strings.Join(want[header.Session], ", ") {
+			t.Errorf("session %s recorded %s\nwant %s", header.Session, got, strings.Join(want[header.Session], ", "))
+		}
+	}
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	const secret = "sk-test-0123456789"
+	agent.RegisterBuiltin("builtin:recorder-test", mockagent.Serve)
+	p := agent.NewProcess(&config.AgentConfig{ID: "mock", Name: "mock", Command: "builtin:recorder-test"})
+	r := New(t.TempDir(), []string{secret})
+	defer r.Attach(p)()
+	var mu sync.Mutex
+	var frames []agent.Frame
+	defer p.OnFrame(func(f agent.Frame) {
+		mu.Lock()
+		defer mu.Unlock()
+		frames = append(frames, f)
+	})()
+
+	if _, err := p.Request("initialize", map[string]any{"protocolVersion": 1}); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := p.Request("session/new", map[string]any{"cwd": t.TempDir(), "mcpServers": []any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	json.Unmarshal(msg.Result, &session)
+	prompt := map[string]any{"sessionId": session.SessionID, "prompt": []map[string]any{{"type": "text", "text": "my key is " + secret}}}
+	if _, err := p.Request("session/prompt", prompt); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	r.Close()
+
+	files := r.List()
+	if len(files) != 1 || files[0].Session != session.SessionID || files[0].Agent != "mock" {
+		t.Fatalf("recordings %+v", files)
+	}
+	path, err := r.Path(files[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, entries := readRecording(t, path)
+	if header.Version != 1 || header.Agent != "mock" || header.Session != session.SessionID {
+		t.Errorf("header %+v", header)
+	}
+
+	// Every frame comes back in order, redacted
+	mu.Lock()
+	defer mu.Unlock()
+	if len(entries) != len(frames) {
+		t.Fatalf("read back %d frames, %d were exchanged", len(entries), len(frames))
+	}
+	for i, e := range entries {
+		f := frames[i]
+		if e.Dir != f.Dir || string(e.Frame) != string(Redact(f.Data, []string{secret})) {
+			t.Errorf("frame %d: read %s %s, exchanged %s %s", i, e.Dir, e.Frame, f.Dir, f.Data)
+		}
+		if e.T < 0 || i > 0 && e.T < entries[i-1].T {
+			t.Errorf("frame %d: time %dms out of order", i, e.T)
+		}
+		if strings.Contains(string(e.Frame), secret) {
+			t.Errorf("frame %d leaks the secret: %s", i, e.Frame)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package recorder
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const redacted = "***"
+
+var sensitiveKey = regexp.MustCompile(`(?i)(api[_-]?key|token|secret|password|authorization|cookie)`)
+
+// Redact masks secret values in a JSON frame. Values of sensitive keys and
+// any occurrence of the given secrets are replaced. Invalid JSON is returned
+// as a JSON string with secrets masked.
+func Redact(data []byte, secrets []string) json.RawMessage {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		s, _ := json.Marshal(maskSecrets(string(data), secrets))
+		return s
+	}
+	out, err := json.Marshal(redactValue(v, secrets))
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return out
+}
+
+func redactValue(v any, secrets []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if _, isStr := item.(string); isStr && sensitiveKey.MatchString(k) {
+				val[k] = redacted
+				continue
+			}
+			val[k] = redactValue(item, secrets)
+		}
+		return val
+	case []any:
+		for i, item := range val {
+			val[i] = redactValue(item, secrets)
+		}
+		return val
+	case string:
+		return maskSecrets(val, secrets)
+	default:
+		return v
+	}
+}
+
+func maskSecrets(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if len(secret) >= 6 {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+// SecretsFromEnv collects values of sensitive environment variables
+func SecretsFromEnv(env map[string]string) []string {
+	var secrets []string
+	for k, v := range env {
+		if v != "" && sensitiveKey.MatchString(k) {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
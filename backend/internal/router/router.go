@@ -6,7 +6,10 @@ import (
 	"github.com/daodao97/acpone/internal/config"
 )
 
-var mentionRegex = regexp.MustCompile(`@(\w+)`)
+var (
+	mentionRegex         = regexp.MustCompile(`@(\w+)`)
+	pipelineMentionRegex = regexp.MustCompile(`(?:^|\s)@([\w-]+)`)
+)
 
 // RouteContext provides context for routing decisions
 type RouteContext struct {
@@ -17,7 +20,16 @@ type RouteContext struct {
 
 // Strategy defines a routing strategy
 type Strategy interface {
-	Route(ctx RouteContext) string
+	Decide(ctx RouteContext) *Decision
+}
+
+// Decision describes which strategy routed a request and why
+type Decision struct {
+	Agent    string `json:"agent"`
+	Strategy string `json:"strategy"`        // mention, keyword, meta or default
+	Rule     string `json:"rule,omitempty"`  // Keyword rule that fired
+	Match    string `json:"match,omitempty"` // Text that matched
+	Reason   string `json:"reason,omitempty"`
 }
 
 // Router routes requests to agents
@@ -25,31 +37,71 @@ type Router struct {
 	strategies      []Strategy
 	defaultAgent    string
 	availableAgents map[string]bool
+	names           map[string]string // Agent ID or alias -> agent ID
+	pipelines       map[string]bool
+	teams           map[string]bool
 }
 
 // New creates a new router
 func New(cfg *config.Config) *Router {
 	agents := make(map[string]bool)
+	names := make(map[string]string)
 	for _, a := range cfg.Agents {
+		if !a.IsEnabled() {
+			continue
+		}
 		agents[a.ID] = true
+		names[a.ID] = a.ID
+		for _, alias := range a.Aliases {
+			names[alias] = a.ID
+		}
+	}
+
+	pipelines := make(map[string]bool)
+	for _, p := range cfg.Pipelines {
+		pipelines[p.ID] = true
+	}
+
+	teams := make(map[string]bool)
+	for _, t := range cfg.Teams {
+		teams[t.ID] = true
 	}
 
-	strategies := buildStrategies(cfg.Routing, agents)
+	strategies := buildStrategies(cfg.Routing, names)
 
 	return &Router{
 		strategies:      strategies,
 		defaultAgent:    cfg.DefaultAgent,
 		availableAgents: agents,
+		names:           names,
+		pipelines:       pipelines,
+		teams:           teams,
 	}
 }
 
-// DetectMention detects @mention in prompt text
+// DetectMention returns the agent of the first @mention of an agent ID or
+// alias in prompt text
 func (r *Router) DetectMention(text string) string {
-	matches := mentionRegex.FindStringSubmatch(text)
-	if len(matches) > 1 {
-		agentID := matches[1]
-		if r.availableAgents[agentID] {
-			return agentID
+	if d := (&MentionStrategy{names: r.names}).Decide(RouteContext{PromptText: text}); d != nil {
+		return d.Agent
+	}
+	return ""
+}
+
+// DetectPipeline returns the first @-mentioned pipeline ID in prompt text
+func (r *Router) DetectPipeline(text string) string {
+	return detectID(text, r.pipelines)
+}
+
+// DetectTeam returns the first @-mentioned team ID in prompt text
+func (r *Router) DetectTeam(text string) string {
+	return detectID(text, r.teams)
+}
+
+func detectID(text string, ids map[string]bool) string {
+	for _, m := range pipelineMentionRegex.FindAllStringSubmatch(text, -1) {
+		if ids[m[1]] {
+			return m[1]
 		}
 	}
 	return ""
@@ -57,13 +109,27 @@ func (r *Router) DetectMention(text string) string {
 
 // Route routes a request to an agent
 func (r *Router) Route(ctx RouteContext) string {
+	return r.Explain(ctx).Agent
+}
+
+// Explain returns the routing decision for a request, falling back to the
+// default agent when no strategy picks an available agent
+func (r *Router) Explain(ctx RouteContext) Decision {
+	if d := r.Match(ctx); d != nil {
+		return *d
+	}
+	return Decision{Agent: r.defaultAgent, Strategy: "default", Reason: "no routing rule matched"}
+}
+
+// Match returns the first strategy decision that picks an available agent,
+// or nil when none does
+func (r *Router) Match(ctx RouteContext) *Decision {
 	for _, s := range r.strategies {
-		agentID := s.Route(ctx)
-		if agentID != "" && r.availableAgents[agentID] {
-			return agentID
+		if d := s.Decide(ctx); d != nil && r.availableAgents[d.Agent] {
+			return d
 		}
 	}
-	return r.defaultAgent
+	return nil
 }
 
 // DefaultAgent returns the default agent ID
@@ -76,19 +142,17 @@ func (r *Router) HasAgent(id string) bool {
 	return r.availableAgents[id]
 }
 
-func buildStrategies(routing *config.RoutingConfig, agents map[string]bool) []Strategy {
-	var strategies []Strategy
+func buildStrategies(routing *config.RoutingConfig, names map[string]string) []Strategy {
+	// Mention strategy (always first, even without routing config)
+	strategies := []Strategy{&MentionStrategy{names: names}}
 
 	if routing == nil {
 		return strategies
 	}
 
-	// Mention strategy (always first)
-	strategies = append(strategies, &MentionStrategy{agents: agents})
-
 	// Keyword strategy
-	if len(routing.Keywords) > 0 {
-		strategies = append(strategies, &KeywordStrategy{keywords: routing.Keywords})
+	if len(routing.Keywords) > 0 || len(routing.Rules) > 0 {
+		strategies = append(strategies, newKeywordStrategy(routing))
 	}
 
 	// Meta strategy
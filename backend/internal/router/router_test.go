@@ -0,0 +1,32 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+func TestInvalidRegexKeyword(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAgent: "claude",
+		Agents:       []config.AgentConfig{{ID: "claude", Name: "claude", Command: "claude"}, {ID: "codex", Name: "codex", Command: "codex"}},
+		Routing: &config.RoutingConfig{Rules: []config.RoutingRule{
+			{Name: "tests", Agent: "codex", Match: config.MatchRegex, Keywords: []string{"unit(", `\btests?\b`}},
+		}},
+	}
+
+	// Loading the config rejects the pattern
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `invalid regex "unit("`) {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	// A router built without validation skips only the broken keyword
+	r := New(cfg)
+	if d := r.Match(RouteContext{PromptText: "write tests for this"}); d == nil || d.Agent != "codex" {
+		t.Fatalf("valid keyword of the rule: %+v", d)
+	}
+	if d := r.Match(RouteContext{PromptText: "unit("}); d != nil {
+		t.Fatalf("broken keyword matched: %+v", d)
+	}
+}
@@ -1,46 +1,132 @@
 package router
 
 import (
-	"strings"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/logging"
 )
 
-// MentionStrategy routes by @mention
+var logger = logging.Component("router")
+
+// MentionStrategy routes by @mention of an agent ID or alias
 type MentionStrategy struct {
-	agents map[string]bool
+	names map[string]string
 }
 
-func (s *MentionStrategy) Route(ctx RouteContext) string {
-	matches := mentionRegex.FindStringSubmatch(ctx.PromptText)
-	if len(matches) > 1 {
-		agentID := matches[1]
-		if s.agents[agentID] {
-			return agentID
+func (s *MentionStrategy) Decide(ctx RouteContext) *Decision {
+	for _, m := range mentionRegex.FindAllStringSubmatch(ctx.PromptText, -1) {
+		if agentID, ok := s.names[m[1]]; ok {
+			return &Decision{Agent: agentID, Strategy: "mention", Match: m[0], Reason: "mentioned " + m[0]}
 		}
 	}
-	return ""
+	return nil
 }
 
-// KeywordStrategy routes by keywords in prompt
+// KeywordStrategy routes by keywords in prompt, trying rules by priority
 type KeywordStrategy struct {
-	keywords map[string]string
+	rules []keywordRule
+}
+
+type keywordRule struct {
+	name     string
+	agentID  string
+	priority int
+	patterns []*regexp.Regexp
+}
+
+func newKeywordStrategy(routing *config.RoutingConfig) *KeywordStrategy {
+	var rules []keywordRule
+	for i, r := range routing.Rules {
+		rule := keywordRule{name: r.Label(i), agentID: r.Agent, priority: r.Priority}
+		for _, k := range r.Keywords {
+			re, err := keywordPattern(k, r.Match)
+			if err != nil {
+				// Validate rejects these; configs that skipped it lose only the keyword
+				logger.Warn("skipping keyword", "rule", rule.name, "keyword", k, "error", err)
+				continue
+			}
+			rule.patterns = append(rule.patterns, re)
+		}
+		rules = append(rules, rule)
+	}
+
+	// Legacy keyword map, longest keyword first for a stable, specific order
+	keywords := make([]string, 0, len(routing.Keywords))
+	for k := range routing.Keywords {
+		keywords = append(keywords, k)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if len(keywords[i]) != len(keywords[j]) {
+			return len(keywords[i]) > len(keywords[j])
+		}
+		return keywords[i] < keywords[j]
+	})
+	for _, k := range keywords {
+		re, _ := keywordPattern(k, config.MatchWord)
+		rules = append(rules, keywordRule{
+			name:     "keywords." + k,
+			agentID:  routing.Keywords[k],
+			patterns: []*regexp.Regexp{re},
+		})
+	}
+
+	slices.SortStableFunc(rules, func(a, b keywordRule) int {
+		return b.priority - a.priority
+	})
+	return &KeywordStrategy{rules: rules}
+}
+
+// keywordPattern compiles a case-insensitive pattern for a keyword. Whole
+// word matching only adds \b next to word characters so that keywords like
+// "@codex" or CJK text still match.
+func keywordPattern(keyword, match string) (*regexp.Regexp, error) {
+	switch match {
+	case config.MatchRegex:
+		return regexp.Compile("(?i)" + keyword)
+	case config.MatchSubstring:
+		return regexp.Compile("(?i)" + regexp.QuoteMeta(keyword))
+	}
+	expr := regexp.QuoteMeta(keyword)
+	if keyword != "" && isWordByte(keyword[0]) {
+		expr = `\b` + expr
+	}
+	if keyword != "" && isWordByte(keyword[len(keyword)-1]) {
+		expr += `\b`
+	}
+	return regexp.Compile("(?i)" + expr)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
 }
 
-func (s *KeywordStrategy) Route(ctx RouteContext) string {
-	text := strings.ToLower(ctx.PromptText)
-	for keyword, agentID := range s.keywords {
-		if strings.Contains(text, strings.ToLower(keyword)) {
-			return agentID
+func (s *KeywordStrategy) Decide(ctx RouteContext) *Decision {
+	for _, rule := range s.rules {
+		for _, re := range rule.patterns {
+			if m := re.FindString(ctx.PromptText); m != "" {
+				return &Decision{
+					Agent:    rule.agentID,
+					Strategy: "keyword",
+					Rule:     rule.name,
+					Match:    m,
+					Reason:   fmt.Sprintf("rule %s matched %q", rule.name, m),
+				}
+			}
 		}
 	}
-	return ""
+	return nil
 }
 
 // MetaStrategy routes by session metadata
 type MetaStrategy struct{}
 
-func (s *MetaStrategy) Route(ctx RouteContext) string {
-	if ctx.Meta == nil {
-		return ""
+func (s *MetaStrategy) Decide(ctx RouteContext) *Decision {
+	if ctx.Meta == nil || ctx.Meta["agent"] == "" {
+		return nil
 	}
-	return ctx.Meta["agent"]
+	return &Decision{Agent: ctx.Meta["agent"], Strategy: "meta", Reason: "requested by session metadata"}
 }
@@ -0,0 +1,177 @@
+// Package sessionsearch keeps an in-memory full-text index of stored
+// conversations, including tool call inputs and outputs, so finding the chat
+// that hit an error doesn't mean opening every session file.
+package sessionsearch
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// Fields a query can be qualified with, e.g. output:connection refused
+const (
+	FieldTitle  = "title"  // Session title
+	FieldText   = "text"   // User and assistant messages
+	FieldTool   = "tool"   // Tool call titles and inputs
+	FieldOutput = "output" // Tool call results and errors
+)
+
+// Fields lists the qualifiers a query accepts
+var Fields = []string{FieldTitle, FieldText, FieldTool, FieldOutput}
+
+const (
+	// maxEntryBytes caps the text indexed per message field, as tool
+	// outputs can hold whole files
+	maxEntryBytes = 32 << 10
+	// maxMatches bounds the snippets returned per session
+	maxMatches = 3
+	// snippetRadius is how much context a snippet shows around a match
+	snippetRadius = 60
+)
+
+// entry is one searchable text of a session
+type entry struct {
+	field   string
+	message int // Index in the session's messages, -1 for the title
+	text    string
+	lower   string // ASCII lower-cased text, same length as text
+}
+
+// doc is the indexed content of one session
+type doc struct {
+	meta    storage.SessionMeta
+	entries []entry
+}
+
+// Match is where a session matched a query
+type Match struct {
+	Field   string `json:"field"`
+	Message int    `json:"message"` // -1 for the title
+	Snippet string `json:"snippet"`
+}
+
+// Hit is a session matching a query
+type Hit struct {
+	Session storage.SessionMeta `json:"session"`
+	Matches []Match             `json:"matches"`
+}
+
+// Index searches the sessions of a store. Sessions are (re)indexed lazily
+// when their update time changed, so it follows saves, merges, deletes and
+// restores without being told about them.
+type Index struct {
+	store storage.Store
+
+	mu   sync.Mutex
+	docs map[string]*doc
+}
+
+// New creates an index of store's sessions
+func New(store storage.Store) *Index {
+	return &Index{store: store, docs: make(map[string]*doc)}
+}
+
+// Search returns the sessions matching query, most recently updated first.
+// A non-empty workspaceID limits the search to that workspace.
+func (ix *Index) Search(query, workspaceID string, limit int) []Hit {
+	q := Parse(query)
+	if len(q.Clauses) == 0 {
+		return []Hit{}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.refresh()
+
+	hits := []Hit{}
+	for _, d := range ix.docs {
+		if workspaceID != "" && d.meta.WorkspaceID != workspaceID {
+			continue
+		}
+		if matches, ok := q.match(d); ok {
+			hits = append(hits, Hit{Session: d.meta, Matches: matches})
+		}
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		return cmp.Compare(b.Session.UpdatedAt, a.Session.UpdatedAt)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// refresh reindexes changed sessions and drops deleted ones
+func (ix *Index) refresh() {
+	seen := make(map[string]bool)
+	for _, meta := range ix.store.List() {
+		seen[meta.ID] = true
+		if d, ok := ix.docs[meta.ID]; ok && d.meta.UpdatedAt == meta.UpdatedAt {
+			d.meta = meta
+			continue
+		}
+		session, err := ix.store.Load(meta.ID)
+		if err != nil {
+			delete(ix.docs, meta.ID)
+			continue
+		}
+		ix.docs[meta.ID] = &doc{meta: meta, entries: entries(session)}
+	}
+	for id := range ix.docs {
+		if !seen[id] {
+			delete(ix.docs, id)
+		}
+	}
+}
+
+// entries extracts the searchable texts of a session
+func entries(session *storage.StoredSession) []entry {
+	var out []entry
+	add := func(field string, message int, text string) {
+		if text == "" {
+			return
+		}
+		if len(text) > maxEntryBytes {
+			text = text[:maxEntryBytes]
+		}
+		out = append(out, entry{field: field, message: message, text: text, lower: lowerASCII(text)})
+	}
+
+	add(FieldTitle, -1, session.Title)
+	for i, msg := range session.Messages {
+		add(FieldText, i, msg.Content)
+		if tc := msg.ToolCall; tc != nil {
+			add(FieldTool, i, toolText(tc))
+			add(FieldOutput, i, tc.Output)
+			add(FieldOutput, i, tc.Error)
+		}
+	}
+	return out
+}
+
+// toolText is what a tool call is searched by besides its output
+func toolText(tc *conversation.ToolCallInfo) string {
+	text := tc.Title
+	for _, s := range []string{tc.ToolName, tc.Input, tc.RawInput} {
+		if s != "" && s != text {
+			text += "\n" + s
+		}
+	}
+	return text
+}
+
+// lowerASCII lower-cases ASCII letters only, keeping byte offsets aligned
+// with the original for snippets
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
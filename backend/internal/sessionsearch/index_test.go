@@ -0,0 +1,145 @@
+package sessionsearch
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/conversation"
+	"github.com/daodao97/acpone/internal/storage"
+)
+
+// newStore returns a session store holding two sessions in different
+// workspaces
+func newStore(t *testing.T) *storage.SessionStore {
+	t.Helper()
+	store := storage.NewSessionStore(t.TempDir())
+	save(t, store, &storage.StoredSession{
+		ID:          "deploy",
+		Title:       "Deploy the API",
+		WorkspaceID: "w1",
+		UpdatedAt:   100,
+		Messages: []conversation.Message{
+			{Role: "user", Content: "Please deploy to staging"},
+			{Role: "assistant", ToolCall: &conversation.ToolCallInfo{
+				Title:    "Run kubectl",
+				RawInput: `{"command":"kubectl apply -f api.yaml"}`,
+				Output:   "error: connection refused\nexit status 1",
+			}},
+		},
+	})
+	save(t, store, &storage.StoredSession{
+		ID:          "docs",
+		Title:       "Write the README",
+		WorkspaceID: "w2",
+		UpdatedAt:   200,
+		Messages: []conversation.Message{
+			{Role: "user", Content: "Explain how to deploy"},
+			{Role: "assistant", Content: "Run make deploy."},
+		},
+	})
+	return store
+}
+
+func save(t *testing.T, store storage.Store, session *storage.StoredSession) {
+	t.Helper()
+	if err := store.Save(session); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ids returns the session IDs of hits in order
+func ids(hits []Hit) []string {
+	out := []string{}
+	for _, h := range hits {
+		out = append(out, h.Session.ID)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	ix := New(newStore(t))
+	tests := []struct {
+		query, workspace string
+		limit            int
+		want             []string
+	}{
+		{"deploy", "", 0, []string{"docs", "deploy"}}, // Most recently updated first
+		{"DEPLOY", "", 1, []string{"docs"}},
+		{"deploy", "w1", 0, []string{"deploy"}},
+		{"title:deploy", "", 0, []string{"deploy"}},
+		{"text:staging", "", 0, []string{"deploy"}},
+		{"tool:kubectl apply", "", 0, []string{"deploy"}},
+		{"output:connection refused", "", 0, []string{"deploy"}},
+		{`deploy output:"exit status 1"`, "", 0, []string{"deploy"}},
+		{"output:deploy", "", 0, []string{}},
+		{"deploy readme", "", 0, []string{"docs"}}, // Every clause must match
+		{"nothing like this", "", 0, []string{}},
+		{"", "", 0, []string{}},
+	}
+	for _, tt := range tests {
+		if got := ids(ix.Search(tt.query, tt.workspace, tt.limit)); !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q, %q, %d) = %v, want %v", tt.query, tt.workspace, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestSearchMatches(t *testing.T) {
+	hits := New(newStore(t)).Search("kubectl output:refused", "", 0)
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits, want 1", len(hits))
+	}
+	want := []Match{
+		{Field: FieldTool, Message: 1, Snippet: `Run kubectl {"command":"kubectl apply -f api.yaml"}`},
+		{Field: FieldOutput, Message: 1, Snippet: "error: connection refused exit status 1"},
+	}
+	if got := hits[0].Matches; !slices.Equal(got, want) {
+		t.Errorf("matches %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchFollowsStore(t *testing.T) {
+	store := newStore(t)
+	ix := New(store)
+	if got := ids(ix.Search("rollback", "", 0)); len(got) != 0 {
+		t.Fatalf("found %v before the session mentioned it", got)
+	}
+
+	// A saved change is indexed once the update time moves
+	session, err := store.Load("deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Messages = append(session.Messages, conversation.Message{Role: "user", Content: "Now rollback"})
+	session.UpdatedAt = 300
+	save(t, store, session)
+	if got := ids(ix.Search("rollback", "", 0)); !slices.Equal(got, []string{"deploy"}) {
+		t.Fatalf("after the update found %v, want [deploy]", got)
+	}
+
+	if err := store.Delete("deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(ix.Search("deploy", "", 0)); !slices.Equal(got, []string{"docs"}) {
+		t.Fatalf("after the delete found %v, want [docs]", got)
+	}
+}
+
+func TestSearchCapsEntries(t *testing.T) {
+	store := storage.NewSessionStore(t.TempDir())
+	output := strings.Repeat("x", maxEntryBytes) + " beyond the cap"
+	save(t, store, &storage.StoredSession{
+		ID:        "big",
+		UpdatedAt: 1,
+		Messages: []conversation.Message{
+			{Role: "assistant", ToolCall: &conversation.ToolCallInfo{Title: "cat big.log", Output: output}},
+		},
+	})
+	ix := New(store)
+	if got := ids(ix.Search("beyond the cap", "", 0)); len(got) != 0 {
+		t.Errorf("text past %d bytes was indexed", maxEntryBytes)
+	}
+	if got := ids(ix.Search("cat big", "", 0)); !slices.Equal(got, []string{"big"}) {
+		t.Errorf("found %v, want [big]", got)
+	}
+}
@@ -0,0 +1,139 @@
+package sessionsearch
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Clause is one condition of a query: text that must appear in one of the
+// session's entries, of Field when set or of any field otherwise
+type Clause struct {
+	Field string `json:"field,omitempty"`
+	Text  string `json:"text"`
+}
+
+// Query is a parsed search, matching sessions that satisfy every clause
+type Query struct {
+	Clauses []Clause `json:"clauses"`
+}
+
+// Parse parses a query. Bare words are separate clauses matched anywhere; a
+// field qualifier takes the words after it up to the next qualifier as one
+// phrase, so `output:connection refused` finds that exact error. Quotes group
+// a phrase without a qualifier. Unknown qualifiers are searched as text.
+func Parse(s string) Query {
+	var q Query
+	var current *Clause // The qualified phrase being collected
+	for _, tok := range tokenize(s) {
+		if field, text, ok := strings.Cut(tok.text, ":"); ok && !tok.quoted && slices.Contains(Fields, field) {
+			q.Clauses = append(q.Clauses, Clause{Field: field, Text: text})
+			current = &q.Clauses[len(q.Clauses)-1]
+			continue
+		}
+		if current != nil {
+			if current.Text != "" {
+				current.Text += " "
+			}
+			current.Text += tok.text
+			continue
+		}
+		q.Clauses = append(q.Clauses, Clause{Text: tok.text})
+	}
+
+	// Drop empty qualifiers and normalize case once
+	clauses := q.Clauses[:0]
+	for _, c := range q.Clauses {
+		if c.Text = lowerASCII(strings.TrimSpace(c.Text)); c.Text != "" {
+			clauses = append(clauses, c)
+		}
+	}
+	q.Clauses = clauses
+	return q
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits s on whitespace, keeping quoted phrases, also after a
+// qualifier as in output:"exit status 1", together
+func tokenize(s string) []token {
+	var tokens []token
+	var b strings.Builder
+	quoted, inQuote := false, false
+	flush := func() {
+		if b.Len() > 0 {
+			tokens = append(tokens, token{text: b.String(), quoted: quoted})
+		}
+		b.Reset()
+		quoted = false
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			// A leading quote makes a phrase rather than a qualifier
+			if b.Len() == 0 && !inQuote {
+				quoted = true
+			}
+			inQuote = !inQuote
+		case !inQuote && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// match checks d against every clause, returning snippets of the first
+// matches
+func (q Query) match(d *doc) ([]Match, bool) {
+	var matches []Match
+	for _, c := range q.Clauses {
+		found := false
+		for _, e := range d.entries {
+			if c.Field != "" && e.field != c.Field {
+				continue
+			}
+			i := strings.Index(e.lower, c.Text)
+			if i < 0 {
+				continue
+			}
+			found = true
+			if len(matches) < maxMatches {
+				matches = append(matches, Match{
+					Field:   e.field,
+					Message: e.message,
+					Snippet: snippet(e.text, i, len(c.Text)),
+				})
+			}
+			break
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return matches, true
+}
+
+// snippet cuts the text around a match at i of length n, on rune boundaries
+func snippet(text string, i, n int) string {
+	start, end := max(i-snippetRadius, 0), min(i+n+snippetRadius, len(text))
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	s := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(text) {
+		s += "…"
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package sessionsearch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []Clause
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"Deploy", []Clause{{Text: "deploy"}}},
+		{"deploy failed", []Clause{{Text: "deploy"}, {Text: "failed"}}},
+		{`"exit status 1"`, []Clause{{Text: "exit status 1"}}},
+		{"output:connection refused", []Clause{{Field: FieldOutput, Text: "connection refused"}}},
+		{`deploy output:"exit status 1"`, []Clause{{Text: "deploy"}, {Field: FieldOutput, Text: "exit status 1"}}},
+		{"title:release notes tool:git push", []Clause{{Field: FieldTitle, Text: "release notes"}, {Field: FieldTool, Text: "git push"}}},
+		{"text: output:", nil},
+		{"foo:bar", []Clause{{Text: "foo:bar"}}},
+		{`"output:raw"`, []Clause{{Text: "output:raw"}}},
+	}
+	for _, tt := range tests {
+		got := Parse(tt.query).Clauses
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("a", 100) + " needle " + strings.Repeat("b", 100)
+	i := strings.Index(text, "needle")
+	got := snippet(text, i, len("needle"))
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "needle") {
+		t.Errorf("snippet = %q, want the match with ellipses on both sides", got)
+	}
+	if n := len([]rune(got)); n > 2*snippetRadius+len("needle")+2 {
+		t.Errorf("snippet of %d runes is wider than its radius", n)
+	}
+
+	if got := snippet("short\n\ttext here", 0, 5); got != "short text here" {
+		t.Errorf("snippet = %q, want whitespace collapsed and no ellipses", got)
+	}
+
+	// Multi-byte text is cut on rune boundaries
+	text = strings.Repeat("日本", 50) + "match" + strings.Repeat("語", 50)
+	got = snippet(text, strings.Index(text, "match"), len("match"))
+	if !strings.Contains(got, "match") || strings.ContainsRune(got, '�') {
+		t.Errorf("snippet = %q cut a rune", got)
+	}
+}
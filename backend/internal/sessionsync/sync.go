@@ -0,0 +1,274 @@
+// Package sessionsync mirrors local sessions to a remote shared between machines.
+package sessionsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/logging"
+	"github.com/daodao97/acpone/internal/storage"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+var logger = logging.Component("sync")
+
+const defaultInterval = 60 * time.Second
+
+// Status describes the last sync run
+type Status struct {
+	Running   bool   `json:"running"`
+	LastSync  int64  `json:"lastSync,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+	Pushed    int    `json:"pushed"`
+	Pulled    int    `json:"pulled"`
+	Deleted   int    `json:"deleted"`
+	Conflicts int    `json:"conflicts"`
+}
+
+// Service synchronizes a local session store with a remote one.
+// The newer UpdatedAt wins; when both sides changed since the last sync
+// the losing version is kept locally as a conflict copy.
+type Service struct {
+	local     storage.Store
+	remote    *storage.SessionStore
+	syncer    storage.Syncable // Non-nil for git remotes
+	interval  time.Duration
+	statePath string
+
+	runMu    sync.Mutex // Serializes runs
+	mu       sync.Mutex
+	status   Status
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a sync service for cfg
+func New(local storage.Store, cfg *config.SyncConfig) (*Service, error) {
+	backend, err := storage.NewBackend(&cfg.Remote)
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		local:     local,
+		remote:    storage.NewSessionStoreWithBackend(storage.WithPrefix(backend, "sessions/")),
+		interval:  time.Duration(cfg.IntervalSeconds) * time.Second,
+		statePath: defaultStatePath(),
+		stop:      make(chan struct{}),
+	}
+	if syncer, ok := backend.(storage.Syncable); ok {
+		s.syncer = syncer
+	}
+	if s.interval <= 0 {
+		s.interval = defaultInterval
+	}
+	return s, nil
+}
+
+func defaultStatePath() string {
+	return filepath.Join(sysutil.DataDir(), "sync-state.json")
+}
+
+// Start runs a sync immediately and then periodically until Stop
+func (s *Service) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Run(); err != nil {
+				logger.Warn("sync failed", "error", err)
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the periodic sync
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Status returns the state of the last run
+func (s *Service) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run performs one sync pass
+func (s *Service) Run() (Status, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	result, err := s.run()
+	result.LastSync = time.Now().UnixMilli()
+	if err != nil {
+		result.LastError = err.Error()
+	}
+
+	s.mu.Lock()
+	s.status = result
+	s.mu.Unlock()
+	return result, err
+}
+
+func (s *Service) run() (Status, error) {
+	var st Status
+	if s.syncer != nil {
+		if err := s.syncer.Pull(); err != nil {
+			return st, fmt.Errorf("pull: %w", err)
+		}
+	}
+
+	synced := s.loadState()
+	// Decisions need the remote as it is now, not as last cached
+	s.remote.Refresh()
+	local := metaByID(s.local.List())
+	remote := metaByID(s.remote.List())
+
+	ids := make(map[string]bool)
+	for id := range local {
+		ids[id] = true
+	}
+	for id := range remote {
+		ids[id] = true
+	}
+
+	for id := range ids {
+		if err := s.syncOne(id, local, remote, synced, &st); err != nil {
+			return st, fmt.Errorf("session %s: %w", id, err)
+		}
+	}
+
+	if s.syncer != nil {
+		host, _ := os.Hostname()
+		if err := s.syncer.Push("acpone sync from " + host); err != nil {
+			return st, fmt.Errorf("push: %w", err)
+		}
+	}
+	// Only remember what actually reached the remote
+	return st, s.saveState(synced)
+}
+
+// syncOne reconciles one session and records its synced UpdatedAt
+func (s *Service) syncOne(id string, local, remote map[string]storage.SessionMeta, synced map[string]int64, st *Status) error {
+	l, hasLocal := local[id]
+	r, hasRemote := remote[id]
+	last, known := synced[id]
+
+	switch {
+	case hasLocal && !hasRemote:
+		// Deleted remotely, unless edited locally since
+		if known && l.UpdatedAt <= last {
+			delete(synced, id)
+			st.Deleted++
+			return s.local.Delete(id)
+		}
+		st.Pushed++
+		synced[id] = l.UpdatedAt
+		return copySession(s.local, s.remote, id)
+
+	case !hasLocal && hasRemote:
+		// Deleted locally, unless edited remotely since
+		if known && r.UpdatedAt <= last {
+			delete(synced, id)
+			st.Deleted++
+			return s.remote.Delete(id)
+		}
+		st.Pulled++
+		synced[id] = r.UpdatedAt
+		return copySession(s.remote, s.local, id)
+	}
+
+	if l.UpdatedAt == r.UpdatedAt {
+		synced[id] = l.UpdatedAt
+		return nil
+	}
+
+	// Both sides changed since the last sync: keep the older version too
+	conflict := known && l.UpdatedAt != last && r.UpdatedAt != last
+
+	if l.UpdatedAt > r.UpdatedAt {
+		if conflict {
+			if err := s.keepConflict(s.remote, id, synced); err != nil {
+				return err
+			}
+			st.Conflicts++
+		}
+		st.Pushed++
+		synced[id] = l.UpdatedAt
+		return copySession(s.local, s.remote, id)
+	}
+
+	if conflict {
+		if err := s.keepConflict(s.local, id, synced); err != nil {
+			return err
+		}
+		st.Conflicts++
+	}
+	st.Pulled++
+	synced[id] = r.UpdatedAt
+	return copySession(s.remote, s.local, id)
+}
+
+// keepConflict saves the losing version of a session under a new ID on both sides
+func (s *Service) keepConflict(from storage.Store, id string, synced map[string]int64) error {
+	session, err := from.Load(id)
+	if err != nil {
+		return err
+	}
+	session.ID = fmt.Sprintf("%s-conflict-%d", id, time.Now().UnixMilli())
+	session.Title += " (conflict)"
+	logger.Warn("conflicting edits, older version kept", "session", id, "copy", session.ID)
+	if err := s.local.Save(session); err != nil {
+		return err
+	}
+	synced[session.ID] = session.UpdatedAt
+	return s.remote.Save(session)
+}
+
+func copySession(from, to storage.Store, id string) error {
+	session, err := from.Load(id)
+	if err != nil {
+		return err
+	}
+	return to.Save(session)
+}
+
+func metaByID(list []storage.SessionMeta) map[string]storage.SessionMeta {
+	m := make(map[string]storage.SessionMeta, len(list))
+	for _, meta := range list {
+		m[meta.ID] = meta
+	}
+	return m
+}
+
+// loadState returns the UpdatedAt of each session as of the last sync
+func (s *Service) loadState() map[string]int64 {
+	state := make(map[string]int64)
+	if data, err := os.ReadFile(s.statePath); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func (s *Service) saveState(state map[string]int64) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(s.statePath), 0755)
+	return os.WriteFile(s.statePath, data, 0644)
+}
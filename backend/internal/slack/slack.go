@@ -0,0 +1,103 @@
+// Package slack posts messages to Slack through an incoming webhook or the
+// chat.postMessage API.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// APIBase is the Slack Web API root
+var APIBase = "https://slack.com/api"
+
+// Client posts to one webhook or channel
+type Client struct {
+	webhookURL string
+	token      string
+	channel    string
+	http       *http.Client
+}
+
+// New creates a client for cfg. A bot token and channel take precedence
+// over the webhook.
+func New(cfg *config.SlackConfig) *Client {
+	token := cfg.BotToken
+	if token == "" {
+		token = os.Getenv("SLACK_BOT_TOKEN")
+	}
+	c := &Client{webhookURL: cfg.WebhookURL, http: &http.Client{Timeout: 15 * time.Second}}
+	if token != "" && cfg.Channel != "" {
+		c.token, c.channel = token, cfg.Channel
+	}
+	return c
+}
+
+// Threaded reports whether posts return a thread to reply in. Incoming
+// webhooks don't.
+func (c *Client) Threaded() bool {
+	return c.token != ""
+}
+
+// Post sends text, in reply to threadTS when set, and returns the new
+// message's timestamp for threading (empty for webhooks)
+func (c *Client) Post(ctx context.Context, text, threadTS string) (string, error) {
+	if c.token == "" {
+		if c.webhookURL == "" {
+			return "", errors.New("slack: no bot token for the channel and no webhook")
+		}
+		return "", c.postJSON(ctx, c.webhookURL, map[string]any{"text": text}, nil)
+	}
+
+	msg := map[string]any{"channel": c.channel, "text": text}
+	if threadTS != "" {
+		msg["thread_ts"] = threadTS
+	}
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := c.postJSON(ctx, APIBase+"/chat.postMessage", msg, &res); err != nil {
+		return "", err
+	}
+	if !res.OK {
+		return "", fmt.Errorf("slack: %s", res.Error)
+	}
+	return res.TS, nil
+}
+
+func (c *Client) postJSON(ctx context.Context, url string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: %s", resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// Backend is a key/value blob store that sessions and workspaces persist to.
+// Keys are slash separated relative paths such as "sessions/ws/id.json".
+// Get returns an error satisfying errors.Is(err, os.ErrNotExist) for
+// missing keys; Delete of a missing key is not an error.
+type Backend interface {
+	Get(key string) ([]byte, error)
+	Put(key string, data []byte) error
+	Delete(key string) error
+	List(prefix string) ([]string, error)
+}
+
+// NewBackend creates the backend selected by cfg; nil selects ~/.acpone on disk
+func NewBackend(cfg *config.StorageConfig) (Backend, error) {
+	if cfg == nil {
+		return NewLocalBackend(""), nil
+	}
+	switch cfg.Type {
+	case "", config.StorageLocal:
+		return NewLocalBackend(""), nil
+	case config.StorageS3:
+		return NewS3Backend(cfg.S3)
+	case config.StorageWebDAV:
+		return NewWebDAVBackend(cfg.WebDAV)
+	case config.StorageGit:
+		return NewGitBackend(cfg.Git)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Type)
+	}
+}
+
+// acponeDir returns the data directory, ~/.acpone by default
+func acponeDir() string {
+	return sysutil.DataDir()
+}
+
+// validKey rejects keys that could escape the backend root
+func validKey(key string) error {
+	if key == "" || path.IsAbs(key) || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key: %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." || part == "" {
+			return fmt.Errorf("invalid storage key: %q", key)
+		}
+	}
+	return nil
+}
+
+// LocalBackend stores blobs as files under a root directory
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend creates a filesystem backend rooted at root (default ~/.acpone)
+func NewLocalBackend(root string) *LocalBackend {
+	if root == "" {
+		root = acponeDir()
+	}
+	os.MkdirAll(root, 0755)
+	return &LocalBackend{root: root}
+}
+
+func (b *LocalBackend) path(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.root, filepath.FromSlash(key)), nil
+}
+
+// Get reads a blob
+func (b *LocalBackend) Get(key string) ([]byte, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+// Put atomically writes a blob, creating parent directories
+func (b *LocalBackend) Put(key string, data []byte) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// Delete removes a blob
+func (b *LocalBackend) Delete(key string) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns all keys starting with prefix
+func (b *LocalBackend) List(prefix string) ([]string, error) {
+	// Only walk the directory containing the prefix
+	start := b.root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		start = filepath.Join(b.root, filepath.FromSlash(prefix[:i]))
+	}
+
+	var keys []string
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// prefixBackend scopes a backend to keys under a prefix
+type prefixBackend struct {
+	Backend
+	prefix string
+}
+
+// WithPrefix returns a view of b where every key is stored under prefix
+func WithPrefix(b Backend, prefix string) Backend {
+	return &prefixBackend{Backend: b, prefix: prefix}
+}
+
+func (b *prefixBackend) Get(key string) ([]byte, error) {
+	return b.Backend.Get(b.prefix + key)
+}
+
+func (b *prefixBackend) Put(key string, data []byte) error {
+	return b.Backend.Put(b.prefix+key, data)
+}
+
+func (b *prefixBackend) Delete(key string) error {
+	return b.Backend.Delete(b.prefix + key)
+}
+
+func (b *prefixBackend) List(prefix string) ([]string, error) {
+	keys, err := b.Backend.List(b.prefix + prefix)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, b.prefix)
+	}
+	return keys, err
+}
+
+// mountBackend serves the keys under a prefix from another backend
+type mountBackend struct {
+	Backend
+	prefix string
+	sub    Backend
+}
+
+// Mount returns a view of b where the keys under prefix are those of sub;
+// keys b itself holds under prefix are hidden
+func Mount(b Backend, prefix string, sub Backend) Backend {
+	return &mountBackend{Backend: b, prefix: prefix, sub: sub}
+}
+
+func (b *mountBackend) route(key string) (Backend, string) {
+	if rest, ok := strings.CutPrefix(key, b.prefix); ok {
+		return b.sub, rest
+	}
+	return b.Backend, key
+}
+
+func (b *mountBackend) Get(key string) ([]byte, error) {
+	backend, key := b.route(key)
+	return backend.Get(key)
+}
+
+func (b *mountBackend) Put(key string, data []byte) error {
+	backend, key := b.route(key)
+	return backend.Put(key, data)
+}
+
+func (b *mountBackend) Delete(key string) error {
+	backend, key := b.route(key)
+	return backend.Delete(key)
+}
+
+func (b *mountBackend) List(prefix string) ([]string, error) {
+	if rest, ok := strings.CutPrefix(prefix, b.prefix); ok {
+		keys, err := b.sub.List(rest)
+		for i, key := range keys {
+			keys[i] = b.prefix + key
+		}
+		return keys, err
+	}
+
+	all, err := b.Backend.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, key := range all {
+		if !strings.HasPrefix(key, b.prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if strings.HasPrefix(b.prefix, prefix) {
+		sub, err := b.sub.List("")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range sub {
+			keys = append(keys, b.prefix+key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/daodao97/acpone/internal/config"
+	"github.com/daodao97/acpone/internal/sysutil"
+)
+
+// Syncable is implemented by backends that batch changes locally and
+// exchange them with a remote explicitly (git)
+type Syncable interface {
+	Pull() error
+	Push(message string) error
+}
+
+// GitBackend stores blobs in a local clone of a git repository.
+// The clone is only a mirror: Pull discards local state in favor of the
+// remote branch, Push commits and pushes everything written since.
+type GitBackend struct {
+	*LocalBackend
+	dir    string
+	repo   string
+	branch string
+}
+
+// NewGitBackend clones the repository if needed
+func NewGitBackend(cfg *config.GitConfig) (*GitBackend, error) {
+	if cfg == nil || cfg.Repo == "" {
+		return nil, errors.New("git: repo is required")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.New("git: git is not installed")
+	}
+	b := &GitBackend{dir: cfg.Dir, repo: cfg.Repo, branch: cfg.Branch}
+	if b.dir == "" {
+		b.dir = filepath.Join(acponeDir(), "sync-git")
+	}
+	if b.branch == "" {
+		b.branch = "main"
+	}
+
+	if _, err := os.Stat(filepath.Join(b.dir, ".git")); err != nil {
+		os.MkdirAll(filepath.Dir(b.dir), 0755)
+		if _, err := b.git(filepath.Dir(b.dir), "clone", "--quiet", b.repo, b.dir); err != nil {
+			return nil, err
+		}
+	}
+	b.LocalBackend = NewLocalBackend(b.dir)
+	return b, nil
+}
+
+func (b *GitBackend) git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	sysutil.HideWindow(cmd)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Pull resets the clone to the remote branch; an empty remote is not an error
+func (b *GitBackend) Pull() error {
+	if _, err := b.git(b.dir, "fetch", "--quiet", "origin"); err != nil {
+		return err
+	}
+	remote := "origin/" + b.branch
+	if _, err := b.git(b.dir, "rev-parse", "--verify", "--quiet", remote); err != nil {
+		return nil // Branch not pushed yet
+	}
+	if _, err := b.git(b.dir, "checkout", "--quiet", "-B", b.branch, remote); err != nil {
+		return err
+	}
+	if _, err := b.git(b.dir, "reset", "--quiet", "--hard", remote); err != nil {
+		return err
+	}
+	_, err := b.git(b.dir, "clean", "--quiet", "-fd")
+	return err
+}
+
+// Push commits all changes and pushes them to the remote branch
+func (b *GitBackend) Push(message string) error {
+	if _, err := b.git(b.dir, "add", "-A"); err != nil {
+		return err
+	}
+	status, err := b.git(b.dir, "status", "--porcelain")
+	if err != nil || status == "" {
+		return err
+	}
+	if _, err := b.git(b.dir, "-c", "user.name=acpone", "-c", "user.email=acpone@localhost",
+		"commit", "--quiet", "-m", message); err != nil {
+		return err
+	}
+	_, err = b.git(b.dir, "push", "--quiet", "origin", "HEAD:refs/heads/"+b.branch)
+	return err
+}
+
+// List skips git metadata
+func (b *GitBackend) List(prefix string) ([]string, error) {
+	keys, err := b.LocalBackend.List(prefix)
+	filtered := keys[:0]
+	for _, key := range keys {
+		if !strings.HasPrefix(key, ".git/") {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered, err
+}
@@ -4,7 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
-	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/daodao97/acpone/internal/conversation"
@@ -14,13 +15,21 @@ const defaultWorkspace = "_default"
 
 // StoredSession represents a persisted session
 type StoredSession struct {
-	ID          string                 `json:"id"`
-	Title       string                 `json:"title"`
-	Messages    []conversation.Message `json:"messages"`
-	ActiveAgent string                 `json:"activeAgent"`
-	WorkspaceID string                 `json:"workspaceId,omitempty"`
-	CreatedAt   int64                  `json:"createdAt"`
-	UpdatedAt   int64                  `json:"updatedAt"`
+	ID          string                    `json:"id"`
+	Title       string                    `json:"title"`
+	Messages    []conversation.Message    `json:"messages"`
+	ActiveAgent string                    `json:"activeAgent"`
+	WorkspaceID string                    `json:"workspaceId,omitempty"`
+	MentionMode string                    `json:"mentionMode,omitempty"`
+	AgentParams map[string]any            `json:"agentParams,omitempty"`
+	Limits      *conversation.Limits      `json:"limits,omitempty"`
+	Pins        []conversation.Pin        `json:"pins,omitempty"`
+	Annotations []conversation.Annotation `json:"annotations,omitempty"`
+	Turns       []conversation.Turn       `json:"turns,omitempty"`
+	Branch      string                    `json:"branch,omitempty"`
+	CreatedAt   int64                     `json:"createdAt"`
+	UpdatedAt   int64                     `json:"updatedAt"`
+	DeletedAt   int64                     `json:"deletedAt,omitempty"` // Set while in the trash
 }
 
 // SessionMeta is metadata for listing
@@ -29,168 +38,151 @@ type SessionMeta struct {
 	Title        string `json:"title"`
 	ActiveAgent  string `json:"activeAgent"`
 	WorkspaceID  string `json:"workspaceId,omitempty"`
+	Branch       string `json:"branch,omitempty"`
 	MessageCount int    `json:"messageCount"`
+	Annotations  int    `json:"annotations,omitempty"` // Annotated messages
 	CreatedAt    int64  `json:"createdAt"`
 	UpdatedAt    int64  `json:"updatedAt"`
 }
 
-// SessionStore manages session persistence
+// SessionStore manages session persistence.
+// Sessions are stored as <workspace>/<id>.json in the backend.
 type SessionStore struct {
-	baseDir string
+	backend Backend
+
+	mu      sync.Mutex            // Guards the cached index
+	index   map[string]indexEntry // Session metadata by ID
+	indexed time.Time             // When index was last read from the backend
 }
 
-// NewSessionStore creates a new session store
+// NewSessionStore creates a session store on disk (default ~/.acpone/sessions)
 func NewSessionStore(baseDir string) *SessionStore {
 	if baseDir == "" {
-		baseDir = defaultBaseDir()
+		baseDir = filepath.Join(acponeDir(), "sessions")
 	}
-	os.MkdirAll(baseDir, 0755)
-	return &SessionStore{baseDir: baseDir}
+	return NewSessionStoreWithBackend(NewLocalBackend(baseDir))
 }
 
-func defaultBaseDir() string {
-	home := os.Getenv("HOME")
-	if home == "" {
-		home = os.Getenv("USERPROFILE")
-	}
-	if home == "" {
-		home = "."
-	}
-	return filepath.Join(home, ".acpone", "sessions")
+// NewSessionStoreWithBackend creates a session store on any backend
+func NewSessionStoreWithBackend(backend Backend) *SessionStore {
+	return &SessionStore{backend: backend}
 }
 
-func (s *SessionStore) workspaceDir(workspaceID string) string {
+func sessionKey(id, workspaceID string) string {
 	if workspaceID == "" {
 		workspaceID = defaultWorkspace
 	}
-	return filepath.Join(s.baseDir, workspaceID)
+	return workspaceID + "/" + id + ".json"
 }
 
-func (s *SessionStore) filePath(id, workspaceID string) string {
-	wsDir := s.workspaceDir(workspaceID)
-	os.MkdirAll(wsDir, 0755)
-	return filepath.Join(wsDir, id+".json")
-}
-
-func (s *SessionStore) findFile(id string) (string, string) {
-	entries, err := os.ReadDir(s.baseDir)
+// findKey locates a session's key
+func (s *SessionStore) findKey(id string) string {
+	// Fast path: the index knows the session's workspace
+	s.mu.Lock()
+	index, err := s.loadIndex()
+	entry, ok := index[id]
+	s.mu.Unlock()
 	if err != nil {
-		return "", ""
+		return ""
+	}
+	if ok {
+		return entry.key
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		filePath := filepath.Join(s.baseDir, entry.Name(), id+".json")
-		if _, err := os.Stat(filePath); err == nil {
-			wsID := entry.Name()
-			if wsID == defaultWorkspace {
-				wsID = ""
-			}
-			return filePath, wsID
+	keys, err := s.backend.List("")
+	if err != nil {
+		return ""
+	}
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/"+id+".json") && strings.Count(key, "/") == 1 {
+			return key
 		}
 	}
-	return "", ""
+	return ""
 }
 
 // Save saves a session
 func (s *SessionStore) Save(session *StoredSession) error {
-	filePath := s.filePath(session.ID, session.WorkspaceID)
-	data, err := json.MarshalIndent(session, "", "  ")
-	if err != nil {
+	key := sessionKey(session.ID, session.WorkspaceID)
+	if err := s.put(key, session); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateIndex(key, session)
+}
+
+// Move saves a session under its workspace and removes the file left under
+// the workspace it was stored in before
+func (s *SessionStore) Move(session *StoredSession) error {
+	oldKey := s.findKey(session.ID)
+	if err := s.Save(session); err != nil {
 		return err
 	}
-	return os.WriteFile(filePath, data, 0644)
+	if oldKey == "" || oldKey == sessionKey(session.ID, session.WorkspaceID) {
+		return nil
+	}
+	return s.backend.Delete(oldKey)
 }
 
 // Load loads a session by ID
 func (s *SessionStore) Load(id string) (*StoredSession, error) {
-	filePath, _ := s.findFile(id)
-	if filePath == "" {
+	key := s.findKey(id)
+	if key == "" {
 		return nil, os.ErrNotExist
 	}
+	return s.get(key)
+}
 
-	data, err := os.ReadFile(filePath)
+// get reads the session stored under key
+func (s *SessionStore) get(key string) (*StoredSession, error) {
+	data, err := s.backend.Get(key)
 	if err != nil {
 		return nil, err
 	}
-
 	var session StoredSession
 	if err := json.Unmarshal(data, &session); err != nil {
 		return nil, err
 	}
-
 	return &session, nil
 }
 
+// put writes a session under key
+func (s *SessionStore) put(key string, session *StoredSession) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.backend.Put(key, data)
+}
+
 // Delete deletes a session
 func (s *SessionStore) Delete(id string) error {
-	filePath, _ := s.findFile(id)
-	if filePath == "" {
-		return nil
+	if key := s.findKey(id); key != "" {
+		if err := s.backend.Delete(key); err != nil {
+			return err
+		}
 	}
-	return os.Remove(filePath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeFromIndex(id)
 }
 
-// List returns all session metadata
+// List returns all session metadata from the index
 func (s *SessionStore) List() []SessionMeta {
-	var sessions []SessionMeta
-
-	entries, err := os.ReadDir(s.baseDir)
-	if err != nil {
-		return sessions
+	s.mu.Lock()
+	index, err := s.loadIndex()
+	sessions := make([]SessionMeta, 0, len(index))
+	for _, entry := range index {
+		sessions = append(sessions, entry.meta)
 	}
-
-	for _, wsEntry := range entries {
-		if !wsEntry.IsDir() {
-			continue
-		}
-
-		wsDir := filepath.Join(s.baseDir, wsEntry.Name())
-		files, err := os.ReadDir(wsDir)
-		if err != nil {
-			continue
-		}
-
-		for _, file := range files {
-			if filepath.Ext(file.Name()) != ".json" {
-				continue
-			}
-
-			filePath := filepath.Join(wsDir, file.Name())
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
-
-			var session StoredSession
-			if err := json.Unmarshal(data, &session); err != nil {
-				continue
-			}
-
-			wsID := session.WorkspaceID
-			if wsID == "" && wsEntry.Name() != defaultWorkspace {
-				wsID = wsEntry.Name()
-			}
-
-			sessions = append(sessions, SessionMeta{
-				ID:           session.ID,
-				Title:        session.Title,
-				ActiveAgent:  session.ActiveAgent,
-				WorkspaceID:  wsID,
-				MessageCount: len(session.Messages),
-				CreatedAt:    session.CreatedAt,
-				UpdatedAt:    session.UpdatedAt,
-			})
-		}
+	s.mu.Unlock()
+	if err != nil {
+		return nil
 	}
-
-	// Sort by updatedAt descending
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].UpdatedAt > sessions[j].UpdatedAt
-	})
-
+	sortSessions(sessions)
 	return sessions
 }
 
@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// indexDir holds a small metadata object per session so listing does not
+// read full transcripts. Objects are named .index/<id>.<version>.json, the
+// version hashing the metadata: a save writes only its own session's
+// object, so machines sharing a remote never overwrite each other's
+// entries, and a refresh only fetches the objects whose names it has not
+// seen yet.
+const indexDir = ".index"
+
+// legacyIndexFile is the single shared index of earlier versions
+const legacyIndexFile = "index.json"
+
+// indexTTL is how long listings are served from the cached index before
+// the backend is listed again for changes made elsewhere
+const indexTTL = 10 * time.Second
+
+// indexFetchers bounds the reads in flight while refreshing the index
+const indexFetchers = 8
+
+// indexEntry is a cached session's metadata and where it is stored
+type indexEntry struct {
+	key     string // Session file
+	metaKey string // Metadata object
+	meta    SessionMeta
+}
+
+func metaKey(meta SessionMeta) (string, []byte, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", nil, err
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	return fmt.Sprintf("%s/%s.%x.json", indexDir, meta.ID, h.Sum64()), data, nil
+}
+
+// parseMetaKey returns the session ID of a metadata object key
+func parseMetaKey(key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, indexDir+"/")
+	if !ok || strings.Contains(name, "/") {
+		return "", false
+	}
+	name, ok = strings.CutSuffix(name, ".json")
+	i := strings.LastIndex(name, ".")
+	if !ok || i <= 0 {
+		return "", false
+	}
+	return name[:i], true
+}
+
+// parseFileKey returns the workspace directory and session ID of a session
+// file key; the trash, the index and other dot directories are not sessions
+func parseFileKey(key string) (wsDir, id string, ok bool) {
+	wsDir, name, ok := strings.Cut(key, "/")
+	if !ok || strings.HasPrefix(wsDir, ".") || strings.Contains(name, "/") || path.Ext(name) != ".json" {
+		return "", "", false
+	}
+	return wsDir, strings.TrimSuffix(name, ".json"), true
+}
+
+// loadIndex returns the cached index, refreshing it from the backend once
+// it is older than indexTTL (caller holds s.mu). Backend errors are returned
+// so that an unreachable remote never passes for an empty one.
+func (s *SessionStore) loadIndex() (map[string]indexEntry, error) {
+	if s.index != nil && time.Since(s.indexed) < indexTTL {
+		return s.index, nil
+	}
+	index, err := s.refreshIndex(false)
+	if err != nil {
+		return nil, err
+	}
+	s.index, s.indexed = index, time.Now()
+	return index, nil
+}
+
+// refreshIndex rebuilds the index from one listing of the backend, reading
+// only the metadata objects not cached yet (caller holds s.mu). Sessions
+// without metadata, such as those written by earlier versions, and every
+// session when rebuild is set, have it written from their file; metadata
+// of sessions deleted elsewhere and versions superseded by a later save
+// are removed.
+func (s *SessionStore) refreshIndex(rebuild bool) (map[string]indexEntry, error) {
+	keys, err := s.backend.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)      // Session ID -> file key
+	versions := make(map[string][]string) // Session ID -> metadata keys
+	for _, key := range keys {
+		if id, ok := parseMetaKey(key); ok {
+			versions[id] = append(versions[id], key)
+		} else if _, id, ok := parseFileKey(key); ok {
+			files[id] = key
+		} else if key == legacyIndexFile {
+			s.backend.Delete(key)
+		}
+	}
+
+	cached := make(map[string]indexEntry) // By metadata key
+	for _, entry := range s.index {
+		cached[entry.metaKey] = entry
+	}
+	var fetch []string
+	for id, key := range files {
+		if rebuild || len(versions[id]) == 0 {
+			fetch = append(fetch, key)
+			continue
+		}
+		for _, mk := range versions[id] {
+			if _, ok := cached[mk]; !ok {
+				fetch = append(fetch, mk)
+			}
+		}
+	}
+	fetched := s.getAll(fetch)
+
+	index := make(map[string]indexEntry, len(files))
+	for id, key := range files {
+		var best indexEntry
+		found := false
+		for _, mk := range versions[id] {
+			if rebuild {
+				break
+			}
+			entry, ok := cached[mk]
+			if !ok {
+				var meta SessionMeta
+				if json.Unmarshal(fetched[mk], &meta) != nil || meta.ID != id {
+					continue
+				}
+				entry = indexEntry{metaKey: mk, meta: meta}
+			}
+			// Concurrent saves can leave two versions: the latest wins
+			if !found || entry.meta.UpdatedAt > best.meta.UpdatedAt ||
+				entry.meta.UpdatedAt == best.meta.UpdatedAt && mk == s.index[id].metaKey {
+				best, found = entry, true
+			}
+		}
+		if !found {
+			entry, err := s.indexFile(key, fetched[key])
+			if err != nil {
+				continue
+			}
+			best = entry
+		}
+		best.key = key
+		index[id] = best
+	}
+
+	for id, mks := range versions {
+		for _, mk := range mks {
+			if index[id].metaKey != mk {
+				s.backend.Delete(mk)
+			}
+		}
+	}
+	return index, nil
+}
+
+// indexFile writes the metadata of the session file under key, read again
+// when data is missing (caller holds s.mu)
+func (s *SessionStore) indexFile(key string, data []byte) (indexEntry, error) {
+	if data == nil {
+		var err error
+		if data, err = s.backend.Get(key); err != nil {
+			return indexEntry{}, err
+		}
+	}
+	var session StoredSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return indexEntry{}, err
+	}
+	meta := sessionMeta(&session)
+	if wsDir, _, _ := parseFileKey(key); meta.WorkspaceID == "" && wsDir != defaultWorkspace {
+		meta.WorkspaceID = wsDir
+	}
+	mk, err := s.putMeta(meta)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	return indexEntry{key: key, metaKey: mk, meta: meta}, nil
+}
+
+// getAll reads keys concurrently, leaving out those that fail
+func (s *SessionStore) getAll(keys []string) map[string][]byte {
+	results := make(map[string][]byte, len(keys))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, indexFetchers)
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			data, err := s.backend.Get(key)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			results[key] = data
+			mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+	return results
+}
+
+// putMeta writes a session's metadata object, returning its key
+func (s *SessionStore) putMeta(meta SessionMeta) (string, error) {
+	mk, data, err := metaKey(meta)
+	if err != nil {
+		return "", err
+	}
+	return mk, s.backend.Put(mk, data)
+}
+
+// updateIndex records a session saved under key (caller holds s.mu)
+func (s *SessionStore) updateIndex(key string, session *StoredSession) error {
+	meta := sessionMeta(session)
+	mk, err := s.putMeta(meta)
+	if err != nil {
+		return err
+	}
+	if s.index == nil {
+		// Not loaded yet: the next listing picks the object up
+		s.index = make(map[string]indexEntry)
+	}
+	old, ok := s.index[meta.ID]
+	s.index[meta.ID] = indexEntry{key: key, metaKey: mk, meta: meta}
+	if ok && old.metaKey != mk {
+		// Left over versions are also removed by the next refresh
+		s.backend.Delete(old.metaKey)
+	}
+	return nil
+}
+
+// removeFromIndex forgets a deleted session (caller holds s.mu)
+func (s *SessionStore) removeFromIndex(id string) error {
+	entry, ok := s.index[id]
+	if !ok {
+		// Not cached: the next refresh drops the metadata of the missing file
+		return nil
+	}
+	delete(s.index, id)
+	return s.backend.Delete(entry.metaKey)
+}
+
+// Refresh makes the next listing read the index from the backend instead
+// of the cache, for callers that must see changes made elsewhere at once
+func (s *SessionStore) Refresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexed = time.Time{}
+}
+
+// RebuildIndex regenerates the metadata of every session from its file
+func (s *SessionStore) RebuildIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.refreshIndex(true)
+	if err != nil {
+		return err
+	}
+	s.index, s.indexed = index, time.Now()
+	return nil
+}
+
+func sessionMeta(session *StoredSession) SessionMeta {
+	return SessionMeta{
+		ID:           session.ID,
+		Title:        session.Title,
+		ActiveAgent:  session.ActiveAgent,
+		WorkspaceID:  session.WorkspaceID,
+		Branch:       session.Branch,
+		MessageCount: len(session.Messages),
+		Annotations:  len(session.Annotations),
+		CreatedAt:    session.CreatedAt,
+		UpdatedAt:    session.UpdatedAt,
+	}
+}
+
+func sortSessions(sessions []SessionMeta) {
+	// Sort by updatedAt descending
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt > sessions[j].UpdatedAt
+	})
+}
@@ -0,0 +1,423 @@
+//go:build cgo
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteMigrations upgrade the schema in order; the database's user_version
+// is the number applied
+var sqliteMigrations = []string{
+	`CREATE TABLE sessions (
+		id            TEXT PRIMARY KEY,
+		workspace_id  TEXT NOT NULL DEFAULT '',
+		title         TEXT NOT NULL DEFAULT '',
+		active_agent  TEXT NOT NULL DEFAULT '',
+		branch        TEXT NOT NULL DEFAULT '',
+		message_count INTEGER NOT NULL DEFAULT 0,
+		annotations   INTEGER NOT NULL DEFAULT 0,
+		created_at    INTEGER NOT NULL DEFAULT 0,
+		updated_at    INTEGER NOT NULL DEFAULT 0,
+		deleted_at    INTEGER NOT NULL DEFAULT 0,
+		data          BLOB NOT NULL
+	);
+	CREATE INDEX sessions_updated ON sessions (deleted_at, updated_at DESC);
+	CREATE INDEX sessions_workspace ON sessions (workspace_id, deleted_at, updated_at DESC);
+	CREATE INDEX sessions_agent ON sessions (active_agent, deleted_at, updated_at DESC);`,
+}
+
+// metaColumns are the columns scanned into a SessionMeta
+const metaColumns = "id, title, active_agent, workspace_id, branch, message_count, annotations, created_at, updated_at"
+
+// SQLiteStore keeps sessions in a SQLite database: the metadata in indexed
+// columns for listing and queries, the session itself as JSON. Every write
+// is a transaction.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// OpenSQLiteStore opens the database at path, creating and migrating it as
+// needed. A new database is filled with the sessions of legacy, the JSON
+// store it replaces, when given; legacy itself is left untouched.
+func OpenSQLiteStore(path string, legacy *SessionStore) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// One connection serializes writers, so there is no SQLITE_BUSY within
+	// the process
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db}
+	version, err := s.migrate()
+	if err == nil && version == 0 && legacy != nil {
+		err = s.importSessions(legacy)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the pending migrations, returning the schema version
+// the database had
+func (s *SQLiteStore) migrate() (int, error) {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version > len(sqliteMigrations) {
+		return version, fmt.Errorf("schema version %d is newer than this acpone supports", version)
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		err := s.transact(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
+			return err
+		})
+		if err != nil {
+			return version, fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return version, nil
+}
+
+// importSessions copies the sessions and trash of a JSON store
+func (s *SQLiteStore) importSessions(legacy *SessionStore) error {
+	return s.transact(func(tx *sql.Tx) error {
+		for _, meta := range legacy.List() {
+			session, err := legacy.Load(meta.ID)
+			if err != nil {
+				continue
+			}
+			if err := putSession(tx, session); err != nil {
+				return err
+			}
+		}
+		for _, session := range legacy.trashed() {
+			if err := putSession(tx, session); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// transact runs fn in a transaction, committing when it succeeds
+func (s *SQLiteStore) transact(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer is a database or transaction
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// putSession inserts or replaces a session row
+func putSession(ex execer, session *StoredSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	meta := sessionMeta(session)
+	_, err = ex.Exec(`INSERT OR REPLACE INTO sessions
+		(id, workspace_id, title, active_agent, branch, message_count, annotations, created_at, updated_at, deleted_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		meta.ID, meta.WorkspaceID, meta.Title, meta.ActiveAgent, meta.Branch, meta.MessageCount,
+		meta.Annotations, meta.CreatedAt, meta.UpdatedAt, session.DeletedAt, data)
+	return err
+}
+
+// querier is a database or transaction
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// getSession reads a session, in the trash or not as trashed says
+func getSession(q querier, id string, trashed bool) (*StoredSession, error) {
+	var data []byte
+	err := q.QueryRow("SELECT data FROM sessions WHERE id = ? AND (deleted_at != 0) = ?", id, trashed).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session StoredSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Save saves a session
+func (s *SQLiteStore) Save(session *StoredSession) error {
+	return putSession(s.db, session)
+}
+
+// Move saves a session; its workspace is just a column
+func (s *SQLiteStore) Move(session *StoredSession) error {
+	return s.Save(session)
+}
+
+// Load loads a session by ID
+func (s *SQLiteStore) Load(id string) (*StoredSession, error) {
+	return getSession(s.db, id, false)
+}
+
+// Delete deletes a session
+func (s *SQLiteStore) Delete(id string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE id = ? AND deleted_at = 0", id)
+	return err
+}
+
+// List returns the metadata of all sessions, most recently updated first
+func (s *SQLiteStore) List() []SessionMeta {
+	return s.Query(SessionQuery{}).Sessions
+}
+
+// Query returns one page of the sessions matching q, most recently updated
+// first, using the indexes on workspace, agent and update time
+func (s *SQLiteStore) Query(q SessionQuery) SessionPage {
+	where := []string{"deleted_at = 0"}
+	var args []any
+	if q.WorkspaceID != "" {
+		where = append(where, "workspace_id = ?")
+		args = append(args, q.WorkspaceID)
+	}
+	if q.Agent != "" {
+		where = append(where, "active_agent = ?")
+		args = append(args, q.Agent)
+	}
+	if q.UpdatedAfter > 0 {
+		where = append(where, "updated_at > ?")
+		args = append(args, q.UpdatedAfter)
+	}
+	cond := strings.Join(where, " AND ")
+
+	page := SessionPage{Sessions: []SessionMeta{}}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE "+cond, args...).Scan(&page.Total); err != nil {
+		return page
+	}
+	limit := -1 // No limit
+	if q.Limit > 0 {
+		limit = q.Limit
+	}
+	rows, err := s.db.Query("SELECT "+metaColumns+" FROM sessions WHERE "+cond+" ORDER BY updated_at DESC LIMIT ? OFFSET ?",
+		append(args, limit, max(q.Offset, 0))...)
+	if err != nil {
+		return page
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var meta SessionMeta
+		if err := rows.Scan(&meta.ID, &meta.Title, &meta.ActiveAgent, &meta.WorkspaceID, &meta.Branch,
+			&meta.MessageCount, &meta.Annotations, &meta.CreatedAt, &meta.UpdatedAt); err != nil {
+			break
+		}
+		page.Sessions = append(page.Sessions, meta)
+	}
+	return page
+}
+
+// Trash moves a session to the trash
+func (s *SQLiteStore) Trash(id string) error {
+	return s.transact(func(tx *sql.Tx) error {
+		session, err := getSession(tx, id, false)
+		if err != nil {
+			return err
+		}
+		session.DeletedAt = time.Now().UnixMilli()
+		return putSession(tx, session)
+	})
+}
+
+// ListTrash returns the trashed sessions, most recently deleted first
+func (s *SQLiteStore) ListTrash() []TrashedSession {
+	sessions := []TrashedSession{}
+	rows, err := s.db.Query("SELECT " + metaColumns + ", deleted_at FROM sessions WHERE deleted_at != 0 ORDER BY deleted_at DESC")
+	if err != nil {
+		return sessions
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t TrashedSession
+		if err := rows.Scan(&t.ID, &t.Title, &t.ActiveAgent, &t.WorkspaceID, &t.Branch,
+			&t.MessageCount, &t.Annotations, &t.CreatedAt, &t.UpdatedAt, &t.DeletedAt); err != nil {
+			break
+		}
+		sessions = append(sessions, t)
+	}
+	return sessions
+}
+
+// Restore takes a session out of the trash
+func (s *SQLiteStore) Restore(id string) (*StoredSession, error) {
+	var session *StoredSession
+	err := s.transact(func(tx *sql.Tx) error {
+		var err error
+		if session, err = getSession(tx, id, true); err != nil {
+			return err
+		}
+		session.DeletedAt = 0
+		return putSession(tx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// PurgeTrash permanently deletes the sessions trashed before the cutoff,
+// returning them so their uploads can be cleaned up
+func (s *SQLiteStore) PurgeTrash(before time.Time) ([]*StoredSession, error) {
+	var purged []*StoredSession
+	err := s.transact(func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT data FROM sessions WHERE deleted_at != 0 AND deleted_at < ?", before.UnixMilli())
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var data []byte
+			var session StoredSession
+			if rows.Scan(&data) == nil && json.Unmarshal(data, &session) == nil {
+				purged = append(purged, &session)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM sessions WHERE deleted_at != 0 AND deleted_at < ?", before.UnixMilli())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// Backend returns a view of the sessions as blobs keyed as a SessionStore
+// keys them (<workspace>/<id>.json, under .trash/ when trashed), so backups
+// hold sessions in the same form whichever store keeps them
+func (s *SQLiteStore) Backend() Backend {
+	return sqliteBackend{s}
+}
+
+// sqliteBackend is the blob view of a SQLiteStore
+type sqliteBackend struct {
+	store *SQLiteStore
+}
+
+// parseSessionKey splits a session blob key into the session ID and whether
+// it is in the trash
+func parseSessionKey(key string) (id string, trashed bool, ok bool) {
+	rest, trashed := strings.CutPrefix(key, trashDir+"/")
+	wsDir, name, ok := strings.Cut(rest, "/")
+	if !ok || strings.HasPrefix(wsDir, ".") || strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") {
+		return "", false, false
+	}
+	return strings.TrimSuffix(name, ".json"), trashed, true
+}
+
+// Get reads a session as JSON
+func (b sqliteBackend) Get(key string) ([]byte, error) {
+	id, trashed, ok := parseSessionKey(key)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	session, err := getSession(b.store.db, id, trashed)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(session, "", "  ")
+}
+
+// Put saves a session given as JSON. Other keys, such as the index of a
+// JSON store, are ignored: the database indexes sessions itself.
+func (b sqliteBackend) Put(key string, data []byte) error {
+	id, trashed, ok := parseSessionKey(key)
+	if !ok {
+		return nil
+	}
+	var session StoredSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	if session.ID == "" {
+		session.ID = id
+	}
+	switch {
+	case !trashed:
+		session.DeletedAt = 0
+	case session.DeletedAt == 0:
+		session.DeletedAt = time.Now().UnixMilli()
+	}
+	return putSession(b.store.db, &session)
+}
+
+// Delete deletes a session
+func (b sqliteBackend) Delete(key string) error {
+	id, trashed, ok := parseSessionKey(key)
+	if !ok {
+		return nil
+	}
+	_, err := b.store.db.Exec("DELETE FROM sessions WHERE id = ? AND (deleted_at != 0) = ?", id, trashed)
+	return err
+}
+
+// List returns the keys of all sessions starting with prefix
+func (b sqliteBackend) List(prefix string) ([]string, error) {
+	rows, err := b.store.db.Query("SELECT id, workspace_id, deleted_at FROM sessions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var id, workspaceID string
+		var deletedAt int64
+		if err := rows.Scan(&id, &workspaceID, &deletedAt); err != nil {
+			return nil, err
+		}
+		key := sessionKey(id, workspaceID)
+		if deletedAt != 0 {
+			key = trashKey(id, workspaceID)
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, rows.Err()
+}
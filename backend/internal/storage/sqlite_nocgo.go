@@ -0,0 +1,25 @@
+//go:build !cgo
+
+package storage
+
+import "errors"
+
+// SQLiteStore is unavailable: the SQLite driver needs cgo
+type SQLiteStore struct {
+	Store
+}
+
+// OpenSQLiteStore fails in builds without cgo
+func OpenSQLiteStore(path string, legacy *SessionStore) (*SQLiteStore, error) {
+	return nil, errors.New("the sqlite session store requires a build with cgo")
+}
+
+// Close does nothing
+func (s *SQLiteStore) Close() error {
+	return nil
+}
+
+// Backend returns nil
+func (s *SQLiteStore) Backend() Backend {
+	return nil
+}
@@ -0,0 +1,164 @@
+//go:build cgo
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func openTestSQLite(t *testing.T, legacy *SessionStore) *SQLiteStore {
+	t.Helper()
+	s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "sessions.db"), legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStoreQuery(t *testing.T) {
+	s := openTestSQLite(t, nil)
+	for _, session := range []*StoredSession{
+		testSession("a", "ws1", "claude", 100),
+		testSession("b", "ws1", "codex", 200),
+		testSession("c", "ws2", "claude", 300),
+		testSession("d", "", "claude", 400),
+	} {
+		if err := s.Save(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query SessionQuery
+		want  []string
+		total int
+	}{
+		{"all", SessionQuery{}, []string{"d", "c", "b", "a"}, 4},
+		{"workspace", SessionQuery{WorkspaceID: "ws1"}, []string{"b", "a"}, 2},
+		{"agent", SessionQuery{Agent: "claude"}, []string{"d", "c", "a"}, 3},
+		{"updated after excludes the cutoff", SessionQuery{UpdatedAfter: 200}, []string{"d", "c"}, 2},
+		{"page", SessionQuery{Offset: 1, Limit: 2}, []string{"c", "b"}, 4},
+		{"past the end", SessionQuery{Offset: 10}, nil, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := s.Query(tt.query)
+			if got := ids(page.Sessions); !slices.Equal(got, tt.want) || page.Total != tt.total {
+				t.Errorf("got %v (total %d), want %v (total %d)", got, page.Total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestSQLiteStoreTrash(t *testing.T) {
+	s := openTestSQLite(t, nil)
+	s.Save(testSession("a", "ws1", "claude", 100))
+
+	if err := s.Trash("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("a"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load of a trashed session: %v", err)
+	}
+	if len(s.List()) != 0 || len(s.ListTrash()) != 1 {
+		t.Fatalf("List %v, ListTrash %v", s.List(), s.ListTrash())
+	}
+
+	restored, err := s.Restore("a")
+	if err != nil || restored.DeletedAt != 0 {
+		t.Fatalf("Restore: %v, %+v", err, restored)
+	}
+	if _, err := s.Load("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Trash("a")
+	purged, err := s.PurgeTrash(time.Now().Add(time.Minute))
+	if err != nil || len(purged) != 1 || purged[0].ID != "a" {
+		t.Fatalf("PurgeTrash: %v, %v", err, purged)
+	}
+	if len(s.ListTrash()) != 0 {
+		t.Fatal("trash not empty after purge")
+	}
+}
+
+func TestSQLiteStoreImportsJSONSessions(t *testing.T) {
+	dir := t.TempDir()
+	legacy := NewSessionStore(filepath.Join(dir, "sessions"))
+	legacy.Save(testSession("a", "ws1", "claude", 100))
+	legacy.Save(testSession("b", "", "codex", 200))
+	legacy.Trash("b")
+
+	path := filepath.Join(dir, "sessions.db")
+	s, err := OpenSQLiteStore(path, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(s.List()); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("List: got %v, want [a]", got)
+	}
+	if trash := s.ListTrash(); len(trash) != 1 || trash[0].ID != "b" {
+		t.Errorf("ListTrash: %v", trash)
+	}
+	s.Close()
+
+	// An existing database is not imported into again
+	legacy.Save(testSession("c", "", "claude", 300))
+	s, err = OpenSQLiteStore(path, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := ids(s.List()); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("List after reopening: got %v, want [a]", got)
+	}
+}
+
+func TestSQLiteBackend(t *testing.T) {
+	s := openTestSQLite(t, nil)
+	s.Save(testSession("a", "ws1", "claude", 100))
+	s.Save(testSession("b", "", "claude", 200))
+	s.Trash("b")
+
+	base := NewLocalBackend(t.TempDir())
+	base.Put("workspaces.json", []byte("{}"))
+	base.Put("sessions/ws1/stale.json", []byte("{}")) // Hidden by the mount
+	b := Mount(base, "sessions/", s.Backend())
+
+	keys, err := b.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	want := []string{"sessions/.trash/_default/b.json", "sessions/ws1/a.json", "workspaces.json"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("List: got %v, want %v", keys, want)
+	}
+
+	data, err := b.Get("sessions/ws1/a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete("sessions/ws1/a.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("a"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load after Delete: %v", err)
+	}
+	if err := b.Put("sessions/ws1/a.json", data); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := s.Load("a"); err != nil || session.WorkspaceID != "ws1" {
+		t.Fatalf("Load after Put: %v, %+v", err, session)
+	}
+	if err := b.Put("sessions/index.json", []byte("{}")); err != nil {
+		t.Errorf("Put of the JSON index: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// Store persists sessions. SessionStore keeps them as JSON blobs on a
+// Backend with an index for listing; SQLiteStore keeps them in a database.
+type Store interface {
+	Save(session *StoredSession) error
+	Move(session *StoredSession) error
+	Load(id string) (*StoredSession, error)
+	Delete(id string) error
+	// List returns the metadata of all sessions, most recently updated first
+	List() []SessionMeta
+	// Query returns one page of the sessions matching q
+	Query(q SessionQuery) SessionPage
+
+	Trash(id string) error
+	ListTrash() []TrashedSession
+	Restore(id string) (*StoredSession, error)
+	PurgeTrash(before time.Time) ([]*StoredSession, error)
+}
+
+var _ Store = (*SessionStore)(nil)
+
+// sqliteFile is the session database, in the data directory
+const sqliteFile = "sessions.db"
+
+// OpenSessions opens the session store cfg selects. Sessions stored as JSON
+// live under sessions/ in backend, the data backend; the returned backend
+// is backend with sessions/ served by the store, for backups. When the
+// SQLite store can't be opened, the JSON store is returned with the error.
+func OpenSessions(cfg *config.StorageConfig, backend Backend) (Store, Backend, error) {
+	jsonStore := NewSessionStoreWithBackend(WithPrefix(backend, "sessions/"))
+	if cfg == nil || cfg.Sessions != config.SessionsSQLite {
+		return jsonStore, backend, nil
+	}
+	db, err := OpenSQLiteStore(filepath.Join(acponeDir(), sqliteFile), jsonStore)
+	if err != nil {
+		return jsonStore, backend, err
+	}
+	return db, Mount(backend, "sessions/", db.Backend()), nil
+}
+
+// SessionQuery filters and pages session listings. Zero fields match
+// everything; Limit 0 returns all sessions after Offset.
+type SessionQuery struct {
+	WorkspaceID  string
+	Agent        string // Active agent
+	UpdatedAfter int64  // Updated strictly after, in Unix milliseconds
+	Offset       int
+	Limit        int
+}
+
+// SessionPage is a page of matching sessions with the number of matches
+type SessionPage struct {
+	Sessions []SessionMeta `json:"sessions"`
+	Total    int           `json:"total"`
+}
+
+// matches reports whether a session is selected by the query's filters
+func (q SessionQuery) matches(meta SessionMeta) bool {
+	switch {
+	case q.WorkspaceID != "" && meta.WorkspaceID != q.WorkspaceID:
+	case q.Agent != "" && meta.ActiveAgent != q.Agent:
+	case q.UpdatedAfter > 0 && meta.UpdatedAt <= q.UpdatedAfter:
+	default:
+		return true
+	}
+	return false
+}
+
+// Query returns one page of the indexed sessions matching q, most recently
+// updated first
+func (s *SessionStore) Query(q SessionQuery) SessionPage {
+	matched := []SessionMeta{}
+	for _, meta := range s.List() {
+		if q.matches(meta) {
+			matched = append(matched, meta)
+		}
+	}
+	page := SessionPage{Total: len(matched)}
+	start := min(max(q.Offset, 0), len(matched))
+	end := len(matched)
+	if q.Limit > 0 {
+		end = min(start+q.Limit, end)
+	}
+	page.Sessions = matched[start:end]
+	return page
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func testSession(id, workspaceID, agent string, updatedAt int64) *StoredSession {
+	session := CreateSession(id, agent, workspaceID)
+	session.CreatedAt = updatedAt
+	session.UpdatedAt = updatedAt
+	return session
+}
+
+func ids(sessions []SessionMeta) []string {
+	var out []string
+	for _, meta := range sessions {
+		out = append(out, meta.ID)
+	}
+	return out
+}
+
+func TestSessionStoreQuery(t *testing.T) {
+	s := NewSessionStore(t.TempDir())
+	s.Save(testSession("a", "ws1", "claude", 100))
+	s.Save(testSession("b", "ws1", "codex", 200))
+	s.Save(testSession("c", "ws2", "claude", 300))
+
+	tests := []struct {
+		name  string
+		query SessionQuery
+		want  []string
+		total int
+	}{
+		{"all", SessionQuery{}, []string{"c", "b", "a"}, 3},
+		{"workspace and agent", SessionQuery{WorkspaceID: "ws1", Agent: "codex"}, []string{"b"}, 1},
+		{"updated after excludes the cutoff", SessionQuery{UpdatedAfter: 200}, []string{"c"}, 1},
+		{"page", SessionQuery{Offset: 1, Limit: 1}, []string{"b"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := s.Query(tt.query)
+			if got := ids(page.Sessions); !slices.Equal(got, tt.want) || page.Total != tt.total {
+				t.Errorf("got %v (total %d), want %v (total %d)", got, page.Total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestSessionIndexSharedBackend(t *testing.T) {
+	// Two machines on one remote, each with its own cached index
+	b := NewLocalBackend(t.TempDir())
+	m1, m2 := NewSessionStoreWithBackend(b), NewSessionStoreWithBackend(b)
+	m1.List()
+	m2.List()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			m1.Save(testSession(fmt.Sprintf("m1-%d", i), "", "claude", int64(i)))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			m2.Save(testSession(fmt.Sprintf("m2-%d", i), "", "claude", int64(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	m1.Refresh()
+	if n := len(m1.List()); n != 20 {
+		t.Fatalf("listed %d sessions after concurrent saves, want 20", n)
+	}
+
+	// Edits and deletions elsewhere show up once refreshed
+	session := testSession("m2-0", "", "claude", 100)
+	session.Title = "renamed"
+	m2.Save(session)
+	m2.Delete("m2-1")
+	m1.Refresh()
+	list := m1.List()
+	if len(list) != 19 || list[0].ID != "m2-0" || list[0].Title != "renamed" {
+		t.Fatalf("after edits elsewhere: %v", list)
+	}
+
+	// Superseded and orphaned metadata objects are cleaned up
+	keys, _ := b.List(indexDir + "/")
+	if len(keys) != 19 {
+		t.Fatalf("%d metadata objects for 19 sessions: %v", len(keys), keys)
+	}
+}
+
+func TestSessionIndexFromFiles(t *testing.T) {
+	// Sessions written without metadata, as by earlier versions
+	b := NewLocalBackend(t.TempDir())
+	data, _ := json.Marshal(testSession("a", "", "claude", 100))
+	b.Put("ws1/a.json", data)
+	b.Put(legacyIndexFile, []byte(`{"version":1,"sessions":{}}`))
+
+	s := NewSessionStoreWithBackend(b)
+	list := s.List()
+	if len(list) != 1 || list[0].ID != "a" || list[0].WorkspaceID != "ws1" {
+		t.Fatalf("List: %v", list)
+	}
+	if _, err := s.Load("a"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := b.List(indexDir + "/"); len(keys) != 1 {
+		t.Fatalf("metadata objects: %v", keys)
+	}
+	if _, err := b.Get(legacyIndexFile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("legacy index left behind: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trashDir holds deleted sessions as .trash/<workspace>/<id>.json, out of
+// reach of the index and session lookups until restored or purged
+const trashDir = ".trash"
+
+func trashKey(id, workspaceID string) string {
+	return trashDir + "/" + sessionKey(id, workspaceID)
+}
+
+// TrashedSession is a deleted session that can still be restored
+type TrashedSession struct {
+	SessionMeta
+	DeletedAt int64 `json:"deletedAt"`
+}
+
+// Trash moves a session to the trash
+func (s *SessionStore) Trash(id string) error {
+	key := s.findKey(id)
+	if key == "" {
+		return os.ErrNotExist
+	}
+	session, err := s.get(key)
+	if err != nil {
+		return err
+	}
+	session.DeletedAt = time.Now().UnixMilli()
+	if err := s.put(trashKey(session.ID, session.WorkspaceID), session); err != nil {
+		return err
+	}
+	if err := s.backend.Delete(key); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeFromIndex(id)
+}
+
+// ListTrash returns the trashed sessions, most recently deleted first
+func (s *SessionStore) ListTrash() []TrashedSession {
+	sessions := []TrashedSession{}
+	for _, session := range s.trashed() {
+		sessions = append(sessions, TrashedSession{SessionMeta: sessionMeta(session), DeletedAt: session.DeletedAt})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].DeletedAt > sessions[j].DeletedAt
+	})
+	return sessions
+}
+
+// Restore takes a session out of the trash
+func (s *SessionStore) Restore(id string) (*StoredSession, error) {
+	for key, session := range s.trashed() {
+		if session.ID != id {
+			continue
+		}
+		session.DeletedAt = 0
+		if err := s.Save(session); err != nil {
+			return nil, err
+		}
+		return session, s.backend.Delete(key)
+	}
+	return nil, os.ErrNotExist
+}
+
+// PurgeTrash permanently deletes the sessions trashed before the cutoff,
+// returning them so their uploads can be cleaned up
+func (s *SessionStore) PurgeTrash(before time.Time) ([]*StoredSession, error) {
+	var purged []*StoredSession
+	for key, session := range s.trashed() {
+		if session.DeletedAt >= before.UnixMilli() {
+			continue
+		}
+		if err := s.backend.Delete(key); err != nil {
+			return purged, err
+		}
+		purged = append(purged, session)
+	}
+	return purged, nil
+}
+
+// trashed loads the sessions in the trash by key
+func (s *SessionStore) trashed() map[string]*StoredSession {
+	sessions := make(map[string]*StoredSession)
+	keys, err := s.backend.List(trashDir + "/")
+	if err != nil {
+		return sessions
+	}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".json") {
+			continue
+		}
+		if session, err := s.get(key); err == nil {
+			sessions[key] = session
+		}
+	}
+	return sessions
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+// WebDAVBackend stores blobs as files in a WebDAV collection
+type WebDAVBackend struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAVBackend creates a WebDAV backend rooted at cfg.URL
+func NewWebDAVBackend(cfg *config.WebDAVConfig) (*WebDAVBackend, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, errors.New("webdav: url is required")
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("webdav: %w", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return &WebDAVBackend{
+		base:     base,
+		username: cfg.Username,
+		password: cfg.Password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (b *WebDAVBackend) do(method, key string, body []byte, header map[string]string) (*http.Response, error) {
+	u := *b.base
+	u.Path += key
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return b.client.Do(req)
+}
+
+// Get reads a file
+func (b *WebDAVBackend) Get(key string) ([]byte, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := b.do("GET", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("webdav %s: %w", key, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, webdavError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Put writes a file, creating parent collections as needed
+func (b *WebDAVBackend) Put(key string, data []byte) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	resp, err := b.do("PUT", key, data, nil)
+	if err != nil {
+		return err
+	}
+	// Missing parent collection
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		b.mkdirAll(path.Dir(key))
+		if resp, err = b.do("PUT", key, data, nil); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return webdavError(resp)
+	}
+	return nil
+}
+
+func (b *WebDAVBackend) mkdirAll(dir string) {
+	if dir == "." || dir == "/" {
+		return
+	}
+	current := ""
+	for _, part := range strings.Split(dir, "/") {
+		current += part + "/"
+		if resp, err := b.do("MKCOL", current, nil, nil); err == nil {
+			resp.Body.Close() // 405 means it already exists
+		}
+	}
+}
+
+// Delete removes a file
+func (b *WebDAVBackend) Delete(key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	resp, err := b.do("DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || (resp.StatusCode >= 200 && resp.StatusCode <= 299) {
+		return nil
+	}
+	return webdavError(resp)
+}
+
+// multistatus is a PROPFIND response
+type multistatus struct {
+	Responses []struct {
+		Href       string    `xml:"href"`
+		Collection *struct{} `xml:"propstat>prop>resourcetype>collection"`
+	} `xml:"response"`
+}
+
+// List returns all keys starting with prefix, walking collections with Depth: 1
+func (b *WebDAVBackend) List(prefix string) ([]string, error) {
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir = prefix[:i+1]
+	}
+
+	var keys []string
+	queue := []string{dir}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		resp, err := b.do("PROPFIND", current, nil, map[string]string{"Depth": "1"})
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			continue
+		}
+		if resp.StatusCode != http.StatusMultiStatus {
+			err := webdavError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var ms multistatus
+		err = xml.NewDecoder(resp.Body).Decode(&ms)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range ms.Responses {
+			key, ok := b.keyFromHref(r.Href)
+			if !ok || key == current || key+"/" == current {
+				continue
+			}
+			if r.Collection != nil {
+				queue = append(queue, strings.TrimSuffix(key, "/")+"/")
+				continue
+			}
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// keyFromHref converts a PROPFIND href into a key relative to the base URL
+func (b *WebDAVBackend) keyFromHref(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	if !strings.HasPrefix(u.Path, b.base.Path) {
+		return "", false
+	}
+	return strings.TrimPrefix(u.Path, b.base.Path), true
+}
+
+func webdavError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("webdav: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -2,83 +2,198 @@ package storage
 
 import (
 	"encoding/json"
-	"os"
+	"errors"
 	"path/filepath"
+	"sync"
 
 	"github.com/daodao97/acpone/internal/config"
 )
 
-// WorkspaceStore manages workspace persistence
+// ErrWorkspaceExists is returned when adding a workspace whose ID is taken
+var ErrWorkspaceExists = errors.New("workspace already exists")
+
+// ErrWorkspaceNotFound is returned for unknown workspace IDs
+var ErrWorkspaceNotFound = errors.New("workspace not found")
+
+const workspaceFileName = "workspaces.json"
+
+// WorkspaceStore is the single source of truth for workspaces.
+// It is persisted to a Backend (by default ~/.acpone/workspaces.json) and
+// safe for concurrent use.
 type WorkspaceStore struct {
-	filePath string
+	mu         sync.RWMutex
+	backend    Backend
+	key        string
+	workspaces []config.WorkspaceConfig
+	defaultID  string
 }
 
-// NewWorkspaceStore creates a new workspace store
+// NewWorkspaceStore creates a workspace store on disk (default ~/.acpone/workspaces.json)
 func NewWorkspaceStore(filePath string) *WorkspaceStore {
 	if filePath == "" {
-		filePath = defaultWorkspacePath()
+		filePath = filepath.Join(acponeDir(), workspaceFileName)
 	}
-	dir := filepath.Dir(filePath)
-	os.MkdirAll(dir, 0755)
-	return &WorkspaceStore{filePath: filePath}
+	return NewWorkspaceStoreWithBackend(NewLocalBackend(filepath.Dir(filePath)), filepath.Base(filePath))
 }
 
-func defaultWorkspacePath() string {
-	home := os.Getenv("HOME")
-	if home == "" {
-		home = os.Getenv("USERPROFILE")
-	}
-	if home == "" {
-		home = "."
-	}
-	return filepath.Join(home, ".acpone", "workspaces.json")
+// NewWorkspaceStoreWithBackend creates a workspace store persisted as key in backend
+func NewWorkspaceStoreWithBackend(backend Backend, key string) *WorkspaceStore {
+	s := &WorkspaceStore{backend: backend, key: key}
+	s.load()
+	return s
 }
 
 // workspaceFile matches TypeScript format: {"workspaces": [...]}
 type workspaceFile struct {
 	Workspaces []config.WorkspaceConfig `json:"workspaces"`
+	Default    string                   `json:"default,omitempty"`
 }
 
-// Load loads all workspaces
-func (s *WorkspaceStore) Load() []config.WorkspaceConfig {
-	data, err := os.ReadFile(s.filePath)
+// Reload re-reads the workspaces from the backend (e.g. after a restore)
+func (s *WorkspaceStore) Reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workspaces, s.defaultID = nil, ""
+	s.load()
+}
+
+func (s *WorkspaceStore) load() {
+	data, err := s.backend.Get(s.key)
 	if err != nil {
-		return nil
+		return
 	}
 
 	var file workspaceFile
 	if err := json.Unmarshal(data, &file); err != nil {
-		return nil
+		return
 	}
 
-	return file.Workspaces
+	s.workspaces = file.Workspaces
+	s.defaultID = file.Default
 }
 
-// Save saves all workspaces
-func (s *WorkspaceStore) Save(workspaces []config.WorkspaceConfig) error {
-	file := workspaceFile{Workspaces: workspaces}
+// save writes the store to the backend (caller holds s.mu)
+func (s *WorkspaceStore) save() error {
+	file := workspaceFile{Workspaces: s.workspaces, Default: s.defaultID}
+	if file.Workspaces == nil {
+		file.Workspaces = []config.WorkspaceConfig{}
+	}
 	data, err := json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.filePath, data, 0644)
+	return s.backend.Put(s.key, data)
+}
+
+// List returns all workspaces
+func (s *WorkspaceStore) List() []config.WorkspaceConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]config.WorkspaceConfig(nil), s.workspaces...)
+}
+
+// Find returns a copy of the workspace with the given ID
+func (s *WorkspaceStore) Find(id string) (config.WorkspaceConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.find(id)
+}
+
+func (s *WorkspaceStore) find(id string) (config.WorkspaceConfig, bool) {
+	for _, ws := range s.workspaces {
+		if ws.ID == id {
+			return ws, true
+		}
+	}
+	return config.WorkspaceConfig{}, false
+}
+
+// Default returns the default workspace ID, falling back to the first workspace
+func (s *WorkspaceStore) Default() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.find(s.defaultID); ok {
+		return s.defaultID
+	}
+	if len(s.workspaces) > 0 {
+		return s.workspaces[0].ID
+	}
+	return ""
+}
+
+// SetDefault changes the default workspace
+func (s *WorkspaceStore) SetDefault(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.find(id); !ok {
+		return ErrWorkspaceNotFound
+	}
+	s.defaultID = id
+	return s.save()
+}
+
+// ResolvePath returns the path of a workspace, or of the default workspace
+// when id is empty or unknown. Returns "." when no workspace exists.
+func (s *WorkspaceStore) ResolvePath(id string) string {
+	if ws, ok := s.Find(id); ok {
+		return ws.Path
+	}
+	if ws, ok := s.Find(s.Default()); ok {
+		return ws.Path
+	}
+	return "."
 }
 
 // Add adds a workspace
 func (s *WorkspaceStore) Add(ws config.WorkspaceConfig) error {
-	workspaces := s.Load()
-	workspaces = append(workspaces, ws)
-	return s.Save(workspaces)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.find(ws.ID); ok {
+		return ErrWorkspaceExists
+	}
+	s.workspaces = append(s.workspaces, ws)
+	return s.save()
 }
 
 // Remove removes a workspace by ID
 func (s *WorkspaceStore) Remove(id string) error {
-	workspaces := s.Load()
-	filtered := make([]config.WorkspaceConfig, 0, len(workspaces))
-	for _, ws := range workspaces {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filtered := make([]config.WorkspaceConfig, 0, len(s.workspaces))
+	for _, ws := range s.workspaces {
 		if ws.ID != id {
 			filtered = append(filtered, ws)
 		}
 	}
-	return s.Save(filtered)
+	if len(filtered) == len(s.workspaces) {
+		return ErrWorkspaceNotFound
+	}
+	s.workspaces = filtered
+	if s.defaultID == id {
+		s.defaultID = ""
+	}
+	return s.save()
+}
+
+// Migrate imports workspaces declared in the config file (legacy location)
+// and clears them from cfg. Returns true when cfg declared any workspaces,
+// meaning the config file should be rewritten without them.
+func (s *WorkspaceStore) Migrate(cfg *config.Config) (bool, error) {
+	if len(cfg.Workspaces) == 0 && cfg.DefaultWorkspace == "" {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ws := range cfg.Workspaces {
+		if _, ok := s.find(ws.ID); !ok {
+			s.workspaces = append(s.workspaces, ws)
+		}
+	}
+	if s.defaultID == "" {
+		s.defaultID = cfg.DefaultWorkspace
+	}
+	cfg.Workspaces = nil
+	cfg.DefaultWorkspace = ""
+	return true, s.save()
 }
@@ -0,0 +1,95 @@
+package sysutil
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"runtime"
+	"sync"
+)
+
+var (
+	hostArchOnce sync.Once
+	hostArch     string
+)
+
+// HostArch returns the CPU architecture of the machine as a GOARCH name.
+// It differs from runtime.GOARCH when acpone itself runs emulated, e.g. an
+// amd64 build under Rosetta 2 or on Windows on ARM.
+func HostArch() string {
+	hostArchOnce.Do(func() {
+		hostArch = nativeArch()
+		if hostArch == "" {
+			hostArch = runtime.GOARCH
+		}
+	})
+	return hostArch
+}
+
+// BinaryArchs returns the architectures an executable is built for, several
+// for a macOS universal binary, none for scripts and unknown formats
+func BinaryArchs(path string) []string {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return nonEmpty(elfArchs[f.Machine])
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return nonEmpty(machoArchs[f.Cpu])
+	}
+	if f, err := macho.OpenFat(path); err == nil {
+		defer f.Close()
+		var archs []string
+		for _, a := range f.Arches {
+			if arch := machoArchs[a.Cpu]; arch != "" {
+				archs = append(archs, arch)
+			}
+		}
+		return archs
+	}
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		return nonEmpty(peArchs[f.Machine])
+	}
+	return nil
+}
+
+// RunsNatively reports whether an executable is built for the host, true
+// for scripts and unknown formats
+func RunsNatively(path string) bool {
+	archs := BinaryArchs(path)
+	if len(archs) == 0 {
+		return true
+	}
+	for _, arch := range archs {
+		if arch == HostArch() {
+			return true
+		}
+	}
+	return false
+}
+
+func nonEmpty(arch string) []string {
+	if arch == "" {
+		return nil
+	}
+	return []string{arch}
+}
+
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_386:     "386",
+	elf.EM_ARM:     "arm",
+}
+
+var machoArchs = map[macho.Cpu]string{
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm64: "arm64",
+}
+
+var peArchs = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+}
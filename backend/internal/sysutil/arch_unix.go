@@ -0,0 +1,37 @@
+//go:build !windows
+
+package sysutil
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// nativeArch detects the machine's architecture, "" when unknown
+func nativeArch() string {
+	if runtime.GOOS == "darwin" {
+		// Processes translated by Rosetta 2 run on Apple Silicon
+		out, err := exec.Command("sysctl", "-n", "sysctl.proc_translated").Output()
+		if err == nil && strings.TrimSpace(string(out)) == "1" {
+			return "arm64"
+		}
+		return runtime.GOARCH
+	}
+
+	out, err := exec.Command("uname", "-m").Output()
+	if err != nil {
+		return ""
+	}
+	switch strings.TrimSpace(string(out)) {
+	case "x86_64", "amd64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "i386", "i686":
+		return "386"
+	case "armv7l", "armv6l":
+		return "arm"
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package sysutil
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procIsWow64Process2 = syscall.NewLazyDLL("kernel32.dll").NewProc("IsWow64Process2")
+
+// nativeArch detects the machine's architecture, "" when unknown. x64
+// processes emulated on Windows on ARM see an AMD64 PROCESSOR_ARCHITECTURE,
+// so the native machine is asked for.
+func nativeArch() string {
+	if procIsWow64Process2.Find() != nil {
+		return ""
+	}
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return ""
+	}
+	var processMachine, nativeMachine uint16
+	ok, _, _ := procIsWow64Process2.Call(
+		uintptr(process),
+		uintptr(unsafe.Pointer(&processMachine)),
+		uintptr(unsafe.Pointer(&nativeMachine)),
+	)
+	if ok == 0 {
+		return ""
+	}
+	return peArchs[nativeMachine]
+}
@@ -0,0 +1,43 @@
+package sysutil
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DataDirEnv names the environment variable relocating acpone's data
+const DataDirEnv = "ACPONE_DATA_DIR"
+
+var (
+	dataDirMu sync.RWMutex
+	dataDir   string
+)
+
+// DataDir returns the directory acpone keeps its sessions, workspaces and
+// other state in: the one set with SetDataDir, else $ACPONE_DATA_DIR, else
+// ~/.acpone
+func DataDir() string {
+	dataDirMu.RLock()
+	dir := dataDir
+	dataDirMu.RUnlock()
+	if dir != "" {
+		return dir
+	}
+	if dir := os.Getenv(DataDirEnv); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	if home == "" {
+		home = "."
+	}
+	return filepath.Join(home, ".acpone")
+}
+
+// SetDataDir relocates acpone's data, e.g. to the configured server.dataDir.
+// It must be called before any store is opened.
+func SetDataDir(dir string) {
+	dataDirMu.Lock()
+	defer dataDirMu.Unlock()
+	dataDir = dir
+}
@@ -0,0 +1,208 @@
+package sysutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var pathMu sync.Mutex
+
+// RefreshPath prepends toolchain directories that exist but are missing from
+// PATH, such as Homebrew, scoop, winget, npm global, nvm and fnm installs. GUI apps don't
+// inherit the shell's PATH, and tools installed after startup are not on it
+// either; on Windows the machine and user PATH are re-read from the registry.
+// Processes started afterwards inherit the new PATH. Returns the added
+// directories.
+func RefreshPath() []string {
+	pathMu.Lock()
+	defer pathMu.Unlock()
+
+	extraPaths := registryPath()
+	if home, _ := os.UserHomeDir(); home != "" {
+		switch runtime.GOOS {
+		case "windows":
+			extraPaths = append(extraPaths, windowsPaths(home)...)
+		case "darwin":
+			extraPaths = append(extraPaths, macOSPaths(home)...)
+		default:
+			extraPaths = append(extraPaths, linuxPaths(home)...)
+		}
+	}
+
+	sep := string(os.PathListSeparator)
+	currentPath := os.Getenv("PATH")
+	pathSet := make(map[string]bool)
+	for _, p := range strings.Split(currentPath, sep) {
+		pathSet[pathKey(p)] = true
+	}
+
+	var newPaths []string
+	for _, p := range extraPaths {
+		if p == "" || pathSet[pathKey(p)] {
+			continue
+		}
+		if _, err := os.Stat(p); err == nil {
+			newPaths = append(newPaths, p)
+			pathSet[pathKey(p)] = true
+		}
+	}
+
+	if len(newPaths) > 0 {
+		os.Setenv("PATH", strings.Join(newPaths, sep)+sep+currentPath)
+	}
+	return newPaths
+}
+
+// pathKey normalizes a PATH entry for comparison
+func pathKey(p string) string {
+	p = filepath.Clean(p)
+	if runtime.GOOS == "windows" {
+		p = strings.ToLower(p)
+	}
+	return p
+}
+
+func macOSPaths(home string) []string {
+	paths := []string{
+		"/usr/local/bin",
+		"/opt/homebrew/bin", // Homebrew (Apple Silicon)
+		"/opt/homebrew/sbin",
+		filepath.Join(home, ".local", "bin"),      // pipx, etc.
+		filepath.Join(home, ".cargo", "bin"),      // Rust
+		filepath.Join(home, "go", "bin"),          // Go
+		filepath.Join(home, ".npm-global", "bin"), // npm global
+		filepath.Join(home, ".bun", "bin"),        // Bun
+		filepath.Join(DataDir(), "bin"),           // Agent binaries installed by setup
+	}
+
+	// nvm
+	paths = append(paths, findNodeVersions(filepath.Join(home, ".nvm", "versions", "node"), "bin")...)
+
+	// fnm
+	fnmDir := filepath.Join(home, "Library", "Application Support", "fnm", "node-versions")
+	paths = append(paths, findNodeVersions(fnmDir, "installation", "bin")...)
+
+	return paths
+}
+
+func linuxPaths(home string) []string {
+	paths := []string{
+		"/usr/local/bin",
+		"/snap/bin",                               // Snap packages
+		filepath.Join(home, ".local", "bin"),      // pipx, etc.
+		filepath.Join(home, ".cargo", "bin"),      // Rust
+		filepath.Join(home, "go", "bin"),          // Go
+		filepath.Join(home, ".npm-global", "bin"), // npm global
+		filepath.Join(home, ".bun", "bin"),        // Bun
+		filepath.Join(DataDir(), "bin"),           // Agent binaries installed by setup
+	}
+
+	// Homebrew on Linux
+	paths = append(paths,
+		"/home/linuxbrew/.linuxbrew/bin",
+		filepath.Join(home, ".linuxbrew", "bin"),
+	)
+
+	// nvm, possibly moved with NVM_DIR
+	nvmDir := filepath.Join(home, ".nvm")
+	if dir := os.Getenv("NVM_DIR"); dir != "" {
+		nvmDir = dir
+	}
+	paths = append(paths, findNodeVersions(filepath.Join(nvmDir, "versions", "node"), "bin")...)
+
+	// Node.js tarballs unpacked as the Node.js docs suggest, e.g.
+	// node-v20.11.0-linux-arm64
+	paths = append(paths, findNodeVersions("/usr/local/lib/nodejs", "bin")...)
+
+	// fnm
+	fnmDir := filepath.Join(home, ".local", "share", "fnm", "node-versions")
+	paths = append(paths, findNodeVersions(fnmDir, "installation", "bin")...)
+
+	return paths
+}
+
+func windowsPaths(home string) []string {
+	appData := os.Getenv("APPDATA")
+	localAppData := os.Getenv("LOCALAPPDATA")
+	programFiles := os.Getenv("ProgramFiles")
+
+	paths := []string{
+		filepath.Join(programFiles, "nodejs"),                                     // Node.js
+		filepath.Join(localAppData, "Microsoft", "WinGet", "Links"),               // winget
+		filepath.Join(appData, "npm"),                                             // npm global
+		filepath.Join(localAppData, "Programs", "Python", "Python311", "Scripts"), // Python
+		filepath.Join(localAppData, "Programs", "Python", "Python312", "Scripts"),
+		filepath.Join(home, ".cargo", "bin"), // Rust
+		filepath.Join(home, "go", "bin"),     // Go
+		filepath.Join(home, ".bun", "bin"),   // Bun
+		filepath.Join(home, ".local", "bin"), // uv tools, pipx
+		filepath.Join(DataDir(), "bin"),      // Agent binaries installed by setup
+	}
+
+	// Native Program Files of a 32-bit or emulated process, and the ARM64
+	// one of Windows on ARM
+	for _, name := range []string{"ProgramW6432", "ProgramFiles(Arm)"} {
+		if dir := os.Getenv(name); dir != "" {
+			paths = append(paths, filepath.Join(dir, "nodejs"))
+		}
+	}
+
+	// scoop shims, per user and global
+	scoop := filepath.Join(home, "scoop")
+	if dir := userEnv("SCOOP"); dir != "" {
+		scoop = dir
+	}
+	paths = append(paths, filepath.Join(scoop, "shims"))
+	scoopGlobal := filepath.Join(os.Getenv("ProgramData"), "scoop")
+	if dir := userEnv("SCOOP_GLOBAL"); dir != "" {
+		scoopGlobal = dir
+	}
+	paths = append(paths, filepath.Join(scoopGlobal, "shims"))
+
+	// nvm-windows, whose variables may have been set after we started
+	if nvmHome := userEnv("NVM_HOME"); nvmHome != "" {
+		paths = append(paths, nvmHome)
+	}
+	if nvmSymlink := userEnv("NVM_SYMLINK"); nvmSymlink != "" {
+		paths = append(paths, nvmSymlink)
+	}
+
+	// fnm
+	fnmDir := filepath.Join(appData, "fnm", "node-versions")
+	if dir := userEnv("FNM_DIR"); dir != "" {
+		fnmDir = filepath.Join(dir, "node-versions")
+	}
+	paths = append(paths, findNodeVersions(fnmDir, "installation")...)
+
+	return paths
+}
+
+// findNodeVersions returns the bin directories of the Node.js versions
+// installed in baseDir. Versions built for the host come first, so an x64
+// install left over from a migrated machine doesn't shadow an arm64 one.
+func findNodeVersions(baseDir string, subPaths ...string) []string {
+	var native, emulated []string
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return nil
+	}
+	node := "node"
+	if runtime.GOOS == "windows" {
+		node = "node.exe"
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			parts := append([]string{baseDir, entry.Name()}, subPaths...)
+			dir := filepath.Join(parts...)
+			if RunsNatively(filepath.Join(dir, node)) {
+				native = append(native, dir)
+			} else {
+				emulated = append(emulated, dir)
+			}
+		}
+	}
+	return append(native, emulated...)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package sysutil
+
+import "os"
+
+// registryPath is only used on Windows
+func registryPath() []string {
+	return nil
+}
+
+// userEnv returns an environment variable of the current process
+func userEnv(name string) string {
+	return os.Getenv(name)
+}
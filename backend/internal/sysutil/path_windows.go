@@ -0,0 +1,88 @@
+//go:build windows
+
+package sysutil
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	machineEnvKey = `SYSTEM\CurrentControlSet\Control\Session Manager\Environment`
+	userEnvKey    = `Environment`
+)
+
+var envRefRegex = regexp.MustCompile(`%([^%]+)%`)
+
+// registryPath returns the machine and user PATH entries stored in the
+// registry, which installers update without touching running processes
+func registryPath() []string {
+	var paths []string
+	for _, value := range []string{
+		readRegistry(syscall.HKEY_LOCAL_MACHINE, machineEnvKey, "Path"),
+		readRegistry(syscall.HKEY_CURRENT_USER, userEnvKey, "Path"),
+	} {
+		for _, p := range strings.Split(value, ";") {
+			if p = strings.TrimSpace(expandEnv(p)); p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// userEnv returns an environment variable, falling back to the user and
+// machine variables in the registry when it was set after we started
+func userEnv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	if v := readRegistry(syscall.HKEY_CURRENT_USER, userEnvKey, name); v != "" {
+		return expandEnv(v)
+	}
+	return expandEnv(readRegistry(syscall.HKEY_LOCAL_MACHINE, machineEnvKey, name))
+}
+
+// expandEnv expands %VAR% references of REG_EXPAND_SZ values
+func expandEnv(s string) string {
+	return envRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		if v, ok := os.LookupEnv(ref[1 : len(ref)-1]); ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// readRegistry reads a string value, returning "" when it is missing
+func readRegistry(root syscall.Handle, path, name string) string {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return ""
+	}
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return ""
+	}
+
+	var key syscall.Handle
+	if syscall.RegOpenKeyEx(root, pathPtr, 0, syscall.KEY_READ, &key) != nil {
+		return ""
+	}
+	defer syscall.RegCloseKey(key)
+
+	var typ, size uint32
+	if syscall.RegQueryValueEx(key, namePtr, nil, &typ, nil, &size) != nil || size == 0 {
+		return ""
+	}
+	if typ != syscall.REG_SZ && typ != syscall.REG_EXPAND_SZ {
+		return ""
+	}
+	buf := make([]uint16, size/2+1)
+	if syscall.RegQueryValueEx(key, namePtr, nil, &typ, (*byte)(unsafe.Pointer(&buf[0])), &size) != nil {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
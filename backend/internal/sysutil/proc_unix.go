@@ -0,0 +1,51 @@
+//go:build !windows
+
+package sysutil
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// SetProcessGroup 让子进程成为新进程组的组长，便于整体结束 npx 启动的进程树
+func SetProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// SetControllingTerminal 让子进程在新会话中运行，以其标准输入（终端）为控制终端。
+// 新会话同时是新进程组，KillTree 仍然适用；不要再调用 SetProcessGroup
+func SetControllingTerminal(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+// AttachToJob 在非 Windows 系统上不需要任何操作（进程组已在启动前设置）
+func AttachToJob(pid int) error {
+	return nil
+}
+
+// InterruptTree 向进程组发送 SIGINT
+func InterruptTree(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGINT)
+}
+
+// KillTree 强制结束整个进程组
+func KillTree(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// ProcessAlive 检查进程是否仍在运行
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
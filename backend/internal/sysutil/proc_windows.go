@@ -0,0 +1,138 @@
+//go:build windows
+
+package sysutil
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObject          = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+
+	jobHandle syscall.Handle
+	jobOnce   sync.Once
+	jobErr    error
+)
+
+const (
+	jobObjectExtendedLimitInformation = 9
+	jobObjectLimitKillOnJobClose      = 0x2000
+	processSetQuota                   = 0x0100
+	processTerminate                  = 0x0001
+	processQueryLimitedInformation    = 0x1000
+	stillActive                       = 259
+)
+
+// jobObjectExtendedLimit 对应 JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+type jobObjectExtendedLimit struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+	IoInfo                  [6]uint64
+	ProcessMemoryLimit      uintptr
+	JobMemoryLimit          uintptr
+	PeakProcessMemoryUsed   uintptr
+	PeakJobMemoryUsed       uintptr
+}
+
+// SetProcessGroup 在 Windows 上不需要任何操作，进程树由 Job Object 管理
+func SetProcessGroup(cmd *exec.Cmd) {}
+
+// SetControllingTerminal 在 Windows 上不需要（没有伪终端，命令通过管道运行）
+func SetControllingTerminal(cmd *exec.Cmd) {}
+
+// createJob 创建一个在 acpone 退出（句柄关闭）时结束所有子进程的 Job Object
+func createJob() (syscall.Handle, error) {
+	h, _, err := procCreateJobObject.Call(0, 0)
+	if h == 0 {
+		return 0, err
+	}
+	info := jobObjectExtendedLimit{LimitFlags: jobObjectLimitKillOnJobClose}
+	ok, _, err := procSetInformationJobObject.Call(h, jobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info))
+	if ok == 0 {
+		syscall.CloseHandle(syscall.Handle(h))
+		return 0, err
+	}
+	return syscall.Handle(h), nil
+}
+
+// AttachToJob 将已启动的进程加入 Job Object，其后代进程会自动继承
+func AttachToJob(pid int) error {
+	jobOnce.Do(func() {
+		jobHandle, jobErr = createJob()
+	})
+	if jobErr != nil {
+		return jobErr
+	}
+
+	ph, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(ph)
+
+	ok, _, err := procAssignProcessToJobObject.Call(uintptr(jobHandle), uintptr(ph))
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+// InterruptTree Windows 不支持向控制台子进程发送中断，直接返回
+func InterruptTree(pid int) error {
+	return nil
+}
+
+// KillTree 使用 taskkill 结束整个进程树
+func KillTree(pid int) error {
+	cmd := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid))
+	HideWindow(cmd)
+	return cmd.Run()
+}
+
+// ProcessAlive 检查进程是否仍在运行
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// ProcessStartTime 返回进程的创建时间，与 PID 一起唯一确定一个进程；
+// 无法获取时返回空字符串
+func ProcessStartTime(pid int) string {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(h)
+	var created, exited, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &created, &exited, &kernel, &user); err != nil {
+		return ""
+	}
+	return fmt.Sprint(created.Nanoseconds())
+}
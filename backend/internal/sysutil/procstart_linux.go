@@ -0,0 +1,28 @@
+package sysutil
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ProcessStartTime 返回进程启动时间的标识（开机 ID 加开机后的时钟滴答数），
+// 与 PID 一起唯一确定一个进程；无法获取时返回空字符串
+func ProcessStartTime(pid int) string {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return ""
+	}
+	// 进程名可能包含空格和括号，从最后一个 ')' 之后开始数字段
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return ""
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	// 第 22 个字段 starttime，即 ')' 之后的第 20 个
+	if len(fields) < 20 {
+		return ""
+	}
+	bootID, _ := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	return strings.TrimSpace(string(bootID)) + ":" + fields[19]
+}
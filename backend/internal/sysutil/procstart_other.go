@@ -0,0 +1,19 @@
+//go:build !linux && !windows
+
+package sysutil
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ProcessStartTime 返回进程的启动时间，与 PID 一起唯一确定一个进程；
+// 无法获取时返回空字符串
+func ProcessStartTime(pid int) string {
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,61 @@
+//go:build darwin
+
+package sysutil
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// OpenPTY opens a pseudo terminal, returning its controlling side and the
+// terminal a child process runs on
+func OpenPTY() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := make([]byte, 128) // TIOCPTYGNAME fills a 128 byte buffer
+	for _, req := range []struct {
+		cmd uintptr
+		arg uintptr
+	}{
+		{syscall.TIOCPTYGRANT, 0},
+		{syscall.TIOCPTYUNLK, 0},
+		{syscall.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))},
+	} {
+		if err := ioctl(ptmx, req.cmd, req.arg); err != nil {
+			ptmx.Close()
+			return nil, nil, err
+		}
+	}
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	tty, err = os.OpenFile(string(name), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	return ptmx, tty, nil
+}
+
+// ioctl runs an ioctl without File.Fd, which would make the file blocking
+// and keep Close from interrupting a pending Read
+func ioctl(f *os.File, req, arg uintptr) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package sysutil
+
+import (
+	"errors"
+	"os"
+)
+
+// OpenPTY is not supported here, commands run on pipes instead
+func OpenPTY() (ptmx, tty *os.File, err error) {
+	return nil, nil, errors.ErrUnsupported
+}
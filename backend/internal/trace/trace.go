@@ -0,0 +1,178 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/recorder"
+)
+
+const (
+	defaultCapacity = 500
+	previewLen      = 300
+)
+
+// Entry kinds
+const (
+	KindRequest      = "request"
+	KindResponse     = "response"
+	KindNotification = "notification"
+)
+
+// Entry is one traced JSON-RPC frame
+type Entry struct {
+	Seq       int64  `json:"seq"`
+	Time      int64  `json:"time"`
+	Dir       string `json:"dir"` // send or recv
+	Kind      string `json:"kind"`
+	Method    string `json:"method,omitempty"`
+	ID        *int   `json:"id,omitempty"`
+	LatencyMs *int64 `json:"latencyMs,omitempty"` // Responses only
+	Error     string `json:"error,omitempty"`
+	Preview   string `json:"preview"`
+	Size      int    `json:"size"`
+}
+
+type pendingCall struct {
+	method string
+	start  time.Time
+}
+
+// buffer is a per-agent ring of recent entries
+type buffer struct {
+	entries []Entry
+	next    int
+	full    bool
+	pending map[string]pendingCall // dir+id -> outstanding request
+}
+
+// Tracer keeps a bounded history of JSON-RPC exchanges per agent
+type Tracer struct {
+	capacity int
+	secrets  []string
+
+	mu      sync.Mutex
+	seq     int64
+	buffers map[string]*buffer
+}
+
+// New creates a tracer keeping capacity entries per agent
+func New(capacity int, secrets []string) *Tracer {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return &Tracer{
+		capacity: capacity,
+		secrets:  secrets,
+		buffers:  make(map[string]*buffer),
+	}
+}
+
+// Attach starts tracing the frames of an agent process
+func (t *Tracer) Attach(p *agent.Process) func() {
+	return p.OnFrame(t.record)
+}
+
+func (t *Tracer) record(f agent.Frame) {
+	var msg struct {
+		ID     *int   `json:"id"`
+		Method string `json:"method"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(f.Data, &msg)
+
+	preview := string(recorder.Redact(f.Data, t.secrets))
+	if len(preview) > previewLen {
+		preview = preview[:previewLen] + "..."
+	}
+
+	entry := Entry{
+		Time:    f.Time.UnixMilli(),
+		Dir:     f.Dir,
+		Method:  msg.Method,
+		ID:      msg.ID,
+		Preview: preview,
+		Size:    len(f.Data),
+	}
+	if msg.Error != nil {
+		entry.Error = msg.Error.Message
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	buf := t.buffers[f.AgentID]
+	if buf == nil {
+		buf = &buffer{entries: make([]Entry, t.capacity), pending: make(map[string]pendingCall)}
+		t.buffers[f.AgentID] = buf
+	}
+
+	switch {
+	case msg.ID != nil && msg.Method != "":
+		entry.Kind = KindRequest
+		if len(buf.pending) >= t.capacity {
+			// Drop requests that were never answered
+			buf.pending = make(map[string]pendingCall)
+		}
+		buf.pending[pendingKey(f.Dir, *msg.ID)] = pendingCall{method: msg.Method, start: f.Time}
+	case msg.ID != nil:
+		entry.Kind = KindResponse
+		key := pendingKey(opposite(f.Dir), *msg.ID)
+		if call, ok := buf.pending[key]; ok {
+			delete(buf.pending, key)
+			latency := f.Time.Sub(call.start).Milliseconds()
+			entry.Method = call.method
+			entry.LatencyMs = &latency
+		}
+	default:
+		entry.Kind = KindNotification
+	}
+
+	t.seq++
+	entry.Seq = t.seq
+	buf.entries[buf.next] = entry
+	buf.next = (buf.next + 1) % len(buf.entries)
+	if buf.next == 0 {
+		buf.full = true
+	}
+}
+
+// Since returns entries of an agent with sequence greater than since, oldest first
+func (t *Tracer) Since(agentID string, since int64) []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := []Entry{}
+	buf := t.buffers[agentID]
+	if buf == nil {
+		return result
+	}
+
+	start, count := 0, buf.next
+	if buf.full {
+		start, count = buf.next, len(buf.entries)
+	}
+	for i := 0; i < count; i++ {
+		e := buf.entries[(start+i)%len(buf.entries)]
+		if e.Seq > since {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func pendingKey(dir string, id int) string {
+	return fmt.Sprintf("%s:%d", dir, id)
+}
+
+func opposite(dir string) string {
+	if dir == agent.FrameSend {
+		return agent.FrameRecv
+	}
+	return agent.FrameSend
+}
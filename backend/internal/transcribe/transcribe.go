@@ -0,0 +1,155 @@
+// Package transcribe turns recorded audio into prompt text using a local
+// command (e.g. whisper.cpp) or an OpenAI-compatible transcription API.
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/daodao97/acpone/internal/config"
+)
+
+const defaultTimeout = 120 * time.Second
+
+// Transcriber converts an audio file to text
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioPath, language string) (string, error)
+}
+
+// New creates the transcriber selected by cfg
+func New(cfg *config.TranscribeConfig) Transcriber {
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if len(cfg.Command) > 0 {
+		return &commandTranscriber{args: cfg.Command, language: cfg.Language, timeout: timeout}
+	}
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	model := cfg.Model
+	if model == "" {
+		model = "whisper-1"
+	}
+	return &apiTranscriber{
+		url:      cfg.URL,
+		apiKey:   apiKey,
+		model:    model,
+		language: cfg.Language,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// commandTranscriber runs a local program and reads the text from stdout
+type commandTranscriber struct {
+	args     []string
+	language string
+	timeout  time.Duration
+}
+
+func (t *commandTranscriber) Transcribe(ctx context.Context, audioPath, language string) (string, error) {
+	if language == "" {
+		language = t.language
+	}
+	if language == "" {
+		language = "auto"
+	}
+
+	args := make([]string, len(t.args))
+	for i, arg := range t.args {
+		arg = strings.ReplaceAll(arg, "{file}", audioPath)
+		args[i] = strings.ReplaceAll(arg, "{lang}", language)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 500 {
+			msg = msg[len(msg)-500:]
+		}
+		return "", fmt.Errorf("%s: %v: %s", filepath.Base(args[0]), err, msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// apiTranscriber posts the audio to an OpenAI-compatible endpoint
+type apiTranscriber struct {
+	url      string
+	apiKey   string
+	model    string
+	language string
+	client   *http.Client
+}
+
+func (t *apiTranscriber) Transcribe(ctx context.Context, audioPath, language string) (string, error) {
+	if language == "" {
+		language = t.language
+	}
+
+	f, err := os.Open(audioPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("model", t.model)
+	if language != "" && language != "auto" {
+		mw.WriteField("language", language)
+	}
+	part, err := mw.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return "", err
+	}
+	mw.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription API: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("transcription API: invalid response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
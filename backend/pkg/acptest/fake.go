@@ -0,0 +1,231 @@
+// Package acptest provides a scriptable fake ACP agent and helpers for
+// driving agent.Process and api.Server in Go tests.
+package acptest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/daodao97/acpone/internal/jsonrpc"
+	"github.com/daodao97/acpone/internal/mockagent"
+)
+
+// JSON-RPC error codes for scripted errors
+const (
+	InvalidParams  = jsonrpc.InvalidParams
+	MethodNotFound = jsonrpc.MethodNotFound
+	InternalError  = jsonrpc.InternalError
+)
+
+// Error is a JSON-RPC error, sent by the fake agent or received from the
+// client
+type Error struct {
+	Code    int
+	Message string
+	Data    any
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// toRPC converts an *Error for the connection; other errors pass as they are
+func toRPC(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return &jsonrpc.Error{Code: e.Code, Message: e.Message, Data: e.Data}
+	}
+	return err
+}
+
+// fromRPC converts an error from the connection to an *Error
+func fromRPC(err error) error {
+	var e *jsonrpc.Error
+	if errors.As(err, &e) {
+		return &Error{Code: e.Code, Message: e.Message, Data: e.Data}
+	}
+	return err
+}
+
+// Response is a canned reply to a request method
+type Response struct {
+	Result  any                      // Result returned to the client
+	Error   *Error                   // Induced error (takes precedence over Result)
+	Delay   time.Duration            // Wait before replying
+	Updates []map[string]any         // session/update payloads sent before replying
+	Func    func(*Call) (any, error) // Computes the reply dynamically when set
+}
+
+// Call is a request received by the fake agent
+type Call struct {
+	Method    string
+	Params    json.RawMessage
+	SessionID string
+	Text      string // Joined prompt text for session/prompt
+	conn      *mockagent.Conn
+}
+
+// Request calls back into the client (e.g. fs/read_text_file or
+// session/request_permission) and returns the result. An error reply from
+// the client is returned as an *Error.
+func (c *Call) Request(method string, params any) (json.RawMessage, error) {
+	msg, err := c.conn.Call(method, params)
+	if err != nil {
+		return nil, fromRPC(err)
+	}
+	return msg.Result, nil
+}
+
+// Update sends a session/update notification for the call's session
+func (c *Call) Update(update map[string]any) error {
+	return c.conn.Update(c.SessionID, update)
+}
+
+// Agent is a scriptable fake ACP agent.
+// Unscripted initialize, session/new, session/set_mode and session/prompt
+// requests get sensible defaults; other methods return MethodNotFound.
+type Agent struct {
+	mu       sync.Mutex
+	script   map[string][]Response
+	received []Call
+	sessions int
+}
+
+// NewAgent creates a fake agent with default behavior
+func NewAgent() *Agent {
+	return &Agent{script: make(map[string][]Response)}
+}
+
+// On queues a response for the next request of method. When several
+// responses are queued they are used in order; the last one repeats.
+func (a *Agent) On(method string, resp Response) *Agent {
+	a.mu.Lock()
+	a.script[method] = append(a.script[method], resp)
+	a.mu.Unlock()
+	return a
+}
+
+// Reply scripts session/prompt to stream text and then end the turn
+func (a *Agent) Reply(text string) *Agent {
+	return a.On("session/prompt", Response{
+		Updates: []map[string]any{TextChunk(text)},
+		Result:  map[string]any{"stopReason": "end_turn"},
+	})
+}
+
+// Fail scripts method to return an error
+func (a *Agent) Fail(method string, code int, message string) *Agent {
+	return a.On(method, Response{Error: &Error{Code: code, Message: message}})
+}
+
+// Received returns all requests and notifications received so far
+func (a *Agent) Received() []Call {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Call(nil), a.received...)
+}
+
+// Methods returns the methods received so far, in order
+func (a *Agent) Methods() []string {
+	var methods []string
+	for _, c := range a.Received() {
+		methods = append(methods, c.Method)
+	}
+	return methods
+}
+
+// Serve runs the fake agent until r is closed
+func (a *Agent) Serve(r io.Reader, w io.Writer) error {
+	conn := mockagent.NewConn(w, a.handle, func(c *mockagent.Conn, msg *jsonrpc.Message) {
+		a.record(c, msg)
+	})
+	return conn.Serve(r)
+}
+
+func (a *Agent) record(conn *mockagent.Conn, msg *jsonrpc.Message) *Call {
+	call := Call{Method: msg.Method, Params: msg.Params, conn: conn}
+	call.SessionID, call.Text = mockagent.PromptText(msg)
+	a.mu.Lock()
+	a.received = append(a.received, call)
+	a.mu.Unlock()
+	return &call
+}
+
+func (a *Agent) next(method string) (Response, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	queue := a.script[method]
+	if len(queue) == 0 {
+		return Response{}, false
+	}
+	resp := queue[0]
+	if len(queue) > 1 {
+		a.script[method] = queue[1:]
+	}
+	return resp, true
+}
+
+func (a *Agent) handle(conn *mockagent.Conn, msg *jsonrpc.Message) (any, error) {
+	call := a.record(conn, msg)
+
+	resp, ok := a.next(msg.Method)
+	if !ok {
+		return a.defaultReply(call)
+	}
+
+	if resp.Delay > 0 {
+		time.Sleep(resp.Delay)
+	}
+	for _, update := range resp.Updates {
+		call.Update(update)
+	}
+	if resp.Error != nil {
+		return nil, toRPC(resp.Error)
+	}
+	if resp.Func != nil {
+		result, err := resp.Func(call)
+		return result, toRPC(err)
+	}
+	return resp.Result, nil
+}
+
+func (a *Agent) defaultReply(call *Call) (any, error) {
+	switch call.Method {
+	case "initialize":
+		return map[string]any{"protocolVersion": 1, "agentCapabilities": map[string]any{}}, nil
+	case "session/new":
+		a.mu.Lock()
+		a.sessions++
+		id := fmt.Sprintf("fake-session-%d", a.sessions)
+		a.mu.Unlock()
+		return map[string]any{"sessionId": id}, nil
+	case "session/set_mode":
+		return map[string]any{}, nil
+	case "session/prompt":
+		return map[string]any{"stopReason": "end_turn"}, nil
+	default:
+		return nil, toRPC(&Error{Code: MethodNotFound, Message: "Method not found: " + call.Method})
+	}
+}
+
+// TextChunk builds an agent_message_chunk update
+func TextChunk(text string) map[string]any {
+	return map[string]any{
+		"sessionUpdate": "agent_message_chunk",
+		"content":       map[string]string{"type": "text", "text": text},
+	}
+}
+
+// ToolCall builds a tool_call update
+func ToolCall(id, title, status string) map[string]any {
+	return map[string]any{
+		"sessionUpdate": "tool_call",
+		"toolCallId":    id,
+		"title":         title,
+		"status":        status,
+	}
+}
@@ -0,0 +1,113 @@
+package acptest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/daodao97/acpone/pkg/acptest"
+)
+
+func prompt(sessionID, text string) map[string]any {
+	return map[string]any{"sessionId": sessionID, "prompt": []map[string]any{{"type": "text", "text": text}}}
+}
+
+func TestAgentScript(t *testing.T) {
+	fake := acptest.NewAgent().
+		Fail("session/load", acptest.MethodNotFound, "no loading").
+		Reply("hello")
+	p := acptest.NewProcess(t, fake)
+
+	if _, err := p.Request("session/load", map[string]any{"sessionId": "s1"}); err == nil || !strings.Contains(err.Error(), "no loading") {
+		t.Fatalf("scripted failure: %v", err)
+	}
+	msg, err := p.Request("session/new", map[string]any{"cwd": t.TempDir(), "mcpServers": []any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.Unmarshal(msg.Result, &session); err != nil || session.SessionID == "" {
+		t.Fatalf("session/new: %s", msg.Result)
+	}
+	if _, err := p.Request("session/prompt", prompt(session.SessionID, "hi there")); err != nil {
+		t.Fatal(err)
+	}
+
+	methods := fake.Methods()
+	if !slices.Contains(methods, "session/load") || !slices.Contains(methods, "session/prompt") {
+		t.Errorf("received methods %v", methods)
+	}
+	for _, call := range fake.Received() {
+		if call.Method == "session/prompt" && (call.SessionID != session.SessionID || call.Text != "hi there") {
+			t.Errorf("prompt received as session %q, text %q", call.SessionID, call.Text)
+		}
+	}
+}
+
+func TestCallRequest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "note.txt")
+	os.WriteFile(path, []byte("from disk"), 0644)
+
+	var content string
+	var missing error
+	fake := acptest.NewAgent().On("session/prompt", acptest.Response{Func: func(c *acptest.Call) (any, error) {
+		result, err := c.Request("fs/read_text_file", map[string]any{"sessionId": c.SessionID, "path": path})
+		if err != nil {
+			return nil, err
+		}
+		var file struct {
+			Content string `json:"content"`
+		}
+		json.Unmarshal(result, &file)
+		content = file.Content
+
+		_, missing = c.Request("fs/read_text_file", map[string]any{"sessionId": c.SessionID, "path": filepath.Join(dir, "missing")})
+		return nil, &acptest.Error{Code: acptest.InvalidParams, Message: "done reading"}
+	}})
+	p := acptest.NewProcess(t, fake)
+
+	_, err := p.Request("session/prompt", prompt("s1", "read"))
+	if err == nil || !strings.Contains(err.Error(), "done reading") {
+		t.Fatalf("error returned by Func: %v", err)
+	}
+	if content != "from disk" {
+		t.Errorf("read %q through the client", content)
+	}
+	var rpcErr *acptest.Error
+	if !errors.As(missing, &rpcErr) || rpcErr.Code != acptest.InternalError {
+		t.Errorf("reading a missing file: %v", missing)
+	}
+}
+
+func TestServerChat(t *testing.T) {
+	fake := acptest.NewAgent().Reply("hello from the fake")
+	s := acptest.NewServer(t, map[string]*acptest.Agent{"fake": fake}, "fake")
+
+	events := s.Chat(t, map[string]any{"message": "hi"})
+	if _, ok := acptest.Find(events, "done"); !ok {
+		t.Fatalf("no done event in %d events", len(events))
+	}
+	var text strings.Builder
+	for _, e := range events {
+		var data struct {
+			Update struct {
+				Content struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"update"`
+		}
+		if e.Name == "update" && e.Decode(&data) == nil {
+			text.WriteString(data.Update.Content.Text)
+		}
+	}
+	if !strings.Contains(text.String(), "hello from the fake") {
+		t.Errorf("reply not streamed: %q", text.String())
+	}
+}
@@ -0,0 +1,138 @@
+package acptest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/daodao97/acpone/internal/agent"
+	"github.com/daodao97/acpone/internal/api"
+	"github.com/daodao97/acpone/internal/config"
+)
+
+var fakeSeq int64
+
+// Register makes the fake agent available as an in-process command and
+// returns the command name to use in an AgentConfig
+func Register(a *Agent) string {
+	command := fmt.Sprintf("builtin:acptest-%d", atomic.AddInt64(&fakeSeq, 1))
+	agent.RegisterBuiltin(command, a.Serve)
+	return command
+}
+
+// AgentConfig returns an agent config backed by the fake agent
+func AgentConfig(id string, a *Agent) config.AgentConfig {
+	return config.AgentConfig{ID: id, Name: id, Command: Register(a)}
+}
+
+// NewProcess starts an agent.Process talking to the fake agent.
+// The process is stopped when the test ends.
+func NewProcess(t testing.TB, a *Agent) *agent.Process {
+	t.Helper()
+	cfg := AgentConfig("fake", a)
+	p := agent.NewProcess(&cfg)
+	if err := p.Start(); err != nil {
+		t.Fatalf("start fake agent: %v", err)
+	}
+	t.Cleanup(func() { p.Stop() })
+	return p
+}
+
+// Server is an api.Server running on an httptest server with isolated storage
+type Server struct {
+	*api.Server
+	HTTP *httptest.Server
+	Dir  string // Workspace directory
+}
+
+// NewServer starts an api.Server whose agents are the given fakes, keyed by agent ID.
+// HOME is redirected to a temp dir so sessions never touch real user data.
+func NewServer(t testing.TB, agents map[string]*Agent, defaultAgent string) *Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", "")
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DefaultAgent:     defaultAgent,
+		Workspaces:       []config.WorkspaceConfig{{ID: "default", Name: "Default", Path: dir}},
+		DefaultWorkspace: "default",
+	}
+	for id, a := range agents {
+		cfg.Agents = append(cfg.Agents, AgentConfig(id, a))
+	}
+
+	s := api.NewServer(cfg, nil)
+	hs := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		hs.Close()
+		s.Shutdown()
+	})
+	return &Server{Server: s, HTTP: hs, Dir: dir}
+}
+
+// Event is a parsed server-sent event
+type Event struct {
+	Name string
+	Data json.RawMessage
+}
+
+// Decode unmarshals the event data into v
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Chat posts a chat request and returns all SSE events of the turn
+func (s *Server) Chat(t testing.TB, req map[string]any) []Event {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	resp, err := s.HTTP.Client().Post(s.HTTP.URL+"/api/chat", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("chat: status %d", resp.StatusCode)
+	}
+	return ReadSSE(resp.Body)
+}
+
+// ReadSSE parses a text/event-stream body until EOF
+func ReadSSE(r io.Reader) []Event {
+	var events []Event
+	var current Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.Name != "" || current.Data != nil {
+				events = append(events, current)
+			}
+			current = Event{}
+		case strings.HasPrefix(line, "event: "):
+			current.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.Data = json.RawMessage(strings.TrimPrefix(line, "data: "))
+		}
+	}
+	return events
+}
+
+// Find returns the first event with the given name
+func Find(events []Event, name string) (Event, bool) {
+	for _, e := range events {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
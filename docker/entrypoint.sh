@@ -0,0 +1,17 @@
+#!/bin/sh
+# Container entrypoint: prepares the mounted volumes, runs the setup checks
+# and serves. Extra arguments are passed to acpone, e.g. -offline.
+set -e
+
+mkdir -p "${ACPONE_DATA_DIR:-/data}" "${HOME}" "${ACPONE_WORKSPACE:-/workspace}"
+
+if [ -z "${ACPONE_AUTH_TOKEN}" ]; then
+    echo "⚠️  ACPONE_AUTH_TOKEN is not set: anyone who can reach port ${ACPONE_PORT:-3000} can run agents" >&2
+fi
+
+check="-check"
+if [ "${ACPONE_REQUIRE_READY}" = "1" ]; then
+    check="-require-ready"
+fi
+
+exec acpone "${check}" "$@"
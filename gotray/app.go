@@ -22,6 +22,9 @@ type App struct {
 	// 生命周期回调
 	OnReady func(app *App)
 	OnExit  func()
+	// OnTrayUnavailable 在 OnReady 之后、托盘图标无法显示时调用（见 TrayAvailable），
+	// 应用可以借此打开主界面并提示用户，而不是悄无声息地运行
+	OnTrayUnavailable func(app *App)
 
 	// 内部状态
 	menus []*MenuItem
@@ -34,6 +37,9 @@ func (a *App) Run() {
 		if a.OnReady != nil {
 			a.OnReady(a)
 		}
+		if a.OnTrayUnavailable != nil && !TrayAvailable() {
+			a.OnTrayUnavailable(a)
+		}
 	}, func() {
 		if a.OnExit != nil {
 			a.OnExit()
@@ -0,0 +1,77 @@
+package gotray
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// 菜单项状态圆点的常用颜色
+var (
+	DotGreen  = color.RGBA{0x34, 0xc7, 0x59, 0xff}
+	DotYellow = color.RGBA{0xff, 0xcc, 0x00, 0xff}
+	DotRed    = color.RGBA{0xff, 0x3b, 0x30, 0xff}
+	DotGray   = color.RGBA{0x8e, 0x8e, 0x93, 0xff}
+)
+
+// dotSize 是圆点图标的边长（像素），与菜单文字高度相当
+const dotSize = 16
+
+var (
+	dotMu    sync.Mutex
+	dotCache = map[color.RGBA][]byte{}
+)
+
+// StatusDot 生成指定颜色的实心圆点图标，可用作菜单项图标。
+// Windows 上返回 ICO，其他平台返回 PNG；同一颜色只生成一次。
+func StatusDot(c color.Color) []byte {
+	key := color.RGBAModel.Convert(c).(color.RGBA)
+	dotMu.Lock()
+	defer dotMu.Unlock()
+	if icon, ok := dotCache[key]; ok {
+		return icon
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, dotSize, dotSize))
+	center, radius := float64(dotSize)/2, float64(dotSize)/2-3
+	for y := 0; y < dotSize; y++ {
+		for x := 0; x < dotSize; x++ {
+			dx, dy := float64(x)+0.5-center, float64(y)+0.5-center
+			// 边缘一个像素做抗锯齿
+			alpha := radius + 0.5 - math.Hypot(dx, dy)
+			if alpha <= 0 {
+				continue
+			}
+			img.SetNRGBA(x, y, color.NRGBA{key.R, key.G, key.B, uint8(min(alpha, 1) * 255)})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+
+	icon := buf.Bytes()
+	if runtime.GOOS == "windows" {
+		icon = pngToICO(icon, dotSize)
+	}
+	dotCache[key] = icon
+	return icon
+}
+
+// pngToICO 把 PNG 包装成只含一张图的 ICO（Windows Vista 起支持 PNG 压缩的图标）
+func pngToICO(data []byte, size int) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Reserved, Type, Count uint16
+	}{0, 1, 1})
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Width, Height, Colors, Reserved uint8
+		Planes, BitCount                uint16
+		Size, Offset                    uint32
+	}{uint8(size), uint8(size), 0, 0, 1, 32, uint32(len(data)), 6 + 16})
+	buf.Write(data)
+	return buf.Bytes()
+}
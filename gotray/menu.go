@@ -1,19 +1,65 @@
 package gotray
 
 import (
+	"bytes"
+	"image/color"
+
 	"github.com/getlantern/systray"
 )
 
 // MenuItem 表示一个菜单项
 type MenuItem struct {
-	Title    string
-	Tooltip  string
-	Icon     []byte
-	OnClick  func(item *MenuItem)
-	Disabled bool
-	Hidden   bool
+	Title   string
+	Tooltip string
+	// 菜单项图标（Windows 为 ICO，其他平台为 PNG）。IconDisabled、IconChecked
+	// 在禁用、勾选时替代 Icon，为空则沿用 Icon。Linux 托盘不支持菜单项图标，
+	// 设置后不显示。
+	Icon         []byte
+	IconDisabled []byte
+	IconChecked  []byte
+	OnClick      func(item *MenuItem)
+	Disabled     bool
+	Hidden       bool
 
 	sysItem *systray.MenuItem
+	shown   []byte // 当前显示的图标
+}
+
+// SetIcon 设置菜单图标，运行中也可以调用
+func (m *MenuItem) SetIcon(icon []byte) {
+	m.Icon = icon
+	m.applyIcon()
+}
+
+// SetStateIcons 设置禁用和勾选状态的图标
+func (m *MenuItem) SetStateIcons(disabled, checked []byte) {
+	m.IconDisabled = disabled
+	m.IconChecked = checked
+	m.applyIcon()
+}
+
+// SetStatus 用指定颜色的圆点作为图标，如 DotGreen、DotRed，见 StatusDot
+func (m *MenuItem) SetStatus(c color.Color) {
+	m.SetIcon(StatusDot(c))
+}
+
+// applyIcon 按当前状态显示对应图标，图标未变化时不重复设置
+func (m *MenuItem) applyIcon() {
+	if m.sysItem == nil {
+		return
+	}
+	icon := m.Icon
+	switch {
+	case m.Disabled && len(m.IconDisabled) > 0:
+		icon = m.IconDisabled
+	case m.sysItem.Checked() && len(m.IconChecked) > 0:
+		icon = m.IconChecked
+	}
+	if len(icon) == 0 || bytes.Equal(icon, m.shown) {
+		return
+	}
+	m.shown = icon
+	m.sysItem.SetIcon(icon)
 }
 
 // SetTitle 设置菜单标题
@@ -37,6 +83,7 @@ func (m *MenuItem) Enable() {
 	m.Disabled = false
 	if m.sysItem != nil {
 		m.sysItem.Enable()
+		m.applyIcon()
 	}
 }
 
@@ -45,6 +92,7 @@ func (m *MenuItem) Disable() {
 	m.Disabled = true
 	if m.sysItem != nil {
 		m.sysItem.Disable()
+		m.applyIcon()
 	}
 }
 
@@ -68,6 +116,7 @@ func (m *MenuItem) Hide() {
 func (m *MenuItem) Check() {
 	if m.sysItem != nil {
 		m.sysItem.Check()
+		m.applyIcon()
 	}
 }
 
@@ -75,6 +124,7 @@ func (m *MenuItem) Check() {
 func (m *MenuItem) Uncheck() {
 	if m.sysItem != nil {
 		m.sysItem.Uncheck()
+		m.applyIcon()
 	}
 }
 
@@ -99,12 +149,10 @@ func (a *App) AddMenuWithOptions(item *MenuItem) *MenuItem {
 	sysItem := systray.AddMenuItem(item.Title, item.Tooltip)
 	item.sysItem = sysItem
 
-	if len(item.Icon) > 0 {
-		sysItem.SetIcon(item.Icon)
-	}
 	if item.Disabled {
 		sysItem.Disable()
 	}
+	item.applyIcon()
 	if item.Hidden {
 		sysItem.Hide()
 	}
@@ -121,6 +169,26 @@ func (a *App) AddMenuWithOptions(item *MenuItem) *MenuItem {
 	return item
 }
 
+// AddSubMenu 在菜单项下添加子菜单项
+func (m *MenuItem) AddSubMenu(title string, onClick func(item *MenuItem)) *MenuItem {
+	sysItem := m.sysItem.AddSubMenuItem(title, "")
+	item := &MenuItem{
+		Title:   title,
+		OnClick: onClick,
+		sysItem: sysItem,
+	}
+
+	go func() {
+		for range sysItem.ClickedCh {
+			if item.OnClick != nil {
+				item.OnClick(item)
+			}
+		}
+	}()
+
+	return item
+}
+
 // AddCheckbox 添加复选框菜单项
 func (a *App) AddCheckbox(title string, checked bool, onClick func(item *MenuItem)) *MenuItem {
 	sysItem := systray.AddMenuItemCheckbox(title, "", checked)
@@ -166,6 +234,7 @@ func (a *App) AddGroup(title string, items []*MenuItem) *MenuGroup {
 	for _, item := range items {
 		subItem := parent.AddSubMenuItem(item.Title, item.Tooltip)
 		item.sysItem = subItem
+		item.applyIcon()
 
 		itemRef := item
 		go func() {
@@ -198,24 +267,22 @@ func (a *App) AddRadioGroup(title string, defaultIdx int, items []*MenuItem) *Ra
 		sysItem:  parent,
 	}
 
-	var sysItems []*systray.MenuItem
-
 	for i, item := range items {
 		checked := i == defaultIdx
 		subItem := parent.AddSubMenuItemCheckbox(item.Title, item.Tooltip, checked)
 		item.sysItem = subItem
-		sysItems = append(sysItems, subItem)
+		item.applyIcon()
 
 		idx := i
 		itemRef := item
 		go func() {
 			for range subItem.ClickedCh {
 				// 更新选中状态
-				for j, si := range sysItems {
+				for j, it := range items {
 					if j == idx {
-						si.Check()
+						it.Check()
 					} else {
-						si.Uncheck()
+						it.Uncheck()
 					}
 				}
 				group.Selected = idx
@@ -0,0 +1,48 @@
+package gotray
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// snWatcher 是 StatusNotifierItem 图标的宿主，Linux 托盘图标通过它显示
+const snWatcher = "org.kde.StatusNotifierWatcher"
+
+// TrayAvailable 检查托盘图标能否显示。
+// macOS 和 Windows 总是可以；Linux 上需要 StatusNotifierWatcher（KDE、XFCE、
+// Cinnamon 等桌面自带，GNOME 需要 AppIndicator 扩展），否则图标不会出现，
+// 应用看起来像没有启动。没有 gdbus/dbus-send 无法检测时视为可用。
+func TrayAvailable() bool {
+	if runtime.GOOS != "linux" {
+		return true
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" && os.Getenv("XDG_RUNTIME_DIR") == "" {
+		return true
+	}
+	available, ok := nameHasOwner(snWatcher)
+	return available || !ok
+}
+
+// nameHasOwner 询问会话总线某个名字是否有人持有，ok 为 false 表示无法询问
+func nameHasOwner(name string) (available, ok bool) {
+	if path, err := exec.LookPath("gdbus"); err == nil {
+		out, err := exec.Command(path, "call", "--session",
+			"--dest", "org.freedesktop.DBus",
+			"--object-path", "/org/freedesktop/DBus",
+			"--method", "org.freedesktop.DBus.NameHasOwner", name).Output()
+		if err == nil {
+			return strings.Contains(string(out), "true"), true
+		}
+	}
+	if path, err := exec.LookPath("dbus-send"); err == nil {
+		out, err := exec.Command(path, "--session", "--print-reply",
+			"--dest=org.freedesktop.DBus", "/org/freedesktop/DBus",
+			"org.freedesktop.DBus.NameHasOwner", "string:"+name).Output()
+		if err == nil {
+			return strings.Contains(string(out), "boolean true"), true
+		}
+	}
+	return false, false
+}
@@ -1,4 +1,4 @@
-import type { Agent, Session, SessionMeta, Workspace } from '../types'
+import type { Agent, AgentProcess, CatalogAgent, Pin, Session, SessionMeta, SessionSearchHit, Workspace } from '../types'
 
 const API_BASE = '/api'
 
@@ -6,14 +6,17 @@ export async function fetchAgents(): Promise<{ agents: Agent[]; default: string
   const res = await fetch(`${API_BASE}/agents`)
   const data = await res.json()
   const agents = (data.agents || []).map(
-    (a: { id: string; name: string; permissionMode?: string; command?: string; args?: string[]; commands?: unknown[]; env?: Record<string, string> }) => ({
+    (a: { id: string; name: string; aliases?: string[]; enabled?: boolean; permissionMode?: string; command?: string; args?: string[]; commands?: unknown[]; env?: Record<string, string>; version?: Agent['version'] }) => ({
       id: a.id,
       name: a.name,
+      aliases: a.aliases || [],
+      enabled: a.enabled !== false,
       permissionMode: a.permissionMode || 'default',
       command: a.command,
       args: a.args,
       commands: a.commands,
       env: a.env || {},
+      version: a.version,
     })
   )
   return { agents, default: data.default }
@@ -42,6 +45,37 @@ export async function fetchWorkspaceFiles(
   return data.files || []
 }
 
+export async function fetchRecentFiles(workspaceId: string, limit: number = 20): Promise<FileInfo[]> {
+  const params = new URLSearchParams({ workspaceId, limit: String(limit) })
+  const res = await fetch(`${API_BASE}/files/recent?${params}`)
+  const data = await res.json()
+  return (data.files || []).map((f: { path: string; name: string }) => ({ path: f.path, name: f.name, isDir: false }))
+}
+
+export interface UsageEntry {
+  path: string
+  bytes: number
+  files: number
+}
+
+export interface WorkspaceUsage {
+  workspaceId: string
+  uploads: UsageEntry
+  transcripts: UsageEntry
+  heavyDirs: UsageEntry[]
+  total: number
+  quotas: { uploadsMB?: number; transcriptsMB?: number; totalMB?: number }
+  exceeded?: string[]
+  measuredAt: number
+}
+
+export async function fetchWorkspaceUsage(workspaceId: string, refresh = false): Promise<WorkspaceUsage | null> {
+  const query = refresh ? '?refresh=1' : ''
+  const res = await fetch(`${API_BASE}/workspaces/${encodeURIComponent(workspaceId)}/usage${query}`)
+  if (!res.ok) return null
+  return res.json()
+}
+
 export async function createWorkspace(
   name: string,
   path: string
@@ -64,11 +98,22 @@ export async function fetchSessions(): Promise<SessionMeta[]> {
   return data.sessions || []
 }
 
+// Searches titles, messages, tool calls and tool outputs; qualifiers like
+// output:connection refused limit a phrase to one field
+export async function searchSessions(q: string, workspaceId?: string): Promise<SessionSearchHit[]> {
+  const params = new URLSearchParams({ q })
+  if (workspaceId) params.set('workspaceId', workspaceId)
+  const res = await fetch(`${API_BASE}/sessions/search?${params}`)
+  if (!res.ok) return []
+  const data = await res.json()
+  return data.results || []
+}
+
 export async function fetchSession(id: string): Promise<Session | null> {
   const res = await fetch(`${API_BASE}/sessions/${id}`)
   if (!res.ok) return null
   const data = await res.json()
-  return data.session || null
+  return data.session ? { ...data.session, context: data.context } : null
 }
 
 export async function createSession(workspaceId?: string): Promise<SessionMeta> {
@@ -81,6 +126,67 @@ export async function createSession(workspaceId?: string): Promise<SessionMeta>
   return data.session
 }
 
+export async function updateSession(
+  id: string,
+  update: {
+    activeAgent?: string
+    mentionMode?: string
+    agentParams?: Record<string, unknown> | null
+    limits?: {
+      maxChars?: number
+      maxToolCalls?: number
+      maxDurationMs?: number
+      maxRepeats?: number
+      onRepeat?: 'warn' | 'cancel'
+    } | null
+  }
+): Promise<{ success: boolean; error?: string }> {
+  const res = await fetch(`${API_BASE}/sessions/${id}`, {
+    method: 'PATCH',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(update),
+  })
+  const data = await res.json()
+  if (!res.ok) {
+    return { success: false, error: data.error || 'Failed to update session' }
+  }
+  return { success: true }
+}
+
+export function sessionExportUrl(id: string): string {
+  return `${API_BASE}/sessions/${id}/export?format=html`
+}
+
+export async function fetchPins(id: string): Promise<Pin[]> {
+  const res = await fetch(`${API_BASE}/sessions/${id}/context`)
+  const data = await res.json()
+  return data.pins || []
+}
+
+export async function addPin(
+  id: string,
+  pin: { type: Pin['type']; value: string; name?: string }
+): Promise<{ pins: Pin[]; error?: string }> {
+  const res = await fetch(`${API_BASE}/sessions/${id}/context`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(pin),
+  })
+  const data = await res.json()
+  if (!res.ok) {
+    return { pins: [], error: data.error || 'Failed to pin' }
+  }
+  return { pins: data.pins }
+}
+
+export async function removePin(id: string, pinId: string): Promise<Pin[]> {
+  const res = await fetch(`${API_BASE}/sessions/${id}/context?id=${encodeURIComponent(pinId)}`, {
+    method: 'DELETE',
+  })
+  const data = await res.json()
+  return data.pins || []
+}
+
 export async function deleteSession(id: string): Promise<void> {
   await fetch(`${API_BASE}/sessions/${id}`, { method: 'DELETE' })
 }
@@ -113,6 +219,41 @@ export async function updateAgentPermission(
   return { success: true }
 }
 
+export async function updateAgentEnabled(
+  agentId: string,
+  enabled: boolean
+): Promise<{ success: boolean; error?: string }> {
+  const res = await fetch(`${API_BASE}/agents/update`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ agentId, enabled }),
+  })
+  const data = await res.json()
+  if (!res.ok) {
+    return { success: false, error: data.error || 'Failed to update agent' }
+  }
+  return { success: true }
+}
+
+export async function fetchAgentProcess(agentId: string): Promise<AgentProcess | null> {
+  const res = await fetch(`${API_BASE}/agents/${encodeURIComponent(agentId)}/status`)
+  if (!res.ok) return null
+  return res.json()
+}
+
+// Stops or restarts an agent's process, failing the turns it is running
+export async function controlAgent(
+  agentId: string,
+  action: 'stop' | 'restart'
+): Promise<{ success: boolean; process?: AgentProcess; error?: string }> {
+  const res = await fetch(`${API_BASE}/agents/${encodeURIComponent(agentId)}/${action}`, { method: 'POST' })
+  const data = await res.json()
+  if (!res.ok) {
+    return { success: false, error: data.error || `Failed to ${action} agent` }
+  }
+  return { success: true, process: data }
+}
+
 export async function updateAgentEnv(
   agentId: string,
   env: Record<string, string>
@@ -170,6 +311,31 @@ export async function uploadFiles(
   return { success: true, files: data.files }
 }
 
+export async function fetchTranscribeStatus(): Promise<boolean> {
+  const res = await fetch(`${API_BASE}/transcribe`)
+  if (!res.ok) return false
+  const data = await res.json()
+  return !!data.enabled
+}
+
+export async function transcribeAudio(
+  audio: Blob,
+  filename: string
+): Promise<{ text?: string; error?: string }> {
+  const formData = new FormData()
+  formData.append('audio', audio, filename)
+
+  const res = await fetch(`${API_BASE}/transcribe`, {
+    method: 'POST',
+    body: formData,
+  })
+  const data = await res.json()
+  if (!res.ok) {
+    return { error: data.error || 'Failed to transcribe' }
+  }
+  return { text: data.text }
+}
+
 export async function cleanupFiles(
   workspaceId: string
 ): Promise<{ success: boolean; error?: string }> {
@@ -191,6 +357,10 @@ export interface ChatFile {
   size: number
 }
 
+// Without any streamed event by then, a proxy is likely buffering the
+// response and the turn is followed through /api/chat/poll instead
+const STREAM_FALLBACK_MS = 8000
+
 export function sendMessage(
   message: string,
   conversationId: string | null,
@@ -199,12 +369,40 @@ export function sendMessage(
   onEvent: (event: unknown) => void
 ): AbortController {
   const controller = new AbortController()
+  const turnId = `${Date.now().toString(36)}-${Math.random().toString(36).slice(2)}`
+
+  const dispatch = (eventType: string, data: Record<string, unknown>) => {
+    if (eventType === 'error') {
+      onEvent({ error: data.message || 'Unknown error', ...data })
+    } else if (eventType === 'commands') {
+      // Commands event: data is { agent, commands }
+      onEvent({ _eventType: 'commands', ...data })
+    } else {
+      // Pass event type to callback
+      onEvent({ _eventType: eventType, ...data })
+    }
+  }
+
+  let streamed = false
+  let polling = false
+  const stream = new AbortController()
+  controller.signal.addEventListener('abort', () => stream.abort())
+  const fallback = setTimeout(() => {
+    if (streamed) return
+    polling = true
+    stream.abort()
+    pollTurn(turnId, controller.signal, dispatch).catch((err) => {
+      if (err.name !== 'AbortError') {
+        onEvent({ error: err.message })
+      }
+    })
+  }, STREAM_FALLBACK_MS)
 
   fetch(`${API_BASE}/chat`, {
     method: 'POST',
     headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({ message, conversationId, workspaceId, files }),
-    signal: controller.signal,
+    body: JSON.stringify({ message, conversationId, workspaceId, files, turnId }),
+    signal: stream.signal,
   })
     .then(async (response) => {
       const reader = response.body?.getReader()
@@ -226,17 +424,10 @@ export function sendMessage(
           if (line.startsWith('event: ')) {
             currentEventType = line.slice(7).trim()
           } else if (line.startsWith('data: ')) {
+            streamed = true
+            clearTimeout(fallback)
             try {
-              const data = JSON.parse(line.slice(6))
-              if (currentEventType === 'error') {
-                onEvent({ error: data.message || 'Unknown error', ...data })
-              } else if (currentEventType === 'commands') {
-                // Commands event: data is { agent, commands }
-                onEvent({ _eventType: 'commands', ...data })
-              } else {
-                // Pass event type to callback
-                onEvent({ _eventType: currentEventType, ...data })
-              }
+              dispatch(currentEventType, JSON.parse(line.slice(6)))
             } catch {
               // ignore parse errors
             }
@@ -246,10 +437,82 @@ export function sendMessage(
       }
     })
     .catch((err) => {
-      if (err.name !== 'AbortError') {
+      if (err.name !== 'AbortError' && !polling) {
         onEvent({ error: err.message })
       }
     })
+    .finally(() => {
+      if (!polling) clearTimeout(fallback)
+    })
 
   return controller
 }
+
+// pollTurn follows a chat turn through /api/chat/poll until it ends
+async function pollTurn(
+  turnId: string,
+  signal: AbortSignal,
+  dispatch: (eventType: string, data: Record<string, unknown>) => void
+) {
+  let cursor = 0
+  while (true) {
+    const res = await fetch(
+      `${API_BASE}/chat/poll?turnId=${encodeURIComponent(turnId)}&cursor=${cursor}`,
+      { signal }
+    )
+    const data = await res.json()
+    if (!res.ok) {
+      throw new Error(data.error || 'Failed to poll turn')
+    }
+    for (const e of data.events as { event: string; data: Record<string, unknown> }[]) {
+      dispatch(e.event, e.data)
+    }
+    cursor = data.cursor
+    if (data.done) return
+  }
+}
+
+export async function fetchAuthStatus(): Promise<{ required: boolean; authenticated: boolean }> {
+  const res = await fetch(`${API_BASE}/auth/status`)
+  return res.json()
+}
+
+// login signs the browser in with an auth token or API key, kept in a cookie
+export async function login(token: string): Promise<{ success: boolean; error?: string }> {
+  const res = await fetch(`${API_BASE}/auth/login`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ token }),
+  })
+  const data = await res.json()
+  if (!res.ok) {
+    return { success: false, error: data.error || 'Failed to sign in' }
+  }
+  return { success: true }
+}
+
+export async function logout(): Promise<void> {
+  await fetch(`${API_BASE}/auth/logout`, { method: 'POST' })
+}
+
+export async function fetchCatalog(): Promise<CatalogAgent[]> {
+  const res = await fetch(`${API_BASE}/catalog`)
+  const data = await res.json()
+  return data.agents || []
+}
+
+export async function addCatalogAgent(
+  id: string,
+  env?: Record<string, string>
+): Promise<{ success: boolean; error?: string }> {
+  const res = await fetch(`${API_BASE}/catalog/add`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ id, env }),
+  })
+  const data = await res.json()
+  if (!res.ok) {
+    return { success: false, error: data.error || 'Failed to add agent' }
+  }
+  return { success: true }
+}
@@ -13,6 +13,8 @@ const messages: Record<Lang, Record<string, string>> = {
         'settings.agents': 'Agents Configuration',
         'settings.agents.desc': 'Available ACP agents and their settings.',
         'settings.default': 'Default',
+        'settings.enabled': 'Enabled',
+        'settings.enabled.desc': 'Disabled agents are kept in the config but excluded from routing, setup checks and pickers.',
         'settings.permission': 'Permission',
         'settings.permission.default': 'User Confirmation',
         'settings.permission.default.desc': 'Requires user approval for tool calls (recommended)',
@@ -23,7 +25,22 @@ const messages: Record<Lang, Record<string, string>> = {
         'settings.save': 'Save',
         'settings.saving': 'Saving...',
         'settings.env': 'Environment Variables',
+        'settings.version': 'Version',
+        'settings.process': 'Process',
+        'settings.process.pid': 'pid',
+        'settings.process.up': 'up',
+        'settings.process.turns': 'turns running',
+        'settings.process.unresponsive': 'unresponsive',
+        'settings.process.stop': 'Stop',
+        'settings.process.restart': 'Restart',
+        'settings.process.confirm': 'The turns this agent is running will fail. Continue?',
+        'settings.updateAvailable': 'Update available:',
         'settings.env.desc': 'Configure environment variables for this agent.',
+        'settings.catalog': 'Add Agents',
+        'settings.catalog.desc': 'Known ACP agents that can be added to the config in one click.',
+        'settings.catalog.env': 'Set:',
+        'settings.catalog.add': 'Add',
+        'settings.catalog.added': 'Added',
         'settings.appearance': 'Appearance',
         'settings.theme': 'Interface Theme',
         'settings.theme.dark': 'Dark Mode ☾',
@@ -34,14 +51,34 @@ const messages: Record<Lang, Record<string, string>> = {
         'welcome.start': 'Start chatting!',
         'welcome.mention': 'Use @ to mention an agent',
         'welcome.select_workspace': 'Please select a workspace first',
+        'chat.switch': 'Keep talking to this agent?',
+        'chat.switch.yes': 'Switch',
+        'chat.switch.no': 'Just this turn',
+        'chat.context': 'Estimated conversation context',
+        'chat.context.warning': 'Nearing the context window. Consider compacting or starting a new conversation.',
+
+        // App
+        'app.updated': 'ACPone has been updated. Reload to use the new version.',
+        'app.reload': 'Reload',
+        'auth.title': 'Sign in to ACPone',
+        'auth.desc': 'This server requires an auth token or API key.',
+        'auth.token': 'Token',
+        'auth.signIn': 'Sign in',
+        'auth.invalid': 'Invalid token',
 
         // Input
         'input.placeholder': 'Message... (Type @ to mention, / for commands)',
         'input.dropFiles': 'Drop files here to upload',
+        'input.voice': 'Voice input',
+        'input.stopRecording': 'Stop recording',
 
         // Sidebar
         'sidebar.new_chat': 'New Chat',
         'sidebar.settings': 'Settings',
+        'sidebar.export': 'Export as HTML',
+        'sidebar.search': 'Search chats',
+        'sidebar.search_hint': 'Qualify a phrase with title:, text:, tool: or output:, e.g. output:connection refused',
+        'sidebar.no_results': 'No matching chats',
     },
     zh: {
         // Settings
@@ -49,6 +86,8 @@ const messages: Record<Lang, Record<string, string>> = {
         'settings.agents': '智能体配置',
         'settings.agents.desc': '可用的 ACP 智能体及其设置。',
         'settings.default': '默认',
+        'settings.enabled': '启用',
+        'settings.enabled.desc': '停用的智能体保留配置，但不参与路由、依赖检查和选择列表。',
         'settings.permission': '权限模式',
         'settings.permission.default': '用户确认',
         'settings.permission.default.desc': '工具调用需要用户批准（推荐）',
@@ -59,7 +98,22 @@ const messages: Record<Lang, Record<string, string>> = {
         'settings.save': '保存',
         'settings.saving': '保存中...',
         'settings.env': '环境变量',
+        'settings.version': '版本',
+        'settings.process': '进程',
+        'settings.process.pid': 'pid',
+        'settings.process.up': '已运行',
+        'settings.process.turns': '轮对话进行中',
+        'settings.process.unresponsive': '无响应',
+        'settings.process.stop': '停止',
+        'settings.process.restart': '重启',
+        'settings.process.confirm': '该智能体正在进行的对话将会失败，确定继续？',
+        'settings.updateAvailable': '有可用更新：',
         'settings.env.desc': '配置该智能体的环境变量。',
+        'settings.catalog': '添加智能体',
+        'settings.catalog.desc': '已知的 ACP 智能体，一键添加到配置。',
+        'settings.catalog.env': '需设置：',
+        'settings.catalog.add': '添加',
+        'settings.catalog.added': '已添加',
         'settings.appearance': '外观设置',
         'settings.theme': '界面主题',
         'settings.theme.dark': '深色模式 ☾',
@@ -70,14 +124,34 @@ const messages: Record<Lang, Record<string, string>> = {
         'welcome.start': '开始对话！',
         'welcome.mention': '使用 @ 呼叫智能体',
         'welcome.select_workspace': '请先选择一个工作区',
+        'chat.switch': '之后继续使用这个智能体吗？',
+        'chat.switch.yes': '切换',
+        'chat.switch.no': '仅本轮',
+        'chat.context': '估算的会话上下文',
+        'chat.context.warning': '即将达到上下文窗口上限，建议压缩或开启新会话。',
+
+        // App
+        'app.updated': 'ACPone 已更新，请刷新页面以使用新版本。',
+        'app.reload': '刷新',
+        'auth.title': '登录 ACPone',
+        'auth.desc': '此服务器需要访问令牌或 API Key。',
+        'auth.token': '令牌',
+        'auth.signIn': '登录',
+        'auth.invalid': '令牌无效',
 
         // Input
         'input.placeholder': '输入消息... (输入 @ 呼叫智能体, / 使用命令)',
         'input.dropFiles': '拖放文件到此处上传',
+        'input.voice': '语音输入',
+        'input.stopRecording': '停止录音',
 
         // Sidebar
         'sidebar.new_chat': '新对话',
         'sidebar.settings': '设置',
+        'sidebar.export': '导出为 HTML',
+        'sidebar.search': '搜索对话',
+        'sidebar.search_hint': '可用 title:、text:、tool:、output: 限定字段，例如 output:connection refused',
+        'sidebar.no_results': '没有匹配的对话',
     }
 }
 
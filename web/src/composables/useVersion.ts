@@ -0,0 +1,58 @@
+import { ref } from 'vue'
+
+// Build this page was loaded from, injected by the server into index.html.
+// Missing in the Vite dev server, where version checks are skipped.
+const loaded = document.querySelector('meta[name="acpone-version"]')?.getAttribute('content') || ''
+
+// True once the server reports a different build than the one loaded
+const stale = ref(false)
+
+let eventSource: EventSource | null = null
+
+function check(client?: string) {
+    if (loaded && client && client !== loaded) {
+        stale.value = true
+    }
+}
+
+async function fetchVersion() {
+    try {
+        const res = await fetch('/api/version')
+        const data = await res.json()
+        check(data.client)
+    } catch {
+        // Server unreachable, checked again on reconnect
+    }
+}
+
+// watch listens for version events on the event stream. The stream drops
+// when the server restarts; on reconnect the version is checked again, so
+// an update installed meanwhile is noticed.
+function watch() {
+    if (!loaded || eventSource) return
+    eventSource = new EventSource('/api/events?topics=config')
+    eventSource.onopen = () => fetchVersion()
+    eventSource.addEventListener('config', (e) => {
+        try {
+            const event = JSON.parse((e as MessageEvent).data)
+            if (event.type === 'version') {
+                check(event.data?.client)
+            }
+        } catch {
+            // Ignore malformed events
+        }
+    })
+}
+
+function reload() {
+    window.location.reload()
+}
+
+export function useVersion() {
+    return {
+        loaded,
+        stale,
+        watch,
+        reload
+    }
+}
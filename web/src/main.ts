@@ -4,6 +4,7 @@ import 'markstream-vue/index.css'
 import App from './App.vue'
 import router from './router'
 import { useTheme } from './composables/useTheme'
+import { fetchAuthStatus } from './api'
 
 // Initialize theme on app startup
 useTheme()
@@ -13,6 +14,22 @@ app.use(router)
 
 // Navigation guard for setup check
 router.beforeEach(async (to, _from, next) => {
+  if (to.path === '/login') {
+    next()
+    return
+  }
+
+  // Servers with an auth token or API keys sign browsers in first
+  try {
+    const auth = await fetchAuthStatus()
+    if (!auth.authenticated) {
+      next({ path: '/login', query: { redirect: to.fullPath } })
+      return
+    }
+  } catch {
+    // API failed, proceed anyway
+  }
+
   if (to.path === '/setup') {
     next()
     return
@@ -22,7 +39,11 @@ router.beforeEach(async (to, _from, next) => {
     const res = await fetch('/api/setup/status')
     const data = await res.json()
 
-    if (!data.ready && to.path !== '/setup') {
+    // Agents that need to log in are shown once per browser session
+    const needsLogin = (data.agents || []).some((a: { status: string }) => a.status === 'needs_login') &&
+      !sessionStorage.getItem('acp-login-skipped')
+
+    if ((!data.ready || needsLogin) && to.path !== '/setup') {
       next('/setup')
     } else {
       next()
@@ -7,6 +7,11 @@ const router = createRouter({
       path: '/',
       redirect: '/c'
     },
+    {
+      path: '/login',
+      name: 'login',
+      component: () => import('../views/LoginView.vue')
+    },
     {
       path: '/setup',
       name: 'setup',
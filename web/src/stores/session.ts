@@ -8,6 +8,9 @@ import type {
   Workspace,
   SlashCommand,
   MessageFile,
+  Message,
+  Routing,
+  ContextUsage,
 } from '../types'
 import * as api from '../api'
 
@@ -197,9 +200,21 @@ function addUserMessage(content: string, files?: MessageFile[]) {
   })
 }
 
-function addAssistantMessage(content: string, agent: string) {
+// Record why the latest user message went to its agent
+function setRouting(routing: Routing) {
   if (!currentSession.value) return
-  currentSession.value.messages.push({ role: 'assistant', content, agent })
+  const last = [...currentSession.value.messages].reverse().find((m) => m.role === 'user')
+  if (last) last.routing = routing
+}
+
+function setContextUsage(context: ContextUsage) {
+  if (!currentSession.value) return
+  currentSession.value.context = context
+}
+
+function addAssistantMessage(content: string, agent: string, kind?: Message['kind']) {
+  if (!currentSession.value) return
+  currentSession.value.messages.push({ role: 'assistant', content, agent, kind })
 }
 
 function addErrorMessage(content: string) {
@@ -235,6 +250,7 @@ function addToolCall(tool: ToolCall, sessionId?: string) {
       rawInput: tool.rawInput || existing.data.rawInput,
       output: tool.output || existing.data.output,
       error: tool.error || existing.data.error,
+      terminalIds: tool.terminalIds?.length ? tool.terminalIds : existing.data.terminalIds,
     }
     existing.data = merged
   } else {
@@ -243,6 +259,18 @@ function addToolCall(tool: ToolCall, sessionId?: string) {
   }
 }
 
+// setTerminalOutput shows a running terminal's output in the tool calls embedding it
+function setTerminalOutput(terminalId: string, output: string, sessionId?: string) {
+  const targetId = sessionId || sendingSessionId.value || currentSession.value?.id
+  if (!targetId) return
+
+  for (const item of streamItemsBySession.value[targetId] || []) {
+    if (item.type === 'tool' && item.data.terminalIds?.includes(terminalId)) {
+      item.data.output = output
+    }
+  }
+}
+
 function addStreamingText(text: string, sessionId?: string) {
   // Use provided sessionId or sendingSessionId or currentSession
   const targetId = sessionId || sendingSessionId.value || currentSession.value?.id
@@ -343,6 +371,15 @@ function setWorkspace(workspaceId: string) {
   // No need to clear streamItems - they are stored per session
 }
 
+// The current conversation continues in another workspace (/workspace)
+function followWorkspace(workspaceId: string) {
+  currentWorkspace.value = workspaceId
+  if (currentSession.value) {
+    currentSession.value.workspaceId = workspaceId
+  }
+  loadSessions(true)
+}
+
 function setSending(value: boolean) {
   isSending.value = value
 }
@@ -369,7 +406,12 @@ function setCommands(agentId: string, newCommands: SlashCommand[]) {
 }
 
 // Computed: current agent's commands
-const commands = computed(() => commandsByAgent.value[currentAgent.value] || [])
+// Commands acpone handles itself, offered next to the agent's
+const builtinCommands: SlashCommand[] = [
+  { name: 'workspace', description: 'Continue this conversation in another workspace', input: { hint: 'workspace ID or name' } },
+]
+
+const commands = computed(() => [...(commandsByAgent.value[currentAgent.value] || []), ...builtinCommands])
 
 export function useSessionStore() {
   return {
@@ -402,8 +444,11 @@ export function useSessionStore() {
     removeSession,
     addUserMessage,
     addAssistantMessage,
+    setRouting,
+    setContextUsage,
     addErrorMessage,
     addToolCall,
+    setTerminalOutput,
     addStreamingText,
     clearStreamItems,
     finalizeStreamItems,
@@ -411,6 +456,7 @@ export function useSessionStore() {
     setConversationId,
     setAgent,
     setWorkspace,
+    followWorkspace,
     setSending,
     setCommands,
     agentSessionId,
@@ -1,11 +1,41 @@
 export interface Agent {
   id: string
   name: string
+  aliases?: string[]
+  enabled?: boolean
   permissionMode?: 'default' | 'bypass' | string
   command?: string
   args?: string[]
   commands?: SlashCommand[]
   env?: Record<string, string>
+  // Installed package version, with the newest one once it was looked up
+  version?: { installed?: string; latest?: string; updateAvailable?: boolean }
+}
+
+// The process of an agent, from /api/agents/{id}/status
+export interface AgentProcess {
+  id: string
+  process: 'idle' | 'starting' | 'running' | 'error' | 'stopped' | string
+  init?: string
+  healthy: boolean
+  error?: string
+  pid?: number
+  startedAt?: number
+  starts?: number
+  pending?: number
+  turns?: number
+}
+
+// An agent template from the built-in catalog or ~/.acpone/catalog.json
+export interface CatalogAgent {
+  id: string
+  name: string
+  description?: string
+  command: string
+  args?: string[]
+  requires?: string
+  envHints?: string[]
+  added: boolean
 }
 
 export interface Workspace {
@@ -19,17 +49,36 @@ export interface SessionMeta {
   title: string
   activeAgent: string
   workspaceId?: string
+  branch?: string
   messageCount: number
   createdAt: number
   updatedAt: number
 }
 
+// A session matching a search, with where it matched
+export interface SessionSearchHit {
+  session: SessionMeta
+  matches: {
+    field: 'title' | 'text' | 'tool' | 'output'
+    message: number // -1 for the title
+    snippet: string
+  }[]
+}
+
 export interface MessageFile {
   name: string
   path: string
   size: number
 }
 
+export interface Routing {
+  agent: string
+  strategy: 'mention' | 'keyword' | 'meta' | 'pipeline' | 'team' | 'active' | 'default' | 'fallback'
+  rule?: string
+  match?: string
+  reason?: string
+}
+
 export interface Message {
   role: 'user' | 'assistant'
   content: string
@@ -38,6 +87,8 @@ export interface Message {
   timestamp?: number
   isError?: boolean
   files?: MessageFile[]
+  kind?: 'review' | 'commit' | 'truncated' | 'workspace'
+  routing?: Routing
 }
 
 export interface Session {
@@ -46,10 +97,31 @@ export interface Session {
   messages: Message[]
   activeAgent: string
   workspaceId?: string
+  pins?: Pin[]
+  branch?: string
+  context?: ContextUsage
   createdAt: number
   updatedAt: number
 }
 
+// Estimated conversation size against the agent's context window
+export interface ContextUsage {
+  agent: string
+  tokens: number
+  window: number
+  percent: number
+  warning?: boolean
+}
+
+// Context sent with every prompt of a conversation
+export interface Pin {
+  id: string
+  type: 'file' | 'url' | 'note'
+  name?: string
+  value: string
+  createdAt: number
+}
+
 export interface ToolCall {
   toolCallId: string
   toolName: string
@@ -61,6 +133,7 @@ export interface ToolCall {
   rawInput?: string
   output?: string
   error?: string
+  terminalIds?: string[] // Agent terminals whose output is the tool's output
 }
 
 // Unified stream item for rendering in order
@@ -77,6 +150,7 @@ export interface StreamEvent {
   update?: SessionUpdate
   sessionUpdate?: string
   stopReason?: string
+  truncated?: string // Why a turn exceeding its limits was stopped
   error?: string
 }
 